@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lrstanley/girc"
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/admin"
+	"github.com/dyuri/mqtt2irc/internal/bridge"
+	"github.com/dyuri/mqtt2irc/internal/bridge/processors"
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/health"
+	"github.com/dyuri/mqtt2irc/internal/persist"
+	"github.com/dyuri/mqtt2irc/internal/transform"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// version is set at build time via -ldflags "-X main.version=..."
+var version = "dev"
+
+func main() {
+	configPath := flag.String("config", "", "path to config file")
+	showVersion := flag.Bool("version", false, "print version and exit")
+	preset := flag.String("preset", "", "print a generated config for a built-in preset and exit (available: meshtastic)")
+	presetRegion := flag.String("region", "", "preset: region (e.g. EU_868, US)")
+	presetChannel := flag.String("channel", "", "preset: IRC channel to post to")
+	presetServer := flag.String("irc-server", "", "preset: IRC server (host:port)")
+	presetNick := flag.String("nick", "", "preset: IRC nickname")
+	listProcessors := flag.Bool("list-processors", false, "print the available message processors and exit")
+	importState := flag.String("import-state", "", "apply a state bundle (written by !state export) at startup")
+	safeMode := flag.Bool("safe-mode", false, "connect and process everything, but send nothing to IRC or MQTT and reject state-changing admin commands")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(version)
+		return
+	}
+
+	processors.RegisterBuiltins()
+	if *listProcessors {
+		for _, name := range bridge.List() {
+			fmt.Println(name)
+		}
+		return
+	}
+	if flag.Arg(0) == "describe" {
+		if err := describe(os.Stdout, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "describe: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *preset != "" {
+		out, err := config.RenderPreset(*preset, config.PresetOptions{
+			Region:    *presetRegion,
+			Channel:   *presetChannel,
+			IRCServer: *presetServer,
+			Nickname:  *presetNick,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "preset: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+		return
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.SafeMode = *safeMode
+
+	logger := setupLogger(cfg.Logging)
+	if cfg.InstanceName != "" {
+		logger = logger.With().Str("instance", cfg.InstanceName).Logger()
+	}
+	logger.Info().Str("version", version).Msg("starting mqtt2irc")
+	logger.Info().
+		Str("file", cfg.Source.File).
+		Strs("env_overrides", cfg.Source.EnvOverrides()).
+		Str("hash", cfg.Source.Hash).
+		Msg("configuration loaded")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// The bridge and health server outlive the signal while draining.
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	defer cancelRun()
+
+	persist.OnRecovery(func(r persist.Recovery) {
+		ev := logger.Warn().Err(r.Err).Str("path", r.Path).Str("moved_to", r.MovedTo)
+		switch {
+		case r.FromBackup:
+			ev.Msg("corrupt state file moved aside; recovered from backup")
+		case r.BackupErr != nil:
+			ev.AnErr("backup_error", r.BackupErr).Msg("corrupt state file moved aside; backup unusable, starting fresh")
+		default:
+			ev.Msg("corrupt state file moved aside; starting fresh")
+		}
+	})
+	processors.SetNodeDBPolicy(cfg.Bridge.Storage.NodeDB.Policy())
+	b, err := bridge.New(cfg, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create bridge")
+	}
+	b.SetVersion(version)
+	if *importState != "" {
+		if err := applyStateBundle(b, *importState); err != nil {
+			logger.Fatal().Err(err).Str("path", *importState).Msg("failed to import state bundle")
+		}
+	}
+	b.SetConfigLoader(func() (*config.Config, error) { return config.Load(*configPath) })
+
+	// Admin handler must be registered before the IRC client connects.
+	if cfg.Admin.Enabled {
+		ac := adminConfig(cfg.Admin, cfg.Tenants, cfg.InstanceName)
+		ac.SafeMode = cfg.SafeMode
+		h := admin.New(ac, b, func() {
+			_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		}, logger)
+		h.SetCommandCounter(b.CommandCounter())
+		b.AddIRCHandler(girc.PRIVMSG, h.GircHandler())
+		logger.Info().
+			Int("allow_list", len(cfg.Admin.AllowList)).
+			Strs("auth", cfg.Admin.Auth.AuthProviders()).
+			Msg("admin commands enabled")
+	}
+
+	var wg sync.WaitGroup
+
+	if cfg.Health.Enabled {
+		hs, err := health.New(cfg.Health, b, logger)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create health server")
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := hs.Start(runCtx); err != nil {
+				logger.Error().Err(err).Msg("health server error")
+			}
+		}()
+	}
+
+	runErr := make(chan error, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runErr <- b.Run(runCtx)
+	}()
+
+	select {
+	case err := <-runErr:
+		if err != nil {
+			logger.Error().Err(err).Msg("bridge failed")
+			cancelRun()
+			wg.Wait()
+			os.Exit(1)
+		}
+	case <-ctx.Done():
+	}
+
+	logger.Info().Msg("shutdown signal received")
+	stop() // a second signal terminates at once
+
+	if d := cfg.Bridge.DrainTimeout; d > 0 {
+		drainCtx, cancel := context.WithTimeout(context.Background(), d)
+		_ = b.Drain(drainCtx) // logged by Drain
+		cancel()
+	}
+	cancelRun()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := b.Shutdown(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("bridge shutdown error")
+	}
+
+	wg.Wait()
+	logger.Info().Msg("mqtt2irc stopped")
+}
+
+// setupLogger creates the root logger from the logging config.
+func setupLogger(cfg config.LoggingConfig) zerolog.Logger {
+	level, err := zerolog.ParseLevel(cfg.Level)
+	if err != nil {
+		level = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(level)
+
+	var logger zerolog.Logger
+	if cfg.Format == "console" {
+		logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339})
+	} else {
+		logger = zerolog.New(os.Stderr)
+	}
+	return logger.Level(level).With().Timestamp().Logger()
+}
+
+// adminConfig converts the loaded admin and tenants config sections into an admin.Config.
+func adminConfig(cfg config.AdminConfig, tenants []config.TenantConfig, instance string) admin.Config {
+	ac := admin.Config{
+		Enabled:       cfg.Enabled,
+		CommandPrefix: cfg.CommandPrefix,
+		AllowList:     allowEntries(cfg.AllowList),
+		Channels:      cfg.Channels,
+		AcceptPM:      cfg.AcceptPM,
+		ReplyMode:     cfg.ReplyMode,
+		Language:      cfg.Language,
+		FlowTimeout:   cfg.FlowTimeout,
+
+		PublicCommands: cfg.PublicCommands,
+		Instance:       instance,
+		RequireAddress: cfg.RequireAddress,
+		Templates: admin.Templates{
+			Status: cfg.Templates.Status,
+			Help:   cfg.Templates.Help,
+			Fields: cfg.Templates.Fields,
+		},
+	}
+	for _, p := range cfg.Auth.AuthProviders() {
+		switch p {
+		case "allow_list":
+			ac.Auth = append(ac.Auth, admin.NewAllowListAuth(ac.AllowList))
+		case "account":
+			ac.Auth = append(ac.Auth, admin.NewAccountAuth(cfg.Auth.Accounts))
+		case "oper":
+			ac.Auth = append(ac.Auth, admin.NewOperAuth())
+		case "webhook":
+			wh := cfg.Auth.Webhook
+			ac.Auth = append(ac.Auth, admin.NewWebhookAuth(wh.URL, wh.Token, wh.Timeout))
+		}
+	}
+	for _, t := range tenants {
+		ac.Tenants = append(ac.Tenants, admin.Tenant{
+			Name:      t.Name,
+			Channels:  t.Channels,
+			Operators: allowEntries(t.Operators),
+		})
+	}
+	return ac
+}
+
+func allowEntries(entries []config.AdminAllowEntry) []admin.AllowEntry {
+	allow := make([]admin.AllowEntry, 0, len(entries))
+	for _, e := range entries {
+		allow = append(allow, admin.AllowEntry{Nick: e.Nick, Hostmask: e.Hostmask})
+	}
+	return allow
+}
+
+// applyStateBundle imports the runtime state bundle at path into b.
+func applyStateBundle(b *bridge.Bridge, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = b.ImportState(f)
+	return err
+}
+
+// describe prints the registered components of a kind ("processors" or
+// "transforms") with their options, or only the named ones.
+func describe(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: mqtt2irc describe processors|transforms [name...]")
+	}
+	var docs []types.ComponentDoc
+	switch args[0] {
+	case "processors":
+		docs = bridge.Docs()
+	case "transforms":
+		docs = transform.Docs()
+	default:
+		return fmt.Errorf("unknown kind %q (available: processors, transforms)", args[0])
+	}
+	if names := args[1:]; len(names) > 0 {
+		byName := make(map[string]types.ComponentDoc, len(docs))
+		for _, doc := range docs {
+			byName[doc.Name] = doc
+		}
+		docs = docs[:0]
+		for _, name := range names {
+			doc, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("no %s named %q", strings.TrimSuffix(args[0], "s"), name)
+			}
+			docs = append(docs, doc)
+		}
+	}
+	for i, doc := range docs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, doc.Name)
+		if doc.Summary != "" {
+			fmt.Fprintf(w, "  %s\n", doc.Summary)
+		}
+		for _, opt := range doc.Options {
+			attrs := opt.Type
+			switch {
+			case opt.Required:
+				attrs += ", required"
+			case opt.Default != "":
+				attrs += ", default " + opt.Default
+			}
+			fmt.Fprintf(w, "    %s (%s)\n        %s\n", opt.Name, attrs, opt.Description)
+		}
+	}
+	return nil
+}
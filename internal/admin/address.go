@@ -0,0 +1,91 @@
+package admin
+
+import "strings"
+
+// address works out whether a message is a command for this bridge, for
+// channels shared with other bots. A command is addressed to this bridge by
+// its nick ("mqttbot: status", "mqttbot, !status") or its instance_name
+// ("!status @mesh-eu"). It returns the command line in the usual form,
+// prefix first and without the address, and false if the message is for
+// another bot or instance, or is unaddressed while require_address is set.
+// PMs are always addressed; text that is no command is returned as is.
+func (h *Handler) address(text, botNick string, isPM bool) (string, bool) {
+	addressed := isPM
+	if rest, ok := cutNick(text, botNick); ok {
+		if !strings.HasPrefix(rest, h.cfg.CommandPrefix) {
+			rest = h.cfg.CommandPrefix + rest
+		}
+		text, addressed = rest, true
+	}
+	if !strings.HasPrefix(text, h.cfg.CommandPrefix) {
+		return text, true
+	}
+
+	text, toInstance, ok := h.addressedTo(text)
+	if !ok {
+		return "", false
+	}
+	if h.cfg.RequireAddress && !addressed && !toInstance {
+		return "", false
+	}
+	return text, true
+}
+
+// cutNick splits "nick: rest" or "nick, rest" (nick case-insensitive) into
+// rest.
+func cutNick(text, nick string) (string, bool) {
+	if nick == "" || len(text) <= len(nick) || !strings.EqualFold(text[:len(nick)], nick) {
+		return "", false
+	}
+	if c := text[len(nick)]; c != ':' && c != ',' {
+		return "", false
+	}
+	rest := strings.TrimLeft(text[len(nick)+1:], " \t")
+	return rest, rest != ""
+}
+
+// addressedTo splits an instance address off a command line: "!status
+// @mesh-eu" is for the bridge whose instance_name is mesh-eu only. It
+// returns the command line without the address, whether it had one, and
+// whether this bridge should handle it; commands without an address are
+// for every bridge.
+func (h *Handler) addressedTo(text string) (cmd string, addressed, ok bool) {
+	trimmed := strings.TrimRight(text, " \t")
+	i := strings.LastIndexAny(trimmed, " \t")
+	if i < 0 {
+		return text, false, true
+	}
+	name, found := strings.CutPrefix(trimmed[i+1:], "@")
+	if !found || !isInstanceName(name) {
+		return text, false, true
+	}
+	if h.cfg.Instance == "" || !strings.EqualFold(name, h.cfg.Instance) {
+		return "", true, false
+	}
+	return strings.TrimRight(trimmed[:i], " \t"), true, true
+}
+
+// isInstanceName reports whether s can be an instance_name (letters, digits,
+// '.', '_' and '-'), so "@bob\"" at the end of a quoted argument is not
+// taken for an address.
+func isInstanceName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// withInstance prefixes a reply with the instance name, if there is one.
+func (h *Handler) withInstance(line string) string {
+	if h.cfg.Instance == "" {
+		return line
+	}
+	return "[" + h.cfg.Instance + "] " + line
+}
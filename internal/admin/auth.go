@@ -0,0 +1,238 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lrstanley/girc"
+)
+
+// DefaultWebhookTimeout bounds a webhook authorization check.
+const DefaultWebhookTimeout = 5 * time.Second
+
+// AuthRequest describes a command attempt to an Authorizer.
+type AuthRequest struct {
+	Nick     string `json:"nick"`
+	Hostmask string `json:"hostmask"` // ident@host
+	Account  string `json:"account"`  // services account; empty if not identified or not known
+	Oper     bool   `json:"oper"`     // IRC operator; only looked up (by WHOIS) when the oper provider is used
+	Command  string `json:"command"`  // command name, e.g. "reload"
+	Target   string `json:"target"`   // channel, or the bot's nick for a PM
+}
+
+// Authorizer decides whether the sender of a command is a bridge admin. The
+// configured authorizers are tried in order; the first to allow wins, and an
+// error counts as a denial.
+type Authorizer interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthRequest) (bool, error)
+}
+
+// remoteAuthorizer is implemented by authorizers that need more than the
+// message to decide: a WHOIS or a network call. They run off the girc event
+// loop, after the local authorizers denied.
+type remoteAuthorizer interface {
+	Authorizer
+	// needsWhois reports whether req lacks something only a WHOIS tells.
+	needsWhois(req AuthRequest) bool
+}
+
+// NewAllowListAuth allows the senders matching an allow-list entry.
+func NewAllowListAuth(entries []AllowEntry) Authorizer {
+	return allowListAuth(entries)
+}
+
+type allowListAuth []AllowEntry
+
+func (allowListAuth) Name() string { return "allow_list" }
+
+func (a allowListAuth) Authorize(_ context.Context, req AuthRequest) (bool, error) {
+	return matchesAllowList(a, req.Nick, req.Hostmask), nil
+}
+
+// NewAccountAuth allows senders identified to services (NickServ) with one
+// of accounts. The account comes from the message's account tag, girc's user
+// tracking or a WHOIS.
+func NewAccountAuth(accounts []string) Authorizer {
+	return accountAuth(accounts)
+}
+
+type accountAuth []string
+
+func (accountAuth) Name() string { return "account" }
+
+func (a accountAuth) Authorize(_ context.Context, req AuthRequest) (bool, error) {
+	return a.allows(req.Account), nil
+}
+
+func (a accountAuth) allows(account string) bool {
+	return account != "" && containsFold(a, account)
+}
+
+func (accountAuth) needsWhois(req AuthRequest) bool { return req.Account == "" }
+
+// NewOperAuth allows IRC operators, as reported by WHOIS.
+func NewOperAuth() Authorizer {
+	return operAuth{}
+}
+
+type operAuth struct{}
+
+func (operAuth) Name() string { return "oper" }
+
+func (operAuth) Authorize(_ context.Context, req AuthRequest) (bool, error) {
+	return req.Oper, nil
+}
+
+func (operAuth) needsWhois(AuthRequest) bool { return true }
+
+// NewWebhookAuth asks an HTTP endpoint: the AuthRequest is POSTed as JSON,
+// with token (if set) as a bearer token. A 2xx status allows, 401 and 403
+// deny, anything else is an error.
+func NewWebhookAuth(url, token string, timeout time.Duration) Authorizer {
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	return &webhookAuth{url: url, token: token, client: &http.Client{Timeout: timeout}}
+}
+
+type webhookAuth struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (*webhookAuth) Name() string { return "webhook" }
+
+func (w *webhookAuth) Authorize(ctx context.Context, req AuthRequest) (bool, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if w.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+w.token)
+	}
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+func (*webhookAuth) needsWhois(AuthRequest) bool { return false }
+
+// authorize runs the authorizers and calls then with the result: right away
+// if a local one (e.g. the allow list) allows or there are no remote ones,
+// otherwise from a goroutine once the WHOIS and remote checks are done, since
+// girc handlers must not block.
+func (h *Handler) authorize(client *girc.Client, req AuthRequest, then func(admin bool)) {
+	var remote []remoteAuthorizer
+	for _, a := range h.auth {
+		if r, ok := a.(remoteAuthorizer); ok {
+			remote = append(remote, r)
+			continue
+		}
+		if h.check(context.Background(), a, req) {
+			then(true)
+			return
+		}
+	}
+	if len(remote) == 0 {
+		then(false)
+		return
+	}
+
+	go func() {
+		for _, r := range remote {
+			if r.needsWhois(req) {
+				account, oper := h.whois(client, req.Nick)
+				if req.Account == "" {
+					req.Account = account
+				}
+				req.Oper = oper
+				break
+			}
+		}
+		for _, r := range remote {
+			if h.check(context.Background(), r, req) {
+				then(true)
+				return
+			}
+		}
+		then(false)
+	}()
+}
+
+// check runs one authorizer, logging errors as denials.
+func (h *Handler) check(ctx context.Context, a Authorizer, req AuthRequest) bool {
+	ok, err := a.Authorize(ctx, req)
+	if err != nil {
+		h.logger.Warn().Err(err).Str("provider", a.Name()).Str("nick", req.Nick).Msg("admin authorization failed")
+		return false
+	}
+	if ok {
+		h.logger.Debug().Str("provider", a.Name()).Str("nick", req.Nick).Msg("admin authorized")
+	}
+	return ok
+}
+
+// accountOf returns the sender's services account from the message's account
+// tag or girc's user tracking, or "" if neither knows it.
+func accountOf(client *girc.Client, event girc.Event) string {
+	if account, _ := event.Tags.Get("account"); account != "" {
+		return account
+	}
+	if user := client.LookupUser(event.Source.Name); user != nil {
+		return user.Extras.Account
+	}
+	return ""
+}
+
+// whois looks up nick's services account and oper status, waiting up to
+// whoisTimeout for the reply. It must not be called from a girc handler.
+func (h *Handler) whois(client *girc.Client, nick string) (account string, oper bool) {
+	var mu sync.Mutex
+	_, done := client.Handlers.AddTmp(girc.ALL_EVENTS, whoisTimeout, func(_ *girc.Client, e girc.Event) bool {
+		if len(e.Params) < 2 || !strings.EqualFold(e.Params[1], nick) {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch e.Command {
+		case girc.RPL_WHOISACCOUNT:
+			if len(e.Params) > 2 {
+				account = e.Params[2]
+			}
+		case girc.RPL_WHOISOPERATOR:
+			oper = true
+		case girc.RPL_ENDOFWHOIS:
+			return true
+		}
+		return false
+	})
+	client.Cmd.Whois(nick)
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	return account, oper
+}
@@ -3,123 +3,955 @@ package admin
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/lrstanley/girc"
+
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
-// dispatch parses the command text and calls the appropriate handler.
+// tenantCommands are the commands available to tenant operators.
+var tenantCommands = map[string]bool{"help": true, "status": true, "health": true, "mapping": true, "mappings": true}
+
+// dispatch runs a command with full admin access.
 func (h *Handler) dispatch(client *girc.Client, replyTo, text string) {
-	// Strip prefix and split into command + args.
-	withoutPrefix := strings.TrimPrefix(text, h.cfg.CommandPrefix)
-	parts := strings.Fields(withoutPrefix)
-	if len(parts) == 0 {
+	h.dispatchFor(client, replyTo, "", "", text, nil)
+}
+
+// dispatchFor parses the command text and calls the appropriate handler.
+// sender and hostmask identify the issuer; acc limits tenant operators to
+// their own mappings (nil = bridge admin).
+func (h *Handler) dispatchFor(client *girc.Client, replyTo, sender, hostmask, text string, acc *access) {
+	c, err := parseCommand(text, h.cfg.CommandPrefix)
+	if err != nil {
+		h.commands.Invoked(sender, "", h.clock.Now())
+		h.fail(client, replyTo, sender, "", h.tr("Cannot parse command: %v", err))
 		return
 	}
+	if c.name == "" {
+		return
+	}
+	cmd, args := c.name, c.args
+	h.commands.Invoked(sender, cmd, h.clock.Now())
 
-	cmd := strings.ToLower(parts[0])
-	args := parts[1:]
+	if acc != nil && !tenantCommands[cmd] {
+		h.fail(client, replyTo, sender, cmd, h.tr("Not permitted: %s%s requires a bridge admin", h.cfg.CommandPrefix, cmd))
+		return
+	}
+	if h.cfg.SafeMode && changesState(cmd, args) {
+		h.fail(client, replyTo, sender, cmd, h.tr("Not permitted in safe mode: %s%s changes state", h.cfg.CommandPrefix, cmd))
+		return
+	}
 
 	switch cmd {
 	case "help":
 		h.cmdHelp(client, replyTo)
 	case "status", "health":
-		h.cmdStatus(client, replyTo)
+		h.cmdStatus(client, replyTo, args)
+	case "features":
+		h.cmdFeatures(client, replyTo)
 	case "nick":
 		h.cmdNick(client, replyTo, args)
 	case "reconnect":
 		h.cmdReconnect(client, replyTo, args)
+	case "mapping", "mappings":
+		if len(args) > 0 && strings.EqualFold(args[0], "add") {
+			h.cmdMappingAdd(client, replyTo, sender, hostmask, c, acc)
+			return
+		}
+		h.cmdMapping(client, replyTo, sender, c, acc)
+	case "queue":
+		h.cmdQueue(client, replyTo, sender, args)
+	case "reload":
+		h.cmdReload(client, replyTo, sender, args)
+	case "ping":
+		h.cmdPing(client, replyTo, sender, cmd, "pong")
+	case "echo":
+		if len(args) == 0 {
+			h.reply(client, replyTo, h.tr("Usage: %secho <text>", h.cfg.CommandPrefix))
+			return
+		}
+		h.cmdPing(client, replyTo, sender, cmd, strings.Join(args, " "))
+	case "trace":
+		h.cmdTrace(client, replyTo, sender, args)
+	case "watch":
+		h.cmdWatch(client, replyTo, sender, args)
+	case "mute":
+		h.cmdMute(client, replyTo, sender, c)
+	case "unmute":
+		h.cmdUnmute(client, replyTo, args)
+	case "mutes":
+		h.cmdMutes(client, replyTo)
+	case "stats":
+		h.cmdStats(client, replyTo, args)
+	case "backfill":
+		h.cmdBackfill(client, replyTo, sender, args)
+	case "fields":
+		h.cmdFields(client, replyTo, sender, args)
+	case "approve", "reject", "review":
+		h.cmdReview(client, replyTo, sender, cmd, args)
+	case "ack":
+		h.cmdAck(client, replyTo, sender, args)
+	case "state":
+		h.cmdState(client, replyTo, sender, args)
+	case "purge":
+		h.cmdPurge(client, replyTo, sender, args)
 	case "shutdown":
 		h.cmdShutdown(client, replyTo)
 	default:
-		h.reply(client, replyTo, fmt.Sprintf("Unknown command: %s%s — try %shelp", h.cfg.CommandPrefix, cmd, h.cfg.CommandPrefix))
+		h.fail(client, replyTo, sender, cmd, h.tr("Unknown command: %s%s — try %shelp", h.cfg.CommandPrefix, cmd, h.cfg.CommandPrefix))
+	}
+}
+
+// changesState reports whether a command changes the bridge, its
+// connections or stored data, or publishes something, and is rejected in
+// safe mode. Commands that only show something, or whose output to IRC safe
+// mode suppresses (!backfill), are allowed; so is !shutdown.
+func changesState(cmd string, args []string) bool {
+	sub := ""
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	switch cmd {
+	case "nick", "reconnect", "mute", "unmute", "approve", "reject", "ack", "state", "purge":
+		return true
+	case "mapping", "mappings":
+		return sub != "" && sub != "list"
+	case "queue":
+		return sub != ""
+	case "reload":
+		return sub == "apply"
 	}
+	return false
 }
 
 func (h *Handler) cmdHelp(client *girc.Client, replyTo string) {
-	p := h.cfg.CommandPrefix
-	lines := []string{
-		fmt.Sprintf("Admin commands (prefix: %s):", p),
-		fmt.Sprintf("  %shelp                — show this help", p),
-		fmt.Sprintf("  %sstatus / %shealth    — show bridge connection status", p, p),
-		fmt.Sprintf("  %snick <newnick>      — change bot IRC nickname", p),
-		fmt.Sprintf("  %sreconnect mqtt      — reconnect to MQTT broker", p),
-		fmt.Sprintf("  %sreconnect irc       — reconnect to IRC server", p),
-		fmt.Sprintf("  %sshutdown            — gracefully shut down the bridge", p),
-	}
-	for _, line := range lines {
+	for _, line := range h.helpLines() {
 		h.reply(client, replyTo, line)
 	}
 }
 
-func (h *Handler) cmdStatus(client *girc.Client, replyTo string) {
+// helpLines returns the !help reply, laid out by the help template if one is
+// configured.
+func (h *Handler) helpLines() []string {
+	lines := h.builtinHelp()
+	if h.templates.help == nil {
+		return lines
+	}
+	data := helpData{Prefix: h.cfg.CommandPrefix, Lines: lines, Instance: h.cfg.Instance, Hostname: h.templates.hostname, Fields: h.templates.fields}
+	if out, ok := h.render(h.templates.help, data); ok {
+		return out
+	}
+	return lines
+}
+
+func (h *Handler) builtinHelp() []string {
+	p := h.cfg.CommandPrefix
+	return []string{
+		h.tr("Admin commands (prefix: %s; quote arguments with spaces):", p),
+		h.tr("  %shelp                — show this help", p),
+		h.tr("  %sstatus / %shealth    — show bridge connection status", p, p),
+		h.tr("  %sstatus detail       — also show recent connect/disconnect events", p),
+		h.tr("  %sfeatures            — show the version, enabled features, processors and transforms", p),
+		h.tr("  %swhoami              — show how the bridge sees you: hostmask, account, allow-list match and role", p),
+		h.tr("  %sping / %secho <text> — reply through the rate limiter with wait time, queue and pace", p, p),
+		h.tr("  %snick <newnick>      — change bot IRC nickname", p),
+		h.tr("  %sreconnect mqtt      — reconnect to MQTT broker", p),
+		h.tr("  %sreconnect irc       — reconnect to IRC server", p),
+		h.tr("  %smapping list        — list mappings and their state", p),
+		h.tr("  %smapping pause|resume <n> — stop/start forwarding mapping #n", p),
+		h.tr("  %smapping format <n> <template> — change the message format of mapping #n", p),
+		h.tr("  %smapping add [topic] [#chan,...] [template] — add a mapping; asks by PM for what is missing", p),
+		h.tr("  %squeue drain         — deliver all queued messages now, oldest first", p),
+		h.tr("  %squeue clear [topic] — discard queued messages (of one mapping)", p),
+		h.tr("  %strace <topic> <duration> — PM you a step-by-step trace of messages on a topic", p),
+		h.tr("  %strace stop          — end your traces", p),
+		h.tr("  %swatch <topic> <field> <op> <value> [duration] — tell you here when a payload field meets a condition", p),
+		h.tr("  %swatch list | stop [id] — list watches, or end yours", p),
+		h.tr("  %smute <topic|node> <duration> — suppress a topic pattern or mesh node for a while (or --for <duration>)", p),
+		h.tr("  %sunmute <topic|node> — remove a mute", p),
+		h.tr("  %smutes               — list active mutes", p),
+		h.tr("  %sstats drops         — count dropped messages by reason", p),
+		h.tr("  %sstats admin         — count admin commands, failures and unauthorized attempts by nick", p),
+		h.tr("  %sstats topics        — count messages per topic, busiest first (bridge.topic_stats)", p),
+		h.tr("  %sbackfill <#channel> <duration> — resend the channel's recent messages, labeled as replay", p),
+		h.tr("  %sfields <topic>      — list the JSON fields of recent payloads, with example values", p),
+		h.tr("  %sreview              — list messages held in review channels", p),
+		h.tr("  %sapprove|reject <id>... — forward a held message to its channel, or drop it", p),
+		h.tr("  %sack <id> [comment]  — acknowledge an alert: publish the ack and suppress its repeats", p),
+		h.tr("  %sstate export        — write mutes, mapping changes and processor state to the state bundle", p),
+		h.tr("  %spurge node|nick|topic <value> — remove a node, nick or topic from the node registry, history and caches", p),
+		h.tr("  %sreload              — show what reloading the config file would change", p),
+		h.tr("  %sreload apply        — apply the previewed mapping/subscription changes", p),
+		h.tr("  %sshutdown            — gracefully shut down the bridge", p),
+	}
+}
+
+func (h *Handler) cmdStatus(client *girc.Client, replyTo string, args []string) {
 	status := h.bridge.HealthStatus()
-	mqttOK, _ := status["mqtt_connected"].(bool)
-	ircOK, _ := status["irc_connected"].(bool)
-	queueSize, _ := status["queue_size"].(int)
-	queueCap, _ := status["queue_capacity"].(int)
+	for _, line := range h.statusLines(status) {
+		h.reply(client, replyTo, line)
+	}
+
+	if len(args) > 0 && strings.EqualFold(args[0], "detail") {
+		h.reply(client, replyTo, h.catalog.historyLine("MQTT", status["mqtt_history"], status["mqtt_flapping"]))
+		h.reply(client, replyTo, h.catalog.historyLine("IRC", status["irc_history"], status["irc_flapping"]))
+	}
+}
+
+// statusLines returns the !status reply for a health report, rendered by the
+// status template if one is configured.
+func (h *Handler) statusLines(status map[string]interface{}) []string {
+	d := statusData{Health: status, Instance: h.cfg.Instance, Hostname: h.templates.hostname, Fields: h.templates.fields}
+	d.MQTTConnected, _ = status["mqtt_connected"].(bool)
+	d.IRCConnected, _ = status["irc_connected"].(bool)
+	d.Queue, _ = status["queue_size"].(int)
+	d.QueueCapacity, _ = status["queue_capacity"].(int)
 
-	mqttStr := "connected"
-	if !mqttOK {
-		mqttStr = "DISCONNECTED"
+	d.MQTT = h.tr("connected")
+	if !d.MQTTConnected {
+		d.MQTT = h.tr("DISCONNECTED")
 	}
-	ircStr := "connected"
-	if !ircOK {
-		ircStr = "DISCONNECTED"
+	d.IRC = h.tr("connected")
+	if !d.IRCConnected {
+		d.IRC = h.tr("DISCONNECTED")
 	}
 
-	h.reply(client, replyTo, fmt.Sprintf(
-		"Bridge status: MQTT=%s IRC=%s queue=%d/%d",
-		mqttStr, ircStr, queueSize, queueCap,
-	))
+	if h.templates.status != nil {
+		if lines, ok := h.render(h.templates.status, d); ok {
+			return lines
+		}
+	}
+	return []string{h.withInstance(h.tr("Bridge status: MQTT=%s IRC=%s queue=%d/%d", d.MQTT, d.IRC, d.Queue, d.QueueCapacity))}
+}
+
+// cmdFeatures handles !features: the capabilities document, as published to
+// bridge.capabilities_topic.
+func (h *Handler) cmdFeatures(client *girc.Client, replyTo string) {
+	caps := h.bridge.Capabilities()
+	list := func(names []string) string {
+		if len(names) == 0 {
+			return "-"
+		}
+		return strings.Join(names, ", ")
+	}
+	h.reply(client, replyTo, h.tr("mqtt2irc %s, %d mapping(s), capabilities schema %d", caps.Version, caps.Mappings, caps.Schema))
+	h.reply(client, replyTo, h.tr("Features: %s", list(caps.Features)))
+	h.reply(client, replyTo, h.tr("Processors: %s", list(caps.Processors)))
+	h.reply(client, replyTo, h.tr("Transforms: %s", list(caps.Transforms)))
+}
+
+// statusDetailEvents is the number of most recent connection events shown by !status detail.
+const statusDetailEvents = 5
+
+// historyLine renders the most recent connection events as a single reply line,
+// e.g. "MQTT history: 12:00:01 up, 12:03:04 down (EOF)".
+func historyLine(name string, history, flapping interface{}) string {
+	return catalog(nil).historyLine(name, history, flapping)
+}
+
+func (c catalog) historyLine(name string, history, flapping interface{}) string {
+	events, _ := history.([]connstate.Event)
+	if len(events) == 0 {
+		return c.tr("%s history: no events", name)
+	}
+	if len(events) > statusDetailEvents {
+		events = events[len(events)-statusDetailEvents:]
+	}
+	parts := make([]string, 0, len(events))
+	for _, ev := range events {
+		state := c.tr("down")
+		if ev.Connected {
+			state = c.tr("up")
+		}
+		part := ev.Time.Format("01-02 15:04:05") + " " + state
+		if !ev.Connected && ev.Detail != "" {
+			part += " (" + ev.Detail + ")"
+		}
+		parts = append(parts, part)
+	}
+	line := c.tr("%s history: %s", name, strings.Join(parts, ", "))
+	if f, _ := flapping.(bool); f {
+		line += c.tr(" [FLAPPING]")
+	}
+	return line
 }
 
 func (h *Handler) cmdNick(client *girc.Client, replyTo string, args []string) {
 	if len(args) == 0 {
-		h.reply(client, replyTo, "Usage: !nick <newnick>")
+		h.reply(client, replyTo, h.tr("Usage: !nick <newnick>"))
 		return
 	}
 	newnick := args[0]
 	if len(newnick) > 30 {
-		h.reply(client, replyTo, "Nick too long (max 30 characters)")
+		h.reply(client, replyTo, h.tr("Nick too long (max 30 characters)"))
 		return
 	}
 	if strings.ContainsAny(newnick, " \t\r\n") {
-		h.reply(client, replyTo, "Invalid nick: must not contain whitespace")
+		h.reply(client, replyTo, h.tr("Invalid nick: must not contain whitespace"))
 		return
 	}
 	h.logger.Info().Str("newnick", newnick).Msg("admin nick change")
 	h.bridge.NickChange(newnick)
-	h.reply(client, replyTo, fmt.Sprintf("Changing nick to: %s", newnick))
+	h.reply(client, replyTo, h.tr("Changing nick to: %s", newnick))
 }
 
 func (h *Handler) cmdReconnect(client *girc.Client, replyTo string, args []string) {
 	if len(args) == 0 {
-		h.reply(client, replyTo, "Usage: !reconnect <mqtt|irc>")
+		h.reply(client, replyTo, h.tr("Usage: !reconnect <mqtt|irc>"))
 		return
 	}
 	switch strings.ToLower(args[0]) {
 	case "mqtt":
 		h.logger.Info().Msg("admin MQTT reconnect")
-		h.reply(client, replyTo, "Reconnecting to MQTT broker...")
+		h.reply(client, replyTo, h.tr("Reconnecting to MQTT broker..."))
 		h.bridge.ReconnectMQTT()
 	case "irc":
 		h.logger.Info().Msg("admin IRC reconnect")
-		h.reply(client, replyTo, "Reconnecting to IRC server...")
+		h.reply(client, replyTo, h.tr("Reconnecting to IRC server..."))
 		h.bridge.ReconnectIRC()
 	default:
-		h.reply(client, replyTo, fmt.Sprintf("Unknown target: %s (use 'mqtt' or 'irc')", args[0]))
+		h.reply(client, replyTo, h.tr("Unknown target: %s (use 'mqtt' or 'irc')", args[0]))
+	}
+}
+
+func (h *Handler) cmdMapping(client *girc.Client, replyTo, sender string, c command, acc *access) {
+	args := c.args
+	action := "list"
+	if len(args) > 0 {
+		action = strings.ToLower(args[0])
+	}
+
+	if action == "list" {
+		shown := 0
+		for _, m := range h.bridge.Mappings() {
+			if !acc.allows(m.Tenant) {
+				continue
+			}
+			h.reply(client, replyTo, h.catalog.mappingLine(m))
+			shown++
+		}
+		if shown == 0 {
+			h.reply(client, replyTo, h.tr("No mappings"))
+		}
+		return
+	}
+
+	if len(args) < 2 || (action == "format" && len(args) < 3) {
+		h.reply(client, replyTo, h.tr("Usage: !mapping <list|add|pause <n>|resume <n>|format <n> <template>>"))
+		return
+	}
+	index, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+	if err != nil {
+		h.reply(client, replyTo, h.tr("Invalid mapping number: %s", args[1]))
+		return
+	}
+	var target *types.MappingInfo
+	for _, m := range h.bridge.Mappings() {
+		if m.Index == index {
+			m := m
+			target = &m
+			break
+		}
+	}
+	if target == nil || !acc.allows(target.Tenant) {
+		// Do not reveal other tenants' mappings.
+		h.reply(client, replyTo, h.tr("No mapping #%d", index))
+		return
+	}
+
+	switch action {
+	case "pause", "resume":
+		paused := action == "pause"
+		if err := h.bridge.PauseMapping(index, paused); err != nil {
+			h.fail(client, replyTo, sender, "mapping", h.tr("Mapping %s failed: %v", action, err))
+			return
+		}
+		h.logger.Info().Int("mapping", index).Bool("paused", paused).Msg("admin mapping pause")
+		state := h.tr("resumed")
+		if paused {
+			state = h.tr("paused")
+		}
+		h.reply(client, replyTo, h.tr("Mapping #%d (%s) %s", index, target.Topic, state))
+	case "format":
+		format := c.rest(2)
+		if err := h.bridge.SetMappingFormat(index, format); err != nil {
+			h.fail(client, replyTo, sender, "mapping", h.tr("Mapping format failed: %v", err))
+			return
+		}
+		h.logger.Info().Int("mapping", index).Str("format", format).Msg("admin mapping format change")
+		h.reply(client, replyTo, h.tr("Mapping #%d (%s) format set to: %s", index, target.Topic, format))
+	default:
+		h.reply(client, replyTo, h.tr("Unknown mapping action: %s (use list, add, pause, resume or format)", args[0]))
+	}
+}
+
+// cmdMappingAdd adds a mapping from "!mapping add [topic] [#chan,...]
+// [template]". Missing pieces are asked for by PM (see flow.go); "default"
+// as the template keeps the default format.
+func (h *Handler) cmdMappingAdd(client *girc.Client, replyTo, sender, hostmask string, c command, acc *access) {
+	p := h.cfg.CommandPrefix
+	if acc != nil {
+		h.reply(client, replyTo, h.tr("Not permitted: %smapping add requires a bridge admin", p))
+		return
+	}
+	answers := make(map[string]string)
+	if len(c.args) > 1 {
+		answers["topic"] = c.args[1]
+	}
+	if len(c.args) > 2 {
+		answers["channels"] = c.args[2]
+	}
+	if len(c.args) > 3 {
+		answers["format"] = c.rest(3)
+	}
+
+	f := &flow{
+		name:    p + "mapping add",
+		answers: answers,
+		steps: []flowStep{
+			{key: "topic", prompt: "Which MQTT topic pattern? (e.g. sensors/+/temperature)", check: checkTopicPattern},
+			{key: "channels", prompt: "Which channel(s)? (comma-separated, e.g. #sensors,#ops)", check: checkChannelList},
+			{key: "format", prompt: "Which message format? (template, e.g. {{.Topic}}: {{.Payload}}, or \"default\")"},
+		},
+		finish: func(a map[string]string) string {
+			format := a["format"]
+			if strings.EqualFold(format, "default") {
+				format = ""
+			}
+			channels := strings.Split(a["channels"], ",")
+			index, err := h.bridge.AddMapping(a["topic"], channels, format)
+			if err != nil {
+				h.commands.Failed(sender, "mapping", h.clock.Now())
+				return h.tr("Mapping add failed: %v", err)
+			}
+			h.logger.Info().Int("mapping", index).Str("topic", a["topic"]).Strs("channels", channels).Str("format", format).Msg("admin mapping add")
+			return h.tr("Added mapping #%d %s → %s (until the next reload or restart)", index, a["topic"], strings.Join(channels, ","))
+		},
+	}
+	for _, step := range f.steps {
+		if v, ok := answers[step.key]; ok && step.check != nil {
+			if err := step.check(v); err != nil {
+				h.fail(client, replyTo, sender, "mapping", h.tr("Mapping add failed: %v", err))
+				return
+			}
+		}
+	}
+
+	if sender == "" {
+		sender = replyTo
+	}
+	if _, missing := f.next(); missing && !strings.EqualFold(replyTo, sender) {
+		h.reply(client, replyTo, h.tr("%s: continuing by PM", sender))
+	}
+	h.startFlow(client, replyTo, sender, hostmask, f)
+}
+
+func checkTopicPattern(topic string) error {
+	if strings.ContainsAny(topic, " \t") || topic == "" {
+		return fmt.Errorf("invalid topic pattern %q", topic)
+	}
+	return nil
+}
+
+func checkChannelList(list string) error {
+	for _, channel := range strings.Split(list, ",") {
+		if !girc.IsValidChannel(channel) {
+			return fmt.Errorf("invalid channel %q", channel)
+		}
+	}
+	return nil
+}
+
+// mappingLine renders a mapping for !mapping list,
+// e.g. "#2 sensors/# → #iot [tenant: hackerspace] (paused)".
+func mappingLine(m types.MappingInfo) string {
+	return catalog(nil).mappingLine(m)
+}
+
+func (c catalog) mappingLine(m types.MappingInfo) string {
+	line := fmt.Sprintf("#%d %s → %s", m.Index, m.Topic, strings.Join(m.Channels, ","))
+	if m.Tenant != "" {
+		line += c.tr(" [tenant: %s]", m.Tenant)
+	}
+	if m.Paused {
+		line += c.tr(" (paused)")
+	}
+	return line
+}
+
+func (h *Handler) cmdQueue(client *girc.Client, replyTo, sender string, args []string) {
+	if len(args) == 0 {
+		h.reply(client, replyTo, h.tr("Usage: !queue <drain|clear [mapping]>"))
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "drain":
+		h.logger.Info().Msg("admin queue drain")
+		n, err := h.bridge.DrainQueue()
+		if err != nil {
+			h.fail(client, replyTo, sender, "queue", h.tr("Queue drain failed: %v", err))
+			return
+		}
+		h.reply(client, replyTo, h.tr("Draining %d queued messages", n))
+	case "clear":
+		mapping := ""
+		if len(args) > 1 {
+			mapping = args[1]
+		}
+		h.logger.Info().Str("mapping", mapping).Msg("admin queue clear")
+		n, err := h.bridge.ClearQueue(mapping)
+		if err != nil {
+			h.fail(client, replyTo, sender, "queue", h.tr("Queue clear failed: %v", err))
+			return
+		}
+		if mapping != "" {
+			h.reply(client, replyTo, h.tr("Discarded %d queued messages for %s", n, mapping))
+			return
+		}
+		h.reply(client, replyTo, h.tr("Discarded %d queued messages", n))
+	default:
+		h.reply(client, replyTo, h.tr("Unknown queue action: %s (use 'drain' or 'clear')", args[0]))
+	}
+}
+
+// cmdPing replies with text through the bridge's rate-limited send path, so
+// the reply shows how long a message currently waits before it goes out.
+func (h *Handler) cmdPing(client *girc.Client, replyTo, sender, cmd, text string) {
+	err := h.bridge.SendTimed(context.Background(), replyTo, func(s types.SendStats) string {
+		return h.tr("%s — wait %s, queue %d/%d, pace %d msg/min (limit %g/s, burst %d)",
+			text, s.Wait.Round(time.Millisecond), s.QueueSize, s.QueueCapacity, s.Pace, s.Rate, s.Burst)
+	})
+	if err != nil {
+		h.fail(client, replyTo, sender, cmd, h.tr("Ping failed: %v", err))
+	}
+}
+
+// cmdTrace starts or stops a message trace. Trace lines go to the issuer by
+// PM, not to the channel the command came from.
+func (h *Handler) cmdTrace(client *girc.Client, replyTo, sender string, args []string) {
+	if sender == "" {
+		sender = replyTo
+	}
+	if len(args) == 1 && strings.ToLower(args[0]) == "stop" {
+		n := h.bridge.StopTrace(sender)
+		h.reply(client, replyTo, h.tr("Stopped %d trace(s)", n))
+		return
+	}
+	if len(args) != 2 {
+		h.reply(client, replyTo, h.tr("Usage: %strace <topic-pattern> <duration> | %strace stop", h.cfg.CommandPrefix, h.cfg.CommandPrefix))
+		return
+	}
+	d, err := time.ParseDuration(args[1])
+	if err != nil {
+		h.reply(client, replyTo, h.tr("Invalid duration %q (e.g. 5m)", args[1]))
+		return
+	}
+	h.logger.Info().Str("pattern", args[0]).Str("owner", sender).Dur("duration", d).Msg("admin trace")
+	until, err := h.bridge.Trace(args[0], sender, d, func(line string) {
+		// Through the bridge's rate limiter, like every other bot message.
+		if err := h.bridge.SendMessage(context.Background(), sender, line); err != nil {
+			h.logger.Warn().Err(err).Str("owner", sender).Msg("failed to send trace line")
+		}
+	})
+	if err != nil {
+		h.fail(client, replyTo, sender, "trace", h.tr("Trace failed: %v", err))
+		return
+	}
+	h.reply(client, replyTo, h.tr("Tracing %s until %s, sending steps to %s", args[0], until.Format("15:04 MST"), sender))
+}
+
+// cmdWatch sets up a temporary condition on a payload field (!watch), lists
+// the watches or stops the issuer's. Notifications go to where the command
+// came from: the channel, or the issuer by PM.
+func (h *Handler) cmdWatch(client *girc.Client, replyTo, sender string, args []string) {
+	if sender == "" {
+		sender = replyTo
+	}
+	if len(args) == 0 || len(args) == 1 && strings.EqualFold(args[0], "list") {
+		watches := h.bridge.Watches()
+		if len(watches) == 0 {
+			h.reply(client, replyTo, h.tr("No active watches"))
+			return
+		}
+		for _, w := range watches {
+			h.reply(client, replyTo, h.tr("[watch %d] %s on %s → %s, by %s until %s", w.ID, w.Cond, w.Pattern, w.Target, w.Owner, w.Until.Format("15:04 MST")))
+		}
+		return
+	}
+	if strings.EqualFold(args[0], "stop") && len(args) <= 2 {
+		id := 0
+		if len(args) == 2 {
+			var err error
+			if id, err = strconv.Atoi(strings.TrimPrefix(args[1], "#")); err != nil {
+				h.reply(client, replyTo, h.tr("Invalid watch ID %q", args[1]))
+				return
+			}
+		}
+		n := h.bridge.StopWatch(sender, id)
+		h.reply(client, replyTo, h.tr("Stopped %d watch(es)", n))
+		return
+	}
+	if len(args) != 4 && len(args) != 5 {
+		h.reply(client, replyTo, h.tr("Usage: %swatch <topic-pattern> <field> <op> <value> [duration] | %swatch list | %swatch stop [id]", h.cfg.CommandPrefix, h.cfg.CommandPrefix, h.cfg.CommandPrefix))
+		return
+	}
+	var d time.Duration
+	if len(args) == 5 {
+		var err error
+		if d, err = time.ParseDuration(args[4]); err != nil {
+			h.reply(client, replyTo, h.tr("Invalid duration %q (e.g. 5m)", args[4]))
+			return
+		}
+	}
+	h.logger.Info().Str("pattern", args[0]).Str("owner", sender).Strs("cond", args[1:4]).Msg("admin watch")
+	w, err := h.bridge.Watch(args[0], args[1], args[2], args[3], sender, replyTo, d, func(line string) {
+		if err := h.bridge.SendMessage(context.Background(), replyTo, line); err != nil {
+			h.logger.Warn().Err(err).Str("target", replyTo).Msg("failed to send watch notification")
+		}
+	})
+	if err != nil {
+		h.fail(client, replyTo, sender, "watch", h.tr("Watch failed: %v", err))
+		return
+	}
+	h.reply(client, replyTo, h.tr("Watch #%d: telling %s when %s on %s, until %s", w.ID, replyTo, w.Cond, w.Pattern, w.Until.Format("15:04 MST")))
+}
+
+func (h *Handler) cmdMute(client *girc.Client, replyTo, sender string, c command) {
+	args := c.args
+	if d, ok := c.flag("for"); ok && len(args) == 1 {
+		args = append(args, d)
+	}
+	if len(args) != 2 {
+		h.reply(client, replyTo, h.tr("Usage: %smute <topic-pattern|node> <duration> (e.g. 2h, or --for 2h)", h.cfg.CommandPrefix))
+		return
+	}
+	d, err := time.ParseDuration(args[1])
+	if err != nil {
+		h.reply(client, replyTo, h.tr("Invalid duration %q (e.g. 30m, 2h)", args[1]))
+		return
+	}
+	h.logger.Info().Str("target", args[0]).Dur("duration", d).Msg("admin mute")
+	until, err := h.bridge.Mute(args[0], d)
+	if err != nil {
+		h.fail(client, replyTo, sender, "mute", h.tr("Mute failed: %v", err))
+		return
+	}
+	h.reply(client, replyTo, h.tr("Muted %s until %s", args[0], until.Format("2006-01-02 15:04 MST")))
+}
+
+func (h *Handler) cmdUnmute(client *girc.Client, replyTo string, args []string) {
+	if len(args) != 1 {
+		h.reply(client, replyTo, h.tr("Usage: %sunmute <topic-pattern|node>", h.cfg.CommandPrefix))
+		return
+	}
+	h.logger.Info().Str("target", args[0]).Msg("admin unmute")
+	if !h.bridge.Unmute(args[0]) {
+		h.reply(client, replyTo, h.tr("%s is not muted", args[0]))
+		return
+	}
+	h.reply(client, replyTo, h.tr("Unmuted %s", args[0]))
+}
+
+func (h *Handler) cmdMutes(client *girc.Client, replyTo string) {
+	mutes := h.bridge.Mutes()
+	if len(mutes) == 0 {
+		h.reply(client, replyTo, h.tr("No active mutes"))
+		return
+	}
+	for _, m := range mutes {
+		h.reply(client, replyTo, h.tr("%s until %s (%d suppressed)", m.Target, m.Until.Format("2006-01-02 15:04 MST"), m.Suppressed))
+	}
+}
+
+func (h *Handler) cmdBackfill(client *girc.Client, replyTo, sender string, args []string) {
+	if len(args) != 2 || (!strings.HasPrefix(args[0], "#") && !strings.HasPrefix(args[0], "&")) {
+		h.reply(client, replyTo, h.tr("Usage: %sbackfill <#channel> <duration> (e.g. 30m)", h.cfg.CommandPrefix))
+		return
+	}
+	d, err := time.ParseDuration(args[1])
+	if err != nil {
+		h.reply(client, replyTo, h.tr("Invalid duration %q (e.g. 30m, 2h)", args[1]))
+		return
+	}
+	h.logger.Info().Str("channel", args[0]).Dur("period", d).Msg("admin backfill")
+	n, err := h.bridge.Backfill(args[0], d)
+	if err != nil {
+		h.fail(client, replyTo, sender, "backfill", h.tr("Backfill failed: %v", err))
+		return
+	}
+	if n == 0 {
+		h.reply(client, replyTo, h.tr("No messages sent to %s in the last %s", args[0], d))
+		return
+	}
+	h.reply(client, replyTo, h.tr("Replaying %d message(s) from the last %s to %s", n, d, args[0]))
+}
+
+// cmdPurge removes a node, nick or topic from everything the bridge stores
+// (!purge node !abcd1234), for data-removal requests.
+func (h *Handler) cmdPurge(client *girc.Client, replyTo, sender string, args []string) {
+	if len(args) != 2 {
+		h.reply(client, replyTo, h.tr("Usage: %spurge <node|nick|topic> <value>", h.cfg.CommandPrefix))
+		return
+	}
+	kind := strings.ToLower(args[0])
+	h.logger.Info().Str("kind", kind).Str("value", args[1]).Str("by", sender).Msg("admin purge")
+	res, err := h.bridge.Purge(kind, args[1])
+	if err != nil {
+		h.fail(client, replyTo, sender, "purge", h.tr("Purge failed: %v", err))
+		return
+	}
+	h.reply(client, replyTo, h.tr("Purged %s %s: %d node registry entries, %d history lines, %d payload samples",
+		kind, args[1], res.Nodes, res.History, res.Samples))
+}
+
+// maxReviewLines bounds the messages listed by !review.
+const maxReviewLines = 10
+
+// cmdReview lists the messages held in review channels (!review), or
+// approves or rejects them by ID (!approve/!reject <id>...).
+func (h *Handler) cmdReview(client *girc.Client, replyTo, sender, cmd string, args []string) {
+	if cmd == "review" {
+		reviews := h.bridge.Reviews()
+		if len(reviews) == 0 {
+			h.reply(client, replyTo, h.tr("No messages awaiting review"))
+			return
+		}
+		for i, r := range reviews {
+			if i == maxReviewLines {
+				h.reply(client, replyTo, h.tr("… and %d more", len(reviews)-i))
+				break
+			}
+			h.reply(client, replyTo, h.tr("#%d %s → %s", r.ID, r.Topic, r.Channel))
+		}
+		return
+	}
+
+	if len(args) == 0 {
+		h.reply(client, replyTo, h.tr("Usage: %s%s <id> [<id>...]", h.cfg.CommandPrefix, cmd))
+		return
+	}
+	for _, arg := range args {
+		id, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+		if err != nil {
+			h.reply(client, replyTo, h.tr("Invalid review ID %q", arg))
+			continue
+		}
+		h.logger.Info().Str("nick", sender).Int("review", id).Msg("admin " + cmd)
+		if cmd == "approve" {
+			r, err := h.bridge.Approve(id)
+			if err != nil {
+				h.fail(client, replyTo, sender, cmd, h.tr("Approve failed: %v", err))
+				continue
+			}
+			h.reply(client, replyTo, h.tr("Approved #%d: sent to %s", r.ID, r.Channel))
+			continue
+		}
+		r, err := h.bridge.Reject(id)
+		if err != nil {
+			h.fail(client, replyTo, sender, cmd, h.tr("Reject failed: %v", err))
+			continue
+		}
+		h.reply(client, replyTo, h.tr("Rejected #%d (%s → %s)", r.ID, r.Topic, r.Channel))
+	}
+}
+
+// cmdAck acknowledges an alert announced by an alert processor
+// (!ack <id> [comment]).
+func (h *Handler) cmdAck(client *girc.Client, replyTo, sender string, args []string) {
+	if len(args) == 0 {
+		h.reply(client, replyTo, h.tr("Usage: %sack <id> [comment]", h.cfg.CommandPrefix))
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		h.reply(client, replyTo, h.tr("Invalid alert ID %q", args[0]))
+		return
+	}
+	comment := strings.Join(args[1:], " ")
+	h.logger.Info().Str("nick", sender).Int("alert_id", id).Msg("admin ack")
+	ack, err := h.bridge.Ack(id, sender, comment)
+	switch {
+	case ack.ID == 0:
+		h.fail(client, replyTo, sender, "ack", h.tr("Ack failed: %v", err))
+	case err != nil:
+		h.reply(client, replyTo, h.tr("Alert #%d (%s) %v", ack.ID, ack.Alert, err))
+	default:
+		h.reply(client, replyTo, h.tr("Acknowledged alert #%d (%s); repeats are suppressed until it resolves", ack.ID, ack.Alert))
+	}
+}
+
+func (h *Handler) cmdFields(client *girc.Client, replyTo, sender string, args []string) {
+	if len(args) != 1 {
+		h.reply(client, replyTo, h.tr("Usage: %sfields <topic-pattern>", h.cfg.CommandPrefix))
+		return
+	}
+	fields, n, err := h.bridge.Fields(args[0])
+	if err != nil {
+		h.fail(client, replyTo, sender, "fields", h.tr("Fields failed: %v", err))
+		return
+	}
+	for _, line := range h.catalog.fieldLines(args[0], fields, n) {
+		h.reply(client, replyTo, line)
+	}
+}
+
+const (
+	fieldLineBytes = 350 // reply line length !fields packs fields into
+	maxFieldLines  = 6   // field lines !fields sends at most
+)
+
+// fieldLines renders the fields of n payloads on pattern for !fields, e.g.
+// "battery=87 (2/3), temperature=21.5", packed into a few lines. Fields
+// missing from some payloads show in how many they were found.
+func fieldLines(pattern string, fields []types.Field, n int) []string {
+	return catalog(nil).fieldLines(pattern, fields, n)
+}
+
+func (c catalog) fieldLines(pattern string, fields []types.Field, n int) []string {
+	lines := []string{c.tr("Fields of %s in %d payload(s), use top-level ones as {{.JSON.<name>}}:", pattern, n)}
+	var cur []string
+	curLen := 0
+	for i, f := range fields {
+		part := f.Name + "=" + f.Example
+		if f.Seen < n {
+			part += fmt.Sprintf(" (%d/%d)", f.Seen, n)
+		}
+		if len(cur) > 0 && curLen+len(part)+2 > fieldLineBytes {
+			if len(lines) == maxFieldLines {
+				return append(lines, c.tr("  … and %d more field(s)", len(fields)-i))
+			}
+			lines = append(lines, "  "+strings.Join(cur, ", "))
+			cur, curLen = nil, 0
+		}
+		cur = append(cur, part)
+		curLen += len(part) + 2
+	}
+	if len(cur) > 0 {
+		lines = append(lines, "  "+strings.Join(cur, ", "))
+	}
+	return lines
+}
+
+func (h *Handler) cmdState(client *girc.Client, replyTo, sender string, args []string) {
+	if len(args) != 1 || strings.ToLower(args[0]) != "export" {
+		h.reply(client, replyTo, h.tr("Usage: %sstate export", h.cfg.CommandPrefix))
+		return
+	}
+	h.logger.Info().Msg("admin state export")
+	path, err := h.bridge.ExportStateFile()
+	if err != nil {
+		h.fail(client, replyTo, sender, "state", h.tr("State export failed: %v", err))
+		return
+	}
+	h.reply(client, replyTo, h.tr("State written to %s", path))
+}
+
+func (h *Handler) cmdStats(client *girc.Client, replyTo string, args []string) {
+	if len(args) == 1 && strings.ToLower(args[0]) == "admin" {
+		h.cmdStatsAdmin(client, replyTo)
+		return
+	}
+	if len(args) == 1 && strings.ToLower(args[0]) == "topics" {
+		h.cmdStatsTopics(client, replyTo)
+		return
+	}
+	if len(args) != 1 || strings.ToLower(args[0]) != "drops" {
+		h.reply(client, replyTo, h.tr("Usage: %sstats <drops|admin|topics>", h.cfg.CommandPrefix))
+		return
+	}
+	drops := h.bridge.Drops()
+	if len(drops) == 0 {
+		h.reply(client, replyTo, h.tr("No messages dropped"))
+		return
+	}
+	var total uint64
+	for _, d := range drops {
+		total += d.Count
+	}
+	h.reply(client, replyTo, h.tr("Dropped %d messages:", total))
+	for _, d := range drops {
+		h.reply(client, replyTo, h.tr("  %s: %d (last %s on %s)", d.Reason, d.Count, d.Last.Format("2006-01-02 15:04 MST"), d.LastTopic))
+	}
+}
+
+// cmdStatsAdmin lists admin command use per nick, most active first.
+func (h *Handler) cmdStatsAdmin(client *girc.Client, replyTo string) {
+	counts := h.commands.Snapshot()
+	if len(counts) == 0 {
+		h.reply(client, replyTo, h.tr("No admin commands recorded"))
+		return
+	}
+	h.reply(client, replyTo, h.tr("Admin commands by nick:"))
+	for _, c := range counts {
+		h.reply(client, replyTo, h.tr("  %s: %d command(s), %d failed, %d unauthorized (last %s%s at %s)",
+			c.Nick, c.Commands, c.Failures, c.Unauthorized, h.cfg.CommandPrefix, c.LastCommand, c.Last.Format("2006-01-02 15:04 MST")))
+	}
+}
+
+// maxTopicLines bounds the topics listed by !stats topics.
+const maxTopicLines = 10
+
+// cmdStatsTopics lists the busiest topics and roll-up buckets.
+func (h *Handler) cmdStatsTopics(client *girc.Client, replyTo string) {
+	counts := h.bridge.TopicStats()
+	if counts == nil {
+		h.reply(client, replyTo, h.tr("Topic statistics are disabled (bridge.topic_stats)"))
+		return
+	}
+	shown := min(len(counts), maxTopicLines)
+	h.reply(client, replyTo, h.tr("Messages by topic (top %d of %d):", shown, len(counts)))
+	for _, c := range counts[:shown] {
+		h.reply(client, replyTo, h.tr("  %s: %d received, %d sent, %d dropped (last %s)",
+			c.Topic, c.Received, c.Sent, c.Dropped, c.Last.Format("2006-01-02 15:04 MST")))
+	}
+}
+
+func (h *Handler) cmdReload(client *girc.Client, replyTo, sender string, args []string) {
+	p := h.cfg.CommandPrefix
+	if len(args) > 0 && strings.ToLower(args[0]) != "apply" {
+		h.reply(client, replyTo, h.tr("Usage: %sreload [apply]", p))
+		return
+	}
+
+	if len(args) == 0 {
+		h.logger.Info().Msg("admin reload preview")
+		summary, pending, err := h.bridge.PrepareReload()
+		if err != nil {
+			h.fail(client, replyTo, sender, "reload", h.tr("Reload failed: %v", err))
+			return
+		}
+		for _, line := range summary {
+			h.reply(client, replyTo, h.tr("Reload: %s", line))
+		}
+		if pending {
+			h.reply(client, replyTo, h.tr("Run %sreload apply to apply these changes", p))
+		}
+		return
+	}
+
+	h.logger.Info().Msg("admin reload apply")
+	summary, err := h.bridge.ApplyReload()
+	if err != nil {
+		h.fail(client, replyTo, sender, "reload", h.tr("Reload failed: %v", err))
+		return
+	}
+	for _, line := range summary {
+		h.reply(client, replyTo, h.tr("Applied: %s", line))
 	}
 }
 
 func (h *Handler) cmdShutdown(client *girc.Client, replyTo string) {
 	h.logger.Warn().Msg("admin shutdown command received")
-	h.reply(client, replyTo, "Shutting down...")
+	h.reply(client, replyTo, h.tr("Shutting down..."))
 	// Send in background so the reply can be delivered before we shutdown.
 	ctx := context.Background()
 	go func() {
 		// Re-send via bridge.SendMessage so it goes through the rate limiter.
-		_ = h.bridge.SendMessage(ctx, replyTo, "Goodbye.")
+		_ = h.bridge.SendMessage(ctx, replyTo, h.tr("Goodbye."))
 		h.shutdownFn()
 	}()
 }
@@ -0,0 +1,143 @@
+package admin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lrstanley/girc"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// DefaultFlowTimeout is how long an interactive flow waits for an answer
+// when Config.FlowTimeout is not set.
+const DefaultFlowTimeout = 5 * time.Minute
+
+// flowStep is one question of an interactive flow.
+type flowStep struct {
+	key    string
+	prompt string
+	check  func(answer string) error // optional
+}
+
+// flow is a multi-step command collecting its missing arguments from the
+// issuer by PM, one question at a time. Only the issuer (same nick and
+// hostmask) can answer; "cancel" ends it, and it expires after the flow
+// timeout.
+type flow struct {
+	name     string
+	hostmask string
+	steps    []flowStep
+	answers  map[string]string
+	finish   func(answers map[string]string) string // runs the command, returns the reply
+	timer    schedule.Timer
+}
+
+// next returns the first unanswered step.
+func (f *flow) next() (flowStep, bool) {
+	for _, s := range f.steps {
+		if _, ok := f.answers[s.key]; !ok {
+			return s, true
+		}
+	}
+	return flowStep{}, false
+}
+
+// startFlow runs f for nick, asking for the answers it does not have yet by
+// PM. A flow with all answers given finishes right away and replies to
+// replyTo, like other commands. A flow the nick already had is replaced.
+func (h *Handler) startFlow(client *girc.Client, replyTo, nick, hostmask string, f *flow) {
+	step, ok := f.next()
+	if !ok {
+		h.reply(client, replyTo, f.finish(f.answers))
+		return
+	}
+
+	key := strings.ToLower(nick)
+	h.flowMu.Lock()
+	if old := h.flows[key]; old != nil {
+		old.timer.Stop()
+	}
+	f.hostmask = hostmask
+	f.timer = h.clock.AfterFunc(h.flowTimeout(), func() {
+		if h.endFlow(key, f) {
+			h.reply(client, nick, h.tr("%s timed out, run it again to start over", f.name))
+		}
+	})
+	h.flows[key] = f
+	h.flowMu.Unlock()
+
+	h.logger.Info().Str("nick", nick).Str("flow", f.name).Msg("admin flow started")
+	h.reply(client, nick, h.tr("%s: %s (answer here, or \"cancel\")", f.name, h.text(step.prompt)))
+}
+
+// answerFlow feeds a PM from nick to its flow. It reports false if nick has
+// no flow (or the hostmask differs), so the text is handled as usual.
+func (h *Handler) answerFlow(client *girc.Client, nick, hostmask, text string) bool {
+	key := strings.ToLower(nick)
+	h.flowMu.Lock()
+	f := h.flows[key]
+	h.flowMu.Unlock()
+	if f == nil || f.hostmask != hostmask {
+		return false
+	}
+
+	answer := strings.TrimSpace(text)
+	if strings.EqualFold(answer, "cancel") || strings.EqualFold(answer, h.cfg.CommandPrefix+"cancel") {
+		if h.endFlow(key, f) {
+			h.reply(client, nick, h.tr("%s cancelled", f.name))
+		}
+		return true
+	}
+	if strings.HasPrefix(answer, h.cfg.CommandPrefix) {
+		// Other commands still work while a flow is open.
+		return false
+	}
+
+	h.flowMu.Lock()
+	if h.flows[key] != f {
+		h.flowMu.Unlock()
+		return true // timed out meanwhile
+	}
+	step, _ := f.next()
+	if step.check != nil {
+		if err := step.check(answer); err != nil {
+			h.flowMu.Unlock()
+			h.reply(client, nick, h.tr("%v. %s", err, h.text(step.prompt)))
+			return true
+		}
+	}
+	f.answers[step.key] = answer
+	next, more := f.next()
+	if !more {
+		f.timer.Stop()
+		delete(h.flows, key)
+	}
+	h.flowMu.Unlock()
+
+	if more {
+		h.reply(client, nick, h.text(next.prompt))
+		return true
+	}
+	h.reply(client, nick, f.finish(f.answers))
+	return true
+}
+
+// endFlow removes f if it is still nick's flow; false means it already ended.
+func (h *Handler) endFlow(key string, f *flow) bool {
+	h.flowMu.Lock()
+	defer h.flowMu.Unlock()
+	if h.flows[key] != f {
+		return false
+	}
+	f.timer.Stop()
+	delete(h.flows, key)
+	return true
+}
+
+func (h *Handler) flowTimeout() time.Duration {
+	if h.cfg.FlowTimeout > 0 {
+		return h.cfg.FlowTimeout
+	}
+	return DefaultFlowTimeout
+}
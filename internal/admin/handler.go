@@ -5,9 +5,15 @@ import (
 	"context"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/lrstanley/girc"
 	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
 // BridgeAdmin is the interface the Bridge must satisfy for admin commands.
@@ -15,9 +21,37 @@ import (
 type BridgeAdmin interface {
 	HealthStatus() map[string]interface{}
 	SendMessage(ctx context.Context, channel, message string) error
+	SendTimed(ctx context.Context, channel string, compose func(types.SendStats) string) error
 	NickChange(newnick string)
 	ReconnectIRC()
 	ReconnectMQTT()
+	DrainQueue() (int, error)
+	ClearQueue(mapping string) (int, error)
+	Mappings() []types.MappingInfo
+	PauseMapping(index int, paused bool) error
+	SetMappingFormat(index int, format string) error
+	PrepareReload() (summary []string, pending bool, err error)
+	ApplyReload() (summary []string, err error)
+	Mute(target string, d time.Duration) (time.Time, error)
+	Unmute(target string) bool
+	Mutes() []types.Mute
+	Trace(pattern, owner string, d time.Duration, sink func(line string)) (time.Time, error)
+	StopTrace(owner string) int
+	Watch(pattern, field, op, value, owner, target string, d time.Duration, sink func(line string)) (types.Watch, error)
+	StopWatch(owner string, id int) int
+	Watches() []types.Watch
+	Drops() []types.DropCount
+	TopicStats() []types.TopicCount
+	Backfill(channel string, d time.Duration) (int, error)
+	AddMapping(topic string, channels []string, format string) (int, error)
+	ExportStateFile() (string, error)
+	Fields(pattern string) ([]types.Field, int, error)
+	Approve(id int) (types.Review, error)
+	Reject(id int) (types.Review, error)
+	Reviews() []types.Review
+	Ack(id int, by, comment string) (types.Ack, error)
+	Purge(kind, value string) (types.PurgeResult, error)
+	Capabilities() types.Capabilities
 }
 
 // AllowEntry defines an authorized IRC user for admin commands.
@@ -33,6 +67,63 @@ type Config struct {
 	AllowList     []AllowEntry
 	Channels      []string // IRC channels where commands are accepted
 	AcceptPM      bool     // also accept commands via private message
+	Tenants       []Tenant // tenant operators may manage their own mappings
+	ReplyMode     string   // "channel" (default), "notice" or "pm": where replies to channel commands go
+	Language      string   // reply language: "en" (default) or "de"
+
+	FlowTimeout time.Duration // how long interactive flows wait for an answer (default DefaultFlowTimeout)
+
+	// PublicCommands are answered for anyone in a mapped or admin channel,
+	// without authorization (see public.go).
+	PublicCommands []string
+
+	// Templates customize the !status and !help replies (see templates.go).
+	Templates Templates
+
+	// Instance is the bridge's instance_name: it prefixes !status and
+	// !bridge replies, and commands addressed "@<name>" to other instances
+	// are ignored (see address.go).
+	Instance string
+
+	// RequireAddress ignores channel commands not addressed to this bridge
+	// by nick ("mqttbot: status") or instance ("!status @mesh-eu"), for
+	// channels shared with other bridges.
+	RequireAddress bool
+
+	// SafeMode rejects the commands that change the bridge, its connections
+	// or stored data (--safe-mode, see changesState).
+	SafeMode bool
+
+	// Auth decides who is a bridge admin, tried in order (see auth.go). Nil
+	// means the allow list alone.
+	Auth []Authorizer
+}
+
+// Tenant is a group of channels whose operators may manage the mappings
+// owned by the tenant (but run no other admin commands).
+type Tenant struct {
+	Name      string
+	Channels  []string // commands are also accepted here, from this tenant's operators
+	Operators []AllowEntry
+}
+
+// access limits a command to the mappings of some tenants; nil means full
+// admin access.
+type access struct {
+	tenants []string
+}
+
+// allows reports whether a mapping owned by tenant may be managed.
+func (a *access) allows(tenant string) bool {
+	if a == nil {
+		return true
+	}
+	for _, t := range a.tenants {
+		if t == tenant {
+			return true
+		}
+	}
+	return false
 }
 
 // Handler processes incoming IRC PRIVMSG events and dispatches admin commands.
@@ -41,6 +132,21 @@ type Handler struct {
 	bridge     BridgeAdmin
 	shutdownFn func()
 	logger     zerolog.Logger
+	catalog    catalog // reply translations for cfg.Language
+	auth       []Authorizer
+	clock      schedule.Clock
+	commands   *stats.Commands // command use per nick (optional, see SetCommandCounter)
+
+	flowMu sync.Mutex
+	flows  map[string]*flow // lower-cased nick → open interactive flow (see flow.go)
+
+	whoamiMu   sync.Mutex
+	whoamiLast map[string]time.Time // lower-cased host → last !whoami (see whoami.go)
+
+	publicMu   sync.Mutex
+	publicLast map[string]time.Time // lower-cased channel and command → last answer (see public.go)
+
+	templates replyTemplates // admin.templates
 }
 
 // New creates a new admin Handler.
@@ -48,12 +154,30 @@ func New(cfg Config, bridge BridgeAdmin, shutdownFn func(), logger zerolog.Logge
 	if cfg.CommandPrefix == "" {
 		cfg.CommandPrefix = "!"
 	}
-	return &Handler{
+	auth := cfg.Auth
+	if auth == nil {
+		auth = []Authorizer{NewAllowListAuth(cfg.AllowList)}
+	}
+	h := &Handler{
 		cfg:        cfg,
+		auth:       auth,
 		bridge:     bridge,
 		shutdownFn: shutdownFn,
 		logger:     logger.With().Str("component", "admin").Logger(),
+		catalog:    catalogs[cfg.Language],
+		clock:      schedule.Real,
+		flows:      make(map[string]*flow),
+		whoamiLast: make(map[string]time.Time),
+		publicLast: make(map[string]time.Time),
 	}
+	h.templates = h.parseTemplates(cfg.Templates)
+	return h
+}
+
+// SetCommandCounter records command invocations, failures and unauthorized
+// attempts per nick in commands. Call it before registering the handler.
+func (h *Handler) SetCommandCounter(commands *stats.Commands) {
+	h.commands = commands
 }
 
 // GircHandler returns a girc PRIVMSG handler function suitable for registration
@@ -68,14 +192,30 @@ func (h *Handler) onPRIVMSG(client *girc.Client, event girc.Event) {
 		return
 	}
 
-	target := event.Params[0]      // channel or bot nick
-	text := event.Last()           // message text
+	target := event.Params[0] // channel or bot nick
+	text := event.Last()      // message text
 	senderNick := event.Source.Name
 	senderHost := event.Source.Ident + "@" + event.Source.Host
 
 	botNick := client.GetNick()
 	isPM := strings.EqualFold(target, botNick)
 
+	// Answers to an open interactive flow (see flow.go).
+	if isPM && h.answerFlow(client, senderNick, senderHost, text) {
+		return
+	}
+
+	// Commands addressed to another bot or instance are not for us.
+	text, ok := h.address(text, botNick, isPM)
+	if !ok {
+		return
+	}
+
+	// Public commands, answered for anyone in a mapped channel.
+	if !isPM && h.answerPublic(client, event, target, text) {
+		return
+	}
+
 	// Determine if this message comes from an accepted source.
 	if !h.acceptsSource(target, isPM) {
 		return
@@ -94,22 +234,46 @@ func (h *Handler) onPRIVMSG(client *girc.Client, event girc.Event) {
 		Str("text", text).
 		Msg("admin command attempt")
 
-	// Authorize sender.
-	if !h.isAuthorized(senderNick, senderHost) {
-		h.logger.Warn().
-			Str("nick", senderNick).
-			Str("host", senderHost).
-			Msg("unauthorized admin command attempt")
+	if h.isWhoami(text) {
+		h.cmdWhoami(client, event, target, isPM)
 		return
 	}
 
-	// Determine reply target: if PM, reply to sender; otherwise reply to channel.
-	replyTo := target
-	if isPM {
-		replyTo = senderNick
+	// Authorize sender: bridge admins get full access, tenant operators only
+	// their tenants' mappings.
+	c, _ := parseCommand(text, h.cfg.CommandPrefix)
+	req := AuthRequest{
+		Nick:     senderNick,
+		Hostmask: senderHost,
+		Account:  accountOf(client, event),
+		Command:  c.name,
+		Target:   target,
 	}
+	h.authorize(client, req, func(admin bool) {
+		var acc *access
+		if !admin {
+			tenants := h.operatorTenants(senderNick, senderHost, target, isPM)
+			if len(tenants) == 0 {
+				h.logger.Warn().
+					Str("nick", senderNick).
+					Str("host", senderHost).
+					Msg("unauthorized admin command attempt")
+				h.commands.Unauthorized(senderNick, c.name, h.clock.Now())
+				return
+			}
+			acc = &access{tenants: tenants}
+		}
+		h.dispatchFor(client, h.replyTarget(target, senderNick, isPM), senderNick, senderHost, text, acc)
+	})
+}
 
-	h.dispatch(client, replyTo, text)
+// replyTarget returns where replies go: the sender for PMs, otherwise the
+// channel, or the sender when reply_mode keeps replies out of the channel.
+func (h *Handler) replyTarget(target, senderNick string, isPM bool) string {
+	if isPM || h.cfg.ReplyMode == "notice" || h.cfg.ReplyMode == "pm" {
+		return senderNick
+	}
+	return target
 }
 
 // acceptsSource reports whether the given message target is an accepted source.
@@ -123,27 +287,76 @@ func (h *Handler) acceptsSource(target string, isPM bool) bool {
 			return true
 		}
 	}
+	for _, t := range h.cfg.Tenants {
+		for _, ch := range t.Channels {
+			if strings.EqualFold(ch, target) {
+				return true
+			}
+		}
+	}
 	return false
 }
 
-// isAuthorized reports whether the given nick+hostmask is allowed to run commands.
-func (h *Handler) isAuthorized(nick, hostmask string) bool {
-	for _, entry := range h.cfg.AllowList {
+// operatorTenants returns the tenants the sender operates that apply to this
+// message: in a channel only the tenants owning that channel, in a PM all.
+func (h *Handler) operatorTenants(nick, hostmask, target string, isPM bool) []string {
+	var tenants []string
+	for _, t := range h.cfg.Tenants {
+		if !matchesAllowList(t.Operators, nick, hostmask) {
+			continue
+		}
+		if !isPM && !containsFold(t.Channels, target) {
+			continue
+		}
+		tenants = append(tenants, t.Name)
+	}
+	return tenants
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAllowList reports whether nick+hostmask matches any entry.
+func matchesAllowList(entries []AllowEntry, nick, hostmask string) bool {
+	_, ok := matchAllowList(entries, nick, hostmask)
+	return ok
+}
+
+// matchAllowList returns the first entry matching nick+hostmask.
+func matchAllowList(entries []AllowEntry, nick, hostmask string) (AllowEntry, bool) {
+	for _, entry := range entries {
 		if !strings.EqualFold(entry.Nick, nick) {
 			continue
 		}
 		if entry.Hostmask == "" {
-			return true
+			return entry, true
 		}
 		matched, err := path.Match(entry.Hostmask, hostmask)
 		if err == nil && matched {
-			return true
+			return entry, true
 		}
 	}
-	return false
+	return AllowEntry{}, false
+}
+
+// reply sends a reply to the given target: a PRIVMSG, or a NOTICE to users in
+// "notice" reply mode.
+// fail replies with an error message and counts a failed command for sender.
+func (h *Handler) fail(client *girc.Client, target, sender, command, message string) {
+	h.commands.Failed(sender, command, h.clock.Now())
+	h.reply(client, target, message)
 }
 
-// reply sends a PRIVMSG reply to the given target.
 func (h *Handler) reply(client *girc.Client, target, message string) {
+	if h.cfg.ReplyMode == "notice" && !girc.IsValidChannel(target) {
+		client.Cmd.Notice(target, message)
+		return
+	}
 	client.Cmd.Message(target, message)
 }
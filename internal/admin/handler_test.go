@@ -2,23 +2,70 @@ package admin
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/lrstanley/girc"
 	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
 // stubBridge implements BridgeAdmin for testing.
 type stubBridge struct {
-	healthCalled      bool
-	sendCalled        bool
-	sendChannel       string
-	sendMessage       string
-	nickCalled        bool
-	nickArg           string
+	healthCalled        bool
+	capabilitiesCalled  bool
+	sendCalled          bool
+	sendChannel         string
+	sendMessage         string
+	nickCalled          bool
+	nickArg             string
 	reconnectIRCCalled  bool
 	reconnectMQTTCalled bool
+	drainCalled         bool
+	clearCalled         bool
+	clearMapping        string
+	paused              map[int]bool
+	formats             map[int]string
+	reloadPrepared      bool
+	reloadApplied       bool
+	muteTarget          string
+	muteDuration        time.Duration
+	unmuteTarget        string
+	tracePattern        string
+	traceOwner          string
+	traceStopped        string
+	dropsCalled         bool
+	topicStatsCalled    bool
+	backfillChannel     string
+	purgeKind           string
+	purgeValue          string
+	backfillPeriod      time.Duration
+	stateExported       bool
+	addedTopic          string
+	addedChannels       []string
+	addedFormat         string
+	fieldsPattern       string
+	approved            []int
+	rejected            []int
+	acked               []string
+	watch               string
+	watchStops          []int
+}
+
+func (s *stubBridge) Capabilities() types.Capabilities {
+	s.capabilitiesCalled = true
+	return types.Capabilities{Schema: types.CapabilitiesSchema, Version: "1.2.3", Features: []string{"admin"}, Mappings: 3}
 }
 
 func (s *stubBridge) HealthStatus() map[string]interface{} {
@@ -28,6 +75,9 @@ func (s *stubBridge) HealthStatus() map[string]interface{} {
 		"irc_connected":  true,
 		"queue_size":     5,
 		"queue_capacity": 1000,
+
+		"messages_per_minute":   int64(3),
+		"channel_messages_sent": map[string]uint64{"#garden": 42},
 	}
 }
 
@@ -38,6 +88,12 @@ func (s *stubBridge) SendMessage(_ context.Context, channel, message string) err
 	return nil
 }
 
+func (s *stubBridge) SendTimed(_ context.Context, channel string, compose func(types.SendStats) string) error {
+	return s.SendMessage(context.Background(), channel, compose(types.SendStats{
+		Wait: 1500 * time.Millisecond, QueueSize: 3, QueueCapacity: 1000, Pace: 12, Rate: 2, Burst: 5,
+	}))
+}
+
 func (s *stubBridge) NickChange(newnick string) {
 	s.nickCalled = true
 	s.nickArg = newnick
@@ -51,6 +107,156 @@ func (s *stubBridge) ReconnectMQTT() {
 	s.reconnectMQTTCalled = true
 }
 
+func (s *stubBridge) DrainQueue() (int, error) {
+	s.drainCalled = true
+	return 3, nil
+}
+
+func (s *stubBridge) ClearQueue(mapping string) (int, error) {
+	s.clearCalled = true
+	s.clearMapping = mapping
+	return 2, nil
+}
+
+func (s *stubBridge) Mappings() []types.MappingInfo {
+	return []types.MappingInfo{
+		{Index: 1, Topic: "sensors/#", Channels: []string{"#ops"}},
+		{Index: 2, Topic: "hs/#", Channels: []string{"#hs"}, Tenant: "hackerspace"},
+		{Index: 3, Topic: "garden/#", Channels: []string{"#garden"}, Tenant: "garden"},
+	}
+}
+
+func (s *stubBridge) PauseMapping(index int, paused bool) error {
+	if s.paused == nil {
+		s.paused = make(map[int]bool)
+	}
+	s.paused[index] = paused
+	return nil
+}
+
+func (s *stubBridge) SetMappingFormat(index int, format string) error {
+	if s.formats == nil {
+		s.formats = make(map[int]string)
+	}
+	s.formats[index] = format
+	return nil
+}
+
+func (s *stubBridge) Mute(target string, d time.Duration) (time.Time, error) {
+	s.muteTarget, s.muteDuration = target, d
+	return time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC).Add(d), nil
+}
+
+func (s *stubBridge) Unmute(target string) bool {
+	s.unmuteTarget = target
+	return target == "sensors/#"
+}
+
+func (s *stubBridge) Mutes() []types.Mute {
+	return nil
+}
+
+func (s *stubBridge) Trace(pattern, owner string, d time.Duration, sink func(string)) (time.Time, error) {
+	s.tracePattern, s.traceOwner = pattern, owner
+	return time.Now().Add(d), nil
+}
+
+func (s *stubBridge) StopTrace(owner string) int {
+	s.traceStopped = owner
+	return 1
+}
+
+func (s *stubBridge) Drops() []types.DropCount {
+	s.dropsCalled = true
+	return []types.DropCount{{Reason: "dedup", Count: 3, LastTopic: "msh/x", Last: time.Now()}}
+}
+
+func (s *stubBridge) TopicStats() []types.TopicCount {
+	s.topicStatsCalled = true
+	return []types.TopicCount{{Topic: "sensors/attic", Received: 5, Sent: 4, Dropped: 1, Last: time.Now()}}
+}
+
+func (s *stubBridge) Purge(kind, value string) (types.PurgeResult, error) {
+	s.purgeKind, s.purgeValue = kind, value
+	return types.PurgeResult{Nodes: 1}, nil
+}
+
+func (s *stubBridge) Backfill(channel string, d time.Duration) (int, error) {
+	s.backfillChannel, s.backfillPeriod = channel, d
+	return 4, nil
+}
+
+func (s *stubBridge) AddMapping(topic string, channels []string, format string) (int, error) {
+	s.addedTopic, s.addedChannels, s.addedFormat = topic, channels, format
+	return 4, nil
+}
+
+func (s *stubBridge) ExportStateFile() (string, error) {
+	s.stateExported = true
+	return "/var/lib/mqtt2irc/state.json", nil
+}
+
+func (s *stubBridge) Fields(pattern string) ([]types.Field, int, error) {
+	s.fieldsPattern = pattern
+	return []types.Field{{Name: "temperature", Type: "number", Example: "21.5", Seen: 1}}, 1, nil
+}
+
+func (s *stubBridge) Approve(id int) (types.Review, error) {
+	if id != 7 {
+		return types.Review{}, fmt.Errorf("no message #%d awaiting review", id)
+	}
+	s.approved = append(s.approved, id)
+	return types.Review{ID: id, Topic: "noisy/x", Channel: "#community"}, nil
+}
+
+func (s *stubBridge) Reject(id int) (types.Review, error) {
+	s.rejected = append(s.rejected, id)
+	return types.Review{ID: id, Topic: "noisy/x", Channel: "#community"}, nil
+}
+
+func (s *stubBridge) Reviews() []types.Review {
+	return []types.Review{{ID: 7, Topic: "noisy/x", Channel: "#community"}}
+}
+
+func (s *stubBridge) Ack(id int, by, comment string) (types.Ack, error) {
+	if id != 3 {
+		return types.Ack{}, fmt.Errorf("no open alert #%d", id)
+	}
+	s.acked = append(s.acked, fmt.Sprintf("%d %s %s", id, by, comment))
+	return types.Ack{ID: id, Alert: "disk_full", By: by, Comment: comment}, nil
+}
+
+func (s *stubBridge) Watch(pattern, field, op, value, owner, target string, d time.Duration, sink func(string)) (types.Watch, error) {
+	if op != ">" && op != "==" {
+		return types.Watch{}, fmt.Errorf("unknown operator %q", op)
+	}
+	s.watch = fmt.Sprintf("%s %s %s %s %s→%s %s", pattern, field, op, value, owner, target, d)
+	sink("[watch 1] sensors/x: temperature=31 (temperature > 30)")
+	return types.Watch{ID: 1, Owner: owner, Target: target, Pattern: pattern, Cond: field + " " + op + " " + value}, nil
+}
+
+func (s *stubBridge) StopWatch(owner string, id int) int {
+	s.watchStops = append(s.watchStops, id)
+	return 1
+}
+
+func (s *stubBridge) Watches() []types.Watch {
+	return []types.Watch{{ID: 1, Owner: "alice", Target: "#ops", Pattern: "sensors/#", Cond: "temperature > 30"}}
+}
+
+func (s *stubBridge) PrepareReload() ([]string, bool, error) {
+	s.reloadPrepared = true
+	return []string{"mappings added (1): new/#"}, true, nil
+}
+
+func (s *stubBridge) ApplyReload() ([]string, error) {
+	if !s.reloadPrepared {
+		return nil, fmt.Errorf("nothing to apply")
+	}
+	s.reloadApplied = true
+	return []string{"mappings added (1): new/#"}, nil
+}
+
 // ---- helpers ----
 
 func newTestLogger() zerolog.Logger {
@@ -61,9 +267,9 @@ func newTestHandler(cfg Config, bridge BridgeAdmin, shutdownFn func()) *Handler
 	return New(cfg, bridge, shutdownFn, newTestLogger())
 }
 
-// ---- TestIsAuthorized ----
+// ---- TestAuthorize ----
 
-func TestIsAuthorized(t *testing.T) {
+func TestAuthorize_AllowList(t *testing.T) {
 	tests := []struct {
 		name      string
 		allowList []AllowEntry
@@ -135,9 +341,71 @@ func TestIsAuthorized(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			h := newTestHandler(Config{AllowList: tt.allowList, CommandPrefix: "!"}, &stubBridge{}, func() {})
-			got := h.isAuthorized(tt.nick, tt.hostmask)
+			var got bool
+			h.authorize(makeClient(), AuthRequest{Nick: tt.nick, Hostmask: tt.hostmask}, func(admin bool) { got = admin })
 			if got != tt.want {
-				t.Errorf("isAuthorized(%q, %q) = %v, want %v", tt.nick, tt.hostmask, got, tt.want)
+				t.Errorf("authorize(%q, %q) = %v, want %v", tt.nick, tt.hostmask, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize_Providers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req AuthRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.Account {
+		case "sso-admin":
+			w.WriteHeader(http.StatusNoContent)
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		CommandPrefix: "!",
+		Auth: []Authorizer{
+			NewAllowListAuth([]AllowEntry{{Nick: "admin"}}),
+			NewAccountAuth([]string{"Alice"}),
+			NewWebhookAuth(srv.URL, "s3cret", time.Second),
+		},
+	}
+	h := newTestHandler(cfg, &stubBridge{}, func() {})
+
+	tests := []struct {
+		name    string
+		nick    string
+		account string
+		want    bool
+	}{
+		{name: "allow list", nick: "admin", account: "nobody", want: true},
+		{name: "services account", nick: "al", account: "alice", want: true},
+		{name: "webhook allows", nick: "carol", account: "sso-admin", want: true},
+		{name: "webhook denies", nick: "mallory", account: "mallory", want: false},
+		{name: "webhook error denies", nick: "eve", account: "broken", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := make(chan bool, 1)
+			req := AuthRequest{Nick: tt.nick, Hostmask: "u@example.net", Account: tt.account, Command: "status", Target: "#ops"}
+			h.authorize(makeClient(), req, func(admin bool) { result <- admin })
+			select {
+			case got := <-result:
+				if got != tt.want {
+					t.Errorf("authorize(%s/%s) = %v, want %v", tt.nick, tt.account, got, tt.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("authorize did not decide")
 			}
 		})
 	}
@@ -183,6 +451,30 @@ func TestAcceptsSource(t *testing.T) {
 	}
 }
 
+// ---- TestReplyTarget ----
+
+func TestReplyTarget(t *testing.T) {
+	tests := []struct {
+		mode   string
+		target string
+		isPM   bool
+		want   string
+	}{
+		{"", "#ops", false, "#ops"},
+		{"channel", "#ops", false, "#ops"},
+		{"channel", "testbot", true, "alice"},
+		{"notice", "#ops", false, "alice"},
+		{"pm", "#ops", false, "alice"},
+		{"pm", "testbot", true, "alice"},
+	}
+	for _, tt := range tests {
+		h := newTestHandler(Config{ReplyMode: tt.mode}, &stubBridge{}, func() {})
+		if got := h.replyTarget(tt.target, "alice", tt.isPM); got != tt.want {
+			t.Errorf("mode %q: replyTarget(%q, isPM=%v) = %q, want %q", tt.mode, tt.target, tt.isPM, got, tt.want)
+		}
+	}
+}
+
 // ---- TestDispatch_* ----
 
 // mockGircClient is a minimal stand-in; dispatch only uses client.Cmd.Message
@@ -205,6 +497,91 @@ func TestDispatch_Status(t *testing.T) {
 	}
 }
 
+func TestStatusLines_Template(t *testing.T) {
+	status := map[string]interface{}{"mqtt_connected": true, "irc_connected": false, "queue_size": 3, "queue_capacity": 100}
+
+	h := newTestHandler(Config{}, &stubBridge{}, func() {})
+	if got := h.statusLines(status); len(got) != 1 || got[0] != "Bridge status: MQTT=connected IRC=DISCONNECTED queue=3/100" {
+		t.Errorf("built-in = %q", got)
+	}
+
+	h = newTestHandler(Config{Templates: Templates{
+		Status: "[{{.Fields.instance}}] MQTT={{.MQTT}} IRC={{.IRC}} queue={{.Queue}}/{{.QueueCapacity}}{{if .Hostname}} on host{{end}}",
+		Fields: map[string]string{"instance": "eu-1"},
+	}}, &stubBridge{}, func() {})
+	if got := h.statusLines(status); len(got) != 1 || got[0] != "[eu-1] MQTT=connected IRC=DISCONNECTED queue=3/100 on host" {
+		t.Errorf("templated = %q", got)
+	}
+
+	// A template that fails at run time falls back to the built-in line.
+	h = newTestHandler(Config{Templates: Templates{Status: "{{index .Fields.x 5}}"}}, &stubBridge{}, func() {})
+	if got := h.statusLines(status); len(got) != 1 || !strings.HasPrefix(got[0], "Bridge status:") {
+		t.Errorf("failing template = %q", got)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		instance string
+		require  bool
+		text     string
+		isPM     bool
+		want     string
+		ok       bool
+	}{
+		{"", false, "!status", false, "!status", true},
+		{"mesh-eu", false, "!status", false, "!status", true},
+		{"mesh-eu", false, "!status @mesh-eu", false, "!status", true},
+		{"mesh-eu", false, "!status detail @MESH-EU ", false, "!status detail", true},
+		{"mesh-eu", false, "!status @mesh-us", false, "", false},
+		{"", false, "!status @mesh-eu", false, "", false},
+		{"mesh-eu", false, `!echo "hi @bob"`, false, `!echo "hi @bob"`, true}, // not an address
+		{"", false, "testbot: status", false, "!status", true},
+		{"", false, "TestBot, !status detail", false, "!status detail", true},
+		{"", false, "otherbot: status", false, "otherbot: status", true}, // no command
+		{"", false, "testbot:", false, "testbot:", true},
+		{"", true, "!status", false, "", false},
+		{"", true, "!status", true, "!status", true},
+		{"", true, "testbot: status", false, "!status", true},
+		{"mesh-eu", true, "!status @mesh-eu", false, "!status", true},
+		{"", true, "hello", false, "hello", true},
+	}
+	for _, tt := range tests {
+		h := newTestHandler(Config{CommandPrefix: "!", Instance: tt.instance, RequireAddress: tt.require}, &stubBridge{}, func() {})
+		if got, ok := h.address(tt.text, "testbot", tt.isPM); got != tt.want || ok != tt.ok {
+			t.Errorf("instance %q, require %v: address(%q, pm=%v) = %q, %v, want %q, %v",
+				tt.instance, tt.require, tt.text, tt.isPM, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// Addressed to another instance: not even a public command is answered.
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!", Instance: "mesh-eu", PublicCommands: []string{"bridge"}}, stub, func() {})
+	h.onPRIVMSG(makeClient(), girc.Event{Source: &girc.Source{Name: "guest"}, Params: []string{"#garden", "!bridge @mesh-us"}})
+	if stub.healthCalled {
+		t.Error("!bridge @mesh-us answered by mesh-eu")
+	}
+	h.onPRIVMSG(makeClient(), girc.Event{Source: &girc.Source{Name: "guest"}, Params: []string{"#garden", "testbot: bridge"}})
+	if !stub.healthCalled {
+		t.Error("testbot: bridge not answered")
+	}
+	if got := h.statusLines(map[string]interface{}{})[0]; !strings.HasPrefix(got, "[mesh-eu] ") {
+		t.Errorf("status line %q lacks the instance", got)
+	}
+}
+
+func TestHelpLines_Template(t *testing.T) {
+	h := newTestHandler(Config{CommandPrefix: "!", Templates: Templates{
+		Help:   "{{.Fields.instance}} ({{.Prefix}}):\n{{range .Lines}}{{.}}\n{{end}}",
+		Fields: map[string]string{"instance": "eu-1"},
+	}}, &stubBridge{}, func() {})
+	builtin := h.builtinHelp()
+	got := h.helpLines()
+	if len(got) != len(builtin)+1 || got[0] != "eu-1 (!):" || got[1] != strings.TrimRight(builtin[0], " ") {
+		t.Errorf("help = %q", got[:2])
+	}
+}
+
 func TestDispatch_Health(t *testing.T) {
 	stub := &stubBridge{}
 	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
@@ -215,6 +592,15 @@ func TestDispatch_Health(t *testing.T) {
 	}
 }
 
+func TestDispatch_Features(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	h.dispatch(makeClient(), "#ops", "!features")
+	if !stub.capabilitiesCalled {
+		t.Error("expected Capabilities() to be called")
+	}
+}
+
 func TestDispatch_Nick(t *testing.T) {
 	stub := &stubBridge{}
 	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
@@ -258,19 +644,141 @@ func TestDispatch_ReconnectIRC(t *testing.T) {
 	}
 }
 
+func TestDispatch_Reload(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", "!reload now")
+	if stub.reloadPrepared || stub.reloadApplied {
+		t.Error("invalid reload argument must not prepare or apply")
+	}
+
+	h.dispatch(client, "#ops", "!reload")
+	if !stub.reloadPrepared || stub.reloadApplied {
+		t.Errorf("!reload: prepared=%v applied=%v, want preview only", stub.reloadPrepared, stub.reloadApplied)
+	}
+
+	h.dispatch(client, "#ops", "!reload apply")
+	if !stub.reloadApplied {
+		t.Error("expected ApplyReload() to be called")
+	}
+}
+
+func TestDispatch_PingEcho(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", "!ping")
+	want := "pong — wait 1.5s, queue 3/1000, pace 12 msg/min (limit 2/s, burst 5)"
+	if stub.sendChannel != "#ops" || stub.sendMessage != want {
+		t.Errorf("!ping sent %q to %q, want %q", stub.sendMessage, stub.sendChannel, want)
+	}
+
+	h.dispatch(client, "#ops", "!echo hello  world")
+	if !strings.HasPrefix(stub.sendMessage, "hello world — wait") {
+		t.Errorf("!echo sent %q", stub.sendMessage)
+	}
+
+	// !echo declares no flags, so "--" words are text.
+	h.dispatch(client, "#ops", "!echo use --force")
+	if !strings.HasPrefix(stub.sendMessage, "use --force — wait") {
+		t.Errorf("!echo sent %q, want the flag-like word kept", stub.sendMessage)
+	}
+}
+
+func TestDispatch_Trace(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatchFor(client, "#ops", "alice", "", "!trace sensors/# 5m", nil)
+	if stub.tracePattern != "sensors/#" || stub.traceOwner != "alice" {
+		t.Errorf("Trace(%q, owner %q), want sensors/# for alice", stub.tracePattern, stub.traceOwner)
+	}
+
+	h.dispatchFor(client, "#ops", "alice", "", "!trace stop", nil)
+	if stub.traceStopped != "alice" {
+		t.Errorf("StopTrace(%q), want alice", stub.traceStopped)
+	}
+}
+
+func TestDispatch_Mute(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", "!mute sensors/# soon")
+	if stub.muteTarget != "" {
+		t.Error("invalid duration must not mute")
+	}
+
+	h.dispatch(client, "#ops", "!mute sensors/# 2h")
+	if stub.muteTarget != "sensors/#" || stub.muteDuration != 2*time.Hour {
+		t.Errorf("Mute(%q, %v), want sensors/# 2h", stub.muteTarget, stub.muteDuration)
+	}
+
+	h.dispatch(client, "#ops", "!unmute sensors/#")
+	if stub.unmuteTarget != "sensors/#" {
+		t.Errorf("Unmute(%q), want sensors/#", stub.unmuteTarget)
+	}
+	h.dispatch(client, "#ops", "!mutes")
+}
+
+func TestDispatch_StatsDrops(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", "!stats")
+	if stub.dropsCalled {
+		t.Error("!stats without a section must only print usage")
+	}
+	h.dispatch(client, "#ops", "!stats drops")
+	if !stub.dropsCalled {
+		t.Error("expected Drops() to be called")
+	}
+	h.dispatch(client, "#ops", "!stats topics")
+	if !stub.topicStatsCalled {
+		t.Error("expected TopicStats() to be called")
+	}
+}
+
+func TestDispatch_Queue(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", "!queue drain")
+	if !stub.drainCalled {
+		t.Error("expected DrainQueue() to be called")
+	}
+
+	h.dispatch(client, "#ops", "!queue clear sensors/#")
+	if !stub.clearCalled || stub.clearMapping != "sensors/#" {
+		t.Errorf("expected ClearQueue(\"sensors/#\"), got called=%v mapping=%q", stub.clearCalled, stub.clearMapping)
+	}
+
+	stub.clearCalled = false
+	h.dispatch(client, "#ops", "!queue clear")
+	if !stub.clearCalled || stub.clearMapping != "" {
+		t.Errorf("expected ClearQueue(\"\"), got called=%v mapping=%q", stub.clearCalled, stub.clearMapping)
+	}
+}
+
 func TestDispatch_Shutdown(t *testing.T) {
 	stub := &stubBridge{}
-	called := false
-	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() { called = true })
+	called := make(chan struct{})
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() { close(called) })
 	client := makeClient()
 	h.cmdShutdown(client, "#ops")
-	// shutdownFn runs in a goroutine; give it a moment
-	for i := 0; i < 100 && !called; i++ {
-		// spin wait (test only)
+	// shutdownFn runs in a goroutine after the goodbye message.
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdownFn not called")
 	}
-	// We don't block forever — just verify shutdownFn was wired correctly.
-	// The goroutine may not have run yet, but the function reference is correct.
-	_ = called
 }
 
 // ---- TestOnPRIVMSG_Unauthorized ----
@@ -297,3 +805,612 @@ func TestOnPRIVMSG_Unauthorized(t *testing.T) {
 		t.Error("bridge methods should not be called for unauthorized user")
 	}
 }
+
+// ---- TestHistoryLine ----
+
+func TestHistoryLine(t *testing.T) {
+	base := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	events := []connstate.Event{
+		{Time: base, Connected: true},
+		{Time: base.Add(time.Minute), Connected: false, Detail: "EOF"},
+	}
+
+	got := historyLine("MQTT", events, true)
+	want := "MQTT history: 01-02 12:00:00 up, 01-02 12:01:00 down (EOF) [FLAPPING]"
+	if got != want {
+		t.Errorf("historyLine() = %q, want %q", got, want)
+	}
+
+	if got := historyLine("IRC", nil, false); got != "IRC history: no events" {
+		t.Errorf("historyLine(nil) = %q", got)
+	}
+
+	// Only the most recent events are shown.
+	many := make([]connstate.Event, 10)
+	for i := range many {
+		many[i] = connstate.Event{Time: base.Add(time.Duration(i) * time.Minute), Connected: true}
+	}
+	if got := historyLine("IRC", many, false); strings.Count(got, " up") != statusDetailEvents {
+		t.Errorf("expected %d events in %q", statusDetailEvents, got)
+	}
+}
+
+// ---- tenants ----
+
+func tenantTestConfig() Config {
+	return Config{
+		CommandPrefix: "!",
+		Channels:      []string{"#ops"},
+		AllowList:     []AllowEntry{{Nick: "admin"}},
+		AcceptPM:      true,
+		Tenants: []Tenant{
+			{Name: "hackerspace", Channels: []string{"#hs"}, Operators: []AllowEntry{{Nick: "hsop", Hostmask: "*@hs.example"}}},
+			{Name: "garden", Channels: []string{"#garden"}, Operators: []AllowEntry{{Nick: "gardener"}}},
+		},
+	}
+}
+
+func TestOperatorTenants(t *testing.T) {
+	h := newTestHandler(tenantTestConfig(), &stubBridge{}, func() {})
+
+	tests := []struct {
+		name     string
+		nick     string
+		host     string
+		target   string
+		isPM     bool
+		expected []string
+	}{
+		{"operator in own channel", "hsop", "op@hs.example", "#hs", false, []string{"hackerspace"}},
+		{"operator in other tenant's channel", "hsop", "op@hs.example", "#garden", false, nil},
+		{"operator in admin channel", "hsop", "op@hs.example", "#ops", false, nil},
+		{"operator via PM", "gardener", "g@anywhere", "testbot", true, []string{"garden"}},
+		{"hostmask mismatch", "hsop", "op@evil.example", "#hs", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.operatorTenants(tt.nick, tt.host, tt.target, tt.isPM)
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("operatorTenants() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	if !h.acceptsSource("#hs", false) {
+		t.Error("tenant channel should be an accepted source")
+	}
+}
+
+func TestOnPRIVMSG_TenantOperator(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(tenantTestConfig(), stub, func() {})
+	client := makeClient()
+	send := func(target, text string) {
+		h.onPRIVMSG(client, girc.Event{
+			Source: &girc.Source{Name: "hsop", Ident: "op", Host: "hs.example"},
+			Params: []string{target, text},
+		})
+	}
+
+	send("#hs", "!mapping pause 2")
+	if !stub.paused[2] {
+		t.Error("operator should be able to pause own mapping")
+	}
+
+	send("#hs", "!mapping pause 3")
+	if _, ok := stub.paused[3]; ok {
+		t.Error("operator must not pause another tenant's mapping")
+	}
+
+	send("#hs", "!mapping pause 1")
+	if _, ok := stub.paused[1]; ok {
+		t.Error("operator must not pause a mapping without tenant")
+	}
+
+	send("#hs", "!mapping format 2 {{.Topic}}: {{.Payload}}")
+	if stub.formats[2] != "{{.Topic}}: {{.Payload}}" {
+		t.Errorf("format = %q", stub.formats[2])
+	}
+
+	send("#hs", "!reconnect irc")
+	if stub.reconnectIRCCalled {
+		t.Error("operator must not run admin-only commands")
+	}
+}
+
+func TestDispatch_MappingAdmin(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", "!mapping pause 3")
+	h.dispatch(client, "#ops", "!mapping resume 1")
+	if !stub.paused[3] || stub.paused[1] {
+		t.Errorf("paused = %v, want 3 paused and 1 resumed", stub.paused)
+	}
+}
+
+func TestMappingLine(t *testing.T) {
+	got := mappingLine(types.MappingInfo{Index: 2, Topic: "hs/#", Channels: []string{"#hs", "#hs-ops"}, Tenant: "hackerspace", Paused: true})
+	want := "#2 hs/# → #hs,#hs-ops [tenant: hackerspace] (paused)"
+	if got != want {
+		t.Errorf("mappingLine() = %q, want %q", got, want)
+	}
+}
+
+func TestDispatch_Purge(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", "!purge node")
+	if stub.purgeKind != "" {
+		t.Fatal("!purge without a value must only print usage")
+	}
+	h.dispatch(client, "#ops", "!purge NODE !abcd1234")
+	if stub.purgeKind != "node" || stub.purgeValue != "!abcd1234" {
+		t.Errorf("Purge(%q, %q), want node !abcd1234", stub.purgeKind, stub.purgeValue)
+	}
+}
+
+func TestDispatch_Backfill(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	for _, bad := range []string{"!backfill", "!backfill sensors 30m", "!backfill #ops soon"} {
+		h.dispatch(client, "#ops", bad)
+		if stub.backfillChannel != "" {
+			t.Fatalf("%q must not backfill", bad)
+		}
+	}
+
+	h.dispatch(client, "#ops", "!backfill #sensors 30m")
+	if stub.backfillChannel != "#sensors" || stub.backfillPeriod != 30*time.Minute {
+		t.Errorf("Backfill(%q, %v), want #sensors 30m", stub.backfillChannel, stub.backfillPeriod)
+	}
+}
+
+func TestDispatch_Fields(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", "!fields")
+	if stub.fieldsPattern != "" {
+		t.Fatal("!fields without a topic must only print usage")
+	}
+	h.dispatch(client, "#ops", "!fields sensors/+/env")
+	if stub.fieldsPattern != "sensors/+/env" {
+		t.Errorf("Fields(%q), want sensors/+/env", stub.fieldsPattern)
+	}
+}
+
+func TestFieldLines(t *testing.T) {
+	fields := []types.Field{
+		{Name: "battery", Example: "87", Seen: 2},
+		{Name: "temperature", Example: "21.5", Seen: 3},
+	}
+	got := fieldLines("env/#", fields, 3)
+	want := []string{
+		"Fields of env/# in 3 payload(s), use top-level ones as {{.JSON.<name>}}:",
+		"  battery=87 (2/3), temperature=21.5",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("fieldLines() = %q, want %q", got, want)
+	}
+
+	many := make([]types.Field, 200)
+	for i := range many {
+		many[i] = types.Field{Name: fmt.Sprintf("field%03d", i), Example: "some example value", Seen: 1}
+	}
+	got = fieldLines("env/#", many, 1)
+	if len(got) != maxFieldLines+1 || !strings.Contains(got[len(got)-1], "more field(s)") {
+		t.Errorf("fieldLines() with many fields = %d lines, last %q", len(got), got[len(got)-1])
+	}
+}
+
+func TestDispatch_StateExport(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", "!state")
+	if stub.stateExported {
+		t.Error("!state without export must only print usage")
+	}
+	h.dispatch(client, "#ops", "!state export")
+	if !stub.stateExported {
+		t.Error("!state export did not export")
+	}
+}
+
+func TestDispatch_Review(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+	h.dispatch(client, "#ops", "!review")
+	h.dispatch(client, "#ops", "!approve 7 #8 x")
+	h.dispatch(client, "#ops", "!reject #3")
+	h.dispatch(client, "#ops", "!reject")
+	if fmt.Sprint(stub.approved) != "[7]" || fmt.Sprint(stub.rejected) != "[3]" {
+		t.Errorf("approved %v, rejected %v; want [7] and [3]", stub.approved, stub.rejected)
+	}
+}
+
+func TestDispatch_Ack(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+	h.dispatch(client, "#ops", "!ack #3 on it, rebooting")
+	h.dispatch(client, "#ops", "!ack 4")
+	h.dispatch(client, "#ops", "!ack x")
+	h.dispatch(client, "#ops", "!ack")
+	if len(stub.acked) != 1 || !strings.HasSuffix(stub.acked[0], " on it, rebooting") || !strings.HasPrefix(stub.acked[0], "3 ") {
+		t.Errorf("acked = %q", stub.acked)
+	}
+}
+
+func TestDispatch_Watch(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+	h.dispatch(client, "#ops", "!watch sensors/# temperature > 30 2h")
+	if want := "sensors/# temperature > 30 #ops→#ops 2h0m0s"; stub.watch != want {
+		t.Errorf("watch = %q, want %q", stub.watch, want)
+	}
+	if stub.sendChannel != "#ops" || !strings.HasPrefix(stub.sendMessage, "[watch 1]") {
+		t.Errorf("notification sent to %q: %q", stub.sendChannel, stub.sendMessage)
+	}
+	h.dispatch(client, "#ops", "!watch sensors/# state == open")
+	if want := "sensors/# state == open #ops→#ops 0s"; stub.watch != want {
+		t.Errorf("watch without duration = %q, want %q", stub.watch, want)
+	}
+	h.dispatch(client, "#ops", "!watch sensors/# a > 1 soon")
+	h.dispatch(client, "#ops", "!watch list")
+	h.dispatch(client, "#ops", "!watch stop")
+	h.dispatch(client, "#ops", "!watch stop #2")
+	h.dispatch(client, "#ops", "!watch stop x")
+	if fmt.Sprint(stub.watchStops) != "[0 2]" {
+		t.Errorf("stops = %v, want [0 2]", stub.watchStops)
+	}
+}
+
+func TestWhoamiLines(t *testing.T) {
+	cfg := tenantTestConfig()
+	cfg.AllowList = []AllowEntry{{Nick: "alice", Hostmask: "*@trusted.net"}}
+	cfg.Auth = []Authorizer{NewAllowListAuth(cfg.AllowList), NewAccountAuth([]string{"carol"})}
+	h := newTestHandler(cfg, &stubBridge{}, func() {})
+
+	tests := []struct {
+		name     string
+		nick     string
+		hostmask string
+		account  string
+		target   string
+		isPM     bool
+		want     []string
+	}{
+		{
+			name: "admin", nick: "alice", hostmask: "al@trusted.net", account: "alice", target: "#ops",
+			want: []string{
+				"You are alice!al@trusted.net, services account: alice",
+				"Role: bridge admin (allow_list entry alice *@trusted.net)",
+			},
+		},
+		{
+			name: "admin nick from the wrong host", nick: "alice", hostmask: "al@evil.net", target: "#ops",
+			want: []string{
+				"You are alice!al@evil.net, services account: none (not identified, or not shared by the server)",
+				"Role: none (no allow_list or tenant operator entry matches nick alice with al@evil.net)",
+			},
+		},
+		{
+			name: "services account", nick: "caz", hostmask: "c@anywhere.net", account: "Carol", target: "#ops",
+			want: []string{
+				"You are caz!c@anywhere.net, services account: Carol",
+				"Role: bridge admin (services account Carol)",
+			},
+		},
+		{
+			name: "tenant operator in own channel", nick: "hsop", hostmask: "op@hs.example", account: "hsop", target: "#hs",
+			want: []string{
+				"You are hsop!op@hs.example, services account: hsop",
+				"Role: operator of tenant hackerspace (entry hsop *@hs.example)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.whoamiLines(tt.nick, tt.hostmask, tt.account, tt.target, tt.isPM)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("whoamiLines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestOnPRIVMSG_WhoamiUnauthorized(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!", Channels: []string{"#ops"}}, stub, func() {})
+	if !h.isWhoami("!WhoAmI") || h.isWhoami("!status") {
+		t.Fatal("isWhoami mismatch")
+	}
+	// Answered without an allow-list match and without touching the bridge.
+	h.onPRIVMSG(makeClient(), girc.Event{
+		Source: &girc.Source{Name: "stranger", Ident: "s", Host: "example.net"},
+		Params: []string{"#ops", "!whoami"},
+		Tags:   girc.Tags{"account": "stranger"},
+	})
+	if stub.healthCalled {
+		t.Error("!whoami should not call the bridge")
+	}
+}
+
+func TestWhoamiCooldown(t *testing.T) {
+	h := newTestHandler(Config{CommandPrefix: "!"}, &stubBridge{}, func() {})
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	h.clock = clock
+
+	if !h.whoamiAllowed("example.net") {
+		t.Fatal("first !whoami refused")
+	}
+	if h.whoamiAllowed("example.net") {
+		t.Error("repeated !whoami within the cooldown allowed")
+	}
+	if !h.whoamiAllowed("other.net") {
+		t.Error("!whoami from another host refused")
+	}
+	clock.Advance(whoamiCooldown)
+	if !h.whoamiAllowed("example.net") {
+		t.Error("!whoami after the cooldown refused")
+	}
+	if len(h.whoamiLast) != 1 {
+		t.Errorf("%d hosts tracked, want expired ones dropped", len(h.whoamiLast))
+	}
+}
+
+func TestOnPRIVMSG_Public(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	send := func(cfg Config, target, text string) bool {
+		t.Helper()
+		stub := &stubBridge{}
+		h := newTestHandler(cfg, stub, func() {})
+		h.clock = clock
+		h.onPRIVMSG(makeClient(), girc.Event{
+			Source: &girc.Source{Name: "guest", Ident: "g", Host: "example.net"},
+			Params: []string{target, text},
+		})
+		return stub.healthCalled
+	}
+	cfg := Config{CommandPrefix: "!", Channels: []string{"#ops"}, PublicCommands: []string{"bridge"}}
+
+	if !send(cfg, "#garden", "!bridge") {
+		t.Error("!bridge in a mapped channel not answered")
+	}
+	if !send(cfg, "#ops", "!BRIDGE") {
+		t.Error("!bridge in an admin channel not answered")
+	}
+	if send(cfg, "#random", "!bridge") {
+		t.Error("!bridge answered in a channel that is neither mapped nor an admin channel")
+	}
+	if send(cfg, "#garden", "!status") {
+		t.Error("!status answered for a guest")
+	}
+	if send(Config{CommandPrefix: "!", Channels: []string{"#ops"}}, "#garden", "!bridge") {
+		t.Error("!bridge answered without public_commands")
+	}
+}
+
+func TestPublicCooldown(t *testing.T) {
+	h := newTestHandler(Config{CommandPrefix: "!"}, &stubBridge{}, func() {})
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	h.clock = clock
+
+	if !h.publicAllowed("#garden bridge") {
+		t.Fatal("first !bridge refused")
+	}
+	if h.publicAllowed("#garden bridge") {
+		t.Error("repeated !bridge within the cooldown allowed")
+	}
+	if !h.publicAllowed("#hs bridge") {
+		t.Error("!bridge in another channel refused")
+	}
+	clock.Advance(publicCooldown)
+	if !h.publicAllowed("#garden bridge") {
+		t.Error("!bridge after the cooldown refused")
+	}
+}
+
+func TestCommandCounter(t *testing.T) {
+	cfg := Config{CommandPrefix: "!", Channels: []string{"#ops"}, AllowList: []AllowEntry{{Nick: "alice"}}}
+	h := newTestHandler(cfg, &stubBridge{}, func() {})
+	h.clock = schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	counter := stats.NewCommands()
+	h.SetCommandCounter(counter)
+	client := makeClient()
+	send := func(nick, text string) {
+		h.onPRIVMSG(client, girc.Event{
+			Source: &girc.Source{Name: nick, Ident: "u", Host: "example.net"},
+			Params: []string{"#ops", text},
+		})
+	}
+
+	send("alice", "!status")
+	send("alice", "!bogus")
+	send("mallory", "!shutdown")
+	send("mallory", "!shutdown now")
+
+	counts := counter.Snapshot()
+	if len(counts) != 2 {
+		t.Fatalf("counts = %+v, want alice and mallory", counts)
+	}
+	for _, c := range counts {
+		switch c.Nick {
+		case "alice":
+			if c.Commands != 2 || c.Failures != 1 || c.Unauthorized != 0 || c.LastCommand != "bogus" {
+				t.Errorf("alice = %+v, want 2 commands, 1 failed", c)
+			}
+		case "mallory":
+			if c.Commands != 0 || c.Unauthorized != 2 || c.LastCommand != "shutdown" {
+				t.Errorf("mallory = %+v, want 2 unauthorized attempts", c)
+			}
+		}
+	}
+}
+
+func TestDispatch_MuteFlag(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!"}, stub, func() {})
+	client := makeClient()
+
+	h.dispatch(client, "#ops", `!mute "msh/EU_868/#" --for 2h`)
+	if stub.muteTarget != "msh/EU_868/#" || stub.muteDuration != 2*time.Hour {
+		t.Errorf("!mute --for: target %q duration %s", stub.muteTarget, stub.muteDuration)
+	}
+}
+
+func TestMappingAddFlow(t *testing.T) {
+	stub := &stubBridge{}
+	cfg := Config{CommandPrefix: "!", Channels: []string{"#ops"}, AllowList: []AllowEntry{{Nick: "alice"}}}
+	h := newTestHandler(cfg, stub, func() {})
+	client := makeClient()
+	say := func(target, host, text string) {
+		h.onPRIVMSG(client, girc.Event{
+			Source: &girc.Source{Name: "alice", Ident: "al", Host: host},
+			Params: []string{target, text},
+		})
+	}
+
+	say("#ops", "home.net", "!mapping add sensors/+/temp")
+	if stub.addedTopic != "" {
+		t.Fatal("mapping added before all answers were given")
+	}
+	say("testbot", "home.net", "not a channel")
+	say("testbot", "evil.net", "#evil") // same nick, other host: not an answer
+	say("testbot", "home.net", "#sensors,#ops")
+	if stub.addedTopic != "" {
+		t.Fatal("mapping added before the format was given")
+	}
+	say("testbot", "home.net", `{{.Topic}}: {{.Payload}}°C`)
+
+	if stub.addedTopic != "sensors/+/temp" || strings.Join(stub.addedChannels, ",") != "#sensors,#ops" || stub.addedFormat != "{{.Topic}}: {{.Payload}}°C" {
+		t.Errorf("AddMapping(%q, %q, %q)", stub.addedTopic, stub.addedChannels, stub.addedFormat)
+	}
+	if len(h.flows) != 0 {
+		t.Error("flow still open after the last answer")
+	}
+}
+
+func TestMappingAddFlow_OneLineAndCancel(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!", AcceptPM: true, AllowList: []AllowEntry{{Nick: "alice"}}}, stub, func() {})
+	client := makeClient()
+	pm := func(text string) {
+		h.onPRIVMSG(client, girc.Event{
+			Source: &girc.Source{Name: "alice", Ident: "al", Host: "home.net"},
+			Params: []string{"testbot", text},
+		})
+	}
+
+	pm(`!mapping add alerts/# #alerts "ALERT: {{.Payload}}"`)
+	if stub.addedTopic != "alerts/#" || stub.addedFormat != "ALERT: {{.Payload}}" {
+		t.Errorf("one-line add: AddMapping(%q, %q, %q)", stub.addedTopic, stub.addedChannels, stub.addedFormat)
+	}
+
+	stub.addedTopic = ""
+	pm("!mapping add")
+	pm("cancel")
+	pm("x/#")
+	if stub.addedTopic != "" || len(h.flows) != 0 {
+		t.Error("cancelled flow should not continue")
+	}
+}
+
+func TestMappingAddFlow_Timeout(t *testing.T) {
+	stub := &stubBridge{}
+	cfg := Config{CommandPrefix: "!", AcceptPM: true, AllowList: []AllowEntry{{Nick: "alice"}}, FlowTimeout: time.Minute}
+	h := newTestHandler(cfg, stub, func() {})
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	h.clock = clock
+	client := makeClient()
+	h.onPRIVMSG(client, girc.Event{
+		Source: &girc.Source{Name: "alice", Ident: "al", Host: "home.net"},
+		Params: []string{"testbot", "!mapping add"},
+	})
+
+	openFlows := func() int {
+		h.flowMu.Lock()
+		defer h.flowMu.Unlock()
+		return len(h.flows)
+	}
+	clock.Advance(59 * time.Second)
+	if openFlows() != 1 {
+		t.Fatal("flow ended before its timeout")
+	}
+	clock.Advance(time.Second)
+	if openFlows() != 0 {
+		t.Fatal("flow did not time out")
+	}
+}
+
+func TestCatalog_German(t *testing.T) {
+	h := newTestHandler(Config{Language: "de"}, &stubBridge{}, func() {})
+	if got := h.tr("No mapping #%d", 3); got != "Kein Mapping #3" {
+		t.Errorf("tr() = %q", got)
+	}
+	if got := h.tr("untranslated %s", "x"); got != "untranslated x" {
+		t.Errorf("tr() fallback = %q", got)
+	}
+	got := h.catalog.mappingLine(types.MappingInfo{Index: 1, Topic: "hs/#", Channels: []string{"#hs"}, Paused: true})
+	if got != "#1 hs/# → #hs (angehalten)" {
+		t.Errorf("mappingLine() = %q", got)
+	}
+
+	en := newTestHandler(Config{Language: "xx"}, &stubBridge{}, func() {})
+	if got := en.tr("No mapping #%d", 3); got != "No mapping #3" {
+		t.Errorf("unknown language: tr() = %q", got)
+	}
+}
+
+// TestCatalogs_Verbs checks that every translation takes the same arguments
+// as its English format.
+func TestCatalogs_Verbs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	for lang, c := range catalogs {
+		for en, tr := range c {
+			if a, b := verbs.FindAllString(en, -1), verbs.FindAllString(tr, -1); strings.Join(a, " ") != strings.Join(b, " ") {
+				t.Errorf("%s: %q has verbs %v, translation %q has %v", lang, en, a, tr, b)
+			}
+		}
+	}
+}
+
+func TestDispatch_SafeMode(t *testing.T) {
+	stub := &stubBridge{}
+	h := newTestHandler(Config{CommandPrefix: "!", SafeMode: true}, stub, func() {})
+	client := makeClient()
+
+	for _, cmd := range []string{"!purge node !abcd1234", "!queue drain", "!mapping pause 0"} {
+		h.dispatch(client, "#ops", cmd)
+	}
+	if stub.purgeKind != "" || stub.drainCalled || stub.paused[0] {
+		t.Error("state-changing command ran in safe mode")
+	}
+
+	h.dispatch(client, "#ops", "!backfill #sensors 30m")
+	if stub.backfillChannel != "#sensors" {
+		t.Error("!backfill rejected in safe mode")
+	}
+
+	for cmd, want := range map[string]bool{
+		"mapping list": false, "mapping": false, "mapping resume 1": true,
+		"reload": false, "reload apply": true, "queue": false, "queue clear": true,
+		"status": false, "stats drops": false, "mute": true, "state export": true, "shutdown": false,
+	} {
+		fields := strings.Fields(cmd)
+		if got := changesState(fields[0], fields[1:]); got != want {
+			t.Errorf("changesState(%q) = %v, want %v", cmd, got, want)
+		}
+	}
+}
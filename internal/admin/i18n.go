@@ -0,0 +1,228 @@
+package admin
+
+import "fmt"
+
+// catalog maps an English reply format to its translation. A nil catalog is
+// English; formats missing from a catalog fall back to English.
+type catalog map[string]string
+
+// catalogs holds the reply languages selectable with Config.Language; an
+// unknown language gets English.
+var catalogs = map[string]catalog{
+	"en": nil,
+	"de": catalogDE,
+}
+
+// tr translates format and, when args are given, formats it like fmt.Sprintf.
+func (c catalog) tr(format string, args ...interface{}) string {
+	if t, ok := c[format]; ok {
+		format = t
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// tr translates a reply into the configured language.
+func (h *Handler) tr(format string, args ...interface{}) string {
+	return h.catalog.tr(format, args...)
+}
+
+// text translates a fixed string that is not a format, e.g. a flow prompt.
+func (h *Handler) text(s string) string {
+	return h.catalog.tr(s)
+}
+
+var catalogDE = catalog{
+	// commands.go
+	"Cannot parse command: %v":                       "Befehl nicht lesbar: %v",
+	"Not permitted: %s%s requires a bridge admin":    "Nicht erlaubt: %s%s erfordert einen Bridge-Admin",
+	"Not permitted in safe mode: %s%s changes state": "Im abgesicherten Modus nicht erlaubt: %s%s ändert den Zustand",
+	"Usage: %secho <text>":                           "Verwendung: %secho <Text>",
+	"Unknown command: %s%s — try %shelp":             "Unbekannter Befehl: %s%s — siehe %shelp",
+
+	"Admin commands (prefix: %s; quote arguments with spaces):":                                                   "Admin-Befehle (Präfix: %s; Argumente mit Leerzeichen in Anführungszeichen):",
+	"  %shelp                — show this help":                                                                    "  %shelp                — diese Hilfe anzeigen",
+	"  %sstatus / %shealth    — show bridge connection status":                                                    "  %sstatus / %shealth    — Verbindungsstatus der Bridge anzeigen",
+	"  %sstatus detail       — also show recent connect/disconnect events":                                        "  %sstatus detail       — zusätzlich die letzten Verbindungsereignisse anzeigen",
+	"  %sfeatures            — show the version, enabled features, processors and transforms":                     "  %sfeatures            — Version, aktivierte Funktionen, Prozessoren und Transformationen anzeigen",
+	"  %swhoami              — show how the bridge sees you: hostmask, account, allow-list match and role":        "  %swhoami              — zeigt, wie die Bridge dich sieht: Hostmask, Account, Allow-List-Treffer und Rolle",
+	"  %sping / %secho <text> — reply through the rate limiter with wait time, queue and pace":                    "  %sping / %secho <Text> — Antwort über den Rate-Limiter mit Wartezeit, Queue und Tempo",
+	"  %snick <newnick>      — change bot IRC nickname":                                                           "  %snick <neuer Nick>   — IRC-Nick des Bots ändern",
+	"  %sreconnect mqtt      — reconnect to MQTT broker":                                                          "  %sreconnect mqtt      — neu mit dem MQTT-Broker verbinden",
+	"  %sreconnect irc       — reconnect to IRC server":                                                           "  %sreconnect irc       — neu mit dem IRC-Server verbinden",
+	"  %smapping list        — list mappings and their state":                                                     "  %smapping list        — Mappings und ihren Zustand auflisten",
+	"  %smapping pause|resume <n> — stop/start forwarding mapping #n":                                             "  %smapping pause|resume <n> — Weiterleitung von Mapping #n anhalten/fortsetzen",
+	"  %smapping format <n> <template> — change the message format of mapping #n":                                 "  %smapping format <n> <Vorlage> — Nachrichtenformat von Mapping #n ändern",
+	"  %smapping add [topic] [#chan,...] [template] — add a mapping; asks by PM for what is missing":              "  %smapping add [Topic] [#Kanal,...] [Vorlage] — Mapping hinzufügen; fragt Fehlendes per PM ab",
+	"  %squeue drain         — deliver all queued messages now, oldest first":                                     "  %squeue drain         — alle wartenden Nachrichten jetzt zustellen, älteste zuerst",
+	"  %squeue clear [topic] — discard queued messages (of one mapping)":                                          "  %squeue clear [Topic] — wartende Nachrichten (eines Mappings) verwerfen",
+	"  %strace <topic> <duration> — PM you a step-by-step trace of messages on a topic":                           "  %strace <Topic> <Dauer> — schickt dir per PM einen schrittweisen Trace der Nachrichten eines Topics",
+	"  %strace stop          — end your traces":                                                                   "  %strace stop          — deine Traces beenden",
+	"  %swatch <topic> <field> <op> <value> [duration] — tell you here when a payload field meets a condition":    "  %swatch <Topic> <Feld> <Op> <Wert> [Dauer] — hier melden, wenn ein Payload-Feld eine Bedingung erfüllt",
+	"  %swatch list | stop [id] — list watches, or end yours":                                                     "  %swatch list | stop [ID] — Beobachtungen auflisten oder eigene beenden",
+	"  %smute <topic|node> <duration> — suppress a topic pattern or mesh node for a while (or --for <duration>)":  "  %smute <Topic|Node> <Dauer> — ein Topic-Muster oder einen Mesh-Node eine Weile stummschalten (oder --for <Dauer>)",
+	"  %sunmute <topic|node> — remove a mute":                                                                     "  %sunmute <Topic|Node> — Stummschaltung aufheben",
+	"  %smutes               — list active mutes":                                                                 "  %smutes               — aktive Stummschaltungen auflisten",
+	"  %sstats drops         — count dropped messages by reason":                                                  "  %sstats drops         — verworfene Nachrichten nach Grund zählen",
+	"  %sstats admin         — count admin commands, failures and unauthorized attempts by nick":                  "  %sstats admin         — Admin-Befehle, Fehlschläge und unberechtigte Versuche nach Nick zählen",
+	"  %sstats topics        — count messages per topic, busiest first (bridge.topic_stats)":                      "  %sstats topics        — Nachrichten pro Topic zählen, die aktivsten zuerst (bridge.topic_stats)",
+	"  %sbackfill <#channel> <duration> — resend the channel's recent messages, labeled as replay":                "  %sbackfill <#Kanal> <Dauer> — die letzten Nachrichten des Kanals erneut senden, als Wiederholung markiert",
+	"  %sreview              — list messages held in review channels":                                             "  %sreview              — Nachrichten auflisten, die in Prüfkanälen warten",
+	"  %sapprove|reject <id>... — forward a held message to its channel, or drop it":                              "  %sapprove|reject <ID>... — wartende Nachricht an ihren Kanal weiterleiten oder verwerfen",
+	"  %sack <id> [comment]  — acknowledge an alert: publish the ack and suppress its repeats":                    "  %sack <ID> [Kommentar] — Alarm bestätigen: Bestätigung veröffentlichen und Wiederholungen unterdrücken",
+	"  %sfields <topic>      — list the JSON fields of recent payloads, with example values":                      "  %sfields <Topic>      — JSON-Felder der letzten Payloads mit Beispielwerten auflisten",
+	"  %sstate export        — write mutes, mapping changes and processor state to the state bundle":              "  %sstate export        — Stummschaltungen, Mapping-Änderungen und Prozessorzustand ins State-Bundle schreiben",
+	"  %spurge node|nick|topic <value> — remove a node, nick or topic from the node registry, history and caches": "  %spurge node|nick|topic <Wert> — einen Node, Nick oder ein Topic aus Node-Register, Verlauf und Caches löschen",
+	"  %sreload              — show what reloading the config file would change":                                  "  %sreload              — zeigen, was ein Neuladen der Konfiguration ändern würde",
+	"  %sreload apply        — apply the previewed mapping/subscription changes":                                  "  %sreload apply        — die angezeigten Mapping-/Abo-Änderungen übernehmen",
+	"  %sshutdown            — gracefully shut down the bridge":                                                   "  %sshutdown            — die Bridge geordnet beenden",
+
+	"connected":    "verbunden",
+	"DISCONNECTED": "GETRENNT",
+	"Bridge status: MQTT=%s IRC=%s queue=%d/%d":          "Bridge-Status: MQTT=%s IRC=%s Queue=%d/%d",
+	"mqtt2irc %s, %d mapping(s), capabilities schema %d": "mqtt2irc %s, %d Mapping(s), Capabilities-Schema %d",
+	"Features: %s":          "Funktionen: %s",
+	"Processors: %s":        "Prozessoren: %s",
+	"Transforms: %s":        "Transformationen: %s",
+	"%s history: no events": "%s-Verlauf: keine Ereignisse",
+	"%s history: %s":        "%s-Verlauf: %s",
+	"down":                  "getrennt",
+	"up":                    "verbunden",
+	" [FLAPPING]":           " [INSTABIL]",
+
+	"Usage: !nick <newnick>":                    "Verwendung: !nick <neuer Nick>",
+	"Nick too long (max 30 characters)":         "Nick zu lang (höchstens 30 Zeichen)",
+	"Invalid nick: must not contain whitespace": "Ungültiger Nick: darf keine Leerzeichen enthalten",
+	"Changing nick to: %s":                      "Ändere Nick zu: %s",
+	"Usage: !reconnect <mqtt|irc>":              "Verwendung: !reconnect <mqtt|irc>",
+	"Reconnecting to MQTT broker...":            "Verbinde neu mit dem MQTT-Broker...",
+	"Reconnecting to IRC server...":             "Verbinde neu mit dem IRC-Server...",
+	"Unknown target: %s (use 'mqtt' or 'irc')":  "Unbekanntes Ziel: %s ('mqtt' oder 'irc' verwenden)",
+
+	"No mappings": "Keine Mappings",
+	"Usage: !mapping <list|add|pause <n>|resume <n>|format <n> <template>>": "Verwendung: !mapping <list|add|pause <n>|resume <n>|format <n> <Vorlage>>",
+	"Invalid mapping number: %s":         "Ungültige Mapping-Nummer: %s",
+	"No mapping #%d":                     "Kein Mapping #%d",
+	"Mapping %s failed: %v":              "Mapping %s fehlgeschlagen: %v",
+	"resumed":                            "fortgesetzt",
+	"paused":                             "angehalten",
+	"Mapping #%d (%s) %s":                "Mapping #%d (%s) %s",
+	"Mapping format failed: %v":          "Mapping-Format fehlgeschlagen: %v",
+	"Mapping #%d (%s) format set to: %s": "Mapping #%d (%s) Format gesetzt auf: %s",
+	"Unknown mapping action: %s (use list, add, pause, resume or format)": "Unbekannte Mapping-Aktion: %s (list, add, pause, resume oder format verwenden)",
+	" [tenant: %s]": " [Mandant: %s]",
+	" (paused)":     " (angehalten)",
+
+	"Not permitted: %smapping add requires a bridge admin":                            "Nicht erlaubt: %smapping add erfordert einen Bridge-Admin",
+	"Which MQTT topic pattern? (e.g. sensors/+/temperature)":                          "Welches MQTT-Topic-Muster? (z. B. sensors/+/temperature)",
+	"Which channel(s)? (comma-separated, e.g. #sensors,#ops)":                         "Welche(r) Kanal/Kanäle? (kommagetrennt, z. B. #sensors,#ops)",
+	"Which message format? (template, e.g. {{.Topic}}: {{.Payload}}, or \"default\")": "Welches Nachrichtenformat? (Vorlage, z. B. {{.Topic}}: {{.Payload}}, oder \"default\")",
+	"Mapping add failed: %v":                                                          "Mapping hinzufügen fehlgeschlagen: %v",
+	"Added mapping #%d %s → %s (until the next reload or restart)":                    "Mapping #%d %s → %s hinzugefügt (bis zum nächsten Neuladen oder Neustart)",
+	"%s: continuing by PM":                                                            "%s: weiter per PM",
+
+	"Usage: !queue <drain|clear [mapping]>":             "Verwendung: !queue <drain|clear [Mapping]>",
+	"Queue drain failed: %v":                            "Queue leeren fehlgeschlagen: %v",
+	"Draining %d queued messages":                       "Stelle %d wartende Nachrichten zu",
+	"Queue clear failed: %v":                            "Queue verwerfen fehlgeschlagen: %v",
+	"Discarded %d queued messages for %s":               "%d wartende Nachrichten für %s verworfen",
+	"Discarded %d queued messages":                      "%d wartende Nachrichten verworfen",
+	"Unknown queue action: %s (use 'drain' or 'clear')": "Unbekannte Queue-Aktion: %s ('drain' oder 'clear' verwenden)",
+
+	"%s — wait %s, queue %d/%d, pace %d msg/min (limit %g/s, burst %d)": "%s — Wartezeit %s, Queue %d/%d, Tempo %d Nachr./min (Limit %g/s, Burst %d)",
+	"Ping failed: %v": "Ping fehlgeschlagen: %v",
+
+	"Stopped %d trace(s)": "%d Trace(s) beendet",
+	"Usage: %strace <topic-pattern> <duration> | %strace stop": "Verwendung: %strace <Topic-Muster> <Dauer> | %strace stop",
+	"Invalid duration %q (e.g. 5m)":                            "Ungültige Dauer %q (z. B. 5m)",
+	"Trace failed: %v":                                         "Trace fehlgeschlagen: %v",
+	"Tracing %s until %s, sending steps to %s":                 "Trace für %s bis %s, Schritte gehen an %s",
+
+	"Usage: %smute <topic-pattern|node> <duration> (e.g. 2h, or --for 2h)": "Verwendung: %smute <Topic-Muster|Node> <Dauer> (z. B. 2h, oder --for 2h)",
+	"Invalid duration %q (e.g. 30m, 2h)":                                   "Ungültige Dauer %q (z. B. 30m, 2h)",
+	"Mute failed: %v":                                                      "Stummschalten fehlgeschlagen: %v",
+	"Muted %s until %s":                                                    "%s stummgeschaltet bis %s",
+
+	"Usage: %sunmute <topic-pattern|node>": "Verwendung: %sunmute <Topic-Muster|Node>",
+	"%s is not muted":                      "%s ist nicht stummgeschaltet",
+	"Unmuted %s":                           "Stummschaltung für %s aufgehoben",
+	"No active mutes":                      "Keine aktiven Stummschaltungen",
+	"%s until %s (%d suppressed)":          "%s bis %s (%d unterdrückt)",
+
+	"Usage: %sbackfill <#channel> <duration> (e.g. 30m)": "Verwendung: %sbackfill <#Kanal> <Dauer> (z. B. 30m)",
+	"Backfill failed: %v":                                "Backfill fehlgeschlagen: %v",
+
+	"Usage: %spurge <node|nick|topic> <value>": "Verwendung: %spurge <node|nick|topic> <Wert>",
+	"Purge failed: %v":                         "Löschen fehlgeschlagen: %v",
+	"Purged %s %s: %d node registry entries, %d history lines, %d payload samples": "%s %s gelöscht: %d Einträge im Node-Register, %d Verlaufszeilen, %d Payload-Beispiele",
+	"No messages sent to %s in the last %s":                                        "Keine Nachrichten an %s in den letzten %s",
+	"No messages awaiting review":                                                  "Keine Nachrichten warten auf Prüfung",
+	"… and %d more":                                                                "… und %d weitere",
+	"#%d %s → %s":                                                                  "#%d %s → %s",
+	"Usage: %s%s <id> [<id>...]":                                                   "Verwendung: %s%s <ID> [<ID>...]",
+	"Invalid review ID %q":                                                         "Ungültige Prüf-ID %q",
+	"No active watches":                                                            "Keine aktiven Beobachtungen",
+	"[watch %d] %s on %s → %s, by %s until %s":                                     "[watch %d] %s auf %s → %s, von %s bis %s",
+	"Invalid watch ID %q":                                                          "Ungültige Beobachtungs-ID %q",
+	"Stopped %d watch(es)":                                                         "%d Beobachtung(en) beendet",
+	"Usage: %swatch <topic-pattern> <field> <op> <value> [duration] | %swatch list | %swatch stop [id]": "Verwendung: %swatch <Topic-Muster> <Feld> <Op> <Wert> [Dauer] | %swatch list | %swatch stop [ID]",
+	"Watch failed: %v": "Beobachtung fehlgeschlagen: %v",
+	"Watch #%d: telling %s when %s on %s, until %s": "Beobachtung #%d: melde an %s, wenn %s auf %s, bis %s",
+	"Ack failed: %v": "Bestätigen fehlgeschlagen: %v",
+	"Acknowledged alert #%d (%s); repeats are suppressed until it resolves": "Alarm #%d (%s) bestätigt; Wiederholungen werden bis zur Entwarnung unterdrückt",
+	"Alert #%d (%s) %v":                              "Alarm #%d (%s) %v",
+	"Invalid alert ID %q":                            "Ungültige Alarm-ID %q",
+	"Usage: %sack <id> [comment]":                    "Verwendung: %sack <ID> [Kommentar]",
+	"Approve failed: %v":                             "Freigabe fehlgeschlagen: %v",
+	"Approved #%d: sent to %s":                       "#%d freigegeben: an %s gesendet",
+	"Reject failed: %v":                              "Ablehnen fehlgeschlagen: %v",
+	"Rejected #%d (%s → %s)":                         "#%d abgelehnt (%s → %s)",
+	"Replaying %d message(s) from the last %s to %s": "Wiederhole %d Nachricht(en) der letzten %s nach %s",
+
+	"Usage: %sfields <topic-pattern>": "Verwendung: %sfields <Topic-Muster>",
+	"Fields failed: %v":               "Felder fehlgeschlagen: %v",
+	"Fields of %s in %d payload(s), use top-level ones as {{.JSON.<name>}}:": "Felder von %s in %d Payload(s), oberste Ebene als {{.JSON.<Name>}} verwenden:",
+	"  … and %d more field(s)": "  … und %d weitere(s) Feld(er)",
+
+	"Usage: %sstate export":               "Verwendung: %sstate export",
+	"State export failed: %v":             "State-Export fehlgeschlagen: %v",
+	"State written to %s":                 "State geschrieben nach %s",
+	"Usage: %sstats <drops|admin|topics>": "Verwendung: %sstats <drops|admin|topics>",
+	"No messages dropped":                 "Keine Nachrichten verworfen",
+	"Dropped %d messages:":                "%d Nachrichten verworfen:",
+	"  %s: %d (last %s on %s)":            "  %s: %d (zuletzt %s auf %s)",
+	"No admin commands recorded":          "Keine Admin-Befehle erfasst",
+	"Admin commands by nick:":             "Admin-Befehle nach Nick:",
+	"  %s: %d command(s), %d failed, %d unauthorized (last %s%s at %s)": "  %s: %d Befehl(e), %d fehlgeschlagen, %d unberechtigt (zuletzt %s%s um %s)",
+	"Topic statistics are disabled (bridge.topic_stats)":                "Topic-Statistik ist deaktiviert (bridge.topic_stats)",
+	"Messages by topic (top %d of %d):":                                 "Nachrichten nach Topic (Top %d von %d):",
+	"  %s: %d received, %d sent, %d dropped (last %s)":                  "  %s: %d empfangen, %d gesendet, %d verworfen (zuletzt %s)",
+
+	"Usage: %sreload [apply]":                   "Verwendung: %sreload [apply]",
+	"Reload failed: %v":                         "Neuladen fehlgeschlagen: %v",
+	"Reload: %s":                                "Neuladen: %s",
+	"Run %sreload apply to apply these changes": "%sreload apply übernimmt diese Änderungen",
+	"Applied: %s":                               "Übernommen: %s",
+	"Shutting down...":                          "Fahre herunter...",
+	"Goodbye.":                                  "Auf Wiedersehen.",
+
+	// flow.go
+	"%s timed out, run it again to start over": "%s abgelaufen, zum Neubeginn erneut ausführen",
+	"%s: %s (answer here, or \"cancel\")":      "%s: %s (hier antworten, oder \"cancel\")",
+	"%s cancelled":                             "%s abgebrochen",
+
+	// whoami.go
+	"none (not identified, or not shared by the server)":                          "keiner (nicht identifiziert, oder vom Server nicht mitgeteilt)",
+	"You are %s!%s, services account: %s":                                         "Du bist %s!%s, Services-Account: %s",
+	"Role: bridge admin (allow_list entry %s)":                                    "Rolle: Bridge-Admin (allow_list-Eintrag %s)",
+	"Role: bridge admin (services account %s)":                                    "Rolle: Bridge-Admin (Services-Account %s)",
+	"Role here: none; operator of tenant %s (entry %s) in %s and by PM":           "Rolle hier: keine; Operator des Mandanten %s (Eintrag %s) in %s und per PM",
+	"Role: operator of tenant %s (entry %s)":                                      "Rolle: Operator des Mandanten %s (Eintrag %s)",
+	"Role: none (no allow_list or tenant operator entry matches nick %s with %s)": "Rolle: keine (kein allow_list- oder Mandanten-Operator-Eintrag passt auf Nick %s mit %s)",
+	"%s (any host)": "%s (beliebiger Host)",
+
+	// public.go
+	"Bridge: MQTT %s, IRC %s, %d messages in the last minute, %d sent to %s since start": "Bridge: MQTT %s, IRC %s, %d Nachrichten in der letzten Minute, %d an %s gesendet seit dem Start",
+}
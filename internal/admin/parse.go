@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// command is a parsed admin command line.
+//
+// Arguments are separated by whitespace. An argument starting with a double
+// or single quote runs to the matching quote and may contain spaces; inside
+// it, a backslash escapes the quote or a backslash. Quotes inside an argument
+// (don't) are literal. Unquoted arguments naming one of the command's
+// commandFlags are flags: --name=value, --name value, or a bare --name
+// (value "true") when followed by another flag or nothing. Anything else
+// starting with "--" is a plain argument, so free text keeps it.
+type command struct {
+	name  string
+	args  []string
+	flags map[string]string
+
+	text  string   // command line without the prefix
+	spans [][2]int // start and end offset of each argument in text
+}
+
+// commandFlags are the flags each command accepts.
+var commandFlags = map[string][]string{
+	"mute": {"for"},
+}
+
+// parseCommand parses a command line starting with prefix.
+func parseCommand(text, prefix string) (command, error) {
+	text = strings.TrimPrefix(text, prefix)
+	c := command{text: text, flags: make(map[string]string)}
+
+	type token struct {
+		value      string
+		start, end int
+		quoted     bool
+	}
+	var tokens []token
+	for i := 0; i < len(text); {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+		start := i
+		var sb strings.Builder
+		quoted := false
+		if q := text[i]; q == '"' || q == '\'' {
+			quoted = true
+			i++
+			closed := false
+			for i < len(text) {
+				ch := text[i]
+				if ch == '\\' && i+1 < len(text) && (text[i+1] == q || text[i+1] == '\\') {
+					sb.WriteByte(text[i+1])
+					i += 2
+					continue
+				}
+				i++
+				if ch == q {
+					closed = true
+					break
+				}
+				sb.WriteByte(ch)
+			}
+			if !closed {
+				return c, fmt.Errorf("unterminated %c quote", q)
+			}
+		}
+		for i < len(text) && text[i] != ' ' && text[i] != '\t' {
+			sb.WriteByte(text[i])
+			i++
+		}
+		tokens = append(tokens, token{value: sb.String(), start: start, end: i, quoted: quoted})
+	}
+	if len(tokens) == 0 {
+		return c, nil
+	}
+
+	c.name = strings.ToLower(tokens[0].value)
+	isFlag := func(t token) bool {
+		if t.quoted || !strings.HasPrefix(t.value, "--") {
+			return false
+		}
+		name, _, _ := strings.Cut(t.value[2:], "=")
+		for _, f := range commandFlags[c.name] {
+			if strings.EqualFold(name, f) {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 1; i < len(tokens); i++ {
+		t := tokens[i]
+		if !isFlag(t) {
+			c.args = append(c.args, t.value)
+			c.spans = append(c.spans, [2]int{t.start, t.end})
+			continue
+		}
+		name, value, ok := strings.Cut(t.value[2:], "=")
+		if !ok {
+			value = "true"
+			if i+1 < len(tokens) && !isFlag(tokens[i+1]) {
+				value = tokens[i+1].value
+				i++
+			}
+		}
+		c.flags[strings.ToLower(name)] = value
+	}
+	return c, nil
+}
+
+// rest returns the command line from argument n on, as typed (flags
+// included), for free-form text such as messages and templates. If argument n
+// is the last thing on the line it is returned unquoted.
+func (c command) rest(n int) string {
+	if n >= len(c.args) {
+		return ""
+	}
+	raw := strings.TrimSpace(c.text[c.spans[n][0]:])
+	if c.spans[n][0]+len(raw) == c.spans[n][1] {
+		return c.args[n]
+	}
+	return raw
+}
+
+// flag returns the value of flag name and whether it was given.
+func (c command) flag(name string) (string, bool) {
+	v, ok := c.flags[name]
+	return v, ok
+}
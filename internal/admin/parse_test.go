@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		args  []string
+		flags map[string]string
+	}{
+		{"!status", "status", nil, map[string]string{}},
+		{"!Mapping  list ", "mapping", []string{"list"}, map[string]string{}},
+		{`!echo "multi word message"`, "echo", []string{"multi word message"}, map[string]string{}},
+		{`!mute "msh/EU_868/#" --for 2h`, "mute", []string{"msh/EU_868/#"}, map[string]string{"for": "2h"}},
+		{`!mute x --FOR=2h --dry-run`, "mute", []string{"x", "--dry-run"}, map[string]string{"for": "2h"}},
+		{`!mute x --for`, "mute", []string{"x"}, map[string]string{"for": "true"}},
+		{`!mute x "--for" 2h`, "mute", []string{"x", "--for", "2h"}, map[string]string{}},
+		{`!echo 'single quoted' don't`, "echo", []string{"single quoted", "don't"}, map[string]string{}},
+		{`!echo "she said \"hi\"" "--not-a-flag"`, "echo", []string{`she said "hi"`, "--not-a-flag"}, map[string]string{}},
+		{`!echo use --force`, "echo", []string{"use", "--force"}, map[string]string{}},
+		{`!echo --for 2h`, "echo", []string{"--for", "2h"}, map[string]string{}},
+		{`!echo -- ""`, "echo", []string{"--", ""}, map[string]string{}},
+		{"!", "", nil, map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			c, err := parseCommand(tt.input, "!")
+			if err != nil {
+				t.Fatalf("parseCommand(%q) error: %v", tt.input, err)
+			}
+			if c.name != tt.name || !reflect.DeepEqual(c.args, tt.args) || !reflect.DeepEqual(c.flags, tt.flags) {
+				t.Errorf("parseCommand(%q) = %q %q %v, want %q %q %v", tt.input, c.name, c.args, c.flags, tt.name, tt.args, tt.flags)
+			}
+		})
+	}
+}
+
+func TestParseCommand_UnterminatedQuote(t *testing.T) {
+	if _, err := parseCommand(`!echo "oops`, "!"); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
+
+func TestCommandRest(t *testing.T) {
+	tests := []struct {
+		input string
+		n     int
+		want  string
+	}{
+		{`!mapping format 2 {{.Topic}}:  {{printf "%.1f" .JSON.t}}`, 2, `{{.Topic}}:  {{printf "%.1f" .JSON.t}}`},
+		{`!mapping format 2 "{{.Topic}} {{.Payload}}"`, 2, "{{.Topic}} {{.Payload}}"},
+		{`!mapping format 2`, 2, ""},
+	}
+	for _, tt := range tests {
+		c, err := parseCommand(tt.input, "!")
+		if err != nil {
+			t.Fatalf("parseCommand(%q) error: %v", tt.input, err)
+		}
+		if got := c.rest(tt.n); got != tt.want {
+			t.Errorf("rest(%d) of %q = %q, want %q", tt.n, tt.input, got, tt.want)
+		}
+	}
+}
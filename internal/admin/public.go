@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lrstanley/girc"
+)
+
+// publicCooldown is how often a public command is answered per channel, so
+// guests cannot make the bridge flood a busy channel.
+const publicCooldown = 30 * time.Second
+
+// answerPublic answers text if it is one of the configured public commands,
+// sent to a mapped or admin channel. Public commands are read-only and
+// answered for anyone, without authorization. It reports whether text was
+// handled, including public commands ignored during the cooldown.
+func (h *Handler) answerPublic(client *girc.Client, event girc.Event, target, text string) bool {
+	if len(h.cfg.PublicCommands) == 0 || !strings.HasPrefix(text, h.cfg.CommandPrefix) {
+		return false
+	}
+	c, err := parseCommand(text, h.cfg.CommandPrefix)
+	if err != nil || !containsFold(h.cfg.PublicCommands, c.name) {
+		return false
+	}
+	if !h.acceptsSource(target, false) && !h.mappedChannel(target) {
+		return false
+	}
+	nick := event.Source.Name
+	if !h.publicAllowed(strings.ToLower(target) + " " + c.name) {
+		h.logger.Debug().Str("nick", nick).Str("target", target).Str("command", c.name).Msg("public command ignored: cooldown")
+		return true
+	}
+	h.logger.Info().
+		Str("nick", nick).
+		Str("host", event.Source.Ident+"@"+event.Source.Host).
+		Str("target", target).
+		Str("text", text).
+		Msg("public command")
+
+	replyTo := h.replyTarget(target, nick, false)
+	switch c.name {
+	case "bridge":
+		h.cmdBridge(client, replyTo, target)
+	}
+	return true
+}
+
+// mappedChannel reports whether channel is a target of any mapping.
+func (h *Handler) mappedChannel(channel string) bool {
+	for _, m := range h.bridge.Mappings() {
+		if containsFold(m.Channels, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// publicAllowed reports whether key (channel and command) may be answered
+// now, at most once per publicCooldown.
+func (h *Handler) publicAllowed(key string) bool {
+	h.publicMu.Lock()
+	defer h.publicMu.Unlock()
+
+	now := h.clock.Now()
+	for k, last := range h.publicLast {
+		if now.Sub(last) >= publicCooldown {
+			delete(h.publicLast, k)
+		}
+	}
+	if _, ok := h.publicLast[key]; ok {
+		return false
+	}
+	h.publicLast[key] = now
+	return true
+}
+
+// cmdBridge handles the public !bridge command: connection state and message
+// counts only, nothing about the configuration.
+func (h *Handler) cmdBridge(client *girc.Client, replyTo, channel string) {
+	status := h.bridge.HealthStatus()
+	state := func(key string) string {
+		if ok, _ := status[key].(bool); ok {
+			return h.tr("up")
+		}
+		return h.tr("down")
+	}
+	perMinute, _ := status["messages_per_minute"].(int64)
+	sent, _ := status["channel_messages_sent"].(map[string]uint64)
+
+	h.reply(client, replyTo, h.withInstance(h.tr(
+		"Bridge: MQTT %s, IRC %s, %d messages in the last minute, %d sent to %s since start",
+		state("mqtt_connected"), state("irc_connected"), perMinute, sent[strings.ToLower(channel)], channel,
+	)))
+}
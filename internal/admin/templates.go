@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Templates customize admin replies (admin.templates), e.g. to tell the
+// instances of a multi-instance deployment apart. Empty templates keep the
+// built-in replies.
+type Templates struct {
+	Status string            // the !status line; see statusData
+	Help   string            // the !help layout; see helpData
+	Fields map[string]string // operator fields, {{.Fields.<name>}} in both
+}
+
+// replyTemplates are the parsed Templates.
+type replyTemplates struct {
+	status   *template.Template // nil = built-in
+	help     *template.Template // nil = built-in
+	fields   map[string]string
+	hostname string
+}
+
+// statusData is what the status template renders, e.g.
+// "{{.Fields.instance}}@{{.Hostname}}: MQTT={{.MQTT}} IRC={{.IRC}} queue={{.Queue}}/{{.QueueCapacity}}".
+type statusData struct {
+	MQTT, IRC                   string // "connected" or "DISCONNECTED", translated
+	MQTTConnected, IRCConnected bool
+	Queue, QueueCapacity        int
+	Health                      map[string]interface{} // the full /health report
+	Instance                    string                 // instance_name
+	Hostname                    string
+	Fields                      map[string]string
+}
+
+// helpData is what the help template renders. Lines are the built-in help
+// lines, so a layout can add a header and keep them:
+// "{{.Fields.instance}} admin commands:\n{{range .Lines}}{{.}}\n{{end}}".
+type helpData struct {
+	Prefix   string
+	Lines    []string
+	Instance string
+	Hostname string
+	Fields   map[string]string
+}
+
+// parseTemplates parses the configured templates. The config is validated
+// at load, so a template that fails here is reported and left built-in.
+func (h *Handler) parseTemplates(cfg Templates) replyTemplates {
+	t := replyTemplates{fields: cfg.Fields}
+	t.hostname, _ = os.Hostname()
+	parse := func(name, text string) *template.Template {
+		if text == "" {
+			return nil
+		}
+		tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			h.logger.Error().Err(err).Str("template", name).Msg("invalid admin template, using the built-in reply")
+			return nil
+		}
+		return tmpl
+	}
+	t.status = parse("status", cfg.Status)
+	t.help = parse("help", cfg.Help)
+	return t
+}
+
+// render executes tmpl and splits the result into reply lines, leaving out
+// empty ones. ok is false if the template failed, so the caller can fall
+// back to the built-in reply.
+func (h *Handler) render(tmpl *template.Template, data interface{}) (lines []string, ok bool) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		h.logger.Warn().Err(err).Str("template", tmpl.Name()).Msg("admin template failed, using the built-in reply")
+		return nil, false
+	}
+	for _, line := range strings.Split(sb.String(), "\n") {
+		if line = strings.TrimRight(line, " \r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, true
+}
@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lrstanley/girc"
+)
+
+const (
+	// whoisTimeout bounds how long !whoami and authorization wait for a
+	// WHOIS reply.
+	whoisTimeout = 5 * time.Second
+	// whoamiCooldown is how often a host may use !whoami. It is answered
+	// before authorization, so anyone in an admin channel can send it.
+	whoamiCooldown = 30 * time.Second
+)
+
+// isWhoami reports whether text is the !whoami command. It is answered for
+// every sender in an accepted source, authorized or not, so users can debug
+// why they are (not) matched.
+func (h *Handler) isWhoami(text string) bool {
+	c, err := parseCommand(text, h.cfg.CommandPrefix)
+	return err == nil && c.name == "whoami"
+}
+
+// whoamiAllowed reports whether host may use !whoami now, at most once per
+// whoamiCooldown. Hosts are tracked rather than nicks, which are free to
+// change.
+func (h *Handler) whoamiAllowed(host string) bool {
+	h.whoamiMu.Lock()
+	defer h.whoamiMu.Unlock()
+
+	now := h.clock.Now()
+	for k, last := range h.whoamiLast {
+		if now.Sub(last) >= whoamiCooldown {
+			delete(h.whoamiLast, k)
+		}
+	}
+	if _, ok := h.whoamiLast[host]; ok {
+		return false
+	}
+	h.whoamiLast[host] = now
+	return true
+}
+
+// cmdWhoami reports how the bridge sees the sender, by PM so the channel is
+// not flooded. The services account comes from the message's account tag or
+// girc's user tracking; failing that, from a WHOIS. Admin roles from the oper
+// and webhook providers are not shown. Requests within
+// whoamiCooldown of the last one from the same host are ignored.
+func (h *Handler) cmdWhoami(client *girc.Client, event girc.Event, target string, isPM bool) {
+	nick := event.Source.Name
+	hostmask := event.Source.Ident + "@" + event.Source.Host
+	if !h.whoamiAllowed(strings.ToLower(event.Source.Host)) {
+		h.logger.Debug().Str("nick", nick).Str("host", event.Source.Host).Msg("!whoami ignored: cooldown")
+		return
+	}
+	replyTo := nick
+
+	account := accountOf(client, event)
+	if account != "" {
+		h.replyLines(client, replyTo, h.whoamiLines(nick, hostmask, account, target, isPM))
+		return
+	}
+	go func() {
+		account, _ := h.whois(client, nick)
+		h.replyLines(client, replyTo, h.whoamiLines(nick, hostmask, account, target, isPM))
+	}()
+}
+
+// whoamiLines renders the !whoami reply: identity, account and the role the
+// sender gets for a command sent to target.
+func (h *Handler) whoamiLines(nick, hostmask, account, target string, isPM bool) []string {
+	if account == "" {
+		account = h.tr("none (not identified, or not shared by the server)")
+	}
+	lines := []string{h.tr("You are %s!%s, services account: %s", nick, hostmask, account)}
+
+	if entry, ok := matchAllowList(h.cfg.AllowList, nick, hostmask); ok {
+		return append(lines, h.tr("Role: bridge admin (allow_list entry %s)", h.entryString(entry)))
+	}
+	for _, a := range h.auth {
+		if a, ok := a.(accountAuth); ok && a.allows(account) {
+			return append(lines, h.tr("Role: bridge admin (services account %s)", account))
+		}
+	}
+	for _, t := range h.cfg.Tenants {
+		entry, ok := matchAllowList(t.Operators, nick, hostmask)
+		if !ok {
+			continue
+		}
+		if !isPM && !containsFold(t.Channels, target) {
+			lines = append(lines, h.tr("Role here: none; operator of tenant %s (entry %s) in %s and by PM", t.Name, h.entryString(entry), strings.Join(t.Channels, ", ")))
+			continue
+		}
+		lines = append(lines, h.tr("Role: operator of tenant %s (entry %s)", t.Name, h.entryString(entry)))
+	}
+	if len(lines) == 1 {
+		lines = append(lines, h.tr("Role: none (no allow_list or tenant operator entry matches nick %s with %s)", nick, hostmask))
+	}
+	return lines
+}
+
+// entryString renders an allow-list entry, e.g. "alice *@trusted.net".
+func (h *Handler) entryString(e AllowEntry) string {
+	if e.Hostmask == "" {
+		return h.tr("%s (any host)", e.Nick)
+	}
+	return e.Nick + " " + e.Hostmask
+}
+
+func (h *Handler) replyLines(client *girc.Client, target string, lines []string) {
+	for _, line := range lines {
+		h.reply(client, target, line)
+	}
+}
@@ -0,0 +1,255 @@
+// Package alertmanager turns Prometheus Alertmanager alerts into IRC lines.
+// Alerts come from a webhook payload (as relayed to MQTT by an Alertmanager
+// MQTT bridge) or from polling the v2 API; a Tracker follows them by
+// fingerprint so each alert is announced once when it fires and once when it
+// resolves.
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/irc"
+)
+
+const (
+	// DefaultFormat is the template of a firing alert.
+	DefaultFormat = "🔥 {{with .Severity}}[{{.}}] {{end}}{{.Name}}{{with .Instance}} on {{.}}{{end}}{{with .Summary}}: {{.}}{{end}}"
+	// DefaultResolvedFormat is the template of a resolved alert.
+	DefaultResolvedFormat = "✅ resolved: {{.Name}}{{with .Instance}} on {{.}}{{end}}{{with .Summary}}: {{.}}{{end}}"
+
+	// maxAPIResponse bounds the alert list read from the API.
+	maxAPIResponse = 4 << 20
+)
+
+// Alert is one Alertmanager alert, in the fields common to the webhook
+// payload and the v2 API.
+type Alert struct {
+	Fingerprint  string            `json:"fingerprint"`
+	Status       string            `json:"-"` // "firing" or "resolved"
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+}
+
+// key identifies the alert: its fingerprint, or its sorted labels when the
+// sender did not include one.
+func (a Alert) key() string {
+	if a.Fingerprint != "" {
+		return a.Fingerprint
+	}
+	names := make([]string, 0, len(a.Labels))
+	for name := range a.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name + "=" + a.Labels[name] + ",")
+	}
+	return sb.String()
+}
+
+// ParseWebhook decodes an Alertmanager webhook payload.
+func ParseWebhook(data []byte) ([]Alert, error) {
+	var payload struct {
+		Alerts []struct {
+			Alert
+			Status string `json:"status"`
+		} `json:"alerts"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Alerts == nil {
+		return nil, fmt.Errorf("not an Alertmanager webhook payload: no alerts")
+	}
+	alerts := make([]Alert, len(payload.Alerts))
+	for i, a := range payload.Alerts {
+		alerts[i] = a.Alert
+		alerts[i].Status = a.Status
+	}
+	return alerts, nil
+}
+
+// Fetch returns the active alerts from the v2 API at baseURL (e.g.
+// "http://localhost:9093"). Silenced and inhibited alerts are left out;
+// filters are Alertmanager matchers such as `severity="critical"`.
+func Fetch(ctx context.Context, client *http.Client, baseURL string, filters []string) ([]Alert, error) {
+	q := url.Values{"active": {"true"}, "silenced": {"false"}, "inhibited": {"false"}}
+	for _, f := range filters {
+		q.Add("filter", f)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/api/v2/alerts?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAPIResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxAPIResponse {
+		return nil, fmt.Errorf("alert list larger than %d bytes", maxAPIResponse)
+	}
+	var alerts []Alert
+	if err := json.Unmarshal(data, &alerts); err != nil {
+		return nil, err
+	}
+	for i := range alerts {
+		alerts[i].Status = "firing"
+	}
+	return alerts, nil
+}
+
+// Tracker remembers the firing alerts, so repeats are not announced again
+// and resolutions are. The zero value is not usable; use NewTracker.
+type Tracker struct {
+	mu     sync.Mutex
+	firing map[string]Alert // by key
+}
+
+// NewTracker creates a tracker with no firing alerts.
+func NewTracker() *Tracker {
+	return &Tracker{firing: make(map[string]Alert)}
+}
+
+// Update takes alerts with their status, as in a webhook payload, and
+// returns the ones to announce: firing alerts not seen firing before and
+// resolved alerts that were. Resolutions of alerts never seen firing (e.g.
+// from before a restart) are not announced.
+func (t *Tracker) Update(alerts []Alert) []Alert {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var changes []Alert
+	for _, a := range alerts {
+		key := a.key()
+		_, known := t.firing[key]
+		switch {
+		case a.Status == "resolved" && known:
+			delete(t.firing, key)
+			changes = append(changes, a)
+		case a.Status != "resolved" && !known:
+			a.Status = "firing"
+			t.firing[key] = a
+			changes = append(changes, a)
+		}
+	}
+	return changes
+}
+
+// Sync takes the complete list of active alerts, as polled from the API,
+// and returns the ones to announce: new firing alerts and, as resolved, the
+// known ones no longer in the list.
+func (t *Tracker) Sync(active []Alert) []Alert {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var changes []Alert
+	seen := make(map[string]bool, len(active))
+	for _, a := range active {
+		key := a.key()
+		seen[key] = true
+		if _, known := t.firing[key]; !known {
+			a.Status = "firing"
+			t.firing[key] = a
+			changes = append(changes, a)
+		}
+	}
+	var resolved []string
+	for key := range t.firing {
+		if !seen[key] {
+			resolved = append(resolved, key)
+		}
+	}
+	sort.Strings(resolved)
+	for _, key := range resolved {
+		a := t.firing[key]
+		delete(t.firing, key)
+		a.Status = "resolved"
+		changes = append(changes, a)
+	}
+	return changes
+}
+
+// Firing returns the number of alerts known to be firing.
+func (t *Tracker) Firing() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.firing)
+}
+
+// Formatter renders alerts with the firing and resolved templates.
+type Formatter struct {
+	firing   *template.Template
+	resolved *template.Template
+}
+
+// NewFormatter parses the templates; empty ones use the defaults. Template
+// fields: {{.Name}} (alertname), {{.Severity}}, {{.Instance}}, {{.Summary}}
+// (summary or description annotation), {{.Status}}, {{.StartsAt}},
+// {{.URL}} (generator URL), {{.Labels}} and {{.Annotations}}.
+func NewFormatter(format, resolvedFormat string) (*Formatter, error) {
+	if format == "" {
+		format = DefaultFormat
+	}
+	if resolvedFormat == "" {
+		resolvedFormat = DefaultResolvedFormat
+	}
+	firing, err := template.New("format").Option("missingkey=zero").Funcs(irc.Funcs).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format template: %w", err)
+	}
+	resolved, err := template.New("resolved_format").Option("missingkey=zero").Funcs(irc.Funcs).Parse(resolvedFormat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resolved_format template: %w", err)
+	}
+	return &Formatter{firing: firing, resolved: resolved}, nil
+}
+
+// Format renders one alert.
+func (f *Formatter) Format(a Alert) (string, error) {
+	summary := a.Annotations["summary"]
+	if summary == "" {
+		summary = a.Annotations["description"]
+	}
+	data := map[string]interface{}{
+		"Name":        a.Labels["alertname"],
+		"Severity":    a.Labels["severity"],
+		"Instance":    a.Labels["instance"],
+		"Summary":     summary,
+		"Status":      a.Status,
+		"StartsAt":    a.StartsAt,
+		"URL":         a.GeneratorURL,
+		"Labels":      a.Labels,
+		"Annotations": a.Annotations,
+	}
+	tmpl := f.firing
+	if a.Status == "resolved" {
+		tmpl = f.resolved
+	}
+	buf := irc.GetBuffer()
+	defer irc.PutBuffer(buf)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,113 @@
+package alertmanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const webhook = `{
+  "version": "4",
+  "status": "firing",
+  "alerts": [
+    {"status": "firing", "fingerprint": "a1", "labels": {"alertname": "DiskFull", "severity": "critical", "instance": "db1"},
+     "annotations": {"summary": "/var at 98%"}},
+    {"status": "resolved", "fingerprint": "b2", "labels": {"alertname": "HighLoad", "instance": "web1"},
+     "annotations": {"description": "load 12"}}
+  ]
+}`
+
+func TestTracker_Update(t *testing.T) {
+	alerts, err := ParseWebhook([]byte(webhook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].Status != "firing" || alerts[1].Status != "resolved" {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+
+	tr := NewTracker()
+	// HighLoad was never seen firing: its resolution is not announced.
+	if changes := tr.Update(alerts); len(changes) != 1 || changes[0].Fingerprint != "a1" {
+		t.Errorf("first update = %+v", changes)
+	}
+	if changes := tr.Update(alerts); len(changes) != 0 {
+		t.Errorf("repeat = %+v", changes)
+	}
+	alerts[0].Status = "resolved"
+	if changes := tr.Update(alerts[:1]); len(changes) != 1 || changes[0].Status != "resolved" {
+		t.Errorf("resolve = %+v", changes)
+	}
+	if tr.Firing() != 0 {
+		t.Errorf("firing = %d", tr.Firing())
+	}
+
+	if _, err := ParseWebhook([]byte(`{"temperature": 21}`)); err == nil {
+		t.Error("payload without alerts accepted")
+	}
+}
+
+func TestTracker_Sync(t *testing.T) {
+	tr := NewTracker()
+	a := Alert{Fingerprint: "a1", Labels: map[string]string{"alertname": "DiskFull"}}
+	b := Alert{Labels: map[string]string{"alertname": "HighLoad", "instance": "web1"}} // keyed by labels
+	if changes := tr.Sync([]Alert{a, b}); len(changes) != 2 {
+		t.Errorf("first sync = %+v", changes)
+	}
+	if changes := tr.Sync([]Alert{b, a}); len(changes) != 0 {
+		t.Errorf("unchanged sync = %+v", changes)
+	}
+	changes := tr.Sync([]Alert{b})
+	if len(changes) != 1 || changes[0].Fingerprint != "a1" || changes[0].Status != "resolved" {
+		t.Errorf("sync after resolve = %+v", changes)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v2/alerts" || q.Get("active") != "true" || q.Get("silenced") != "false" || q.Get("filter") != `severity="critical"` {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`[{"fingerprint": "a1", "labels": {"alertname": "DiskFull"}, "status": {"state": "active"}}]`))
+	}))
+	defer srv.Close()
+
+	alerts, err := Fetch(context.Background(), srv.Client(), srv.URL+"/", []string{`severity="critical"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Fingerprint != "a1" || alerts[0].Status != "firing" {
+		t.Errorf("alerts = %+v", alerts)
+	}
+
+	if _, err := Fetch(context.Background(), srv.Client(), srv.URL, nil); err == nil {
+		t.Error("error status accepted")
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	f, err := NewFormatter("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts, _ := ParseWebhook([]byte(webhook))
+	tests := []struct {
+		alert Alert
+		want  string
+	}{
+		{alerts[0], "🔥 [critical] DiskFull on db1: /var at 98%"},
+		{alerts[1], "✅ resolved: HighLoad on web1: load 12"},
+	}
+	for _, tt := range tests {
+		if got, err := f.Format(tt.alert); err != nil || got != tt.want {
+			t.Errorf("Format = %q, %v; want %q", got, err, tt.want)
+		}
+	}
+
+	if _, err := NewFormatter("{{.Name", ""); err == nil {
+		t.Error("invalid template accepted")
+	}
+}
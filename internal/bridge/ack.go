@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// AckingProcessor is implemented by processors whose messages announce
+// alerts by ID, such as the alert processor. An admin acknowledges one with
+// !ack; its repeats are then suppressed.
+type AckingProcessor interface {
+	Processor
+	// Ack marks the open alert with id as acknowledged and returns its key
+	// and MQTT topic, or false if the processor has no such alert. Called
+	// concurrently with Process.
+	Ack(id int) (alert, topic string, ok bool)
+}
+
+// Ack acknowledges the alert with id for the admin by, and publishes the
+// acknowledgement to bridge.ack_topic if set. Alert IDs are unique across
+// processors.
+func (b *Bridge) Ack(id int, by, comment string) (types.Ack, error) {
+	b.reloadMu.Lock()
+	var ack types.Ack
+	found := false
+	for _, p := range b.processors {
+		ap, ok := p.(AckingProcessor)
+		if !ok {
+			continue
+		}
+		if alert, topic, ok := ap.Ack(id); ok {
+			ack = types.Ack{ID: id, Alert: alert, Topic: topic, By: by, Comment: comment, Time: b.clock.Now()}
+			found = true
+			break
+		}
+	}
+	b.reloadMu.Unlock()
+	if !found {
+		return types.Ack{}, fmt.Errorf("no open alert #%d", id)
+	}
+
+	b.logger.Info().Int("alert_id", id).Str("alert", ack.Alert).Str("by", by).Str("comment", comment).Msg("alert acknowledged")
+	topic := b.config.AckTopic
+	if topic == "" {
+		return ack, nil
+	}
+	payload, _ := json.Marshal(ack)
+	if err := b.mqttClient.Publish(topic, 1, false, payload); err != nil {
+		return ack, fmt.Errorf("acknowledged, but not published: %w", err)
+	}
+	return ack, nil
+}
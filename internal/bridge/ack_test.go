@@ -0,0 +1,50 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// fakeAcking is an AckingProcessor with one open alert, #5.
+type fakeAcking struct{ acked bool }
+
+func (*fakeAcking) Process(types.Message) (ProcessResult, error) { return ProcessResult{}, nil }
+
+func (f *fakeAcking) Ack(id int) (string, string, bool) {
+	if id != 5 {
+		return "", "", false
+	}
+	f.acked = true
+	return "disk_full", "alarms/server1", true
+}
+
+func TestAck(t *testing.T) {
+	b := newReloadTestBridge(t, reloadTestConfig())
+	b.clock = schedule.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	p := &fakeAcking{}
+	b.processors["alarms/#"] = p
+
+	if _, err := b.Ack(4, "alice", ""); err == nil {
+		t.Error("Ack(4) succeeded")
+	}
+	ack, err := b.Ack(5, "alice", "rebooting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.Ack{ID: 5, Alert: "disk_full", Topic: "alarms/server1", By: "alice", Comment: "rebooting", Time: b.clock.Now()}
+	if ack != want || !p.acked {
+		t.Errorf("Ack = %+v, want %+v", ack, want)
+	}
+
+	// Publishing needs a broker; the ack itself still counts.
+	b.config = config.BridgeConfig{AckTopic: "alarms/ack"}
+	ack, err = b.Ack(5, "alice", "")
+	if ack.ID != 5 || err == nil || !strings.Contains(err.Error(), "not published") {
+		t.Errorf("Ack without broker = %+v, %v", ack, err)
+	}
+}
@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/alertmanager"
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+// alertmanagerTimeout bounds one poll of the Alertmanager API.
+const alertmanagerTimeout = 10 * time.Second
+
+// alertPoller polls bridge.alertmanager for active alerts.
+type alertPoller struct {
+	cfg       config.AlertmanagerConfig
+	client    *http.Client
+	tracker   *alertmanager.Tracker
+	formatter *alertmanager.Formatter
+	failing   bool // the last poll failed; only the first failure is reported
+}
+
+// newAlertPoller returns nil unless bridge.alertmanager.url is set.
+func newAlertPoller(cfg config.AlertmanagerConfig) (*alertPoller, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	f, err := alertmanager.NewFormatter(cfg.Format, cfg.ResolvedFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &alertPoller{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: alertmanagerTimeout},
+		tracker:   alertmanager.NewTracker(),
+		formatter: f,
+	}, nil
+}
+
+// runAlertmanager polls the Alertmanager API every interval and posts the
+// alerts that started firing or resolved since the last poll.
+func (b *Bridge) runAlertmanager(ctx context.Context) {
+	defer b.wg.Done()
+	for {
+		b.pollAlerts(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.clock.After(b.alerts.cfg.Interval):
+		}
+	}
+}
+
+// pollAlerts fetches the active alerts once and posts the changes. When the
+// API is unreachable nothing is posted, so alerts do not appear resolved.
+func (b *Bridge) pollAlerts(ctx context.Context) {
+	p := b.alerts
+	active, err := alertmanager.Fetch(ctx, p.client, p.cfg.URL, p.cfg.Filters)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		b.logger.Warn().Err(err).Str("url", p.cfg.URL).Msg("failed to poll Alertmanager")
+		if !p.failing {
+			p.failing = true
+			b.notifyOps("Alertmanager poll failed: " + err.Error())
+		}
+		return
+	}
+	if p.failing {
+		p.failing = false
+		b.logger.Info().Str("url", p.cfg.URL).Msg("Alertmanager reachable again")
+	}
+
+	for _, a := range p.tracker.Sync(active) {
+		line, err := p.formatter.Format(a)
+		if err != nil {
+			b.logger.Warn().Err(err).Str("alert", a.Labels["alertname"]).Msg("failed to format alert")
+			continue
+		}
+		for _, channel := range p.cfg.Channels {
+			if err := b.SendMessage(ctx, channel, b.limits.Fit(line)); err != nil {
+				b.logger.Warn().Err(err).Str("channel", channel).Msg("failed to post alert")
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package bridge
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+// aliasRule rewrites topics matching re to the expansion of canonical.
+type aliasRule struct {
+	re        *regexp.Regexp
+	canonical string
+}
+
+// topicAliases maps incoming MQTT topics to canonical topics before mapping,
+// so devices whose topic layout changed keep matching the existing mappings.
+type topicAliases struct {
+	exact map[string]string
+	rules []aliasRule // tried in config order after exact aliases
+}
+
+// newTopicAliases returns nil when no aliases are configured.
+func newTopicAliases(cfgs []config.TopicAlias) (*topicAliases, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	a := &topicAliases{exact: make(map[string]string)}
+	for i, c := range cfgs {
+		if c.Topic != "" {
+			a.exact[c.Topic] = c.Canonical
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + c.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("bridge.topic_aliases[%d].regex: %w", i, err)
+		}
+		a.rules = append(a.rules, aliasRule{re: re, canonical: c.Canonical})
+	}
+	return a, nil
+}
+
+// canonical returns the canonical topic for topic and whether an alias
+// applied. A nil table returns topic unchanged.
+func (a *topicAliases) canonical(topic string) (string, bool) {
+	if a == nil {
+		return topic, false
+	}
+	if c, ok := a.exact[topic]; ok {
+		return c, true
+	}
+	for _, r := range a.rules {
+		if m := r.re.FindStringSubmatchIndex(topic); m != nil {
+			return string(r.re.ExpandString(nil, r.canonical, topic, m)), true
+		}
+	}
+	return topic, false
+}
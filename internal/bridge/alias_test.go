@@ -0,0 +1,41 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+func TestTopicAliases(t *testing.T) {
+	a, err := newTopicAliases([]config.TopicAlias{
+		{Topic: "zigbee2mqtt/0x00158d0001a2b3c4", Canonical: "zigbee2mqtt/living_room"},
+		{Regex: `tasmota/(\w+)/tele/SENSOR`, Canonical: "tele/$1/SENSOR"},
+		{Regex: `zigbee2mqtt/.*`, Canonical: "never"}, // exact aliases win over regexes
+	})
+	if err != nil {
+		t.Fatalf("newTopicAliases: %v", err)
+	}
+
+	tests := []struct {
+		topic   string
+		want    string
+		aliased bool
+	}{
+		{"zigbee2mqtt/0x00158d0001a2b3c4", "zigbee2mqtt/living_room", true},
+		{"tasmota/kitchen/tele/SENSOR", "tele/kitchen/SENSOR", true},
+		{"tasmota/kitchen/tele/SENSOR/extra", "tasmota/kitchen/tele/SENSOR/extra", false}, // regexes match whole topics
+		{"prefix/tasmota/kitchen/tele/SENSOR", "prefix/tasmota/kitchen/tele/SENSOR", false},
+		{"sensors/temp", "sensors/temp", false},
+	}
+	for _, tt := range tests {
+		got, aliased := a.canonical(tt.topic)
+		if got != tt.want || aliased != tt.aliased {
+			t.Errorf("canonical(%q) = %q, %v; want %q, %v", tt.topic, got, aliased, tt.want, tt.aliased)
+		}
+	}
+
+	var none *topicAliases
+	if got, ok := none.canonical("a/b"); got != "a/b" || ok {
+		t.Errorf("nil table canonical = %q, %v", got, ok)
+	}
+}
@@ -0,0 +1,82 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/lrstanley/girc"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+)
+
+const defaultBannerFormat = "{{with .Instance}}[{{.}}] {{end}}bridge online ({{.Version}}, {{.Mappings}} mappings)"
+
+// SetVersion sets the version reported in the startup banner.
+func (b *Bridge) SetVersion(version string) {
+	b.version = version
+}
+
+// newBannerTemplate parses the banner format (nil when the banner is disabled).
+func newBannerTemplate(cfg config.BannerConfig) (*template.Template, error) {
+	if len(cfg.Channels) == 0 {
+		return nil, nil
+	}
+	format := cfg.Format
+	if format == "" {
+		format = defaultBannerFormat
+	}
+	tmpl, err := template.New("banner").Option("missingkey=zero").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bridge.banner.format: %w", err)
+	}
+	return tmpl, nil
+}
+
+// bannerDue reports whether the banner should be posted for a connection made
+// at now. The first connection always announces; a reconnect only does when
+// the preceding outage lasted at least minDowntime, so a flapping connection
+// does not spam the channels. Also returns the outage duration (0 at startup).
+func bannerDue(events []connstate.Event, now time.Time, minDowntime time.Duration) (bool, time.Duration) {
+	for i := len(events) - 1; i >= 0; i-- {
+		if !events[i].Connected {
+			downtime := now.Sub(events[i].Time)
+			return downtime >= minDowntime, downtime
+		}
+	}
+	return true, 0
+}
+
+// onIRCConnected posts the banner after a successful IRC (re)connection.
+func (b *Bridge) onIRCConnected(_ *girc.Client, _ girc.Event) {
+	due, downtime := bannerDue(b.ircClient.History().Events(), b.clock.Now(), b.config.Banner.MinDowntime)
+	if !due {
+		b.logger.Debug().Dur("downtime", downtime).Msg("short outage, suppressing banner")
+		return
+	}
+
+	var buf bytes.Buffer
+	err := b.bannerTmpl.Execute(&buf, map[string]interface{}{
+		"Version":  b.version,
+		"Instance": b.instance,
+		"Mappings": len(b.mapper.Info()),
+		"Downtime": downtime.Round(time.Second),
+	})
+	if err != nil {
+		b.logger.Error().Err(err).Msg("failed to render banner")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, channel := range b.config.Banner.Channels {
+			if err := b.ircClient.SendMessage(ctx, channel, buf.String()); err != nil {
+				b.logger.Error().Err(err).Str("channel", channel).Msg("failed to send banner")
+			}
+		}
+	}()
+}
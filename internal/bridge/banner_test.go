@@ -0,0 +1,46 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+)
+
+func TestBannerDue(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		events       []connstate.Event
+		wantDue      bool
+		wantDowntime time.Duration
+	}{
+		{"startup", nil, true, 0},
+		{"startup, up recorded", []connstate.Event{{Time: now, Connected: true}}, true, 0},
+		{
+			"short outage",
+			[]connstate.Event{{Time: now.Add(-time.Hour), Connected: true}, {Time: now.Add(-10 * time.Second)}},
+			false, 10 * time.Second,
+		},
+		{
+			"long outage",
+			[]connstate.Event{{Time: now.Add(-time.Hour), Connected: true}, {Time: now.Add(-5 * time.Minute)}},
+			true, 5 * time.Minute,
+		},
+		{
+			"long outage, up already recorded",
+			[]connstate.Event{{Time: now.Add(-5 * time.Minute)}, {Time: now, Connected: true}},
+			true, 5 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, downtime := bannerDue(tt.events, now, time.Minute)
+			if due != tt.wantDue || downtime != tt.wantDowntime {
+				t.Errorf("bannerDue() = %v, %s; want %v, %s", due, downtime, tt.wantDue, tt.wantDowntime)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+// circuitBreakers skip a channel the server keeps refusing messages or JOINs
+// for (banned, +m, unknown channel) for a cooldown, so the other channels are
+// not held up by its failures and the log is not flooded with them. Sends are
+// not acknowledged, so failures are the server's error replies (see
+// irc.Client.OnSendRejected), and a breaker only closes once the channel is
+// seen to work: the bot joins it or can speak in it again. After the cooldown
+// lines are tried again; another refusal skips the channel for another
+// cooldown. A nil *circuitBreakers never skips.
+type circuitBreakers struct {
+	cfg config.BreakerConfig
+
+	mu       sync.Mutex
+	channels map[string]*channelBreaker // by lower-case channel
+}
+
+// channelBreaker is the failure state of one channel.
+type channelBreaker struct {
+	failures int       // since the channel was last seen to work
+	until    time.Time // skipped until; zero while closed
+	skipped  int       // lines skipped since the breaker opened
+}
+
+// newCircuitBreakers returns nil unless bridge.circuit_breaker.failures is set.
+func newCircuitBreakers(cfg config.BreakerConfig) *circuitBreakers {
+	if cfg.Failures <= 0 {
+		return nil
+	}
+	return &circuitBreakers{cfg: cfg, channels: make(map[string]*channelBreaker)}
+}
+
+// allow reports whether to send a line to channel now, and counts it as
+// skipped if not.
+func (c *circuitBreakers) allow(channel string, now time.Time) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cb := c.channels[strings.ToLower(channel)]
+	if cb == nil || !now.Before(cb.until) {
+		return true
+	}
+	cb.skipped++
+	return false
+}
+
+// failed counts a refusal for channel and reports whether the breaker opened
+// with it. A refusal after a cooldown opens it again, silently.
+func (c *circuitBreakers) failed(channel string, now time.Time) (opened bool) {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strings.ToLower(channel)
+	cb := c.channels[key]
+	if cb == nil {
+		cb = &channelBreaker{}
+		c.channels[key] = cb
+	}
+	cb.failures++
+	if !cb.until.IsZero() {
+		cb.until = now.Add(c.cfg.Cooldown)
+		return false
+	}
+	if cb.failures < c.cfg.Failures {
+		return false
+	}
+	cb.until = now.Add(c.cfg.Cooldown)
+	return true
+}
+
+// succeeded resets channel's breaker once the channel is seen to work, and
+// reports whether it was open, and how many lines it skipped.
+func (c *circuitBreakers) succeeded(channel string) (closed bool, skipped int) {
+	if c == nil {
+		return false, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strings.ToLower(channel)
+	cb := c.channels[key]
+	if cb == nil {
+		return false, 0
+	}
+	delete(c.channels, key)
+	return !cb.until.IsZero(), cb.skipped
+}
+
+// open returns the channels whose breaker is open, sorted.
+func (c *circuitBreakers) open() []string {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var channels []string
+	for channel, cb := range c.channels {
+		if !cb.until.IsZero() {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// breakerFailed counts a refusal of the server for channel against its
+// breaker (the irc.Client.OnSendRejected callback). Local failures, such as
+// sends while IRC is down, say nothing about the channel and are not counted.
+func (b *Bridge) breakerFailed(channel, reason string) {
+	if !b.breakers.failed(channel, b.clock.Now()) {
+		return
+	}
+	cfg := b.config.CircuitBreaker
+	b.logger.Warn().
+		Str("channel", channel).
+		Str("reason", reason).
+		Int("failures", cfg.Failures).
+		Dur("cooldown", cfg.Cooldown).
+		Msg("sends keep failing, skipping channel")
+	b.notifyOps(fmt.Sprintf("Sending to %s failed %d times in a row (%s); skipping it for %s", channel, cfg.Failures, reason, cfg.Cooldown))
+}
+
+// breakerSucceeded resets channel's breaker once the bot joined it or can
+// speak in it again, and reports a channel that is back.
+func (b *Bridge) breakerSucceeded(channel string) {
+	closed, skipped := b.breakers.succeeded(channel)
+	if !closed {
+		return
+	}
+	b.logger.Info().Str("channel", channel).Int("skipped", skipped).Msg("sends work again, channel resumed")
+	b.notifyOps(fmt.Sprintf("Sending to %s works again; %d messages were skipped", channel, skipped))
+}
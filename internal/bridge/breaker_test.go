@@ -0,0 +1,98 @@
+package bridge
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+func TestCircuitBreakers(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	c := newCircuitBreakers(config.BreakerConfig{Failures: 3, Cooldown: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		if c.failed("#Banned", now) {
+			t.Fatalf("opened after %d failures", i+1)
+		}
+	}
+	if !c.failed("#banned", now) {
+		t.Fatal("not opened after 3 failures")
+	}
+	if c.allow("#banned", now.Add(time.Second)) || c.allow("#BANNED", now.Add(2*time.Second)) {
+		t.Error("open breaker allowed a line")
+	}
+	if !c.allow("#ok", now) {
+		t.Error("other channel skipped")
+	}
+	if got := c.open(); !reflect.DeepEqual(got, []string{"#banned"}) {
+		t.Errorf("open() = %v", got)
+	}
+
+	// The try after the cooldown fails: skipped for another cooldown, silently.
+	now = now.Add(time.Minute)
+	if !c.allow("#banned", now) {
+		t.Fatal("no try after the cooldown")
+	}
+	if c.failed("#banned", now) {
+		t.Error("failed try reported as a new opening")
+	}
+	if c.allow("#banned", now.Add(time.Second)) {
+		t.Error("allowed a line after a failed try")
+	}
+
+	now = now.Add(time.Minute)
+	closed, skipped := c.succeeded("#banned")
+	if !closed || skipped != 3 {
+		t.Errorf("succeeded() = %v, %d, want closed with 3 skipped", closed, skipped)
+	}
+	if closed, _ := c.succeeded("#ok"); closed {
+		t.Error("closed a breaker that never opened")
+	}
+	if got := c.open(); len(got) != 0 {
+		t.Errorf("open() after success = %v", got)
+	}
+
+	var off *circuitBreakers
+	if !off.allow("#x", now) || off.failed("#x", now) {
+		t.Error("nil breakers skip")
+	}
+	if newCircuitBreakers(config.BreakerConfig{}) != nil {
+		t.Error("breakers without failures set")
+	}
+}
+
+// TestBreaker_ServerReplies drives the breakers through the callbacks of the
+// IRC client (see irc.TestSendRejected): the server's error replies, and a
+// JOIN or regained voice as the sign that a channel works.
+func TestBreaker_ServerReplies(t *testing.T) {
+	cfg := config.BreakerConfig{Failures: 2, Cooldown: time.Minute}
+	b := &Bridge{
+		config:   config.BridgeConfig{CircuitBreaker: cfg},
+		clock:    schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)),
+		logger:   zerolog.New(os.Stderr).Level(zerolog.Disabled),
+		breakers: newCircuitBreakers(cfg),
+	}
+	// ERR_CANNOTSENDTOCHAN twice, ERR_NOSUCHCHANNEL twice.
+	b.breakerFailed("#quiet", "Cannot send to channel")
+	b.breakerFailed("#gone", "No such channel")
+	if got := b.breakers.open(); len(got) != 0 {
+		t.Fatalf("opened after one reply: %v", got)
+	}
+	b.breakerFailed("#quiet", "Cannot send to channel")
+	b.breakerFailed("#gone", "No such channel")
+	if got := b.breakers.open(); !reflect.DeepEqual(got, []string{"#gone", "#quiet"}) {
+		t.Fatalf("open() = %v", got)
+	}
+
+	b.onChannelBlocked("#quiet", "", false) // voiced
+	b.breakerSucceeded("#gone")             // joined
+	if got := b.breakers.open(); len(got) != 0 {
+		t.Errorf("open() after the channels work = %v", got)
+	}
+}
@@ -3,28 +3,101 @@ package bridge
 import (
 	"context"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/lrstanley/girc"
 	"github.com/rs/zerolog"
 
 	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/eventlog"
 	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/metadata"
 	"github.com/dyuri/mqtt2irc/internal/mqtt"
+	"github.com/dyuri/mqtt2irc/internal/redact"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/schema"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/internal/transform"
 	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
 // Bridge coordinates message flow from MQTT to IRC
 type Bridge struct {
-	config     config.BridgeConfig
-	mqttClient *mqtt.Client
-	ircClient  *irc.Client
-	mapper     *Mapper
-	processors map[string]Processor // mqtt_topic pattern → Processor (nil if none configured)
-	msgQueue   chan types.Message
-	logger     zerolog.Logger
-	wg         sync.WaitGroup
+	config      config.BridgeConfig
+	ircCfg      config.IRCConfig
+	mqttClient  *mqtt.Client
+	ircClient   *irc.Client
+	mapper      *Mapper
+	processors  map[string]Processor      // mappingKey → Processor (nil if none configured)
+	schemas     map[string]*schema.Schema // by mappingKey, for mappings with a schema
+	transforms  map[string]transform.Func // by mappingKey, for mappings with output transforms
+	groupSpecs  map[string]groupSpec      // by mappingKey, for mappings with a group key
+	spam        map[string]*spamFilter    // by mappingKey, for mappings with spam heuristics
+	breakers    *circuitBreakers
+	retired     *retiredMappings
+	groups      *grouper
+	schemaErrs  *schemaViolations
+	zones       map[string]*time.Location // by mapping timezone, "" = global, for {{.Time}}
+	reports     []*scheduledReport        // of ReportingProcessors; guarded by reloadMu
+	heartbeats  []*heartbeatMonitor
+	msgQueue    chan types.Message
+	highQueue   chan types.Message // QoS-priority messages; nil unless queue.qos_priority is set
+	spill       highSpill          // QoS-priority messages that found highQueue full
+	queueOps    chan queueOp       // admin drain/clear requests (see queue.go)
+	backlog     []types.Message    // messages kept back by !queue clear <mapping> or restored from storage.queue.file; processed first
+	queueStore  *queueStore        // nil unless bridge.storage.queue.file is set
+	queueMu     sync.Mutex         // guards closing msgQueue against requeue
+	queueClosed bool
+	bannerTmpl  *template.Template // nil unless bridge.banner.channels is set
+	version     string
+	instance    string // instance_name; empty for a single bridge
+	clock       schedule.Clock
+	redactor    *redact.Redactor // nil unless bridge.redaction is configured
+	usage       *usageTracker
+	mutes       *muteList
+	tracer      *tracer
+	watcher     *watcher
+	alerts      *alertPoller   // nil unless bridge.alertmanager.url is set
+	budget      *channelBudget // nil unless bridge.channel_rate is set
+	drops       *stats.Drops
+	commands    *stats.Commands
+	aliases     *topicAliases   // nil unless bridge.topic_aliases is set
+	metadata    *metadata.Table // nil unless bridge.metadata.file is set
+	limits      irc.Limits
+	order       *channelOrder   // nil unless bridge.channel_order is round_robin or random
+	outbox      *outboxes       // nil unless bridge.channel_queue_size is set
+	history     *channelHistory // nil unless bridge.history_size is set
+	samples     *payloadSamples // last payload per topic, for !fields
+	topicStats  *topicStats     // nil unless bridge.topic_stats is enabled
+	payloadLog  *payloadLog     // nil unless logging.payloads is set
+	reviews     *reviewQueue    // messages held in review channels (see review.go)
+	events      *eventlog.Log   // nil unless logging.event_log is set
+	received    minuteRate      // messages received, for messages_per_minute
+	handled     atomic.Uint64   // messages received, for the error budget
+	errBudget   *errorBudget    // nil unless bridge.error_budget.threshold is set
+	faults      *faults         // nil unless bridge.faults is set
+	paused      atomic.Bool     // set by the Home Assistant pause button
+	draining    atomic.Bool     // set by Drain
+	startup     *startupState   // nil unless startup.degraded is set
+	safeMode    bool            // --safe-mode: nothing is sent to IRC or MQTT
+	sentHooks   *sentHooks      // run after each line sent to IRC
+
+	// Config reload (see reload.go)
+	loadConfig func() (*config.Config, error)
+	reloads    chan reloadOp
+	reloadMu   sync.Mutex
+	current    *config.Config  // config in effect, for reload diffs
+	pending    *config.Config  // previewed by PrepareReload, awaiting ApplyReload
+	remote     *remoteMappings // nil unless bridge.remote_mappings is set
+
+	logger zerolog.Logger
+	wg     sync.WaitGroup
 }
 
 // New creates a new bridge instance
@@ -32,21 +105,62 @@ func New(cfg *config.Config, logger zerolog.Logger) (*Bridge, error) {
 	// Create message queue
 	msgQueue := make(chan types.Message, cfg.Bridge.Queue.MaxSize)
 
+	// Connection state histories (shared with /health and !status detail)
+	mqttHistory := connstate.New("mqtt", cfg.Bridge.FlapDetection.HistorySize)
+	ircHistory := connstate.New("irc", cfg.Bridge.FlapDetection.HistorySize)
+
+	// Heartbeat and remote mappings topics need their own subscriptions.
+	mqttCfg := cfg.MQTT
+	heartbeats := make([]*heartbeatMonitor, 0, len(cfg.Bridge.Heartbeats))
+	for _, hb := range cfg.Bridge.Heartbeats {
+		m, err := newHeartbeatMonitor(hb, schedule.Real.Now())
+		if err != nil {
+			return nil, err
+		}
+		heartbeats = append(heartbeats, m)
+	}
+	mqttCfg.Topics = subscriptions(cfg.MQTT, cfg.Bridge)
+	if cfg.SafeMode {
+		// Do not take over the session of the production bridge, and do not
+		// publish probes.
+		mqttCfg.ClientID += "-safe"
+		mqttCfg.Probe.Interval = 0
+	}
+
 	// Create MQTT client
-	mqttClient, err := mqtt.New(cfg.MQTT, msgQueue, logger)
+	mqttClient, err := mqtt.New(mqttCfg, msgQueue, mqttHistory, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MQTT client: %w", err)
 	}
 
+	var highQueue chan types.Message
+	if cfg.Bridge.Queue.QoSPriority {
+		highQueue = make(chan types.Message, cfg.Bridge.Queue.MaxSize)
+	}
+
 	// Create IRC client
-	ircClient := irc.New(cfg.IRC, logger)
+	ircClient := irc.New(cfg.IRC, ircHistory, logger)
+	if cfg.SafeMode {
+		mqttClient.SetSafeMode()
+		ircClient.SetSafeMode()
+	}
 
 	// Create mapper
 	mapper := NewMapper(cfg.Bridge.Mappings)
 
-	// Instantiate processors for mappings that declare one.
+	history, err := openChannelHistory(cfg.Bridge.HistorySize, cfg.Location(), cfg.Bridge.Storage.History, schedule.Real)
+	if err != nil {
+		return nil, err
+	}
+	queueStore, restored, err := openQueueStore(cfg.Bridge.Storage.Queue, schedule.Real)
+	if err != nil {
+		return nil, err
+	}
+
+	// Instantiate processors for mappings that declare one, by mappingKey.
 	processors := make(map[string]Processor)
-	for _, m := range cfg.Bridge.Mappings {
+	keys := mappingKeys(cfg.Bridge.Mappings)
+	for i, m := range cfg.Bridge.Mappings {
 		if m.Processor == "" {
 			continue
 		}
@@ -54,39 +168,290 @@ func New(cfg *config.Config, logger zerolog.Logger) (*Bridge, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to create processor for mapping %q: %w", m.MQTTTopic, err)
 		}
-		processors[m.MQTTTopic] = p
+		processors[keys[i]] = p
+	}
+
+	schemas, err := loadSchemas(cfg.Bridge.Mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := loadZones(cfg.Bridge.Mappings, cfg.Location())
+	if err != nil {
+		return nil, err
+	}
+	reports, err := loadReports(cfg.Bridge.Mappings, processors, cfg.Location(), schedule.Real, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	bannerTmpl, err := newBannerTemplate(cfg.Bridge.Banner)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := newTopicAliases(cfg.Bridge.TopicAliases)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta *metadata.Table
+	if cfg.Bridge.Metadata.File != "" {
+		if meta, err = metadata.Load(cfg.Bridge.Metadata.File); err != nil {
+			return nil, err
+		}
+	}
+
+	limits := irc.Limits{
+		MaxLength: cfg.Bridge.MaxMessageLength,
+		MaxBytes:  cfg.Bridge.MaxMessageBytes,
+		Width:     cfg.Bridge.TruncateMode == "width",
+		Suffix:    cfg.Bridge.TruncateSuffix,
+	}
+
+	transforms, err := loadTransforms(cfg.Bridge.Mappings, cfg.Bridge, limits)
+	if err != nil {
+		return nil, err
+	}
+	groupSpecs, err := loadGroups(cfg.Bridge.Mappings)
+	if err != nil {
+		return nil, err
+	}
+	spam, err := loadSpamFilters(cfg.Bridge.Mappings, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rcfg := cfg.Bridge.Redaction
+	redactor, err := redact.New(rcfg.Fields, rcfg.Patterns, rcfg.Mask)
+	if err != nil {
+		return nil, err
+	}
+	if redactor != nil {
+		ircClient.SetLogRedactor(redactor.String)
+	}
+
+	alerts, err := newAlertPoller(cfg.Bridge.Alertmanager)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bridge.alertmanager: %w", err)
+	}
+
+	faults, err := newFaults(cfg.Bridge.Faults, cfg.Location())
+	if err != nil {
+		return nil, fmt.Errorf("invalid bridge.faults: %w", err)
+	}
+
+	var events *eventlog.Log
+	if cfg.Logging.EventLog != "" {
+		if events, err = eventlog.Open(cfg.Logging.EventLog); err != nil {
+			return nil, fmt.Errorf("failed to open event log: %w", err)
+		}
 	}
 
-	return &Bridge{
+	b := &Bridge{
 		config:     cfg.Bridge,
+		ircCfg:     cfg.IRC,
 		mqttClient: mqttClient,
 		ircClient:  ircClient,
 		mapper:     mapper,
 		processors: processors,
+		schemas:    schemas,
+		transforms: transforms,
+		groupSpecs: groupSpecs,
+		spam:       spam,
+		zones:      zones,
+		reports:    reports,
+		schemaErrs: newSchemaViolations(),
+		heartbeats: heartbeats,
 		msgQueue:   msgQueue,
+		highQueue:  highQueue,
+		spill:      newHighSpill(),
+		queueOps:   make(chan queueOp),
+		reloads:    make(chan reloadOp),
+		current:    cfg,
+		remote:     newRemoteMappings(cfg.Bridge.RemoteMappings),
+		bannerTmpl: bannerTmpl,
+		version:    "dev",
+		instance:   cfg.InstanceName,
+		safeMode:   cfg.SafeMode,
+		sentHooks:  newSentHooks(cfg.Bridge.SentHook, logger),
+		clock:      schedule.Real,
+		redactor:   redactor,
+		usage:      newUsageTracker(cfg.Tenants, cfg.Location(), schedule.Real),
+		mutes:      newMuteList(schedule.Real),
+		tracer:     newTracer(schedule.Real),
+		watcher:    newWatcher(schedule.Real),
+		alerts:     alerts,
+		budget:     newChannelBudget(cfg.Bridge.ChannelRate, cfg.Bridge.OverflowChannel),
+		errBudget:  newErrorBudget(cfg.Bridge.ErrorBudget),
+		breakers:   newCircuitBreakers(cfg.Bridge.CircuitBreaker),
+		retired:    newRetiredMappings(cfg.Bridge.RemovedMappings),
+		faults:     faults,
+		drops:      stats.NewDrops(),
+		commands:   stats.NewCommands(),
+		aliases:    aliases,
+		metadata:   meta,
+		limits:     limits,
+		order:      newChannelOrder(cfg.Bridge.ChannelOrder),
+		history:    history,
+		queueStore: queueStore,
+		backlog:    restored,
+		samples:    newPayloadSamples(),
+		topicStats: newTopicStats(cfg.Bridge.TopicStats),
+		payloadLog: newPayloadLog(cfg.Logging.Payloads),
+		reviews:    newReviewQueue(),
+		events:     events,
 		logger:     logger.With().Str("component", "bridge").Logger(),
-	}, nil
+	}
+	if cfg.Startup.Degraded {
+		b.startup = newStartupState("MQTT", "IRC")
+	}
+
+	b.outbox = newOutboxes(cfg.Bridge.ChannelQueueSize, b.deliver)
+	b.groups = newGrouper(schedule.Real, b.sendGroup)
+	if len(restored) > 0 {
+		b.logger.Info().Int("messages", len(restored)).Str("path", cfg.Bridge.Storage.Queue.File).Msg("restored unsent messages")
+	}
+	mqttClient.SetDropCounter(b.drops)
+	if highQueue != nil {
+		watermark := int(float64(cfg.Bridge.Queue.MaxSize) * cfg.Bridge.Queue.LowPriorityWatermark)
+		mqttClient.SetPriorityQueue(highQueue, watermark, b.spill.add)
+	}
+
+	if bannerTmpl != nil {
+		ircClient.AddHandler(girc.CONNECTED, b.onIRCConnected)
+	}
+
+	ircClient.OnChannelBlocked(b.onChannelBlocked)
+	ircClient.OnSendRejected(b.breakerFailed)
+	ircClient.OnJoined(b.breakerSucceeded)
+
+	mqttClient.OnProbeFailure(func(timeout time.Duration) {
+		b.notifyOps(fmt.Sprintf("MQTT broker stopped delivering: probe not received back within %s, reconnecting", timeout))
+	})
+	mqttClient.OnStorm(func(topic string, maxRate int, cooldown time.Duration) {
+		b.notifyOps(fmt.Sprintf("Topic storm on %s: over %d messages/s, suppressed for %s", topic, maxRate, cooldown))
+	})
+
+	if flap := cfg.Bridge.FlapDetection; flap.MaxDisconnects > 0 {
+		policy := connstate.FlapPolicy{
+			MaxDisconnects: flap.MaxDisconnects,
+			Window:         flap.Window,
+			Backoff:        flap.Backoff,
+		}
+		mqttHistory.SetFlapPolicy(policy, b.onFlap)
+		ircHistory.SetFlapPolicy(policy, b.onFlap)
+	}
+
+	return b, nil
+}
+
+// onFlap is called once when a connection starts flapping.
+func (b *Bridge) onFlap(name string, disconnects int) {
+	window := b.config.FlapDetection.Window
+	b.logger.Warn().
+		Str("connection", name).
+		Int("disconnects", disconnects).
+		Dur("window", window).
+		Dur("backoff", b.config.FlapDetection.Backoff).
+		Msg("connection flapping")
+	b.notifyOps(fmt.Sprintf("%s connection is flapping: %d disconnects in %s, reconnect backoff %s",
+		name, disconnects, window, b.config.FlapDetection.Backoff))
+}
+
+// notifyOps posts an operational notification to all configured ops channels.
+// Delivery is asynchronous and best-effort; it must not block connection handlers.
+func (b *Bridge) notifyOps(message string) {
+	if len(b.config.OpsChannels) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, channel := range b.config.OpsChannels {
+			if err := b.ircClient.SendMessage(ctx, channel, message); err != nil {
+				b.logger.Error().Err(err).Str("channel", channel).Msg("failed to send ops notification")
+			}
+		}
+	}()
 }
 
 // Run starts the bridge
 func (b *Bridge) Run(ctx context.Context) error {
 	b.logger.Info().Msg("starting bridge")
+	if b.safeMode {
+		b.logger.Warn().Msg("safe mode: nothing is sent to IRC or MQTT, state-changing admin commands are rejected")
+	}
 
-	// Connect to MQTT
-	if err := b.mqttClient.Connect(ctx); err != nil {
-		return fmt.Errorf("failed to connect to MQTT: %w", err)
+	if b.config.HomeAssistant.Discovery {
+		b.setupHomeAssistant()
 	}
 
-	// Connect to IRC
-	if err := b.ircClient.Connect(ctx); err != nil {
-		b.mqttClient.Disconnect(5 * time.Second)
-		return fmt.Errorf("failed to connect to IRC: %w", err)
+	if b.startup != nil {
+		// Degraded startup: connect both sides in the background.
+		if err := b.startDegraded(ctx); err != nil {
+			return err
+		}
+	} else {
+		// Connect to MQTT and IRC in parallel; bridging starts once both are
+		// up. Messages received meanwhile wait in the queue.
+		connCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		err := connectAll(connCtx, cancel, b.connectors(b.current.Startup.Retry)...)
+		if err != nil {
+			b.mqttClient.Disconnect(5 * time.Second)
+			return err
+		}
 	}
 
 	// Start message processor
+	b.outbox.start(ctx)
 	b.wg.Add(1)
 	go b.processMessages(ctx)
 
+	b.wg.Add(1)
+	go b.runReports(ctx)
+
+	if len(b.heartbeats) > 0 {
+		b.wg.Add(1)
+		go b.runHeartbeats(ctx)
+	}
+
+	if b.startup == nil {
+		b.announce()
+	}
+	if b.config.StatsPublish.Topic != "" {
+		b.wg.Add(1)
+		go b.runStatsPublisher(ctx)
+	}
+	if b.remote != nil {
+		b.wg.Add(1)
+		go b.runRemoteMappings(ctx)
+	}
+	if b.alerts != nil {
+		b.wg.Add(1)
+		go b.runAlertmanager(ctx)
+	}
+	if b.errBudget != nil {
+		b.wg.Add(1)
+		go b.runErrorBudget(ctx)
+	}
+	if snapshotsNeeded(b.current.Bridge.Storage, b.history != nil) {
+		b.wg.Add(1)
+		go b.runSnapshots(ctx)
+	}
+	if b.faults != nil {
+		b.logger.Warn().
+			Float64("irc_send_drop", b.config.Faults.IRCSendDrop).
+			Dur("mqtt_delay", b.config.Faults.MQTTDelay).
+			Str("irc_disconnect", b.config.Faults.IRCDisconnect).
+			Msg("fault injection enabled")
+		if b.faults.disconnect != nil {
+			b.wg.Add(1)
+			go b.runFaults(ctx)
+		}
+	}
+
 	b.logger.Info().Msg("bridge running")
 
 	// Wait for context cancellation
@@ -96,160 +461,498 @@ func (b *Bridge) Run(ctx context.Context) error {
 	return nil
 }
 
+// announce publishes the Home Assistant discovery and capabilities
+// documents once MQTT is connected.
+func (b *Bridge) announce() {
+	if b.config.HomeAssistant.Discovery {
+		b.publishDiscovery()
+	}
+	b.publishCapabilities()
+}
+
+// flushProcessors writes out the on-disk state of the processors. The
+// caller holds reloadMu.
+func (b *Bridge) flushProcessors() {
+	for key, p := range b.processors {
+		if fp, ok := p.(FlushingProcessor); ok {
+			if err := fp.Flush(); err != nil {
+				b.logger.Warn().Err(err).Str("mapping", key).Msg("failed to flush processor state")
+			}
+		}
+	}
+}
+
 // processMessages processes messages from the queue
 func (b *Bridge) processMessages(ctx context.Context) {
 	defer b.wg.Done()
 
+	// Periodic queue snapshots, for when no messages come in.
+	var snapshots <-chan time.Time
+	if b.queueStore != nil && b.queueStore.state.Policy().Memory() {
+		snapshots = b.clock.After(snapshotCheck)
+	}
+
+	// In degraded startup, messages wait in the queues until IRC is up.
+	var ircReady <-chan struct{}
+	if b.startup != nil {
+		ircReady = b.ircClient.Ready()
+	}
+
 	for {
+		b.takeSpill()
+		b.queueChanged()
+
+		msgQueue, highQueue := b.msgQueue, b.highQueue
+		if ircReady != nil {
+			select {
+			case <-ircReady:
+				ircReady = nil
+			default:
+				msgQueue, highQueue = nil, nil
+			}
+		}
+
+		// High-priority messages always go first.
+		select {
+		case msg := <-highQueue:
+			b.handleMessage(ctx, msg)
+			continue
+		default:
+		}
+
+		if len(b.backlog) > 0 && msgQueue != nil && ctx.Err() == nil {
+			msg := b.backlog[0]
+			b.backlog = b.backlog[1:]
+			b.handleMessage(ctx, msg)
+			continue
+		}
+
 		select {
 		case <-ctx.Done():
 			b.logger.Info().Msg("stopping message processor")
 			return
 
-		case msg := <-b.msgQueue:
+		case op := <-b.reloads:
+			op.result <- b.runReload(op.cfg)
+
+		case op := <-b.queueOps:
+			for _, msg := range b.runQueueOp(op) {
+				if ctx.Err() != nil {
+					break
+				}
+				b.handleMessage(ctx, msg)
+			}
+
+		case <-ircReady:
+
+		case <-b.spill.wake:
+
+		case msg := <-highQueue:
+			b.handleMessage(ctx, msg)
+
+		case msg := <-msgQueue:
 			b.handleMessage(ctx, msg)
+
+		case <-snapshots:
+			if b.queueStore.state.Due() {
+				b.saveQueue()
+			}
+			snapshots = b.clock.After(snapshotCheck)
 		}
 	}
 }
 
 // handleMessage processes a single message
 func (b *Bridge) handleMessage(ctx context.Context, msg types.Message) {
+	b.faults.delay(ctx, b.clock)
+	b.received.add(b.clock.Now())
+	b.handled.Add(1)
+	b.observeHeartbeats(ctx, msg.Topic)
+	if b.remote.observe(msg) {
+		return
+	}
+
+	// Everything past heartbeats (traces, mutes, mappings) sees the canonical topic.
+	original := msg.Topic
+	msg.Topic, _ = b.aliases.canonical(msg.Topic)
+	// Only topics tracked one by one keep a sample, bounding memory under "#".
+	if b.topicStats.received(msg.Topic, b.clock.Now(), b.mapper.matchTopic) {
+		b.samples.record(msg.Topic, msg.Payload, b.clock.Now())
+	}
+	b.watcher.check(msg, b.mapper.matchTopic)
+
+	tr := b.tracer.start(msg.Topic, b.mapper.matchTopic, b.logger, b.redactor.String)
+	if tr != nil {
+		tr.step("received %d bytes, qos %d, id %s: %s", len(msg.Payload), msg.QoS, msg.ID, tracePayload(b.redactor.Payload(msg.Payload)))
+		if original != msg.Topic {
+			tr.step("topic alias: %s → %s", original, msg.Topic)
+		}
+	}
+
+	if b.paused.Load() {
+		b.dropped(stats.DropPaused, msg).Msg("bridge paused")
+		tr.step("bridge paused")
+		return
+	}
+
+	if b.mutes.muted(msg, b.mapper.matchTopic) {
+		b.dropped(stats.DropMute, msg).Msg("message suppressed by mute")
+		tr.step("suppressed by mute")
+		return
+	}
+
+	if b.config.CacheJSON {
+		irc.CacheJSON(&msg)
+	}
+	b.enrich(&msg)
+
 	// Find matching mappings
-	mappings := b.mapper.Map(msg.Topic)
+	mappings := b.mapper.Map(msg.Topic, len(msg.Payload))
 
 	if len(mappings) == 0 {
-		b.logger.Debug().
-			Str("topic", msg.Topic).
-			Msg("no mapping found for topic")
+		b.dropped(stats.DropNoMapping, msg).Msg("no mapping found for topic")
+		tr.step("no active mapping matches")
 		return
 	}
+	if tr != nil {
+		for _, mapping := range mappings {
+			tr.step("matched mapping %s → %s", mapping.MQTTTopic, strings.Join(mapping.IRCChannels, ","))
+		}
+	}
 
 	b.logger.Debug().
+		Str("msg_id", msg.ID).
 		Str("topic", msg.Topic).
 		Int("mappings", len(mappings)).
 		Msg("processing message")
 
+	if b.config.Redaction.IRCOutput {
+		msg.Payload = b.redactor.Payload(msg.Payload)
+		if msg.JSONParsed {
+			irc.CacheJSON(&msg)
+		}
+	}
+
 	// Debug: log payload and JSON parsing result
 	if b.logger.GetLevel() <= zerolog.DebugLevel {
-		jsonData := irc.ParseJSON(msg.Payload)
-		ev := b.logger.Debug().
-			Str("topic", msg.Topic).
-			Str("payload", string(msg.Payload))
-		if jsonData == nil {
-			ev.Bool("json_parsed", false)
-		} else {
-			keys := make([]string, 0, len(jsonData))
-			for k := range jsonData {
-				keys = append(keys, k)
-			}
-			ev.Bool("json_parsed", true).Strs("json_keys", keys)
-		}
-		ev.Msg("message payload")
+		b.logPayload(msg)
 	}
 
-	// Send to all matched channels
+	// Send to all matched channels. Mappings are handled in config order;
+	// the channels of a mapping in bridge.channel_order.
 	for _, mapping := range mappings {
-		var formatted string
+		channels := b.order.channels(mapping.MQTTTopic, mapping.IRCChannels)
+		// prefix marks lines redirected to the overflow channel.
+		prefix := ""
+		if ok, first := b.usage.allow(mapping.Tenant); !ok {
+			overflow := b.config.OverflowChannel
+			if first {
+				action := "dropping its messages"
+				if overflow != "" {
+					action = "redirecting its messages to " + overflow
+				}
+				b.notifyOps(fmt.Sprintf("tenant %s exceeded its daily quota, %s until midnight", mapping.Tenant, action))
+			}
+			if overflow == "" {
+				b.dropped(stats.DropQuota, msg).
+					Str("tenant", mapping.Tenant).
+					Msg("message dropped: tenant daily quota exceeded")
+				tr.step("%s: dropped, tenant %s over daily quota", mapping.MQTTTopic, mapping.Tenant)
+				continue
+			}
+			b.logger.Debug().
+				Str("msg_id", msg.ID).
+				Str("tenant", mapping.Tenant).
+				Str("overflow", overflow).
+				Msg("tenant over daily quota, redirecting to overflow channel")
+			tr.step("%s: tenant %s over daily quota, redirected to %s", mapping.MQTTTopic, mapping.Tenant, overflow)
+			prefix = "[" + strings.Join(channels, ",") + "] "
+			channels = []string{overflow}
+		}
+
+		if !b.checkSchema(msg, mapping, tr) {
+			continue
+		}
 
 		// If a processor is registered for this mapping, run it first.
-		if proc, ok := b.processors[mapping.MQTTTopic]; ok {
+		if proc, ok := b.processors[mapping.Key]; ok {
 			result, err := proc.Process(msg)
 			if err != nil {
 				b.logger.Error().
 					Err(err).
+					Str("msg_id", msg.ID).
 					Str("topic", msg.Topic).
 					Str("processor", mapping.Processor).
 					Msg("processor error")
+				tr.step("%s: processor %s error: %v", mapping.MQTTTopic, mapping.Processor, err)
 			}
 			if result.Drop {
-				b.logger.Debug().
-					Str("topic", msg.Topic).
+				reason := result.Reason
+				if reason == "" {
+					reason = stats.DropProcessor
+				}
+				b.dropped(reason, msg).
+					Str("processor", mapping.Processor).
 					Msg("message dropped by processor")
+				tr.step("%s: processor %s dropped the message (%s)", mapping.MQTTTopic, mapping.Processor, reason)
 				continue
 			}
 			if result.Formatted != "" {
-				formatted = irc.SanitizeAndTruncate(
-					result.Formatted,
-					b.config.MaxMessageLength,
-					b.config.TruncateSuffix,
-				)
+				lines := irc.SplitLines(result.Formatted, mapping.MaxLines, b.limits)
+				tr.step("%s: processor %s rendered: %s", mapping.MQTTTopic, mapping.Processor, strings.Join(lines, " ⏎ "))
 				// Send pre-formatted output directly, skipping FormatMessage.
-				for _, channel := range mapping.IRCChannels {
-					if err := b.ircClient.SendMessage(ctx, channel, formatted); err != nil {
-						b.logger.Error().
-							Err(err).
-							Str("channel", channel).
-							Str("topic", msg.Topic).
-							Msg("failed to send message to IRC")
-					} else {
-						b.logger.Debug().
-							Str("channel", channel).
-							Str("topic", msg.Topic).
-							Msg("message sent to IRC")
+				for _, channel := range channels {
+					if text, ok := result.ChannelFormatted[strings.ToLower(channel)]; ok {
+						b.sendMapped(ctx, msg, mapping, channel, b.prefixed(prefix, irc.SplitLines(text, mapping.MaxLines, b.limits)), tr)
+						continue
 					}
+					b.sendMapped(ctx, msg, mapping, channel, b.prefixed(prefix, lines), tr)
 				}
 				continue
 			}
+			tr.step("%s: processor %s passed the message through", mapping.MQTTTopic, mapping.Processor)
 		}
 
 		// No processor, or processor passed through — use normal template formatting.
-		var err error
-		formatted, err = irc.FormatMessage(
-			msg,
-			mapping.MessageFormat,
-			b.config.MaxMessageLength,
-			b.config.TruncateSuffix,
-		)
-		if err != nil {
-			b.logger.Error().
-				Err(err).
-				Str("topic", msg.Topic).
-				Msg("failed to format message")
+		// Templates referencing IRC state are rendered separately for each channel.
+		if irc.UsesTarget(mapping.MessageFormat) {
+			for _, channel := range channels {
+				lines := b.format(msg, mapping.MappingConfig, b.ircClient.Target(channel))
+				tr.step("%s: rendered for %s: %s", mapping.MQTTTopic, channel, strings.Join(lines, " ⏎ "))
+				b.sendMapped(ctx, msg, mapping, channel, b.prefixed(prefix, lines), tr)
+			}
 			continue
 		}
 
+		lines := b.format(msg, mapping.MappingConfig, irc.Target{})
+		tr.step("%s: rendered: %s", mapping.MQTTTopic, strings.Join(lines, " ⏎ "))
+
 		// Send to each IRC channel
-		for _, channel := range mapping.IRCChannels {
-			if err := b.ircClient.SendMessage(ctx, channel, formatted); err != nil {
-				b.logger.Error().
-					Err(err).
-					Str("channel", channel).
-					Str("topic", msg.Topic).
-					Msg("failed to send message to IRC")
-			} else {
-				b.logger.Debug().
-					Str("channel", channel).
-					Str("topic", msg.Topic).
-					Msg("message sent to IRC")
+		for _, channel := range channels {
+			b.sendMapped(ctx, msg, mapping, channel, b.prefixed(prefix, lines), tr)
+		}
+	}
+}
+
+// format renders msg with the mapping's message_format for target: one IRC
+// line, or up to max_lines lines for multi-line mappings. Invalid templates
+// fall back to "[topic] payload".
+func (b *Bridge) format(msg types.Message, mapping config.MappingConfig, target irc.Target) []string {
+	if loc := b.zones[mapping.Timezone]; loc != nil {
+		msg.Timestamp = msg.Timestamp.In(loc)
+	}
+	return irc.SplitLines(irc.RenderMessage(msg, mapping.MessageFormat, target), mapping.MaxLines, b.limits)
+}
+
+// sendLines sends the lines of one message in order; each line goes through
+// the rate limiter (and the channel budget) on its own. mapping is the
+// mappingKey of the mapping the lines were formatted for, "" for other lines.
+func (b *Bridge) sendLines(ctx context.Context, msg types.Message, mapping, tenant, channel string, lines []string, tr *trace) {
+	for _, line := range lines {
+		b.send(ctx, msg, mapping, tenant, channel, line, tr)
+	}
+}
+
+// enrich attaches the static metadata of the message's topic and device.
+func (b *Bridge) enrich(msg *types.Message) {
+	if b.metadata == nil {
+		return
+	}
+	var device string
+	if b.metadata.HasDevices() {
+		fields := irc.MessageJSON(*msg)
+		for _, f := range b.config.Metadata.DeviceFields {
+			if device = fields[f]; device != "" {
+				break
 			}
 		}
 	}
+	msg.Meta = b.metadata.Lookup(msg.Topic, device, b.mapper.matchTopic)
+}
+
+// send delivers a formatted message to one IRC channel on behalf of tenant
+// ("" for mappings without one), through the channel's outbound queue if
+// bridge.channel_queue_size is set.
+func (b *Bridge) send(ctx context.Context, msg types.Message, mapping, tenant, channel, formatted string, tr *trace) {
+	out := outbound{msg: msg, mapping: mapping, tenant: tenant, channel: channel, text: formatted, tr: tr}
+	if b.outbox == nil {
+		b.deliver(ctx, out)
+		return
+	}
+	if !b.outbox.enqueue(out) {
+		b.droppedIn(stats.DropChannelQueue, msg, channel).
+			Msg("message dropped: channel queue full")
+		tr.step("dropped, %s queue full", channel)
+	}
+}
+
+// deliver sends one line to IRC and logs (and traces) the outcome.
+func (b *Bridge) deliver(ctx context.Context, out outbound) {
+	msg, tenant, channel, formatted, tr := out.msg, out.tenant, out.channel, out.text, out.tr
+	if b.retired.has(out.mapping) {
+		b.droppedIn(stats.DropMappingRemoved, msg, channel).
+			Msg("message dropped: mapping removed")
+		tr.step("dropped, mapping removed by a reload")
+		return
+	}
+	if b.config.Redaction.IRCOutput {
+		formatted = b.redactor.String(formatted)
+	}
+
+	target, text, summary, ok := b.budget.route(channel, formatted, b.clock.Now())
+	if !ok {
+		b.droppedIn(stats.DropChannelBudget, msg, channel).
+			Msg("message dropped: channel over budget")
+		tr.step("dropped, %s over its message budget", channel)
+		return
+	}
+	if target != channel {
+		if summary != "" {
+			b.sendOverflow(ctx, target, summary)
+		}
+		b.logger.Debug().
+			Str("msg_id", msg.ID).
+			Str("channel", channel).
+			Str("overflow", target).
+			Msg("channel over budget, redirecting to overflow channel")
+		tr.step("%s over its message budget, redirected to %s", channel, target)
+		channel, formatted = target, b.limits.Fit(text)
+	}
+
+	if !b.breakers.allow(channel, b.clock.Now()) {
+		b.droppedIn(stats.DropCircuitOpen, msg, channel).
+			Msg("message dropped: sends to channel keep failing")
+		tr.step("dropped, sends to %s keep failing", channel)
+		return
+	}
+	if !b.waitSpeak(ctx, msg, channel, tr) {
+		return
+	}
+
+	err := b.faults.failSend()
+	if err == nil && b.config.OrderedDelivery {
+		// Hold this line, and the lines queued behind it, until IRC is back.
+		err = b.ircClient.WaitConnected(ctx)
+	}
+	if err == nil {
+		formatted = b.annotateLatency(msg, channel, formatted)
+		err = b.ircClient.SendMessage(ctx, channel, formatted)
+	}
+	if err != nil {
+		now := b.clock.Now()
+		b.drops.Record(stats.DropSendFailed, msg.Topic, now)
+		b.topicStats.dropped(msg.Topic, b.mapper.matchTopic)
+		b.logEvent(msg, channel, eventlog.OutcomeDropped, stats.DropSendFailed, "", now)
+		b.logger.Error().
+			Err(err).
+			Str("msg_id", msg.ID).
+			Str("channel", channel).
+			Str("topic", msg.Topic).
+			Str("reason", string(stats.DropSendFailed)).
+			Msg("failed to send message to IRC")
+		tr.step("send to %s failed: %v", channel, err)
+		return
+	}
+	now := b.clock.Now()
+	b.usage.recordSent(tenant, channel)
+	b.topicStats.sent(msg.Topic, b.mapper.matchTopic)
+	if err := b.history.record(channel, formatted, msg.ID, msg.Topic, now); err != nil {
+		b.logger.Warn().Err(err).Msg("failed to save history")
+	}
+	b.logEvent(msg, channel, eventlog.OutcomeSent, "", formatted, now)
+	b.runSentHooks(out, channel, formatted)
+	tr.step("sent to %s", channel)
+	b.logger.Debug().
+		Str("msg_id", msg.ID).
+		Str("channel", channel).
+		Str("topic", msg.Topic).
+		Msg("message sent to IRC")
+}
+
+// waitSpeak holds a line for a channel the bot cannot speak in until it can
+// again, and reports whether to send it. Only channel outboxes hold lines, as
+// each channel has its own sender; sending inline, the line is dropped.
+func (b *Bridge) waitSpeak(ctx context.Context, msg types.Message, channel string, tr *trace) bool {
+	reason, blocked := b.ircClient.Blocked(channel)
+	if !blocked {
+		return true
+	}
+	if b.outbox != nil {
+		tr.step("cannot speak in %s (%s), holding", channel, reason)
+		if b.ircClient.WaitSpeak(ctx, channel) == nil {
+			return true
+		}
+	}
+	b.droppedIn(stats.DropChannelBlocked, msg, channel).
+		Str("blocked", reason).
+		Msg("message dropped: cannot speak in channel")
+	tr.step("dropped, cannot speak in %s (%s)", channel, reason)
+	return false
+}
+
+// onChannelBlocked tells the ops channels that the bot lost or regained its
+// voice in a channel. Regaining it closes the channel's circuit breaker.
+func (b *Bridge) onChannelBlocked(channel, reason string, blocked bool) {
+	if !blocked {
+		b.breakerSucceeded(channel)
+		b.notifyOps(fmt.Sprintf("Can speak in %s again, deliveries resumed", channel))
+		return
+	}
+	what := "dropped"
+	if b.outbox != nil {
+		what = "held"
+	}
+	b.notifyOps(fmt.Sprintf("Cannot speak in %s: %s; messages for it are %s until the bot gets voice or op", channel, reason, what))
+}
+
+// sendOverflow posts a bridge notice to the overflow channel.
+func (b *Bridge) sendOverflow(ctx context.Context, channel, message string) {
+	if err := b.ircClient.SendMessage(ctx, channel, message); err != nil {
+		b.logger.Error().Err(err).Str("channel", channel).Msg("failed to send overflow summary")
+	}
 }
 
 // Shutdown gracefully shuts down the bridge
 func (b *Bridge) Shutdown(ctx context.Context) error {
 	b.logger.Info().Msg("shutting down bridge")
 
+	// Stop MQTT delivery first: its handler sends to the queues, and a send
+	// on a closed queue panics.
+	b.mqttClient.Disconnect(5 * time.Second)
+
 	// Close message queue (no new messages)
+	b.queueMu.Lock()
+	b.queueClosed = true
 	close(b.msgQueue)
+	b.queueMu.Unlock()
 
-	// Wait for message processor to finish with timeout
+	// Wait for message processor and channel senders to finish with timeout
 	done := make(chan struct{})
 	go func() {
 		b.wg.Wait()
+		b.outbox.close()
+		b.sentHooks.wait()
 		close(done)
 	}()
 
 	select {
 	case <-done:
 		b.logger.Info().Msg("message processor stopped")
+		b.reloadMu.Lock()
+		b.flushProcessors()
+		b.reloadMu.Unlock()
+		b.saveQueueOnShutdown()
+		if err := b.history.flush(); err != nil {
+			b.logger.Error().Err(err).Msg("failed to save history")
+		}
 	case <-ctx.Done():
 		b.logger.Warn().Msg("shutdown timeout, forcing stop")
 	}
 
-	// Disconnect clients
-	b.mqttClient.Disconnect(5 * time.Second)
 	b.ircClient.Disconnect()
+	if err := b.events.Close(); err != nil {
+		b.logger.Warn().Err(err).Msg("failed to close event log")
+	}
 
 	b.logger.Info().Msg("bridge shutdown complete")
 	return nil
@@ -257,12 +960,139 @@ func (b *Bridge) Shutdown(ctx context.Context) error {
 
 // HealthStatus returns the health status of the bridge
 func (b *Bridge) HealthStatus() map[string]interface{} {
-	return map[string]interface{}{
-		"mqtt_connected": b.mqttClient.IsConnected(),
-		"irc_connected":  b.ircClient.IsConnected(),
-		"queue_size":     len(b.msgQueue),
-		"queue_capacity": cap(b.msgQueue),
+	status := map[string]interface{}{
+		"mqtt_connected":  b.mqttClient.IsConnected(),
+		"irc_connected":   b.ircClient.IsConnected(),
+		"queue_size":      len(b.msgQueue),
+		"queue_capacity":  cap(b.msgQueue),
+		"high_queue_size": len(b.highQueue) + b.spill.len(),
+		"mqtt_history":    b.mqttClient.History().Events(),
+		"irc_history":     b.ircClient.History().Events(),
+		"mqtt_flapping":   b.mqttClient.History().Flapping(),
+		"irc_flapping":    b.ircClient.History().Flapping(),
+
+		"mqtt_redeliveries_suppressed": b.mqttClient.SuppressedRedeliveries(),
+		"mqtt_storm_topics":            b.mqttClient.StormTopics(),
+		"irc_stalls":                   b.ircClient.Stalls(),
+		"irc_blocked_channels":         b.ircClient.BlockedChannels(),
+		"open_circuits":                b.breakers.open(),
+		"tenant_messages_today":        b.usage.todayCounts(),
+		"channel_messages_sent":        b.usage.channelCounts(),
+		"drops":                        b.drops.Snapshot(),
+		"admin_commands":               b.commands.Snapshot(),
+		"channel_queues":               b.outbox.lengths(),
+		"messages_per_minute":          b.received.count(b.clock.Now()),
+		"paused":                       b.paused.Load(),
+		"draining":                     b.draining.Load(),
+		"error_budget_exceeded":        b.errBudget.exceeded(),
+		"schema_violations":            b.schemaErrs.snapshot(),
+	}
+	if src := b.ConfigSource(); src != nil {
+		status["config_hash"] = src.Hash
+	}
+	if b.instance != "" {
+		status["instance"] = b.instance
 	}
+	if b.safeMode {
+		status["safe_mode"] = true
+	}
+	if b.startup != nil {
+		pending, degraded := b.startup.snapshot()
+		status["degraded"] = degraded
+		status["startup_pending"] = pending
+	}
+	if b.alerts != nil {
+		status["alertmanager_firing"] = b.alerts.tracker.Firing()
+	}
+	return status
+}
+
+// WriteMetrics writes per-tenant, per-channel, drop, admin command and
+// topic counters and channel queue lengths in the Prometheus text format
+// (implements health.MetricsProvider).
+// With instance_name set, every sample is labeled instance_name.
+func (b *Bridge) WriteMetrics(w io.Writer) error {
+	var sb strings.Builder
+	if err := b.usage.writeMetrics(&sb); err != nil {
+		return err
+	}
+	if err := writeDropMetrics(&sb, b.drops.Snapshot()); err != nil {
+		return err
+	}
+	if err := writeCommandMetrics(&sb, b.commands.Snapshot()); err != nil {
+		return err
+	}
+	if err := b.outbox.writeMetrics(&sb); err != nil {
+		return err
+	}
+	if err := b.topicStats.writeMetrics(&sb); err != nil {
+		return err
+	}
+	out := sb.String()
+	if b.instance != "" {
+		out = addMetricsLabel(out, "instance_name="+promLabel(b.instance))
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// Mappings returns the mappings and their runtime state (implements admin.BridgeAdmin).
+func (b *Bridge) Mappings() []types.MappingInfo {
+	return b.mapper.Info()
+}
+
+// PauseMapping pauses or resumes a mapping by 1-based index (implements admin.BridgeAdmin).
+func (b *Bridge) PauseMapping(index int, paused bool) error {
+	return b.mapper.SetPaused(index, paused)
+}
+
+// SetMappingFormat changes a mapping's message_format (implements admin.BridgeAdmin).
+func (b *Bridge) SetMappingFormat(index int, format string) error {
+	return b.mapper.SetFormat(index, format)
+}
+
+// AddMapping adds a mapping at runtime and returns its 1-based index
+// (implements admin.BridgeAdmin). Its topic is subscribed unless an existing
+// subscription covers it. Like runtime format changes, the mapping and its
+// subscription are dropped by a config reload.
+func (b *Bridge) AddMapping(topic string, channels []string, format string) (int, error) {
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+	index, err := b.addMapping(topic, channels, format)
+	if err == nil {
+		go b.publishCapabilities()
+	}
+	return index, err
+}
+
+// addMapping validates and adds a mapping and subscribes to its topic. The
+// caller holds reloadMu.
+func (b *Bridge) addMapping(topic string, channels []string, format string) (int, error) {
+	if !IsValidPattern(topic) {
+		return 0, fmt.Errorf("invalid topic pattern %q", topic)
+	}
+	if len(channels) == 0 {
+		return 0, fmt.Errorf("no channels")
+	}
+	for _, channel := range channels {
+		if !strings.HasPrefix(channel, "#") && !strings.HasPrefix(channel, "&") {
+			return 0, fmt.Errorf("invalid channel %q", channel)
+		}
+	}
+
+	index, err := b.mapper.Add(config.MappingConfig{MQTTTopic: topic, IRCChannels: channels, MessageFormat: format})
+	if err != nil {
+		return 0, err
+	}
+
+	topics := b.mqttClient.Topics()
+	for _, t := range topics {
+		if covers(t.Pattern, topic) {
+			return index, nil
+		}
+	}
+	b.mqttClient.SetTopics(append(topics, config.TopicConfig{Pattern: topic, QoS: b.current.MQTT.QoS}))
+	return index, nil
 }
 
 // SendMessage sends a message to an IRC channel (implements admin.BridgeAdmin).
@@ -270,6 +1100,22 @@ func (b *Bridge) SendMessage(ctx context.Context, channel, message string) error
 	return b.ircClient.SendMessage(ctx, channel, message)
 }
 
+// SendTimed sends a message built by compose once it passed the IRC rate
+// limiter, with the send path statistics at that moment (implements
+// admin.BridgeAdmin).
+func (b *Bridge) SendTimed(ctx context.Context, channel string, compose func(types.SendStats) string) error {
+	return b.ircClient.SendMessageTimed(ctx, channel, func(wait time.Duration) string {
+		return compose(types.SendStats{
+			Wait:          wait,
+			QueueSize:     len(b.msgQueue) + len(b.highQueue),
+			QueueCapacity: cap(b.msgQueue),
+			Pace:          b.ircClient.Pace(),
+			Rate:          b.ircCfg.RateLimit.MessagesPerSecond,
+			Burst:         b.ircCfg.RateLimit.Burst,
+		})
+	})
+}
+
 // NickChange changes the bot's IRC nickname (implements admin.BridgeAdmin).
 func (b *Bridge) NickChange(newnick string) {
 	b.ircClient.Nick(newnick)
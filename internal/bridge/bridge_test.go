@@ -0,0 +1,52 @@
+package bridge
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// BenchmarkMapAndFormat measures the per-message work done between the
+// queue and the IRC rate limiter: mapping lookup and template rendering.
+// Compare msgs/s against the throughput target in the README.
+func BenchmarkMapAndFormat(b *testing.B) {
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "home/+/temperature", IRCChannels: []string{"#home"}, MessageFormat: "{{.Topic}}: {{.Payload}}°C"},
+		{MQTTTopic: "sensors/env/#", IRCChannels: []string{"#sensors", "#ops"}, MessageFormat: "[{{.Topic}}] temp={{.JSON.temperature}} humidity={{.JSON.humidity}}%"},
+		{MQTTTopic: "alerts/+", IRCChannels: []string{"#alerts"}, MessageFormat: "ALERT: {{.Payload}}"},
+	}
+	bridge := &Bridge{
+		config: config.BridgeConfig{Mappings: mappings},
+		mapper: NewMapper(mappings),
+		limits: irc.Limits{MaxLength: 400, MaxBytes: 400, Suffix: "..."},
+		logger: zerolog.New(os.Stderr).Level(zerolog.Disabled),
+	}
+	msgs := make([]types.Message, 100)
+	for i := range msgs {
+		msgs[i] = types.Message{
+			Topic:     fmt.Sprintf("sensors/env/room%d", i),
+			Payload:   []byte(fmt.Sprintf(`{"temperature":%d.5,"humidity":48,"battery":97}`, 15+i%10)),
+			Timestamp: time.Now(),
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msg := msgs[i%len(msgs)]
+		irc.CacheJSON(&msg)
+		for _, mapping := range bridge.mapper.Map(msg.Topic, len(msg.Payload)) {
+			for _, channel := range mapping.IRCChannels {
+				bridge.format(msg, mapping.MappingConfig, irc.Target{Channel: irc.ChannelInfo{Name: channel}})
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "msgs/s")
+}
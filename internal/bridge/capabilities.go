@@ -0,0 +1,91 @@
+package bridge
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/transform"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// enabledFeatures returns the optional features cfg turns on, sorted. The
+// names are part of the capabilities document, so they stay stable.
+func enabledFeatures(cfg *config.Config) []string {
+	br := cfg.Bridge
+	grouping := false
+	for _, m := range br.Mappings {
+		grouping = grouping || m.Group.Key != ""
+	}
+	enabled := map[string]bool{
+		"acks":             br.AckTopic != "",
+		"admin":            cfg.Admin.Enabled,
+		"alertmanager":     br.Alertmanager.URL != "",
+		"backfill":         br.HistorySize > 0,
+		"banner":           len(br.Banner.Channels) > 0,
+		"channel_rate":     br.ChannelRate.MessagesPerMinute > 0,
+		"circuit_breaker":  br.CircuitBreaker.Failures > 0,
+		"error_budget":     br.ErrorBudget.Threshold > 0,
+		"grouping":         grouping,
+		"health":           cfg.Health.Enabled,
+		"heartbeats":       len(br.Heartbeats) > 0,
+		"home_assistant":   br.HomeAssistant.Discovery,
+		"latency":          br.Latency.Threshold > 0,
+		"metadata":         br.Metadata.File != "",
+		"ordered_delivery": br.OrderedDelivery,
+		"public_commands":  cfg.Admin.Enabled && len(cfg.Admin.PublicCommands) > 0,
+		"remote_mappings":  br.RemoteMappings.URL != "" || br.RemoteMappings.MQTTTopic != "",
+		"sent_hook":        len(br.SentHook.Command) > 0,
+		"stats_publish":    br.StatsPublish.Topic != "",
+		"topic_stats":      br.TopicStats.Enabled,
+	}
+	features := make([]string, 0, len(enabled))
+	for name, on := range enabled {
+		if on {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
+
+// capabilities builds the capabilities document. The caller holds reloadMu.
+func (b *Bridge) capabilities() types.Capabilities {
+	return types.Capabilities{
+		Schema:     types.CapabilitiesSchema,
+		Version:    b.version,
+		Instance:   b.instance,
+		Features:   enabledFeatures(b.current),
+		Mappings:   len(b.mapper.Info()),
+		Processors: List(),
+		Transforms: transform.List(),
+	}
+}
+
+// Capabilities returns what this instance supports (implements
+// admin.BridgeAdmin).
+func (b *Bridge) Capabilities() types.Capabilities {
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+	return b.capabilities()
+}
+
+// publishCapabilities publishes the capabilities document (retained) to
+// bridge.capabilities_topic, if set. It is called at startup and after the
+// mappings change, so the retained copy stays current.
+func (b *Bridge) publishCapabilities() {
+	topic := b.config.CapabilitiesTopic
+	if topic == "" {
+		return
+	}
+	payload, err := json.Marshal(b.Capabilities())
+	if err != nil {
+		b.logger.Error().Err(err).Msg("failed to encode capabilities")
+		return
+	}
+	if err := b.mqttClient.Publish(topic, 1, true, payload); err != nil {
+		b.logger.Warn().Err(err).Str("topic", topic).Msg("failed to publish capabilities")
+		return
+	}
+	b.logger.Debug().Str("topic", topic).Msg("capabilities published")
+}
@@ -0,0 +1,37 @@
+package bridge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestCapabilities(t *testing.T) {
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "a/#", IRCChannels: []string{"#a"}},
+		{MQTTTopic: "msh/#", IRCChannels: []string{"#mesh"}, Group: config.GroupConfig{Key: "{{.JSON.from}}"}},
+	}
+	cfg := &config.Config{
+		Admin:  config.AdminConfig{Enabled: true, PublicCommands: []string{"bridge"}},
+		Bridge: config.BridgeConfig{Mappings: mappings, AckTopic: "alarms/ack", HistorySize: 50},
+	}
+	b := &Bridge{mapper: NewMapper(mappings), current: cfg, version: "1.2.3"}
+
+	caps := b.Capabilities()
+	want := []string{"acks", "admin", "backfill", "grouping", "public_commands"}
+	if !reflect.DeepEqual(caps.Features, want) {
+		t.Errorf("Features = %v, want %v", caps.Features, want)
+	}
+	if caps.Schema != types.CapabilitiesSchema || caps.Version != "1.2.3" || caps.Mappings != 2 {
+		t.Errorf("Capabilities() = %+v", caps)
+	}
+	if len(caps.Transforms) == 0 {
+		t.Error("no transforms listed")
+	}
+
+	if features := enabledFeatures(&config.Config{}); len(features) != 0 {
+		t.Errorf("features of an empty config = %v", features)
+	}
+}
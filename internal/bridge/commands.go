@@ -0,0 +1,35 @@
+package bridge
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// CommandCounter returns the admin command counter, shown on /health, in the
+// stats report and on /metrics. The admin handler records into it.
+func (b *Bridge) CommandCounter() *stats.Commands {
+	return b.commands
+}
+
+// writeCommandMetrics writes the admin command counters in the Prometheus
+// text format.
+func writeCommandMetrics(w io.Writer, counts []types.CommandCount) error {
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Nick < counts[j].Nick })
+
+	var sb strings.Builder
+	sb.WriteString("# HELP mqtt2irc_admin_commands_total Admin commands by nick and result (ok, failed or unauthorized).\n")
+	sb.WriteString("# TYPE mqtt2irc_admin_commands_total counter\n")
+	for _, c := range counts {
+		nick := promLabel(c.Nick)
+		fmt.Fprintf(&sb, "mqtt2irc_admin_commands_total{nick=%s,result=\"ok\"} %d\n", nick, c.Commands-min(c.Failures, c.Commands))
+		fmt.Fprintf(&sb, "mqtt2irc_admin_commands_total{nick=%s,result=\"failed\"} %d\n", nick, c.Failures)
+		fmt.Fprintf(&sb, "mqtt2irc_admin_commands_total{nick=%s,result=\"unauthorized\"} %d\n", nick, c.Unauthorized)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
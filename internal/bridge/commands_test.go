@@ -0,0 +1,32 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestWriteCommandMetrics(t *testing.T) {
+	var sb strings.Builder
+	counts := []types.CommandCount{
+		{Nick: "mallory", Unauthorized: 4},
+		{Nick: "alice", Commands: 5, Failures: 2},
+	}
+	if err := writeCommandMetrics(&sb, counts); err != nil {
+		t.Fatalf("writeCommandMetrics: %v", err)
+	}
+	out := sb.String()
+	for _, want := range []string{
+		`mqtt2irc_admin_commands_total{nick="alice",result="ok"} 3`,
+		`mqtt2irc_admin_commands_total{nick="alice",result="failed"} 2`,
+		`mqtt2irc_admin_commands_total{nick="mallory",result="unauthorized"} 4`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, `nick="alice"`) > strings.Index(out, `nick="mallory"`) {
+		t.Errorf("nicks not sorted:\n%s", out)
+	}
+}
@@ -0,0 +1,92 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+// connector is one side of the bridge to connect at startup.
+type connector struct {
+	name    string // "MQTT" or "IRC", for errors
+	connect func(ctx context.Context) error
+}
+
+// connectAll runs the connectors in parallel and returns once all are
+// connected, so a slow or unreachable MQTT broker does not hold up IRC
+// presence and admin commands, and DNS lookups and TLS handshakes of both
+// sides overlap. The first failure cancels the other attempts through
+// cancel, which must cancel ctx, and is returned. The clients keep ctx for
+// their connection, so cancel must not be called once they are up.
+func connectAll(ctx context.Context, cancel context.CancelFunc, connectors ...connector) error {
+	errs := make(chan error, len(connectors))
+	for _, c := range connectors {
+		go func() {
+			if err := c.connect(ctx); err != nil {
+				errs <- fmt.Errorf("failed to connect to %s: %w", c.name, err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+	var first error
+	for range connectors {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			cancel()
+		}
+	}
+	return first
+}
+
+// maxConnectBackoff caps the delay between connect attempts.
+const maxConnectBackoff = time.Minute
+
+// connectors returns the connectors of both sides. With startup.retry
+// enabled, each retries its own connection with backoff; without it, the
+// first failure is final.
+func (b *Bridge) connectors(retry config.StartupRetryConfig) []connector {
+	if !retry.Enabled {
+		return []connector{
+			{name: "MQTT", connect: b.mqttClient.Connect},
+			{name: "IRC", connect: b.ircClient.Connect},
+		}
+	}
+	return []connector{
+		{name: "MQTT", connect: func(ctx context.Context) error {
+			return b.retryConnect(ctx, "MQTT", retry.MaxAttempts, retry.Backoff, b.mqttClient.Connect)
+		}},
+		{name: "IRC", connect: func(ctx context.Context) error {
+			return b.ircClient.ConnectRetry(ctx, retry.MaxAttempts, retry.Backoff, func(attempt int, err error) {
+				b.logger.Warn().Err(err).Str("connection", "IRC").Int("attempt", attempt).Msg("connect failed, retrying")
+			})
+		}},
+	}
+}
+
+// retryConnect calls connect until it succeeds, attempts calls failed (0 =
+// no limit) or ctx is cancelled. It waits backoff (default 1s) after the
+// first failure, doubled after every further one up to maxConnectBackoff.
+func (b *Bridge) retryConnect(ctx context.Context, name string, attempts int, backoff time.Duration, connect func(context.Context) error) error {
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for attempt := 1; ; attempt++ {
+		err := connect(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if attempts > 0 && attempt >= attempts {
+			return fmt.Errorf("after %d attempts: %w", attempt, err)
+		}
+		b.logger.Warn().Err(err).Str("connection", name).Int("attempt", attempt).Dur("retry_in", backoff).Msg("connect failed, retrying")
+		select {
+		case <-b.clock.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+}
@@ -0,0 +1,91 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+func TestConnectAll(t *testing.T) {
+	// Both sides connect at the same time: neither waits for the other.
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	wait := func(name string) connector {
+		return connector{name: name, connect: func(ctx context.Context) error {
+			started <- name
+			<-release
+			return nil
+		}}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- connectAll(ctx, cancel, wait("MQTT"), wait("IRC")) }()
+	for range 2 {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("connects did not start in parallel")
+		}
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("connectAll = %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("context cancelled after a successful connect")
+	}
+
+	// A failure abandons the other side's attempt.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	slow := connector{name: "MQTT", connect: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	failing := connector{name: "IRC", connect: func(context.Context) error { return errors.New("no route") }}
+	err := connectAll(ctx, cancel, slow, failing)
+	if err == nil || !strings.Contains(err.Error(), "failed to connect to IRC: no route") {
+		t.Errorf("connectAll = %v, want the IRC error", err)
+	}
+}
+
+func TestRetryConnect(t *testing.T) {
+	b := &Bridge{clock: schedule.Real, logger: zerolog.New(os.Stderr).Level(zerolog.Disabled)}
+	calls := 0
+	flaky := func(context.Context) error {
+		if calls++; calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	if err := b.retryConnect(context.Background(), "MQTT", 0, time.Millisecond, flaky); err != nil || calls != 3 {
+		t.Errorf("retryConnect = %v after %d calls, want success after 3", err, calls)
+	}
+
+	// max_attempts bounds the calls.
+	calls = 0
+	down := func(context.Context) error {
+		calls++
+		return errors.New("connection refused")
+	}
+	err := b.retryConnect(context.Background(), "MQTT", 2, time.Millisecond, down)
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") || calls != 2 {
+		t.Errorf("retryConnect = %v after %d calls, want failure after 2", err, calls)
+	}
+
+	// Cancelling stops the retries.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	if err := b.retryConnect(ctx, "MQTT", 0, time.Hour, down); err == nil || calls != 1 {
+		t.Errorf("retryConnect = %v after %d calls, want failure after 1", err, calls)
+	}
+}
@@ -0,0 +1,124 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Degraded startup (startup.degraded): instead of exiting when MQTT or IRC
+// cannot be reached at startup, the bridge starts with what it has and keeps
+// connecting the rest in the background. MQTT messages wait in the queue
+// until IRC is up; without MQTT the bridge idles, answering admin commands.
+
+// degradedGrace is how long the sides may take to connect before the bridge
+// reports running degraded, the IRC connect timeout of one server.
+const degradedGrace = 30 * time.Second
+
+// startupState tracks the sides still connecting in degraded startup. A nil
+// *startupState has nothing pending.
+type startupState struct {
+	mu       sync.Mutex
+	pending  []string // "MQTT", "IRC"
+	degraded bool     // the grace period passed with sides pending
+}
+
+func newStartupState(names ...string) *startupState {
+	return &startupState{pending: names}
+}
+
+// connected marks a side as up. It returns the sides still pending and
+// whether the bridge had been reported degraded.
+func (s *startupState) connected(name string) (pending []string, wasDegraded bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, p := range s.pending {
+		if p == name {
+			s.pending = append(s.pending[:i:i], s.pending[i+1:]...)
+			break
+		}
+	}
+	wasDegraded = s.degraded
+	if len(s.pending) == 0 {
+		s.degraded = false
+	}
+	return append([]string(nil), s.pending...), wasDegraded
+}
+
+// expire ends the grace period: with sides still pending the bridge is
+// degraded from now on. It returns the pending sides.
+func (s *startupState) expire() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.degraded = len(s.pending) > 0
+	return append([]string(nil), s.pending...)
+}
+
+// snapshot returns the pending sides and whether the bridge is degraded.
+func (s *startupState) snapshot() (pending []string, degraded bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.pending...), s.degraded
+}
+
+// startDegraded starts both sides without waiting for them. It fails only
+// if the IRC client cannot start at all (ident setup).
+func (b *Bridge) startDegraded(ctx context.Context) error {
+	if err := b.ircClient.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start IRC client: %w", err)
+	}
+
+	// MQTT is retried without a limit, with the startup.retry backoff.
+	backoff := b.current.Startup.Retry.Backoff
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		if b.retryConnect(ctx, "MQTT", 0, backoff, b.mqttClient.Connect) == nil {
+			b.sideConnected("MQTT")
+			b.announce()
+		}
+	}()
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		select {
+		case <-b.ircClient.Ready():
+			b.sideConnected("IRC")
+		case <-ctx.Done():
+		}
+	}()
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		select {
+		case <-b.clock.After(degradedGrace):
+		case <-ctx.Done():
+			return
+		}
+		pending := b.startup.expire()
+		if len(pending) == 0 {
+			return
+		}
+		b.logger.Warn().Strs("waiting_for", pending).Msg("running degraded, still connecting")
+		b.notifyOps(fmt.Sprintf("Running degraded: %s unavailable, still connecting", strings.Join(pending, " and ")))
+	}()
+	return nil
+}
+
+// sideConnected records that a side came up in degraded startup, and tells
+// the ops channels once the bridge is no longer degraded.
+func (b *Bridge) sideConnected(name string) {
+	pending, wasDegraded := b.startup.connected(name)
+	if len(pending) > 0 || !wasDegraded {
+		return
+	}
+	b.logger.Info().Str("connection", name).Msg("connected, leaving degraded mode")
+	b.notifyOps(fmt.Sprintf("%s connected, leaving degraded mode (%d messages queued)", name, len(b.msgQueue)))
+}
@@ -0,0 +1,44 @@
+package bridge
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStartupState(t *testing.T) {
+	// Both sides connect within the grace period: never degraded.
+	s := newStartupState("MQTT", "IRC")
+	if pending, was := s.connected("MQTT"); !reflect.DeepEqual(pending, []string{"IRC"}) || was {
+		t.Errorf("connected(MQTT) = %v, %v", pending, was)
+	}
+	if pending, was := s.connected("IRC"); len(pending) != 0 || was {
+		t.Errorf("connected(IRC) = %v, %v", pending, was)
+	}
+	if pending := s.expire(); len(pending) != 0 {
+		t.Errorf("expire() = %v, want nothing pending", pending)
+	}
+	if _, degraded := s.snapshot(); degraded {
+		t.Error("degraded with both sides connected")
+	}
+
+	// IRC is still missing when the grace period ends.
+	s = newStartupState("MQTT", "IRC")
+	s.connected("MQTT")
+	if pending := s.expire(); !reflect.DeepEqual(pending, []string{"IRC"}) {
+		t.Errorf("expire() = %v, want [IRC]", pending)
+	}
+	if pending, degraded := s.snapshot(); !degraded || !reflect.DeepEqual(pending, []string{"IRC"}) {
+		t.Errorf("snapshot() = %v, %v, want [IRC], degraded", pending, degraded)
+	}
+	if pending, was := s.connected("IRC"); len(pending) != 0 || !was {
+		t.Errorf("connected(IRC) = %v, %v, want leaving degraded mode", pending, was)
+	}
+	if pending, degraded := s.snapshot(); degraded || len(pending) != 0 {
+		t.Errorf("snapshot() = %v, %v after both connected", pending, degraded)
+	}
+
+	var none *startupState
+	if pending, degraded := none.snapshot(); pending != nil || degraded {
+		t.Error("nil startupState reports pending sides")
+	}
+}
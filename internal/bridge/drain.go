@@ -0,0 +1,52 @@
+package bridge
+
+import (
+	"context"
+	"time"
+)
+
+// drainPoll is how often Drain checks whether the queues are empty.
+const drainPoll = 100 * time.Millisecond
+
+// Drain prepares for termination: /ready reports 503 from now on, MQTT
+// delivery stops, and the messages already queued are sent. It returns once
+// the queues are empty and grouped messages sent, or ctx is done; the bridge
+// keeps running until its Run context is cancelled. A drain is terminal:
+// MQTT stays disconnected and /ready stays 503 until the process restarts.
+// Calling it again only waits.
+func (b *Bridge) Drain(ctx context.Context) error {
+	if !b.draining.Swap(true) {
+		b.logger.Info().Int("queued", len(b.msgQueue)+len(b.highQueue)).Msg("draining: not ready, stopping MQTT delivery")
+		b.mqttClient.Disconnect(5 * time.Second)
+	}
+
+	for {
+		// A drain op is picked up only after the previous batch was handled,
+		// so a result of 0 means the processor has nothing left.
+		n, err := b.queueOpContext(ctx, queueOp{})
+		if err == nil && n == 0 && b.outboxQueued() == 0 && b.groups.waiting() == 0 {
+			b.logger.Info().Msg("drained")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			b.logger.Warn().Int("queued", len(b.msgQueue)+len(b.highQueue)+b.outboxQueued()).Msg("drain timeout")
+			return ctx.Err()
+		case <-b.clock.After(drainPoll):
+		}
+	}
+}
+
+// Draining reports whether Drain was called (implements health.DrainProvider).
+func (b *Bridge) Draining() bool {
+	return b.draining.Load()
+}
+
+// outboxQueued returns the number of lines in the per-channel outboxes.
+func (b *Bridge) outboxQueued() int {
+	n := 0
+	for _, l := range b.outbox.lengths() {
+		n += l
+	}
+	return n
+}
@@ -0,0 +1,43 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestDrain(t *testing.T) {
+	b := newReloadTestBridge(t, reloadTestConfig())
+	b.clock = schedule.Real
+	b.msgQueue = make(chan types.Message, 10)
+	for i := 0; i < 5; i++ {
+		b.msgQueue <- types.Message{Topic: "unmapped/x"}
+	}
+
+	// Without a message processor the drain times out.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := b.Drain(ctx); err == nil {
+		t.Fatal("Drain without a message processor succeeded")
+	}
+	if !b.Draining() {
+		t.Error("not reported as draining")
+	}
+
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	b.wg.Add(1)
+	go b.processMessages(runCtx)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if len(b.msgQueue) != 0 {
+		t.Errorf("%d messages left after drain", len(b.msgQueue))
+	}
+}
@@ -0,0 +1,81 @@
+package bridge
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/eventlog"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// dropped counts msg as dropped for reason and returns a debug log event
+// carrying the message ID, topic and reason; the caller adds details and
+// sends it.
+func (b *Bridge) dropped(reason stats.DropReason, msg types.Message) *zerolog.Event {
+	return b.droppedIn(reason, msg, "")
+}
+
+// droppedIn is dropped for a message dropped on its way to channel.
+func (b *Bridge) droppedIn(reason stats.DropReason, msg types.Message, channel string) *zerolog.Event {
+	now := b.clock.Now()
+	b.drops.Record(reason, msg.Topic, now)
+	b.topicStats.dropped(msg.Topic, b.mapper.matchTopic)
+	b.logEvent(msg, channel, eventlog.OutcomeDropped, reason, "", now)
+	ev := b.logger.Debug().
+		Str("msg_id", msg.ID).
+		Str("topic", msg.Topic).
+		Str("reason", string(reason))
+	if channel != "" {
+		ev.Str("channel", channel)
+	}
+	return ev
+}
+
+// logEvent writes a message outcome to the event log, if there is one.
+func (b *Bridge) logEvent(msg types.Message, channel, outcome string, reason stats.DropReason, text string, now time.Time) {
+	if b.events == nil {
+		return
+	}
+	e := eventlog.Event{
+		Time:         now,
+		MsgID:        msg.ID,
+		Topic:        msg.Topic,
+		Channel:      channel,
+		Outcome:      outcome,
+		Reason:       string(reason),
+		PayloadBytes: len(msg.Payload),
+		TextBytes:    len(text),
+	}
+	if !msg.Timestamp.IsZero() {
+		e.LatencyMS = float64(now.Sub(msg.Timestamp).Microseconds()) / 1000
+	}
+	if err := b.events.Write(e); err != nil {
+		b.logger.Debug().Err(err).Msg("failed to write event log")
+	}
+}
+
+// Drops returns the dropped message counts by reason, most frequent first
+// (implements admin.BridgeAdmin).
+func (b *Bridge) Drops() []types.DropCount {
+	return b.drops.Snapshot()
+}
+
+// writeDropMetrics writes the drop counters in the Prometheus text format.
+func writeDropMetrics(w io.Writer, drops []types.DropCount) error {
+	sort.Slice(drops, func(i, j int) bool { return drops[i].Reason < drops[j].Reason })
+
+	var sb strings.Builder
+	sb.WriteString("# HELP mqtt2irc_messages_dropped_total Messages that never reached IRC, by reason.\n")
+	sb.WriteString("# TYPE mqtt2irc_messages_dropped_total counter\n")
+	for _, d := range drops {
+		fmt.Fprintf(&sb, "mqtt2irc_messages_dropped_total{reason=%s} %d\n", promLabel(d.Reason), d.Count)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,67 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/eventlog"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestWriteDropMetrics(t *testing.T) {
+	var sb strings.Builder
+	drops := []types.DropCount{
+		{Reason: "queue_full", Count: 2},
+		{Reason: "dedup", Count: 5},
+	}
+	if err := writeDropMetrics(&sb, drops); err != nil {
+		t.Fatalf("writeDropMetrics: %v", err)
+	}
+	out := sb.String()
+	dedup := strings.Index(out, `mqtt2irc_messages_dropped_total{reason="dedup"} 5`)
+	full := strings.Index(out, `mqtt2irc_messages_dropped_total{reason="queue_full"} 2`)
+	if dedup < 0 || full < 0 {
+		t.Fatalf("missing drop counters in:\n%s", out)
+	}
+	if dedup > full {
+		t.Errorf("reasons not sorted:\n%s", out)
+	}
+}
+
+func TestDropped_LogsMessageID(t *testing.T) {
+	var sb strings.Builder
+	b := newReloadTestBridge(t, reloadTestConfig())
+	b.clock = schedule.Real
+	b.logger = zerolog.New(&sb)
+
+	b.handleMessage(context.Background(), types.Message{ID: "0000abcd", Topic: "unmapped/x"})
+	if !strings.Contains(sb.String(), `"msg_id":"0000abcd"`) {
+		t.Errorf("drop log lacks the message ID:\n%s", sb.String())
+	}
+}
+
+func TestDropped_EventLog(t *testing.T) {
+	var buf bytes.Buffer
+	b := newReloadTestBridge(t, reloadTestConfig())
+	clock := schedule.NewFake(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	b.clock = clock
+	b.events = eventlog.New(&buf)
+
+	msg := types.Message{ID: "0000abcd", Topic: "unmapped/x", Payload: []byte("hello"), Timestamp: clock.Now().Add(-250 * time.Millisecond)}
+	b.handleMessage(context.Background(), msg)
+	b.droppedIn(stats.DropChannelQueue, msg, "#a").Msg("dropped")
+
+	want := `{"time":"2026-01-01T12:00:00Z","msg_id":"0000abcd","topic":"unmapped/x","outcome":"dropped","reason":"no_mapping","payload_bytes":5,"latency_ms":250}
+{"time":"2026-01-01T12:00:00Z","msg_id":"0000abcd","topic":"unmapped/x","channel":"#a","outcome":"dropped","reason":"channel_queue","payload_bytes":5,"latency_ms":250}
+`
+	if buf.String() != want {
+		t.Errorf("event log =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
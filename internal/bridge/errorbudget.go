@@ -0,0 +1,145 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// errorBudgetChecks is how often per window the error budget is checked.
+const errorBudgetChecks = 10
+
+// errorBudget watches the share of messages lost to errors (the drop reasons
+// in bridge.error_budget.reasons) over a rolling window. It reports once
+// when the share reaches the threshold and once when it falls below again.
+type errorBudget struct {
+	cfg     config.ErrorBudgetConfig
+	reasons map[string]bool
+
+	mu      sync.Mutex
+	samples []budgetSample // oldest first; the first is the window's baseline
+	firing  bool
+}
+
+// budgetSample holds the cumulative counters at one check.
+type budgetSample struct {
+	at       time.Time
+	messages uint64            // received
+	errors   map[string]uint64 // by drop reason
+}
+
+// newErrorBudget returns nil unless bridge.error_budget.threshold is set.
+func newErrorBudget(cfg config.ErrorBudgetConfig) *errorBudget {
+	if cfg.Threshold <= 0 {
+		return nil
+	}
+	reasons := make(map[string]bool, len(cfg.Reasons))
+	for _, r := range cfg.Reasons {
+		reasons[r] = true
+	}
+	return &errorBudget{cfg: cfg, reasons: reasons}
+}
+
+// observe records the cumulative message count and drop counts at now and
+// returns the notification to post if the budget state changed, else "".
+func (e *errorBudget) observe(now time.Time, messages uint64, drops []types.DropCount) string {
+	cur := budgetSample{at: now, messages: messages, errors: make(map[string]uint64)}
+	for _, d := range drops {
+		if e.reasons[d.Reason] {
+			cur.errors[d.Reason] = d.Count
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.samples = append(e.samples, cur)
+	// The baseline is the newest sample at least a window old.
+	for len(e.samples) > 1 && !e.samples[1].at.After(now.Add(-e.cfg.Window)) {
+		e.samples = e.samples[1:]
+	}
+	base := e.samples[0]
+
+	total := cur.messages - base.messages
+	if total < uint64(e.cfg.MinMessages) {
+		return ""
+	}
+	var failed uint64
+	var parts []string
+	for _, reason := range sortedKeys(cur.errors) {
+		if n := cur.errors[reason] - base.errors[reason]; n > 0 {
+			failed += n
+			parts = append(parts, fmt.Sprintf("%s %d", reason, n))
+		}
+	}
+	share := float64(failed) / float64(total)
+
+	switch {
+	case !e.firing && share >= e.cfg.Threshold:
+		e.firing = true
+		return fmt.Sprintf("Error budget exceeded: %.0f%% of %d messages failed in the last %s (%s)",
+			share*100, total, e.cfg.Window, strings.Join(parts, ", "))
+	case e.firing && share < e.cfg.Threshold:
+		e.firing = false
+		return fmt.Sprintf("Error budget recovered: %.0f%% of %d messages failed in the last %s",
+			share*100, total, e.cfg.Window)
+	}
+	return ""
+}
+
+// exceeded reports whether the budget is currently exceeded.
+func (e *errorBudget) exceeded() bool {
+	if e == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.firing
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkErrorBudget feeds the current counters to the error budget. Messages
+// dropped before reaching the queue count as received too.
+func (b *Bridge) checkErrorBudget() {
+	drops := b.drops.Snapshot()
+	messages := b.handled.Load()
+	for _, d := range drops {
+		if d.Reason == string(stats.DropQueueFull) || d.Reason == string(stats.DropLowPriority) {
+			messages += d.Count
+		}
+	}
+	if msg := b.errBudget.observe(b.clock.Now(), messages, drops); msg != "" {
+		b.logger.Warn().Msg(msg)
+		b.notifyOps(msg)
+	}
+}
+
+// runErrorBudget checks the error budget errorBudgetChecks times per window.
+func (b *Bridge) runErrorBudget(ctx context.Context) {
+	defer b.wg.Done()
+
+	interval := b.errBudget.cfg.Window / errorBudgetChecks
+	b.checkErrorBudget()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.clock.After(interval):
+			b.checkErrorBudget()
+		}
+	}
+}
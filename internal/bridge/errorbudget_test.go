@@ -0,0 +1,67 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestErrorBudget(t *testing.T) {
+	e := newErrorBudget(config.ErrorBudgetConfig{
+		Threshold:   0.25,
+		Window:      10 * time.Minute,
+		MinMessages: 20,
+		Reasons:     []string{"queue_full", "send_failed"},
+	})
+	base := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	drops := func(queueFull, sendFailed, dedup uint64) []types.DropCount {
+		return []types.DropCount{
+			{Reason: "queue_full", Count: queueFull},
+			{Reason: "send_failed", Count: sendFailed},
+			{Reason: "dedup", Count: dedup},
+		}
+	}
+
+	if msg := e.observe(base, 0, nil); msg != "" {
+		t.Errorf("first sample: %q", msg)
+	}
+	// Too few messages to judge, even if all failed.
+	if msg := e.observe(base.Add(time.Minute), 10, drops(10, 0, 0)); msg != "" {
+		t.Errorf("below min_messages: %q", msg)
+	}
+	// Dedup drops are not errors.
+	if msg := e.observe(base.Add(2*time.Minute), 100, drops(10, 0, 80)); msg != "" {
+		t.Errorf("under threshold: %q", msg)
+	}
+	msg := e.observe(base.Add(3*time.Minute), 200, drops(40, 20, 80))
+	if !strings.HasPrefix(msg, "Error budget exceeded: 30% of 200 messages") || !strings.Contains(msg, "queue_full 40, send_failed 20") {
+		t.Errorf("alert = %q", msg)
+	}
+	if !e.exceeded() {
+		t.Error("not reported as exceeded")
+	}
+	// Still exceeded: no repeated alert.
+	if msg := e.observe(base.Add(4*time.Minute), 300, drops(80, 20, 80)); msg != "" {
+		t.Errorf("repeated alert: %q", msg)
+	}
+
+	// Once the bad minutes leave the window, the budget recovers.
+	if msg := e.observe(base.Add(14*time.Minute), 1300, drops(80, 21, 80)); !strings.HasPrefix(msg, "Error budget recovered") {
+		t.Errorf("recovery = %q", msg)
+	}
+	if e.exceeded() {
+		t.Error("still reported as exceeded")
+	}
+	if len(e.samples) > 3 {
+		t.Errorf("%d samples kept, want old ones dropped", len(e.samples))
+	}
+}
+
+func TestErrorBudget_Disabled(t *testing.T) {
+	if e := newErrorBudget(config.ErrorBudgetConfig{}); e != nil || e.exceeded() {
+		t.Error("error budget enabled without a threshold")
+	}
+}
@@ -0,0 +1,75 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// errFaultInjected fails IRC sends dropped by bridge.faults.irc_send_drop.
+var errFaultInjected = errors.New("fault injected")
+
+// faults injects the failures of bridge.faults. A nil *faults injects none.
+type faults struct {
+	cfg        config.FaultConfig
+	disconnect *schedule.Cron // nil unless irc_disconnect is set
+	random     func() float64
+}
+
+// newFaults returns nil unless bridge.faults injects anything.
+func newFaults(cfg config.FaultConfig, loc *time.Location) (*faults, error) {
+	if cfg.IRCSendDrop == 0 && cfg.MQTTDelay == 0 && cfg.IRCDisconnect == "" {
+		return nil, nil
+	}
+	f := &faults{cfg: cfg, random: rand.Float64}
+	if cfg.IRCDisconnect != "" {
+		cron, err := schedule.ParseCron(cfg.IRCDisconnect, loc)
+		if err != nil {
+			return nil, err
+		}
+		f.disconnect = cron
+	}
+	return f, nil
+}
+
+// failSend returns errFaultInjected for the share of sends to fail.
+func (f *faults) failSend() error {
+	if f == nil || f.cfg.IRCSendDrop == 0 || f.random() >= f.cfg.IRCSendDrop {
+		return nil
+	}
+	return errFaultInjected
+}
+
+// delay waits mqtt_delay before a received message is handled.
+func (f *faults) delay(ctx context.Context, clock schedule.Clock) {
+	if f == nil || f.cfg.MQTTDelay == 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-clock.After(f.cfg.MQTTDelay):
+	}
+}
+
+// runFaults forces IRC reconnects on the irc_disconnect schedule.
+func (b *Bridge) runFaults(ctx context.Context) {
+	defer b.wg.Done()
+
+	for {
+		next := b.faults.disconnect.Next(b.clock.Now())
+		if next.IsZero() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.clock.After(next.Sub(b.clock.Now())):
+			b.logger.Warn().Msg("fault injection: forcing IRC reconnect")
+			b.ircClient.Reconnect()
+		}
+	}
+}
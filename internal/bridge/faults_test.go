@@ -0,0 +1,60 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+func TestFaults(t *testing.T) {
+	if f, err := newFaults(config.FaultConfig{}, time.UTC); f != nil || err != nil {
+		t.Fatalf("newFaults without faults = %v, %v", f, err)
+	}
+	var none *faults
+	if none.failSend() != nil {
+		t.Error("nil faults failed a send")
+	}
+	none.delay(context.Background(), schedule.Real)
+
+	f, err := newFaults(config.FaultConfig{IRCSendDrop: 0.25, IRCDisconnect: "*/15 * * * *"}, time.UTC)
+	if err != nil {
+		t.Fatalf("newFaults: %v", err)
+	}
+	failed := 0
+	for _, r := range []float64{0, 0.1, 0.24, 0.25, 0.5, 0.99} {
+		f.random = func() float64 { return r }
+		if f.failSend() == errFaultInjected {
+			failed++
+		}
+	}
+	if failed != 3 {
+		t.Errorf("%d of 6 sends failed, want 3", failed)
+	}
+	now := time.Date(2026, 1, 1, 12, 5, 0, 0, time.UTC)
+	if next := f.disconnect.Next(now); !next.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("next forced disconnect at %v", next)
+	}
+
+	if _, err := newFaults(config.FaultConfig{IRCDisconnect: "sometimes"}, time.UTC); err == nil {
+		t.Error("invalid irc_disconnect: want error")
+	}
+}
+
+func TestFaults_DelayStopsWithContext(t *testing.T) {
+	f, _ := newFaults(config.FaultConfig{MQTTDelay: time.Hour}, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		f.delay(ctx, schedule.Real)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("delay ignored the cancelled context")
+	}
+}
@@ -0,0 +1,168 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+const (
+	maxSampleTopics  = 256  // topics whose last payload is kept for !fields
+	maxSampleBytes   = 8192 // larger payloads are not kept
+	maxFieldsSamples = 20   // most recent matching topics inspected by Fields
+	maxFieldDepth    = 4    // levels of nested objects listed
+	maxFieldExample  = 40   // characters of an example value
+)
+
+type payloadSample struct {
+	at      time.Time
+	payload []byte
+}
+
+// payloadSamples keeps the last payload of recently seen topics, so !fields
+// can list their fields. A nil *payloadSamples records nothing.
+type payloadSamples struct {
+	mu     sync.Mutex
+	topics map[string]payloadSample
+}
+
+func newPayloadSamples() *payloadSamples {
+	return &payloadSamples{topics: make(map[string]payloadSample)}
+}
+
+// record keeps payload as topic's sample, evicting the least recently seen
+// topic when full. The payload is not copied; messages are not modified
+// after they are received.
+func (s *payloadSamples) record(topic string, payload []byte, now time.Time) {
+	if s == nil || len(payload) > maxSampleBytes {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.topics[topic]; !ok && len(s.topics) >= maxSampleTopics {
+		oldest := ""
+		for t, p := range s.topics {
+			if oldest == "" || p.at.Before(s.topics[oldest].at) {
+				oldest = t
+			}
+		}
+		delete(s.topics, oldest)
+	}
+	s.topics[topic] = payloadSample{at: now, payload: payload}
+}
+
+// matching returns the samples of topics matching pattern, most recent first.
+func (s *payloadSamples) matching(pattern string, match func(topic, pattern string) bool) []payloadSample {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []payloadSample
+	for topic, p := range s.topics {
+		if match(topic, pattern) {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].at.After(out[j].at) })
+	return out
+}
+
+// purge forgets the samples of topics matching pattern and returns how many.
+func (s *payloadSamples) purge(pattern string, match func(topic, pattern string) bool) int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for topic := range s.topics {
+		if match(topic, pattern) {
+			delete(s.topics, topic)
+			n++
+		}
+	}
+	return n
+}
+
+// Fields lists the fields of the recent JSON object payloads on topics
+// matching pattern, sorted by name, and the number of payloads inspected
+// (implements admin.BridgeAdmin). Example values are redacted.
+func (b *Bridge) Fields(pattern string) ([]types.Field, int, error) {
+	samples := b.samples.matching(pattern, b.mapper.matchTopic)
+	if len(samples) == 0 {
+		return nil, 0, fmt.Errorf("no recent messages on %s", pattern)
+	}
+	if len(samples) > maxFieldsSamples {
+		samples = samples[:maxFieldsSamples]
+	}
+
+	fields := make(map[string]*types.Field)
+	objects := 0
+	for _, p := range samples {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(b.redactor.Payload(p.payload), &doc); err != nil {
+			continue
+		}
+		objects++
+		collectFields(fields, "", doc, 1)
+	}
+	if objects == 0 {
+		return nil, len(samples), fmt.Errorf("recent payloads on %s are not JSON objects", pattern)
+	}
+
+	out := make([]types.Field, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, objects, nil
+}
+
+// collectFields adds the leaf fields of obj to fields. Samples are visited
+// most recent first, so the first example seen is kept.
+func collectFields(fields map[string]*types.Field, prefix string, obj map[string]interface{}, depth int) {
+	for k, v := range obj {
+		name := prefix + k
+		if nested, ok := v.(map[string]interface{}); ok && depth < maxFieldDepth && len(nested) > 0 {
+			collectFields(fields, name+".", nested, depth+1)
+			continue
+		}
+		f := fields[name]
+		if f == nil {
+			f = &types.Field{Name: name, Type: jsonType(v), Example: fieldExample(v)}
+			fields[name] = f
+		}
+		f.Seen++
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// fieldExample renders v the way {{.JSON.field}} would, shortened.
+func fieldExample(v interface{}) string {
+	s := fmt.Sprintf("%v", v)
+	if r := []rune(s); len(r) > maxFieldExample {
+		s = string(r[:maxFieldExample-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package bridge
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestFields(t *testing.T) {
+	b := &Bridge{mapper: NewMapper(nil), samples: newPayloadSamples()}
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	b.samples.record("env/attic", []byte(`{"temperature": 19, "battery": 80}`), now)
+	b.samples.record("env/cellar", []byte(`{"temperature": 12.5, "sensor": {"id": "a1", "rssi": -70}}`), now.Add(time.Second))
+	b.samples.record("env/garage", []byte(`not json`), now.Add(2*time.Second))
+	b.samples.record("door/front", []byte(`{"open": true}`), now)
+
+	fields, n, err := b.Fields("env/+")
+	if err != nil {
+		t.Fatalf("Fields: %v", err)
+	}
+	want := []types.Field{
+		{Name: "battery", Type: "number", Example: "80", Seen: 1},
+		{Name: "sensor.id", Type: "string", Example: "a1", Seen: 1},
+		{Name: "sensor.rssi", Type: "number", Example: "-70", Seen: 1},
+		{Name: "temperature", Type: "number", Example: "12.5", Seen: 2}, // most recent
+	}
+	if n != 2 || !reflect.DeepEqual(fields, want) {
+		t.Errorf("Fields() = %+v, %d; want %+v, 2", fields, n, want)
+	}
+
+	if _, _, err := b.Fields("env/garage"); err == nil {
+		t.Error("Fields() on a non-JSON topic: want error")
+	}
+	if _, _, err := b.Fields("nothing/#"); err == nil {
+		t.Error("Fields() without samples: want error")
+	}
+}
+
+func TestPayloadSamples_Evicts(t *testing.T) {
+	s := newPayloadSamples()
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	for i := 0; i <= maxSampleTopics; i++ {
+		s.record(fmt.Sprintf("t/%d", i), []byte(`{}`), now.Add(time.Duration(i)*time.Second))
+	}
+	s.record("t/big", make([]byte, maxSampleBytes+1), now)
+
+	match := func(topic, pattern string) bool { return topic == pattern }
+	if got := s.matching("t/0", match); len(got) != 0 {
+		t.Error("least recently seen topic not evicted")
+	}
+	if got := s.matching(fmt.Sprintf("t/%d", maxSampleTopics), match); len(got) != 1 {
+		t.Error("newest topic missing")
+	}
+	if got := s.matching("t/big", match); len(got) != 0 {
+		t.Error("oversized payload kept")
+	}
+}
@@ -0,0 +1,199 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+const (
+	defaultGroupWindow    = 5 * time.Second
+	defaultGroupSeparator = " | "
+)
+
+// groupSpec is a mapping's group setting with the defaults applied.
+type groupSpec struct {
+	key       string // template
+	window    time.Duration
+	separator string
+}
+
+// loadGroups checks the group key template of every mapping that has one and
+// returns the settings by mappingKey.
+func loadGroups(mappings []config.MappingConfig) (map[string]groupSpec, error) {
+	groups := make(map[string]groupSpec)
+	keys := mappingKeys(mappings)
+	for i, m := range mappings {
+		g := m.Group
+		if g.Key == "" {
+			continue
+		}
+		if _, err := template.New("group").Funcs(irc.Funcs).Parse(g.Key); err != nil {
+			return nil, fmt.Errorf("invalid group.key for mapping %q: %w", m.MQTTTopic, err)
+		}
+		spec := groupSpec{key: g.Key, window: g.Window, separator: g.Separator}
+		if spec.window == 0 {
+			spec.window = defaultGroupWindow
+		}
+		if spec.separator == "" {
+			spec.separator = defaultGroupSeparator
+		}
+		groups[keys[i]] = spec
+	}
+	return groups, nil
+}
+
+// messageGroup is the lines waiting to be merged for one mapping, channel
+// and group key. The first message stands for the group when it is sent.
+type messageGroup struct {
+	ctx       context.Context
+	msg       types.Message
+	mapping   Mapped
+	channel   string
+	separator string
+	lines     []string
+	timer     schedule.Timer
+}
+
+// line returns the group's lines merged into one.
+func (g *messageGroup) line() string {
+	return mergeLines(g.lines, g.separator)
+}
+
+// grouper holds the message groups waiting for their window to end.
+type grouper struct {
+	mu      sync.Mutex
+	clock   schedule.Clock
+	pending map[string]*messageGroup
+	send    func(*messageGroup)
+}
+
+func newGrouper(clock schedule.Clock, send func(*messageGroup)) *grouper {
+	return &grouper{clock: clock, pending: make(map[string]*messageGroup), send: send}
+}
+
+// add puts line into the group of mapping, channel and key, starting the
+// group (and its window) if there is none. A group that would no longer fit
+// limits with the line is sent at once and the line starts a new one.
+func (g *grouper) add(ctx context.Context, msg types.Message, mapping Mapped, channel, key string, spec groupSpec, line string, limits irc.Limits) {
+	id := mapping.Key + "\x00" + channel + "\x00" + key
+	g.mu.Lock()
+	var full *messageGroup
+	if grp := g.pending[id]; grp != nil {
+		merged := mergeLines(append(grp.lines[:len(grp.lines):len(grp.lines)], line), grp.separator)
+		if limits.Fit(merged) == merged {
+			grp.lines = append(grp.lines, line)
+			g.mu.Unlock()
+			return
+		}
+		grp.timer.Stop()
+		delete(g.pending, id)
+		full = grp
+	}
+	grp := &messageGroup{ctx: ctx, msg: msg, mapping: mapping, channel: channel, separator: spec.separator, lines: []string{line}}
+	grp.timer = g.clock.AfterFunc(spec.window, func() { g.expire(id, grp) })
+	g.pending[id] = grp
+	g.mu.Unlock()
+
+	if full != nil {
+		g.send(full)
+	}
+}
+
+// expire sends grp when its window has ended.
+func (g *grouper) expire(id string, grp *messageGroup) {
+	g.mu.Lock()
+	if g.pending[id] != grp {
+		g.mu.Unlock()
+		return
+	}
+	delete(g.pending, id)
+	g.mu.Unlock()
+	g.send(grp)
+}
+
+// take removes the groups of the mapping with key and returns them, to be
+// sent or dropped at once. A nil grouper has none.
+func (g *grouper) take(key string) []*messageGroup {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var groups []*messageGroup
+	for id, grp := range g.pending {
+		if grp.mapping.Key == key {
+			grp.timer.Stop()
+			delete(g.pending, id)
+			groups = append(groups, grp)
+		}
+	}
+	return groups
+}
+
+// waiting returns the number of groups waiting for their window to end. A
+// nil grouper has none.
+func (g *grouper) waiting() int {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.pending)
+}
+
+// mergeLines joins lines with sep, leaving out of all but the first the
+// words they all start with: "BOB: a", "BOB: b" become "BOB: a | b".
+func mergeLines(lines []string, sep string) string {
+	if len(lines) == 1 {
+		return lines[0]
+	}
+	prefix := lines[0]
+	for _, l := range lines[1:] {
+		n := 0
+		for n < len(prefix) && n < len(l) && prefix[n] == l[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	// Only whole words: cut back to just after the last space.
+	prefix = prefix[:strings.LastIndexByte(prefix, ' ')+1]
+
+	var sb strings.Builder
+	sb.WriteString(lines[0])
+	for _, l := range lines[1:] {
+		sb.WriteString(sep)
+		sb.WriteString(l[len(prefix):])
+	}
+	return sb.String()
+}
+
+// groupMapped holds a one-line message of a mapping with a group key for
+// merging and reports whether it did. Messages whose key renders empty, and
+// multi-line messages, are not grouped.
+func (b *Bridge) groupMapped(ctx context.Context, msg types.Message, mapping Mapped, channel string, lines []string, tr *trace) bool {
+	spec, ok := b.groupSpecs[mapping.Key]
+	if !ok || len(lines) != 1 {
+		return false
+	}
+	key := strings.TrimSpace(irc.RenderMessage(msg, spec.key, irc.Target{}))
+	if key == "" {
+		return false
+	}
+	b.groups.add(ctx, msg, mapping, channel, key, spec, lines[0], b.limits)
+	tr.step("%s: grouped by %q for %s, sent when the %s window ends", mapping.MQTTTopic, key, channel, spec.window)
+	return true
+}
+
+// sendGroup sends a group's merged line.
+func (b *Bridge) sendGroup(grp *messageGroup) {
+	b.deliverMapped(grp.ctx, grp.msg, grp.mapping, grp.channel, []string{grp.line()}, nil)
+}
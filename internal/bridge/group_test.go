@@ -0,0 +1,99 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  string
+	}{
+		{[]string{"BOB: hi"}, "BOB: hi"},
+		{[]string{"BOB: msg1", "BOB: msg2", "BOB: msg3"}, "BOB: msg1 | msg2 | msg3"},
+		{[]string{"temp 21", "temp 22"}, "temp 21 | 22"}, // whole words only
+		{[]string{"BOB: hi", "BOB: hi"}, "BOB: hi | hi"},
+		{[]string{"alpha", "beta"}, "alpha | beta"},
+	}
+	for _, tt := range tests {
+		if got := mergeLines(tt.lines, " | "); got != tt.want {
+			t.Errorf("mergeLines(%q) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestGrouper(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC))
+	sent := make(chan string, 10)
+	g := newGrouper(clock, func(grp *messageGroup) { sent <- grp.channel + " " + grp.line() })
+	spec := groupSpec{window: 5 * time.Second, separator: " | "}
+	limits := irc.Limits{MaxLength: 30}
+	mapping := Mapped{Key: "msh/#"}
+	add := func(key, line string) {
+		g.add(context.Background(), types.Message{}, mapping, "#mesh", key, spec, line, limits)
+	}
+	next := func() string {
+		t.Helper()
+		select {
+		case line := <-sent:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("no group sent")
+			return ""
+		}
+	}
+
+	add("bob", "BOB: msg1")
+	add("alice", "ALICE: hello")
+	clock.Advance(2 * time.Second)
+	add("bob", "BOB: msg2")
+	if len(sent) != 0 || g.waiting() != 2 {
+		t.Fatalf("sent %d, waiting %d before the window ended", len(sent), g.waiting())
+	}
+	// The window runs from the first message of the group.
+	clock.Advance(3 * time.Second)
+	got := []string{next(), next()}
+	if !(got[0] == "#mesh BOB: msg1 | msg2" && got[1] == "#mesh ALICE: hello" ||
+		got[1] == "#mesh BOB: msg1 | msg2" && got[0] == "#mesh ALICE: hello") {
+		t.Errorf("sent %q", got)
+	}
+
+	// A line that would make the group too long sends it and starts a new one.
+	add("bob", "BOB: a long message")
+	add("bob", "BOB: and another one")
+	if got := next(); got != "#mesh BOB: a long message" {
+		t.Errorf("full group = %q", got)
+	}
+	clock.Advance(5 * time.Second)
+	if got := next(); got != "#mesh BOB: and another one" {
+		t.Errorf("new group = %q", got)
+	}
+	if g.waiting() != 0 {
+		t.Errorf("waiting = %d", g.waiting())
+	}
+}
+
+func TestLoadGroups(t *testing.T) {
+	groups, err := loadGroups([]config.MappingConfig{
+		{MQTTTopic: "a/#"},
+		{MQTTTopic: "msh/#", Group: config.GroupConfig{Key: "{{.JSON.from}}"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, ok := groups[mappingKey("msh/#", 0)]
+	if len(groups) != 1 || !ok || spec.window != defaultGroupWindow || spec.separator != defaultGroupSeparator {
+		t.Errorf("groups = %+v", groups)
+	}
+
+	if _, err := loadGroups([]config.MappingConfig{{MQTTTopic: "a/#", Group: config.GroupConfig{Key: "{{.JSON.from"}}}); err == nil {
+		t.Error("invalid key template accepted")
+	}
+}
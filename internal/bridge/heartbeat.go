@@ -0,0 +1,182 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+const (
+	defaultHeartbeatDownFormat = "💔 {{.Name}} heartbeat lost (last seen {{.Since}} ago)"
+	defaultHeartbeatUpFormat   = "💚 {{.Name}} is back (down for {{.Downtime}})"
+)
+
+// heartbeatTransition is the result of observing or checking a heartbeat.
+type heartbeatTransition int
+
+const (
+	heartbeatNoChange heartbeatTransition = iota
+	heartbeatWentDown
+	heartbeatCameUp
+	heartbeatStillDown // down and due for a repeated announcement
+)
+
+// heartbeatMonitor tracks the presence of a single heartbeat topic.
+type heartbeatMonitor struct {
+	cfg      config.HeartbeatConfig
+	mapper   *Mapper
+	downTmpl *template.Template
+	upTmpl   *template.Template
+
+	mu        sync.Mutex
+	lastSeen  time.Time
+	downSince time.Time // zero while up
+	announced time.Time // last down announcement
+}
+
+// newHeartbeatMonitor creates a monitor. The heartbeat is assumed present at
+// start, so a source that never publishes is reported after one timeout.
+func newHeartbeatMonitor(cfg config.HeartbeatConfig, start time.Time) (*heartbeatMonitor, error) {
+	if cfg.DownFormat == "" {
+		cfg.DownFormat = defaultHeartbeatDownFormat
+	}
+	if cfg.UpFormat == "" {
+		cfg.UpFormat = defaultHeartbeatUpFormat
+	}
+	downTmpl, err := template.New("down").Option("missingkey=zero").Parse(cfg.DownFormat)
+	if err != nil {
+		return nil, fmt.Errorf("heartbeat %q: invalid down_format: %w", cfg.Name, err)
+	}
+	upTmpl, err := template.New("up").Option("missingkey=zero").Parse(cfg.UpFormat)
+	if err != nil {
+		return nil, fmt.Errorf("heartbeat %q: invalid up_format: %w", cfg.Name, err)
+	}
+	return &heartbeatMonitor{
+		cfg:      cfg,
+		mapper:   NewMapper([]config.MappingConfig{{MQTTTopic: cfg.MQTTTopic}}),
+		downTmpl: downTmpl,
+		upTmpl:   upTmpl,
+		lastSeen: start,
+	}, nil
+}
+
+// matches reports whether topic belongs to this heartbeat.
+func (m *heartbeatMonitor) matches(topic string) bool {
+	return m.mapper.matchTopic(topic, m.cfg.MQTTTopic)
+}
+
+// observe records a heartbeat at now.
+func (m *heartbeatMonitor) observe(now time.Time) heartbeatTransition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSeen = now
+	if m.downSince.IsZero() {
+		return heartbeatNoChange
+	}
+	return heartbeatCameUp
+}
+
+// check evaluates the timeout at now.
+func (m *heartbeatMonitor) check(now time.Time) heartbeatTransition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSeen) <= m.cfg.Timeout {
+		return heartbeatNoChange
+	}
+	if m.downSince.IsZero() {
+		m.downSince = now
+		m.announced = now
+		return heartbeatWentDown
+	}
+	if m.cfg.Repeat > 0 && m.cfg.Mode != "topic" && now.Sub(m.announced) >= m.cfg.Repeat {
+		m.announced = now
+		return heartbeatStillDown
+	}
+	return heartbeatNoChange
+}
+
+// render produces the announcement line for a transition and clears the down
+// state when the heartbeat came back.
+func (m *heartbeatMonitor) render(tr heartbeatTransition, now time.Time) (string, error) {
+	m.mu.Lock()
+	data := map[string]interface{}{
+		"Name":     m.cfg.Name,
+		"Topic":    m.cfg.MQTTTopic,
+		"LastSeen": m.lastSeen.Format(time.RFC3339),
+		"Since":    now.Sub(m.lastSeen).Round(time.Second).String(),
+		"Downtime": "",
+	}
+	tmpl := m.downTmpl
+	if tr == heartbeatCameUp {
+		data["Downtime"] = now.Sub(m.downSince).Round(time.Second).String()
+		m.downSince = time.Time{}
+		tmpl = m.upTmpl
+	}
+	m.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("heartbeat %q: %w", m.cfg.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// observeHeartbeats feeds an incoming message to all heartbeat monitors.
+func (b *Bridge) observeHeartbeats(ctx context.Context, topic string) {
+	for _, m := range b.heartbeats {
+		if !m.matches(topic) {
+			continue
+		}
+		now := b.clock.Now()
+		if tr := m.observe(now); tr != heartbeatNoChange {
+			b.announceHeartbeat(ctx, m, tr, now)
+		}
+	}
+}
+
+// runHeartbeats periodically checks all heartbeat monitors for timeouts.
+func (b *Bridge) runHeartbeats(ctx context.Context) {
+	defer b.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-b.clock.After(time.Second):
+			for _, m := range b.heartbeats {
+				if tr := m.check(now); tr != heartbeatNoChange {
+					b.announceHeartbeat(ctx, m, tr, now)
+				}
+			}
+		}
+	}
+}
+
+// announceHeartbeat posts a heartbeat state change to the monitor's channels,
+// either as a message or by setting the channel topic.
+func (b *Bridge) announceHeartbeat(ctx context.Context, m *heartbeatMonitor, tr heartbeatTransition, now time.Time) {
+	line, err := m.render(tr, now)
+	if err != nil {
+		b.logger.Error().Err(err).Msg("failed to render heartbeat announcement")
+		return
+	}
+	b.logger.Info().
+		Str("heartbeat", m.cfg.Name).
+		Bool("up", tr == heartbeatCameUp).
+		Msg("heartbeat state changed")
+
+	for _, channel := range m.cfg.IRCChannels {
+		if m.cfg.Mode == "topic" {
+			b.ircClient.SetTopic(channel, line)
+			continue
+		}
+		if err := b.ircClient.SendMessage(ctx, channel, line); err != nil {
+			b.logger.Error().Err(err).Str("channel", channel).Msg("failed to send heartbeat announcement")
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+func TestHeartbeatMonitor_Transitions(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m, err := newHeartbeatMonitor(config.HeartbeatConfig{
+		Name:      "homeserver",
+		MQTTTopic: "home/server/heartbeat",
+		Timeout:   time.Minute,
+	}, start)
+	if err != nil {
+		t.Fatalf("newHeartbeatMonitor: %v", err)
+	}
+
+	if tr := m.check(start.Add(30 * time.Second)); tr != heartbeatNoChange {
+		t.Fatalf("within timeout: got %v, want no change", tr)
+	}
+	if tr := m.observe(start.Add(45 * time.Second)); tr != heartbeatNoChange {
+		t.Fatalf("heartbeat while up: got %v, want no change", tr)
+	}
+
+	down := start.Add(2 * time.Minute)
+	if tr := m.check(down); tr != heartbeatWentDown {
+		t.Fatalf("after timeout: got %v, want went down", tr)
+	}
+	line, err := m.render(heartbeatWentDown, down)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if want := "💔 homeserver heartbeat lost (last seen 1m15s ago)"; line != want {
+		t.Errorf("down line = %q, want %q", line, want)
+	}
+
+	// Without repeat, a still-down heartbeat is announced once.
+	if tr := m.check(down.Add(time.Hour)); tr != heartbeatNoChange {
+		t.Errorf("still down without repeat: got %v, want no change", tr)
+	}
+
+	up := down.Add(5 * time.Minute)
+	if tr := m.observe(up); tr != heartbeatCameUp {
+		t.Fatalf("heartbeat after down: got %v, want came up", tr)
+	}
+	line, err = m.render(heartbeatCameUp, up)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if want := "💚 homeserver is back (down for 5m0s)"; line != want {
+		t.Errorf("up line = %q, want %q", line, want)
+	}
+	if tr := m.observe(up.Add(time.Second)); tr != heartbeatNoChange {
+		t.Errorf("heartbeat after recovery: got %v, want no change", tr)
+	}
+}
+
+func TestHeartbeatMonitor_Repeat(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m, err := newHeartbeatMonitor(config.HeartbeatConfig{
+		Name:      "nas",
+		MQTTTopic: "nas/+/alive",
+		Timeout:   time.Minute,
+		Repeat:    10 * time.Minute,
+	}, start)
+	if err != nil {
+		t.Fatalf("newHeartbeatMonitor: %v", err)
+	}
+
+	if !m.matches("nas/1/alive") || m.matches("nas/alive") {
+		t.Error("matches() should honour MQTT wildcards")
+	}
+
+	down := start.Add(2 * time.Minute)
+	if tr := m.check(down); tr != heartbeatWentDown {
+		t.Fatalf("got %v, want went down", tr)
+	}
+	if tr := m.check(down.Add(5 * time.Minute)); tr != heartbeatNoChange {
+		t.Errorf("before repeat interval: got %v, want no change", tr)
+	}
+	if tr := m.check(down.Add(10 * time.Minute)); tr != heartbeatStillDown {
+		t.Errorf("after repeat interval: got %v, want still down", tr)
+	}
+}
+
+func TestHeartbeatMonitor_InvalidFormat(t *testing.T) {
+	_, err := newHeartbeatMonitor(config.HeartbeatConfig{
+		Name:       "bad",
+		MQTTTopic:  "x",
+		Timeout:    time.Minute,
+		DownFormat: "{{.Name",
+	}, time.Now())
+	if err == nil {
+		t.Error("expected error for invalid down_format")
+	}
+}
@@ -0,0 +1,223 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/persist"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// maxBackfill is the longest period !backfill replays.
+const maxBackfill = 24 * time.Hour
+
+type historyLine struct {
+	at    time.Time
+	text  string
+	id    string // of the bridged message, not shown in replays
+	topic string // of the bridged message, for !purge topic
+}
+
+// historyFileLine is a historyLine as saved to bridge.storage.history.file.
+type historyFileLine struct {
+	At    time.Time `json:"at"`
+	Text  string    `json:"text"`
+	ID    string    `json:"id,omitempty"`
+	Topic string    `json:"topic,omitempty"`
+}
+
+// channelHistory keeps the last lines sent to each channel (bridge.history_size)
+// for !backfill. It lives in memory and starts empty after a restart, unless
+// bridge.storage.history.file is set. A nil *channelHistory records nothing.
+type channelHistory struct {
+	mu    sync.Mutex
+	size  int
+	loc   *time.Location
+	lines map[string][]historyLine // lower-cased channel → oldest first
+
+	path  string         // storage.history.file; empty = not saved
+	state *persist.State // unsaved changes; nil without path
+}
+
+func newChannelHistory(size int, loc *time.Location) *channelHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &channelHistory{size: size, loc: loc, lines: make(map[string][]historyLine)}
+}
+
+// openChannelHistory creates the history and, with store.File set, loads
+// the lines saved by the last run. A missing file is a fresh start, and so
+// is a corrupt one, unless its backup can be loaded (see persist.Load).
+func openChannelHistory(size int, loc *time.Location, store config.StoreConfig, clock schedule.Clock) (*channelHistory, error) {
+	h := newChannelHistory(size, loc)
+	if h == nil || store.File == "" {
+		return h, nil
+	}
+	h.path = store.File
+	h.state = persist.NewState(store.Policy(), clock)
+
+	err := persist.Load(h.path, store.Backup, func(data []byte) error {
+		var saved map[string][]historyFileLine
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return err
+		}
+		for channel, lines := range saved {
+			if len(lines) > size {
+				lines = lines[len(lines)-size:]
+			}
+			for _, l := range lines {
+				h.lines[channel] = append(h.lines[channel], historyLine{at: l.At, text: l.Text, id: l.ID, topic: l.Topic})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	return h, nil
+}
+
+// record remembers a line sent to channel for the message with the given ID
+// and topic. A non-nil error means only that saving the history failed.
+func (h *channelHistory) record(channel, text, id, topic string, now time.Time) error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.ToLower(channel)
+	lines := append(h.lines[key], historyLine{at: now, text: text, id: id, topic: topic})
+	if len(lines) > h.size {
+		lines = lines[len(lines)-h.size:]
+	}
+	h.lines[key] = lines
+	if !h.state.Changed() {
+		return nil
+	}
+	return h.saveLocked()
+}
+
+// snapshot saves the history if it has unsaved changes whose snapshot is
+// due.
+func (h *channelHistory) snapshot() error {
+	if h == nil || !h.state.Due() {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.saveLocked()
+}
+
+// flush saves the history if it has unsaved changes.
+func (h *channelHistory) flush() error {
+	if h == nil || !h.state.Dirty() {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.saveLocked()
+}
+
+// saveLocked writes the history to its file. The caller holds mu.
+func (h *channelHistory) saveLocked() error {
+	saved := make(map[string][]historyFileLine, len(h.lines))
+	for channel, lines := range h.lines {
+		for _, l := range lines {
+			saved[channel] = append(saved[channel], historyFileLine{At: l.at, Text: l.text, ID: l.id, Topic: l.topic})
+		}
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("history: marshal: %w", err)
+	}
+	if err := persist.WriteFile(h.path, data, h.state.Policy()); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	h.state.Saved()
+	return nil
+}
+
+// purge removes the lines match selects and returns how many it removed.
+// A history file is saved at once, and its backup removed, so the lines are
+// gone from disk too.
+func (h *channelHistory) purge(match func(historyLine) bool) (int, error) {
+	if h == nil {
+		return 0, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for channel, lines := range h.lines {
+		kept := lines[:0]
+		for _, l := range lines {
+			if match(l) {
+				n++
+				continue
+			}
+			kept = append(kept, l)
+		}
+		if len(kept) == 0 {
+			delete(h.lines, channel)
+			continue
+		}
+		h.lines[channel] = kept
+	}
+	if n == 0 || h.path == "" {
+		return n, nil
+	}
+	if err := h.saveLocked(); err != nil {
+		return n, err
+	}
+	return n, persist.RemoveBackup(h.path)
+}
+
+// since returns the lines sent to channel at or after t, oldest first.
+func (h *channelHistory) since(channel string, t time.Time) []historyLine {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	lines := h.lines[strings.ToLower(channel)]
+	for i, l := range lines {
+		if !l.at.Before(t) {
+			return append([]historyLine(nil), lines[i:]...)
+		}
+	}
+	return nil
+}
+
+// replayText labels a replayed line with the time it was first sent.
+func (h *channelHistory) replayText(l historyLine) string {
+	return fmt.Sprintf("[replay %s] %s", l.at.In(h.loc).Format("15:04"), l.text)
+}
+
+// Backfill resends the lines sent to channel within the last d, each labeled
+// as a replay, and returns how many it queued (implements admin.BridgeAdmin).
+// The lines go through the rate limiter in the background.
+func (b *Bridge) Backfill(channel string, d time.Duration) (int, error) {
+	if b.history == nil {
+		return 0, fmt.Errorf("message history is disabled (bridge.history_size)")
+	}
+	if d <= 0 || d > maxBackfill {
+		return 0, fmt.Errorf("duration must be between 0 and %s", maxBackfill)
+	}
+	lines := b.history.since(channel, b.clock.Now().Add(-d))
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	b.logger.Info().Str("channel", channel).Dur("period", d).Int("lines", len(lines)).Msg("backfill")
+	go func() {
+		for _, l := range lines {
+			if err := b.ircClient.SendMessage(context.Background(), channel, b.limits.Fit(b.history.replayText(l))); err != nil {
+				b.logger.Error().Err(err).Str("channel", channel).Msg("backfill send failed")
+				return
+			}
+			b.logger.Debug().Str("msg_id", l.id).Str("channel", channel).Msg("message replayed")
+		}
+	}()
+	return len(lines), nil
+}
@@ -0,0 +1,66 @@
+package bridge
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+func TestChannelHistory(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := newChannelHistory(3, time.UTC)
+	for i, text := range []string{"a", "b", "c", "d"} {
+		h.record("#Sensors", text, "", "", base.Add(time.Duration(i)*time.Minute))
+	}
+	h.record("#other", "x", "", "", base)
+
+	// Only the last 3 lines are kept; channel names are case-insensitive.
+	got := h.since("#sensors", base)
+	if len(got) != 3 || got[0].text != "b" || got[2].text != "d" {
+		t.Fatalf("since() = %v, want b c d", got)
+	}
+	got = h.since("#sensors", base.Add(150*time.Second))
+	if len(got) != 1 || got[0].text != "d" {
+		t.Errorf("since(12:02:30) = %v, want d", got)
+	}
+	if got := h.since("#unknown", base); got != nil {
+		t.Errorf("since(#unknown) = %v, want nil", got)
+	}
+
+	if text := h.replayText(got[0]); text != "[replay 12:03] d" {
+		t.Errorf("replayText() = %q", text)
+	}
+}
+
+func TestChannelHistory_Disabled(t *testing.T) {
+	h := newChannelHistory(0, time.UTC)
+	if h != nil {
+		t.Fatal("newChannelHistory(0) should be nil")
+	}
+	h.record("#c", "text", "", "", time.Now()) // must not panic
+}
+
+func TestBackfill_Errors(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	b := &Bridge{clock: clock, logger: zerolog.New(os.Stderr).Level(zerolog.Disabled)}
+	if _, err := b.Backfill("#c", time.Hour); err == nil {
+		t.Error("Backfill() without history should fail")
+	}
+
+	b.history = newChannelHistory(10, time.UTC)
+	for _, d := range []time.Duration{0, -time.Minute, 25 * time.Hour} {
+		if _, err := b.Backfill("#c", d); err == nil {
+			t.Errorf("Backfill(%v) should fail", d)
+		}
+	}
+
+	b.history.record("#c", "old", "", "", clock.Now().Add(-2*time.Hour))
+	n, err := b.Backfill("#c", time.Hour)
+	if err != nil || n != 0 {
+		t.Errorf("Backfill() = %d, %v, want 0 lines", n, err)
+	}
+}
@@ -0,0 +1,164 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Home Assistant button commands, published to the command topic.
+const (
+	haReconnect = "reconnect"
+	haPause     = "pause"
+	haResume    = "resume"
+)
+
+// haEntity is one entity of the bridge device in Home Assistant.
+type haEntity struct {
+	component string // sensor, binary_sensor or button
+	object    string
+	config    map[string]interface{}
+}
+
+// haMessage is a retained discovery config to publish.
+type haMessage struct {
+	topic   string
+	payload []byte
+}
+
+// haCommandTopic returns the topic the Home Assistant buttons publish to.
+func (b *Bridge) haCommandTopic() string {
+	if t := b.config.HomeAssistant.CommandTopic; t != "" {
+		return t
+	}
+	return strings.TrimSuffix(b.config.StatsPublish.Topic, "/") + "/command"
+}
+
+// haDiscovery returns the discovery configs for the bridge device: sensors
+// reading the stats_publish report and buttons publishing to the command
+// topic.
+func (b *Bridge) haDiscovery() ([]haMessage, error) {
+	ha := b.config.HomeAssistant
+	state := b.config.StatsPublish.Topic
+	command := b.haCommandTopic()
+	// Values older than three reports show as unavailable.
+	expire := int(3 * b.config.StatsPublish.Interval.Seconds())
+
+	entities := []haEntity{
+		{"binary_sensor", "connected", map[string]interface{}{
+			"name":           "Connected",
+			"device_class":   "connectivity",
+			"state_topic":    state,
+			"value_template": "{{ 'ON' if value_json.mqtt_connected and value_json.irc_connected else 'OFF' }}",
+			"expire_after":   expire,
+		}},
+		{"binary_sensor", "paused", map[string]interface{}{
+			"name":           "Paused",
+			"state_topic":    state,
+			"value_template": "{{ 'ON' if value_json.paused else 'OFF' }}",
+			"expire_after":   expire,
+		}},
+		{"sensor", "queue_depth", map[string]interface{}{
+			"name":                "Queue depth",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.queue_size }}",
+			"unit_of_measurement": "messages",
+			"state_class":         "measurement",
+			"expire_after":        expire,
+		}},
+		{"sensor", "messages_per_minute", map[string]interface{}{
+			"name":                "Messages per minute",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.messages_per_minute }}",
+			"unit_of_measurement": "messages/min",
+			"state_class":         "measurement",
+			"expire_after":        expire,
+		}},
+		{"button", haReconnect, map[string]interface{}{
+			"name":          "Reconnect IRC",
+			"command_topic": command,
+			"payload_press": haReconnect,
+		}},
+		{"button", haPause, map[string]interface{}{
+			"name":          "Pause",
+			"command_topic": command,
+			"payload_press": haPause,
+		}},
+		{"button", haResume, map[string]interface{}{
+			"name":          "Resume",
+			"command_topic": command,
+			"payload_press": haResume,
+		}},
+	}
+
+	device := map[string]interface{}{
+		"identifiers": []string{ha.NodeID},
+		"name":        ha.NodeID,
+		"model":       "mqtt2irc",
+		"sw_version":  b.version,
+	}
+	messages := make([]haMessage, 0, len(entities))
+	for _, e := range entities {
+		e.config["unique_id"] = ha.NodeID + "_" + e.object
+		e.config["device"] = device
+		payload, err := json.Marshal(e.config)
+		if err != nil {
+			return nil, fmt.Errorf("encode %s discovery config: %w", e.object, err)
+		}
+		messages = append(messages, haMessage{
+			topic:   fmt.Sprintf("%s/%s/%s/%s/config", ha.Prefix, e.component, ha.NodeID, e.object),
+			payload: payload,
+		})
+	}
+	return messages, nil
+}
+
+// publishDiscovery publishes the discovery configs (retained).
+func (b *Bridge) publishDiscovery() {
+	messages, err := b.haDiscovery()
+	if err != nil {
+		b.logger.Error().Err(err).Msg("failed to build Home Assistant discovery configs")
+		return
+	}
+	for _, m := range messages {
+		if err := b.mqttClient.Publish(m.topic, 1, true, m.payload); err != nil {
+			b.logger.Warn().Err(err).Msg("failed to publish Home Assistant discovery config")
+			return
+		}
+	}
+	b.logger.Info().Int("entities", len(messages)).Msg("published Home Assistant discovery configs")
+}
+
+// setupHomeAssistant registers the button command topic and re-publishes the
+// discovery configs whenever Home Assistant comes online. Must be called
+// before the MQTT client connects.
+func (b *Bridge) setupHomeAssistant() {
+	b.mqttClient.Handle(b.haCommandTopic(), func(payload []byte) {
+		b.haCommand(strings.TrimSpace(string(payload)))
+	})
+	b.mqttClient.Handle(b.config.HomeAssistant.Prefix+"/status", func(payload []byte) {
+		if string(payload) == "online" {
+			// Publishing waits for the broker; not on paho's delivery goroutine.
+			go b.publishDiscovery()
+		}
+	})
+}
+
+// haCommand runs a Home Assistant button press.
+func (b *Bridge) haCommand(command string) {
+	switch command {
+	case haReconnect:
+		b.logger.Info().Msg("IRC reconnect requested from Home Assistant")
+		b.ircClient.Reconnect()
+	case haPause:
+		if !b.paused.Swap(true) {
+			b.logger.Warn().Msg("bridge paused from Home Assistant")
+		}
+	case haResume:
+		if b.paused.Swap(false) {
+			b.logger.Info().Msg("bridge resumed from Home Assistant")
+		}
+	default:
+		b.logger.Warn().Str("command", command).Msg("unknown Home Assistant command")
+	}
+}
@@ -0,0 +1,79 @@
+package bridge
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+func TestHADiscovery(t *testing.T) {
+	b := &Bridge{
+		config: config.BridgeConfig{
+			StatsPublish:  config.StatsTopicConfig{Topic: "mqtt2irc/status/", Interval: time.Minute},
+			HomeAssistant: config.HAConfig{Discovery: true, Prefix: "homeassistant", NodeID: "bridge1"},
+		},
+		version: "1.2.3",
+		logger:  zerolog.New(os.Stderr).Level(zerolog.Disabled),
+	}
+	messages, err := b.haDiscovery()
+	if err != nil {
+		t.Fatalf("haDiscovery: %v", err)
+	}
+
+	configs := make(map[string]map[string]interface{})
+	for _, m := range messages {
+		var cfg map[string]interface{}
+		if err := json.Unmarshal(m.payload, &cfg); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", m.topic, err)
+		}
+		configs[m.topic] = cfg
+	}
+
+	for _, topic := range []string{
+		"homeassistant/binary_sensor/bridge1/connected/config",
+		"homeassistant/sensor/bridge1/queue_depth/config",
+		"homeassistant/sensor/bridge1/messages_per_minute/config",
+		"homeassistant/button/bridge1/reconnect/config",
+		"homeassistant/button/bridge1/pause/config",
+		"homeassistant/button/bridge1/resume/config",
+	} {
+		if _, ok := configs[topic]; !ok {
+			t.Errorf("missing discovery config %s", topic)
+		}
+	}
+
+	queue := configs["homeassistant/sensor/bridge1/queue_depth/config"]
+	if queue["state_topic"] != "mqtt2irc/status/" || queue["unique_id"] != "bridge1_queue_depth" || queue["expire_after"] != 180.0 {
+		t.Errorf("queue_depth config = %v", queue)
+	}
+	device, _ := queue["device"].(map[string]interface{})
+	if device["sw_version"] != "1.2.3" {
+		t.Errorf("device = %v, want sw_version 1.2.3", device)
+	}
+	pause := configs["homeassistant/button/bridge1/pause/config"]
+	if pause["command_topic"] != "mqtt2irc/status/command" || pause["payload_press"] != "pause" {
+		t.Errorf("pause config = %v", pause)
+	}
+}
+
+func TestHACommand_PauseResume(t *testing.T) {
+	b := &Bridge{logger: zerolog.New(os.Stderr).Level(zerolog.Disabled)}
+
+	b.haCommand("pause")
+	if !b.paused.Load() {
+		t.Fatal("bridge not paused after pause")
+	}
+	b.haCommand("bogus")
+	if !b.paused.Load() {
+		t.Fatal("unknown command changed the pause state")
+	}
+	b.haCommand("resume")
+	if b.paused.Load() {
+		t.Fatal("bridge still paused after resume")
+	}
+}
@@ -0,0 +1,149 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// sentHooks are run after each line is sent to IRC: the functions added with
+// OnSent, and the bridge.sent_hook command. A nil *sentHooks runs nothing.
+type sentHooks struct {
+	mu    sync.RWMutex
+	funcs []func(types.SentMessage)
+	exec  *execHook // nil unless bridge.sent_hook.command is set
+}
+
+func newSentHooks(cfg config.SentHookConfig, logger zerolog.Logger) *sentHooks {
+	h := &sentHooks{}
+	if len(cfg.Command) > 0 {
+		h.exec = &execHook{
+			cfg:     cfg,
+			running: make(chan struct{}, cfg.MaxRunning),
+			logger:  logger.With().Str("component", "sent_hook").Logger(),
+		}
+	}
+	return h
+}
+
+func (h *sentHooks) add(fn func(types.SentMessage)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.funcs = append(h.funcs, fn)
+}
+
+// run calls the hooks for a sent line, the functions in order in the calling
+// goroutine, then starts the command.
+func (h *sentHooks) run(sent types.SentMessage) {
+	if h == nil {
+		return
+	}
+	h.mu.RLock()
+	funcs := h.funcs
+	h.mu.RUnlock()
+	for _, fn := range funcs {
+		fn(sent)
+	}
+	h.exec.start(sent)
+}
+
+// wait waits for the running hook commands to finish.
+func (h *sentHooks) wait() {
+	if h != nil && h.exec != nil {
+		h.exec.wg.Wait()
+	}
+}
+
+// execHook runs the bridge.sent_hook command, with the sent line as JSON on
+// stdin and its channel, topic and message ID in MQTT2IRC_CHANNEL,
+// MQTT2IRC_TOPIC and MQTT2IRC_MSG_ID. At most max_running commands run at
+// once; lines sent meanwhile skip the hook rather than queue behind it. A nil
+// *execHook runs nothing.
+type execHook struct {
+	cfg     config.SentHookConfig
+	running chan struct{} // semaphore
+	logger  zerolog.Logger
+	wg      sync.WaitGroup
+}
+
+func (e *execHook) start(sent types.SentMessage) {
+	if e == nil {
+		return
+	}
+	select {
+	case e.running <- struct{}{}:
+	default:
+		e.logger.Warn().
+			Str("msg_id", sent.MsgID).
+			Str("channel", sent.Channel).
+			Int("max_running", e.cfg.MaxRunning).
+			Msg("sent hook skipped, too many running")
+		return
+	}
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer func() { <-e.running }()
+		e.run(sent)
+	}()
+}
+
+func (e *execHook) run(sent types.SentMessage) {
+	input, err := json.Marshal(sent)
+	if err != nil {
+		e.logger.Error().Err(err).Msg("failed to encode sent message for hook")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, e.cfg.Command[0], e.cfg.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Env = append(os.Environ(),
+		"MQTT2IRC_CHANNEL="+sent.Channel,
+		"MQTT2IRC_TOPIC="+sent.Topic,
+		"MQTT2IRC_MSG_ID="+sent.MsgID,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		ev := e.logger.Warn().Err(err).Str("msg_id", sent.MsgID).Str("channel", sent.Channel)
+		if ctx.Err() != nil {
+			ev = ev.Dur("timeout", e.cfg.Timeout)
+		}
+		ev.Str("output", strings.TrimSpace(string(out))).Msg("sent hook failed")
+	}
+}
+
+// OnSent adds a function called after each line is sent to IRC, with the
+// line, its channel and the message it came from. It runs in the goroutine
+// that sends to the channel, so it must return quickly; hand slow work off
+// to a goroutine of your own. Hooks do not run in safe mode.
+func (b *Bridge) OnSent(fn func(types.SentMessage)) {
+	b.sentHooks.add(fn)
+}
+
+// runSentHooks runs the send hooks for a line sent to channel.
+func (b *Bridge) runSentHooks(out outbound, channel, line string) {
+	if b.safeMode {
+		return
+	}
+	b.sentHooks.run(types.SentMessage{
+		MsgID:    out.msg.ID,
+		Topic:    out.msg.Topic,
+		Channel:  channel,
+		Text:     line,
+		Tenant:   out.tenant,
+		Priority: out.msg.Priority.String(),
+		Meta:     out.msg.Meta,
+		Received: out.msg.Timestamp,
+		Sent:     b.clock.Now(),
+	})
+}
@@ -0,0 +1,92 @@
+package bridge
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestSentHooks(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "sent")
+	hooks := newSentHooks(config.SentHookConfig{
+		Command:    []string{"sh", "-c", `cat > "$0"; echo "$MQTT2IRC_CHANNEL $MQTT2IRC_MSG_ID" >> "$0"`, out},
+		Timeout:    5 * time.Second,
+		MaxRunning: 1,
+	}, zerolog.New(os.Stderr).Level(zerolog.Disabled))
+
+	received := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := schedule.NewFake(received.Add(time.Second))
+	b := &Bridge{clock: clock, sentHooks: hooks}
+	var got []types.SentMessage
+	b.OnSent(func(s types.SentMessage) { got = append(got, s) })
+
+	msg := types.Message{ID: "m1", Topic: "sensors/x", Timestamp: received, Priority: types.PriorityHigh}
+	b.runSentHooks(outbound{msg: msg, tenant: "acme"}, "#ch", "hello")
+	hooks.wait()
+
+	want := types.SentMessage{
+		MsgID: "m1", Topic: "sensors/x", Channel: "#ch", Text: "hello", Tenant: "acme",
+		Priority: "high", Received: received, Sent: received.Add(time.Second),
+	}
+	if len(got) != 1 || got[0].Text != want.Text || got[0].Channel != want.Channel || !got[0].Sent.Equal(want.Sent) {
+		t.Fatalf("OnSent got %+v, want %+v", got, want)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin, env, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	var sent types.SentMessage
+	if err := json.Unmarshal([]byte(stdin), &sent); err != nil {
+		t.Fatalf("hook stdin %q: %v", stdin, err)
+	}
+	if sent.MsgID != "m1" || sent.Text != "hello" || sent.Priority != "high" || sent.Tenant != "acme" {
+		t.Errorf("hook stdin = %+v", sent)
+	}
+	if env != "#ch m1" {
+		t.Errorf("hook environment = %q", env)
+	}
+
+	// Safe mode runs no hooks.
+	b.safeMode = true
+	b.runSentHooks(outbound{msg: msg}, "#ch", "again")
+	if len(got) != 1 {
+		t.Errorf("hook ran in safe mode")
+	}
+}
+
+func TestExecHook_MaxRunning(t *testing.T) {
+	hooks := newSentHooks(config.SentHookConfig{
+		Command:    []string{"sleep", "0.2"},
+		Timeout:    5 * time.Second,
+		MaxRunning: 1,
+	}, zerolog.New(os.Stderr).Level(zerolog.Disabled))
+
+	hooks.run(types.SentMessage{MsgID: "m1"})
+	if len(hooks.exec.running) != 1 {
+		t.Fatalf("running = %d, want 1", len(hooks.exec.running))
+	}
+	hooks.run(types.SentMessage{MsgID: "m2"}) // skipped, not queued
+	if len(hooks.exec.running) != 1 {
+		t.Errorf("running = %d after a skipped hook", len(hooks.exec.running))
+	}
+	hooks.wait()
+	if len(hooks.exec.running) != 0 {
+		t.Errorf("running = %d after wait", len(hooks.exec.running))
+	}
+
+	var nilHooks *sentHooks
+	nilHooks.run(types.SentMessage{}) // no-op
+	nilHooks.wait()
+}
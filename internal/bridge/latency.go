@@ -0,0 +1,42 @@
+package bridge
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// annotateLatency reports a line delivered later than bridge.latency.threshold
+// after its message was received: it logs the latency and, with annotate,
+// appends it to the line ("(+2.3s)"), so channel members see that a flood or
+// the rate limiter held it back.
+func (b *Bridge) annotateLatency(msg types.Message, channel, line string) string {
+	cfg := b.config.Latency
+	if cfg.Threshold <= 0 || msg.Timestamp.IsZero() {
+		return line
+	}
+	latency := b.clock.Now().Sub(msg.Timestamp)
+	if latency < cfg.Threshold {
+		return line
+	}
+	b.logger.Info().
+		Str("msg_id", msg.ID).
+		Str("channel", channel).
+		Str("topic", msg.Topic).
+		Dur("latency", latency).
+		Msg("late delivery")
+	if !cfg.Annotate {
+		return line
+	}
+	return b.limits.FitWith(line, " "+formatLatency(latency))
+}
+
+// formatLatency renders a latency for an annotation: "(+2.3s)", and from a
+// minute on in whole seconds, "(+1m5s)".
+func formatLatency(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("(+%.1fs)", d.Seconds())
+	}
+	return "(+" + d.Round(time.Second).String() + ")"
+}
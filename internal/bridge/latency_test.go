@@ -0,0 +1,44 @@
+package bridge
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestAnnotateLatency(t *testing.T) {
+	received := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := schedule.NewFake(received)
+	b := &Bridge{
+		config: config.BridgeConfig{Latency: config.LatencyConfig{Threshold: 2 * time.Second, Annotate: true}},
+		clock:  clock,
+		limits: irc.Limits{MaxLength: 20, Suffix: "..."},
+		logger: zerolog.New(os.Stderr).Level(zerolog.Disabled),
+	}
+	msg := types.Message{ID: "m1", Topic: "sensors/x", Timestamp: received}
+
+	clock.Advance(time.Second)
+	if got := b.annotateLatency(msg, "#ch", "hello"); got != "hello" {
+		t.Errorf("under the threshold: %q", got)
+	}
+	clock.Advance(1300 * time.Millisecond)
+	if got := b.annotateLatency(msg, "#ch", "hello"); got != "hello (+2.3s)" {
+		t.Errorf("over the threshold: %q", got)
+	}
+	clock.Advance(time.Minute)
+	if got := b.annotateLatency(msg, "#ch", "hello world, long"); got != "hello wor... (+1m2s)" {
+		t.Errorf("long line: %q", got)
+	}
+
+	b.config.Latency.Annotate = false
+	if got := b.annotateLatency(msg, "#ch", "hello"); got != "hello" {
+		t.Errorf("log only: %q", got)
+	}
+}
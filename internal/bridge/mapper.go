@@ -1,37 +1,189 @@
 package bridge
 
 import (
+	"fmt"
 	"path"
 	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
 // Mapper handles topic-to-channel mapping
 type Mapper struct {
-	mappings []config.MappingConfig
+	mu         sync.RWMutex
+	mappings   []config.MappingConfig
+	keys       []string // mappingKey of each mapping
+	configured []string // message_format before runtime changes
+	added      []bool   // added at runtime, dropped by a reload
+	paused     []bool
+}
+
+// Mapped is a mapping matched by Map, with its mappingKey.
+type Mapped struct {
+	config.MappingConfig
+	Key string
 }
 
 // NewMapper creates a new topic mapper
 func NewMapper(mappings []config.MappingConfig) *Mapper {
-	return &Mapper{
-		mappings: mappings,
+	m := &Mapper{}
+	m.set(mappings)
+	return m
+}
+
+// set replaces the mappings, resetting their runtime state.
+func (m *Mapper) set(mappings []config.MappingConfig) {
+	m.mappings = make([]config.MappingConfig, len(mappings))
+	copy(m.mappings, mappings) // runtime changes must not leak into the caller's config
+	m.keys = mappingKeys(mappings)
+	m.configured = make([]string, len(mappings))
+	for i, mapping := range mappings {
+		m.configured[i] = mapping.MessageFormat
 	}
+	m.added = make([]bool, len(mappings))
+	m.paused = make([]bool, len(mappings))
 }
 
-// Map finds all matching mapping configs for a given MQTT topic
-func (m *Mapper) Map(topic string) []config.MappingConfig {
-	var results []config.MappingConfig
+// mappingKey identifies a mapping by mqtt_topic and its position among
+// mappings with that topic, so it survives other mappings being added,
+// removed or reordered by a reload.
+func mappingKey(topic string, nth int) string {
+	return fmt.Sprintf("%s#%d", topic, nth)
+}
 
-	for _, mapping := range m.mappings {
-		if m.matchTopic(topic, mapping.MQTTTopic) {
-			results = append(results, mapping)
+// mappingKeys returns the mappingKey of each mapping.
+func mappingKeys(mappings []config.MappingConfig) []string {
+	keys := make([]string, len(mappings))
+	seen := make(map[string]int)
+	for i, mapping := range mappings {
+		keys[i] = mappingKey(mapping.MQTTTopic, seen[mapping.MQTTTopic])
+		seen[mapping.MQTTTopic]++
+	}
+	return keys
+}
+
+// Map finds all active mapping configs matching a given MQTT topic and payload
+// size. Matches are returned in config order, which is the order they are sent in.
+func (m *Mapper) Map(topic string, size int) []Mapped {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var results []Mapped
+
+	for i, mapping := range m.mappings {
+		if m.paused[i] {
+			continue
+		}
+		if m.matchTopic(topic, mapping.MQTTTopic) && matchSize(size, mapping) {
+			results = append(results, Mapped{MappingConfig: mapping, Key: m.keys[i]})
 		}
 	}
 
 	return results
 }
 
+// Info returns the mappings and their runtime state.
+func (m *Mapper) Info() []types.MappingInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	infos := make([]types.MappingInfo, len(m.mappings))
+	for i, mapping := range m.mappings {
+		infos[i] = types.MappingInfo{
+			Index:    i + 1,
+			Topic:    mapping.MQTTTopic,
+			Channels: mapping.IRCChannels,
+			Tenant:   mapping.Tenant,
+			Paused:   m.paused[i],
+			Format:   mapping.MessageFormat,
+			Changed:  mapping.MessageFormat != m.configured[i],
+			Added:    m.added[i],
+		}
+	}
+	return infos
+}
+
+// SetPaused pauses or resumes the mapping with the given 1-based index.
+func (m *Mapper) SetPaused(index int, paused bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if index < 1 || index > len(m.mappings) {
+		return fmt.Errorf("no mapping #%d", index)
+	}
+	m.paused[index-1] = paused
+	return nil
+}
+
+// SetFormat replaces the message_format of the mapping with the given 1-based index.
+func (m *Mapper) SetFormat(index int, format string) error {
+	if _, err := template.New("message").Funcs(irc.Funcs).Parse(format); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if index < 1 || index > len(m.mappings) {
+		return fmt.Errorf("no mapping #%d", index)
+	}
+	m.mappings[index-1].MessageFormat = format
+	irc.ResetTemplates()
+	return nil
+}
+
+// Add appends a mapping and returns its 1-based index.
+func (m *Mapper) Add(mapping config.MappingConfig) (int, error) {
+	if _, err := template.New("message").Funcs(irc.Funcs).Parse(mapping.MessageFormat); err != nil {
+		return 0, fmt.Errorf("invalid template: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	nth := 0
+	for _, existing := range m.mappings {
+		if existing.MQTTTopic == mapping.MQTTTopic {
+			nth++
+		}
+	}
+	m.mappings = append(m.mappings, mapping)
+	m.keys = append(m.keys, mappingKey(mapping.MQTTTopic, nth))
+	m.configured = append(m.configured, mapping.MessageFormat)
+	m.added = append(m.added, true)
+	m.paused = append(m.paused, false)
+	return len(m.mappings), nil
+}
+
+// Replace swaps in a new set of mappings (config reload). Mappings with the
+// same mappingKey keep their paused state; runtime format changes and
+// mappings added at runtime are discarded.
+func (m *Mapper) Replace(mappings []config.MappingConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	paused := make(map[string]bool)
+	for i, key := range m.keys {
+		paused[key] = m.paused[i]
+	}
+
+	m.set(mappings)
+	irc.ResetTemplates()
+	for i, key := range m.keys {
+		m.paused[i] = paused[key]
+	}
+}
+
+// matchSize checks the payload size against the mapping's min_bytes/max_bytes
+func matchSize(size int, mapping config.MappingConfig) bool {
+	if mapping.MinBytes > 0 && size < mapping.MinBytes {
+		return false
+	}
+	if mapping.MaxBytes > 0 && size > mapping.MaxBytes {
+		return false
+	}
+	return true
+}
+
 // matchTopic checks if an MQTT topic matches a pattern
 // Supports MQTT wildcards: + (single level), # (multi level)
 func (m *Mapper) matchTopic(topic, pattern string) bool {
@@ -82,6 +234,29 @@ func (m *Mapper) matchParts(topicParts, patternParts []string) bool {
 	return false
 }
 
+// covers reports whether a subscription to sub receives every topic matching
+// pattern.
+func covers(sub, pattern string) bool {
+	subParts := strings.Split(sub, "/")
+	patternParts := strings.Split(pattern, "/")
+	for i, part := range subParts {
+		if part == "#" {
+			return true
+		}
+		if i >= len(patternParts) {
+			return false
+		}
+		switch p := patternParts[i]; {
+		case p == "#":
+			return false
+		case part == "+":
+		case part != p:
+			return false
+		}
+	}
+	return len(subParts) == len(patternParts)
+}
+
 // IsValidPattern checks if a pattern is valid MQTT topic pattern
 func IsValidPattern(pattern string) bool {
 	if pattern == "" {
@@ -1,6 +1,7 @@
 package bridge
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/dyuri/mqtt2irc/internal/config"
@@ -115,7 +116,7 @@ func TestMap(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			results := mapper.Map(tt.topic)
+			results := mapper.Map(tt.topic, 0)
 			if len(results) != tt.expectedCount {
 				t.Errorf("Map(%q) returned %d results, want %d",
 					tt.topic, len(results), tt.expectedCount)
@@ -129,3 +130,163 @@ func TestMap(t *testing.T) {
 		})
 	}
 }
+
+func TestMapPayloadSize(t *testing.T) {
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "camera/+/snapshot", IRCChannels: []string{"#inline"}, MaxBytes: 1024},
+		{MQTTTopic: "camera/+/snapshot", IRCChannels: []string{"#links"}, MinBytes: 1025},
+		{MQTTTopic: "camera/#", IRCChannels: []string{"#all"}},
+	}
+	mapper := NewMapper(mappings)
+
+	tests := []struct {
+		name     string
+		size     int
+		channels []string
+	}{
+		{"empty payload", 0, []string{"#inline", "#all"}},
+		{"at max_bytes", 1024, []string{"#inline", "#all"}},
+		{"at min_bytes", 1025, []string{"#links", "#all"}},
+		{"large payload", 500000, []string{"#links", "#all"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := mapper.Map("camera/door/snapshot", tt.size)
+			var got []string
+			for _, r := range results {
+				got = append(got, r.IRCChannels[0])
+			}
+			if strings.Join(got, ",") != strings.Join(tt.channels, ",") {
+				t.Errorf("Map(size=%d) channels = %v, want %v", tt.size, got, tt.channels)
+			}
+		})
+	}
+}
+
+func TestMapperPauseAndFormat(t *testing.T) {
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "sensors/#", IRCChannels: []string{"#sensors"}, MessageFormat: "{{.Payload}}"},
+		{MQTTTopic: "sensors/temp", IRCChannels: []string{"#temp"}, Tenant: "home"},
+	}
+	mapper := NewMapper(mappings)
+
+	if err := mapper.SetPaused(1, true); err != nil {
+		t.Fatalf("SetPaused: %v", err)
+	}
+	results := mapper.Map("sensors/temp", 0)
+	if len(results) != 1 || results[0].IRCChannels[0] != "#temp" {
+		t.Errorf("paused mapping still matched: %v", results)
+	}
+
+	if err := mapper.SetFormat(2, "T={{.Payload}}"); err != nil {
+		t.Fatalf("SetFormat: %v", err)
+	}
+	if got := mapper.Map("sensors/temp", 0)[0].MessageFormat; got != "T={{.Payload}}" {
+		t.Errorf("MessageFormat = %q", got)
+	}
+	if mappings[1].MessageFormat != "" {
+		t.Error("SetFormat modified the caller's config")
+	}
+
+	info := mapper.Info()
+	if !info[0].Paused || info[1].Tenant != "home" || info[1].Index != 2 {
+		t.Errorf("Info() = %+v", info)
+	}
+
+	if err := mapper.SetPaused(3, true); err == nil {
+		t.Error("expected error for unknown mapping")
+	}
+	if err := mapper.SetFormat(1, "{{.Payload"); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
+
+func TestMapperReplace(t *testing.T) {
+	m := NewMapper([]config.MappingConfig{
+		{MQTTTopic: "a/#", IRCChannels: []string{"#a"}},
+		{MQTTTopic: "b/#", IRCChannels: []string{"#b"}},
+	})
+	if err := m.SetPaused(2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Replace([]config.MappingConfig{
+		{MQTTTopic: "b/#", IRCChannels: []string{"#b2"}},
+		{MQTTTopic: "c/#", IRCChannels: []string{"#c"}},
+	})
+
+	infos := m.Info()
+	if len(infos) != 2 || infos[0].Topic != "b/#" || infos[1].Topic != "c/#" {
+		t.Fatalf("Info() after Replace = %+v", infos)
+	}
+	if !infos[0].Paused || infos[1].Paused {
+		t.Errorf("paused state not carried over by topic: %+v", infos)
+	}
+	if got := m.Map("a/x", 0); len(got) != 0 {
+		t.Errorf("removed mapping still matches: %+v", got)
+	}
+}
+
+func BenchmarkMapperMap(b *testing.B) {
+	var mappings []config.MappingConfig
+	for _, room := range []string{"kitchen", "bedroom", "garage", "office", "attic"} {
+		mappings = append(mappings,
+			config.MappingConfig{MQTTTopic: "home/" + room + "/temperature", IRCChannels: []string{"#home"}},
+			config.MappingConfig{MQTTTopic: "home/" + room + "/+/state", IRCChannels: []string{"#home"}},
+		)
+	}
+	mappings = append(mappings,
+		config.MappingConfig{MQTTTopic: "zigbee2mqtt/#", IRCChannels: []string{"#zigbee"}},
+		config.MappingConfig{MQTTTopic: "alerts/+", IRCChannels: []string{"#alerts"}},
+	)
+	mapper := NewMapper(mappings)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mapper.Map("home/garage/door/state", 64)
+	}
+}
+
+func TestMap_ConfigOrder(t *testing.T) {
+	mapper := NewMapper([]config.MappingConfig{
+		{MQTTTopic: "sensors/#", IRCChannels: []string{"#all"}},
+		{MQTTTopic: "sensors/temp", IRCChannels: []string{"#exact"}},
+		{MQTTTopic: "other/#", IRCChannels: []string{"#other"}},
+		{MQTTTopic: "+/temp", IRCChannels: []string{"#plus"}},
+	})
+
+	// Stable across calls, regardless of pattern specificity.
+	for i := 0; i < 10; i++ {
+		var got []string
+		for _, m := range mapper.Map("sensors/temp", 0) {
+			got = append(got, m.MQTTTopic)
+		}
+		want := []string{"sensors/#", "sensors/temp", "+/temp"}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Fatalf("Map() order = %v, want config order %v", got, want)
+		}
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		sub, pattern string
+		want         bool
+	}{
+		{"sensors/#", "sensors/+/temp", true},
+		{"#", "anything/#", true},
+		{"sensors/+/temp", "sensors/+/temp", true},
+		{"sensors/+/temp", "sensors/kitchen/temp", true},
+		{"sensors/+", "sensors/#", false},
+		{"sensors/kitchen/temp", "sensors/+/temp", false},
+		{"sensors/+", "sensors/a/b", false},
+		{"sensors/a/b", "sensors/a", false},
+		{"sensors/#", "alerts/x", false},
+	}
+	for _, tt := range tests {
+		if got := covers(tt.sub, tt.pattern); got != tt.want {
+			t.Errorf("covers(%q, %q) = %v, want %v", tt.sub, tt.pattern, got, tt.want)
+		}
+	}
+}
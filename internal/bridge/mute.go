@@ -0,0 +1,200 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// muteRule suppresses messages of a topic pattern or a mesh node until it expires.
+type muteRule struct {
+	target     string
+	node       uint32 // set for node rules
+	isNode     bool
+	until      time.Time
+	suppressed int
+}
+
+// muteList holds runtime suppression rules. Rules are not persisted and
+// expire on their own.
+type muteList struct {
+	mu    sync.Mutex
+	clock schedule.Clock
+	rules []*muteRule
+}
+
+func newMuteList(clock schedule.Clock) *muteList {
+	return &muteList{clock: clock}
+}
+
+// parseNode parses a mesh node ID given as !xxxxxxxx (hex) or as a decimal number.
+func parseNode(s string) (uint32, bool) {
+	if strings.HasPrefix(s, "!") {
+		n, err := strconv.ParseUint(s[1:], 16, 32)
+		return uint32(n), err == nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	return uint32(n), err == nil
+}
+
+// add mutes target (a topic pattern or a node ID) for d, replacing an
+// existing rule for the same target.
+func (l *muteList) add(target string, d time.Duration) (time.Time, error) {
+	if d <= 0 {
+		return time.Time{}, fmt.Errorf("duration must be positive")
+	}
+	rule := &muteRule{target: target, until: l.clock.Now().Add(d)}
+	if node, ok := parseNode(target); ok {
+		rule.node, rule.isNode = node, true
+		rule.target = fmt.Sprintf("!%08x", node)
+	} else if !IsValidPattern(target) {
+		return time.Time{}, fmt.Errorf("%q is neither a topic pattern nor a node ID", target)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	for i, r := range l.rules {
+		if r.target == rule.target {
+			l.rules[i] = rule
+			return rule.until, nil
+		}
+	}
+	l.rules = append(l.rules, rule)
+	return rule.until, nil
+}
+
+// remove deletes the rule for target and reports whether there was one.
+func (l *muteList) remove(target string) bool {
+	if node, ok := parseNode(target); ok {
+		target = fmt.Sprintf("!%08x", node)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, r := range l.rules {
+		if r.target == target {
+			l.rules = append(l.rules[:i], l.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the active rules.
+func (l *muteList) list() []types.Mute {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire()
+	mutes := make([]types.Mute, len(l.rules))
+	for i, r := range l.rules {
+		mutes[i] = types.Mute{Target: r.target, Until: r.until, Suppressed: r.suppressed}
+	}
+	return mutes
+}
+
+// expire drops rules past their end time. Must be called with mu held.
+func (l *muteList) expire() {
+	now := l.clock.Now()
+	kept := l.rules[:0]
+	for _, r := range l.rules {
+		if now.Before(r.until) {
+			kept = append(kept, r)
+		}
+	}
+	l.rules = kept
+}
+
+// muted reports whether msg matches an active rule and counts it. A nil list
+// mutes nothing.
+func (l *muteList) muted(msg types.Message, match func(topic, pattern string) bool) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.rules) == 0 {
+		return false
+	}
+	l.expire()
+
+	var node uint32
+	var nodeParsed, hasNode bool
+	for _, r := range l.rules {
+		if !r.isNode {
+			if match(msg.Topic, r.target) {
+				r.suppressed++
+				return true
+			}
+			continue
+		}
+		if !nodeParsed {
+			node, hasNode = payloadNode(msg.Payload)
+			nodeParsed = true
+		}
+		if hasNode && node == r.node {
+			r.suppressed++
+			return true
+		}
+	}
+	return false
+}
+
+// payloadNode extracts the sending node from a Meshtastic JSON payload: the
+// numeric "from" field, or the "sender" field (!xxxxxxxx).
+func payloadNode(payload []byte) (uint32, bool) {
+	if len(payload) == 0 || payload[0] != '{' {
+		return 0, false
+	}
+	var data map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return 0, false
+	}
+	switch from := data["from"].(type) {
+	case json.Number:
+		if n, ok := parseNode(from.String()); ok {
+			return n, true
+		}
+	case string:
+		if n, ok := parseNode(from); ok {
+			return n, true
+		}
+	}
+	if sender, ok := data["sender"].(string); ok {
+		return parseNode(sender)
+	}
+	return 0, false
+}
+
+// Mute suppresses messages of a topic pattern or mesh node (!xxxxxxxx) for d
+// and returns when the rule expires (implements admin.BridgeAdmin).
+func (b *Bridge) Mute(target string, d time.Duration) (time.Time, error) {
+	until, err := b.mutes.add(target, d)
+	if err != nil {
+		return time.Time{}, err
+	}
+	b.logger.Info().Str("target", target).Time("until", until).Msg("muted")
+	return until, nil
+}
+
+// Unmute removes the rule for target (implements admin.BridgeAdmin).
+func (b *Bridge) Unmute(target string) bool {
+	ok := b.mutes.remove(target)
+	if ok {
+		b.logger.Info().Str("target", target).Msg("unmuted")
+	}
+	return ok
+}
+
+// Mutes returns the active mute rules (implements admin.BridgeAdmin).
+func (b *Bridge) Mutes() []types.Mute {
+	return b.mutes.list()
+}
@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestParseNode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint32
+		ok   bool
+	}{
+		{"!a1b2c3d4", 0xa1b2c3d4, true},
+		{"2712847316", 0xa1b2c3d4, true},
+		{"!xyz", 0, false},
+		{"sensors/#", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseNode(tt.in)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseNode(%q) = %x, %v; want %x, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMuteList(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC))
+	l := newMuteList(clock)
+	m := NewMapper(nil)
+
+	if _, err := l.add("sensors/+/temp", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.add("2712847316", 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.add("bad//topic", time.Hour); err == nil {
+		t.Error("invalid target accepted")
+	}
+	if _, err := l.add("sensors/#", 0); err == nil {
+		t.Error("zero duration accepted")
+	}
+
+	tests := []struct {
+		name string
+		msg  types.Message
+		want bool
+	}{
+		{"topic match", types.Message{Topic: "sensors/a/temp"}, true},
+		{"other topic", types.Message{Topic: "sensors/a/hum"}, false},
+		{"node from", types.Message{Topic: "msh/x", Payload: []byte(`{"from":2712847316,"type":"text"}`)}, true},
+		{"node sender", types.Message{Topic: "msh/x", Payload: []byte(`{"from":1,"sender":"!a1b2c3d4"}`)}, false},
+		{"other node", types.Message{Topic: "msh/x", Payload: []byte(`{"sender":"!00000001"}`)}, false},
+		{"node sender only", types.Message{Topic: "msh/x", Payload: []byte(`{"sender":"!a1b2c3d4"}`)}, true},
+	}
+	for _, tt := range tests {
+		if got := l.muted(tt.msg, m.matchTopic); got != tt.want {
+			t.Errorf("%s: muted = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	mutes := l.list()
+	if len(mutes) != 2 || mutes[0].Suppressed != 1 || mutes[1].Target != "!a1b2c3d4" || mutes[1].Suppressed != 2 {
+		t.Errorf("list() = %+v", mutes)
+	}
+
+	// The topic rule expires after an hour; the node rule is still active.
+	clock.Advance(time.Hour)
+	if l.muted(types.Message{Topic: "sensors/a/temp"}, m.matchTopic) {
+		t.Error("expired rule still mutes")
+	}
+	if len(l.list()) != 1 {
+		t.Errorf("list() after expiry = %+v", l.list())
+	}
+
+	if !l.remove("!A1B2C3D4") || l.remove("!a1b2c3d4") {
+		t.Error("remove by node ID failed")
+	}
+
+	var nilList *muteList
+	if nilList.muted(types.Message{Topic: "x"}, m.matchTopic) {
+		t.Error("nil list muted a message")
+	}
+}
@@ -0,0 +1,59 @@
+package bridge
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// Channel send orders (bridge.channel_order). Mappings are always evaluated
+// in config order; this only changes the order in which a mapping's channels
+// are sent to, and so which channel waits longest on the rate limiter.
+const (
+	ChannelOrderConfig     = "config"      // irc_channels order (default)
+	ChannelOrderRoundRobin = "round_robin" // rotate the first channel on every message
+	ChannelOrderRandom     = "random"      // shuffle on every message
+)
+
+// channelOrder decides the send order of a mapping's channels. A nil
+// *channelOrder keeps the config order.
+type channelOrder struct {
+	mode    string
+	mu      sync.Mutex
+	next    map[string]int // mapping topic → rotation offset (round_robin)
+	shuffle func(n int, swap func(i, j int))
+}
+
+func newChannelOrder(mode string) *channelOrder {
+	if mode == "" || mode == ChannelOrderConfig {
+		return nil
+	}
+	return &channelOrder{
+		mode:    mode,
+		next:    make(map[string]int),
+		shuffle: rand.Shuffle,
+	}
+}
+
+// channels returns the channels of the mapping identified by key in the order
+// they should be sent to. The input slice is never modified.
+func (o *channelOrder) channels(key string, channels []string) []string {
+	if o == nil || len(channels) < 2 {
+		return channels
+	}
+	ordered := make([]string, len(channels))
+	switch o.mode {
+	case ChannelOrderRoundRobin:
+		o.mu.Lock()
+		offset := o.next[key] % len(channels)
+		o.next[key] = offset + 1
+		o.mu.Unlock()
+		copy(ordered, channels[offset:])
+		copy(ordered[len(channels)-offset:], channels[:offset])
+	case ChannelOrderRandom:
+		copy(ordered, channels)
+		o.shuffle(len(ordered), func(i, j int) { ordered[i], ordered[j] = ordered[j], ordered[i] })
+	default:
+		return channels
+	}
+	return ordered
+}
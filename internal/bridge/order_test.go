@@ -0,0 +1,66 @@
+package bridge
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannelOrder_Config(t *testing.T) {
+	channels := []string{"#a", "#b", "#c"}
+	for _, mode := range []string{"", ChannelOrderConfig} {
+		o := newChannelOrder(mode)
+		for i := 0; i < 3; i++ {
+			if got := o.channels("m", channels); !reflect.DeepEqual(got, channels) {
+				t.Errorf("mode %q: channels() = %v, want config order %v", mode, got, channels)
+			}
+		}
+	}
+}
+
+func TestChannelOrder_RoundRobin(t *testing.T) {
+	o := newChannelOrder(ChannelOrderRoundRobin)
+	channels := []string{"#a", "#b", "#c"}
+
+	want := [][]string{
+		{"#a", "#b", "#c"},
+		{"#b", "#c", "#a"},
+		{"#c", "#a", "#b"},
+		{"#a", "#b", "#c"},
+	}
+	for i, w := range want {
+		if got := o.channels("m1", channels); !reflect.DeepEqual(got, w) {
+			t.Errorf("message %d: channels() = %v, want %v", i, got, w)
+		}
+	}
+
+	// Each mapping rotates on its own.
+	if got := o.channels("m2", channels); !reflect.DeepEqual(got, channels) {
+		t.Errorf("other mapping: channels() = %v, want %v", got, channels)
+	}
+	if !reflect.DeepEqual(channels, []string{"#a", "#b", "#c"}) {
+		t.Errorf("input modified: %v", channels)
+	}
+}
+
+func TestChannelOrder_Random(t *testing.T) {
+	o := newChannelOrder(ChannelOrderRandom)
+	o.shuffle = func(n int, swap func(i, j int)) { swap(0, n-1) } // deterministic
+	channels := []string{"#a", "#b", "#c"}
+
+	got := o.channels("m", channels)
+	if want := []string{"#c", "#b", "#a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("channels() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(channels, []string{"#a", "#b", "#c"}) {
+		t.Errorf("input modified: %v", channels)
+	}
+}
+
+func TestChannelOrder_SingleChannel(t *testing.T) {
+	o := newChannelOrder(ChannelOrderRoundRobin)
+	for i := 0; i < 3; i++ {
+		if got := o.channels("m", []string{"#only"}); !reflect.DeepEqual(got, []string{"#only"}) {
+			t.Errorf("channels() = %v, want [#only]", got)
+		}
+	}
+}
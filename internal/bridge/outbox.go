@@ -0,0 +1,179 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// outbound is one formatted line waiting in a channel's outbox.
+type outbound struct {
+	msg     types.Message
+	mapping string // mappingKey; "" for lines not formatted by a mapping
+	tenant  string
+	channel string
+	text    string
+	tr      *trace
+}
+
+// outboxes gives every IRC channel its own bounded queue and sender
+// goroutine (bridge.channel_queue_size). Senders still share the IRC rate
+// limiter, but each waits for it with at most one line at a time, so a
+// channel with a long backlog no longer holds up lines for other channels.
+// A nil *outboxes sends inline.
+type outboxes struct {
+	size    int
+	deliver func(ctx context.Context, out outbound)
+
+	mu     sync.Mutex
+	ctx    context.Context
+	queues map[string]chan outbound
+	queued map[string][]outbound // the content of queues, for listing
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func newOutboxes(size int, deliver func(ctx context.Context, out outbound)) *outboxes {
+	if size <= 0 {
+		return nil
+	}
+	return &outboxes{
+		size:    size,
+		deliver: deliver,
+		ctx:     context.Background(),
+		queues:  make(map[string]chan outbound),
+		queued:  make(map[string][]outbound),
+	}
+}
+
+// start sets the context senders deliver with; they stop once it is done.
+func (o *outboxes) start(ctx context.Context) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ctx = ctx
+}
+
+// enqueue adds out to its channel's outbox, starting the channel's sender on
+// first use. It reports false if the outbox is full or closed.
+func (o *outboxes) enqueue(out outbound) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return false
+	}
+	q, ok := o.queues[out.channel]
+	if !ok {
+		q = make(chan outbound, o.size)
+		o.queues[out.channel] = q
+		o.wg.Add(1)
+		go o.run(o.ctx, q)
+	}
+	select {
+	case q <- out:
+		o.queued[out.channel] = append(o.queued[out.channel], out)
+		return true
+	default:
+		return false
+	}
+}
+
+func (o *outboxes) run(ctx context.Context, q chan outbound) {
+	defer o.wg.Done()
+	for out := range q {
+		o.taken(out.channel)
+		if ctx.Err() != nil {
+			return
+		}
+		o.deliver(ctx, out)
+	}
+}
+
+// taken removes the line a sender took from its queue from the listing.
+func (o *outboxes) taken(channel string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if q := o.queued[channel]; len(q) > 0 {
+		q[0] = outbound{}
+		o.queued[channel] = q[1:]
+	}
+}
+
+// list returns the queued lines of every channel, in channel order.
+func (o *outboxes) list() []outbound {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	channels := make([]string, 0, len(o.queued))
+	for channel := range o.queued {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	var lines []outbound
+	for _, channel := range channels {
+		lines = append(lines, o.queued[channel]...)
+	}
+	return lines
+}
+
+// close stops accepting lines and waits for the senders to finish the lines
+// already queued (or for their context to end).
+func (o *outboxes) close() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		for _, q := range o.queues {
+			close(q)
+		}
+	}
+	o.mu.Unlock()
+	o.wg.Wait()
+}
+
+// lengths returns the number of queued lines per channel.
+func (o *outboxes) lengths() map[string]int {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	lengths := make(map[string]int, len(o.queues))
+	for channel, q := range o.queues {
+		lengths[channel] = len(q)
+	}
+	return lengths
+}
+
+// writeMetrics writes the outbox lengths in the Prometheus text format.
+func (o *outboxes) writeMetrics(w io.Writer) error {
+	if o == nil {
+		return nil
+	}
+	lengths := o.lengths()
+	channels := make([]string, 0, len(lengths))
+	for channel := range lengths {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	var sb strings.Builder
+	sb.WriteString("# HELP mqtt2irc_channel_queue_length Lines waiting in a channel's outbound queue.\n")
+	sb.WriteString("# TYPE mqtt2irc_channel_queue_length gauge\n")
+	for _, channel := range channels {
+		fmt.Fprintf(&sb, "mqtt2irc_channel_queue_length{channel=%s} %d\n", promLabel(channel), lengths[channel])
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,143 @@
+package bridge
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutboxes_Disabled(t *testing.T) {
+	if o := newOutboxes(0, nil); o != nil {
+		t.Fatalf("newOutboxes(0) = %v, want nil", o)
+	}
+	var o *outboxes
+	o.start(context.Background())
+	o.close()
+	if o.lengths() != nil {
+		t.Error("nil outboxes should report no lengths")
+	}
+}
+
+func TestOutboxes_ChannelsIndependent(t *testing.T) {
+	release := make(chan struct{})
+	delivered := make(chan string, 10)
+	o := newOutboxes(10, func(ctx context.Context, out outbound) {
+		if out.channel == "#flood" {
+			<-release
+		}
+		delivered <- out.channel + " " + out.text
+	})
+	o.start(context.Background())
+
+	for _, text := range []string{"1", "2", "3"} {
+		o.enqueue(outbound{channel: "#flood", text: text})
+	}
+	o.enqueue(outbound{channel: "#alerts", text: "fire"})
+
+	// #alerts goes out while #flood's sender is stuck.
+	select {
+	case got := <-delivered:
+		if got != "#alerts fire" {
+			t.Fatalf("first delivery = %q, want #alerts fire", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("#alerts line held up by #flood backlog")
+	}
+
+	close(release)
+	o.close()
+	close(delivered)
+	var got []string
+	for d := range delivered {
+		got = append(got, d)
+	}
+	if want := "#flood 1,#flood 2,#flood 3"; strings.Join(got, ",") != want {
+		t.Errorf("#flood deliveries = %v, want %s (in order)", got, want)
+	}
+}
+
+func TestOutboxes_Full(t *testing.T) {
+	release := make(chan struct{})
+	o := newOutboxes(1, func(ctx context.Context, out outbound) { <-release })
+	o.start(context.Background())
+	defer func() {
+		close(release)
+		o.close()
+	}()
+
+	// One line in flight, one queued; the next does not fit.
+	accepted := 0
+	for i := 0; i < 5; i++ {
+		if o.enqueue(outbound{channel: "#c"}) {
+			accepted++
+		}
+	}
+	if accepted < 1 || accepted > 2 {
+		t.Errorf("accepted %d lines, want 1 or 2 with a queue of 1", accepted)
+	}
+	if !o.enqueue(outbound{channel: "#other"}) {
+		t.Error("a full #c queue must not affect #other")
+	}
+}
+
+func TestOutboxes_CloseDrains(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	o := newOutboxes(10, func(ctx context.Context, out outbound) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, out.text)
+	})
+	o.start(context.Background())
+	for _, text := range []string{"a", "b", "c"} {
+		o.enqueue(outbound{channel: "#c", text: text})
+	}
+	o.close()
+
+	if strings.Join(got, "") != "abc" {
+		t.Errorf("delivered %v before close returned, want a b c", got)
+	}
+	if o.enqueue(outbound{channel: "#c", text: "late"}) {
+		t.Error("enqueue after close should fail")
+	}
+}
+
+func TestOutboxes_ContextCancelStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	delivered := 0
+	o := newOutboxes(10, func(ctx context.Context, out outbound) { delivered++ })
+	o.start(ctx)
+	cancel()
+	o.enqueue(outbound{channel: "#c"})
+	o.close()
+	if delivered != 0 {
+		t.Errorf("delivered %d lines after the context ended, want 0", delivered)
+	}
+}
+
+func TestOutboxes_WriteMetrics(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	o := newOutboxes(5, func(ctx context.Context, out outbound) {
+		started <- struct{}{}
+		<-release
+	})
+	o.start(context.Background())
+	for i := 0; i < 3; i++ {
+		o.enqueue(outbound{channel: "#b"})
+	}
+
+	// The sender holds the first line; two wait in the queue.
+	<-started
+	var sb strings.Builder
+	if err := o.writeMetrics(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), `mqtt2irc_channel_queue_length{channel="#b"} 2`) {
+		t.Errorf("metrics = %q, want #b queue length 2", sb.String())
+	}
+	close(release)
+	o.close()
+}
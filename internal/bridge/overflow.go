@@ -0,0 +1,94 @@
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+// channelBudget limits how many messages each channel receives per minute.
+// Messages over a channel's budget go to the overflow channel when one is
+// configured; when that is over budget too they are counted and summarized.
+type channelBudget struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	overflow string
+	limiters map[string]*rate.Limiter // lower-cased channel → limiter
+	dropped  map[string]int           // channel → messages lost since the last summary
+}
+
+// newChannelBudget returns nil when no per-channel budget is configured.
+func newChannelBudget(cfg config.ChannelRateConfig, overflow string) *channelBudget {
+	if cfg.MessagesPerMinute <= 0 {
+		return nil
+	}
+	return &channelBudget{
+		limit:    rate.Limit(cfg.MessagesPerMinute / 60),
+		burst:    cfg.Burst,
+		overflow: overflow,
+		limiters: make(map[string]*rate.Limiter),
+		dropped:  make(map[string]int),
+	}
+}
+
+// allow takes a token from channel's budget. Must be called with mu held.
+func (cb *channelBudget) allow(channel string, now time.Time) bool {
+	key := strings.ToLower(channel)
+	l, ok := cb.limiters[key]
+	if !ok {
+		l = rate.NewLimiter(cb.limit, cb.burst)
+		cb.limiters[key] = l
+	}
+	return l.AllowN(now, 1)
+}
+
+// route decides where a message for channel goes: the channel itself, the
+// overflow channel (with the original channel as prefix), or nowhere (ok is
+// false). summary, when non-empty, reports earlier losses and should be sent
+// to the overflow channel first. A nil budget always passes.
+func (cb *channelBudget) route(channel, message string, now time.Time) (target, text, summary string, ok bool) {
+	if cb == nil {
+		return channel, message, "", true
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.allow(channel, now) {
+		return channel, message, "", true
+	}
+	if cb.overflow == "" || strings.EqualFold(channel, cb.overflow) || !cb.allow(cb.overflow, now) {
+		cb.dropped[channel]++
+		return "", "", "", false
+	}
+
+	if len(cb.dropped) > 0 {
+		parts := make([]string, 0, len(cb.dropped))
+		for ch, n := range cb.dropped {
+			parts = append(parts, fmt.Sprintf("%s: %d", ch, n))
+		}
+		sort.Strings(parts)
+		summary = fmt.Sprintf("[overflow] messages dropped while over budget — %s", strings.Join(parts, ", "))
+		cb.dropped = make(map[string]int)
+	}
+	return cb.overflow, fmt.Sprintf("[%s] %s", channel, message), summary, true
+}
+
+// prefixed returns lines with prefix prepended (re-fitted to the IRC line
+// limit), or lines unchanged for an empty prefix.
+func (b *Bridge) prefixed(prefix string, lines []string) []string {
+	if prefix == "" {
+		return lines
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = b.limits.Fit(prefix + line)
+	}
+	return out
+}
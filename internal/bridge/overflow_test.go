@@ -0,0 +1,123 @@
+package bridge
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestChannelBudget_Route(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	cb := newChannelBudget(config.ChannelRateConfig{MessagesPerMinute: 1, Burst: 1}, "#overflow")
+
+	steps := []struct {
+		channel     string
+		wantTarget  string
+		wantText    string
+		wantSummary string
+		wantOK      bool
+	}{
+		{"#a", "#a", "m", "", true},
+		{"#a", "#overflow", "[#a] m", "", true},
+		{"#a", "", "", "", false}, // overflow over budget as well
+		{"#b", "#b", "m", "", true},
+	}
+	for i, s := range steps {
+		target, text, summary, ok := cb.route(s.channel, "m", now)
+		if target != s.wantTarget || text != s.wantText || summary != s.wantSummary || ok != s.wantOK {
+			t.Errorf("step %d: route(%s) = %q, %q, %q, %v", i, s.channel, target, text, summary, ok)
+		}
+	}
+
+	// Once the budgets refill, the next redirected message reports the loss.
+	now = now.Add(time.Minute)
+	cb.route("#a", "m", now)
+	target, _, summary, ok := cb.route("#a", "m", now)
+	if !ok || target != "#overflow" || summary != "[overflow] messages dropped while over budget — #a: 1" {
+		t.Errorf("after refill: target=%q summary=%q ok=%v", target, summary, ok)
+	}
+}
+
+func TestChannelBudget_NoOverflow(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	cb := newChannelBudget(config.ChannelRateConfig{MessagesPerMinute: 60, Burst: 2}, "")
+	for i, want := range []bool{true, true, false} {
+		if _, _, _, ok := cb.route("#a", "m", now); ok != want {
+			t.Errorf("message %d: ok = %v, want %v", i, ok, want)
+		}
+	}
+	if _, _, _, ok := cb.route("#a", "m", now.Add(time.Second)); !ok {
+		t.Error("budget did not refill")
+	}
+
+	var none *channelBudget
+	if target, text, _, ok := none.route("#a", "m", now); !ok || target != "#a" || text != "m" {
+		t.Error("nil budget must pass messages through")
+	}
+	if newChannelBudget(config.ChannelRateConfig{}, "#o") != nil {
+		t.Error("budget created without messages_per_minute")
+	}
+}
+
+// TestHandleMessage_QuotaOverflow checks that messages of a tenant over its
+// daily quota go to the overflow channel instead of being dropped.
+func TestHandleMessage_QuotaOverflow(t *testing.T) {
+	for _, overflow := range []string{"#overflow", ""} {
+		clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+		mappings := []config.MappingConfig{
+			{MQTTTopic: "hs/#", IRCChannels: []string{"#hs", "#hs-ops"}, MessageFormat: "{{.Payload}}", Tenant: "hackerspace"},
+		}
+		tenants := []config.TenantConfig{{Name: "hackerspace", Quota: config.TenantQuota{MessagesPerDay: 1}}}
+		var mu sync.Mutex
+		var sent []string
+		b := &Bridge{
+			config: config.BridgeConfig{Mappings: mappings, OverflowChannel: overflow},
+			mapper: NewMapper(mappings),
+			clock:  clock,
+			usage:  newUsageTracker(tenants, time.UTC, clock),
+			mutes:  newMuteList(clock),
+			drops:  stats.NewDrops(),
+			limits: irc.Limits{MaxLength: 400, MaxBytes: 400, Suffix: "..."},
+			logger: zerolog.Nop(),
+		}
+		// Every channel has its own sender goroutine.
+		b.outbox = newOutboxes(10, func(ctx context.Context, out outbound) {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, out.channel+" "+out.text)
+		})
+
+		b.handleMessage(context.Background(), types.Message{Topic: "hs/door", Payload: []byte("open")})
+		b.handleMessage(context.Background(), types.Message{Topic: "hs/door", Payload: []byte("closed")})
+		b.outbox.close()
+
+		sort.Strings(sent)
+		want := []string{"#hs open", "#hs-ops open"}
+		if overflow != "" {
+			want = append(want, "#overflow [#hs,#hs-ops] closed")
+		}
+		if strings.Join(sent, "|") != strings.Join(want, "|") {
+			t.Errorf("overflow %q: sent %q, want %q", overflow, sent, want)
+		}
+		quotaDrops := 0
+		for _, d := range b.drops.Snapshot() {
+			if d.Reason == string(stats.DropQuota) {
+				quotaDrops = int(d.Count)
+			}
+		}
+		if wantDrops := map[bool]int{true: 0, false: 1}[overflow != ""]; quotaDrops != wantDrops {
+			t.Errorf("overflow %q: %d quota drops, want %d", overflow, quotaDrops, wantDrops)
+		}
+	}
+}
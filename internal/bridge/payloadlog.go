@@ -0,0 +1,113 @@
+package bridge
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// payloadLog decides which message payloads are logged at debug level and
+// how much of them (logging.payloads). A nil *payloadLog logs every payload
+// whole.
+type payloadLog struct {
+	cfg config.PayloadLogConfig
+
+	mu      sync.Mutex
+	seen    uint64    // matching messages, for sample
+	minute  time.Time // start of the current max_per_minute window
+	logged  int       // payloads logged in the current window
+	skipped int       // payloads not logged since the last logged one
+}
+
+// newPayloadLog returns nil unless logging.payloads limits anything.
+func newPayloadLog(cfg config.PayloadLogConfig) *payloadLog {
+	if cfg.Sample <= 1 && cfg.MaxPerMinute == 0 && cfg.MaxBytes == 0 && len(cfg.Topics) == 0 {
+		return nil
+	}
+	return &payloadLog{cfg: cfg}
+}
+
+// allow reports whether to log the payload of a message on topic, and how
+// many payloads of matching topics were skipped since the last logged one.
+func (l *payloadLog) allow(topic string, now time.Time, match func(topic, pattern string) bool) (ok bool, skipped int) {
+	if l == nil {
+		return true, 0
+	}
+	if len(l.cfg.Topics) > 0 {
+		matched := false
+		for _, pattern := range l.cfg.Topics {
+			if match(topic, pattern) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, 0
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seen++
+	if l.cfg.Sample > 1 && (l.seen-1)%uint64(l.cfg.Sample) != 0 {
+		l.skipped++
+		return false, 0
+	}
+	if l.cfg.MaxPerMinute > 0 {
+		if now.Sub(l.minute) >= time.Minute {
+			l.minute, l.logged = now, 0
+		}
+		if l.logged >= l.cfg.MaxPerMinute {
+			l.skipped++
+			return false, 0
+		}
+		l.logged++
+	}
+	skipped, l.skipped = l.skipped, 0
+	return true, skipped
+}
+
+// text returns payload for the log, truncated to max_bytes on a UTF-8
+// boundary.
+func (l *payloadLog) text(payload []byte) string {
+	if l == nil || l.cfg.MaxBytes == 0 || len(payload) <= l.cfg.MaxBytes {
+		return string(payload)
+	}
+	cut := l.cfg.MaxBytes
+	for cut > 0 && !utf8.RuneStart(payload[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s… (%d bytes)", payload[:cut], len(payload))
+}
+
+// logPayload logs the payload and JSON keys of msg at debug level, within
+// the limits of logging.payloads.
+func (b *Bridge) logPayload(msg types.Message) {
+	ok, skipped := b.payloadLog.allow(msg.Topic, b.clock.Now(), b.mapper.matchTopic)
+	if !ok {
+		return
+	}
+	jsonData := irc.MessageJSON(msg)
+	ev := b.logger.Debug().
+		Str("msg_id", msg.ID).
+		Str("topic", msg.Topic).
+		Str("payload", b.payloadLog.text(b.redactor.Payload(msg.Payload)))
+	if skipped > 0 {
+		ev.Int("payloads_skipped", skipped)
+	}
+	if jsonData == nil {
+		ev.Bool("json_parsed", false)
+	} else {
+		keys := make([]string, 0, len(jsonData))
+		for k := range jsonData {
+			keys = append(keys, k)
+		}
+		ev.Bool("json_parsed", true).Strs("json_keys", keys)
+	}
+	ev.Msg("message payload")
+}
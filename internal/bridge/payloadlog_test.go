@@ -0,0 +1,56 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+func TestPayloadLog(t *testing.T) {
+	if newPayloadLog(config.PayloadLogConfig{Sample: 1}) != nil {
+		t.Error("payload log without limits should be nil")
+	}
+	var unlimited *payloadLog
+	if ok, _ := unlimited.allow("a", time.Time{}, nil); !ok || unlimited.text([]byte("abc")) != "abc" {
+		t.Error("nil payload log should log everything whole")
+	}
+
+	m := NewMapper(nil)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newPayloadLog(config.PayloadLogConfig{Sample: 2, MaxPerMinute: 2, Topics: []string{"sensors/#"}})
+	var got []bool
+	var skips []int
+	for i := 0; i < 8; i++ {
+		ok, skipped := l.allow("sensors/temp", now, m.matchTopic)
+		got = append(got, ok)
+		if ok {
+			skips = append(skips, skipped)
+		}
+	}
+	// Every second message, at most two a minute.
+	want := []bool{true, false, true, false, false, false, false, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("allow sequence = %v, want %v", got, want)
+		}
+	}
+	if ok, _ := l.allow("other/topic", now, m.matchTopic); ok {
+		t.Error("payload of a topic not in topics logged")
+	}
+	// The next minute's first sampled message reports the skipped ones.
+	ok, skipped := l.allow("sensors/temp", now.Add(time.Minute), m.matchTopic)
+	if !ok || skipped != 5 || skips[1] != 1 {
+		t.Errorf("allow after a minute = %v, %d skipped (earlier %v)", ok, skipped, skips)
+	}
+}
+
+func TestPayloadLog_Truncate(t *testing.T) {
+	l := newPayloadLog(config.PayloadLogConfig{MaxBytes: 5})
+	if got := l.text([]byte("short")); got != "short" {
+		t.Errorf("text(short) = %q", got)
+	}
+	if got := l.text([]byte("abcdéfgh")); got != "abcd… (9 bytes)" {
+		t.Errorf("text cut in a rune = %q", got)
+	}
+}
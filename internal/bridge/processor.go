@@ -1,15 +1,25 @@
 package bridge
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/dyuri/mqtt2irc/internal/stats"
 	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
 // ProcessResult is returned by a Processor after handling a message.
 type ProcessResult struct {
-	Drop      bool   // if true, discard the message; do not send to IRC
-	Formatted string // if non-empty, use this as the IRC message (skips FormatMessage)
+	Drop      bool             // if true, discard the message; do not send to IRC
+	Formatted string           // if non-empty, use this as the IRC message (skips FormatMessage)
+	Reason    stats.DropReason // why the message was dropped; stats.DropProcessor if empty
+
+	// ChannelFormatted overrides Formatted for some channels (lower-cased),
+	// e.g. ops channels that see real node IDs where others see hashes.
+	ChannelFormatted map[string]string
 }
 
 // Processor is the interface for per-mapping message pre-processors.
@@ -17,22 +27,97 @@ type Processor interface {
 	Process(msg types.Message) (ProcessResult, error)
 }
 
+// StatefulProcessor is implemented by processors whose runtime state is
+// carried over to another host in a state bundle (see state.go).
+type StatefulProcessor interface {
+	Processor
+	ExportState() (json.RawMessage, error)
+	ImportState(state json.RawMessage) error
+}
+
+// FlushingProcessor is implemented by processors that keep state on disk
+// and write it out lazily. Flush is called when the bridge shuts down and
+// before a reload replaces the processor.
+type FlushingProcessor interface {
+	Processor
+	Flush() error
+}
+
+// SnapshottingProcessor is implemented by processors whose on-disk state
+// may be kept in memory between periodic snapshots (bridge.storage).
+// Snapshot writes the state if its snapshot is due; the bridge calls it
+// every snapshotCheck.
+type SnapshottingProcessor interface {
+	Processor
+	Snapshot() error
+}
+
 // ProcessorFactory creates a new Processor from a config map.
 type ProcessorFactory func(config map[string]interface{}) (Processor, error)
 
-var processorRegistry = map[string]ProcessorFactory{}
+// registry holds the registered processor factories. Registration usually
+// happens at startup but may race with bridge creation or reloads.
+var registry = struct {
+	sync.RWMutex
+	factories map[string]ProcessorFactory
+	docs      map[string]types.ComponentDoc
+}{factories: map[string]ProcessorFactory{}, docs: map[string]types.ComponentDoc{}}
 
-// Register adds a ProcessorFactory to the global registry under the given name.
+// Register adds a ProcessorFactory to the global registry under the given
+// name, replacing any factory registered under the same name. Built-in
+// processors are registered by processors.RegisterBuiltins.
 func Register(name string, factory ProcessorFactory) {
-	processorRegistry[name] = factory
+	registry.Lock()
+	defer registry.Unlock()
+	registry.factories[name] = factory
+}
+
+// List returns the names of the registered processors, sorted.
+func List() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Document records the description and options of the processor registered
+// under name, for "mqtt2irc describe processors".
+func Document(name string, doc types.ComponentDoc) {
+	registry.Lock()
+	defer registry.Unlock()
+	doc.Name = name
+	registry.docs[name] = doc
+}
+
+// Docs returns the descriptions of the registered processors, sorted by
+// name. A processor registered without Document has only its name.
+func Docs() []types.ComponentDoc {
+	registry.RLock()
+	defer registry.RUnlock()
+	docs := make([]types.ComponentDoc, 0, len(registry.factories))
+	for name := range registry.factories {
+		doc, ok := registry.docs[name]
+		if !ok {
+			doc = types.ComponentDoc{Name: name}
+		}
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Name < docs[j].Name })
+	return docs
 }
 
 // NewProcessor instantiates a named processor with the given config.
 // Returns an error if the processor name is not registered.
 func NewProcessor(name string, config map[string]interface{}) (Processor, error) {
-	factory, ok := processorRegistry[name]
+	registry.RLock()
+	factory, ok := registry.factories[name]
+	registry.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("unknown processor %q (not registered)", name)
+		return nil, fmt.Errorf("unknown processor %q (registered: %s)", name, strings.Join(List(), ", "))
 	}
 	return factory(config)
 }
@@ -0,0 +1,63 @@
+package bridge
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry_List(t *testing.T) {
+	Register("zz-listtest", func(map[string]interface{}) (Processor, error) { return &reloadTestProcessor{}, nil })
+	Register("aa-listtest", func(map[string]interface{}) (Processor, error) { return &reloadTestProcessor{}, nil })
+
+	names := List()
+	first, last := -1, -1
+	for i, name := range names {
+		switch name {
+		case "aa-listtest":
+			first = i
+		case "zz-listtest":
+			last = i
+		}
+		if i > 0 && names[i-1] > name {
+			t.Errorf("List() not sorted: %v", names)
+		}
+	}
+	if first < 0 || last < 0 {
+		t.Errorf("List() = %v, want both registered processors", names)
+	}
+}
+
+func TestNewProcessor_Unknown(t *testing.T) {
+	_, err := NewProcessor("does-not-exist", nil)
+	if err == nil {
+		t.Fatal("NewProcessor() error = nil, want unknown processor error")
+	}
+	if !strings.Contains(err.Error(), "reloadtest") {
+		t.Errorf("error %q should list the registered processors", err)
+	}
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	Register("concurrent", func(map[string]interface{}) (Processor, error) { return &reloadTestProcessor{}, nil })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Register(fmt.Sprintf("concurrent-%d", i), func(map[string]interface{}) (Processor, error) {
+				return &reloadTestProcessor{}, nil
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := NewProcessor("concurrent", nil); err != nil {
+				t.Errorf("NewProcessor: %v", err)
+			}
+			List()
+		}()
+	}
+	wg.Wait()
+}
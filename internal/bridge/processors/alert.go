@@ -0,0 +1,175 @@
+package processors
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+
+	"github.com/dyuri/mqtt2irc/internal/bridge"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+const (
+	defaultAlertFormat    = "🚨 alert #{{.ID}} {{.Alert}}{{with .Severity}} ({{.}}){{end}}: {{.Message}}"
+	defaultResolvedFormat = "✅ alert #{{.ID}} {{.Alert}} resolved{{if .Acked}} (was acknowledged){{end}}"
+)
+
+// lastAlertID numbers alerts across all alert processors, so an ID given to
+// !ack is unambiguous.
+var lastAlertID atomic.Int64
+
+// alertProcessor announces alarms with an ID an admin can acknowledge with
+// !ack (see bridge.AckingProcessor). Repeats of an acknowledged alert are
+// dropped until it resolves.
+type alertProcessor struct {
+	keyField      string
+	severityField string
+	messageField  string
+	statusField   string
+	format        *template.Template
+	resolved      *template.Template
+
+	mu   sync.Mutex
+	open map[string]*openAlert // by alert key
+	byID map[int]*openAlert
+}
+
+// openAlert is an alert that fired and has not resolved yet.
+type openAlert struct {
+	id    int
+	key   string
+	topic string
+	acked bool
+}
+
+// alertDoc declares the alert processor's processor_config options.
+var alertDoc = types.ComponentDoc{
+	Summary: "Announces alarms with an ID that !ack acknowledges; repeats of acknowledged alerts are dropped until they resolve",
+	Options: []types.OptionDoc{
+		{Name: "key_field", Type: "string", Default: "alert", Description: "JSON field identifying the alert; without it the topic is the key"},
+		{Name: "severity_field", Type: "string", Default: "severity", Description: "JSON field shown as {{.Severity}}"},
+		{Name: "message_field", Type: "string", Default: "message", Description: "JSON field shown as {{.Message}}"},
+		{Name: "status_field", Type: "string", Default: "status", Description: "JSON field whose value resolved, ok, cleared or inactive ends the alert"},
+		{Name: "format", Type: "template", Default: defaultAlertFormat, Description: "Firing alert; fields ID, Alert, Severity, Message, Status, Topic, Acked, JSON"},
+		{Name: "resolved_format", Type: "template", Default: defaultResolvedFormat, Description: "Resolution of an alert"},
+	},
+}
+
+// newAlertProcessor creates an alert processor from a config map.
+func newAlertProcessor(config map[string]interface{}) (bridge.Processor, error) {
+	p := &alertProcessor{
+		keyField:      "alert",
+		severityField: "severity",
+		messageField:  "message",
+		statusField:   "status",
+		open:          make(map[string]*openAlert),
+		byID:          make(map[int]*openAlert),
+	}
+	for key, field := range map[string]*string{
+		"key_field":      &p.keyField,
+		"severity_field": &p.severityField,
+		"message_field":  &p.messageField,
+		"status_field":   &p.statusField,
+	} {
+		if v, ok := config[key]; ok {
+			*field = fmt.Sprintf("%v", v)
+		}
+	}
+	var err error
+	if p.format, err = alertTemplate(config, "format", defaultAlertFormat); err != nil {
+		return nil, err
+	}
+	if p.resolved, err = alertTemplate(config, "resolved_format", defaultResolvedFormat); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func alertTemplate(config map[string]interface{}, key, def string) (*template.Template, error) {
+	text := def
+	if v, ok := config[key]; ok {
+		text = fmt.Sprintf("%v", v)
+	}
+	tmpl, err := template.New(key).Option("missingkey=zero").Funcs(irc.Funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("alert: invalid %s template: %w", key, err)
+	}
+	return tmpl, nil
+}
+
+// Process announces a firing alert with its ID (the same ID for repeats
+// until it resolves), drops repeats of an acknowledged one and announces
+// the resolution of an open one. A payload that is not a JSON object is an
+// alert keyed by its topic, with the payload as message.
+func (p *alertProcessor) Process(msg types.Message) (bridge.ProcessResult, error) {
+	data := map[string]interface{}{"Topic": msg.Topic}
+	key, status := msg.Topic, ""
+	if raw := irc.JSONObject(msg); raw != nil {
+		if k := stringify(raw[p.keyField]); k != "" {
+			key = k
+		}
+		status = strings.ToLower(stringify(raw[p.statusField]))
+		data["Severity"] = stringify(raw[p.severityField])
+		data["Message"] = stringify(raw[p.messageField])
+		data["Status"] = status
+		data["JSON"] = raw
+	} else {
+		data["Message"] = irc.StripMarkers(strings.TrimSpace(string(msg.Payload)))
+	}
+	data["Alert"] = key
+
+	p.mu.Lock()
+	a := p.open[key]
+	tmpl := p.format
+	switch {
+	case isResolved(status):
+		if a == nil {
+			p.mu.Unlock()
+			return bridge.ProcessResult{Drop: true, Reason: stats.DropQuiet}, nil
+		}
+		delete(p.open, key)
+		delete(p.byID, a.id)
+		tmpl = p.resolved
+	case a == nil:
+		a = &openAlert{id: int(lastAlertID.Add(1)), key: key, topic: msg.Topic}
+		p.open[key] = a
+		p.byID[a.id] = a
+	case a.acked:
+		p.mu.Unlock()
+		return bridge.ProcessResult{Drop: true, Reason: stats.DropAcked}, nil
+	}
+	data["ID"], data["Acked"] = a.id, a.acked
+	p.mu.Unlock()
+
+	buf := irc.GetBuffer()
+	defer irc.PutBuffer(buf)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return bridge.ProcessResult{}, fmt.Errorf("alert: template execution failed: %w", err)
+	}
+	return bridge.ProcessResult{Formatted: buf.String()}, nil
+}
+
+// isResolved reports whether an alert's status means it is over.
+func isResolved(status string) bool {
+	switch status {
+	case "resolved", "ok", "cleared", "inactive":
+		return true
+	}
+	return false
+}
+
+// Ack marks the open alert with id as acknowledged.
+func (p *alertProcessor) Ack(id int) (alert, topic string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a := p.byID[id]
+	if a == nil {
+		return "", "", false
+	}
+	a.acked = true
+	return a.key, a.topic, true
+}
@@ -0,0 +1,60 @@
+package processors
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/internal/bridge"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestAlertProcessor(t *testing.T) {
+	proc, err := newAlertProcessor(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("newAlertProcessor: %v", err)
+	}
+	p := proc.(*alertProcessor)
+	process := func(payload string) bridge.ProcessResult {
+		t.Helper()
+		res, err := p.Process(types.Message{Topic: "alarms/server1", Payload: []byte(payload)})
+		if err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+		return res
+	}
+
+	res := process(`{"alert":"disk_full","severity":"critical","message":"/var at 98%"}`)
+	id := int(lastAlertID.Load())
+	if want := fmt.Sprintf("🚨 alert #%d disk_full (critical): /var at 98%%", id); res.Formatted != want {
+		t.Errorf("firing = %q, want %q", res.Formatted, want)
+	}
+	// Repeats keep the ID until acknowledged, then are dropped.
+	if res := process(`{"alert":"disk_full","message":"/var at 99%"}`); res.Formatted != fmt.Sprintf("🚨 alert #%d disk_full: /var at 99%%", id) {
+		t.Errorf("repeat = %q", res.Formatted)
+	}
+	if _, _, ok := p.Ack(id + 1); ok {
+		t.Error("Ack of an unknown ID succeeded")
+	}
+	if alert, topic, ok := p.Ack(id); !ok || alert != "disk_full" || topic != "alarms/server1" {
+		t.Errorf("Ack = %q, %q, %v", alert, topic, ok)
+	}
+	if res := process(`{"alert":"disk_full","message":"/var at 99%"}`); !res.Drop || res.Reason != stats.DropAcked {
+		t.Errorf("acked repeat = %+v", res)
+	}
+
+	if res := process(`{"alert":"disk_full","status":"Resolved"}`); res.Formatted != fmt.Sprintf("✅ alert #%d disk_full resolved (was acknowledged)", id) {
+		t.Errorf("resolved = %q", res.Formatted)
+	}
+	if res := process(`{"alert":"disk_full","status":"resolved"}`); !res.Drop {
+		t.Errorf("resolve of a closed alert = %+v", res)
+	}
+	if _, _, ok := p.Ack(id); ok {
+		t.Error("Ack of a resolved alert succeeded")
+	}
+
+	// A new firing gets a new ID; plain payloads are keyed by topic.
+	if res := process("door open"); res.Formatted != fmt.Sprintf("🚨 alert #%d alarms/server1: door open", id+1) {
+		t.Errorf("plain = %q", res.Formatted)
+	}
+}
@@ -0,0 +1,72 @@
+package processors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyuri/mqtt2irc/internal/alertmanager"
+	"github.com/dyuri/mqtt2irc/internal/bridge"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// alertmanagerProcessor posts the alerts of Alertmanager webhook payloads
+// relayed to MQTT: one line when an alert fires and one when it resolves.
+// The repeats Alertmanager sends every repeat_interval are dropped.
+type alertmanagerProcessor struct {
+	tracker   *alertmanager.Tracker
+	formatter *alertmanager.Formatter
+}
+
+// alertmanagerDoc declares the alertmanager processor's processor_config
+// options.
+var alertmanagerDoc = types.ComponentDoc{
+	Summary: "Posts the alerts of Alertmanager webhook payloads when they fire and resolve; repeats are dropped",
+	Options: []types.OptionDoc{
+		{Name: "format", Type: "template", Default: "bridge.alertmanager default", Description: "Firing alert, with the fields of bridge.alertmanager"},
+		{Name: "resolved_format", Type: "template", Default: "bridge.alertmanager default", Description: "Resolved alert"},
+	},
+}
+
+// newAlertmanagerProcessor creates an Alertmanager webhook processor from a
+// config map.
+func newAlertmanagerProcessor(config map[string]interface{}) (bridge.Processor, error) {
+	format, resolved := "", ""
+	if v, ok := config["format"]; ok {
+		format = fmt.Sprintf("%v", v)
+	}
+	if v, ok := config["resolved_format"]; ok {
+		resolved = fmt.Sprintf("%v", v)
+	}
+	f, err := alertmanager.NewFormatter(format, resolved)
+	if err != nil {
+		return nil, fmt.Errorf("alertmanager: %w", err)
+	}
+	return &alertmanagerProcessor{tracker: alertmanager.NewTracker(), formatter: f}, nil
+}
+
+// Process posts the alerts of a webhook payload that changed state, one per
+// line (see the mapping's max_lines). Other payloads pass through.
+func (p *alertmanagerProcessor) Process(msg types.Message) (bridge.ProcessResult, error) {
+	if irc.JSONObject(msg) == nil {
+		return bridge.ProcessResult{}, nil
+	}
+	alerts, err := alertmanager.ParseWebhook(msg.Payload)
+	if err != nil {
+		return bridge.ProcessResult{}, nil
+	}
+	changes := p.tracker.Update(alerts)
+	if len(changes) == 0 {
+		return bridge.ProcessResult{Drop: true, Reason: stats.DropDedup}, nil
+	}
+	lines := make([]string, 0, len(changes))
+	for _, a := range changes {
+		line, err := p.formatter.Format(a)
+		if err != nil {
+			return bridge.ProcessResult{}, fmt.Errorf("alertmanager: template execution failed: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	return bridge.ProcessResult{Formatted: strings.Join(lines, "\n")}, nil
+}
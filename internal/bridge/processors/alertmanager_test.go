@@ -0,0 +1,45 @@
+package processors
+
+import (
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestAlertmanagerProcessor(t *testing.T) {
+	p, err := newAlertmanagerProcessor(map[string]interface{}{"resolved_format": "OK {{.Name}}"})
+	if err != nil {
+		t.Fatalf("newAlertmanagerProcessor: %v", err)
+	}
+	process := func(payload string) (string, stats.DropReason) {
+		t.Helper()
+		res, err := p.Process(types.Message{Topic: "alertmanager/webhook", Payload: []byte(payload)})
+		if err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+		return res.Formatted, res.Reason
+	}
+
+	firing := `{"alerts": [
+		{"status": "firing", "fingerprint": "a1", "labels": {"alertname": "DiskFull", "severity": "critical"}},
+		{"status": "firing", "fingerprint": "b2", "labels": {"alertname": "HighLoad"}}]}`
+	if got, _ := process(firing); got != "🔥 [critical] DiskFull\n🔥 HighLoad" {
+		t.Errorf("firing = %q", got)
+	}
+	if got, reason := process(firing); got != "" || reason != stats.DropDedup {
+		t.Errorf("repeat = %q, %q", got, reason)
+	}
+	resolved := `{"alerts": [{"status": "resolved", "fingerprint": "a1", "labels": {"alertname": "DiskFull"}}]}`
+	if got, _ := process(resolved); got != "OK DiskFull" {
+		t.Errorf("resolved = %q", got)
+	}
+
+	// Other payloads pass through to the mapping's message_format.
+	if got, reason := process(`{"temperature": 21}`); got != "" || reason != "" {
+		t.Errorf("other JSON = %q, %q", got, reason)
+	}
+	if got, reason := process("plain text"); got != "" || reason != "" {
+		t.Errorf("plain text = %q, %q", got, reason)
+	}
+}
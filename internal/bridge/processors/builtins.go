@@ -0,0 +1,32 @@
+// Package processors provides message pre-processors for the mqtt2irc bridge.
+// Call RegisterBuiltins before creating the bridge to make them available:
+//
+//	processors.RegisterBuiltins()
+//	b, err := bridge.New(cfg, logger)
+package processors
+
+import (
+	"sync"
+
+	"github.com/dyuri/mqtt2irc/internal/bridge"
+)
+
+var registerOnce sync.Once
+
+// RegisterBuiltins registers the built-in processors with the bridge
+// registry. It is safe to call more than once.
+func RegisterBuiltins() {
+	registerOnce.Do(func() {
+		bridge.Register("alert", newAlertProcessor)
+		bridge.Register("alertmanager", newAlertmanagerProcessor)
+		bridge.Register("image", newImageProcessor)
+		bridge.Register("meshtastic", newMeshtasticProcessor)
+		bridge.Register("sys", newSysProcessor)
+
+		bridge.Document("alert", alertDoc)
+		bridge.Document("alertmanager", alertmanagerDoc)
+		bridge.Document("image", imageDoc)
+		bridge.Document("meshtastic", meshtasticDoc)
+		bridge.Document("sys", sysDoc)
+	})
+}
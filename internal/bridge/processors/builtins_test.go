@@ -0,0 +1,39 @@
+package processors
+
+import (
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/internal/bridge"
+)
+
+func TestRegisterBuiltins(t *testing.T) {
+	RegisterBuiltins()
+	RegisterBuiltins() // idempotent
+
+	registered := map[string]bool{}
+	for _, name := range bridge.List() {
+		registered[name] = true
+	}
+	for _, name := range []string{"image", "meshtastic", "sys"} {
+		if !registered[name] {
+			t.Errorf("processor %q not registered, have %v", name, bridge.List())
+		}
+	}
+	if _, err := bridge.NewProcessor("meshtastic", map[string]interface{}{}); err != nil {
+		t.Errorf("NewProcessor(meshtastic): %v", err)
+	}
+}
+
+func TestBuiltinDocs(t *testing.T) {
+	RegisterBuiltins()
+	for _, doc := range bridge.Docs() {
+		if doc.Summary == "" {
+			t.Errorf("processor %q has no description", doc.Name)
+		}
+		for _, opt := range doc.Options {
+			if opt.Name == "" || opt.Type == "" || opt.Description == "" {
+				t.Errorf("processor %q: incomplete option %+v", doc.Name, opt)
+			}
+		}
+	}
+}
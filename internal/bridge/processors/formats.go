@@ -0,0 +1,22 @@
+package processors
+
+import (
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// loadFormatsFile reads a format pack: a YAML map of message type → template,
+// referenced by a processor's formats_file option.
+func loadFormatsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read formats_file: %w", err)
+	}
+	var formats map[string]string
+	if err := yaml.Unmarshal(data, &formats); err != nil {
+		return nil, fmt.Errorf("formats_file %s: %w", path, err)
+	}
+	return formats, nil
+}
@@ -0,0 +1,112 @@
+package processors
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultIDHashLength is the number of hex digits of a hashed node ID.
+const defaultIDHashLength = 6
+
+// idHashFields are the message fields holding node identifiers.
+var idHashFields = []string{"from", "sender", "to"}
+
+// idHasher replaces node IDs by stable salted short hashes ("~3f9a1c") in
+// public channels (hash_ids), so bridged mesh traffic does not expose
+// hardware identifiers. Channels in clear_channels still see the real IDs.
+// A nil *idHasher hashes nothing.
+type idHasher struct {
+	salt   []byte
+	length int
+	clear  []string // lower-cased channels that see real IDs
+}
+
+func parseIDHasher(config map[string]interface{}) (*idHasher, error) {
+	v, ok := config["hash_ids"]
+	if !ok {
+		return nil, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("hash_ids must be a map")
+	}
+	h := &idHasher{length: defaultIDHashLength}
+	if s, ok := m["salt"]; ok {
+		h.salt = []byte(fmt.Sprintf("%v", s))
+	}
+	if len(h.salt) == 0 {
+		return nil, fmt.Errorf("hash_ids.salt is required")
+	}
+	if l, ok := m["length"]; ok {
+		n, err := strconv.Atoi(fmt.Sprintf("%v", l))
+		if err != nil || n < 4 || n > 16 {
+			return nil, fmt.Errorf("invalid hash_ids.length %v (want 4-16)", l)
+		}
+		h.length = n
+	}
+	if c, ok := m["clear_channels"]; ok {
+		list, ok := c.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("hash_ids.clear_channels must be a list")
+		}
+		for _, ch := range list {
+			h.clear = append(h.clear, strings.ToLower(fmt.Sprintf("%v", ch)))
+		}
+	}
+	return h, nil
+}
+
+// hash returns the pseudonym of a node ID; the same ID and salt always give
+// the same pseudonym.
+func (h *idHasher) hash(id uint32) string {
+	mac := hmac.New(sha256.New, h.salt)
+	mac.Write([]byte(strconv.FormatUint(uint64(id), 10)))
+	return "~" + hex.EncodeToString(mac.Sum(nil))[:h.length]
+}
+
+// broadcastID is the "to" of messages for every node; it identifies no one.
+const broadcastID = 0xffffffff
+
+// hashValue hashes a field value that is a node ID (decimal or !hex); other
+// values are returned unchanged.
+func (h *idHasher) hashValue(v string) string {
+	if id, ok := parseNodeID(v, v); ok && id != broadcastID {
+		return h.hash(id)
+	}
+	return v
+}
+
+// apply replaces the node IDs in data by their hashes. smartFromIsID says
+// whether smart_from shows an ID rather than a name.
+func (h *idHasher) apply(data map[string]interface{}, smartFromIsID bool) {
+	from, _ := data["from"].(string)
+	sender, _ := data["sender"].(string)
+	id, ok := parseNodeID(from, sender)
+	for _, key := range idHashFields {
+		if v, _ := data[key].(string); v != "" {
+			data[key] = h.hashValue(v)
+		}
+	}
+	if ok {
+		if v, _ := data["node_id"].(string); v != "" {
+			data["node_id"] = h.hash(id)
+		}
+		if smartFromIsID {
+			data["smart_from"] = h.hash(id)
+		}
+	}
+}
+
+// clearChannel reports whether channel sees real node IDs.
+func (h *idHasher) clearChannel(channel string) bool {
+	for _, c := range h.clear {
+		if c == strings.ToLower(channel) {
+			return true
+		}
+	}
+	return false
+}
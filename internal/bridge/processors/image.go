@@ -0,0 +1,306 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/bridge"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+const (
+	defaultImageFormat = "📷 [{{.Topic}}] {{.URL}}"
+	imgbbEndpoint      = "https://api.imgbb.com/1/upload"
+)
+
+// imageExtensions maps detected content types to file extensions.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// imageUploader stores an image and returns its public URL.
+type imageUploader interface {
+	upload(ctx context.Context, name, contentType string, data []byte) (string, error)
+}
+
+type imageProcessor struct {
+	uploader imageUploader
+	format   *template.Template
+	timeout  time.Duration
+	clock    schedule.Clock
+}
+
+// imageDoc declares the image processor's processor_config options.
+var imageDoc = types.ComponentDoc{
+	Summary: "Uploads raw or base64 image payloads and posts the URL",
+	Options: []types.OptionDoc{
+		{Name: "destination", Type: "string", Required: true, Description: "local, http_put or imgbb"},
+		{Name: "dir", Type: "string", Description: "local: directory to write images to"},
+		{Name: "base_url", Type: "string", Description: "local: public URL of dir; http_put: public URL prefix if it differs from put_url"},
+		{Name: "put_url", Type: "string", Description: "http_put: URL prefix images are PUT to"},
+		{Name: "authorization", Type: "string", Description: "http_put: value of the Authorization header"},
+		{Name: "api_key", Type: "string", Description: "imgbb: API key"},
+		{Name: "expiration", Type: "int", Description: "imgbb: auto-delete after this many seconds"},
+		{Name: "timeout", Type: "duration", Default: "10s", Description: "Upload timeout"},
+		{Name: "format", Type: "template", Default: defaultImageFormat, Description: "Fields Topic, URL, Size (bytes), Type (content type)"},
+	},
+}
+
+// newImageProcessor creates an image upload processor from a config map.
+func newImageProcessor(config map[string]interface{}) (bridge.Processor, error) {
+	p := &imageProcessor{
+		timeout: 10 * time.Second,
+		clock:   schedule.Real,
+	}
+
+	str := func(key string) string {
+		if v, ok := config[key]; ok && v != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return ""
+	}
+
+	if v := str("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("image: invalid timeout %q: %w", v, err)
+		}
+		p.timeout = d
+	}
+
+	client := &http.Client{Timeout: p.timeout}
+
+	switch dest := str("destination"); dest {
+	case "local":
+		dir, baseURL := str("dir"), str("base_url")
+		if dir == "" || baseURL == "" {
+			return nil, fmt.Errorf("image: destination local requires dir and base_url")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("image: create %s: %w", dir, err)
+		}
+		p.uploader = &localUploader{dir: dir, baseURL: baseURL}
+	case "http_put":
+		putURL := str("put_url")
+		if putURL == "" {
+			return nil, fmt.Errorf("image: destination http_put requires put_url")
+		}
+		baseURL := str("base_url")
+		if baseURL == "" {
+			baseURL = putURL
+		}
+		p.uploader = &putUploader{client: client, putURL: putURL, baseURL: baseURL, auth: str("authorization")}
+	case "imgbb":
+		apiKey := str("api_key")
+		if apiKey == "" {
+			return nil, fmt.Errorf("image: destination imgbb requires api_key")
+		}
+		p.uploader = &imgbbUploader{client: client, endpoint: imgbbEndpoint, apiKey: apiKey, expiration: str("expiration")}
+	case "":
+		return nil, fmt.Errorf("image: destination is required (local, http_put or imgbb)")
+	default:
+		return nil, fmt.Errorf("image: unknown destination %q (local, http_put or imgbb)", dest)
+	}
+
+	format := defaultImageFormat
+	if v := str("format"); v != "" {
+		format = v
+	}
+	tmpl, err := template.New("image").Option("missingkey=zero").Funcs(irc.Funcs).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("image: invalid format template: %w", err)
+	}
+	p.format = tmpl
+
+	return p, nil
+}
+
+// Process uploads image payloads (raw or base64 encoded) and posts the URL.
+// Payloads that are not images pass through to the normal formatting path.
+func (p *imageProcessor) Process(msg types.Message) (bridge.ProcessResult, error) {
+	data, contentType, ok := decodeImage(msg.Payload)
+	if !ok {
+		return bridge.ProcessResult{}, nil
+	}
+
+	name := imageFileName(msg.Topic, p.clock.Now(), imageExtensions[contentType])
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	fields := map[string]interface{}{
+		"Topic": irc.StripMarkers(msg.Topic),
+		"Size":  len(data),
+		"Type":  contentType,
+		"URL":   "",
+	}
+
+	u, err := p.uploader.upload(ctx, name, contentType, data)
+	if err != nil {
+		// Still post something more useful than "[binary data, N bytes]".
+		return bridge.ProcessResult{
+			Formatted: fmt.Sprintf("📷 [%s] image (%d bytes, upload failed)", msg.Topic, len(data)),
+		}, fmt.Errorf("image: upload failed: %w", err)
+	}
+	fields["URL"] = u
+
+	buf := irc.GetBuffer()
+	defer irc.PutBuffer(buf)
+	if err := p.format.Execute(buf, fields); err != nil {
+		return bridge.ProcessResult{}, fmt.Errorf("image: template execution failed: %w", err)
+	}
+	return bridge.ProcessResult{Formatted: buf.String()}, nil
+}
+
+// decodeImage returns the image bytes and content type of a raw or base64
+// (optionally data: URI) encoded image payload.
+func decodeImage(payload []byte) ([]byte, string, bool) {
+	if ct := http.DetectContentType(payload); imageExtensions[ct] != "" {
+		return payload, ct, true
+	}
+
+	s := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(s, "data:") {
+		if i := strings.Index(s, ","); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, "", false
+	}
+	if ct := http.DetectContentType(decoded); imageExtensions[ct] != "" {
+		return decoded, ct, true
+	}
+	return nil, "", false
+}
+
+// imageFileName builds a unique, URL-safe file name from the topic and time.
+func imageFileName(topic string, t time.Time, ext string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, topic)
+	return fmt.Sprintf("%s_%s%s", safe, t.UTC().Format("20060102T150405.000Z"), ext)
+}
+
+// joinURL appends a file name to a base URL.
+func joinURL(base, name string) string {
+	return strings.TrimSuffix(base, "/") + "/" + url.PathEscape(name)
+}
+
+// --- uploaders ---
+
+// localUploader writes images to a directory served by an external web server.
+type localUploader struct {
+	dir     string
+	baseURL string
+}
+
+func (u *localUploader) upload(_ context.Context, name, _ string, data []byte) (string, error) {
+	path := filepath.Join(u.dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("write %s: %w", path, err)
+	}
+	return joinURL(u.baseURL, name), nil
+}
+
+// putUploader uploads images with HTTP PUT (S3-compatible buckets via a
+// writable prefix, WebDAV, simple upload servers).
+type putUploader struct {
+	client  *http.Client
+	putURL  string
+	baseURL string
+	auth    string
+}
+
+func (u *putUploader) upload(ctx context.Context, name, contentType string, data []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, joinURL(u.putURL, name), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if u.auth != "" {
+		req.Header.Set("Authorization", u.auth)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("PUT %s: %s", req.URL, resp.Status)
+	}
+	return joinURL(u.baseURL, name), nil
+}
+
+// imgbbUploader uploads images to imgbb.com.
+type imgbbUploader struct {
+	client     *http.Client
+	endpoint   string
+	apiKey     string
+	expiration string // seconds, optional
+}
+
+func (u *imgbbUploader) upload(ctx context.Context, name, _ string, data []byte) (string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("image", base64.StdEncoding.EncodeToString(data))
+	_ = w.WriteField("name", strings.TrimSuffix(name, filepath.Ext(name)))
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	q := url.Values{"key": {u.apiKey}}
+	if u.expiration != "" {
+		q.Set("expiration", u.expiration)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.endpoint+"?"+q.Encode(), &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			URL string `json:"url"`
+		} `json:"data"`
+		Success bool `json:"success"`
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(raw, &result); err != nil || !result.Success || result.Data.URL == "" {
+		return "", fmt.Errorf("imgbb: unexpected response (%s)", resp.Status)
+	}
+	return result.Data.URL, nil
+}
@@ -0,0 +1,142 @@
+package processors
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// pngHeader is enough of a PNG file for content type detection.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+func TestDecodeImage(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString(pngHeader)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		wantOK  bool
+	}{
+		{"raw png", pngHeader, true},
+		{"base64 png", []byte(b64), true},
+		{"data uri", []byte("data:image/png;base64," + b64), true},
+		{"text", []byte("hello"), false},
+		{"base64 text", []byte(base64.StdEncoding.EncodeToString([]byte("hello"))), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, ct, ok := decodeImage(tt.payload)
+			if ok != tt.wantOK {
+				t.Fatalf("decodeImage() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (ct != "image/png" || string(data) != string(pngHeader)) {
+				t.Errorf("decodeImage() = %q, %q", data, ct)
+			}
+		})
+	}
+}
+
+func TestImageProcessor_Local(t *testing.T) {
+	dir := t.TempDir()
+	p, err := newImageProcessor(map[string]interface{}{
+		"destination": "local",
+		"dir":         dir,
+		"base_url":    "https://example.com/snaps/",
+	})
+	if err != nil {
+		t.Fatalf("newImageProcessor: %v", err)
+	}
+	p.(*imageProcessor).clock = schedule.NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	result, err := p.Process(types.Message{Topic: "camera/door", Payload: pngHeader})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	want := "📷 [camera/door] https://example.com/snaps/camera_door_20260102T030405.000Z.png"
+	if result.Formatted != want {
+		t.Errorf("Formatted = %q, want %q", result.Formatted, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "camera_door_20260102T030405.000Z.png")); err != nil {
+		t.Errorf("image not written: %v", err)
+	}
+
+	// Non-image payloads pass through.
+	result, err = p.Process(types.Message{Topic: "camera/door", Payload: []byte("motion")})
+	if err != nil || result.Formatted != "" || result.Drop {
+		t.Errorf("non-image payload: result = %+v, err = %v", result, err)
+	}
+}
+
+func TestImageProcessor_HTTPPut(t *testing.T) {
+	var gotPath, gotType string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotType = r.URL.Path, r.Header.Get("Content-Type")
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	p, err := newImageProcessor(map[string]interface{}{
+		"destination": "http_put",
+		"put_url":     srv.URL + "/upload",
+		"format":      "{{.URL}} ({{.Size}} bytes, {{.Type}})",
+	})
+	if err != nil {
+		t.Fatalf("newImageProcessor: %v", err)
+	}
+
+	result, err := p.Process(types.Message{Topic: "cam", Payload: []byte(base64.StdEncoding.EncodeToString(pngHeader))})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if !strings.HasPrefix(gotPath, "/upload/cam_") || gotType != "image/png" || string(gotBody) != string(pngHeader) {
+		t.Errorf("upload: path=%q type=%q body=%q", gotPath, gotType, gotBody)
+	}
+	if !strings.HasPrefix(result.Formatted, srv.URL+"/upload/cam_") || !strings.HasSuffix(result.Formatted, "(16 bytes, image/png)") {
+		t.Errorf("Formatted = %q", result.Formatted)
+	}
+}
+
+func TestImageProcessor_UploadFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	p, err := newImageProcessor(map[string]interface{}{"destination": "http_put", "put_url": srv.URL})
+	if err != nil {
+		t.Fatalf("newImageProcessor: %v", err)
+	}
+	result, err := p.Process(types.Message{Topic: "cam", Payload: pngHeader})
+	if err == nil {
+		t.Error("expected upload error")
+	}
+	if want := "📷 [cam] image (16 bytes, upload failed)"; result.Formatted != want {
+		t.Errorf("Formatted = %q, want %q", result.Formatted, want)
+	}
+}
+
+func TestImageProcessor_ConfigErrors(t *testing.T) {
+	configs := []map[string]interface{}{
+		{},
+		{"destination": "s3"},
+		{"destination": "local", "dir": "/tmp"},
+		{"destination": "http_put"},
+		{"destination": "imgbb"},
+		{"destination": "imgbb", "api_key": "k", "timeout": "soon"},
+	}
+	for _, cfg := range configs {
+		if _, err := newImageProcessor(cfg); err == nil {
+			t.Errorf("newImageProcessor(%v) succeeded, want error", cfg)
+		}
+	}
+}
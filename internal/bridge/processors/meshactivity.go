@@ -0,0 +1,197 @@
+package processors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+const (
+	defaultDigestTop = 5  // most active nodes listed
+	maxDigestNew     = 10 // new nodes listed by name
+)
+
+// meshDigest configures the activity digest (processor_config.digest).
+type meshDigest struct {
+	schedule string // cron, e.g. "0 8 * * *"
+	channel  string // empty = the mapping's channels
+	top      int
+}
+
+// parseMeshDigest reads processor_config.digest; ok is false if not set.
+func parseMeshDigest(config map[string]interface{}) (d meshDigest, ok bool, err error) {
+	v, ok := config["digest"]
+	if !ok {
+		return d, false, nil
+	}
+	m, isMap := v.(map[string]interface{})
+	if !isMap {
+		return d, false, fmt.Errorf("digest must be a map")
+	}
+	d.top = defaultDigestTop
+	if s, ok := m["schedule"]; ok {
+		d.schedule = fmt.Sprintf("%v", s)
+	}
+	if _, err := schedule.ParseCron(d.schedule, nil); err != nil {
+		return d, false, fmt.Errorf("digest: %w", err)
+	}
+	if c, ok := m["channel"]; ok {
+		d.channel = fmt.Sprintf("%v", c)
+	}
+	if t, ok := m["top"]; ok {
+		n, isInt := t.(int)
+		if !isInt || n < 1 {
+			return d, false, fmt.Errorf("digest: top must be a positive integer")
+		}
+		d.top = n
+	}
+	return d, true, nil
+}
+
+// heardNode is a node's activity in the current digest period.
+type heardNode struct {
+	sender   string // !xxxxxxxx, for nodes without a registry name
+	messages int
+	isNew    bool
+}
+
+// meshActivity counts what was heard on the mesh for the digest. A nil
+// *meshActivity records nothing.
+type meshActivity struct {
+	mu    sync.Mutex
+	since time.Time             // start of the current period
+	heard map[string]*heardNode // by node ID (from)
+	known map[string]bool       // heard in an earlier period
+	types map[string]int        // messages by type
+	total int
+}
+
+func newMeshActivity(now time.Time) *meshActivity {
+	return &meshActivity{
+		since: now,
+		heard: make(map[string]*heardNode),
+		known: make(map[string]bool),
+		types: make(map[string]int),
+	}
+}
+
+// record counts a message of msgType from node. registered says whether the
+// node is in the node registry, which makes it not new.
+func (a *meshActivity) record(from, sender, msgType string, registered bool) {
+	if a == nil {
+		return
+	}
+	if msgType == "" {
+		msgType = "unknown"
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.total++
+	a.types[msgType]++
+	if from == "" {
+		return
+	}
+	n := a.heard[from]
+	if n == nil {
+		n = &heardNode{isNew: !registered && !a.known[from]}
+		a.heard[from] = n
+	}
+	if sender != "" {
+		n.sender = sender
+	}
+	n.messages++
+}
+
+// report renders the period ending at now and starts a new one. name
+// resolves a node's display name.
+func (a *meshActivity) report(now time.Time, top int, name func(from, sender string) string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	type active struct {
+		name     string
+		messages int
+	}
+	var nodes []active
+	var fresh []string
+	for from, n := range a.heard {
+		nodes = append(nodes, active{name(from, n.sender), n.messages})
+		if n.isNew {
+			fresh = append(fresh, name(from, n.sender))
+		}
+		a.known[from] = true
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].messages != nodes[j].messages {
+			return nodes[i].messages > nodes[j].messages
+		}
+		return nodes[i].name < nodes[j].name
+	})
+	sort.Strings(fresh)
+
+	lines := []string{fmt.Sprintf("📊 Mesh activity in the last %s: %d nodes heard (%d new), %d messages",
+		period(now.Sub(a.since)), len(nodes), len(fresh), a.total)}
+	if len(nodes) > 0 {
+		parts := make([]string, 0, top)
+		for _, n := range nodes[:min(top, len(nodes))] {
+			parts = append(parts, fmt.Sprintf("%s %d", n.name, n.messages))
+		}
+		lines = append(lines, "Most active: "+strings.Join(parts, ", "))
+	}
+	if len(fresh) > 0 {
+		line := "New nodes: " + strings.Join(fresh[:min(maxDigestNew, len(fresh))], ", ")
+		if len(fresh) > maxDigestNew {
+			line += fmt.Sprintf(" and %d more", len(fresh)-maxDigestNew)
+		}
+		lines = append(lines, line)
+	}
+	if len(a.types) > 0 {
+		types := make([]string, 0, len(a.types))
+		for t := range a.types {
+			types = append(types, t)
+		}
+		sort.Slice(types, func(i, j int) bool {
+			if a.types[types[i]] != a.types[types[j]] {
+				return a.types[types[i]] > a.types[types[j]]
+			}
+			return types[i] < types[j]
+		})
+		parts := make([]string, len(types))
+		for i, t := range types {
+			parts[i] = fmt.Sprintf("%s %d", t, a.types[t])
+		}
+		lines = append(lines, "By type: "+strings.Join(parts, ", "))
+	}
+
+	a.since = now
+	a.heard = make(map[string]*heardNode)
+	a.types = make(map[string]int)
+	a.total = 0
+	return lines
+}
+
+// period renders a report period in whole minutes, e.g. "24h" or "1h30m".
+func period(d time.Duration) string {
+	s := d.Round(time.Minute).String()
+	s = strings.TrimSuffix(s, "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// forget removes a node from the current period and from the nodes heard
+// before, for !purge.
+func (a *meshActivity) forget(from string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.heard, from)
+	delete(a.known, from)
+}
@@ -1,43 +1,84 @@
-// Package processors provides message pre-processors for the mqtt2irc bridge.
-// Import this package with a blank import to register all processors:
-//
-//	import _ "github.com/dyuri/mqtt2irc/internal/bridge/processors"
 package processors
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"text/template"
 	"time"
 
 	"github.com/dyuri/mqtt2irc/internal/bridge"
+	"github.com/dyuri/mqtt2irc/internal/geo"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/persist"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
 	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
-func init() {
-	bridge.Register("meshtastic", newMeshtasticProcessor)
-}
-
 // defaultMeshtasticFormats are the built-in format strings for each Meshtastic message type.
-// {{.smart_from}} resolves to: registry shortname > sender field (!xxxxxxxx) > numeric from.
+// {{.smart_from}} resolves to: registry shortname > sender field (!xxxxxxxx) > numeric from
+// by default (see smart_from_chain).
 var defaultMeshtasticFormats = map[string]string{
 	"nodeinfo":  "📱 {{.smart_from}} - {{.longname}} ({{.hardware}})",
-	"position":  "🌍 {{.smart_from}} @ {{.latitude_i}},{{.longitude_i}} alt={{.altitude}}m",
+	"position":  "🌍 {{.smart_from}} @ {{.latitude_i}},{{.longitude_i}} alt={{.altitude}}m{{if .place}} near {{.place}}{{end}}",
 	"text":      "🖊️ {{.smart_from}}: {{.text}}",
 	"telemetry": "📡 {{.smart_from}} bat={{.battery_level}}% air={{.air_util_tx}} channel={{.channel_utilization}}",
 	"default":   "🗨 [{{.msgtype}}] from {{.smart_from}}: {{.payload}}",
 }
 
+// quietMeshtasticTypes are map reports and store-and-forward control packets:
+// periodic, not meant for people, and noisy under the default template. They
+// are dropped (counted as quiet) unless formats has an entry for the type.
+var quietMeshtasticTypes = map[string]bool{
+	"mapreport":    true,
+	"storeforward": true,
+}
+
 type meshtasticProcessor struct {
 	dedupWindow time.Duration
 	idField     string
+	dedupKey    *template.Template // dedup_key; nil = the id_field
 	typeField   string
 	formats     map[string]*template.Template
 	cache       *dedupCache
 	nodes       *nodeRegistry
+	names       nodeNames
+	hashIDs     *idHasher // nil unless hash_ids is configured
+	clock       schedule.Clock
+	privacy     positionPrivacy
+	geocoder    geo.Geocoder // nil unless reverse_geocode is configured
+	digest      meshDigest
+	activity    *meshActivity // nil unless digest is configured
+}
+
+// meshtasticDoc declares the meshtastic processor's processor_config
+// options.
+var meshtasticDoc = types.ComponentDoc{
+	Summary: "Deduplicates Meshtastic mesh traffic and formats it by message type, with node names from a registry",
+	Options: []types.OptionDoc{
+		{Name: "dedup_window", Type: "duration", Default: "30s", Description: "Drop duplicate message IDs within this duration"},
+		{Name: "dedup_db", Type: "string", Description: "JSON file keeping the recent message IDs across restarts"},
+		{Name: "id_field", Type: "string", Default: "id", Description: "JSON field used for deduplication"},
+		{Name: "dedup_key", Type: "template", Description: "Deduplication key instead of id_field, e.g. {{.from}}-{{.id}}"},
+		{Name: "type_field", Type: "string", Default: "type", Description: "JSON field that selects the format template"},
+		{Name: "node_db", Type: "string", Description: "JSON file persisting the node registry across restarts"},
+		{Name: "node_id_format", Type: "string", Description: "bang, hex or decimal: how nodes without a name and {{.node_id}} are shown"},
+		{Name: "smart_from_prefer", Type: "string", Default: "shortname", Description: "Registry name {{.smart_from}} shows first: shortname or longname"},
+		{Name: "smart_from_chain", Type: "list", Description: "Order of registry.shortname, registry.longname, alias, sender, from, node_id; replaces smart_from_prefer"},
+		{Name: "aliases_file", Type: "string", Description: "YAML map of node ID to display name, for the alias step"},
+		{Name: "formats", Type: "map", Description: "Message type to template; overrides the defaults and formats_file"},
+		{Name: "formats_file", Type: "string", Description: "YAML file with a map of message type to template"},
+		{Name: "position_precision", Type: "int", Description: "Round coordinates to this many decimal places (0-7)"},
+		{Name: "private_zones", Type: "list", Description: "{lat, lon, radius_m} geofences; positions inside are not posted"},
+		{Name: "reverse_geocode", Type: "map", Description: "Show positions as a place name ({{.place}})"},
+		{Name: "hash_ids", Type: "map", Description: "Replace node IDs by salted hashes: salt (required), length (4-16, default 6), clear_channels"},
+		{Name: "digest", Type: "map", Description: "Activity digest: schedule (cron), channel, top (default 5)"},
+	},
 }
 
 // newMeshtasticProcessor creates a Meshtastic processor from a config map.
@@ -47,6 +88,7 @@ func newMeshtasticProcessor(config map[string]interface{}) (bridge.Processor, er
 		idField:     "id",
 		typeField:   "type",
 		formats:     make(map[string]*template.Template),
+		clock:       schedule.Real,
 	}
 
 	if v, ok := config["dedup_window"]; ok {
@@ -59,26 +101,80 @@ func newMeshtasticProcessor(config map[string]interface{}) (bridge.Processor, er
 	if v, ok := config["id_field"]; ok {
 		p.idField = fmt.Sprintf("%v", v)
 	}
+	if v, ok := config["dedup_key"]; ok {
+		// A missing field fails the template, so such messages are not deduplicated.
+		tmpl, err := template.New("dedup_key").Option("missingkey=error").Funcs(irc.Funcs).Parse(fmt.Sprintf("%v", v))
+		if err != nil {
+			return nil, fmt.Errorf("meshtastic: invalid dedup_key: %w", err)
+		}
+		p.dedupKey = tmpl
+	}
 	if v, ok := config["type_field"]; ok {
 		p.typeField = fmt.Sprintf("%v", v)
 	}
 
+	names, err := parseNodeNames(config)
+	if err != nil {
+		return nil, fmt.Errorf("meshtastic: %w", err)
+	}
+	p.names = names
+	if p.hashIDs, err = parseIDHasher(config); err != nil {
+		return nil, fmt.Errorf("meshtastic: %w", err)
+	}
+
+	privacy, err := parsePositionPrivacy(config)
+	if err != nil {
+		return nil, fmt.Errorf("meshtastic: %w", err)
+	}
+	p.privacy = privacy
+
+	if v, ok := config["reverse_geocode"]; ok {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("meshtastic: reverse_geocode must be a map")
+		}
+		gcfg, err := geo.ParseConfig(m)
+		if err != nil {
+			return nil, fmt.Errorf("meshtastic: %w", err)
+		}
+		if p.geocoder, err = geo.New(gcfg); err != nil {
+			return nil, fmt.Errorf("meshtastic: %w", err)
+		}
+	}
+
+	if d, ok, err := parseMeshDigest(config); err != nil {
+		return nil, fmt.Errorf("meshtastic: %w", err)
+	} else if ok {
+		p.digest = d
+		p.activity = newMeshActivity(p.clock.Now())
+	}
+
 	// Node registry — optional persistence via node_db path.
 	nodeDBPath := ""
 	if v, ok := config["node_db"]; ok {
 		nodeDBPath = fmt.Sprintf("%v", v)
 	}
-	reg := newNodeRegistry(nodeDBPath)
-	if err := reg.load(); err != nil {
+	reg, err := openNodeRegistry(nodeDBPath)
+	if err != nil {
 		return nil, fmt.Errorf("meshtastic: failed to load node registry: %w", err)
 	}
 	p.nodes = reg
 
-	// Start from defaults, then override with user-supplied formats.
+	// Start from defaults, then override with the formats file, then with
+	// inline formats.
 	fmtStrings := make(map[string]string, len(defaultMeshtasticFormats))
 	for k, v := range defaultMeshtasticFormats {
 		fmtStrings[k] = v
 	}
+	if v, ok := config["formats_file"]; ok {
+		pack, err := loadFormatsFile(fmt.Sprintf("%v", v))
+		if err != nil {
+			return nil, fmt.Errorf("meshtastic: %w", err)
+		}
+		for k, val := range pack {
+			fmtStrings[k] = val
+		}
+	}
 	if v, ok := config["formats"]; ok {
 		if fm, ok := v.(map[string]interface{}); ok {
 			for k, val := range fm {
@@ -88,30 +184,34 @@ func newMeshtasticProcessor(config map[string]interface{}) (bridge.Processor, er
 	}
 
 	for name, tmplStr := range fmtStrings {
-		tmpl, err := template.New(name).Option("missingkey=zero").Parse(tmplStr)
+		tmpl, err := template.New(name).Option("missingkey=zero").Funcs(irc.Funcs).Parse(tmplStr)
 		if err != nil {
 			return nil, fmt.Errorf("meshtastic: invalid format template %q: %w", name, err)
 		}
 		p.formats[name] = tmpl
 	}
 
-	p.cache = newDedupCache(p.dedupWindow)
+	p.cache = newDedupCache(p.dedupWindow, p.clock)
+	if v, ok := config["dedup_db"]; ok {
+		p.cache.path = fmt.Sprintf("%v", v)
+		if err := p.cache.load(); err != nil {
+			return nil, fmt.Errorf("meshtastic: failed to load dedup cache: %w", err)
+		}
+	}
 	return p, nil
 }
 
 // Process handles a single MQTT message for the Meshtastic bridge.
 func (p *meshtasticProcessor) Process(msg types.Message) (bridge.ProcessResult, error) {
-	var raw map[string]interface{}
-	if err := json.Unmarshal(msg.Payload, &raw); err != nil {
-		// Not JSON — pass through to normal FormatMessage path.
+	raw := irc.JSONObject(msg)
+	if raw == nil {
+		// Not a JSON object — pass through to normal FormatMessage path.
 		return bridge.ProcessResult{}, nil
 	}
 
-	// Deduplicate by message ID field.
-	if id, ok := raw[p.idField]; ok && id != nil {
-		if p.cache.seen(fmt.Sprintf("%v", id)) {
-			return bridge.ProcessResult{Drop: true}, nil
-		}
+	// Deduplicate by message ID.
+	if id, ok := p.dedupID(raw); ok && p.cache.seen(id) {
+		return bridge.ProcessResult{Drop: true, Reason: stats.DropDedup}, nil
 	}
 
 	// Determine message type.
@@ -122,11 +222,19 @@ func (p *meshtasticProcessor) Process(msg types.Message) (bridge.ProcessResult,
 
 	// Build flat template data from nested JSON.
 	data := flattenMeshtastic(raw, msgType)
+	defer releaseData(data)
+
+	if p.activity != nil {
+		fromStr, _ := data["from"].(string)
+		sender, _ := data["sender"].(string)
+		_, registered := p.nodes.get(fromStr)
+		p.activity.record(fromStr, sender, msgType, registered)
+	}
 
 	// Update node registry on nodeinfo messages.
 	if msgType == "nodeinfo" {
 		if fromStr, _ := data["from"].(string); fromStr != "" {
-			rec := nodeRecord{UpdatedAt: time.Now()}
+			rec := nodeRecord{UpdatedAt: p.clock.Now()}
 			rec.ShortName, _ = data["shortname"].(string)
 			rec.LongName, _ = data["longname"].(string)
 			// Non-fatal: in-memory registry is always updated; only disk write may fail.
@@ -134,8 +242,51 @@ func (p *meshtasticProcessor) Process(msg types.Message) (bridge.ProcessResult,
 		}
 	}
 
-	// Add smart_from: registry shortname > sender field (!xxxxxxxx) > raw from.
-	data["smart_from"] = p.smartFrom(data)
+	// Map reports carry the node's names too; record them when they change,
+	// so periodic reports do not rewrite node_db.
+	if msgType == "mapreport" {
+		if fromStr, _ := data["from"].(string); fromStr != "" {
+			rec := nodeRecord{
+				ShortName: firstString(data, "shortname", "short_name"),
+				LongName:  firstString(data, "longname", "long_name"),
+				UpdatedAt: p.clock.Now(),
+			}
+			if cur, ok := p.nodes.get(fromStr); rec.ShortName != "" && (!ok || cur.ShortName != rec.ShortName || cur.LongName != rec.LongName) {
+				_ = p.nodes.update(fromStr, rec)
+			}
+		}
+	}
+
+	region := meshtasticRegion(msg.Topic)
+	if fromStr, _ := data["from"].(string); fromStr != "" {
+		p.nodes.touch(fromStr, region, p.clock.Now())
+	}
+
+	if quietMeshtasticTypes[msgType] && p.formats[msgType] == nil {
+		return bridge.ProcessResult{Drop: true, Reason: stats.DropQuiet}, nil
+	}
+
+	if msgType == "position" || msgType == "mapreport" {
+		if !p.applyPositionPrivacy(data) {
+			return bridge.ProcessResult{Drop: true, Reason: stats.DropPrivacy}, nil
+		}
+		// Geocode the (possibly rounded) position, so no more precise
+		// coordinates than those posted leave the bridge.
+		if lat, lon, ok := position(data); ok && p.geocoder != nil {
+			if place, ok := p.geocoder.Reverse(lat, lon); ok {
+				data["place"] = place.String()
+			}
+		}
+	}
+
+	// Add smart_from: registry shortname > sender field (!xxxxxxxx) > raw from,
+	// or as set by smart_from_chain.
+	smartFrom, step := p.smartFrom(data, region)
+	data["smart_from"] = smartFrom
+	fromStr, _ := data["from"].(string)
+	sender, _ := data["sender"].(string)
+	data["node_id"] = p.names.nodeID(fromStr, sender)
+	data["Meta"] = msg.Meta
 
 	// Select the best matching template.
 	tmpl := p.selectTemplate(msgType)
@@ -143,31 +294,143 @@ func (p *meshtasticProcessor) Process(msg types.Message) (bridge.ProcessResult,
 		return bridge.ProcessResult{}, nil
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
+	buf := irc.GetBuffer()
+	defer irc.PutBuffer(buf)
+	if err := tmpl.Execute(buf, data); err != nil {
 		return bridge.ProcessResult{}, fmt.Errorf("meshtastic: template execution failed: %w", err)
 	}
 
 	// Return the raw rendered string; bridge applies SanitizeAndTruncate.
-	return bridge.ProcessResult{Formatted: buf.String()}, nil
+	formatted := buf.String()
+	if p.hashIDs == nil {
+		return bridge.ProcessResult{Formatted: formatted}, nil
+	}
+
+	// Render again with hashed node IDs for public channels; the channels in
+	// clear_channels get the real ones.
+	p.hashIDs.apply(data, isIDStep(step))
+	buf.Reset()
+	if err := tmpl.Execute(buf, data); err != nil {
+		return bridge.ProcessResult{}, fmt.Errorf("meshtastic: template execution failed: %w", err)
+	}
+	result := bridge.ProcessResult{Formatted: buf.String()}
+	if result.Formatted != formatted && len(p.hashIDs.clear) > 0 {
+		result.ChannelFormatted = make(map[string]string, len(p.hashIDs.clear))
+		for _, channel := range p.hashIDs.clear {
+			result.ChannelFormatted[channel] = formatted
+		}
+	}
+	return result, nil
+}
+
+// ReportSchedule returns the activity digest's schedule and channel
+// (implements bridge.ReportingProcessor).
+func (p *meshtasticProcessor) ReportSchedule() (string, string) {
+	return p.digest.schedule, p.digest.channel
+}
+
+// Report returns the activity digest for the period ending at now
+// (implements bridge.ReportingProcessor).
+func (p *meshtasticProcessor) Report(now time.Time) []string {
+	if p.activity == nil {
+		return nil
+	}
+	return p.activity.report(now, p.digest.top, p.displayName)
 }
 
-// smartFrom resolves the best display name for a message sender.
+// dedupID returns the key a message is deduplicated by: the rendered
+// dedup_key, or else the id_field. ok is false for messages without one.
+func (p *meshtasticProcessor) dedupID(raw map[string]interface{}) (string, bool) {
+	if p.dedupKey == nil {
+		id, ok := raw[p.idField]
+		if !ok || id == nil {
+			return "", false
+		}
+		return fmt.Sprintf("%v", id), true
+	}
+	var sb strings.Builder
+	if err := p.dedupKey.Execute(&sb, raw); err != nil {
+		return "", false
+	}
+	return sb.String(), sb.Len() > 0
+}
+
+// firstString returns the first non-empty string field of data among keys.
+func firstString(data map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if v, _ := data[k].(string); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// smartFrom resolves the best display name for a message sender: the first
+// step of the smart_from_chain that yields a name. The default chain is
+//
+//  1. shortname from the node registry (populated by nodeinfo messages), or
+//     the longname first with smart_from_prefer: longname
+//  2. alias from the aliases_file, if set
+//  3. node ID in node_id_format, if set
+//  4. sender field from the current message (!xxxxxxxx — always 9 chars)
+//  5. raw from value (numeric node ID)
 //
-// Priority:
-//  1. shortname from the node registry (populated by nodeinfo messages)
-//  2. sender field from the current message (!xxxxxxxx — always 9 chars)
-//  3. raw from value (numeric node ID)
-func (p *meshtasticProcessor) smartFrom(data map[string]interface{}) string {
+// A registry shortname that another node uses in a different region gets
+// the message's region appended, e.g. "HILL@EU_868". The chain step the
+// name came from is returned as well.
+func (p *meshtasticProcessor) smartFrom(data map[string]interface{}, region string) (string, string) {
 	fromStr, _ := data["from"].(string)
+	sender, _ := data["sender"].(string)
+	rec, _ := p.nodes.get(fromStr)
+	name, step := p.names.resolve(fromStr, sender, rec)
+	if step == nameShortname && p.nodes.collides(fromStr, rec.ShortName, region) {
+		name += "@" + region
+	}
+	return name, step
+}
+
+// displayName is smartFrom for a node ID and its sender field. With
+// hash_ids, IDs are hashed unless the digest goes to a clear channel.
+func (p *meshtasticProcessor) displayName(from, sender string) string {
+	rec, _ := p.nodes.get(from)
+	name, step := p.names.resolve(from, sender, rec)
+	if p.hashIDs != nil && isIDStep(step) && !p.hashIDs.clearChannel(p.digest.channel) {
+		if id, ok := parseNodeID(from, sender); ok {
+			return p.hashIDs.hash(id)
+		}
+	}
+	return name
+}
 
-	if rec, ok := p.nodes.get(fromStr); ok && rec.ShortName != "" {
-		return rec.ShortName
+// applyPositionPrivacy rounds the latitude_i/longitude_i fields (1e-7 degrees)
+// to the configured precision. Returns false if the position lies inside a
+// private zone and must not be posted.
+func (p *meshtasticProcessor) applyPositionPrivacy(data map[string]interface{}) bool {
+	lat, lon, ok := position(data)
+	if !ok {
+		return true // no usable position
 	}
-	if sender, _ := data["sender"].(string); sender != "" {
-		return sender
+
+	if p.privacy.suppressed(lat, lon) {
+		return false
 	}
-	return fromStr
+	if p.privacy.precision >= 0 {
+		data["latitude_i"] = strconv.FormatInt(int64(math.Round(p.privacy.round(lat)*1e7)), 10)
+		data["longitude_i"] = strconv.FormatInt(int64(math.Round(p.privacy.round(lon)*1e7)), 10)
+	}
+	return true
+}
+
+// position returns the latitude_i/longitude_i fields in degrees.
+func position(data map[string]interface{}) (lat, lon float64, ok bool) {
+	latStr, _ := data["latitude_i"].(string)
+	lonStr, _ := data["longitude_i"].(string)
+	latI, errLat := strconv.ParseInt(latStr, 10, 64)
+	lonI, errLon := strconv.ParseInt(lonStr, 10, 64)
+	if errLat != nil || errLon != nil {
+		return 0, 0, false
+	}
+	return float64(latI) / 1e7, float64(lonI) / 1e7, true
 }
 
 // selectTemplate returns the template for msgType, or the "default" template, or nil.
@@ -190,7 +453,7 @@ func (p *meshtasticProcessor) selectTemplate(msgType string) *template.Template
 //  3. Nested objects within "payload" are also hoisted one level deep.
 //  4. "type" is renamed to "msgtype" to avoid collision with Go template internals.
 func flattenMeshtastic(raw map[string]interface{}, msgType string) map[string]interface{} {
-	data := make(map[string]interface{}, len(raw))
+	data := dataPool.Get().(map[string]interface{})
 
 	// Step 1: top-level scalar fields.
 	for k, v := range raw {
@@ -223,25 +486,39 @@ func flattenMeshtastic(raw map[string]interface{}, msgType string) map[string]in
 	return data
 }
 
+// dataPool recycles template data maps; Process returns them with releaseData
+// once the template has been rendered.
+var dataPool = sync.Pool{
+	New: func() interface{} { return make(map[string]interface{}, 32) },
+}
+
+func releaseData(data map[string]interface{}) {
+	clear(data)
+	dataPool.Put(data)
+}
+
 // stringify converts a JSON-decoded value to a human-readable string.
 // float64 values that are whole numbers are formatted as integers to avoid
-// scientific notation (e.g. 479000000 instead of 4.79e+08).
+// scientific notation (e.g. 479000000 instead of 4.79e+08). Optional section
+// markers are stripped, see irc.StripMarkers.
 func stringify(v interface{}) string {
 	switch val := v.(type) {
 	case float64:
 		if val == float64(int64(val)) {
-			return fmt.Sprintf("%d", int64(val))
+			return strconv.FormatInt(int64(val), 10)
 		}
-		return fmt.Sprintf("%g", val)
+		return strconv.FormatFloat(val, 'g', -1, 64)
 	case bool:
 		if val {
 			return "true"
 		}
 		return "false"
+	case string:
+		return irc.StripMarkers(val)
 	case nil:
 		return ""
 	default:
-		return fmt.Sprintf("%v", val)
+		return irc.StripMarkers(fmt.Sprintf("%v", val))
 	}
 }
 
@@ -253,40 +530,107 @@ type nodeRecord struct {
 	ShortName string    `json:"shortname,omitempty"`
 	LongName  string    `json:"longname,omitempty"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Seen is when the node was last heard, by region (see meshtasticRegion).
+	// Only modified under the registry's lock.
+	Seen map[string]time.Time `json:"seen,omitempty"`
 }
 
 // nodeRegistry stores node identity associations keyed by the numeric node ID
 // (the "from" field, stringified). When a node_db path is configured, the
-// registry is loaded at startup and saved atomically after each update.
+// registry is loaded at startup and saved atomically after each update, or
+// as periodic snapshots with the memory storage policy (see SetNodeDBPolicy).
 type nodeRegistry struct {
-	mu    sync.RWMutex
-	nodes map[string]nodeRecord
-	path  string // empty = in-memory only, no persistence
+	mu      sync.RWMutex
+	nodes   map[string]nodeRecord
+	byShort map[string]map[string]bool // node IDs by shortname
+	path    string                     // empty = in-memory only, no persistence
+	state   *persist.State             // unsaved changes; nil without path
+}
+
+func newNodeRegistry(path string, policy persist.Policy) *nodeRegistry {
+	r := &nodeRegistry{
+		nodes:   make(map[string]nodeRecord),
+		byShort: make(map[string]map[string]bool),
+		path:    path,
+	}
+	if path != "" {
+		r.state = persist.NewState(policy, schedule.Real)
+	}
+	return r
 }
 
-func newNodeRegistry(path string) *nodeRegistry {
-	return &nodeRegistry{
-		nodes: make(map[string]nodeRecord),
-		path:  path,
+var (
+	registriesMu sync.Mutex
+	registries   = make(map[string]*nodeRegistry)
+	nodeDBPolicy persist.Policy // how node_db files are written
+)
+
+// SetNodeDBPolicy sets how node registries opened afterwards write their
+// node_db (bridge.storage.node_db). Call it before creating processors.
+func SetNodeDBPolicy(policy persist.Policy) {
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	nodeDBPolicy = policy
+}
+
+// openNodeRegistry returns the node registry for a node_db path. Processors
+// with the same node_db share one registry, so mappings for different regions
+// merge what they learn instead of overwriting each other's file. Without a
+// path the registry is the processor's own.
+func openNodeRegistry(path string) (*nodeRegistry, error) {
+	if path == "" {
+		return newNodeRegistry("", persist.Policy{}), nil
+	}
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	if r, ok := registries[path]; ok {
+		return r, nil
+	}
+	r := newNodeRegistry(path, nodeDBPolicy)
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	registries[path] = r
+	return r, nil
+}
+
+// meshtasticRegion returns the region of a Meshtastic topic
+// ("msh/EU_868/2/json/..." is "EU_868"), or "" for other topics.
+func meshtasticRegion(topic string) string {
+	parts := strings.SplitN(topic, "/", 3)
+	if len(parts) < 3 || parts[0] != "msh" {
+		return ""
 	}
+	return parts[1]
 }
 
-// load reads the node registry from disk. No-op when path is empty or file does not exist.
+// load reads the node registry from disk. No-op when path is empty or file
+// does not exist. A corrupt file (e.g. truncated by a power loss) does not
+// fail startup: it is moved aside and the registry starts from its backup or
+// empty (see persist.Load).
 func (r *nodeRegistry) load() error {
 	if r.path == "" {
 		return nil
 	}
-	data, err := os.ReadFile(r.path)
-	if os.IsNotExist(err) {
-		return nil // fresh start; file will be created on first update
-	}
+	err := persist.Load(r.path, r.state.Policy().Backup, func(data []byte) error {
+		var nodes map[string]nodeRecord
+		if err := json.Unmarshal(data, &nodes); err != nil {
+			return err
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.nodes = nodes
+		if r.nodes == nil {
+			r.nodes = make(map[string]nodeRecord)
+		}
+		for from, rec := range r.nodes {
+			r.index(from, "", rec.ShortName)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("node registry: read %s: %w", r.path, err)
-	}
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if err := json.Unmarshal(data, &r.nodes); err != nil {
-		return fmt.Errorf("node registry: parse %s: %w", r.path, err)
+		return fmt.Errorf("node registry: %w", err)
 	}
 	return nil
 }
@@ -303,16 +647,30 @@ func (r *nodeRegistry) save() error {
 	if err != nil {
 		return fmt.Errorf("node registry: marshal: %w", err)
 	}
-	tmpPath := r.path + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
-		return fmt.Errorf("node registry: write %s: %w", tmpPath, err)
-	}
-	if err := os.Rename(tmpPath, r.path); err != nil {
-		return fmt.Errorf("node registry: rename to %s: %w", r.path, err)
+	if err := persist.WriteFile(r.path, data, r.state.Policy()); err != nil {
+		return fmt.Errorf("node registry: %w", err)
 	}
+	r.state.Saved()
 	return nil
 }
 
+// snapshot saves the registry if it has unsaved changes whose snapshot is
+// due.
+func (r *nodeRegistry) snapshot() error {
+	if !r.state.Due() {
+		return nil
+	}
+	return r.save()
+}
+
+// flush saves the registry if it has unsaved changes.
+func (r *nodeRegistry) flush() error {
+	if !r.state.Dirty() {
+		return nil
+	}
+	return r.save()
+}
+
 // get returns the record for a node ID, if known.
 func (r *nodeRegistry) get(from string) (nodeRecord, bool) {
 	r.mu.RLock()
@@ -321,28 +679,222 @@ func (r *nodeRegistry) get(from string) (nodeRecord, bool) {
 	return rec, ok
 }
 
-// update stores a node record in memory and persists to disk.
-// The in-memory update always succeeds; a non-nil error indicates only that
-// the disk write failed (the registry remains correct in memory).
+// update stores a node record in memory and persists to disk, at once or
+// with the next snapshot. The in-memory update always succeeds; a non-nil
+// error indicates only that the disk write failed (the registry remains
+// correct in memory).
 func (r *nodeRegistry) update(from string, rec nodeRecord) error {
 	r.mu.Lock()
+	cur := r.nodes[from]
+	rec.Seen = mergeSeen(cur.Seen, rec.Seen)
+	r.index(from, cur.ShortName, rec.ShortName)
 	r.nodes[from] = rec
 	r.mu.Unlock()
+	if !r.state.Changed() {
+		return nil
+	}
 	return r.save()
 }
 
+// merge adds nodes to the registry, keeping the more recently updated record
+// of nodes known on both sides, and returns how many records it took. The
+// regions a node was seen in are merged either way.
+func (r *nodeRegistry) merge(nodes map[string]nodeRecord) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for from, rec := range nodes {
+		cur, ok := r.nodes[from]
+		if ok && !rec.UpdatedAt.After(cur.UpdatedAt) {
+			cur.Seen = mergeSeen(cur.Seen, rec.Seen)
+			r.nodes[from] = cur
+			continue
+		}
+		rec.Seen = mergeSeen(cur.Seen, rec.Seen)
+		r.index(from, cur.ShortName, rec.ShortName)
+		r.nodes[from] = rec
+		n++
+	}
+	return n
+}
+
+// touch records that a known node was heard in region. It is not saved by
+// itself, but with the next update, snapshot or flush.
+func (r *nodeRegistry) touch(from, region string, now time.Time) {
+	if region == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.nodes[from]
+	if !ok {
+		return
+	}
+	r.state.Touched()
+	if rec.Seen == nil {
+		rec.Seen = make(map[string]time.Time)
+		r.nodes[from] = rec
+	}
+	rec.Seen[region] = now
+}
+
+// collides reports whether another node with shortName was heard in a region
+// other than region.
+func (r *nodeRegistry) collides(from, shortName, region string) bool {
+	if region == "" {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for id := range r.byShort[shortName] {
+		if id == from {
+			continue
+		}
+		for other := range r.nodes[id].Seen {
+			if other != region {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// index moves node from under its old shortname to its new one in byShort.
+// The caller holds the write lock.
+func (r *nodeRegistry) index(from, old, name string) {
+	if old == name {
+		return
+	}
+	if ids := r.byShort[old]; ids != nil {
+		delete(ids, from)
+		if len(ids) == 0 {
+			delete(r.byShort, old)
+		}
+	}
+	if name == "" {
+		return
+	}
+	if r.byShort[name] == nil {
+		r.byShort[name] = make(map[string]bool)
+	}
+	r.byShort[name][from] = true
+}
+
+// remove deletes a node from the registry and, if it was known, saves the
+// registry at once and removes its backup, for !purge.
+func (r *nodeRegistry) remove(from string) (nodeRecord, bool, error) {
+	r.mu.Lock()
+	rec, ok := r.nodes[from]
+	if ok {
+		r.index(from, rec.ShortName, "")
+		delete(r.nodes, from)
+	}
+	r.mu.Unlock()
+	if !ok || r.path == "" {
+		return rec, ok, nil
+	}
+	if err := r.save(); err != nil {
+		return rec, true, err
+	}
+	return rec, true, persist.RemoveBackup(r.path)
+}
+
+// mergeSeen returns the latest time of each region in a and b.
+func mergeSeen(a, b map[string]time.Time) map[string]time.Time {
+	if len(a) == 0 {
+		return b
+	}
+	out := make(map[string]time.Time, len(a)+len(b))
+	for region, t := range a {
+		out[region] = t
+	}
+	for region, t := range b {
+		if t.After(out[region]) {
+			out[region] = t
+		}
+	}
+	return out
+}
+
+// ExportState returns the node registry for a state bundle (implements
+// bridge.StatefulProcessor).
+func (p *meshtasticProcessor) ExportState() (json.RawMessage, error) {
+	p.nodes.mu.RLock()
+	defer p.nodes.mu.RUnlock()
+	return json.Marshal(p.nodes.nodes)
+}
+
+// ImportState merges the node registry of a state bundle (implements
+// bridge.StatefulProcessor).
+func (p *meshtasticProcessor) ImportState(state json.RawMessage) error {
+	var nodes map[string]nodeRecord
+	if err := json.Unmarshal(state, &nodes); err != nil {
+		return fmt.Errorf("meshtastic: parse node registry: %w", err)
+	}
+	if p.nodes.merge(nodes) == 0 {
+		return nil
+	}
+	return p.nodes.save()
+}
+
+// Flush saves the dedup cache and the node registry's unsaved changes, such
+// as last-heard times (implements bridge.FlushingProcessor).
+func (p *meshtasticProcessor) Flush() error {
+	if err := p.cache.save(); err != nil {
+		return err
+	}
+	return p.nodes.flush()
+}
+
+// Snapshot saves the node registry when its snapshot is due (implements
+// bridge.SnapshottingProcessor).
+func (p *meshtasticProcessor) Snapshot() error {
+	return p.nodes.snapshot()
+}
+
+// Purge removes a node from the node registry and the activity digest
+// (implements bridge.PurgingProcessor). The terms returned are its names,
+// alias and hash, as they may appear in bridged lines.
+func (p *meshtasticProcessor) Purge(kind, value string) (int, []string, error) {
+	if kind != bridge.PurgeNode {
+		return 0, nil, nil
+	}
+	id, ok := parseNodeID(value, value)
+	if !ok {
+		return 0, nil, fmt.Errorf("meshtastic: invalid node ID %q", value)
+	}
+	from := strconv.FormatUint(uint64(id), 10)
+	p.activity.forget(from)
+	terms := []string{p.names.aliases[id]}
+	if p.hashIDs != nil {
+		terms = append(terms, p.hashIDs.hash(id))
+	}
+	rec, removed, err := p.nodes.remove(from)
+	if !removed {
+		return 0, terms, err
+	}
+	return 1, append(terms, rec.ShortName, rec.LongName), err
+}
+
 // --- dedup cache ---
 
+// dedupSaveInterval is the least time between two saves of a dedup_db.
+const dedupSaveInterval = 10 * time.Second
+
 type dedupCache struct {
 	mu      sync.Mutex
 	entries map[string]time.Time // id → expiry time
 	window  time.Duration
+	clock   schedule.Clock
+	path    string    // dedup_db; empty = in-memory only
+	savedAt time.Time // of the last save, for dedupSaveInterval
 }
 
-func newDedupCache(window time.Duration) *dedupCache {
+func newDedupCache(window time.Duration, clock schedule.Clock) *dedupCache {
 	return &dedupCache{
 		entries: make(map[string]time.Time),
 		window:  window,
+		clock:   clock,
 	}
 }
 
@@ -352,7 +904,7 @@ func (c *dedupCache) seen(id string) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	now := time.Now()
+	now := c.clock.Now()
 
 	// Lazy eviction of expired entries.
 	for k, expiry := range c.entries {
@@ -366,5 +918,65 @@ func (c *dedupCache) seen(id string) bool {
 	}
 
 	c.entries[id] = now.Add(c.window)
+	if c.path != "" && now.Sub(c.savedAt) >= dedupSaveInterval {
+		// Non-fatal: a failed save only risks duplicates after a restart.
+		_ = c.saveLocked(now)
+	}
 	return false
 }
+
+// load reads the unexpired entries of the dedup_db. No-op when path is empty
+// or the file does not exist; a corrupt file is moved aside (see
+// persist.Load).
+func (c *dedupCache) load() error {
+	if c.path == "" {
+		return nil
+	}
+	err := persist.Load(c.path, false, func(data []byte) error {
+		var entries map[string]time.Time
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		now := c.clock.Now()
+		for id, expiry := range entries {
+			if now.Before(expiry) {
+				c.entries[id] = expiry
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("dedup cache: %w", err)
+	}
+	return nil
+}
+
+// save writes the dedup cache to the dedup_db. No-op when path is empty.
+func (c *dedupCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.saveLocked(c.clock.Now())
+}
+
+// saveLocked writes the cache atomically (write temp + rename). The caller
+// holds mu.
+func (c *dedupCache) saveLocked(now time.Time) error {
+	c.savedAt = now
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("dedup cache: marshal: %w", err)
+	}
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("dedup cache: write %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("dedup cache: rename to %s: %w", c.path, err)
+	}
+	return nil
+}
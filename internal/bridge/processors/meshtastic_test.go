@@ -2,11 +2,19 @@ package processors
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/dyuri/mqtt2irc/internal/bridge"
+	"github.com/dyuri/mqtt2irc/internal/persist"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
 	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
@@ -54,6 +62,17 @@ func TestMeshtasticProcessor_Dedup(t *testing.T) {
 	if !result.Drop {
 		t.Error("duplicate within window should be dropped")
 	}
+	if result.Reason != stats.DropDedup {
+		t.Errorf("Reason = %q, want %q", result.Reason, stats.DropDedup)
+	}
+}
+
+// withFakeClock switches a meshtastic processor to a fake clock.
+func withFakeClock(p *meshtasticProcessor) *schedule.Fake {
+	clk := schedule.NewFake(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	p.clock = clk
+	p.cache.clock = clk
+	return clk
 }
 
 func TestMeshtasticProcessor_Dedup_Expiry(t *testing.T) {
@@ -61,6 +80,7 @@ func TestMeshtasticProcessor_Dedup_Expiry(t *testing.T) {
 	if err != nil {
 		t.Fatalf("newMeshtasticProcessor: %v", err)
 	}
+	clk := withFakeClock(p.(*meshtasticProcessor))
 
 	msg := meshtasticMsg(99, "text", 222222, "!000036b2", map[string]interface{}{"text": "hi"})
 
@@ -69,7 +89,7 @@ func TestMeshtasticProcessor_Dedup_Expiry(t *testing.T) {
 		t.Error("first occurrence should not be dropped")
 	}
 
-	time.Sleep(100 * time.Millisecond)
+	clk.Advance(100 * time.Millisecond)
 
 	result, _ = p.Process(msg)
 	if result.Drop {
@@ -212,6 +232,34 @@ func TestMeshtasticProcessor_CustomFormats(t *testing.T) {
 	}
 }
 
+func TestMeshtasticProcessor_FormatsFile(t *testing.T) {
+	pack := filepath.Join(t.TempDir(), "formats.yaml")
+	content := "text: \"PACK {{.smart_from}}: {{.text}}\"\ntelemetry: \"TEL {{.battery_level}}\"\n"
+	if err := os.WriteFile(pack, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newMeshtasticProcessor(map[string]interface{}{
+		"formats_file": pack,
+		"formats":      map[string]interface{}{"telemetry": "INLINE {{.battery_level}}"},
+	})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+
+	text, _ := p.Process(meshtasticMsg(1, "text", 666, "!0000029a", map[string]interface{}{"text": "hi"}))
+	if text.Formatted != "PACK !0000029a: hi" {
+		t.Errorf("text = %q, want the formats_file template", text.Formatted)
+	}
+	tel, _ := p.Process(meshtasticMsg(2, "telemetry", 666, "!0000029a", map[string]interface{}{"battery_level": 80}))
+	if tel.Formatted != "INLINE 80" {
+		t.Errorf("telemetry = %q, want the inline formats to win", tel.Formatted)
+	}
+
+	if _, err := newMeshtasticProcessor(map[string]interface{}{"formats_file": filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("expected error for missing formats_file")
+	}
+}
+
 // --- smart_from ---
 
 func TestMeshtasticProcessor_SmartFrom_SenderFallback(t *testing.T) {
@@ -287,6 +335,123 @@ func TestMeshtasticProcessor_SmartFrom_RegistryShortname(t *testing.T) {
 	}
 }
 
+func TestMeshtasticProcessor_NodeNames(t *testing.T) {
+	tests := []struct {
+		config     map[string]interface{}
+		unknown    string // smart_from of a node not in the registry
+		known      string // smart_from of BOB
+		nodeIDText string // {{.node_id}} of 0xabcd1234
+	}{
+		{map[string]interface{}{}, "!abcd1234", "BOB", "!abcd1234"},
+		{map[string]interface{}{"node_id_format": "hex"}, "ABCD1234", "BOB", "ABCD1234"},
+		{map[string]interface{}{"node_id_format": "decimal", "smart_from_prefer": "longname"}, "2882343476", "Bob's Node", "2882343476"},
+	}
+	for _, tt := range tests {
+		tt.config["formats"] = map[string]interface{}{"text": "{{.smart_from}} {{.node_id}}"}
+		p, err := newMeshtasticProcessor(tt.config)
+		if err != nil {
+			t.Fatalf("newMeshtasticProcessor(%v): %v", tt.config, err)
+		}
+		res, _ := p.Process(meshtasticMsg(1, "text", 0xabcd1234, "!abcd1234", map[string]interface{}{"text": "hi"}))
+		if want := tt.unknown + " " + tt.nodeIDText; res.Formatted != want {
+			t.Errorf("%v: unknown node = %q, want %q", tt.config, res.Formatted, want)
+		}
+		p.Process(meshtasticMsg(2, "nodeinfo", 999, "!000003e7", map[string]interface{}{"shortname": "BOB", "longname": "Bob's Node"})) //nolint:errcheck
+		res, _ = p.Process(meshtasticMsg(3, "text", 999, "!000003e7", map[string]interface{}{"text": "hi"}))
+		if !strings.HasPrefix(res.Formatted, tt.known+" ") {
+			t.Errorf("%v: known node = %q, want %q", tt.config, res.Formatted, tt.known)
+		}
+	}
+
+	for _, config := range []map[string]interface{}{
+		{"node_id_format": "octal"},
+		{"smart_from_prefer": "nick"},
+		{"smart_from_chain": []interface{}{"registry.nick"}},
+		{"smart_from_chain": []interface{}{"alias"}}, // without aliases_file
+		{"smart_from_chain": []interface{}{"from"}, "smart_from_prefer": "longname"},
+	} {
+		if _, err := newMeshtasticProcessor(config); err == nil {
+			t.Errorf("%v accepted", config)
+		}
+	}
+}
+
+func TestMeshtasticProcessor_SmartFromChain(t *testing.T) {
+	aliases := filepath.Join(t.TempDir(), "aliases.yaml")
+	if err := os.WriteFile(aliases, []byte("\"!000003e7\": Bobby\n\"1000\": Relay\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newMeshtasticProcessor(map[string]interface{}{
+		"aliases_file":     aliases,
+		"smart_from_chain": []interface{}{"alias", "registry.shortname", "node_id"},
+		"formats":          map[string]interface{}{"text": "{{.smart_from}}"},
+	})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	p.Process(meshtasticMsg(1, "nodeinfo", 999, "!000003e7", map[string]interface{}{"shortname": "BOB"})) //nolint:errcheck
+	p.Process(meshtasticMsg(2, "nodeinfo", 77, "!0000004d", map[string]interface{}{"shortname": "CAT"}))  //nolint:errcheck
+	for _, tt := range []struct {
+		from   int
+		sender string
+		want   string
+	}{
+		{999, "!000003e7", "Bobby"}, // the alias wins over the registry
+		{1000, "", "Relay"},
+		{77, "!0000004d", "CAT"},
+		{5, "!00000005", "!00000005"},
+	} {
+		res, _ := p.Process(meshtasticMsg(10+tt.from, "text", tt.from, tt.sender, map[string]interface{}{"text": "hi"}))
+		if res.Formatted != tt.want {
+			t.Errorf("from %d = %q, want %q", tt.from, res.Formatted, tt.want)
+		}
+	}
+
+	if _, err := newMeshtasticProcessor(map[string]interface{}{"aliases_file": filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("missing aliases_file accepted")
+	}
+}
+
+func TestMeshtasticProcessor_HashIDs(t *testing.T) {
+	p, err := newMeshtasticProcessor(map[string]interface{}{
+		"hash_ids": map[string]interface{}{"salt": "s3cret", "clear_channels": []interface{}{"#Ops"}},
+		"formats":  map[string]interface{}{"text": "{{.smart_from}} {{.sender}} {{.node_id}}: {{.text}}"},
+	})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	h := p.(*meshtasticProcessor).hashIDs
+	hash := h.hash(999)
+	if len(hash) != 1+defaultIDHashLength || hash != h.hash(999) || hash == h.hash(1000) {
+		t.Fatalf("hash(999) = %q, want a stable %d-digit hash", hash, defaultIDHashLength)
+	}
+
+	res, _ := p.Process(meshtasticMsg(1, "text", 999, "!000003e7", map[string]interface{}{"text": "hi"}))
+	if want := hash + " " + hash + " " + hash + ": hi"; res.Formatted != want {
+		t.Errorf("public = %q, want %q", res.Formatted, want)
+	}
+	if want := "!000003e7 !000003e7 !000003e7: hi"; res.ChannelFormatted["#ops"] != want {
+		t.Errorf("#ops = %q, want %q", res.ChannelFormatted["#ops"], want)
+	}
+
+	// Names are no identifiers and stay as they are.
+	p.Process(meshtasticMsg(2, "nodeinfo", 999, "!000003e7", map[string]interface{}{"shortname": "BOB"})) //nolint:errcheck
+	res, _ = p.Process(meshtasticMsg(3, "text", 999, "!000003e7", map[string]interface{}{"text": "hi"}))
+	if want := "BOB " + hash + " " + hash + ": hi"; res.Formatted != want {
+		t.Errorf("public = %q, want %q", res.Formatted, want)
+	}
+
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"salt": "x", "length": 2},
+		{"salt": "x", "clear_channels": "#ops"},
+	} {
+		if _, err := newMeshtasticProcessor(map[string]interface{}{"hash_ids": cfg}); err == nil {
+			t.Errorf("hash_ids %v accepted", cfg)
+		}
+	}
+}
+
 func TestMeshtasticProcessor_SmartFrom_RegistryUpdate(t *testing.T) {
 	// Verify that a second nodeinfo for the same node updates the shortname.
 	p, err := newMeshtasticProcessor(map[string]interface{}{})
@@ -324,7 +489,7 @@ func TestMeshtasticProcessor_SmartFrom_RegistryUpdate(t *testing.T) {
 // --- node registry ---
 
 func TestNodeRegistry_GetUpdate(t *testing.T) {
-	r := newNodeRegistry("")
+	r := newNodeRegistry("", persist.Policy{})
 
 	_, ok := r.get("123")
 	if ok {
@@ -353,7 +518,7 @@ func TestNodeRegistry_Persistence(t *testing.T) {
 	path := filepath.Join(dir, "nodes.json")
 
 	// Write registry to disk.
-	r1 := newNodeRegistry(path)
+	r1 := newNodeRegistry(path, persist.Policy{})
 	if err := r1.load(); err != nil {
 		t.Fatalf("load (empty): %v", err)
 	}
@@ -361,7 +526,7 @@ func TestNodeRegistry_Persistence(t *testing.T) {
 	r1.update("99", nodeRecord{ShortName: "Y", LongName: "Yankee", UpdatedAt: time.Now()}) //nolint:errcheck
 
 	// New registry instance loads from same path.
-	r2 := newNodeRegistry(path)
+	r2 := newNodeRegistry(path, persist.Policy{})
 	if err := r2.load(); err != nil {
 		t.Fatalf("load (existing): %v", err)
 	}
@@ -384,6 +549,32 @@ func TestNodeRegistry_Persistence(t *testing.T) {
 	}
 }
 
+func TestNodeRegistry_MemoryMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.json")
+	r := newNodeRegistry(path, persist.Policy{Mode: persist.ModeMemory, Interval: time.Hour})
+
+	if err := r.update("42", nodeRecord{ShortName: "X", UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := r.snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("node_db written before the snapshot was due: %v", err)
+	}
+
+	if err := r.flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	r2 := newNodeRegistry(path, persist.Policy{})
+	if err := r2.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if rec, ok := r2.get("42"); !ok || rec.ShortName != "X" {
+		t.Errorf("after flush: %+v, %v", rec, ok)
+	}
+}
+
 func TestNodeRegistry_PersistenceWithProcessor(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "nodes.json")
@@ -400,6 +591,7 @@ func TestNodeRegistry_PersistenceWithProcessor(t *testing.T) {
 	}))
 
 	// Second processor instance (simulating restart) should have the shortname.
+	forgetNodeRegistries()
 	p2, err := newMeshtasticProcessor(map[string]interface{}{"node_db": path})
 	if err != nil {
 		t.Fatalf("newMeshtasticProcessor (reload): %v", err)
@@ -413,18 +605,128 @@ func TestNodeRegistry_PersistenceWithProcessor(t *testing.T) {
 	}
 }
 
+// forgetNodeRegistries drops the shared registries, as a restart would.
+func forgetNodeRegistries() {
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	registries = make(map[string]*nodeRegistry)
+}
+
+func TestNodeRegistry_SharedAcrossRegions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.json")
+	eu, err := newMeshtasticProcessor(map[string]interface{}{"node_db": path})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	us, err := newMeshtasticProcessor(map[string]interface{}{"node_db": path})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	if eu.(*meshtasticProcessor).nodes != us.(*meshtasticProcessor).nodes {
+		t.Fatal("processors with the same node_db should share the registry")
+	}
+
+	in := func(region string, msg types.Message) types.Message {
+		msg.Topic = "msh/" + region + "/2/json/LongFast/!gateway"
+		return msg
+	}
+	eu.Process(in("EU_868", meshtasticMsg(1, "nodeinfo", 100, "!00000064", map[string]interface{}{"shortname": "HILL"}))) //nolint:errcheck
+	eu.Process(in("EU_868", meshtasticMsg(2, "nodeinfo", 300, "!0000012c", map[string]interface{}{"shortname": "SOLO"}))) //nolint:errcheck
+
+	text := in("EU_868", meshtasticMsg(3, "text", 100, "!00000064", map[string]interface{}{"text": "hi"}))
+	if result, _ := eu.Process(text); result.Formatted != "🖊️ HILL: hi" {
+		t.Errorf("before the collision: %q", result.Formatted)
+	}
+
+	us.Process(in("US", meshtasticMsg(4, "nodeinfo", 200, "!000000c8", map[string]interface{}{"shortname": "HILL"}))) //nolint:errcheck
+	for _, tc := range []struct {
+		p    bridge.Processor
+		msg  types.Message
+		want string
+	}{
+		{eu, in("EU_868", meshtasticMsg(5, "text", 100, "!00000064", map[string]interface{}{"text": "hi"})), "🖊️ HILL@EU_868: hi"},
+		{us, in("US", meshtasticMsg(6, "text", 200, "!000000c8", map[string]interface{}{"text": "hi"})), "🖊️ HILL@US: hi"},
+		{eu, in("EU_868", meshtasticMsg(7, "text", 300, "!0000012c", map[string]interface{}{"text": "hi"})), "🖊️ SOLO: hi"},
+	} {
+		if result, _ := tc.p.Process(tc.msg); result.Formatted != tc.want {
+			t.Errorf("got %q, want %q", result.Formatted, tc.want)
+		}
+	}
+
+	rec, _ := eu.(*meshtasticProcessor).nodes.get("100")
+	if _, ok := rec.Seen["EU_868"]; !ok || len(rec.Seen) != 1 {
+		t.Errorf("node 100 seen in %v, want EU_868", rec.Seen)
+	}
+}
+
+func TestNodeRegistry_MergeSeen(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := newNodeRegistry("", persist.Policy{})
+	r.update("1", nodeRecord{ShortName: "A", UpdatedAt: t0, Seen: map[string]time.Time{"EU_868": t0}}) //nolint:errcheck
+
+	// An older record keeps the names but adds where the node was heard.
+	r.merge(map[string]nodeRecord{"1": {ShortName: "OLD", UpdatedAt: t0.Add(-time.Hour), Seen: map[string]time.Time{"US": t0}}})
+	rec, _ := r.get("1")
+	if rec.ShortName != "A" || len(rec.Seen) != 2 {
+		t.Errorf("after merging an older record: %+v", rec)
+	}
+
+	// A newer record renames the node and keeps the latest time per region.
+	r.merge(map[string]nodeRecord{"1": {ShortName: "B", UpdatedAt: t0.Add(time.Hour), Seen: map[string]time.Time{"EU_868": t0.Add(-time.Hour)}}})
+	rec, _ = r.get("1")
+	if rec.ShortName != "B" || !rec.Seen["EU_868"].Equal(t0) || len(rec.Seen) != 2 {
+		t.Errorf("after merging a newer record: %+v", rec)
+	}
+	if r.byShort["A"] != nil || !r.byShort["B"]["1"] {
+		t.Errorf("shortname index after rename: %v", r.byShort)
+	}
+}
+
 func TestNodeRegistry_MissingFile(t *testing.T) {
 	// A non-existent file should not be an error (fresh start).
-	r := newNodeRegistry(filepath.Join(t.TempDir(), "nonexistent.json"))
+	r := newNodeRegistry(filepath.Join(t.TempDir(), "nonexistent.json"), persist.Policy{})
 	if err := r.load(); err != nil {
 		t.Errorf("load of missing file should not error, got: %v", err)
 	}
 }
 
+func TestNodeRegistry_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.json")
+	policy := persist.Policy{Backup: true}
+	r1 := newNodeRegistry(path, policy)
+	r1.update("42", nodeRecord{ShortName: "X", UpdatedAt: time.Now()}) //nolint:errcheck
+	r1.update("99", nodeRecord{ShortName: "Y", UpdatedAt: time.Now()}) //nolint:errcheck
+
+	// Truncated by a power loss: recovered from the backup of the last write.
+	if err := os.WriteFile(path, []byte(`{"42": {"short`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r2 := newNodeRegistry(path, policy)
+	if err := r2.load(); err != nil {
+		t.Fatalf("load of corrupt file: %v", err)
+	}
+	if rec, ok := r2.get("42"); !ok || rec.ShortName != "X" || len(r2.byShort["X"]) != 1 {
+		t.Errorf("not recovered from backup: %+v, %v", rec, ok)
+	}
+
+	// Without a backup the registry starts empty.
+	if err := os.WriteFile(path, []byte("\x00\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r3 := newNodeRegistry(path, persist.Policy{})
+	if err := r3.load(); err != nil {
+		t.Fatalf("load of corrupt file: %v", err)
+	}
+	if len(r3.nodes) != 0 {
+		t.Errorf("nodes = %v, want a fresh start", r3.nodes)
+	}
+}
+
 // --- dedup cache ---
 
 func TestDedupCache(t *testing.T) {
-	c := newDedupCache(100 * time.Millisecond)
+	clk := schedule.NewFake(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	c := newDedupCache(100*time.Millisecond, clk)
 
 	if c.seen("abc") {
 		t.Error("first call should return false")
@@ -433,7 +735,7 @@ func TestDedupCache(t *testing.T) {
 		t.Error("second call within window should return true")
 	}
 
-	time.Sleep(150 * time.Millisecond)
+	clk.Advance(150 * time.Millisecond)
 
 	if c.seen("abc") {
 		t.Error("call after window expiry should return false")
@@ -443,3 +745,469 @@ func TestDedupCache(t *testing.T) {
 func containsStr(s, sub string) bool {
 	return strings.Contains(s, sub)
 }
+
+// --- position privacy ---
+
+func TestMeshtasticProcessor_PositionPrecision(t *testing.T) {
+	p, err := newMeshtasticProcessor(map[string]interface{}{
+		"position_precision": 2,
+		"formats":            map[string]interface{}{"position": "{{.latitude_i}},{{.longitude_i}}"},
+	})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	msg := meshtasticMsg(1, "position", 222, "!000000de", map[string]interface{}{
+		"latitude_i":  474979123,
+		"longitude_i": 190402456,
+	})
+	result, err := p.Process(msg)
+	if err != nil {
+		t.Fatalf("Process error: %v", err)
+	}
+	if want := "475000000,190400000"; result.Formatted != want {
+		t.Errorf("Formatted = %q, want %q", result.Formatted, want)
+	}
+}
+
+func TestMeshtasticProcessor_PrivateZone(t *testing.T) {
+	p, err := newMeshtasticProcessor(map[string]interface{}{
+		"private_zones": []interface{}{
+			map[string]interface{}{"lat": 47.4979, "lon": 19.0402, "radius_m": 500},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+
+	home := meshtasticMsg(1, "position", 222, "!000000de", map[string]interface{}{
+		"latitude_i": 474990000, "longitude_i": 190410000, // ~150 m away
+	})
+	if result, _ := p.Process(home); !result.Drop || result.Reason != stats.DropPrivacy {
+		t.Errorf("position inside private zone should be dropped for privacy, got %+v", result)
+	}
+
+	away := meshtasticMsg(2, "position", 222, "!000000de", map[string]interface{}{
+		"latitude_i": 475300000, "longitude_i": 190410000, // ~3.5 km away
+	})
+	if result, _ := p.Process(away); result.Drop {
+		t.Error("position outside private zone should not be dropped")
+	}
+
+	// Other message types from the same node are unaffected.
+	text := meshtasticMsg(3, "text", 222, "!000000de", map[string]interface{}{"text": "hi"})
+	if result, _ := p.Process(text); result.Drop {
+		t.Error("text message should not be dropped")
+	}
+}
+
+func TestMeshtasticProcessor_ReverseGeocode(t *testing.T) {
+	dataset := filepath.Join(t.TempDir(), "places.csv")
+	if err := os.WriteFile(dataset, []byte("Budapest,HU,47.4979,19.0402\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newMeshtasticProcessor(map[string]interface{}{
+		"reverse_geocode": map[string]interface{}{"dataset": dataset, "max_distance_km": 30},
+		"formats":         map[string]interface{}{"position": "{{.smart_from}}{{if .place}} near {{.place}}{{end}}"},
+	})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+
+	tests := []struct {
+		lat, lon int
+		want     string
+	}{
+		{475300000, 190410000, "!000000de near Budapest, HU"},
+		{460000000, 250000000, "!000000de"}, // too far from any place
+	}
+	for i, tt := range tests {
+		msg := meshtasticMsg(i+1, "position", 222, "!000000de", map[string]interface{}{
+			"latitude_i": tt.lat, "longitude_i": tt.lon,
+		})
+		result, err := p.Process(msg)
+		if err != nil {
+			t.Fatalf("Process error: %v", err)
+		}
+		if result.Formatted != tt.want {
+			t.Errorf("Formatted = %q, want %q", result.Formatted, tt.want)
+		}
+	}
+
+	if _, err := newMeshtasticProcessor(map[string]interface{}{"reverse_geocode": "yes"}); err == nil {
+		t.Error("expected error for non-map reverse_geocode")
+	}
+}
+
+func TestMeshtasticProcessor_PrivacyConfigErrors(t *testing.T) {
+	configs := []map[string]interface{}{
+		{"position_precision": 9},
+		{"position_precision": "x"},
+		{"private_zones": "home"},
+		{"private_zones": []interface{}{map[string]interface{}{"lat": 47.0, "lon": 19.0}}},
+		{"private_zones": []interface{}{map[string]interface{}{"lat": "north", "lon": 19.0, "radius_m": 100}}},
+	}
+	for _, cfg := range configs {
+		if _, err := newMeshtasticProcessor(cfg); err == nil {
+			t.Errorf("newMeshtasticProcessor(%v) succeeded, want error", cfg)
+		}
+	}
+}
+
+// --- benchmarks ---
+
+func BenchmarkFlattenMeshtastic(b *testing.B) {
+	msg := meshtasticMsg(1, "telemetry", 3663915068, "!da5ed03c", map[string]interface{}{
+		"battery_level": 97, "voltage": 4.1, "air_util_tx": 0.8, "channel_utilization": 12.5,
+	})
+	var raw map[string]interface{}
+	if err := json.Unmarshal(msg.Payload, &raw); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		releaseData(flattenMeshtastic(raw, "telemetry"))
+	}
+}
+
+func BenchmarkDedupCache_Seen(b *testing.B) {
+	c := newDedupCache(time.Minute, schedule.Real)
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%d", i)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.seen(ids[i%len(ids)])
+	}
+}
+
+func BenchmarkMeshtasticProcessor_Process(b *testing.B) {
+	p, err := newMeshtasticProcessor(map[string]interface{}{"dedup_window": "1m"})
+	if err != nil {
+		b.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	msgs := make([]types.Message, 1000)
+	for i := range msgs {
+		msgs[i] = meshtasticMsg(i, "text", 3663915068, "!da5ed03c", map[string]interface{}{"text": "hello mesh"})
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Process(msgs[i%len(msgs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMeshtasticProcessor_CachedJSON(t *testing.T) {
+	p, err := newMeshtasticProcessor(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	// A payload already decoded by the bridge is not parsed again.
+	msg := types.Message{
+		Topic:      "msh/test",
+		Payload:    []byte("not json"),
+		JSONParsed: true,
+		JSON: map[string]interface{}{
+			"id": 1.0, "type": "text", "from": 123.0, "sender": "!0000007b",
+			"payload": map[string]interface{}{"text": "cached"},
+		},
+	}
+	result, err := p.Process(msg)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if !strings.Contains(result.Formatted, "cached") {
+		t.Errorf("Formatted = %q, want the cached text", result.Formatted)
+	}
+}
+
+func TestMeshtasticProcessor_StateBundle(t *testing.T) {
+	old := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	src, _ := newMeshtasticProcessor(map[string]interface{}{})
+	srcNodes := src.(*meshtasticProcessor).nodes
+	_ = srcNodes.update("1", nodeRecord{ShortName: "NEW1", UpdatedAt: old.Add(time.Hour)})
+	_ = srcNodes.update("2", nodeRecord{ShortName: "OLD2", UpdatedAt: old})
+
+	state, err := src.(bridge.StatefulProcessor).ExportState()
+	if err != nil {
+		t.Fatalf("ExportState: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "nodes.json")
+	dst, _ := newMeshtasticProcessor(map[string]interface{}{"node_db": path})
+	dstNodes := dst.(*meshtasticProcessor).nodes
+	_ = dstNodes.update("1", nodeRecord{ShortName: "OLD1", UpdatedAt: old})
+	_ = dstNodes.update("2", nodeRecord{ShortName: "NEW2", UpdatedAt: old.Add(time.Hour)})
+
+	if err := dst.(bridge.StatefulProcessor).ImportState(state); err != nil {
+		t.Fatalf("ImportState: %v", err)
+	}
+	// The more recent record of each node wins, and the result is saved.
+	for from, want := range map[string]string{"1": "NEW1", "2": "NEW2"} {
+		if rec, _ := dstNodes.get(from); rec.ShortName != want {
+			t.Errorf("node %s = %q, want %q", from, rec.ShortName, want)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "NEW1") {
+		t.Errorf("node_db not saved after import: %s", data)
+	}
+}
+
+func TestMeshtasticProcessor_QuietTypes(t *testing.T) {
+	p, err := newMeshtasticProcessor(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	mp := p.(*meshtasticProcessor)
+
+	report := meshtasticMsg(1, "mapreport", 333, "!0000014d", map[string]interface{}{
+		"short_name": "HILL", "long_name": "Hilltop Router", "latitude_i": 475300000, "longitude_i": 190410000,
+	})
+	if result, _ := p.Process(report); !result.Drop || result.Reason != stats.DropQuiet {
+		t.Errorf("mapreport without a format should be dropped as quiet, got %+v", result)
+	}
+	if rec, ok := mp.nodes.get("333"); !ok || rec.ShortName != "HILL" || rec.LongName != "Hilltop Router" {
+		t.Errorf("mapreport names not registered: %+v", rec)
+	}
+
+	sf := meshtasticMsg(2, "storeforward", 333, "!0000014d", map[string]interface{}{"rr": "ROUTER_HEARTBEAT"})
+	if result, _ := p.Process(sf); !result.Drop || result.Reason != stats.DropQuiet {
+		t.Errorf("storeforward without a format should be dropped as quiet, got %+v", result)
+	}
+}
+
+func TestMeshtasticProcessor_QuietTypes_OptIn(t *testing.T) {
+	p, err := newMeshtasticProcessor(map[string]interface{}{
+		"position_precision": 2,
+		"formats": map[string]interface{}{
+			"mapreport":    "🗺 {{.smart_from}} @ {{.latitude_i}},{{.longitude_i}}",
+			"storeforward": "📦 {{.smart_from}} {{.rr}}",
+		},
+	})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+
+	report := meshtasticMsg(1, "mapreport", 333, "!0000014d", map[string]interface{}{
+		"short_name": "HILL", "latitude_i": 475312345, "longitude_i": 190412345,
+	})
+	result, err := p.Process(report)
+	if err != nil || result.Drop {
+		t.Fatalf("Process(mapreport) = %+v, %v", result, err)
+	}
+	if want := "🗺 HILL @ 475300000,190400000"; result.Formatted != want {
+		t.Errorf("mapreport = %q, want %q (rounded like positions)", result.Formatted, want)
+	}
+
+	sf := meshtasticMsg(2, "storeforward", 333, "!0000014d", map[string]interface{}{"rr": "ROUTER_HEARTBEAT"})
+	if result, _ := p.Process(sf); result.Formatted != "📦 HILL ROUTER_HEARTBEAT" {
+		t.Errorf("storeforward = %q", result.Formatted)
+	}
+}
+
+// --- activity digest ---
+
+func TestMeshtasticProcessor_Digest(t *testing.T) {
+	p, err := newMeshtasticProcessor(map[string]interface{}{
+		"digest": map[string]interface{}{"schedule": "0 8 * * *", "channel": "#mesh", "top": 2},
+	})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	mp := p.(*meshtasticProcessor)
+	clk := withFakeClock(mp)
+	mp.activity = newMeshActivity(clk.Now())
+	mp.nodes.update("333", nodeRecord{ShortName: "HILL"})
+
+	if cron, channel := mp.ReportSchedule(); cron != "0 8 * * *" || channel != "#mesh" {
+		t.Errorf("ReportSchedule() = %q, %q", cron, channel)
+	}
+	msgs := []types.Message{
+		meshtasticMsg(1, "text", 333, "!0000014d", map[string]interface{}{"text": "a"}),
+		meshtasticMsg(2, "text", 333, "!0000014d", map[string]interface{}{"text": "b"}),
+		meshtasticMsg(3, "position", 333, "!0000014d", map[string]interface{}{"latitude_i": 1}),
+		meshtasticMsg(4, "nodeinfo", 444, "!000001bc", map[string]interface{}{"shortname": "NEW1"}),
+		meshtasticMsg(5, "position", 555, "!0000022b", map[string]interface{}{"latitude_i": 1}),
+		meshtasticMsg(5, "position", 555, "!0000022b", map[string]interface{}{"latitude_i": 1}), // duplicate
+		meshtasticMsg(6, "storeforward", 555, "!0000022b", map[string]interface{}{"rr": "ROUTER_HEARTBEAT"}),
+	}
+	for _, msg := range msgs {
+		if _, err := p.Process(msg); err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+	}
+
+	clk.Advance(90 * time.Minute)
+	want := []string{
+		"📊 Mesh activity in the last 1h30m: 3 nodes heard (2 new), 6 messages",
+		"Most active: HILL 3, !0000022b 2",
+		"New nodes: !0000022b, NEW1",
+		"By type: position 2, text 2, nodeinfo 1, storeforward 1",
+	}
+	if got := mp.Report(clk.Now()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Report() =\n%q\nwant\n%q", got, want)
+	}
+
+	// Nodes heard in an earlier period are no longer new.
+	p.Process(meshtasticMsg(7, "text", 555, "!0000022b", map[string]interface{}{"text": "c"}))
+	clk.Advance(24 * time.Hour)
+	want = []string{
+		"📊 Mesh activity in the last 24h: 1 nodes heard (0 new), 1 messages",
+		"Most active: !0000022b 1",
+		"By type: text 1",
+	}
+	if got := mp.Report(clk.Now()); !reflect.DeepEqual(got, want) {
+		t.Errorf("second Report() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestMeshtasticProcessor_DigestConfig(t *testing.T) {
+	for name, digest := range map[string]interface{}{
+		"not a map":    "daily",
+		"no schedule":  map[string]interface{}{"channel": "#mesh"},
+		"bad schedule": map[string]interface{}{"schedule": "every day"},
+		"bad top":      map[string]interface{}{"schedule": "@daily", "top": 0},
+	} {
+		if _, err := newMeshtasticProcessor(map[string]interface{}{"digest": digest}); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+	p, err := newMeshtasticProcessor(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	if cron, _ := p.(*meshtasticProcessor).ReportSchedule(); cron != "" {
+		t.Errorf("ReportSchedule() without digest = %q, want none", cron)
+	}
+}
+
+// --- persistent dedup ---
+
+func TestMeshtasticProcessor_DedupDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.json")
+	p1, err := newMeshtasticProcessor(map[string]interface{}{"dedup_db": path})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	msg := meshtasticMsg(1, "text", 100, "!00000064", map[string]interface{}{"text": "hi"})
+	if result, _ := p1.Process(msg); result.Drop {
+		t.Fatal("first occurrence dropped")
+	}
+	if err := p1.(bridge.FlushingProcessor).Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	// A restarted processor still knows the rebroadcast message.
+	p2, err := newMeshtasticProcessor(map[string]interface{}{"dedup_db": path})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor (restart): %v", err)
+	}
+	if result, _ := p2.Process(msg); !result.Drop || result.Reason != stats.DropDedup {
+		t.Errorf("rebroadcast after restart = %+v, want dedup drop", result)
+	}
+
+	// Expired entries are not loaded.
+	expired, _ := json.Marshal(map[string]time.Time{"2": time.Now().Add(-time.Minute)})
+	if err := os.WriteFile(path, expired, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p3, err := newMeshtasticProcessor(map[string]interface{}{"dedup_db": path})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	if n := len(p3.(*meshtasticProcessor).cache.entries); n != 0 {
+		t.Errorf("%d expired entries loaded", n)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A corrupt dedup_db is moved aside instead of failing startup.
+	p4, err := newMeshtasticProcessor(map[string]interface{}{"dedup_db": path})
+	if err != nil {
+		t.Fatalf("corrupt dedup_db: %v", err)
+	}
+	if n := len(p4.(*meshtasticProcessor).cache.entries); n != 0 {
+		t.Errorf("%d entries loaded from a corrupt dedup_db", n)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("corrupt dedup_db left in place: %v", err)
+	}
+}
+
+func TestMeshtasticProcessor_DedupKey(t *testing.T) {
+	p, err := newMeshtasticProcessor(map[string]interface{}{"dedup_key": "{{.from}}-{{.id}}"})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	first := meshtasticMsg(7, "text", 100, "!00000064", map[string]interface{}{"text": "a"})
+	collision := meshtasticMsg(7, "text", 200, "!000000c8", map[string]interface{}{"text": "b"})
+	for i, msg := range []types.Message{first, collision} {
+		if result, _ := p.Process(msg); result.Drop {
+			t.Errorf("message %d dropped: same id, different node", i)
+		}
+	}
+	if result, _ := p.Process(first); !result.Drop {
+		t.Error("repeated message not dropped")
+	}
+
+	// Nested IDs; messages without them are not deduplicated.
+	p, err = newMeshtasticProcessor(map[string]interface{}{"dedup_key": "{{.payload.seq}}"})
+	if err != nil {
+		t.Fatalf("newMeshtasticProcessor: %v", err)
+	}
+	seq := meshtasticMsg(1, "text", 100, "", map[string]interface{}{"seq": 5, "text": "a"})
+	if result, _ := p.Process(seq); result.Drop {
+		t.Error("first nested id dropped")
+	}
+	if result, _ := p.Process(meshtasticMsg(2, "text", 100, "", map[string]interface{}{"seq": 5, "text": "a"})); !result.Drop {
+		t.Error("repeated nested id not dropped")
+	}
+	noSeq := meshtasticMsg(3, "text", 100, "", map[string]interface{}{"text": "a"})
+	for i := 0; i < 2; i++ {
+		if result, _ := p.Process(noSeq); result.Drop {
+			t.Error("message without the key dropped")
+		}
+	}
+
+	if _, err := newMeshtasticProcessor(map[string]interface{}{"dedup_key": "{{.id"}); err == nil {
+		t.Error("invalid dedup_key: want error")
+	}
+}
+
+func TestMeshtasticProcessor_Purge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.json")
+	p := &meshtasticProcessor{nodes: newNodeRegistry(path, persist.Policy{Backup: true})}
+	p.nodes.update("2882343476", nodeRecord{ShortName: "HILL", LongName: "Hilltop", UpdatedAt: time.Now()}) //nolint:errcheck
+	p.nodes.update("42", nodeRecord{ShortName: "X", UpdatedAt: time.Now()})                                 //nolint:errcheck
+
+	n, terms, err := p.Purge(bridge.PurgeNode, "!abcd1234")
+	if err != nil || n != 1 {
+		t.Fatalf("Purge = %d, %v", n, err)
+	}
+	if !slices.Contains(terms, "HILL") || !slices.Contains(terms, "Hilltop") {
+		t.Errorf("terms = %q, want the node's names", terms)
+	}
+	if _, ok := p.nodes.get("2882343476"); ok || len(p.nodes.byShort["HILL"]) != 0 {
+		t.Error("node still in the registry")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || strings.Contains(string(data), "HILL") {
+		t.Errorf("node_db = %s, %v, want the node gone", data, err)
+	}
+	if _, err := os.Stat(path + persist.BackupSuffix); !os.IsNotExist(err) {
+		t.Errorf("backup kept after purge: %v", err)
+	}
+
+	if n, _, _ := p.Purge(bridge.PurgeNode, "!abcd1234"); n != 0 {
+		t.Errorf("second Purge removed %d", n)
+	}
+	if n, _, err := p.Purge(bridge.PurgeNick, "alice"); n != 0 || err != nil {
+		t.Errorf("Purge(nick) = %d, %v", n, err)
+	}
+}
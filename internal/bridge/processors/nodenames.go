@@ -0,0 +1,199 @@
+package processors
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Node ID formats of the node_id_format processor option.
+const (
+	nodeIDBang    = "bang"    // !abcd1234, as Meshtastic apps show it
+	nodeIDHex     = "hex"     // ABCD1234
+	nodeIDDecimal = "decimal" // 2882343476, the raw from field
+)
+
+// Steps of the smart_from_chain processor option.
+const (
+	nameShortname = "registry.shortname" // learned from nodeinfo and map reports
+	nameLongname  = "registry.longname"
+	nameAlias     = "alias"   // aliases_file
+	nameSender    = "sender"  // the message's sender field
+	nameFrom      = "from"    // the raw from field
+	nameNodeID    = "node_id" // the node ID in node_id_format
+)
+
+var nameSteps = map[string]bool{
+	nameShortname: true, nameLongname: true, nameAlias: true,
+	nameSender: true, nameFrom: true, nameNodeID: true,
+}
+
+// nodeNames is how the Meshtastic processor displays node names and IDs
+// (smart_from_chain, smart_from_prefer, aliases_file, node_id_format).
+type nodeNames struct {
+	chain    []string          // steps tried in order; the first non-empty one is shown
+	aliases  map[uint32]string // aliases_file: node ID → name
+	idFormat string            // node_id_format; empty = !abcd1234 for {{.node_id}}
+}
+
+// parseNodeNames reads the name options of a processor. Without
+// smart_from_chain the chain is the registry shortname (or longname first,
+// with smart_from_prefer: longname), an alias if aliases_file is set, and
+// then the sender field and the raw from, preceded by the node ID if
+// node_id_format is set.
+func parseNodeNames(config map[string]interface{}) (nodeNames, error) {
+	var n nodeNames
+	if v, ok := config["node_id_format"]; ok {
+		n.idFormat = strings.ToLower(fmt.Sprintf("%v", v))
+		switch n.idFormat {
+		case nodeIDBang, nodeIDHex, nodeIDDecimal:
+		default:
+			return n, fmt.Errorf("invalid node_id_format %q (want bang, hex or decimal)", v)
+		}
+	}
+	if v, ok := config["aliases_file"]; ok {
+		aliases, err := loadAliasesFile(fmt.Sprintf("%v", v))
+		if err != nil {
+			return n, err
+		}
+		n.aliases = aliases
+	}
+
+	if v, ok := config["smart_from_chain"]; ok {
+		if _, ok := config["smart_from_prefer"]; ok {
+			return n, fmt.Errorf("smart_from_chain and smart_from_prefer are mutually exclusive")
+		}
+		list, ok := v.([]interface{})
+		if !ok || len(list) == 0 {
+			return n, fmt.Errorf("smart_from_chain must be a non-empty list")
+		}
+		for _, item := range list {
+			step := strings.ToLower(fmt.Sprintf("%v", item))
+			if !nameSteps[step] {
+				return n, fmt.Errorf("invalid smart_from_chain step %q (want registry.shortname, registry.longname, alias, sender, from or node_id)", item)
+			}
+			if step == nameAlias && n.aliases == nil {
+				return n, fmt.Errorf("smart_from_chain step %q needs aliases_file", step)
+			}
+			n.chain = append(n.chain, step)
+		}
+		return n, nil
+	}
+
+	n.chain = []string{nameShortname}
+	if v, ok := config["smart_from_prefer"]; ok {
+		switch strings.ToLower(fmt.Sprintf("%v", v)) {
+		case "shortname":
+		case "longname":
+			n.chain = []string{nameLongname, nameShortname}
+		default:
+			return n, fmt.Errorf("invalid smart_from_prefer %q (want shortname or longname)", v)
+		}
+	}
+	if n.aliases != nil {
+		n.chain = append(n.chain, nameAlias)
+	}
+	if n.idFormat != "" {
+		n.chain = append(n.chain, nameNodeID)
+	}
+	n.chain = append(n.chain, nameSender, nameFrom)
+	return n, nil
+}
+
+// loadAliasesFile reads an alias table: a YAML map of node ID (!abcd1234 or
+// decimal) → display name, referenced by a processor's aliases_file option.
+func loadAliasesFile(path string) (map[uint32]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read aliases_file: %w", err)
+	}
+	var names map[string]string
+	if err := yaml.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("aliases_file %s: %w", path, err)
+	}
+	aliases := make(map[uint32]string, len(names))
+	for key, name := range names {
+		id, ok := parseNodeID(key, key)
+		if !ok {
+			return nil, fmt.Errorf("aliases_file %s: invalid node ID %q", path, key)
+		}
+		aliases[id] = name
+	}
+	return aliases, nil
+}
+
+// resolve returns the first non-empty step of the chain for a node; rec is
+// its registry entry, if known. It also returns the step, e.g. to tell a
+// registry shortname, which may need a region to tell it apart, from an ID.
+func (n nodeNames) resolve(from, sender string, rec nodeRecord) (name, step string) {
+	for _, step := range n.chain {
+		var name string
+		switch step {
+		case nameShortname:
+			name = rec.ShortName
+		case nameLongname:
+			name = rec.LongName
+		case nameAlias:
+			if id, ok := parseNodeID(from, sender); ok {
+				name = n.aliases[id]
+			}
+		case nameSender:
+			name = sender
+		case nameFrom:
+			name = from
+		case nameNodeID:
+			name = n.nodeID(from, sender)
+		}
+		if name != "" {
+			return name, step
+		}
+	}
+	return "", ""
+}
+
+// isIDStep reports whether a step resolves to a node ID rather than a name.
+func isIDStep(step string) bool {
+	return step == nameSender || step == nameFrom || step == nameNodeID
+}
+
+// nodeID renders the node ID for {{.node_id}}: in node_id_format, by default
+// as !abcd1234. It is "" when the message has no usable ID.
+func (n nodeNames) nodeID(from, sender string) string {
+	id, ok := parseNodeID(from, sender)
+	if !ok {
+		return ""
+	}
+	format := n.idFormat
+	if format == "" {
+		format = nodeIDBang
+	}
+	return formatNodeID(id, format)
+}
+
+// parseNodeID returns the numeric node ID of the raw from value (decimal)
+// or, failing that, of the sender field (!hex).
+func parseNodeID(from, sender string) (uint32, bool) {
+	if id, err := strconv.ParseUint(from, 10, 32); err == nil {
+		return uint32(id), true
+	}
+	if hex, ok := strings.CutPrefix(sender, "!"); ok {
+		if id, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return uint32(id), true
+		}
+	}
+	return 0, false
+}
+
+func formatNodeID(id uint32, format string) string {
+	switch format {
+	case nodeIDHex:
+		return fmt.Sprintf("%08X", id)
+	case nodeIDDecimal:
+		return strconv.FormatUint(uint64(id), 10)
+	default:
+		return fmt.Sprintf("!%08x", id)
+	}
+}
@@ -0,0 +1,102 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// privateZone is a circular geofence inside which positions are suppressed.
+type privateZone struct {
+	lat, lon float64 // degrees
+	radius   float64 // metres
+}
+
+// positionPrivacy holds the position privacy options of a processor.
+type positionPrivacy struct {
+	precision int // decimal places kept in coordinates; -1 = unchanged
+	zones     []privateZone
+}
+
+// parsePositionPrivacy reads position_precision and private_zones from a
+// processor config map.
+func parsePositionPrivacy(config map[string]interface{}) (positionPrivacy, error) {
+	pp := positionPrivacy{precision: -1}
+
+	if v, ok := config["position_precision"]; ok {
+		n, err := strconv.Atoi(fmt.Sprintf("%v", v))
+		if err != nil || n < 0 || n > 7 {
+			return pp, fmt.Errorf("invalid position_precision %v (want 0-7 decimal places)", v)
+		}
+		pp.precision = n
+	}
+
+	if v, ok := config["private_zones"]; ok {
+		list, ok := v.([]interface{})
+		if !ok {
+			return pp, fmt.Errorf("private_zones must be a list")
+		}
+		for i, item := range list {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return pp, fmt.Errorf("private_zones[%d] must be a map with lat, lon and radius_m", i)
+			}
+			var z privateZone
+			var err error
+			if z.lat, err = toFloat(m["lat"]); err != nil {
+				return pp, fmt.Errorf("private_zones[%d].lat: %w", i, err)
+			}
+			if z.lon, err = toFloat(m["lon"]); err != nil {
+				return pp, fmt.Errorf("private_zones[%d].lon: %w", i, err)
+			}
+			if z.radius, err = toFloat(m["radius_m"]); err != nil || z.radius <= 0 {
+				return pp, fmt.Errorf("private_zones[%d].radius_m must be a positive number", i)
+			}
+			pp.zones = append(pp.zones, z)
+		}
+	}
+	return pp, nil
+}
+
+// suppressed reports whether a position lies inside any private zone.
+func (pp positionPrivacy) suppressed(lat, lon float64) bool {
+	for _, z := range pp.zones {
+		if haversine(lat, lon, z.lat, z.lon) <= z.radius {
+			return true
+		}
+	}
+	return false
+}
+
+// round rounds a coordinate in degrees to the configured precision.
+func (pp positionPrivacy) round(deg float64) float64 {
+	if pp.precision < 0 {
+		return deg
+	}
+	f := math.Pow(10, float64(pp.precision))
+	return math.Round(deg*f) / f
+}
+
+// haversine returns the great-circle distance in metres.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case nil:
+		return 0, fmt.Errorf("missing")
+	default:
+		return strconv.ParseFloat(fmt.Sprintf("%v", v), 64)
+	}
+}
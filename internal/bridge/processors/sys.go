@@ -0,0 +1,192 @@
+package processors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/bridge"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+const defaultSysFormat = "📊 MQTT broker {{.Version}}: {{.Clients}} clients, {{.MessagesIn}} msg/s in, {{.MessagesOut}} msg/s out, up {{.Uptime}}"
+
+// sysProcessor summarizes a broker's $SYS topics (as published by Mosquitto)
+// into one digest line per interval instead of a line per topic.
+type sysProcessor struct {
+	interval time.Duration
+	format   *template.Template
+	clock    schedule.Clock
+
+	mu     sync.Mutex
+	values map[string]string // latest value by $SYS path, e.g. "broker/clients/connected"
+	start  time.Time         // of the current digest period; zero before the first message
+	in     counterStart      // broker/messages/received
+	out    counterStart      // broker/messages/sent
+}
+
+// counterStart is a message counter's first value in a digest period.
+type counterStart struct {
+	path  string
+	value float64 // -1 until the counter is seen in the period
+	at    time.Time
+}
+
+// observe takes the counter's value at now if it has none yet.
+func (c *counterStart) observe(p *sysProcessor, now time.Time) {
+	if c.value < 0 {
+		c.value, c.at = p.number(c.path), now
+	}
+}
+
+// sysDoc declares the sys processor's processor_config options.
+var sysDoc = types.ComponentDoc{
+	Summary: "Summarizes a broker's $SYS topics into one digest line per interval",
+	Options: []types.OptionDoc{
+		{Name: "interval", Type: "duration", Default: "5m", Description: "Time between digests"},
+		{Name: "format", Type: "template", Default: defaultSysFormat, Description: "Fields Version, Clients, MessagesIn, MessagesOut, Uptime, Values (by $SYS path)"},
+	},
+}
+
+// newSysProcessor creates a $SYS digest processor from a config map.
+func newSysProcessor(config map[string]interface{}) (bridge.Processor, error) {
+	p := &sysProcessor{
+		interval: 5 * time.Minute,
+		clock:    schedule.Real,
+		values:   make(map[string]string),
+		in:       counterStart{path: "broker/messages/received"},
+		out:      counterStart{path: "broker/messages/sent"},
+	}
+	if v, ok := config["interval"]; ok {
+		d, err := time.ParseDuration(fmt.Sprintf("%v", v))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("sys: invalid interval %q", v)
+		}
+		p.interval = d
+	}
+	format := defaultSysFormat
+	if v, ok := config["format"]; ok {
+		format = fmt.Sprintf("%v", v)
+	}
+	tmpl, err := template.New("sys").Option("missingkey=zero").Funcs(irc.Funcs).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("sys: invalid format template: %w", err)
+	}
+	p.format = tmpl
+	return p, nil
+}
+
+// Process records a $SYS value and, once per interval, posts the digest.
+// Other messages are dropped as digested.
+func (p *sysProcessor) Process(msg types.Message) (bridge.ProcessResult, error) {
+	now := p.clock.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.values[sysPath(msg.Topic)] = strings.TrimSpace(string(msg.Payload))
+	if p.start.IsZero() {
+		p.begin(now)
+	}
+	if now.Sub(p.start) < p.interval {
+		p.in.observe(p, now)
+		p.out.observe(p, now)
+		return bridge.ProcessResult{Drop: true, Reason: stats.DropDigest}, nil
+	}
+
+	data := p.digest(now)
+	p.begin(now)
+
+	buf := irc.GetBuffer()
+	defer irc.PutBuffer(buf)
+	if err := p.format.Execute(buf, data); err != nil {
+		return bridge.ProcessResult{}, fmt.Errorf("sys: template execution failed: %w", err)
+	}
+	return bridge.ProcessResult{Formatted: buf.String()}, nil
+}
+
+// begin starts a digest period at now.
+func (p *sysProcessor) begin(now time.Time) {
+	p.start = now
+	p.in.value, p.out.value = -1, -1
+	p.in.observe(p, now)
+	p.out.observe(p, now)
+}
+
+// digest returns the template data for the period ending at now. Unknown
+// values are "?".
+func (p *sysProcessor) digest(now time.Time) map[string]interface{} {
+	values := make(map[string]string, len(p.values))
+	for k, v := range p.values {
+		values[k] = irc.StripMarkers(v)
+	}
+	value := func(paths ...string) string {
+		for _, path := range paths {
+			if v, ok := values[path]; ok {
+				return v
+			}
+		}
+		return "?"
+	}
+	return map[string]interface{}{
+		"Version":     strings.TrimPrefix(value("broker/version"), "mosquitto version "),
+		"Clients":     value("broker/clients/connected", "broker/clients/active"),
+		"MessagesIn":  p.rate(p.in, "broker/load/messages/received/1min", now),
+		"MessagesOut": p.rate(p.out, "broker/load/messages/sent/1min", now),
+		"Uptime":      formatUptime(value("broker/uptime")),
+		"Values":      values,
+	}
+}
+
+// rate returns messages per second since the counter's start, or from the
+// broker's one-minute load average when the counter is unknown or went
+// backwards (broker restart).
+func (p *sysProcessor) rate(c counterStart, load string, now time.Time) string {
+	if v, elapsed := p.number(c.path), now.Sub(c.at).Seconds(); c.value >= 0 && v >= c.value && elapsed > 0 {
+		return strconv.FormatFloat((v-c.value)/elapsed, 'f', 1, 64)
+	}
+	if perMinute := p.number(load); perMinute >= 0 {
+		return strconv.FormatFloat(perMinute/60, 'f', 1, 64)
+	}
+	return "?"
+}
+
+// number returns the numeric value of path, or -1.
+func (p *sysProcessor) number(path string) float64 {
+	f, err := strconv.ParseFloat(p.values[path], 64)
+	if err != nil || f < 0 {
+		return -1
+	}
+	return f
+}
+
+// sysPath returns the part of topic after "$SYS/", so brokers bridged under
+// a prefix (e.g. "site1/$SYS/broker/uptime") use the same paths.
+func sysPath(topic string) string {
+	if i := strings.Index(topic, "$SYS/"); i >= 0 {
+		return topic[i+len("$SYS/"):]
+	}
+	return topic
+}
+
+// formatUptime shortens Mosquitto's "93784 seconds" to "1d 2h".
+func formatUptime(s string) string {
+	secs, err := strconv.ParseInt(strings.TrimSuffix(s, " seconds"), 10, 64)
+	if err != nil {
+		return s
+	}
+	d := time.Duration(secs) * time.Second
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", d/time.Hour, d%time.Hour/time.Minute)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
@@ -0,0 +1,96 @@
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestSysProcessor_Digest(t *testing.T) {
+	p, err := newSysProcessor(map[string]interface{}{"interval": "1m"})
+	if err != nil {
+		t.Fatalf("newSysProcessor: %v", err)
+	}
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	p.(*sysProcessor).clock = clock
+
+	publish := func(topic, payload string) string {
+		t.Helper()
+		res, err := p.Process(types.Message{Topic: topic, Payload: []byte(payload)})
+		if err != nil {
+			t.Fatalf("Process(%s): %v", topic, err)
+		}
+		if res.Drop && res.Reason != stats.DropDigest {
+			t.Errorf("Process(%s) dropped as %q", topic, res.Reason)
+		}
+		return res.Formatted
+	}
+
+	publish("$SYS/broker/version", "mosquitto version 2.0.18")
+	publish("$SYS/broker/messages/received", "1000")
+	publish("$SYS/broker/messages/sent", "2000")
+	clock.Advance(30 * time.Second)
+	if got := publish("$SYS/broker/clients/connected", "12"); got != "" {
+		t.Errorf("digest before the interval: %q", got)
+	}
+	clock.Advance(30 * time.Second)
+	// The first message after the interval triggers the digest.
+	got := publish("$SYS/broker/messages/sent", "2600")
+	if want := "📊 MQTT broker 2.0.18: 12 clients, 0.0 msg/s in, 10.0 msg/s out, up ?"; got != want {
+		t.Errorf("digest = %q, want %q", got, want)
+	}
+
+	clock.Advance(30 * time.Second)
+	publish("$SYS/broker/messages/received", "1300")
+	clock.Advance(30 * time.Second)
+	got = publish("$SYS/broker/uptime", "93784 seconds")
+	if want := "📊 MQTT broker 2.0.18: 12 clients, 5.0 msg/s in, 0.0 msg/s out, up 1d 2h"; got != want {
+		t.Errorf("second digest = %q, want %q", got, want)
+	}
+}
+
+func TestSysProcessor_Fallbacks(t *testing.T) {
+	p, err := newSysProcessor(map[string]interface{}{
+		"interval": "1m",
+		"format":   "{{.Clients}} {{.MessagesIn}} {{.MessagesOut}} {{.Uptime}} {{index .Values \"broker/subscriptions/count\"}}",
+	})
+	if err != nil {
+		t.Fatalf("newSysProcessor: %v", err)
+	}
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	p.(*sysProcessor).clock = clock
+
+	// Bridged under a prefix, an older broker without clients/connected.
+	for topic, payload := range map[string]string{
+		"site1/$SYS/broker/clients/active":              "3",
+		"site1/$SYS/broker/load/messages/received/1min": "90",
+		"site1/$SYS/broker/subscriptions/count":         "17",
+	} {
+		if _, err := p.Process(types.Message{Topic: topic, Payload: []byte(payload)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(time.Minute)
+	res, err := p.Process(types.Message{Topic: "site1/$SYS/broker/uptime", Payload: []byte("600 seconds")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "3 1.5 ? 10m 17"; res.Formatted != want {
+		t.Errorf("digest = %q, want %q", res.Formatted, want)
+	}
+}
+
+func TestNewSysProcessor_Errors(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"interval": "soon"},
+		{"interval": "0s"},
+		{"format": "{{.Clients"},
+	} {
+		if _, err := newSysProcessor(cfg); err == nil {
+			t.Errorf("newSysProcessor(%v): want error", cfg)
+		}
+	}
+}
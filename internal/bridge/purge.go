@@ -0,0 +1,134 @@
+package bridge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// Kinds of data subject !purge removes.
+const (
+	PurgeNode  = "node"  // a mesh node, by !abcd1234 or decimal ID
+	PurgeNick  = "nick"  // an IRC nick or other name mentioned in bridged lines
+	PurgeTopic = "topic" // an MQTT topic pattern
+)
+
+// PurgingProcessor is implemented by processors that keep data about nodes,
+// nicks or topics, so !purge can remove it. Purge removes what the processor
+// knows about the subject, saves its stores at once without keeping a
+// backup, and returns how many entries it removed and the other terms the
+// subject appears as in bridged lines, such as a node's names.
+type PurgingProcessor interface {
+	Processor
+	Purge(kind, value string) (removed int, terms []string, err error)
+}
+
+// Purge removes a node, nick or topic from everything the bridge keeps:
+// processor state such as the node registry, the channel history (lines
+// from the topic, or mentioning the node or nick) and the payload samples of
+// !fields (implements admin.BridgeAdmin). Stores on disk are rewritten at
+// once and their backups removed; logs are not rewritten.
+func (b *Bridge) Purge(kind, value string) (types.PurgeResult, error) {
+	var res types.PurgeResult
+	terms := []string{value}
+	switch kind {
+	case PurgeNode:
+		id, ok := parseNode(value)
+		if !ok {
+			return res, fmt.Errorf("%w: invalid node ID %q (want !abcd1234 or decimal)", types.ErrInvalidPurge, value)
+		}
+		terms = []string{fmt.Sprintf("!%08x", id), strconv.FormatUint(uint64(id), 10)}
+	case PurgeNick, PurgeTopic:
+		if value == "" {
+			return res, fmt.Errorf("%w: missing %s", types.ErrInvalidPurge, kind)
+		}
+	default:
+		return res, fmt.Errorf("%w: unknown kind %q (want node, nick or topic)", types.ErrInvalidPurge, kind)
+	}
+
+	b.reloadMu.Lock()
+	procs := make([]Processor, 0, len(b.processors))
+	for _, p := range b.processors {
+		procs = append(procs, p)
+	}
+	b.reloadMu.Unlock()
+
+	var firstErr error
+	for _, p := range procs {
+		pp, ok := p.(PurgingProcessor)
+		if !ok {
+			continue
+		}
+		n, more, err := pp.Purge(kind, value)
+		res.Nodes += n
+		terms = append(terms, more...)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	var err error
+	if kind == PurgeTopic {
+		res.History, err = b.history.purge(func(l historyLine) bool {
+			return l.topic != "" && b.mapper.matchTopic(l.topic, value)
+		})
+		res.Samples = b.samples.purge(value, b.mapper.matchTopic)
+	} else {
+		res.History, err = b.history.purge(func(l historyLine) bool {
+			return mentionsAny(l.text, terms)
+		})
+	}
+	if err != nil && firstErr == nil {
+		firstErr = err
+	}
+	b.logger.Info().Str("kind", kind).Str("value", value).
+		Int("nodes", res.Nodes).Int("history", res.History).Int("samples", res.Samples).
+		Msg("purged stored data")
+	return res, firstErr
+}
+
+// mentionsAny reports whether text contains one of terms as a whole word,
+// ignoring case.
+func mentionsAny(text string, terms []string) bool {
+	lower := strings.ToLower(text)
+	for _, term := range terms {
+		if term != "" && mentions(lower, strings.ToLower(term)) {
+			return true
+		}
+	}
+	return false
+}
+
+// mentions reports whether the lower-cased text contains the lower-cased
+// term not preceded or followed by a letter or digit.
+func mentions(text, term string) bool {
+	for from := 0; ; {
+		i := strings.Index(text[from:], term)
+		if i < 0 {
+			return false
+		}
+		start, end := from+i, from+i+len(term)
+		if !wordRuneBefore(text, start) && !wordRuneAt(text, end) {
+			return true
+		}
+		from = start + 1
+	}
+}
+
+func wordRuneBefore(s string, i int) bool {
+	r, size := utf8.DecodeLastRuneInString(s[:i])
+	return size > 0 && isWordRune(r)
+}
+
+func wordRuneAt(s string, i int) bool {
+	r, size := utf8.DecodeRuneInString(s[i:])
+	return size > 0 && isWordRune(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,67 @@
+package bridge
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+type purgingStub struct{ kind, value string }
+
+func (p *purgingStub) Process(types.Message) (ProcessResult, error) { return ProcessResult{}, nil }
+
+func (p *purgingStub) Purge(kind, value string) (int, []string, error) {
+	p.kind, p.value = kind, value
+	return 1, []string{"HILL"}, nil
+}
+
+func TestPurge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	proc := &purgingStub{}
+	b := &Bridge{
+		mapper:     NewMapper(nil),
+		history:    newChannelHistory(10, time.UTC),
+		samples:    newPayloadSamples(),
+		processors: map[string]Processor{"msh/#": proc},
+		logger:     zerolog.New(os.Stderr).Level(zerolog.Disabled),
+	}
+	for _, l := range []struct{ text, topic string }{
+		{"!abcd1234: hello", "msh/EU/1"},
+		{"HILL: position 47.5, 19.0", "msh/EU/1"},
+		{"HILLTOP relay up", "msh/EU/2"},
+		{"alice left", "irc/relay"},
+		{"22.5 °C", "env/attic"},
+	} {
+		b.history.record("#c", l.text, "", l.topic, now)
+	}
+	b.samples.record("env/attic", []byte(`{}`), now)
+
+	res, err := b.Purge(PurgeNode, "!ABCD1234")
+	if err != nil || res.Nodes != 1 || res.History != 2 {
+		t.Errorf("Purge(node) = %+v, %v, want 1 node and 2 lines", res, err)
+	}
+	if proc.kind != PurgeNode || proc.value != "!ABCD1234" {
+		t.Errorf("processor Purge(%q, %q)", proc.kind, proc.value)
+	}
+
+	if res, _ := b.Purge(PurgeNick, "Alice"); res.History != 1 {
+		t.Errorf("Purge(nick) removed %d lines, want 1", res.History)
+	}
+	if res, _ := b.Purge(PurgeTopic, "env/#"); res.History != 1 || res.Samples != 1 {
+		t.Errorf("Purge(topic) = %+v, want 1 line and 1 sample", res)
+	}
+	if lines := b.history.since("#c", now); len(lines) != 1 || lines[0].text != "HILLTOP relay up" {
+		t.Errorf("history left = %v", lines)
+	}
+
+	for _, bad := range [][2]string{{PurgeNode, "bob"}, {"planet", "x"}, {PurgeNick, ""}} {
+		if _, err := b.Purge(bad[0], bad[1]); !errors.Is(err, types.ErrInvalidPurge) {
+			t.Errorf("Purge(%q, %q) = %v, want ErrInvalidPurge", bad[0], bad[1], err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// queueOpTimeout bounds how long an admin queue operation waits for the
+// message processor to pick it up.
+const queueOpTimeout = 10 * time.Second
+
+// queueOp is an admin request executed by the message processor goroutine, so
+// queued messages are never handled concurrently.
+type queueOp struct {
+	clear   bool   // discard instead of deliver
+	mapping string // clear only messages matching this mapping's mqtt_topic ("" = all)
+	result  chan int
+
+	list chan []types.Message // set to only list what is queued, leaving it queued
+}
+
+// DrainQueue delivers all currently queued messages now, in arrival order
+// regardless of priority (still rate limited). Returns the number of messages
+// being drained (implements admin.BridgeAdmin).
+func (b *Bridge) DrainQueue() (int, error) {
+	return b.queueOp(queueOp{})
+}
+
+// ClearQueue discards queued messages, optionally only those matching the
+// mapping with the given mqtt_topic. Returns the number of discarded messages
+// (implements admin.BridgeAdmin).
+func (b *Bridge) ClearQueue(mapping string) (int, error) {
+	if mapping != "" {
+		found := false
+		for _, m := range b.mapper.Info() {
+			if m.Topic == mapping {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown mapping %q", mapping)
+		}
+	}
+	return b.queueOp(queueOp{clear: true, mapping: mapping})
+}
+
+func (b *Bridge) queueOp(op queueOp) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueOpTimeout)
+	defer cancel()
+	return b.queueOpContext(ctx, op)
+}
+
+// queueOpContext hands op to the message processor and waits for its result
+// until ctx is done.
+func (b *Bridge) queueOpContext(ctx context.Context, op queueOp) (int, error) {
+	op.result = make(chan int, 1)
+	select {
+	case b.queueOps <- op:
+	case <-ctx.Done():
+		return 0, fmt.Errorf("message processor not running")
+	}
+	select {
+	case n := <-op.result:
+		return n, nil
+	case <-ctx.Done():
+		return 0, fmt.Errorf("message processor did not respond")
+	}
+}
+
+// highSpill holds the QoS 2 messages (queue.qos_priority) that arrived while
+// the high-priority queue was full. They are never dropped, and the MQTT
+// handler must not block paho's delivery goroutine waiting for room, so they
+// wait here, unbounded, until the message processor moves them to the
+// backlog.
+type highSpill struct {
+	mu   sync.Mutex
+	msgs []types.Message
+	wake chan struct{} // signalled when a message is added
+}
+
+func newHighSpill() highSpill {
+	return highSpill{wake: make(chan struct{}, 1)}
+}
+
+// add keeps msg and wakes the message processor. It never blocks.
+func (s *highSpill) add(msg types.Message) {
+	s.mu.Lock()
+	s.msgs = append(s.msgs, msg)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take removes and returns the messages kept.
+func (s *highSpill) take() []types.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msgs := s.msgs
+	s.msgs = nil
+	return msgs
+}
+
+func (s *highSpill) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.msgs)
+}
+
+// takeSpill moves the spilled QoS 2 messages to the backlog, behind the
+// high-priority messages already there and ahead of the rest, so they go out
+// before normal traffic. Called from processMessages.
+func (b *Bridge) takeSpill() {
+	msgs := b.spill.take()
+	if len(msgs) == 0 {
+		return
+	}
+	i := 0
+	for i < len(b.backlog) && b.backlog[i].Priority == types.PriorityHigh {
+		i++
+	}
+	b.backlog = slices.Insert(b.backlog, i, msgs...)
+}
+
+// collectQueued removes and returns everything currently queued: the backlog
+// (with the spilled QoS 2 messages) first, then the high-priority and normal
+// queues. Only the messages present when called are taken, so a busy
+// producer cannot keep it looping.
+func (b *Bridge) collectQueued() []types.Message {
+	b.takeSpill()
+	msgs := b.backlog
+	b.backlog = nil
+	for _, q := range []chan types.Message{b.highQueue, b.msgQueue} {
+		for n := len(q); n > 0; n-- {
+			select {
+			case msg := <-q:
+				msgs = append(msgs, msg)
+			default:
+				n = 0
+			}
+		}
+	}
+	return msgs
+}
+
+// runQueueOp executes an admin queue operation. Called from processMessages.
+func (b *Bridge) runQueueOp(op queueOp) []types.Message {
+	if op.list != nil {
+		op.list <- b.requeue()
+		return nil
+	}
+	msgs := b.collectQueued()
+
+	if !op.clear {
+		sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Timestamp.Before(msgs[j].Timestamp) })
+		op.result <- len(msgs)
+		b.logger.Info().Int("messages", len(msgs)).Msg("draining message queue")
+		return msgs
+	}
+
+	discarded := 0
+	for _, msg := range msgs {
+		topic, _ := b.aliases.canonical(msg.Topic)
+		if op.mapping == "" || b.mapper.matchTopic(topic, op.mapping) {
+			discarded++
+			continue
+		}
+		b.backlog = append(b.backlog, msg)
+	}
+	op.result <- discarded
+	b.logger.Info().Int("messages", discarded).Str("mapping", op.mapping).Msg("cleared message queue")
+	return nil
+}
+
+// queueListTimeout bounds how long Queue waits for the message processor,
+// which may be sending a message, to list the queue.
+const queueListTimeout = 5 * time.Second
+
+// Queue lists what is waiting to be sent, in the order it is sent: the
+// formatted lines in the channel queues (bridge.channel_queue_size), then
+// the messages in the bridge queue, at most limit of them (implements
+// health.QueueProvider).
+func (b *Bridge) Queue(limit int) (types.QueueListing, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueListTimeout)
+	defer cancel()
+	list := make(chan []types.Message, 1)
+	select {
+	case b.queueOps <- queueOp{list: list}:
+	case <-ctx.Done():
+		return types.QueueListing{}, fmt.Errorf("message processor not running")
+	}
+	var msgs []types.Message
+	select {
+	case msgs = <-list:
+	case <-ctx.Done():
+		return types.QueueListing{}, fmt.Errorf("message processor did not respond")
+	}
+	lines := b.outbox.list()
+
+	now := b.clock.Now()
+	listing := types.QueueListing{Total: len(lines) + len(msgs), Messages: []types.QueuedMessage{}}
+	add := func(msg types.Message, stage string, channels []string, preview []byte) bool {
+		if len(listing.Messages) >= limit {
+			return false
+		}
+		listing.Messages = append(listing.Messages, types.QueuedMessage{
+			ID:         msg.ID,
+			Topic:      msg.Topic,
+			Stage:      stage,
+			Received:   msg.Timestamp,
+			AgeSeconds: now.Sub(msg.Timestamp).Seconds(),
+			Channels:   channels,
+			Priority:   msg.Priority.String(),
+			Preview:    tracePayload(preview),
+		})
+		return true
+	}
+	for _, out := range lines {
+		if !add(out.msg, types.QueueStageChannel, []string{out.channel}, []byte(b.redactor.String(out.text))) {
+			return listing, nil
+		}
+	}
+	for _, msg := range msgs {
+		topic, _ := b.aliases.canonical(msg.Topic)
+		channels := []string{}
+		for _, m := range b.mapper.Map(topic, len(msg.Payload)) {
+			channels = appendNew(channels, m.IRCChannels...)
+		}
+		if !add(msg, types.QueueStageBridge, channels, b.redactor.Payload(msg.Payload)) {
+			break
+		}
+	}
+	return listing, nil
+}
+
+// appendNew appends the values that are not in list yet.
+func appendNew(list []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,172 @@
+package bridge
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func newQueueTestBridge(msgs, high []types.Message) *Bridge {
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "sensors/#", IRCChannels: []string{"#sensors"}},
+		{MQTTTopic: "alerts/#", IRCChannels: []string{"#alerts"}},
+	}
+	b := &Bridge{
+		config:    config.BridgeConfig{Mappings: mappings},
+		mapper:    NewMapper(mappings),
+		msgQueue:  make(chan types.Message, 10),
+		highQueue: make(chan types.Message, 10),
+		logger:    zerolog.New(os.Stderr).Level(zerolog.Disabled),
+	}
+	for _, m := range msgs {
+		b.msgQueue <- m
+	}
+	for _, m := range high {
+		b.highQueue <- m
+	}
+	return b
+}
+
+func TestRunQueueOp_Drain(t *testing.T) {
+	base := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	b := newQueueTestBridge(
+		[]types.Message{{Topic: "sensors/a", Timestamp: base}, {Topic: "sensors/c", Timestamp: base.Add(2 * time.Second)}},
+		[]types.Message{{Topic: "alerts/b", Timestamp: base.Add(time.Second)}},
+	)
+
+	op := queueOp{result: make(chan int, 1)}
+	msgs := b.runQueueOp(op)
+	if n := <-op.result; n != 3 {
+		t.Errorf("drain count = %d, want 3", n)
+	}
+	// Arrival order, not priority order.
+	want := []string{"sensors/a", "alerts/b", "sensors/c"}
+	for i, msg := range msgs {
+		if msg.Topic != want[i] {
+			t.Errorf("msgs[%d] = %q, want %q", i, msg.Topic, want[i])
+		}
+	}
+	if len(b.msgQueue) != 0 || len(b.highQueue) != 0 {
+		t.Error("queues not emptied")
+	}
+}
+
+func TestRunQueueOp_Clear(t *testing.T) {
+	b := newQueueTestBridge(
+		[]types.Message{{Topic: "sensors/a"}, {Topic: "alerts/b"}, {Topic: "sensors/c"}},
+		nil,
+	)
+
+	op := queueOp{clear: true, mapping: "sensors/#", result: make(chan int, 1)}
+	if msgs := b.runQueueOp(op); msgs != nil {
+		t.Errorf("clear returned messages to deliver: %v", msgs)
+	}
+	if n := <-op.result; n != 2 {
+		t.Errorf("clear count = %d, want 2", n)
+	}
+	if len(b.backlog) != 1 || b.backlog[0].Topic != "alerts/b" {
+		t.Errorf("backlog = %v, want [alerts/b]", b.backlog)
+	}
+
+	op = queueOp{clear: true, result: make(chan int, 1)}
+	b.runQueueOp(op)
+	if n := <-op.result; n != 1 {
+		t.Errorf("clear all count = %d, want 1", n)
+	}
+	if len(b.backlog) != 0 {
+		t.Errorf("backlog not cleared: %v", b.backlog)
+	}
+}
+
+func TestClearQueue_UnknownMapping(t *testing.T) {
+	b := newQueueTestBridge(nil, nil)
+	if _, err := b.ClearQueue("nope/#"); err == nil {
+		t.Error("expected error for unknown mapping")
+	}
+}
+
+func TestQueue(t *testing.T) {
+	base := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	b := newQueueTestBridge(
+		[]types.Message{{ID: "m1", Topic: "sensors/a", Payload: []byte(`{"t": 21.5}`), Timestamp: base}, {ID: "m3", Topic: "other/x", Timestamp: base}},
+		[]types.Message{{ID: "m2", Topic: "alerts/b", Payload: []byte("disk full"), Timestamp: base.Add(time.Second), Priority: types.PriorityHigh}},
+	)
+	b.clock = schedule.NewFake(base.Add(10 * time.Second))
+	b.queueOps = make(chan queueOp)
+
+	// One line is being sent; the next one waits in the #alerts queue.
+	sending, release := make(chan struct{}, 2), make(chan struct{})
+	b.outbox = newOutboxes(5, func(ctx context.Context, out outbound) {
+		sending <- struct{}{}
+		<-release
+	})
+	defer func() { close(release); b.outbox.close() }()
+	b.outbox.enqueue(outbound{msg: types.Message{ID: "m0"}, channel: "#alerts", text: "first"})
+	<-sending
+	b.outbox.enqueue(outbound{msg: types.Message{ID: "m4", Topic: "alerts/c", Timestamp: base}, channel: "#alerts", text: "ALERT: disk full"})
+
+	go func() { b.runQueueOp(<-b.queueOps) }()
+	listing, err := b.Queue(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listing.Total != 4 || len(listing.Messages) != 4 {
+		t.Fatalf("listing = %+v, want 4 messages", listing)
+	}
+	want := []types.QueuedMessage{
+		{ID: "m4", Topic: "alerts/c", Stage: types.QueueStageChannel, Received: base, AgeSeconds: 10, Channels: []string{"#alerts"}, Priority: "normal", Preview: "ALERT: disk full"},
+		{ID: "m2", Topic: "alerts/b", Stage: types.QueueStageBridge, Received: base.Add(time.Second), AgeSeconds: 9, Channels: []string{"#alerts"}, Priority: "high", Preview: "disk full"},
+		{ID: "m1", Topic: "sensors/a", Stage: types.QueueStageBridge, Received: base, AgeSeconds: 10, Channels: []string{"#sensors"}, Priority: "normal", Preview: `{"t": 21.5}`},
+		{ID: "m3", Topic: "other/x", Stage: types.QueueStageBridge, Received: base, AgeSeconds: 10, Channels: []string{}, Priority: "normal"},
+	}
+	if !reflect.DeepEqual(listing.Messages, want) {
+		t.Errorf("messages =\n%+v\nwant\n%+v", listing.Messages, want)
+	}
+	// Listing leaves everything queued.
+	if len(b.highQueue)+len(b.msgQueue)+len(b.backlog) != 3 {
+		t.Errorf("queues changed by listing: %d high, %d normal, %d backlog", len(b.highQueue), len(b.msgQueue), len(b.backlog))
+	}
+
+	go func() { b.runQueueOp(<-b.queueOps) }()
+	if listing, err := b.Queue(2); err != nil || listing.Total != 4 || len(listing.Messages) != 2 {
+		t.Errorf("Queue(2) = %+v, %v", listing, err)
+	}
+}
+
+func TestHighSpill(t *testing.T) {
+	b := newQueueTestBridge(nil, nil)
+	b.spill = newHighSpill()
+	b.backlog = []types.Message{{Topic: "alerts/restored", Priority: types.PriorityHigh}, {Topic: "sensors/kept"}}
+
+	b.spill.add(types.Message{Topic: "alerts/a", Priority: types.PriorityHigh})
+	b.spill.add(types.Message{Topic: "alerts/b", Priority: types.PriorityHigh})
+	select {
+	case <-b.spill.wake:
+	default:
+		t.Error("message processor not woken")
+	}
+	if n := b.spill.len(); n != 2 {
+		t.Fatalf("%d spilled, want 2", n)
+	}
+
+	var topics []string
+	for _, msg := range b.collectQueued() {
+		topics = append(topics, msg.Topic)
+	}
+	// Behind the high-priority backlog, ahead of the rest, in arrival order.
+	want := []string{"alerts/restored", "alerts/a", "alerts/b", "sensors/kept"}
+	if !reflect.DeepEqual(topics, want) {
+		t.Errorf("queued = %v, want %v", topics, want)
+	}
+	if b.spill.len() != 0 {
+		t.Error("spilled messages left behind")
+	}
+}
@@ -0,0 +1,205 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+// reloadOp swaps mappings and processors in the message processor goroutine,
+// so no message is handled with a half-applied configuration.
+type reloadOp struct {
+	cfg    *config.Config
+	result chan error
+}
+
+// SetConfigLoader enables config reload; load reads and validates the config
+// file the bridge was started with. Must be called before Run.
+func (b *Bridge) SetConfigLoader(load func() (*config.Config, error)) {
+	b.loadConfig = load
+}
+
+// PrepareReload reads the config file and returns a summary of what applying
+// it would change. The loaded config is kept for ApplyReload (implements
+// admin.BridgeAdmin).
+func (b *Bridge) PrepareReload() ([]string, bool, error) {
+	if b.loadConfig == nil {
+		return nil, false, fmt.Errorf("config reload is not available")
+	}
+	cfg, err := b.load()
+	if err != nil {
+		return nil, false, err
+	}
+
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+	diff := config.Compare(b.current, cfg)
+	b.pending = nil
+	if diff.Live() {
+		b.pending = cfg
+	}
+	return diff.Lines(), diff.Live(), nil
+}
+
+// ApplyReload applies the mapping and subscription changes of the config
+// loaded by PrepareReload. It refuses if the file changed since, so what is
+// applied is always what was previewed (implements admin.BridgeAdmin).
+func (b *Bridge) ApplyReload() ([]string, error) {
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+
+	if b.pending == nil {
+		return nil, fmt.Errorf("nothing to apply, preview the changes with reload first")
+	}
+	pending := b.pending
+	b.pending = nil
+
+	cfg, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(cfg, pending) {
+		return nil, fmt.Errorf("config file changed since the preview, run reload again")
+	}
+
+	diff := config.Compare(b.current, cfg)
+	op := reloadOp{cfg: cfg, result: make(chan error, 1)}
+	timeout := time.After(queueOpTimeout)
+	select {
+	case b.reloads <- op:
+	case <-timeout:
+		return nil, fmt.Errorf("message processor not running")
+	}
+	select {
+	case err := <-op.result:
+		if err != nil {
+			return nil, err
+		}
+	case <-timeout:
+		return nil, fmt.Errorf("message processor did not respond")
+	}
+
+	b.mqttClient.SetTopics(subscriptions(cfg.MQTT, b.current.Bridge))
+
+	// Only the live parts were applied; the rest still differs until restart.
+	next := *b.current
+	next.MQTT.Topics = cfg.MQTT.Topics
+	next.Bridge.Mappings = cfg.Bridge.Mappings
+	if next.Source != nil && cfg.Source != nil {
+		next.Source = next.Source.With(cfg.Source, "mqtt.topics", "bridge.mappings")
+	}
+	b.current = &next
+
+	b.logger.Info().
+		Strs("added", diff.MappingsAdded).
+		Strs("removed", diff.MappingsRemoved).
+		Strs("changed", diff.MappingsChanged).
+		Strs("subscribe", diff.Subscribe).
+		Strs("unsubscribe", diff.Unsubscribe).
+		Strs("restart", diff.Restart).
+		Msg("config reloaded")
+	go b.publishCapabilities()
+	return diff.Lines(), nil
+}
+
+// ConfigSource returns the effective configuration and where each value came
+// from; after a reload only the applied settings reflect the new file
+// (implements health.ConfigProvider).
+func (b *Bridge) ConfigSource() *config.Source {
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+	if b.current == nil {
+		return nil
+	}
+	return b.current.Source
+}
+
+// runReload swaps in the new mappings and processors. Processors of mappings
+// whose mappingKey, processor and processor_config are unchanged are kept, so
+// they keep their state (dedup caches, node registries). Removed mappings are
+// retired by bridge.removed_mappings. Called from processMessages.
+func (b *Bridge) runReload(cfg *config.Config) error {
+	old := make(map[string]config.MappingConfig, len(b.current.Bridge.Mappings))
+	for i, key := range mappingKeys(b.current.Bridge.Mappings) {
+		old[key] = b.current.Bridge.Mappings[i]
+	}
+
+	// Replaced processors hand over their state through the disk.
+	b.flushProcessors()
+
+	processors := make(map[string]Processor)
+	keys := mappingKeys(cfg.Bridge.Mappings)
+	for i, m := range cfg.Bridge.Mappings {
+		if m.Processor == "" {
+			continue
+		}
+		key := keys[i]
+		if prev, ok := old[key]; ok && prev.Processor == m.Processor &&
+			reflect.DeepEqual(prev.ProcessorConfig, m.ProcessorConfig) && b.processors[key] != nil {
+			processors[key] = b.processors[key]
+			continue
+		}
+		p, err := NewProcessor(m.Processor, m.ProcessorConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create processor for mapping %q: %w", m.MQTTTopic, err)
+		}
+		processors[key] = p
+	}
+
+	schemas, err := loadSchemas(cfg.Bridge.Mappings)
+	if err != nil {
+		return err
+	}
+	transforms, err := loadTransforms(cfg.Bridge.Mappings, b.config, b.limits)
+	if err != nil {
+		return err
+	}
+	groupSpecs, err := loadGroups(cfg.Bridge.Mappings)
+	if err != nil {
+		return err
+	}
+	spam, err := loadSpamFilters(cfg.Bridge.Mappings, b.spam)
+	if err != nil {
+		return err
+	}
+	zones, err := loadZones(cfg.Bridge.Mappings, b.zones[""])
+	if err != nil {
+		return err
+	}
+	reports, err := loadReports(cfg.Bridge.Mappings, processors, zones[""], b.clock, b.reports)
+	if err != nil {
+		return err
+	}
+
+	b.processors = processors
+	b.schemas = schemas
+	b.transforms = transforms
+	b.groupSpecs = groupSpecs
+	b.spam = spam
+	b.zones = zones
+	b.reports = reports
+	b.mapper.Replace(cfg.Bridge.Mappings)
+
+	removed := removedMappings(b.current.Bridge.Mappings, cfg.Bridge.Mappings)
+	b.retired.update(removed, keys)
+	if len(removed) > 0 {
+		go b.retireMappings(context.Background(), removed)
+	}
+	return nil
+}
+
+// subscriptions returns the MQTT subscriptions: the configured topics plus
+// one per heartbeat topic and the remote mappings topic.
+func subscriptions(cfg config.MQTTConfig, bcfg config.BridgeConfig) []config.TopicConfig {
+	topics := append([]config.TopicConfig(nil), cfg.Topics...)
+	for _, hb := range bcfg.Heartbeats {
+		topics = append(topics, config.TopicConfig{Pattern: hb.MQTTTopic, QoS: cfg.QoS})
+	}
+	if t := bcfg.RemoteMappings.MQTTTopic; t != "" {
+		topics = append(topics, config.TopicConfig{Pattern: t, QoS: cfg.QoS})
+	}
+	return topics
+}
@@ -0,0 +1,205 @@
+package bridge
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/mqtt"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+type reloadTestProcessor struct{ id int }
+
+func (p *reloadTestProcessor) Process(types.Message) (ProcessResult, error) {
+	return ProcessResult{}, nil
+}
+
+func init() {
+	n := 0
+	Register("reloadtest", func(map[string]interface{}) (Processor, error) {
+		n++
+		return &reloadTestProcessor{id: n}, nil
+	})
+}
+
+func newReloadTestBridge(t *testing.T, cfg *config.Config) *Bridge {
+	t.Helper()
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	mqttClient, err := mqtt.New(cfg.MQTT, make(chan types.Message, 1), connstate.New("mqtt", 0), logger)
+	if err != nil {
+		t.Fatalf("mqtt.New: %v", err)
+	}
+	b := &Bridge{
+		config:     cfg.Bridge,
+		mqttClient: mqttClient,
+		mapper:     NewMapper(cfg.Bridge.Mappings),
+		processors: make(map[string]Processor),
+		msgQueue:   make(chan types.Message, 1),
+		queueOps:   make(chan queueOp),
+		reloads:    make(chan reloadOp),
+		current:    cfg,
+		logger:     logger,
+	}
+	keys := mappingKeys(cfg.Bridge.Mappings)
+	for i, m := range cfg.Bridge.Mappings {
+		if m.Processor != "" {
+			p, _ := NewProcessor(m.Processor, m.ProcessorConfig)
+			b.processors[keys[i]] = p
+		}
+	}
+	return b
+}
+
+func reloadTestConfig() *config.Config {
+	return &config.Config{
+		MQTT: config.MQTTConfig{Broker: "tcp://localhost:1883", Topics: []config.TopicConfig{{Pattern: "a/#"}, {Pattern: "b/#"}}},
+		Bridge: config.BridgeConfig{Mappings: []config.MappingConfig{
+			{MQTTTopic: "a/#", IRCChannels: []string{"#a"}, Processor: "reloadtest"},
+			{MQTTTopic: "b/#", IRCChannels: []string{"#b"}, Processor: "reloadtest"},
+		}},
+	}
+}
+
+func TestReload_PreviewAndApply(t *testing.T) {
+	cfg := reloadTestConfig()
+	cfg.Source = &config.Source{Settings: map[string]config.Setting{
+		"bridge.mappings": {Value: "old"}, "irc.nickname": {Value: "bot"},
+	}}
+	b := newReloadTestBridge(t, cfg)
+	keptProcessor := b.processors["a/##0"]
+
+	next := reloadTestConfig()
+	next.Source = &config.Source{Settings: map[string]config.Setting{
+		"bridge.mappings": {Value: "new"}, "irc.nickname": {Value: "other"},
+	}}
+	next.MQTT.Topics = []config.TopicConfig{{Pattern: "a/#"}, {Pattern: "c/#"}}
+	next.Bridge.Mappings[1] = config.MappingConfig{MQTTTopic: "c/#", IRCChannels: []string{"#c"}}
+	onDisk := next
+	b.SetConfigLoader(func() (*config.Config, error) { return onDisk, nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.wg.Add(1)
+	go b.processMessages(ctx)
+
+	if _, err := b.ApplyReload(); err == nil {
+		t.Fatal("ApplyReload without a preview succeeded")
+	}
+
+	summary, pending, err := b.PrepareReload()
+	if err != nil || !pending {
+		t.Fatalf("PrepareReload() = %v, %v, %v", summary, pending, err)
+	}
+	if got := strings.Join(summary, "; "); !strings.Contains(got, "mappings added (1): c/#") || !strings.Contains(got, "unsubscribe (1): b/#") {
+		t.Errorf("summary = %q", got)
+	}
+
+	if _, err := b.ApplyReload(); err != nil {
+		t.Fatalf("ApplyReload: %v", err)
+	}
+	infos := b.Mappings()
+	if len(infos) != 2 || infos[1].Topic != "c/#" {
+		t.Errorf("mappings after reload = %+v", infos)
+	}
+	if b.processors["a/##0"] != keptProcessor {
+		t.Error("processor of unchanged mapping was recreated")
+	}
+	if _, ok := b.processors["b/##0"]; ok {
+		t.Error("processor of removed mapping still present")
+	}
+	if src := b.ConfigSource(); src.Settings["bridge.mappings"].Value != "new" || src.Settings["irc.nickname"].Value != "bot" {
+		t.Errorf("ConfigSource after reload = %v, want only the applied settings updated", src.Settings)
+	}
+
+	// Nothing left to apply.
+	if _, pending, _ := b.PrepareReload(); pending {
+		t.Error("PrepareReload after apply still has pending changes")
+	}
+}
+
+func TestReload_FileChangedSincePreview(t *testing.T) {
+	b := newReloadTestBridge(t, reloadTestConfig())
+	next := reloadTestConfig()
+	next.Bridge.Mappings[0].IRCChannels = []string{"#a2"}
+	onDisk := next
+	b.SetConfigLoader(func() (*config.Config, error) { return onDisk, nil })
+
+	if _, pending, err := b.PrepareReload(); err != nil || !pending {
+		t.Fatalf("PrepareReload: pending=%v err=%v", pending, err)
+	}
+
+	edited := reloadTestConfig()
+	edited.Bridge.Mappings[0].IRCChannels = []string{"#a3"}
+	onDisk = edited
+	if _, err := b.ApplyReload(); err == nil || !strings.Contains(err.Error(), "changed since") {
+		t.Errorf("ApplyReload after edit: err = %v, want changed-since error", err)
+	}
+}
+
+func TestAddMapping(t *testing.T) {
+	b := newReloadTestBridge(t, reloadTestConfig())
+
+	index, err := b.AddMapping("a/x/+", []string{"#ax"}, "{{.Payload}}")
+	if err != nil || index != 3 {
+		t.Fatalf("AddMapping = %d, %v, want 3", index, err)
+	}
+	if got := b.mapper.Map("a/x/1", 1); len(got) != 2 || got[1].IRCChannels[0] != "#ax" {
+		t.Errorf("Map(a/x/1) = %v, want the a/# mapping and the new one", got)
+	}
+	if n := len(b.mqttClient.Topics()); n != 2 {
+		t.Errorf("a/x/+ is covered by a/#, got %d subscriptions", n)
+	}
+
+	if _, err := b.AddMapping("c/#", []string{"#c"}, ""); err != nil {
+		t.Fatalf("AddMapping(c/#): %v", err)
+	}
+	topics := b.mqttClient.Topics()
+	if len(topics) != 3 || topics[2].Pattern != "c/#" {
+		t.Errorf("subscriptions = %v, want c/# added", topics)
+	}
+
+	for _, bad := range []struct {
+		topic    string
+		channels []string
+		format   string
+	}{
+		{"a/#/b", []string{"#a"}, ""},
+		{"d/#", []string{"nochannel"}, ""},
+		{"d/#", []string{"#d"}, "{{.Payload"},
+	} {
+		if _, err := b.AddMapping(bad.topic, bad.channels, bad.format); err == nil {
+			t.Errorf("AddMapping(%q, %q, %q) should fail", bad.topic, bad.channels, bad.format)
+		}
+	}
+}
+
+type flushTestProcessor struct {
+	reloadTestProcessor
+	flushes int
+}
+
+func (p *flushTestProcessor) Flush() error {
+	p.flushes++
+	return nil
+}
+
+func TestReload_FlushesProcessors(t *testing.T) {
+	b := newReloadTestBridge(t, reloadTestConfig())
+	fp := &flushTestProcessor{}
+	b.processors["b/##0"] = fp
+
+	next := reloadTestConfig()
+	next.Bridge.Mappings = next.Bridge.Mappings[:1]
+	if err := b.runReload(next); err != nil {
+		t.Fatalf("runReload: %v", err)
+	}
+	if fp.flushes != 1 {
+		t.Errorf("removed processor flushed %d times, want 1", fp.flushes)
+	}
+}
@@ -0,0 +1,197 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// maxRemoteDocument limits the size of a fetched mappings document.
+const maxRemoteDocument = 1 << 20
+
+// remoteMappings holds the mappings fetched from bridge.remote_mappings. A
+// changed document is staged like a config reload: previewed, then applied
+// by !reload apply or, with auto_apply, right away. Reloads use the last
+// accepted document instead of the file's bridge.mappings.
+type remoteMappings struct {
+	cfg     config.RemoteConfig
+	client  *http.Client
+	etag    string      // of the last HTTP response, for conditional requests
+	updates chan []byte // payloads from the MQTT topic; only the latest is kept
+
+	mu  sync.Mutex
+	doc *config.RemoteMappings // last accepted document; nil until one arrived
+	raw []byte                 // payload of doc, to skip unchanged refreshes
+}
+
+// newRemoteMappings returns nil unless remote mappings are configured.
+func newRemoteMappings(cfg config.RemoteConfig) *remoteMappings {
+	if cfg.URL == "" && cfg.MQTTTopic == "" {
+		return nil
+	}
+	return &remoteMappings{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		updates: make(chan []byte, 1),
+	}
+}
+
+// document returns the last accepted document, or nil.
+func (r *remoteMappings) document() *config.RemoteMappings {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.doc
+}
+
+// swap replaces the document and returns the previous one.
+func (r *remoteMappings) swap(doc *config.RemoteMappings, raw []byte) (*config.RemoteMappings, []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev, prevRaw := r.doc, r.raw
+	r.doc, r.raw = doc, raw
+	return prev, prevRaw
+}
+
+// unchanged reports whether raw is the accepted document.
+func (r *remoteMappings) unchanged(raw []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.doc != nil && bytes.Equal(r.raw, raw)
+}
+
+// observe takes a message on the remote mappings topic and reports whether
+// it was one. Called from processMessages, so staging happens elsewhere.
+func (r *remoteMappings) observe(msg types.Message) bool {
+	if r == nil || r.cfg.MQTTTopic == "" || msg.Topic != r.cfg.MQTTTopic {
+		return false
+	}
+	select {
+	case <-r.updates: // replaced by the newer payload
+	default:
+	}
+	r.updates <- msg.Payload
+	return true
+}
+
+// fetch downloads the document; it returns nil if it is not modified.
+func (r *remoteMappings) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteDocument+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRemoteDocument {
+		return nil, fmt.Errorf("document larger than %d bytes", maxRemoteDocument)
+	}
+	r.etag = resp.Header.Get("ETag")
+	return data, nil
+}
+
+// load reads the config file and uses the remote mappings, if any.
+func (b *Bridge) load() (*config.Config, error) {
+	cfg, err := b.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if doc := b.remote.document(); doc != nil {
+		return cfg.WithRemoteMappings(doc)
+	}
+	return cfg, nil
+}
+
+// runRemoteMappings refreshes the URL every interval and stages documents
+// received on the MQTT topic.
+func (b *Bridge) runRemoteMappings(ctx context.Context) {
+	defer b.wg.Done()
+
+	var refresh <-chan time.Time
+	for {
+		if b.remote.cfg.URL != "" && refresh == nil {
+			data, err := b.remote.fetch(ctx)
+			if err != nil {
+				b.logger.Warn().Err(err).Str("url", b.remote.cfg.URL).Msg("failed to fetch remote mappings")
+			} else if data != nil {
+				b.stageRemote(data)
+			}
+			refresh = b.clock.After(b.remote.cfg.Interval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-b.remote.updates:
+			b.stageRemote(data)
+		case <-refresh:
+			refresh = nil
+		}
+	}
+}
+
+// stageRemote previews a fetched document as a reload and applies it with
+// auto_apply. A document that does not parse or validate is rejected and the
+// previous one stays in use.
+func (b *Bridge) stageRemote(data []byte) {
+	if len(bytes.TrimSpace(data)) == 0 || b.remote.unchanged(data) {
+		return
+	}
+	doc, err := config.ParseMappings(data)
+	if err == nil {
+		prev, prevRaw := b.remote.swap(doc, data)
+		var lines []string
+		var live bool
+		if lines, live, err = b.PrepareReload(); err != nil {
+			b.remote.swap(prev, prevRaw)
+		} else if !live {
+			b.logger.Debug().Msg("remote mappings unchanged")
+		} else if b.remote.cfg.AutoApply {
+			b.applyRemote()
+		} else {
+			b.logger.Info().Strs("changes", lines).Msg("remote mappings staged")
+			b.notifyOps("Remote mappings staged, apply with reload apply: " + strings.Join(lines, "; "))
+		}
+	}
+	if err != nil {
+		b.logger.Warn().Err(err).Msg("remote mappings rejected")
+		b.notifyOps(fmt.Sprintf("Remote mappings rejected: %v", err))
+	}
+}
+
+// applyRemote applies the staged remote mappings.
+func (b *Bridge) applyRemote() {
+	lines, err := b.ApplyReload()
+	if err != nil {
+		b.logger.Warn().Err(err).Msg("failed to apply remote mappings")
+		b.notifyOps(fmt.Sprintf("Failed to apply remote mappings: %v", err))
+		return
+	}
+	b.notifyOps("Remote mappings applied: " + strings.Join(lines, "; "))
+}
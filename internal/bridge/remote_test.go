@@ -0,0 +1,118 @@
+package bridge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+const remoteTestConfig = `
+mqtt:
+  broker: "tcp://localhost:1883"
+  client_id: "test"
+  topics:
+    - pattern: "a/#"
+irc:
+  server: "irc.example.org:6697"
+  nickname: "bot"
+bridge:
+  mappings:
+    - mqtt_topic: "a/#"
+      irc_channels: ["#a"]
+      message_format: "{{.Payload}}"
+`
+
+// newRemoteTestBridge returns a running bridge that reloads from a config
+// file and takes remote mappings per rcfg.
+func newRemoteTestBridge(t *testing.T, rcfg config.RemoteConfig) *Bridge {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(remoteTestConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newReloadTestBridge(t, cfg)
+	b.SetConfigLoader(func() (*config.Config, error) { return config.Load(path) })
+	b.remote = newRemoteMappings(rcfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	b.wg.Add(1)
+	go b.processMessages(ctx)
+	return b
+}
+
+const remoteDoc = `{"mappings": [{"mqtt_topic": "b/#", "irc_channels": ["#b"], "message_format": "{{.Payload}}"}]}`
+
+func TestRemoteMappings_HTTP(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(remoteDoc))
+	}))
+	defer srv.Close()
+
+	b := newRemoteTestBridge(t, config.RemoteConfig{URL: srv.URL, AutoApply: true})
+	data, err := b.remote.fetch(context.Background())
+	if err != nil || data == nil {
+		t.Fatalf("fetch() = %q, %v", data, err)
+	}
+	b.stageRemote(data)
+	if infos := b.Mappings(); len(infos) != 1 || infos[0].Topic != "b/#" {
+		t.Errorf("mappings after auto apply = %+v", infos)
+	}
+	if src := b.ConfigSource(); src.Settings["bridge.mappings"].Origin != config.OriginRemote {
+		t.Errorf("bridge.mappings source = %+v", src.Settings["bridge.mappings"])
+	}
+
+	if data, err := b.remote.fetch(context.Background()); err != nil || data != nil {
+		t.Errorf("conditional fetch = %q, %v, want not modified", data, err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+}
+
+func TestRemoteMappings_StagedAndRejected(t *testing.T) {
+	b := newRemoteTestBridge(t, config.RemoteConfig{MQTTTopic: "fleet/mappings"})
+
+	if !b.remote.observe(types.Message{Topic: "fleet/mappings", Payload: []byte("old")}) ||
+		!b.remote.observe(types.Message{Topic: "fleet/mappings", Payload: []byte(remoteDoc)}) {
+		t.Fatal("message on the remote mappings topic not taken")
+	}
+	if b.remote.observe(types.Message{Topic: "a/b"}) {
+		t.Error("message on another topic taken")
+	}
+	b.stageRemote(<-b.remote.updates)
+
+	// Without auto_apply the change waits for !reload apply.
+	if infos := b.Mappings(); infos[0].Topic != "a/#" {
+		t.Errorf("mappings applied without reload apply: %+v", infos)
+	}
+	if _, err := b.ApplyReload(); err != nil {
+		t.Fatalf("ApplyReload: %v", err)
+	}
+	if infos := b.Mappings(); len(infos) != 1 || infos[0].Topic != "b/#" {
+		t.Errorf("mappings after reload apply = %+v", infos)
+	}
+
+	// An invalid document keeps the accepted one.
+	b.stageRemote([]byte(`{"mappings": [{"mqtt_topic": "c/#", "irc_channels": ["c"]}]}`))
+	if doc := b.remote.document(); doc == nil || doc.Mappings[0].MQTTTopic != "b/#" {
+		t.Errorf("document after rejected update = %+v", doc)
+	}
+}
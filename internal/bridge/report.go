@@ -0,0 +1,117 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// ReportingProcessor is implemented by processors that post a report of their
+// own on a schedule, such as the meshtastic activity digest.
+type ReportingProcessor interface {
+	Processor
+	// ReportSchedule returns the report's cron schedule, evaluated in the
+	// global timezone, and the channel to post it to. An empty schedule
+	// means no report, an empty channel the mapping's channels.
+	ReportSchedule() (cron, channel string)
+	// Report returns the report for the period ending at now and starts a
+	// new period. Called concurrently with Process.
+	Report(now time.Time) []string
+}
+
+// scheduledReport is the schedule of one mapping's ReportingProcessor.
+type scheduledReport struct {
+	key      string // mappingKey
+	cron     *schedule.Cron
+	channels []string
+	next     time.Time
+}
+
+// loadReports schedules the reports of the mappings' processors. Reports
+// already scheduled in prev keep their next time.
+func loadReports(mappings []config.MappingConfig, processors map[string]Processor, loc *time.Location, clock schedule.Clock, prev []*scheduledReport) ([]*scheduledReport, error) {
+	var reports []*scheduledReport
+	keys := mappingKeys(mappings)
+	for i, m := range mappings {
+		p, ok := processors[keys[i]].(ReportingProcessor)
+		if !ok {
+			continue
+		}
+		expr, channel := p.ReportSchedule()
+		if expr == "" {
+			continue
+		}
+		cron, err := schedule.ParseCron(expr, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid report schedule for mapping %q: %w", m.MQTTTopic, err)
+		}
+		r := &scheduledReport{key: keys[i], cron: cron, channels: m.IRCChannels, next: cron.Next(clock.Now())}
+		if channel != "" {
+			r.channels = []string{channel}
+		}
+		if r.next.IsZero() {
+			return nil, fmt.Errorf("report schedule %q for mapping %q never fires", expr, m.MQTTTopic)
+		}
+		for _, old := range prev {
+			if old.key == r.key && old.cron.String() == expr {
+				r.next = old.next
+			}
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// runReports posts processor reports when they are due, checking every minute.
+func (b *Bridge) runReports(ctx context.Context) {
+	defer b.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-b.clock.After(time.Minute):
+			b.postReports(ctx, now)
+		}
+	}
+}
+
+// reportPost is a due report and where to post it.
+type reportPost struct {
+	channels []string
+	lines    []string
+}
+
+// dueReports collects the reports due at now and schedules their next run.
+func (b *Bridge) dueReports(now time.Time) []reportPost {
+	// Reloads swap processors and reports while holding reloadMu.
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+	var due []reportPost
+	for _, r := range b.reports {
+		if now.Before(r.next) {
+			continue
+		}
+		r.next = r.cron.Next(now)
+		if p, ok := b.processors[r.key].(ReportingProcessor); ok {
+			due = append(due, reportPost{channels: r.channels, lines: p.Report(now)})
+		}
+	}
+	return due
+}
+
+// postReports posts the reports due at now.
+func (b *Bridge) postReports(ctx context.Context, now time.Time) {
+	for _, p := range b.dueReports(now) {
+		for _, channel := range p.channels {
+			for _, line := range p.lines {
+				if err := b.SendMessage(ctx, channel, b.limits.Fit(line)); err != nil {
+					b.logger.Warn().Err(err).Str("channel", channel).Msg("failed to post report")
+				}
+			}
+		}
+	}
+}
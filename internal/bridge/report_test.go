@@ -0,0 +1,76 @@
+package bridge
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// reportingStub is a ReportingProcessor counting its reports.
+type reportingStub struct {
+	cron, channel string
+	reports       int
+}
+
+func (r *reportingStub) Process(types.Message) (ProcessResult, error) { return ProcessResult{}, nil }
+func (r *reportingStub) ReportSchedule() (string, string)             { return r.cron, r.channel }
+func (r *reportingStub) Report(time.Time) []string {
+	r.reports++
+	return []string{"report"}
+}
+
+func TestReports(t *testing.T) {
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "mesh/#", IRCChannels: []string{"#mesh"}, Processor: "stub"},
+		{MQTTTopic: "other/#", IRCChannels: []string{"#other"}, Processor: "stub"},
+		{MQTTTopic: "quiet/#", IRCChannels: []string{"#quiet"}, Processor: "stub"},
+	}
+	keys := mappingKeys(mappings)
+	daily := &reportingStub{cron: "0 8 * * *"}
+	hourly := &reportingStub{cron: "@hourly", channel: "#reports"}
+	processors := map[string]Processor{keys[0]: daily, keys[1]: hourly, keys[2]: &reportingStub{}}
+
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 7, 30, 0, 0, time.UTC))
+	reports, err := loadReports(mappings, processors, time.UTC, clock, nil)
+	if err != nil {
+		t.Fatalf("loadReports: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("%d reports scheduled, want 2 (no schedule, no report)", len(reports))
+	}
+	b := &Bridge{processors: processors, reports: reports}
+
+	if due := b.dueReports(clock.Now()); len(due) != 0 {
+		t.Errorf("reports due at 07:30: %+v", due)
+	}
+	due := b.dueReports(time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC))
+	want := []reportPost{
+		{channels: []string{"#mesh"}, lines: []string{"report"}},
+		{channels: []string{"#reports"}, lines: []string{"report"}},
+	}
+	if !reflect.DeepEqual(due, want) {
+		t.Errorf("due at 08:00 = %+v, want %+v", due, want)
+	}
+	if due := b.dueReports(time.Date(2026, 1, 2, 8, 1, 0, 0, time.UTC)); len(due) != 0 {
+		t.Errorf("reports due again at 08:01: %+v", due)
+	}
+
+	// A reload keeps the next run of unchanged schedules.
+	clock.Set(time.Date(2026, 1, 2, 8, 30, 0, 0, time.UTC))
+	reloaded, err := loadReports(mappings, processors, time.UTC, clock, b.reports)
+	if err != nil {
+		t.Fatalf("loadReports: %v", err)
+	}
+	if next := reloaded[0].next; !next.Equal(time.Date(2026, 1, 3, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("daily report next at %v after reload", next)
+	}
+
+	daily.cron = "0 0 30 2 *"
+	if _, err := loadReports(mappings, processors, time.UTC, clock, nil); err == nil {
+		t.Error("loadReports with a schedule that never fires: want error")
+	}
+}
@@ -0,0 +1,103 @@
+package bridge
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// retiredMappings are the mappings removed by a reload whose waiting lines
+// are dropped (removed_mappings.queued: drop), by mappingKey. A nil
+// *retiredMappings has none.
+type retiredMappings struct {
+	mu   sync.RWMutex
+	keys map[string]bool
+}
+
+func newRetiredMappings(cfg config.RemovedConfig) *retiredMappings {
+	if cfg.Queued != config.RemovedDrop {
+		return nil
+	}
+	return &retiredMappings{keys: make(map[string]bool)}
+}
+
+// has reports whether the mapping with key was removed.
+func (r *retiredMappings) has(key string) bool {
+	if r == nil || key == "" {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys[key]
+}
+
+// update retires removed and brings back the mappings in current, as a
+// reload may add a removed mapping again.
+func (r *retiredMappings) update(removed []Mapped, current []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range current {
+		delete(r.keys, key)
+	}
+	for _, m := range removed {
+		r.keys[m.Key] = true
+	}
+}
+
+// removedMappings returns the mappings of old that are not in mappings.
+func removedMappings(old, mappings []config.MappingConfig) []Mapped {
+	current := make(map[string]bool, len(mappings))
+	for _, key := range mappingKeys(mappings) {
+		current[key] = true
+	}
+	var removed []Mapped
+	for i, key := range mappingKeys(old) {
+		if !current[key] {
+			removed = append(removed, Mapped{MappingConfig: old[i], Key: key})
+		}
+	}
+	return removed
+}
+
+// retireMappings handles the lines of removed mappings still waiting in
+// groups, by removed_mappings.queued, then posts removed_mappings.notice to
+// their channels. With channel queues the notice follows the lines queued
+// before it, which are sent or dropped as they come up.
+func (b *Bridge) retireMappings(ctx context.Context, removed []Mapped) {
+	cfg := b.config.RemovedMappings
+	var notice *template.Template
+	if cfg.Notice != "" {
+		// Checked by config validation.
+		notice = template.Must(template.New("notice").Parse(cfg.Notice))
+	}
+	for _, m := range removed {
+		for _, grp := range b.groups.take(m.Key) {
+			if cfg.Queued == config.RemovedDrop {
+				b.droppedIn(stats.DropMappingRemoved, grp.msg, grp.channel).
+					Msg("message dropped: mapping removed")
+				continue
+			}
+			b.sendGroup(grp)
+		}
+		if notice == nil {
+			continue
+		}
+		msg := types.Message{Topic: m.MQTTTopic, Timestamp: b.clock.Now()}
+		for _, channel := range m.IRCChannels {
+			var sb strings.Builder
+			if err := notice.Execute(&sb, struct{ Topic, Channel string }{m.MQTTTopic, channel}); err != nil {
+				b.logger.Error().Err(err).Str("mapping", m.MQTTTopic).Str("channel", channel).Msg("failed to render removed mapping notice")
+				continue
+			}
+			b.send(ctx, msg, "", "", channel, b.limits.Fit(sb.String()), nil)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestRemovedMappings(t *testing.T) {
+	old := []config.MappingConfig{{MQTTTopic: "a/#"}, {MQTTTopic: "b/#"}, {MQTTTopic: "b/#"}}
+	removed := removedMappings(old, []config.MappingConfig{{MQTTTopic: "b/#"}, {MQTTTopic: "c/#"}})
+	if len(removed) != 2 || removed[0].Key != "a/##0" || removed[1].Key != "b/##1" {
+		t.Fatalf("removed = %+v", removed)
+	}
+
+	r := newRetiredMappings(config.RemovedConfig{Queued: config.RemovedDrop})
+	r.update(removed, nil)
+	if !r.has("a/##0") || r.has("b/##0") || r.has("") {
+		t.Error("wrong mappings retired")
+	}
+	r.update(nil, []string{"a/##0"}) // added again
+	if r.has("a/##0") {
+		t.Error("mapping added again is still retired")
+	}
+	if newRetiredMappings(config.RemovedConfig{}) != nil {
+		t.Error("retired mappings kept with queued: send")
+	}
+}
+
+func TestRetireMappings(t *testing.T) {
+	for _, queued := range []string{config.RemovedSend, config.RemovedDrop} {
+		cfg := reloadTestConfig()
+		cfg.Bridge.RemovedMappings = config.RemovedConfig{Notice: "{{.Topic}} is disabled in {{.Channel}}", Queued: queued}
+		b := newReloadTestBridge(t, cfg)
+		clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+		b.clock = clock
+		b.drops = stats.NewDrops()
+		b.retired = newRetiredMappings(cfg.Bridge.RemovedMappings)
+		b.groups = newGrouper(clock, b.sendGroup)
+		sent := make(chan outbound, 10)
+		b.outbox = newOutboxes(10, func(ctx context.Context, out outbound) {
+			if b.retired.has(out.mapping) {
+				return
+			}
+			sent <- out
+		})
+
+		mapping := Mapped{MappingConfig: cfg.Bridge.Mappings[0], Key: mappingKeys(cfg.Bridge.Mappings)[0]}
+		b.groups.add(context.Background(), types.Message{Topic: "a/x"}, mapping, "#a", "k", groupSpec{window: time.Minute}, "grouped", irc.Limits{})
+		b.retired.update([]Mapped{mapping}, nil)
+		b.retireMappings(context.Background(), []Mapped{mapping})
+		b.outbox.close()
+		close(sent)
+
+		var lines []string
+		for out := range sent {
+			lines = append(lines, out.text)
+		}
+		want := []string{"grouped", "a/# is disabled in #a"}
+		dropped := 0
+		if queued == config.RemovedDrop {
+			want, dropped = want[1:], 1
+		}
+		if len(lines) != len(want) || lines[len(lines)-1] != want[len(want)-1] {
+			t.Errorf("%s: sent %q, want %q", queued, lines, want)
+		}
+		if got := len(b.drops.Snapshot()); got != dropped {
+			t.Errorf("%s: %d drop reasons, want %d", queued, got, dropped)
+		}
+		if b.groups.waiting() != 0 {
+			t.Errorf("%s: group still waiting", queued)
+		}
+	}
+}
+
+func TestRetireMappings_NoticeFails(t *testing.T) {
+	cfg := reloadTestConfig()
+	// Fails to render for a/# only.
+	cfg.Bridge.RemovedMappings = config.RemovedConfig{Notice: `{{if eq .Topic "a/#"}}{{.Nope}}{{else}}{{.Topic}} is disabled{{end}}`}
+	b := newReloadTestBridge(t, cfg)
+	b.clock = schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	sent := make(chan outbound, 10)
+	b.outbox = newOutboxes(10, func(ctx context.Context, out outbound) { sent <- out })
+
+	keys := mappingKeys(cfg.Bridge.Mappings)
+	removed := []Mapped{
+		{MappingConfig: cfg.Bridge.Mappings[0], Key: keys[0]},
+		{MappingConfig: cfg.Bridge.Mappings[1], Key: keys[1]},
+	}
+	b.retireMappings(context.Background(), removed)
+	b.outbox.close()
+	close(sent)
+
+	var lines []string
+	for out := range sent {
+		lines = append(lines, out.channel+" "+out.text)
+	}
+	if len(lines) != 1 || lines[0] != "#b b/# is disabled" {
+		t.Errorf("sent %q, want the notice for b/#", lines)
+	}
+}
@@ -0,0 +1,143 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// maxPendingReviews bounds the messages held for review; the oldest is
+// dropped when a new one arrives.
+const maxPendingReviews = 200
+
+// pendingReview is a message held in a mapping's review channel.
+type pendingReview struct {
+	id      int
+	msg     types.Message
+	tenant  string
+	channel string
+	lines   []string
+}
+
+// reviewQueue holds the messages of mappings with a review_channel until an
+// admin approves or rejects them. Pending messages are not persisted.
+type reviewQueue struct {
+	mu      sync.Mutex
+	next    int
+	pending map[int]*pendingReview
+}
+
+func newReviewQueue() *reviewQueue {
+	return &reviewQueue{pending: make(map[int]*pendingReview)}
+}
+
+// add holds r under a new ID and returns the message it evicted, if any.
+func (q *reviewQueue) add(r *pendingReview) (evicted *pendingReview) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.next++
+	r.id = q.next
+	if len(q.pending) >= maxPendingReviews {
+		oldest := r.id
+		for id := range q.pending {
+			if id < oldest {
+				oldest = id
+			}
+		}
+		evicted = q.pending[oldest]
+		delete(q.pending, oldest)
+	}
+	q.pending[r.id] = r
+	return evicted
+}
+
+// take removes and returns the message with id.
+func (q *reviewQueue) take(id int) (*pendingReview, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	r, ok := q.pending[id]
+	delete(q.pending, id)
+	return r, ok
+}
+
+// list returns the pending messages, oldest first.
+func (q *reviewQueue) list() []types.Review {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]types.Review, 0, len(q.pending))
+	for _, r := range q.pending {
+		out = append(out, r.review())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (r *pendingReview) review() types.Review {
+	return types.Review{ID: r.id, Topic: r.msg.Topic, Channel: r.channel}
+}
+
+// sendMapped sends a mapping's lines to channel, after merging them with
+// others of the same group key if the mapping groups messages.
+func (b *Bridge) sendMapped(ctx context.Context, msg types.Message, mapping Mapped, channel string, lines []string, tr *trace) {
+	if b.groupMapped(ctx, msg, mapping, channel, lines, tr) {
+		return
+	}
+	b.deliverMapped(ctx, msg, mapping, channel, lines, tr)
+}
+
+// deliverMapped sends a mapping's lines to channel unless they look like spam.
+func (b *Bridge) deliverMapped(ctx context.Context, msg types.Message, mapping Mapped, channel string, lines []string, tr *trace) {
+	if b.checkSpam(msg, mapping, channel, lines, tr) {
+		b.holdOrSend(ctx, msg, mapping, channel, lines, tr)
+	}
+}
+
+// holdOrSend sends a mapping's lines to channel, or holds them in the
+// mapping's review channel, after the mapping's output transforms and trust
+// level.
+func (b *Bridge) holdOrSend(ctx context.Context, msg types.Message, mapping Mapped, channel string, lines []string, tr *trace) {
+	lines = b.transformLines(mapping, channel, lines)
+	if mapping.ReviewChannel == "" {
+		b.sendLines(ctx, msg, mapping.Key, mapping.Tenant, channel, lines, tr)
+		return
+	}
+	r := &pendingReview{msg: msg, tenant: mapping.Tenant, channel: channel, lines: lines}
+	if old := b.reviews.add(r); old != nil {
+		b.droppedIn(stats.DropReview, old.msg, old.channel).
+			Int("review", old.id).
+			Msg("message dropped: too many messages awaiting review")
+	}
+	tr.step("%s: held for review as #%d in %s", mapping.MQTTTopic, r.id, mapping.ReviewChannel)
+	prefix := fmt.Sprintf("[review %d → %s] ", r.id, channel)
+	b.sendLines(ctx, msg, "", "", mapping.ReviewChannel, b.prefixed(prefix, lines), tr)
+}
+
+// Approve sends a message held for review to its channel.
+func (b *Bridge) Approve(id int) (types.Review, error) {
+	r, ok := b.reviews.take(id)
+	if !ok {
+		return types.Review{}, fmt.Errorf("no message #%d awaiting review", id)
+	}
+	b.logger.Info().Int("review", id).Str("msg_id", r.msg.ID).Str("channel", r.channel).Msg("review approved")
+	go b.sendLines(context.Background(), r.msg, "", r.tenant, r.channel, r.lines, nil)
+	return r.review(), nil
+}
+
+// Reject drops a message held for review.
+func (b *Bridge) Reject(id int) (types.Review, error) {
+	r, ok := b.reviews.take(id)
+	if !ok {
+		return types.Review{}, fmt.Errorf("no message #%d awaiting review", id)
+	}
+	b.droppedIn(stats.DropReview, r.msg, r.channel).Int("review", id).Msg("message dropped: rejected in review")
+	return r.review(), nil
+}
+
+// Reviews returns the messages awaiting review, oldest first.
+func (b *Bridge) Reviews() []types.Review {
+	return b.reviews.list()
+}
@@ -0,0 +1,64 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestReviewQueue(t *testing.T) {
+	q := newReviewQueue()
+	for i := 0; i < maxPendingReviews; i++ {
+		if old := q.add(&pendingReview{msg: types.Message{Topic: "noisy/x"}, channel: "#community"}); old != nil {
+			t.Fatalf("evicted #%d before the queue was full", old.id)
+		}
+	}
+	old := q.add(&pendingReview{msg: types.Message{Topic: "noisy/y"}, channel: "#community"})
+	if old == nil || old.id != 1 {
+		t.Fatalf("add to a full queue evicted %v, want #1", old)
+	}
+
+	if _, ok := q.take(1); ok {
+		t.Error("took the evicted message")
+	}
+	r, ok := q.take(2)
+	if !ok || r.id != 2 {
+		t.Fatalf("take(2) = %v, %v", r, ok)
+	}
+	if _, ok := q.take(2); ok {
+		t.Error("took #2 twice")
+	}
+
+	list := q.list()
+	if len(list) != maxPendingReviews-1 || list[0].ID != 3 {
+		t.Fatalf("list() has %d entries starting at #%d", len(list), list[0].ID)
+	}
+	if last := list[len(list)-1]; last != (types.Review{ID: maxPendingReviews + 1, Topic: "noisy/y", Channel: "#community"}) {
+		t.Errorf("last review = %+v", last)
+	}
+}
+
+func TestReject(t *testing.T) {
+	b := newReloadTestBridge(t, reloadTestConfig())
+	b.reviews = newReviewQueue()
+	b.drops = stats.NewDrops()
+	b.clock = schedule.NewFake(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	b.reviews.add(&pendingReview{msg: types.Message{Topic: "noisy/x"}, channel: "#community"})
+
+	r, err := b.Reject(1)
+	if err != nil || r.Channel != "#community" {
+		t.Fatalf("Reject(1) = %+v, %v", r, err)
+	}
+	if _, err := b.Reject(1); err == nil {
+		t.Error("rejected #1 twice")
+	}
+	if _, err := b.Approve(1); err == nil {
+		t.Error("approved a rejected message")
+	}
+	if drops := b.drops.Snapshot(); len(drops) != 1 || drops[0].Reason != string(stats.DropReview) {
+		t.Errorf("drops = %+v, want one review drop", drops)
+	}
+}
@@ -0,0 +1,150 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schema"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// schemaWarnInterval is the least time between two ops warnings about the
+// same mapping's schema violations.
+const schemaWarnInterval = 10 * time.Minute
+
+// loadSchemas compiles the schema of every mapping that has one, by mappingKey.
+func loadSchemas(mappings []config.MappingConfig) (map[string]*schema.Schema, error) {
+	schemas := make(map[string]*schema.Schema)
+	keys := mappingKeys(mappings)
+	for i, m := range mappings {
+		if m.Schema.File == "" {
+			continue
+		}
+		s, err := schema.Load(m.Schema.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load schema for mapping %q: %w", m.MQTTTopic, err)
+		}
+		schemas[keys[i]] = s
+	}
+	return schemas, nil
+}
+
+// schemaViolations counts non-conforming payloads per mapping (mqtt_topic)
+// and samples the ops warnings. A nil *schemaViolations counts nothing.
+type schemaViolations struct {
+	mu       sync.Mutex
+	counts   map[string]uint64
+	lastWarn map[string]time.Time
+	unwarned map[string]int // violations since the last warning
+}
+
+func newSchemaViolations() *schemaViolations {
+	return &schemaViolations{
+		counts:   make(map[string]uint64),
+		lastWarn: make(map[string]time.Time),
+		unwarned: make(map[string]int),
+	}
+}
+
+// record counts a violation on mapping and reports whether to warn now, and
+// how many earlier violations the warning covers.
+func (v *schemaViolations) record(mapping string, now time.Time) (warn bool, earlier int) {
+	if v == nil {
+		return false, 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.counts[mapping]++
+	if last, ok := v.lastWarn[mapping]; ok && now.Sub(last) < schemaWarnInterval {
+		v.unwarned[mapping]++
+		return false, 0
+	}
+	earlier = v.unwarned[mapping]
+	v.lastWarn[mapping] = now
+	v.unwarned[mapping] = 0
+	return true, earlier
+}
+
+// snapshot returns the violation counts by mapping.
+func (v *schemaViolations) snapshot() map[string]uint64 {
+	if v == nil {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	out := make(map[string]uint64, len(v.counts))
+	for k, n := range v.counts {
+		out[k] = n
+	}
+	return out
+}
+
+// deadLetter is published to schema.dead_letter_topic for a non-conforming message.
+type deadLetter struct {
+	Topic   string `json:"topic"`
+	Mapping string `json:"mapping"`
+	Error   string `json:"error"`
+	Payload string `json:"payload"`
+	MsgID   string `json:"msg_id"`
+}
+
+// checkSchema validates msg against the mapping's schema and reports whether
+// it may be bridged. Violations are counted, dead-lettered and (sampled)
+// reported to the ops channels.
+func (b *Bridge) checkSchema(msg types.Message, mapping Mapped, tr *trace) bool {
+	s := b.schemas[mapping.Key]
+	if s == nil {
+		return true
+	}
+	var doc interface{}
+	err := json.Unmarshal(msg.Payload, &doc)
+	if err != nil {
+		err = fmt.Errorf("payload is not JSON")
+	} else {
+		err = s.Validate(doc)
+	}
+	if err == nil {
+		return true
+	}
+
+	cfg := mapping.Schema
+	warn, earlier := b.schemaErrs.record(mapping.MQTTTopic, b.clock.Now())
+	b.logger.Debug().
+		Str("msg_id", msg.ID).
+		Str("topic", msg.Topic).
+		Str("mapping", mapping.MQTTTopic).
+		Err(err).
+		Msg("payload violates schema")
+	tr.step("%s: payload violates schema: %v", mapping.MQTTTopic, err)
+	if warn {
+		text := fmt.Sprintf("Schema violation on mapping %s, topic %s: %v", mapping.MQTTTopic, msg.Topic, err)
+		if earlier > 0 {
+			text += fmt.Sprintf(" (%d more since the last warning)", earlier)
+		}
+		b.notifyOps(text)
+	}
+
+	if cfg.DeadLetterTopic != "" {
+		letter, _ := json.Marshal(deadLetter{
+			Topic:   msg.Topic,
+			Mapping: mapping.MQTTTopic,
+			Error:   err.Error(),
+			Payload: string(msg.Payload),
+			MsgID:   msg.ID,
+		})
+		if err := b.mqttClient.Publish(cfg.DeadLetterTopic, 0, false, letter); err != nil {
+			b.logger.Warn().Err(err).Str("topic", cfg.DeadLetterTopic).Msg("failed to publish dead letter")
+		}
+	}
+
+	if cfg.Drop {
+		b.dropped(stats.DropSchema, msg).Str("mapping", mapping.MQTTTopic).Msg("message dropped: schema violation")
+		tr.step("%s: dropped, schema violation", mapping.MQTTTopic)
+		return false
+	}
+	return true
+}
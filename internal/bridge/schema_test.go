@@ -0,0 +1,76 @@
+package bridge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestCheckSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.json")
+	if err := os.WriteFile(path, []byte(`{"type": "object", "required": ["temperature"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := reloadTestConfig()
+	cfg.Bridge.Mappings[0].Schema = config.SchemaConfig{File: path, Drop: true}
+	cfg.Bridge.Mappings[1].Schema = config.SchemaConfig{File: path}
+	b := newReloadTestBridge(t, cfg)
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	b.clock = clock
+	b.drops = stats.NewDrops()
+	b.schemaErrs = newSchemaViolations()
+	var err error
+	if b.schemas, err = loadSchemas(cfg.Bridge.Mappings); err != nil {
+		t.Fatal(err)
+	}
+	mapped := func(topic string) Mapped {
+		m := b.mapper.Map(topic, 0)
+		if len(m) != 1 {
+			t.Fatalf("Map(%q) = %+v", topic, m)
+		}
+		return m[0]
+	}
+
+	if !b.checkSchema(types.Message{Topic: "a/x", Payload: []byte(`{"temperature": 1}`)}, mapped("a/x"), nil) {
+		t.Error("conforming payload rejected")
+	}
+	if b.checkSchema(types.Message{Topic: "a/x", Payload: []byte(`{"humidity": 1}`)}, mapped("a/x"), nil) {
+		t.Error("non-conforming payload bridged with schema.drop")
+	}
+	if !b.checkSchema(types.Message{Topic: "b/x", Payload: []byte(`not json`)}, mapped("b/x"), nil) {
+		t.Error("non-conforming payload dropped without schema.drop")
+	}
+
+	if got := b.schemaErrs.snapshot(); got["a/#"] != 1 || got["b/#"] != 1 {
+		t.Errorf("violations = %v", got)
+	}
+	if drops := b.drops.Snapshot(); len(drops) != 1 || drops[0].Reason != string(stats.DropSchema) || drops[0].Count != 1 {
+		t.Errorf("drops = %+v", drops)
+	}
+}
+
+func TestSchemaViolations_Sampling(t *testing.T) {
+	v := newSchemaViolations()
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	if warn, _ := v.record("a/#", now); !warn {
+		t.Error("first violation not warned about")
+	}
+	for i := 1; i <= 3; i++ {
+		if warn, _ := v.record("a/#", now.Add(time.Duration(i)*time.Minute)); warn {
+			t.Errorf("violation %d within the interval warned about", i)
+		}
+	}
+	if warn, _ := v.record("b/#", now); !warn {
+		t.Error("other mapping's first violation not warned about")
+	}
+	warn, earlier := v.record("a/#", now.Add(schemaWarnInterval))
+	if !warn || earlier != 3 {
+		t.Errorf("record after the interval = %v, %d; want a warning covering 3 earlier violations", warn, earlier)
+	}
+}
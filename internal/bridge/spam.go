@@ -0,0 +1,237 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/internal/transform"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+const (
+	defaultSpamRepeatWindow = 10 * time.Minute
+	defaultSpamMinLetters   = 12
+	defaultSpamDelay        = time.Minute
+)
+
+// spamWarnInterval is the least time between two ops warnings about the same
+// mapping's spam.
+const spamWarnInterval = 10 * time.Minute
+
+// spamFilter applies a mapping's spam heuristics. It remembers recent texts
+// and who sent them, for repeat_senders. A nil *spamFilter finds no spam.
+type spamFilter struct {
+	configured config.SpamConfig
+	cfg        config.SpamConfig // with the defaults applied
+	patterns   []*regexp.Regexp
+
+	mu       sync.Mutex
+	seen     map[string]*spamRepeat // by normalized text
+	swept    time.Time
+	lastWarn time.Time
+	unwarned int // spam since the last warning
+}
+
+// spamRepeat is the senders of one text since it was first seen.
+type spamRepeat struct {
+	first   time.Time
+	senders map[string]bool
+}
+
+// loadSpamFilters builds the spam filter of every mapping that has spam
+// heuristics, by mappingKey. Filters in old whose settings are unchanged are
+// kept, so a reload does not forget the texts seen.
+func loadSpamFilters(mappings []config.MappingConfig, old map[string]*spamFilter) (map[string]*spamFilter, error) {
+	filters := make(map[string]*spamFilter)
+	keys := mappingKeys(mappings)
+	for i, m := range mappings {
+		if !m.Spam.Enabled() {
+			continue
+		}
+		if f := old[keys[i]]; f != nil && reflect.DeepEqual(f.settings(), m.Spam) {
+			filters[keys[i]] = f
+			continue
+		}
+		f, err := newSpamFilter(m.Spam)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spam settings for mapping %q: %w", m.MQTTTopic, err)
+		}
+		filters[keys[i]] = f
+	}
+	return filters, nil
+}
+
+func newSpamFilter(cfg config.SpamConfig) (*spamFilter, error) {
+	f := &spamFilter{configured: cfg, cfg: cfg, seen: make(map[string]*spamRepeat)}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", p, err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	if f.cfg.RepeatWindow == 0 {
+		f.cfg.RepeatWindow = defaultSpamRepeatWindow
+	}
+	if f.cfg.MinLetters == 0 {
+		f.cfg.MinLetters = defaultSpamMinLetters
+	}
+	if f.cfg.Delay == 0 {
+		f.cfg.Delay = defaultSpamDelay
+	}
+	if f.cfg.Action == "" {
+		f.cfg.Action = config.SpamDrop
+	}
+	return f, nil
+}
+
+// settings returns the configuration the filter was built from, to compare
+// with a reloaded one.
+func (f *spamFilter) settings() config.SpamConfig {
+	return f.configured
+}
+
+// check reports why text from sender looks like spam, or "" if it does not.
+// Each heuristic is tried in turn: patterns, capitals, then repeats; a text
+// caught earlier is not counted as a repeat.
+func (f *spamFilter) check(text, sender string, now time.Time) string {
+	if f == nil {
+		return ""
+	}
+	for _, re := range f.patterns {
+		if re.MatchString(text) {
+			return fmt.Sprintf("matches %q", re.String())
+		}
+	}
+	if f.cfg.MaxCaps > 0 {
+		if share, ok := capsShare(text, f.cfg.MinLetters); ok && share > f.cfg.MaxCaps {
+			return fmt.Sprintf("%.0f%% capitals", share*100)
+		}
+	}
+	if f.cfg.RepeatSenders > 0 {
+		if n := f.repeat(text, sender, now); n >= f.cfg.RepeatSenders {
+			return fmt.Sprintf("same text from %d senders", n)
+		}
+	}
+	return ""
+}
+
+// repeat records text from sender and returns the number of senders of the
+// same text within repeat_window.
+func (f *spamFilter) repeat(text, sender string, now time.Time) int {
+	key := strings.ToLower(strings.Join(strings.Fields(transform.StripColors(text)), " "))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if now.Sub(f.swept) >= f.cfg.RepeatWindow {
+		f.swept = now
+		for k, r := range f.seen {
+			if now.Sub(r.first) >= f.cfg.RepeatWindow {
+				delete(f.seen, k)
+			}
+		}
+	}
+	r, ok := f.seen[key]
+	if !ok || now.Sub(r.first) >= f.cfg.RepeatWindow {
+		r = &spamRepeat{first: now, senders: make(map[string]bool)}
+		f.seen[key] = r
+	}
+	r.senders[sender] = true
+	return len(r.senders)
+}
+
+// warn counts spam and reports whether to tell the ops channels now, and how
+// much earlier spam the warning covers.
+func (f *spamFilter) warn(now time.Time) (warn bool, earlier int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.lastWarn.IsZero() && now.Sub(f.lastWarn) < spamWarnInterval {
+		f.unwarned++
+		return false, 0
+	}
+	earlier = f.unwarned
+	f.lastWarn, f.unwarned = now, 0
+	return true, earlier
+}
+
+// capsShare returns the share of capital letters in s, if it has at least
+// minLetters letters.
+func capsShare(s string, minLetters int) (float64, bool) {
+	letters, upper := 0, 0
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters == 0 || letters < minLetters {
+		return 0, false
+	}
+	return float64(upper) / float64(letters), true
+}
+
+// checkSpam applies the mapping's spam heuristics to the lines for channel
+// and reports whether to send them now. Spam is dropped or, with action
+// delay, sent after spam.delay; with action notify it is sent as usual. The
+// ops channels are told in each case (sampled).
+func (b *Bridge) checkSpam(msg types.Message, mapping Mapped, channel string, lines []string, tr *trace) bool {
+	f := b.spam[mapping.Key]
+	if f == nil {
+		return true
+	}
+	sender := msg.ID
+	if field := f.cfg.SenderField; field != "" {
+		if from := irc.MessageJSON(msg)[field]; from != "" {
+			sender = from
+		}
+	}
+	now := b.clock.Now()
+	text := strings.Join(lines, " ")
+	why := f.check(text, sender, now)
+	if why == "" {
+		return true
+	}
+
+	action := f.cfg.Action
+	if warn, earlier := f.warn(now); warn {
+		note := fmt.Sprintf("Possible spam on mapping %s in %s (%s, %s): %s", mapping.MQTTTopic, channel, why, action, b.limits.Fit(text))
+		if earlier > 0 {
+			note += fmt.Sprintf(" (%d more since the last warning)", earlier)
+		}
+		b.notifyOps(note)
+	}
+	switch action {
+	case config.SpamNotify:
+		tr.step("%s: possible spam (%s), sent anyway", mapping.MQTTTopic, why)
+		return true
+	case config.SpamDelay:
+		b.logger.Info().
+			Str("msg_id", msg.ID).
+			Str("channel", channel).
+			Str("spam", why).
+			Dur("delay", f.cfg.Delay).
+			Msg("possible spam, delaying")
+		tr.step("%s: possible spam (%s), delayed by %s", mapping.MQTTTopic, why, f.cfg.Delay)
+		// Without a trace: the message was handled long ago.
+		b.clock.AfterFunc(f.cfg.Delay, func() {
+			b.holdOrSend(context.Background(), msg, mapping, channel, lines, nil)
+		})
+		return false
+	}
+	b.droppedIn(stats.DropSpam, msg, channel).
+		Str("mapping", mapping.MQTTTopic).
+		Str("spam", why).
+		Msg("message dropped: possible spam")
+	tr.step("%s: dropped, possible spam (%s)", mapping.MQTTTopic, why)
+	return false
+}
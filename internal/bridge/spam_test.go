@@ -0,0 +1,124 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestSpamFilter(t *testing.T) {
+	f, err := newSpamFilter(config.SpamConfig{
+		RepeatSenders: 3,
+		MaxCaps:       0.7,
+		Patterns:      []string{`(?i)free\s+crypto`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	if why := f.check("get FREE  crypto now", "!a", now); why == "" {
+		t.Error("pattern not caught")
+	}
+	if why := f.check("BUY NOW AT THE MESH STORE", "!a", now); why != "100% capitals" {
+		t.Errorf("capitals: %q", why)
+	}
+	if why := f.check("OK", "!a", now); why != "" {
+		t.Errorf("short capitals caught: %q", why)
+	}
+
+	for _, sender := range []string{"!a", "!a", "!b"} {
+		if why := f.check("join my channel", sender, now); why != "" {
+			t.Fatalf("repeat from %s caught early: %q", sender, why)
+		}
+	}
+	if why := f.check("Join  my channel", "!c", now.Add(time.Minute)); why != "same text from 3 senders" {
+		t.Errorf("third sender: %q", why)
+	}
+	if why := f.check("join my channel", "!d", now.Add(defaultSpamRepeatWindow)); why != "" {
+		t.Errorf("repeat after the window caught: %q", why)
+	}
+
+	var nilFilter *spamFilter
+	if why := nilFilter.check("FREE CRYPTO", "!a", now); why != "" {
+		t.Errorf("nil filter caught %q", why)
+	}
+}
+
+func TestCheckSpam_Actions(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	for _, tc := range []struct {
+		action  string
+		send    bool
+		dropped int
+		waiters int
+	}{
+		{config.SpamDrop, false, 1, 0},
+		{config.SpamDelay, false, 0, 1},
+		{config.SpamNotify, true, 0, 0},
+	} {
+		cfg := reloadTestConfig()
+		cfg.Bridge.Mappings[0].Spam = config.SpamConfig{Patterns: []string{"spam"}, Action: tc.action}
+		b := newReloadTestBridge(t, cfg)
+		b.clock = clock
+		b.drops = stats.NewDrops()
+		var err error
+		if b.spam, err = loadSpamFilters(cfg.Bridge.Mappings, nil); err != nil {
+			t.Fatal(err)
+		}
+		mapping := Mapped{MappingConfig: cfg.Bridge.Mappings[0], Key: mappingKeys(cfg.Bridge.Mappings)[0]}
+		waiters := clock.Waiters()
+
+		if !b.checkSpam(types.Message{Topic: "a/x"}, mapping, "#a", []string{"hello"}, nil) {
+			t.Errorf("%s: clean line held back", tc.action)
+		}
+		if got := b.checkSpam(types.Message{Topic: "a/x"}, mapping, "#a", []string{"buy spam"}, nil); got != tc.send {
+			t.Errorf("%s: send = %v, want %v", tc.action, got, tc.send)
+		}
+		dropped := 0
+		for _, d := range b.drops.Snapshot() {
+			if d.Reason == string(stats.DropSpam) {
+				dropped = int(d.Count)
+			}
+		}
+		if dropped != tc.dropped {
+			t.Errorf("%s: %d spam drops, want %d", tc.action, dropped, tc.dropped)
+		}
+		if got := clock.Waiters() - waiters; got != tc.waiters {
+			t.Errorf("%s: %d delayed sends, want %d", tc.action, got, tc.waiters)
+		}
+	}
+}
+
+func TestLoadSpamFilters_KeepsUnchanged(t *testing.T) {
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "a/#", Spam: config.SpamConfig{RepeatSenders: 2}},
+		{MQTTTopic: "b/#"},
+	}
+	old, err := loadSpamFilters(mappings, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := mappingKeys(mappings)
+	if len(old) != 1 || old[keys[0]] == nil {
+		t.Fatalf("filters = %v", old)
+	}
+	kept, _ := loadSpamFilters(mappings, old)
+	if kept[keys[0]] != old[keys[0]] {
+		t.Error("unchanged filter replaced")
+	}
+	mappings[0].Spam.RepeatSenders = 3
+	changed, _ := loadSpamFilters(mappings, old)
+	if changed[keys[0]] == old[keys[0]] {
+		t.Error("changed filter kept")
+	}
+
+	mappings[1].Spam.Patterns = []string{"("}
+	if _, err := loadSpamFilters(mappings, nil); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
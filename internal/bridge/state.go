@@ -0,0 +1,212 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stateBundleVersion is bumped on incompatible changes to stateBundle.
+const stateBundleVersion = 1
+
+// stateBundle is the runtime state carried over between hosts: active mutes,
+// runtime mapping changes and the state of stateful processors.
+type stateBundle struct {
+	Version    int               `json:"version"`
+	Created    time.Time         `json:"created"`
+	Mutes      []bundleMute      `json:"mutes,omitempty"`
+	Mappings   []bundleMapping   `json:"mappings,omitempty"`
+	Processors []bundleProcessor `json:"processors,omitempty"`
+}
+
+type bundleMute struct {
+	Target string    `json:"target"`
+	Until  time.Time `json:"until"`
+}
+
+// bundleMapping identifies a mapping by mqtt_topic and its position among
+// mappings with that topic (see mappingKey), so bundles survive mappings
+// being added or reordered. Mappings added at runtime carry their channels
+// and are added again on import.
+type bundleMapping struct {
+	Topic    string   `json:"topic"`
+	Nth      int      `json:"nth,omitempty"`
+	Paused   bool     `json:"paused,omitempty"`
+	Format   string   `json:"format,omitempty"` // only if changed at runtime or added
+	Added    bool     `json:"added,omitempty"`
+	Channels []string `json:"channels,omitempty"` // only if added
+}
+
+// bundleProcessor is the state of the processor of the mapping identified
+// like bundleMapping.
+type bundleProcessor struct {
+	Topic     string          `json:"topic"`
+	Nth       int             `json:"nth,omitempty"`
+	Processor string          `json:"processor"`
+	State     json.RawMessage `json:"state"`
+}
+
+// ExportState writes the runtime state bundle to w as JSON.
+func (b *Bridge) ExportState(w io.Writer) error {
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+
+	bundle := stateBundle{Version: stateBundleVersion, Created: b.clock.Now()}
+	for _, m := range b.mutes.list() {
+		bundle.Mutes = append(bundle.Mutes, bundleMute{Target: m.Target, Until: m.Until})
+	}
+
+	seen := make(map[string]int)
+	for _, info := range b.mapper.Info() {
+		nth := seen[info.Topic]
+		seen[info.Topic]++
+		entry := bundleMapping{Topic: info.Topic, Nth: nth, Paused: info.Paused}
+		switch {
+		case info.Added:
+			entry.Added, entry.Channels, entry.Format = true, info.Channels, info.Format
+		case info.Changed:
+			entry.Format = info.Format
+		}
+		if entry.Paused || entry.Added || entry.Format != "" {
+			bundle.Mappings = append(bundle.Mappings, entry)
+		}
+	}
+
+	configured := b.current.Bridge.Mappings
+	seen = make(map[string]int)
+	for _, m := range configured {
+		nth := seen[m.MQTTTopic]
+		seen[m.MQTTTopic]++
+		sp, ok := b.processors[mappingKey(m.MQTTTopic, nth)].(StatefulProcessor)
+		if !ok {
+			continue
+		}
+		state, err := sp.ExportState()
+		if err != nil {
+			return fmt.Errorf("export %s processor state of %q: %w", m.Processor, m.MQTTTopic, err)
+		}
+		bundle.Processors = append(bundle.Processors, bundleProcessor{Topic: m.MQTTTopic, Nth: nth, Processor: m.Processor, State: state})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bundle)
+}
+
+// ExportStateFile writes the state bundle to bridge.state_bundle atomically
+// and returns the path (implements admin.BridgeAdmin).
+func (b *Bridge) ExportStateFile() (string, error) {
+	path := b.config.StateBundle
+	if path == "" {
+		return "", fmt.Errorf("no state bundle path configured (bridge.state_bundle)")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("create state bundle: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := b.ExportState(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("write state bundle: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("write state bundle: %w", err)
+	}
+	b.logger.Info().Str("path", path).Msg("state bundle exported")
+	return path, nil
+}
+
+// ImportState applies a state bundle read from r: mutes that have not
+// expired, mapping changes for mappings that still exist, mappings added at
+// runtime and processor state for mappings with the same processor. It
+// returns what was applied.
+// Call it before Run.
+func (b *Bridge) ImportState(r io.Reader) ([]string, error) {
+	var bundle stateBundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("parse state bundle: %w", err)
+	}
+	if bundle.Version != stateBundleVersion {
+		return nil, fmt.Errorf("unsupported state bundle version %d (want %d)", bundle.Version, stateBundleVersion)
+	}
+
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+
+	var summary []string
+	now := b.clock.Now()
+	mutes := 0
+	for _, m := range bundle.Mutes {
+		if !m.Until.After(now) {
+			continue
+		}
+		if _, err := b.mutes.add(m.Target, m.Until.Sub(now)); err != nil {
+			return summary, fmt.Errorf("mute %s: %w", m.Target, err)
+		}
+		mutes++
+	}
+	if mutes > 0 {
+		summary = append(summary, fmt.Sprintf("%d mute(s)", mutes))
+	}
+
+	index := make(map[string]int) // mappingKey → 1-based index
+	seen := make(map[string]int)
+	for _, info := range b.mapper.Info() {
+		index[mappingKey(info.Topic, seen[info.Topic])] = info.Index
+		seen[info.Topic]++
+	}
+	for _, m := range bundle.Mappings {
+		if m.Added {
+			i, err := b.addMapping(m.Topic, m.Channels, m.Format)
+			if err != nil {
+				return summary, fmt.Errorf("add mapping %s: %w", m.Topic, err)
+			}
+			if err := b.mapper.SetPaused(i, m.Paused); err != nil {
+				return summary, fmt.Errorf("mapping %s: %w", m.Topic, err)
+			}
+			summary = append(summary, fmt.Sprintf("added mapping #%d %s → %s (paused: %t)", i, m.Topic, strings.Join(m.Channels, ","), m.Paused))
+			continue
+		}
+		i, ok := index[mappingKey(m.Topic, m.Nth)]
+		if !ok {
+			summary = append(summary, fmt.Sprintf("skipped mapping %s: not configured", m.Topic))
+			continue
+		}
+		if m.Format != "" {
+			if err := b.mapper.SetFormat(i, m.Format); err != nil {
+				return summary, fmt.Errorf("mapping %s: %w", m.Topic, err)
+			}
+		}
+		if err := b.mapper.SetPaused(i, m.Paused); err != nil {
+			return summary, fmt.Errorf("mapping %s: %w", m.Topic, err)
+		}
+		summary = append(summary, fmt.Sprintf("mapping #%d %s (paused: %t, format changed: %t)", i, m.Topic, m.Paused, m.Format != ""))
+	}
+
+	configured := make(map[string]string) // mappingKey → processor
+	for i, key := range mappingKeys(b.current.Bridge.Mappings) {
+		configured[key] = b.current.Bridge.Mappings[i].Processor
+	}
+	for _, ps := range bundle.Processors {
+		key := mappingKey(ps.Topic, ps.Nth)
+		sp, ok := b.processors[key].(StatefulProcessor)
+		if !ok || configured[key] != ps.Processor {
+			summary = append(summary, fmt.Sprintf("skipped %s processor state of %s: not configured", ps.Processor, ps.Topic))
+			continue
+		}
+		if err := sp.ImportState(ps.State); err != nil {
+			return summary, fmt.Errorf("import %s processor state of %q: %w", ps.Processor, ps.Topic, err)
+		}
+		summary = append(summary, fmt.Sprintf("%s processor state of %s", ps.Processor, ps.Topic))
+	}
+
+	b.logger.Info().Strs("applied", summary).Time("created", bundle.Created).Msg("state bundle imported")
+	return summary, nil
+}
@@ -0,0 +1,165 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/mqtt"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+type statefulTestProcessor struct {
+	state string
+}
+
+func (p *statefulTestProcessor) Process(types.Message) (ProcessResult, error) {
+	return ProcessResult{}, nil
+}
+
+func (p *statefulTestProcessor) ExportState() (json.RawMessage, error) {
+	return json.Marshal(p.state)
+}
+
+func (p *statefulTestProcessor) ImportState(state json.RawMessage) error {
+	return json.Unmarshal(state, &p.state)
+}
+
+func newStateTestBridge(t *testing.T, clock schedule.Clock, mappings []config.MappingConfig) *Bridge {
+	t.Helper()
+	cfg := &config.Config{
+		MQTT:   config.MQTTConfig{Broker: "tcp://localhost:1883", Topics: []config.TopicConfig{{Pattern: "sensors/#"}}},
+		Bridge: config.BridgeConfig{Mappings: mappings},
+	}
+	mqttClient, err := mqtt.New(cfg.MQTT, make(chan types.Message, 1), connstate.New("mqtt", 0), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("mqtt.New: %v", err)
+	}
+	b := &Bridge{
+		config:     cfg.Bridge,
+		current:    cfg,
+		mqttClient: mqttClient,
+		mapper:     NewMapper(mappings),
+		processors: make(map[string]Processor),
+		clock:      clock,
+		mutes:      newMuteList(clock),
+		logger:     zerolog.New(os.Stderr).Level(zerolog.Disabled),
+	}
+	keys := mappingKeys(mappings)
+	for i, m := range mappings {
+		if m.Processor != "" {
+			b.processors[keys[i]] = &statefulTestProcessor{}
+		}
+	}
+	return b
+}
+
+func TestStateBundle_RoundTrip(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "sensors/#", IRCChannels: []string{"#s"}, MessageFormat: "{{.Payload}}"},
+		{MQTTTopic: "msh/#", IRCChannels: []string{"#mesh"}, Processor: "stateful"},
+		{MQTTTopic: "sensors/#", IRCChannels: []string{"#s2"}},
+		{MQTTTopic: "msh/#", IRCChannels: []string{"#mesh2"}, Processor: "stateful"},
+	}
+	src := newStateTestBridge(t, clock, mappings)
+	if _, err := src.mutes.add("alerts/#", 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	_ = src.mapper.SetPaused(3, true)
+	_ = src.mapper.SetFormat(1, "T {{.Payload}}")
+	src.processors["msh/##0"].(*statefulTestProcessor).state = "nodes"
+	src.processors["msh/##1"].(*statefulTestProcessor).state = "other nodes"
+	if _, err := src.AddMapping("alerts/#", []string{"#alerts"}, "! {{.Payload}}"); err != nil {
+		t.Fatal(err)
+	}
+	_ = src.mapper.SetPaused(5, true)
+
+	var buf bytes.Buffer
+	if err := src.ExportState(&buf); err != nil {
+		t.Fatalf("ExportState: %v", err)
+	}
+
+	// The new host lists the mappings in another order.
+	clock.Advance(time.Hour)
+	dst := newStateTestBridge(t, clock, []config.MappingConfig{mappings[1], mappings[0], mappings[3], mappings[2]})
+	if _, err := dst.ImportState(&buf); err != nil {
+		t.Fatalf("ImportState: %v", err)
+	}
+
+	mutes := dst.mutes.list()
+	if len(mutes) != 1 || mutes[0].Target != "alerts/#" || !mutes[0].Until.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("mutes = %+v, want alerts/# for the remaining hour", mutes)
+	}
+	infos := dst.mapper.Info()
+	if infos[1].Format != "T {{.Payload}}" || infos[1].Paused {
+		t.Errorf("first sensors/# mapping = %+v, want runtime format, not paused", infos[1])
+	}
+	if !infos[3].Paused || infos[3].Format != "" {
+		t.Errorf("second sensors/# mapping = %+v, want paused", infos[3])
+	}
+	if len(infos) != 5 || !infos[4].Added || !infos[4].Paused || infos[4].Topic != "alerts/#" ||
+		infos[4].Channels[0] != "#alerts" || infos[4].Format != "! {{.Payload}}" {
+		t.Errorf("infos = %+v, want the runtime alerts/# mapping added, paused", infos)
+	}
+	topics := dst.mqttClient.Topics()
+	if len(topics) != 2 || topics[1].Pattern != "alerts/#" {
+		t.Errorf("subscriptions = %v, want alerts/# added", topics)
+	}
+	if got := dst.processors["msh/##0"].(*statefulTestProcessor).state; got != "nodes" {
+		t.Errorf("first processor state = %q, want nodes", got)
+	}
+	if got := dst.processors["msh/##1"].(*statefulTestProcessor).state; got != "other nodes" {
+		t.Errorf("second processor state = %q, want other nodes", got)
+	}
+}
+
+func TestStateBundle_SkipsExpiredAndUnknown(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+	b := newStateTestBridge(t, clock, []config.MappingConfig{{MQTTTopic: "a/#", IRCChannels: []string{"#a"}}})
+
+	bundle := `{"version":1,"mutes":[{"target":"x/#","until":"2026-05-01T11:00:00Z"}],
+		"mappings":[{"topic":"gone/#","paused":true}],
+		"processors":[{"topic":"msh/#","processor":"meshtastic","state":{}}]}`
+	summary, err := b.ImportState(strings.NewReader(bundle))
+	if err != nil {
+		t.Fatalf("ImportState: %v", err)
+	}
+	if len(b.mutes.list()) != 0 {
+		t.Error("expired mute was imported")
+	}
+	if len(summary) != 2 || !strings.Contains(summary[0], "skipped mapping gone/#") || !strings.Contains(summary[1], "skipped meshtastic") {
+		t.Errorf("summary = %v, want both entries skipped", summary)
+	}
+
+	if _, err := b.ImportState(strings.NewReader(`{"version":99}`)); err == nil {
+		t.Error("unknown bundle version should fail")
+	}
+}
+
+func TestExportStateFile(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+	b := newStateTestBridge(t, clock, nil)
+	if _, err := b.ExportStateFile(); err == nil {
+		t.Error("ExportStateFile() without bridge.state_bundle should fail")
+	}
+
+	b.config.StateBundle = filepath.Join(t.TempDir(), "state.json")
+	path, err := b.ExportStateFile()
+	if err != nil {
+		t.Fatalf("ExportStateFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), `"version": 1`) {
+		t.Errorf("bundle = %s (%v)", data, err)
+	}
+}
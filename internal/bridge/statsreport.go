@@ -0,0 +1,85 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// statsReport returns the health/stats report published to
+// bridge.stats_publish.topic: the /health document plus the time and version.
+func (b *Bridge) statsReport(now time.Time) ([]byte, error) {
+	status := b.HealthStatus()
+	if status["mqtt_connected"] == true && status["irc_connected"] == true {
+		status["status"] = "healthy"
+	} else {
+		status["status"] = "unhealthy"
+	}
+	status["version"] = b.version
+	status["timestamp"] = now.UTC().Format(time.RFC3339)
+	return json.Marshal(status)
+}
+
+// publishStats publishes one stats report.
+func (b *Bridge) publishStats() {
+	cfg := b.config.StatsPublish
+	report, err := b.statsReport(b.clock.Now())
+	if err != nil {
+		b.logger.Error().Err(err).Msg("failed to encode stats report")
+		return
+	}
+	if err := b.mqttClient.Publish(cfg.Topic, cfg.QoS, cfg.Retain, report); err != nil {
+		b.logger.Warn().Err(err).Msg("failed to publish stats report")
+		return
+	}
+	b.logger.Debug().Str("topic", cfg.Topic).Int("bytes", len(report)).Msg("stats report published")
+}
+
+// runStatsPublisher publishes the stats report every stats_publish.interval.
+func (b *Bridge) runStatsPublisher(ctx context.Context) {
+	defer b.wg.Done()
+
+	b.publishStats()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.clock.After(b.config.StatsPublish.Interval):
+			b.publishStats()
+		}
+	}
+}
+
+// minuteRate counts events over the last minute in one-second buckets.
+type minuteRate struct {
+	mu      sync.Mutex
+	buckets [60]int64
+	seconds [60]int64 // unix second each bucket counts
+}
+
+// add counts one event at now.
+func (r *minuteRate) add(now time.Time) {
+	sec := now.Unix()
+	i := sec % 60
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seconds[i] != sec {
+		r.seconds[i], r.buckets[i] = sec, 0
+	}
+	r.buckets[i]++
+}
+
+// count returns the number of events in the minute up to now.
+func (r *minuteRate) count(now time.Time) int64 {
+	sec := now.Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for i, s := range r.seconds {
+		if s > sec-60 && s <= sec {
+			n += r.buckets[i]
+		}
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package bridge
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/mqtt"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestStatsReport(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	mqttClient, err := mqtt.New(config.MQTTConfig{Broker: "tcp://localhost:1883"}, make(chan types.Message, 1), connstate.New("mqtt", 0), logger)
+	if err != nil {
+		t.Fatalf("mqtt.New: %v", err)
+	}
+	b := &Bridge{
+		mqttClient: mqttClient,
+		ircClient:  irc.New(config.IRCConfig{Server: "localhost:6667", Nickname: "bot"}, connstate.New("irc", 0), logger),
+		msgQueue:   make(chan types.Message, 10),
+		usage:      newUsageTracker(nil, time.UTC, schedule.NewFake(time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC))),
+		drops:      stats.NewDrops(),
+		version:    "1.2.3",
+		clock:      schedule.Real,
+		logger:     logger,
+	}
+	b.drops.Record(stats.DropMute, "a/b", time.Now())
+
+	data, err := b.statsReport(time.Date(2026, 6, 1, 10, 0, 0, 0, time.FixedZone("CEST", 7200)))
+	if err != nil {
+		t.Fatalf("statsReport: %v", err)
+	}
+	var report map[string]interface{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, data)
+	}
+	want := map[string]interface{}{
+		"status":         "unhealthy",
+		"mqtt_connected": false,
+		"version":        "1.2.3",
+		"timestamp":      "2026-06-01T08:00:00Z",
+		"queue_capacity": 10.0,
+	}
+	for k, v := range want {
+		if report[k] != v {
+			t.Errorf("report[%s] = %v, want %v", k, report[k], v)
+		}
+	}
+	if drops, _ := report["drops"].([]interface{}); len(drops) != 1 {
+		t.Errorf("report[drops] = %v, want one entry", report["drops"])
+	}
+}
+
+func TestMinuteRate(t *testing.T) {
+	var r minuteRate
+	start := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 90; i++ {
+		r.add(start.Add(time.Duration(i) * time.Second))
+	}
+	last := start.Add(89 * time.Second)
+	if got := r.count(last); got != 60 {
+		t.Errorf("count() = %d, want 60", got)
+	}
+	if got := r.count(last.Add(30 * time.Second)); got != 30 {
+		t.Errorf("count() 30s later = %d, want 30", got)
+	}
+	if got := r.count(last.Add(2 * time.Minute)); got != 0 {
+		t.Errorf("count() 2m later = %d, want 0", got)
+	}
+}
@@ -0,0 +1,195 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/persist"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// snapshotCheck is how often stores in memory mode (bridge.storage) are
+// checked for a due snapshot.
+const snapshotCheck = 30 * time.Second
+
+// queueFileMessage is a queued message as saved to bridge.storage.queue.file.
+// The cached JSON is left out; it is parsed again when the message is
+// handled.
+type queueFileMessage struct {
+	ID        string            `json:"id"`
+	Topic     string            `json:"topic"`
+	Payload   []byte            `json:"payload"`
+	Timestamp time.Time         `json:"timestamp"`
+	QoS       byte              `json:"qos,omitempty"`
+	Priority  types.Priority    `json:"priority,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+}
+
+// queueStore saves the unsent messages to bridge.storage.queue.file, so they
+// are delivered after a restart. Only the message processor goroutine uses
+// it. A nil *queueStore saves nothing.
+type queueStore struct {
+	path  string
+	state *persist.State
+	saved int // messages in the file
+}
+
+// openQueueStore returns the queue store of cfg (nil without a file) and the
+// messages saved by the last run, oldest first. A corrupt queue file is moved
+// aside (see persist.Load).
+func openQueueStore(cfg config.StoreConfig, clock schedule.Clock) (*queueStore, []types.Message, error) {
+	if cfg.File == "" {
+		return nil, nil, nil
+	}
+	q := &queueStore{path: cfg.File, state: persist.NewState(cfg.Policy(), clock)}
+	var msgs []types.Message
+	err := persist.Load(q.path, cfg.Backup, func(data []byte) error {
+		var saved []queueFileMessage
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return err
+		}
+		msgs = make([]types.Message, len(saved))
+		for i, m := range saved {
+			msgs[i] = types.Message{ID: m.ID, Topic: m.Topic, Payload: m.Payload, Timestamp: m.Timestamp, QoS: m.QoS, Priority: m.Priority, Meta: m.Meta}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("queue: %w", err)
+	}
+	q.saved = len(msgs)
+	return q, msgs, nil
+}
+
+// write replaces the queue file with msgs.
+func (q *queueStore) write(msgs []types.Message) error {
+	saved := make([]queueFileMessage, len(msgs))
+	for i, m := range msgs {
+		saved[i] = queueFileMessage{ID: m.ID, Topic: m.Topic, Payload: m.Payload, Timestamp: m.Timestamp, QoS: m.QoS, Priority: m.Priority, Meta: m.Meta}
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("queue: marshal: %w", err)
+	}
+	if err := persist.WriteFile(q.path, data, q.state.Policy()); err != nil {
+		return fmt.Errorf("queue: %w", err)
+	}
+	q.saved = len(msgs)
+	q.state.Saved()
+	return nil
+}
+
+// queueChanged is called by the message processor whenever the queue may
+// have changed: it saves the queue at once in file mode, or when the
+// snapshot is due in memory mode.
+func (b *Bridge) queueChanged() {
+	if b.queueStore == nil {
+		return
+	}
+	if !b.queueStore.state.Changed() {
+		return
+	}
+	b.saveQueue()
+}
+
+// saveQueue writes the queued messages to the queue file and puts them back.
+// Called from processMessages.
+func (b *Bridge) saveQueue() {
+	q := b.queueStore
+	if len(b.backlog)+len(b.highQueue)+len(b.msgQueue) == 0 && q.saved == 0 {
+		q.state.Saved()
+		return
+	}
+	msgs := b.requeue()
+	if err := q.write(msgs); err != nil {
+		b.logger.Warn().Err(err).Msg("failed to save message queue")
+	}
+}
+
+// requeue returns everything queued, the backlog first, and leaves it
+// queued. Messages that no longer fit their queue, because new ones arrived
+// in the meantime, go to the backlog. Called from processMessages.
+func (b *Bridge) requeue() []types.Message {
+	b.takeSpill()
+	msgs := append([]types.Message(nil), b.backlog...)
+	b.queueMu.Lock()
+	defer b.queueMu.Unlock()
+	for _, ch := range []chan types.Message{b.highQueue, b.msgQueue} {
+		var taken []types.Message
+		for n := len(ch); n > 0; n-- {
+			select {
+			case msg := <-ch:
+				taken = append(taken, msg)
+			default:
+				n = 0
+			}
+		}
+		msgs = append(msgs, taken...)
+		for i, msg := range taken {
+			if b.queueClosed && ch == b.msgQueue {
+				b.backlog = append(b.backlog, taken[i:]...)
+				break
+			}
+			select {
+			case ch <- msg:
+				continue
+			default:
+			}
+			b.backlog = append(b.backlog, taken[i:]...)
+			break
+		}
+	}
+	return msgs
+}
+
+// saveQueueOnShutdown writes what is left in the queues once the message
+// processor has stopped.
+func (b *Bridge) saveQueueOnShutdown() {
+	if b.queueStore == nil {
+		return
+	}
+	msgs := b.collectQueued()
+	if err := b.queueStore.write(msgs); err != nil {
+		b.logger.Error().Err(err).Msg("failed to save message queue")
+		return
+	}
+	if len(msgs) > 0 {
+		b.logger.Info().Int("messages", len(msgs)).Str("path", b.queueStore.path).Msg("saved unsent messages")
+	}
+}
+
+// runSnapshots writes the snapshots of the history and of processor state
+// (node registries) kept in memory mode when they are due. The queue is
+// snapshotted by processMessages.
+func (b *Bridge) runSnapshots(ctx context.Context) {
+	defer b.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.clock.After(snapshotCheck):
+		}
+		if err := b.history.snapshot(); err != nil {
+			b.logger.Warn().Err(err).Msg("failed to save history snapshot")
+		}
+		b.reloadMu.Lock()
+		for key, p := range b.processors {
+			if sp, ok := p.(SnapshottingProcessor); ok {
+				if err := sp.Snapshot(); err != nil {
+					b.logger.Warn().Err(err).Str("mapping", key).Msg("failed to save processor snapshot")
+				}
+			}
+		}
+		b.reloadMu.Unlock()
+	}
+}
+
+// snapshotsNeeded reports whether any store besides the queue is kept in
+// memory between snapshots.
+func snapshotsNeeded(storage config.StorageConfig, historyEnabled bool) bool {
+	return storage.NodeDB.Policy().Memory() || historyEnabled && storage.History.File != "" && storage.History.Policy().Memory()
+}
@@ -0,0 +1,97 @@
+package bridge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/persist"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestQueueStore(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC))
+	cfg := config.StoreConfig{File: filepath.Join(t.TempDir(), "queue.json")}
+	q, restored, err := openQueueStore(cfg, clock)
+	if err != nil || len(restored) != 0 {
+		t.Fatalf("openQueueStore (no file) = %v, %v", restored, err)
+	}
+	b := &Bridge{
+		msgQueue:   make(chan types.Message, 2),
+		highQueue:  make(chan types.Message, 2),
+		backlog:    []types.Message{{ID: "m1", Topic: "a", Payload: []byte("1")}},
+		queueStore: q,
+		logger:     zerolog.Nop(),
+	}
+	b.highQueue <- types.Message{ID: "m2", Topic: "a", Priority: types.PriorityHigh}
+	b.msgQueue <- types.Message{ID: "m3", Topic: "b", JSON: map[string]interface{}{"x": 1.0}, JSONParsed: true}
+
+	// File mode: saved at once, and everything stays queued.
+	b.queueChanged()
+	if len(b.backlog) != 1 || len(b.highQueue) != 1 || len(b.msgQueue) != 1 {
+		t.Fatalf("queues after saving: backlog %d, high %d, normal %d", len(b.backlog), len(b.highQueue), len(b.msgQueue))
+	}
+	_, restored, err = openQueueStore(cfg, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 3 || restored[0].ID != "m1" || string(restored[0].Payload) != "1" ||
+		restored[1].Priority != types.PriorityHigh || restored[2].JSONParsed {
+		t.Errorf("restored = %+v", restored)
+	}
+
+	// Memory mode: only saved once the snapshot is due.
+	cfg.Mode = persist.ModeMemory
+	b.queueStore, _, _ = openQueueStore(cfg, clock)
+	<-b.msgQueue
+	b.queueChanged()
+	if _, restored, _ = openQueueStore(cfg, clock); len(restored) != 3 {
+		t.Errorf("memory mode saved before the snapshot was due: %d messages", len(restored))
+	}
+	clock.Advance(persist.DefaultInterval)
+	b.queueChanged()
+	if _, restored, _ = openQueueStore(cfg, clock); len(restored) != 2 {
+		t.Errorf("snapshot has %d messages, want 2", len(restored))
+	}
+
+	// A corrupt queue file does not fail startup.
+	if err := os.WriteFile(cfg.File, []byte(`[{"id":"m1",`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, restored, err = openQueueStore(cfg, clock); err != nil || len(restored) != 0 {
+		t.Errorf("openQueueStore (corrupt) = %v, %v", restored, err)
+	}
+}
+
+func TestChannelHistory_File(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := config.StoreConfig{File: filepath.Join(t.TempDir(), "history.json"), Mode: persist.ModeMemory}
+	h, err := openChannelHistory(2, time.UTC, store, schedule.NewFake(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, text := range []string{"a", "b", "c"} {
+		if err := h.record("#Sensors", text, "", "", base.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(store.File); !os.IsNotExist(err) {
+		t.Fatalf("history written in memory mode before a snapshot: %v", err)
+	}
+	if err := h.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	h2, err := openChannelHistory(1, time.UTC, store, schedule.NewFake(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h2.since("#sensors", base); len(got) != 1 || got[0].text != "c" {
+		t.Errorf("restored history = %v, want c", got)
+	}
+}
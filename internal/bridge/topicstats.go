@@ -0,0 +1,165 @@
+package bridge
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// otherTopics is the bucket of topics neither tracked nor matching a
+// topic_stats.rollup pattern.
+const otherTopics = "(other)"
+
+// topicStats counts messages per topic (bridge.topic_stats). Topics matching
+// a detail pattern are tracked one by one until max_topics of them are; the
+// rest are counted under the first matching rollup pattern, or otherTopics,
+// so a subscription to "#" cannot grow the counters without bound. A nil
+// *topicStats counts nothing and tracks every topic.
+type topicStats struct {
+	cfg config.TopicStatsConfig
+	max int
+
+	mu       sync.Mutex
+	counts   map[string]*types.TopicCount // topic or bucket → counts
+	detailed int                          // topics in counts tracked one by one
+}
+
+// newTopicStats returns nil unless topic_stats is enabled.
+func newTopicStats(cfg config.TopicStatsConfig) *topicStats {
+	if !cfg.Enabled {
+		return nil
+	}
+	s := &topicStats{cfg: cfg, max: cfg.MaxTopics, counts: make(map[string]*types.TopicCount)}
+	if s.max == 0 {
+		s.max = config.DefaultMaxTopics
+	}
+	return s
+}
+
+// countLocked returns the counts topic is recorded in, adding them if
+// needed. The caller holds mu.
+func (s *topicStats) countLocked(topic string, match func(topic, pattern string) bool) *types.TopicCount {
+	if c, ok := s.counts[topic]; ok && !c.Rollup {
+		return c
+	}
+	if s.detailed < s.max && matchesAny(topic, s.cfg.Detail, match) {
+		c := &types.TopicCount{Topic: topic}
+		s.counts[topic] = c
+		s.detailed++
+		return c
+	}
+	bucket := otherTopics
+	for _, pattern := range s.cfg.Rollup {
+		if match(topic, pattern) {
+			bucket = pattern
+			break
+		}
+	}
+	c, ok := s.counts[bucket]
+	if !ok {
+		c = &types.TopicCount{Topic: bucket, Rollup: true}
+		s.counts[bucket] = c
+	}
+	return c
+}
+
+// matchesAny reports whether topic matches one of patterns; no patterns
+// match every topic.
+func matchesAny(topic string, patterns []string, match func(topic, pattern string) bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if match(topic, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// received counts a message received on topic and reports whether the topic
+// is tracked one by one, e.g. to keep its payload sample for !fields.
+func (s *topicStats) received(topic string, now time.Time, match func(topic, pattern string) bool) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.countLocked(topic, match)
+	c.Received++
+	c.Last = now
+	return !c.Rollup
+}
+
+// sent counts a line of a message on topic delivered to a channel.
+func (s *topicStats) sent(topic string, match func(topic, pattern string) bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.countLocked(topic, match).Sent++
+}
+
+// dropped counts a message on topic the bridge dropped.
+func (s *topicStats) dropped(topic string, match func(topic, pattern string) bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.countLocked(topic, match).Dropped++
+}
+
+// snapshot returns the counts, busiest topic first.
+func (s *topicStats) snapshot() []types.TopicCount {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]types.TopicCount, 0, len(s.counts))
+	for _, c := range s.counts {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Received != out[j].Received {
+			return out[i].Received > out[j].Received
+		}
+		return out[i].Topic < out[j].Topic
+	})
+	return out
+}
+
+// writeMetrics writes the topic counters in the Prometheus text format.
+func (s *topicStats) writeMetrics(w io.Writer) error {
+	if s == nil {
+		return nil
+	}
+	counts := s.snapshot()
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Topic < counts[j].Topic })
+
+	var sb strings.Builder
+	sb.WriteString("# HELP mqtt2irc_topic_messages_total Messages per topic (bridge.topic_stats) and outcome; untracked topics are counted under their roll-up pattern or (other).\n")
+	sb.WriteString("# TYPE mqtt2irc_topic_messages_total counter\n")
+	for _, c := range counts {
+		topic := promLabel(c.Topic)
+		fmt.Fprintf(&sb, "mqtt2irc_topic_messages_total{topic=%s,outcome=\"received\"} %d\n", topic, c.Received)
+		fmt.Fprintf(&sb, "mqtt2irc_topic_messages_total{topic=%s,outcome=\"sent\"} %d\n", topic, c.Sent)
+		fmt.Fprintf(&sb, "mqtt2irc_topic_messages_total{topic=%s,outcome=\"dropped\"} %d\n", topic, c.Dropped)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// TopicStats returns the message counts per topic, busiest first, or nil
+// without bridge.topic_stats (implements admin.BridgeAdmin).
+func (b *Bridge) TopicStats() []types.TopicCount {
+	return b.topicStats.snapshot()
+}
@@ -0,0 +1,64 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+func TestTopicStats(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	match := NewMapper(nil).matchTopic
+	s := newTopicStats(config.TopicStatsConfig{
+		Enabled:   true,
+		Detail:    []string{"sensors/#"},
+		Rollup:    []string{"msh/+/#"},
+		MaxTopics: 2,
+	})
+
+	for _, topic := range []string{"sensors/attic", "sensors/cellar", "sensors/garage", "msh/EU/1", "msh/US/2", "misc"} {
+		s.received(topic, now, match)
+	}
+	if !s.received("sensors/attic", now, match) {
+		t.Error("tracked topic reported as rolled up")
+	}
+	if s.received("sensors/garage", now, match) {
+		t.Error("topic over max_topics reported as tracked")
+	}
+	s.sent("sensors/attic", match)
+	s.dropped("msh/EU/1", match)
+
+	got := make(map[string][3]uint64)
+	for _, c := range s.snapshot() {
+		got[c.Topic] = [3]uint64{c.Received, c.Sent, c.Dropped}
+	}
+	want := map[string][3]uint64{
+		"sensors/attic":  {2, 1, 0},
+		"sensors/cellar": {1, 0, 0},
+		"msh/+/#":        {2, 0, 1},
+		otherTopics:      {3, 0, 0}, // sensors/garage twice, misc
+	}
+	if len(got) != len(want) {
+		t.Fatalf("counts = %v, want %v", got, want)
+	}
+	for topic, w := range want {
+		if got[topic] != w {
+			t.Errorf("%s = %v, want %v", topic, got[topic], w)
+		}
+	}
+
+	var sb strings.Builder
+	if err := s.writeMetrics(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if line := `mqtt2irc_topic_messages_total{topic="msh/+/#",outcome="dropped"} 1`; !strings.Contains(sb.String(), line) {
+		t.Errorf("metrics lack %s:\n%s", line, sb.String())
+	}
+
+	var off *topicStats
+	if !off.received("x", now, match) || off.snapshot() != nil {
+		t.Error("disabled topic stats must track everything and report nothing")
+	}
+}
@@ -0,0 +1,207 @@
+package bridge
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// maxTraceDuration bounds a trace so a forgotten one cannot flood its owner.
+const maxTraceDuration = time.Hour
+
+// traceLineBuffer is how many trace lines may wait for a slow sink before
+// further lines of that trace are dropped.
+const traceLineBuffer = 64
+
+// traceRule sends a step-by-step account of each message on matching topics
+// to its owner until it expires. Lines go through a buffer drained by the
+// rule's own goroutine, so a slow sink (an IRC send waiting for the rate
+// limiter) never holds up message processing.
+type traceRule struct {
+	pattern string
+	owner   string
+	until   time.Time
+	timer   schedule.Timer
+
+	mu     sync.Mutex
+	lines  chan string
+	closed bool
+}
+
+// send queues line for the sink, dropping it when the buffer is full.
+func (r *traceRule) send(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.lines <- line:
+	default:
+	}
+}
+
+// end queues a last line (if any) and stops the rule's sender once the
+// buffered lines are delivered.
+func (r *traceRule) end(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	if line != "" {
+		select {
+		case r.lines <- line:
+		default:
+		}
+	}
+	r.closed = true
+	close(r.lines)
+}
+
+// tracer holds the active trace rules.
+type tracer struct {
+	mu    sync.Mutex
+	clock schedule.Clock
+	rules []*traceRule
+}
+
+func newTracer(clock schedule.Clock) *tracer {
+	return &tracer{clock: clock}
+}
+
+// add starts tracing pattern for d on behalf of owner; sink receives the
+// lines, ending with "[trace <pattern>] ended" when d has passed.
+func (t *tracer) add(pattern, owner string, d time.Duration, sink func(string)) (time.Time, error) {
+	if d <= 0 || d > maxTraceDuration {
+		return time.Time{}, fmt.Errorf("duration must be between 0 and %s", maxTraceDuration)
+	}
+	if !IsValidPattern(pattern) {
+		return time.Time{}, fmt.Errorf("invalid topic pattern %q", pattern)
+	}
+	rule := &traceRule{pattern: pattern, owner: owner, until: t.clock.Now().Add(d), lines: make(chan string, traceLineBuffer)}
+	go func() {
+		for line := range rule.lines {
+			sink(line)
+		}
+	}()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = append(t.rules, rule)
+	rule.timer = t.clock.AfterFunc(d, func() { t.expire(rule) })
+	return rule.until, nil
+}
+
+// expire removes rule when its duration has passed and announces the end.
+func (t *tracer) expire(rule *traceRule) {
+	t.mu.Lock()
+	for i, r := range t.rules {
+		if r == rule {
+			t.rules = append(t.rules[:i], t.rules[i+1:]...)
+			break
+		}
+	}
+	t.mu.Unlock()
+	rule.end(fmt.Sprintf("[trace %s] ended", rule.pattern))
+}
+
+// stop removes all traces of owner and returns how many there were.
+func (t *tracer) stop(owner string) int {
+	t.mu.Lock()
+	var stopped []*traceRule
+	kept := t.rules[:0]
+	for _, r := range t.rules {
+		if r.owner == owner {
+			stopped = append(stopped, r)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	t.rules = kept
+	t.mu.Unlock()
+
+	for _, r := range stopped {
+		r.timer.Stop()
+		r.end("")
+	}
+	return len(stopped)
+}
+
+// start returns a trace for a message on topic, or nil when no active rule
+// matches. A nil tracer traces nothing.
+func (t *tracer) start(topic string, match func(topic, pattern string) bool, logger zerolog.Logger, redact func(string) string) *trace {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var rules []*traceRule
+	for _, r := range t.rules {
+		if match(topic, r.pattern) {
+			rules = append(rules, r)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return &trace{topic: topic, rules: rules, logger: logger, redact: redact}
+}
+
+// trace collects the steps of one message. All methods are no-ops on nil.
+type trace struct {
+	topic  string
+	rules  []*traceRule
+	logger zerolog.Logger
+	redact func(string) string
+}
+
+// step records one step: it is logged and sent to every tracing operator.
+func (tr *trace) step(format string, args ...interface{}) {
+	if tr == nil {
+		return
+	}
+	text := fmt.Sprintf(format, args...)
+	if tr.redact != nil {
+		text = tr.redact(text)
+	}
+	tr.logger.Info().Str("topic", tr.topic).Str("step", text).Msg("trace")
+	line := fmt.Sprintf("[trace %s] %s", tr.topic, text)
+	for _, r := range tr.rules {
+		r.send(line)
+	}
+}
+
+// Trace sends a step-by-step trace of every message on topics matching
+// pattern to sink for d (implements admin.BridgeAdmin).
+func (b *Bridge) Trace(pattern, owner string, d time.Duration, sink func(line string)) (time.Time, error) {
+	until, err := b.tracer.add(pattern, owner, d, sink)
+	if err != nil {
+		return time.Time{}, err
+	}
+	b.logger.Info().Str("pattern", pattern).Str("owner", owner).Time("until", until).Msg("trace started")
+	return until, nil
+}
+
+// StopTrace ends all traces of owner (implements admin.BridgeAdmin).
+func (b *Bridge) StopTrace(owner string) int {
+	return b.tracer.stop(owner)
+}
+
+// tracePayload shortens a payload for a trace line.
+func tracePayload(payload []byte) string {
+	const max = 200
+	if !utf8.Valid(payload) {
+		return fmt.Sprintf("[binary data, %d bytes]", len(payload))
+	}
+	s := strings.Join(strings.Fields(string(payload)), " ")
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+func TestTracer(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC))
+	tc := newTracer(clock)
+	m := NewMapper(nil)
+	lines := make(chan string, 10)
+	sink := func(line string) { lines <- line }
+	next := func() string {
+		t.Helper()
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("no trace line delivered")
+			return ""
+		}
+	}
+
+	if _, err := tc.add("sensors/#", "alice", 2*time.Hour, sink); err == nil {
+		t.Error("trace longer than the maximum accepted")
+	}
+	if _, err := tc.add("sensors/#", "alice", 5*time.Minute, sink); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr := tc.start("alerts/x", m.matchTopic, zerolog.Nop(), nil); tr != nil {
+		t.Error("trace started for a topic not being traced")
+	}
+
+	tr := tc.start("sensors/temp", m.matchTopic, zerolog.Nop(), func(s string) string {
+		return strings.ReplaceAll(s, "secret", "***")
+	})
+	tr.step("rendered: %s", "pw=secret")
+	if got := next(); got != "[trace sensors/temp] rendered: pw=***" {
+		t.Errorf("line = %q", got)
+	}
+
+	// An expiring trace announces its end right away, without waiting for
+	// another message, and no longer matches.
+	clock.Advance(5 * time.Minute)
+	if got := next(); got != "[trace sensors/#] ended" {
+		t.Errorf("line after expiry = %q", got)
+	}
+	if tr := tc.start("sensors/temp", m.matchTopic, zerolog.Nop(), nil); tr != nil {
+		t.Error("expired trace still active")
+	}
+	// Steps of a trace started before the end are dropped.
+	tr.step("late")
+
+	if _, err := tc.add("a/#", "bob", time.Minute, sink); err != nil {
+		t.Fatal(err)
+	}
+	if n := tc.stop("bob"); n != 1 {
+		t.Errorf("stop(bob) = %d, want 1", n)
+	}
+	if tr := tc.start("a/b", m.matchTopic, zerolog.Nop(), nil); tr != nil {
+		t.Error("stopped trace still active")
+	}
+	clock.Advance(time.Minute)
+	select {
+	case line := <-lines:
+		t.Errorf("unexpected line %q after stop", line)
+	default:
+	}
+
+	// Steps on a nil trace are no-ops.
+	var none *trace
+	none.step("ignored")
+}
+
+// TestTracer_SlowSink checks that a blocked sink does not hold up the
+// message being traced.
+func TestTracer_SlowSink(t *testing.T) {
+	tc := newTracer(schedule.NewFake(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)))
+	release := make(chan struct{})
+	defer close(release)
+	if _, err := tc.add("#", "alice", time.Minute, func(string) { <-release }); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		tr := tc.start("a/b", NewMapper(nil).matchTopic, zerolog.Nop(), nil)
+		for i := 0; i < 2*traceLineBuffer; i++ {
+			tr.step("step %d", i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tracing blocked on a slow sink")
+	}
+}
+
+func TestTracePayload(t *testing.T) {
+	if got := tracePayload([]byte("{\n  \"a\": 1\n}")); got != `{ "a": 1 }` {
+		t.Errorf("tracePayload() = %q", got)
+	}
+	if got := tracePayload([]byte{0xff, 0xfe}); got != "[binary data, 2 bytes]" {
+		t.Errorf("tracePayload(binary) = %q", got)
+	}
+	if got := tracePayload([]byte(strings.Repeat("é", 300))); len([]rune(got)) != 203 {
+		t.Errorf("tracePayload(long) has %d runes, want 203", len([]rune(got)))
+	}
+}
@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"fmt"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/transform"
+)
+
+// loadTransforms builds the output transform chain of every mapping that has
+// one, by mappingKey: its own transforms, then those of its trust level in
+// bcfg.
+func loadTransforms(mappings []config.MappingConfig, bcfg config.BridgeConfig, limits irc.Limits) (map[string]transform.Func, error) {
+	transforms := make(map[string]transform.Func)
+	keys := mappingKeys(mappings)
+	for i, m := range mappings {
+		level, ok := bcfg.TrustLevel(m.Trust)
+		if !ok {
+			return nil, fmt.Errorf("unknown trust level %q for mapping %q", m.Trust, m.MQTTTopic)
+		}
+		fn, err := transform.Chain(append(m.OutputTransforms(), level.Transforms()...), limits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transforms for mapping %q: %w", m.MQTTTopic, err)
+		}
+		if fn != nil {
+			transforms[keys[i]] = fn
+		}
+	}
+	return transforms, nil
+}
+
+// transformLines applies the mapping's output transforms to formatted lines
+// for channel, breaks the nicks of channel members in them if the mapping's
+// trust level says so, and fits them to the message limits again
+// (sanitized), as either may lengthen a line (shortcodes, prefix).
+func (b *Bridge) transformLines(mapping Mapped, channel string, lines []string) []string {
+	fn := b.transforms[mapping.Key]
+	level, _ := b.config.TrustLevel(mapping.Trust)
+	if fn == nil && !level.MangleHighlights {
+		return lines
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if fn != nil {
+			line = fn(line)
+		}
+		if level.MangleHighlights {
+			line = b.ircClient.Unhighlight(channel, line)
+		}
+		out[i] = b.limits.Fit(line)
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+)
+
+func TestTransformLines(t *testing.T) {
+	limits := irc.Limits{MaxLength: 20, Suffix: "…"}
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "plain"},
+		{MQTTTopic: "codes", Emoji: "shortcodes"},
+		{MQTTTopic: "unicode", Emoji: "unicode"},
+		{MQTTTopic: "chain", Transforms: []string{"strip_colors", "prefix=[mesh]"}, Emoji: "shortcodes"},
+	}
+	transforms, err := loadTransforms(mappings, config.BridgeConfig{}, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Bridge{limits: limits, transforms: transforms}
+	keys := mappingKeys(mappings)
+	mapped := func(i int) Mapped { return Mapped{MappingConfig: mappings[i], Key: keys[i]} }
+	lines := []string{"🔋 87%", "📡 -70dB 🔋 low battery"}
+
+	if got := b.transformLines(mapped(0), "#ch", lines); got[0] != lines[0] {
+		t.Errorf("no transform: %q", got)
+	}
+
+	got := b.transformLines(mapped(1), "#ch", lines)
+	if got[0] != ":battery: 87%" {
+		t.Errorf("line 0 = %q", got[0])
+	}
+	if got[1] != ":satellite: -70dB :…" { // refitted to max_message_length
+		t.Errorf("line 1 = %q", got[1])
+	}
+
+	if got := b.transformLines(mapped(2), "#ch", []string{":battery: 87%"}); got[0] != "🔋 87%" {
+		t.Errorf("unicode: %q", got)
+	}
+
+	if got := b.transformLines(mapped(3), "#ch", []string{"\x02🔋\x02 87%"}); got[0] != "[mesh] :battery: 87%" {
+		t.Errorf("chain: %q", got)
+	}
+}
+
+func TestLoadTransforms_Unknown(t *testing.T) {
+	mappings := []config.MappingConfig{{MQTTTopic: "a", Transforms: []string{"nope"}}}
+	if _, err := loadTransforms(mappings, config.BridgeConfig{}, irc.Limits{}); err == nil {
+		t.Error("unknown transform accepted")
+	}
+}
+
+func TestLoadTransforms_Trust(t *testing.T) {
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "internal"},
+		{MQTTTopic: "public", Trust: config.TrustUntrusted, Transforms: []string{"prefix=[mesh]"}},
+		{MQTTTopic: "short", Trust: "short"},
+		{MQTTTopic: "marked", Trust: "marked"},
+	}
+	bcfg := config.BridgeConfig{TrustLevels: map[string]config.TrustLevelConfig{
+		"short":  {MaxLength: 10},
+		"marked": {URLs: "mark"},
+	}}
+	transforms, err := loadTransforms(mappings, bcfg, irc.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := mappingKeys(mappings)
+	if transforms[keys[0]] != nil {
+		t.Error("trusted mapping got transforms")
+	}
+	line := "\x02alert\x02 see https://example.com/x now"
+	if got := transforms[keys[1]](line); got != "[mesh] alert see now" {
+		t.Errorf("untrusted: %q", got)
+	}
+	if got := transforms[keys[2]](line); got != "\x02alert\x02 se" {
+		t.Errorf("custom level: %q", got)
+	}
+	if got := transforms[keys[3]](line); got != "\x02alert\x02 see [link removed] now" {
+		t.Errorf("marked: %q", got)
+	}
+
+	mappings = append(mappings, config.MappingConfig{MQTTTopic: "x", Trust: "nope"})
+	if _, err := loadTransforms(mappings, bcfg, irc.Limits{}); err == nil {
+		t.Error("unknown trust level accepted")
+	}
+}
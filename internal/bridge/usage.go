@@ -0,0 +1,191 @@
+package bridge
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// usageKey identifies a (tenant, channel) pair; tenant is "" for mappings
+// without an owner.
+type usageKey struct {
+	tenant  string
+	channel string
+}
+
+// usageTracker counts delivered messages per tenant and channel and enforces
+// the tenants' daily message quotas.
+type usageTracker struct {
+	mu     sync.Mutex
+	clock  schedule.Clock
+	loc    *time.Location
+	limits map[string]int // tenant → messages per day (0 = unlimited)
+
+	day      string         // current calendar day in loc (2006-01-02)
+	today    map[string]int // tenant → mapped messages accepted today
+	notified map[string]bool
+
+	sent    map[usageKey]uint64 // lines sent to IRC
+	dropped map[string]uint64   // tenant → messages dropped by the daily quota
+}
+
+func newUsageTracker(tenants []config.TenantConfig, loc *time.Location, clock schedule.Clock) *usageTracker {
+	limits := make(map[string]int, len(tenants))
+	for _, t := range tenants {
+		limits[t.Name] = t.Quota.MessagesPerDay
+	}
+	return &usageTracker{
+		clock:    clock,
+		loc:      loc,
+		limits:   limits,
+		today:    make(map[string]int),
+		notified: make(map[string]bool),
+		sent:     make(map[usageKey]uint64),
+		dropped:  make(map[string]uint64),
+	}
+}
+
+// rollover resets the daily counters when the calendar day changes.
+// Must be called with mu held.
+func (u *usageTracker) rollover() {
+	day := u.clock.Now().In(u.loc).Format("2006-01-02")
+	if day == u.day {
+		return
+	}
+	u.day = day
+	u.today = make(map[string]int)
+	u.notified = make(map[string]bool)
+}
+
+// allow counts one mapped message for tenant against its daily quota. It
+// reports whether the message may be delivered and, for the first rejected
+// message of the day, first=true so the caller can notify operators once.
+func (u *usageTracker) allow(tenant string) (ok, first bool) {
+	if tenant == "" {
+		return true, false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover()
+
+	if limit := u.limits[tenant]; limit > 0 && u.today[tenant] >= limit {
+		u.dropped[tenant]++
+		first = !u.notified[tenant]
+		u.notified[tenant] = true
+		return false, first
+	}
+	u.today[tenant]++
+	return true, false
+}
+
+// recordSent counts a line delivered to channel on behalf of tenant.
+func (u *usageTracker) recordSent(tenant, channel string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sent[usageKey{tenant: tenant, channel: strings.ToLower(channel)}]++
+}
+
+// todayCounts returns the messages accepted today per tenant.
+func (u *usageTracker) todayCounts() map[string]int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover()
+	counts := make(map[string]int, len(u.limits))
+	for tenant := range u.limits {
+		counts[tenant] = u.today[tenant]
+	}
+	return counts
+}
+
+// channelCounts returns the lines sent to IRC per lower-cased channel, over
+// all tenants.
+func (u *usageTracker) channelCounts() map[string]uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	counts := make(map[string]uint64)
+	for k, n := range u.sent {
+		counts[k.channel] += n
+	}
+	return counts
+}
+
+// writeMetrics writes the counters in the Prometheus text exposition format.
+func (u *usageTracker) writeMetrics(w io.Writer) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover()
+
+	var sb strings.Builder
+
+	sb.WriteString("# HELP mqtt2irc_messages_sent_total Messages sent to IRC.\n")
+	sb.WriteString("# TYPE mqtt2irc_messages_sent_total counter\n")
+	keys := make([]usageKey, 0, len(u.sent))
+	for k := range u.sent {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tenant != keys[j].tenant {
+			return keys[i].tenant < keys[j].tenant
+		}
+		return keys[i].channel < keys[j].channel
+	})
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "mqtt2irc_messages_sent_total{tenant=%s,channel=%s} %d\n",
+			promLabel(k.tenant), promLabel(k.channel), u.sent[k])
+	}
+
+	tenants := make([]string, 0, len(u.limits))
+	for t := range u.limits {
+		tenants = append(tenants, t)
+	}
+	sort.Strings(tenants)
+
+	sb.WriteString("# HELP mqtt2irc_tenant_messages_today Mapped messages accepted today per tenant.\n")
+	sb.WriteString("# TYPE mqtt2irc_tenant_messages_today gauge\n")
+	for _, t := range tenants {
+		fmt.Fprintf(&sb, "mqtt2irc_tenant_messages_today{tenant=%s} %d\n", promLabel(t), u.today[t])
+	}
+
+	sb.WriteString("# HELP mqtt2irc_tenant_quota_messages_per_day Daily message quota per tenant (0 = unlimited).\n")
+	sb.WriteString("# TYPE mqtt2irc_tenant_quota_messages_per_day gauge\n")
+	for _, t := range tenants {
+		fmt.Fprintf(&sb, "mqtt2irc_tenant_quota_messages_per_day{tenant=%s} %d\n", promLabel(t), u.limits[t])
+	}
+
+	sb.WriteString("# HELP mqtt2irc_tenant_quota_dropped_total Messages dropped because the tenant exceeded its daily quota.\n")
+	sb.WriteString("# TYPE mqtt2irc_tenant_quota_dropped_total counter\n")
+	for _, t := range tenants {
+		fmt.Fprintf(&sb, "mqtt2irc_tenant_quota_dropped_total{tenant=%s} %d\n", promLabel(t), u.dropped[t])
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// addMetricsLabel adds label (name="value") to every sample in text, in the
+// Prometheus text format.
+func addMetricsLabel(text, label string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.Contains(line, "{"):
+			lines[i] = strings.Replace(line, "{", "{"+label+",", 1)
+		default:
+			lines[i] = strings.Replace(line, " ", "{"+label+"} ", 1)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// promLabel quotes a Prometheus label value.
+func promLabel(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
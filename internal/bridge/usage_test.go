@@ -0,0 +1,93 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+func TestUsageTracker_Quota(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	clock := schedule.NewFake(time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC)) // 23:00 local
+	u := newUsageTracker([]config.TenantConfig{
+		{Name: "hs", Quota: config.TenantQuota{MessagesPerDay: 2}},
+		{Name: "club"},
+	}, loc, clock)
+
+	steps := []struct {
+		tenant    string
+		wantOK    bool
+		wantFirst bool
+	}{
+		{"hs", true, false},
+		{"hs", true, false},
+		{"hs", false, true},
+		{"hs", false, false},
+		{"club", true, false},
+		{"", true, false},
+	}
+	for i, s := range steps {
+		ok, first := u.allow(s.tenant)
+		if ok != s.wantOK || first != s.wantFirst {
+			t.Errorf("step %d allow(%q) = %v, %v; want %v, %v", i, s.tenant, ok, first, s.wantOK, s.wantFirst)
+		}
+	}
+
+	// Local midnight resets the daily counts.
+	clock.Advance(time.Hour)
+	if ok, _ := u.allow("hs"); !ok {
+		t.Error("allow after midnight = false, want true")
+	}
+	if got := u.todayCounts(); got["hs"] != 1 || got["club"] != 0 {
+		t.Errorf("todayCounts() = %v", got)
+	}
+}
+
+func TestUsageTracker_Metrics(t *testing.T) {
+	u := newUsageTracker([]config.TenantConfig{
+		{Name: "hs", Quota: config.TenantQuota{MessagesPerDay: 1}},
+	}, time.UTC, schedule.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)))
+
+	u.allow("hs")
+	u.allow("hs")
+	u.recordSent("hs", "#HS")
+	u.recordSent("", "#ops")
+	u.recordSent("hs", "#ops")
+	if got := u.channelCounts(); got["#hs"] != 1 || got["#ops"] != 2 {
+		t.Errorf("channelCounts() = %v", got)
+	}
+
+	var sb strings.Builder
+	if err := u.writeMetrics(&sb); err != nil {
+		t.Fatalf("writeMetrics: %v", err)
+	}
+	out := sb.String()
+	for _, want := range []string{
+		`mqtt2irc_messages_sent_total{tenant="",channel="#ops"} 1`,
+		`mqtt2irc_messages_sent_total{tenant="hs",channel="#hs"} 1`,
+		`mqtt2irc_tenant_messages_today{tenant="hs"} 1`,
+		`mqtt2irc_tenant_quota_messages_per_day{tenant="hs"} 1`,
+		`mqtt2irc_tenant_quota_dropped_total{tenant="hs"} 1`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPromLabel(t *testing.T) {
+	if got, want := promLabel("a\"b\\c\n"), `"a\"b\\c\n"`; got != want {
+		t.Errorf("promLabel() = %s, want %s", got, want)
+	}
+}
+
+func TestAddMetricsLabel(t *testing.T) {
+	in := "# HELP x Things.\n# TYPE x counter\nx{a=\"1\"} 2\nup 1\n"
+	want := "# HELP x Things.\n# TYPE x counter\nx{instance_name=\"eu\",a=\"1\"} 2\nup{instance_name=\"eu\"} 1\n"
+	if got := addMetricsLabel(in, `instance_name="eu"`); got != want {
+		t.Errorf("addMetricsLabel() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+package bridge
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/expr"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+const (
+	defaultWatchDuration = time.Hour
+	maxWatchDuration     = 24 * time.Hour
+	maxWatches           = 50 // active watches, all owners together
+)
+
+// watchRule notifies its target when a payload field on matching topics
+// meets a condition, until it expires.
+type watchRule struct {
+	types.Watch
+	cond     *expr.Cond
+	timer    schedule.Timer
+	sink     func(line string)
+	matching map[string]bool // topics whose last message met the condition
+}
+
+// watcher holds the active !watch rules.
+type watcher struct {
+	mu    sync.Mutex
+	clock schedule.Clock
+	next  int
+	rules []*watchRule
+}
+
+func newWatcher(clock schedule.Clock) *watcher {
+	return &watcher{clock: clock}
+}
+
+// add watches pattern for d (defaultWatchDuration if 0); sink receives the
+// notifications, ending with "[watch N] ended" when d has passed.
+func (w *watcher) add(pattern string, cond *expr.Cond, owner, target string, d time.Duration, sink func(string)) (types.Watch, error) {
+	if d == 0 {
+		d = defaultWatchDuration
+	}
+	if d < 0 || d > maxWatchDuration {
+		return types.Watch{}, fmt.Errorf("duration must be between 0 and %s", maxWatchDuration)
+	}
+	if !IsValidPattern(pattern) {
+		return types.Watch{}, fmt.Errorf("invalid topic pattern %q", pattern)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.rules) >= maxWatches {
+		return types.Watch{}, fmt.Errorf("too many active watches (%d)", maxWatches)
+	}
+	w.next++
+	rule := &watchRule{
+		Watch:    types.Watch{ID: w.next, Owner: owner, Target: target, Pattern: pattern, Cond: cond.String(), Until: w.clock.Now().Add(d)},
+		cond:     cond,
+		sink:     sink,
+		matching: make(map[string]bool),
+	}
+	w.rules = append(w.rules, rule)
+	rule.timer = w.clock.AfterFunc(d, func() { w.expire(rule) })
+	return rule.Watch, nil
+}
+
+// expire removes rule when its duration has passed and announces the end.
+func (w *watcher) expire(rule *watchRule) {
+	if w.remove(func(r *watchRule) bool { return r == rule }) > 0 {
+		go rule.sink(fmt.Sprintf("[watch %d] %s on %s ended", rule.ID, rule.Cond, rule.Pattern))
+	}
+}
+
+// stop removes the watches of owner, or only the one with id if id > 0,
+// and returns how many there were.
+func (w *watcher) stop(owner string, id int) int {
+	return w.remove(func(r *watchRule) bool {
+		return r.Owner == owner && (id == 0 || r.ID == id)
+	})
+}
+
+func (w *watcher) remove(match func(*watchRule) bool) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	kept := w.rules[:0]
+	for _, r := range w.rules {
+		if match(r) {
+			r.timer.Stop()
+			n++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	w.rules = kept
+	return n
+}
+
+// list returns the active watches, oldest first.
+func (w *watcher) list() []types.Watch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]types.Watch, len(w.rules))
+	for i, r := range w.rules {
+		out[i] = r.Watch
+	}
+	return out
+}
+
+// check evaluates the watches matching msg's topic and notifies when a
+// condition starts to hold on a topic: on the first message meeting it, and
+// again only after a message that does not. A nil watcher checks nothing.
+func (w *watcher) check(msg types.Message, match func(topic, pattern string) bool) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var doc map[string]interface{}
+	parsed := false
+	for _, r := range w.rules {
+		if !match(msg.Topic, r.Pattern) {
+			continue
+		}
+		if !parsed {
+			doc, parsed = irc.JSONObject(msg), true
+		}
+		value, ok := "", false
+		if doc != nil {
+			value, ok = r.cond.Eval(doc)
+		}
+		if !ok {
+			delete(r.matching, msg.Topic)
+			continue
+		}
+		if r.matching[msg.Topic] {
+			continue
+		}
+		r.matching[msg.Topic] = true
+		line := fmt.Sprintf("[watch %d] %s: %s=%s (%s)", r.ID, msg.Topic, r.cond.Field(), value, r.Cond)
+		// Through the rate limiter, so not from the message processor.
+		go r.sink(line)
+	}
+}
+
+// Watch notifies sink when the field condition "field op value" starts to
+// hold on topics matching pattern, for d (implements admin.BridgeAdmin).
+func (b *Bridge) Watch(pattern, field, op, value, owner, target string, d time.Duration, sink func(line string)) (types.Watch, error) {
+	cond, err := expr.Compile(field, op, value)
+	if err != nil {
+		return types.Watch{}, err
+	}
+	watch, err := b.watcher.add(pattern, cond, owner, target, d, sink)
+	if err != nil {
+		return types.Watch{}, err
+	}
+	b.logger.Info().Int("watch", watch.ID).Str("pattern", pattern).Str("cond", watch.Cond).
+		Str("owner", owner).Time("until", watch.Until).Msg("watch started")
+	return watch, nil
+}
+
+// StopWatch ends owner's watches, or only the one with id if id > 0
+// (implements admin.BridgeAdmin).
+func (b *Bridge) StopWatch(owner string, id int) int {
+	return b.watcher.stop(owner, id)
+}
+
+// Watches returns the active watches (implements admin.BridgeAdmin).
+func (b *Bridge) Watches() []types.Watch {
+	return b.watcher.list()
+}
@@ -0,0 +1,87 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/expr"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestWatcher(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC))
+	w := newWatcher(clock)
+	m := NewMapper(nil)
+	lines := make(chan string, 10)
+	sink := func(line string) { lines <- line }
+	next := func() string {
+		t.Helper()
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("no watch line delivered")
+			return ""
+		}
+	}
+	none := func() {
+		t.Helper()
+		select {
+		case line := <-lines:
+			t.Errorf("unexpected line %q", line)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	publish := func(topic, payload string) {
+		w.check(types.Message{Topic: topic, Payload: []byte(payload)}, m.matchTopic)
+	}
+
+	cond, err := expr.Compile("temperature", ">", "30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.add("sensors/#", cond, "alice", "#ops", 48*time.Hour, sink); err == nil {
+		t.Error("watch longer than the maximum accepted")
+	}
+	watch, err := w.add("sensors/#", cond, "alice", "#ops", 0, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !watch.Until.Equal(clock.Now().Add(defaultWatchDuration)) || watch.Cond != "temperature > 30" {
+		t.Errorf("watch = %+v", watch)
+	}
+
+	publish("sensors/kitchen", `{"temperature": 21}`)
+	publish("other/kitchen", `{"temperature": 35}`)
+	none()
+	publish("sensors/kitchen", `{"temperature": 31.5}`)
+	if got := next(); got != "[watch 1] sensors/kitchen: temperature=31.5 (temperature > 30)" {
+		t.Errorf("line = %q", got)
+	}
+	// Only when the condition starts to hold again.
+	publish("sensors/kitchen", `{"temperature": 32}`)
+	none()
+	publish("sensors/kitchen", `{"temperature": 29}`)
+	publish("sensors/kitchen", `{"temperature": 33}`)
+	if got := next(); got != "[watch 1] sensors/kitchen: temperature=33 (temperature > 30)" {
+		t.Errorf("line after recovery = %q", got)
+	}
+
+	if n := w.stop("bob", 0); n != 0 {
+		t.Errorf("bob stopped %d of alice's watches", n)
+	}
+	clock.Advance(defaultWatchDuration)
+	if got := next(); got != "[watch 1] temperature > 30 on sensors/# ended" {
+		t.Errorf("line after expiry = %q", got)
+	}
+	if len(w.list()) != 0 {
+		t.Errorf("expired watch still listed: %v", w.list())
+	}
+
+	w.add("sensors/#", cond, "alice", "#ops", time.Minute, sink)
+	w.add("sensors/#", cond, "alice", "alice", time.Minute, sink)
+	if n := w.stop("alice", 3); n != 1 || len(w.list()) != 1 || w.list()[0].ID != 2 {
+		t.Errorf("stop(3) = %d, left %v", n, w.list())
+	}
+}
@@ -0,0 +1,26 @@
+package bridge
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// loadZones resolves the zones {{.Time}} is rendered in: global under "",
+// and each mapping's timezone under its name.
+func loadZones(mappings []config.MappingConfig, global *time.Location) (map[string]*time.Location, error) {
+	zones := map[string]*time.Location{"": global}
+	for _, m := range mappings {
+		if _, ok := zones[m.Timezone]; ok {
+			continue
+		}
+		loc, err := schedule.LoadLocation(m.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone for mapping %q: %w", m.MQTTTopic, err)
+		}
+		zones[m.Timezone] = loc
+	}
+	return zones, nil
+}
@@ -0,0 +1,34 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestFormat_MappingTimezone(t *testing.T) {
+	mappings := []config.MappingConfig{
+		{MQTTTopic: "alerts/#", MessageFormat: "{{.Time.Format \"15:04 MST\"}} {{.Payload}}"},
+		{MQTTTopic: "alerts/#", MessageFormat: "{{.Time.Format \"15:04 MST\"}} {{.Payload}}", Timezone: "America/New_York"},
+	}
+	zones, err := loadZones(mappings, time.UTC)
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	b := &Bridge{zones: zones, limits: irc.Limits{MaxLength: 400}}
+	msg := types.Message{Topic: "alerts/door", Payload: []byte("open"), Timestamp: time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC)}
+
+	if got := b.format(msg, mappings[0], irc.Target{}); len(got) != 1 || got[0] != "15:04 UTC open" {
+		t.Errorf("global zone: %q", got)
+	}
+	if got := b.format(msg, mappings[1], irc.Target{}); len(got) != 1 || got[0] != "10:04 EST open" {
+		t.Errorf("mapping zone: %q", got)
+	}
+
+	if _, err := loadZones([]config.MappingConfig{{MQTTTopic: "x", Timezone: "Mars/Olympus"}}, time.UTC); err == nil {
+		t.Error("loadZones with an unknown zone: want error")
+	}
+}
@@ -3,8 +3,12 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/dyuri/mqtt2irc/internal/persist"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
 )
 
 // Config represents the application configuration
@@ -15,15 +19,102 @@ type Config struct {
 	Logging LoggingConfig `mapstructure:"logging"`
 	Health  HealthConfig  `mapstructure:"health"`
 	Admin   AdminConfig   `mapstructure:"admin"`
+	Startup StartupConfig `mapstructure:"startup"`
+
+	// Tenants group mappings and admin operators by community (optional)
+	Tenants []TenantConfig `mapstructure:"tenants"`
+
+	// Timezone is the IANA zone (e.g. "Europe/Budapest") used by scheduled
+	// features such as quiet hours and digests; empty means the system zone
+	Timezone string `mapstructure:"timezone"`
+
+	// InstanceName tells bridges apart that share channels or monitoring: it
+	// appears in logs, metrics, admin replies, status documents and the
+	// banner, and addresses admin commands ("!status @mesh-eu")
+	InstanceName string `mapstructure:"instance_name"`
+
+	// Source records where each value came from (set by Load)
+	Source *Source `mapstructure:"-"`
+
+	// SafeMode is set by the --safe-mode flag: the bridge sends nothing to
+	// IRC or MQTT and rejects admin commands that change state
+	SafeMode bool `mapstructure:"-"`
+}
+
+// Location returns the configured timezone (validated by Validate).
+func (c *Config) Location() *time.Location {
+	loc, err := schedule.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
 }
 
 // AdminConfig contains IRC admin command system configuration
 type AdminConfig struct {
-	Enabled       bool             `mapstructure:"enabled"`
-	CommandPrefix string           `mapstructure:"command_prefix"`
+	Enabled       bool              `mapstructure:"enabled"`
+	CommandPrefix string            `mapstructure:"command_prefix"`
 	AllowList     []AdminAllowEntry `mapstructure:"allow_list"`
-	Channels      []string         `mapstructure:"channels"`
-	AcceptPM      bool             `mapstructure:"accept_pm"`
+	Channels      []string          `mapstructure:"channels"`
+	AcceptPM      bool              `mapstructure:"accept_pm"`
+	ReplyMode     string            `mapstructure:"reply_mode"`   // channel, notice or pm
+	Language      string            `mapstructure:"language"`     // reply language: en or de
+	FlowTimeout   time.Duration     `mapstructure:"flow_timeout"` // interactive commands (e.g. !mapping add) wait this long for answers
+	Auth          AdminAuthConfig   `mapstructure:"auth"`
+	// PublicCommands are read-only commands (e.g. bridge) answered for
+	// anyone in a mapped or admin channel, without authorization
+	PublicCommands []string             `mapstructure:"public_commands"`
+	Templates      AdminTemplatesConfig `mapstructure:"templates"`
+	// RequireAddress ignores channel commands that are not addressed to this
+	// bridge by nick ("mqttbot: status") or instance_name ("!status @mesh-eu")
+	RequireAddress bool `mapstructure:"require_address"`
+}
+
+// AdminTemplatesConfig customizes admin replies with Go templates, e.g. to
+// show the instance in multi-instance deployments
+type AdminTemplatesConfig struct {
+	Status string            `mapstructure:"status"` // the !status line
+	Help   string            `mapstructure:"help"`   // the !help layout, one reply per line
+	Fields map[string]string `mapstructure:"fields"` // operator fields, {{.Fields.<name>}}
+}
+
+// AdminAuthConfig selects how bridge admins are recognized
+type AdminAuthConfig struct {
+	// Providers are tried in order, the first to allow wins: allow_list
+	// (default), account, oper and webhook
+	Providers []string           `mapstructure:"providers"`
+	Accounts  []string           `mapstructure:"accounts"` // services (NickServ) accounts for the account provider
+	Webhook   AdminWebhookConfig `mapstructure:"webhook"`
+}
+
+// AdminWebhookConfig configures the webhook authorization provider
+type AdminWebhookConfig struct {
+	URL     string        `mapstructure:"url"`     // receives a POST per command attempt
+	Token   string        `mapstructure:"token"`   // sent as a bearer token (optional)
+	Timeout time.Duration `mapstructure:"timeout"` // default 5s
+}
+
+// AuthProviders returns the configured providers, or the allow list alone
+func (a AdminAuthConfig) AuthProviders() []string {
+	if len(a.Providers) == 0 {
+		return []string{"allow_list"}
+	}
+	return a.Providers
+}
+
+// TenantConfig binds a group of IRC channels to the operators allowed to manage
+// the mappings that post there
+type TenantConfig struct {
+	Name      string            `mapstructure:"name"`
+	Channels  []string          `mapstructure:"channels"`
+	Operators []AdminAllowEntry `mapstructure:"operators"`
+	Quota     TenantQuota       `mapstructure:"quota"`
+}
+
+// TenantQuota limits what a tenant may use of a shared bridge (0 = unlimited)
+type TenantQuota struct {
+	MessagesPerDay int `mapstructure:"messages_per_day"` // mapped messages per calendar day (see timezone)
+	MaxMappings    int `mapstructure:"max_mappings"`     // checked at startup
 }
 
 // AdminAllowEntry defines an authorized IRC user for admin commands
@@ -41,6 +132,41 @@ type MQTTConfig struct {
 	QoS      byte          `mapstructure:"qos"`
 	Topics   []TopicConfig `mapstructure:"topics"`
 	UseTLS   bool          `mapstructure:"use_tls"`
+	Probe    ProbeConfig   `mapstructure:"probe"`
+
+	// RedeliveryWindow suppresses QoS 1/2 messages redelivered with the DUP
+	// flag within this window of the original delivery (0 disables)
+	RedeliveryWindow time.Duration `mapstructure:"redelivery_window"`
+
+	// CleanSession starts every connection with a fresh broker session. The
+	// broker only redelivers in-flight QoS 1/2 messages into a persistent
+	// session, so it defaults to false when RedeliveryWindow is set
+	CleanSession *bool `mapstructure:"clean_session"`
+
+	StormBreaker StormBreakerConfig `mapstructure:"storm_breaker"`
+}
+
+// StormBreakerConfig suppresses a topic that receives more than MaxRate
+// messages in a second for Cooldown, e.g. a publisher stuck in a loop
+type StormBreakerConfig struct {
+	MaxRate  int           `mapstructure:"max_rate"` // messages per second on one topic; 0 = off
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// UseCleanSession resolves mqtt.clean_session: as configured, otherwise on
+// unless redelivery suppression is enabled.
+func (c MQTTConfig) UseCleanSession() bool {
+	if c.CleanSession != nil {
+		return *c.CleanSession
+	}
+	return c.RedeliveryWindow == 0
+}
+
+// ProbeConfig controls the MQTT loopback liveness probe
+type ProbeConfig struct {
+	Interval time.Duration `mapstructure:"interval"` // 0 disables the probe
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Topic    string        `mapstructure:"topic"` // default: mqtt2irc/probe/<client_id>
 }
 
 // TopicConfig represents an MQTT topic subscription
@@ -51,13 +177,44 @@ type TopicConfig struct {
 
 // IRCConfig contains IRC server configuration
 type IRCConfig struct {
-	Server           string         `mapstructure:"server"`
-	UseTLS           bool           `mapstructure:"use_tls"`
-	Nickname         string         `mapstructure:"nickname"`
-	Username         string         `mapstructure:"username"`
-	Realname         string         `mapstructure:"realname"`
-	NickServPassword string         `mapstructure:"nickserv_password"`
-	RateLimit        RateLimitConfig `mapstructure:"rate_limit"`
+	Server           string            `mapstructure:"server"`
+	Servers          []string          `mapstructure:"servers"`           // failover list; overrides server when set
+	FailbackInterval time.Duration     `mapstructure:"failback_interval"` // how often to retry the primary while on a fallback
+	SRVDomain        string            `mapstructure:"srv_domain"`        // resolve servers via _irc(s)._tcp.<domain> SRV records
+	UseTLS           bool              `mapstructure:"use_tls"`
+	Nickname         string            `mapstructure:"nickname"`
+	Username         string            `mapstructure:"username"`
+	Realname         string            `mapstructure:"realname"`
+	NickServPassword string            `mapstructure:"nickserv_password"`
+	RateLimit        RateLimitConfig   `mapstructure:"rate_limit"`
+	Keepalive        KeepaliveConfig   `mapstructure:"keepalive"`
+	PingDelay        time.Duration     `mapstructure:"ping_delay"`    // how often girc PINGs the server; 0 = girc's default
+	StallTimeout     time.Duration     `mapstructure:"stall_timeout"` // reconnect after this long without server traffic; 0 = off
+	NickReclaim      NickReclaimConfig `mapstructure:"nick_reclaim"`
+	Ident            IdentConfig       `mapstructure:"ident"`
+}
+
+// IdentConfig answers ident (RFC 1413) lookups from the IRC server, for
+// networks that delay or reject clients without ident
+type IdentConfig struct {
+	Mode        string `mapstructure:"mode"`         // "" (off), "server" (built-in identd) or "oidentd"
+	Listen      string `mapstructure:"listen"`       // built-in identd address
+	Username    string `mapstructure:"username"`     // reported user; default irc.username, then nickname
+	OidentdFile string `mapstructure:"oidentd_file"` // oidentd user config written in oidentd mode
+}
+
+// NickReclaimConfig controls taking the configured nick back after connecting
+// on a fallback nick (nick in use, or held by services)
+type NickReclaimConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Command  string        `mapstructure:"command"`  // NickServ command freeing the nick: regain, recover, ghost or none
+	Interval time.Duration `mapstructure:"interval"` // retry interval while on a fallback nick
+}
+
+// KeepaliveConfig controls the active IRC liveness check
+type KeepaliveConfig struct {
+	Interval time.Duration `mapstructure:"interval"` // 0 disables the check
+	Timeout  time.Duration `mapstructure:"timeout"`
 }
 
 // RateLimitConfig contains IRC rate limiting settings
@@ -68,10 +225,244 @@ type RateLimitConfig struct {
 
 // BridgeConfig contains bridge behavior configuration
 type BridgeConfig struct {
-	Mappings         []MappingConfig `mapstructure:"mappings"`
-	Queue            QueueConfig     `mapstructure:"queue"`
-	MaxMessageLength int             `mapstructure:"max_message_length"`
-	TruncateSuffix   string          `mapstructure:"truncate_suffix"`
+	Mappings         []MappingConfig    `mapstructure:"mappings"`
+	Queue            QueueConfig        `mapstructure:"queue"`
+	MaxMessageLength int                `mapstructure:"max_message_length"`
+	MaxMessageBytes  int                `mapstructure:"max_message_bytes"` // 0 = no byte limit
+	TruncateMode     string             `mapstructure:"truncate_mode"`     // "runes" or "width"
+	TruncateSuffix   string             `mapstructure:"truncate_suffix"`
+	CacheJSON        bool               `mapstructure:"cache_json"`         // parse JSON payloads once per message
+	ChannelOrder     string             `mapstructure:"channel_order"`      // config, round_robin or random
+	ChannelQueueSize int                `mapstructure:"channel_queue_size"` // per-channel outbound queue; 0 = send inline
+	OrderedDelivery  bool               `mapstructure:"ordered_delivery"`   // hold lines while IRC is down instead of sending them into the void
+	HistorySize      int                `mapstructure:"history_size"`       // lines kept per channel for !backfill; 0 = off
+	StateBundle      string             `mapstructure:"state_bundle"`       // file written by !state export
+	StatsPublish     StatsTopicConfig   `mapstructure:"stats_publish"`
+	HomeAssistant    HAConfig           `mapstructure:"home_assistant"`
+	OpsChannels      []string           `mapstructure:"ops_channels"`
+	FlapDetection    FlapConfig         `mapstructure:"flap_detection"`
+	ErrorBudget      ErrorBudgetConfig  `mapstructure:"error_budget"`
+	CircuitBreaker   BreakerConfig      `mapstructure:"circuit_breaker"`
+	RemovedMappings  RemovedConfig      `mapstructure:"removed_mappings"`
+	Faults           FaultConfig        `mapstructure:"faults"`
+	Heartbeats       []HeartbeatConfig  `mapstructure:"heartbeats"`
+	Banner           BannerConfig       `mapstructure:"banner"`
+	Redaction        RedactionConfig    `mapstructure:"redaction"`
+	ChannelRate      ChannelRateConfig  `mapstructure:"channel_rate"`
+	OverflowChannel  string             `mapstructure:"overflow_channel"` // receives messages over a channel's budget or a tenant's quota
+	TopicAliases     []TopicAlias       `mapstructure:"topic_aliases"`
+	Metadata         MetadataConfig     `mapstructure:"metadata"`
+	RemoteMappings   RemoteConfig       `mapstructure:"remote_mappings"`
+	DrainTimeout     time.Duration      `mapstructure:"drain_timeout"` // on SIGTERM, how long to send queued messages; 0 = stop at once
+	AckTopic         string             `mapstructure:"ack_topic"`     // !ack publishes acknowledgements here; empty = not published
+	Alertmanager     AlertmanagerConfig `mapstructure:"alertmanager"`
+
+	Storage StorageConfig `mapstructure:"storage"`
+
+	TopicStats TopicStatsConfig `mapstructure:"topic_stats"`
+
+	Latency LatencyConfig `mapstructure:"latency"`
+
+	SentHook SentHookConfig `mapstructure:"sent_hook"`
+
+	// TrustLevels define how defensively content of mappings with that
+	// trust is treated; "untrusted" may be redefined, "trusted" not
+	TrustLevels map[string]TrustLevelConfig `mapstructure:"trust_levels"`
+
+	// CapabilitiesTopic receives a retained JSON document with the version,
+	// enabled features and mapping count; empty = not published
+	CapabilitiesTopic string `mapstructure:"capabilities_topic"`
+}
+
+// TopicStatsConfig counts messages per topic for /metrics and !stats topics.
+// To bound memory and metric cardinality on broad subscriptions such as "#",
+// only topics matching Detail are tracked one by one, up to MaxTopics; the
+// others roll up into the first matching Rollup pattern, or "(other)"
+type TopicStatsConfig struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	Detail    []string `mapstructure:"detail"`     // MQTT patterns tracked per topic; empty = all topics
+	Rollup    []string `mapstructure:"rollup"`     // MQTT patterns the other topics are counted under
+	MaxTopics int      `mapstructure:"max_topics"` // topics tracked one by one; default DefaultMaxTopics
+}
+
+// LatencyConfig reports lines delivered late: from receipt on MQTT until the
+// line is handed to IRC, e.g. because of queueing behind the rate limiter
+type LatencyConfig struct {
+	Threshold time.Duration `mapstructure:"threshold"` // log lines later than this; 0 = off
+	Annotate  bool          `mapstructure:"annotate"`  // also append "(+2.3s)" to them
+}
+
+// SentHookConfig runs a command after each line sent to IRC, with the line
+// as JSON on stdin, e.g. to ring a bell or bump an external counter
+type SentHookConfig struct {
+	Command    []string      `mapstructure:"command"`     // program and arguments; empty = off
+	Timeout    time.Duration `mapstructure:"timeout"`     // the command is killed after this
+	MaxRunning int           `mapstructure:"max_running"` // hooks running at once; lines sent meanwhile skip the hook
+}
+
+// DefaultMaxTopics is the number of topics topic_stats tracks one by one
+// when max_topics is not set
+const DefaultMaxTopics = 200
+
+// StorageConfig selects how each state store is written, e.g. to spare the
+// SD card of a Raspberry Pi
+type StorageConfig struct {
+	NodeDB  StoreConfig `mapstructure:"node_db"` // meshtastic node_db files; their paths are set in processor_config
+	History StoreConfig `mapstructure:"history"` // !backfill history, kept across restarts when file is set
+	Queue   StoreConfig `mapstructure:"queue"`   // unsent messages, kept across restarts when file is set
+}
+
+// StoreConfig configures one state store
+type StoreConfig struct {
+	File             string        `mapstructure:"file"`              // state file (not for node_db)
+	Mode             string        `mapstructure:"mode"`              // file (write every change, default) or memory (periodic snapshots)
+	SnapshotInterval time.Duration `mapstructure:"snapshot_interval"` // memory mode; default 5m
+	Fsync            bool          `mapstructure:"fsync"`             // flush each write to the device
+	Backup           bool          `mapstructure:"backup"`            // keep the previous file as <file>.bak to recover from
+}
+
+// Policy returns the store's write policy
+func (s StoreConfig) Policy() persist.Policy {
+	return persist.Policy{Mode: s.Mode, Interval: s.SnapshotInterval, Fsync: s.Fsync, Backup: s.Backup}
+}
+
+// RemoteConfig fetches the mappings from an HTTP URL or a retained MQTT
+// topic instead of the config file, so a fleet of bridges can be managed
+// centrally. The document is YAML or JSON with a top-level "mappings" list in
+// the format of bridge.mappings. At most one of URL and MQTTTopic is set.
+type RemoteConfig struct {
+	URL       string        `mapstructure:"url"`
+	MQTTTopic string        `mapstructure:"mqtt_topic"`
+	Interval  time.Duration `mapstructure:"interval"`   // URL refresh interval
+	AutoApply bool          `mapstructure:"auto_apply"` // apply changes without !reload apply
+}
+
+// AlertmanagerConfig polls a Prometheus Alertmanager's API and posts its
+// alerts when they fire and when they resolve
+type AlertmanagerConfig struct {
+	URL            string        `mapstructure:"url"` // e.g. http://localhost:9093; empty disables polling
+	Interval       time.Duration `mapstructure:"interval"`
+	Channels       []string      `mapstructure:"channels"`
+	Filters        []string      `mapstructure:"filters"` // Alertmanager matchers, e.g. severity="critical"
+	Format         string        `mapstructure:"format"`
+	ResolvedFormat string        `mapstructure:"resolved_format"`
+}
+
+// MetadataConfig enriches messages with static attributes per topic or
+// device, available to templates as {{.Meta.<attribute>}}
+type MetadataConfig struct {
+	File         string   `mapstructure:"file"`          // YAML or CSV; empty disables enrichment
+	DeviceFields []string `mapstructure:"device_fields"` // payload JSON fields holding the device ID, first non-empty wins
+}
+
+// TopicAlias rewrites an incoming MQTT topic to a canonical topic before it
+// is mapped. Exactly one of Topic (exact match) and Regex is set.
+type TopicAlias struct {
+	Topic     string `mapstructure:"topic"`
+	Regex     string `mapstructure:"regex"` // must match the whole topic; $1... refer to groups in Canonical
+	Canonical string `mapstructure:"canonical"`
+}
+
+// ChannelRateConfig is the per-channel message budget (on top of the global
+// IRC rate limit)
+type ChannelRateConfig struct {
+	MessagesPerMinute float64 `mapstructure:"messages_per_minute"` // 0 disables the budget
+	Burst             int     `mapstructure:"burst"`
+}
+
+// RedactionConfig masks sensitive values in debug logs and, optionally, in IRC output
+type RedactionConfig struct {
+	Fields    []string `mapstructure:"fields"`     // JSON field names, any depth, case-insensitive
+	Patterns  []string `mapstructure:"patterns"`   // regular expressions; capture groups limit what is masked
+	Mask      string   `mapstructure:"mask"`       // replacement text
+	IRCOutput bool     `mapstructure:"irc_output"` // also redact payloads before formatting for IRC
+}
+
+// BannerConfig controls the "bridge online" announcement posted after the
+// IRC connection is established
+type BannerConfig struct {
+	Channels    []string      `mapstructure:"channels"` // empty disables the banner
+	Format      string        `mapstructure:"format"`
+	MinDowntime time.Duration `mapstructure:"min_downtime"` // skip the banner on reconnects after shorter outages
+}
+
+// HeartbeatConfig watches a heartbeat topic published by another system and
+// announces in IRC when heartbeats stop and resume
+type HeartbeatConfig struct {
+	Name        string        `mapstructure:"name"`
+	MQTTTopic   string        `mapstructure:"mqtt_topic"`
+	IRCChannels []string      `mapstructure:"irc_channels"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	Mode        string        `mapstructure:"mode"`   // "message" (default) or "topic"
+	Repeat      time.Duration `mapstructure:"repeat"` // re-post the down line while down (message mode, 0 = once)
+	DownFormat  string        `mapstructure:"down_format"`
+	UpFormat    string        `mapstructure:"up_format"`
+}
+
+// StatsTopicConfig periodically publishes the health/stats report (as on
+// /health) to an MQTT topic, e.g. for a Home Assistant sensor
+type StatsTopicConfig struct {
+	Topic    string        `mapstructure:"topic"` // empty disables publishing
+	Interval time.Duration `mapstructure:"interval"`
+	Retain   bool          `mapstructure:"retain"`
+	QoS      byte          `mapstructure:"qos"`
+}
+
+// HAConfig publishes Home Assistant MQTT discovery configs for the bridge
+// itself. The sensors read the stats_publish report, so it needs
+// stats_publish.topic.
+type HAConfig struct {
+	Discovery    bool   `mapstructure:"discovery"`
+	Prefix       string `mapstructure:"prefix"`        // HA discovery prefix
+	NodeID       string `mapstructure:"node_id"`       // device identifier, unique per bridge
+	CommandTopic string `mapstructure:"command_topic"` // button presses; default <stats topic>/command
+}
+
+// ErrorBudgetConfig alerts the ops channels once when the share of messages
+// lost to errors over a rolling window exceeds a threshold, and again when it
+// recovers
+type ErrorBudgetConfig struct {
+	Threshold   float64       `mapstructure:"threshold"` // fraction of messages, e.g. 0.1; 0 disables
+	Window      time.Duration `mapstructure:"window"`
+	MinMessages int           `mapstructure:"min_messages"` // windows with fewer messages are not judged
+	Reasons     []string      `mapstructure:"reasons"`      // drop reasons that count as errors
+}
+
+// BreakerConfig skips a channel for Cooldown once the server refused messages
+// or JOINs for it Failures times in a row (banned, +m, no such channel),
+// instead of failing and logging for every message
+type BreakerConfig struct {
+	Failures int           `mapstructure:"failures"` // server refusals since the channel last worked; 0 = off
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// Policies for the lines still waiting for a mapping a reload removes
+const (
+	RemovedSend = "send" // send them (default)
+	RemovedDrop = "drop" // drop them
+)
+
+// RemovedConfig says what a reload does for the channels of the mappings it
+// removes, so their members learn why a feed stopped
+type RemovedConfig struct {
+	Notice string `mapstructure:"notice"` // template posted to the mapping's channels, with {{.Topic}} and {{.Channel}}; empty = none
+	Queued string `mapstructure:"queued"` // send (default) or drop the mapping's lines waiting in channel queues and groups
+}
+
+// FaultConfig injects failures, to exercise retries, backoff and draining in
+// integration tests and staging. Never set in production
+type FaultConfig struct {
+	IRCSendDrop   float64       `mapstructure:"irc_send_drop"`  // fraction of IRC sends that fail
+	MQTTDelay     time.Duration `mapstructure:"mqtt_delay"`     // added before each received message is handled
+	IRCDisconnect string        `mapstructure:"irc_disconnect"` // cron schedule of forced IRC reconnects
+}
+
+// FlapConfig controls connection state history and flap detection
+type FlapConfig struct {
+	HistorySize    int           `mapstructure:"history_size"`
+	MaxDisconnects int           `mapstructure:"max_disconnects"` // 0 disables flap detection
+	Window         time.Duration `mapstructure:"window"`
+	Backoff        time.Duration `mapstructure:"backoff"`
 }
 
 // MappingConfig maps MQTT topics to IRC channels
@@ -81,47 +472,253 @@ type MappingConfig struct {
 	MessageFormat   string                 `mapstructure:"message_format"`
 	Processor       string                 `mapstructure:"processor"`
 	ProcessorConfig map[string]interface{} `mapstructure:"processor_config"`
+	Tenant          string                 `mapstructure:"tenant"` // owning tenant (optional)
+
+	// Payload size conditions in bytes (0 = no limit); lets large payloads be
+	// routed to a different format than small ones
+	MinBytes int `mapstructure:"min_bytes"`
+	MaxBytes int `mapstructure:"max_bytes"`
+
+	// Lines of a formatted message sent as separate IRC messages (0 or 1 =
+	// newlines are flattened to spaces)
+	MaxLines int `mapstructure:"max_lines"`
+
+	// Schema checks JSON payloads against a JSON Schema (optional)
+	Schema SchemaConfig `mapstructure:"schema"`
+
+	// Timezone is the IANA zone of {{.Time}} in this mapping's messages;
+	// empty means the global timezone
+	Timezone string `mapstructure:"timezone"`
+
+	// ReviewChannel holds the formatted messages in this channel until an
+	// admin approves (!approve) or rejects (!reject) them (optional)
+	ReviewChannel string `mapstructure:"review_channel"`
+
+	// Emoji converts emoji in the formatted lines: "shortcodes" (🔋 →
+	// :battery:) or "unicode" (:battery: → 🔋); empty leaves them alone
+	Emoji string `mapstructure:"emoji"`
+
+	// Transforms are named output transforms applied to the formatted lines
+	// in order, e.g. [strip_colors, ascii, "truncate=200"]; see
+	// internal/transform for the registered names
+	Transforms []string `mapstructure:"transforms"`
+
+	// Trust names how far the mapping's source is trusted: "trusted"
+	// (default), "untrusted" or a level of bridge.trust_levels
+	Trust string `mapstructure:"trust"`
+
+	// Group merges one-line messages with the same key that arrive within a
+	// window into one line (optional)
+	Group GroupConfig `mapstructure:"group"`
+
+	// Spam catches bridged chat content that looks like spam (optional)
+	Spam SpamConfig `mapstructure:"spam"`
+}
+
+// GroupConfig merges rapid-fire messages, e.g. the parts of a long mesh
+// message, into one line: "BOB: msg1 | msg2 | msg3"
+type GroupConfig struct {
+	Key       string        `mapstructure:"key"`       // template, e.g. {{.JSON.from}}; empty disables grouping
+	Window    time.Duration `mapstructure:"window"`    // from the first message of a group; default 5s
+	Separator string        `mapstructure:"separator"` // default " | "
+}
+
+// Spam actions
+const (
+	SpamDrop   = "drop"   // drop the message (default)
+	SpamDelay  = "delay"  // send it after spam.delay
+	SpamNotify = "notify" // send it; only tell the ops channels
+)
+
+// SpamConfig holds heuristics that catch spam in chat bridged from public
+// channels. Whatever the action, the ops channels are told (sampled).
+type SpamConfig struct {
+	RepeatSenders int           `mapstructure:"repeat_senders"` // the same text from this many senders within repeat_window is spam; 0 = off
+	RepeatWindow  time.Duration `mapstructure:"repeat_window"`  // default 10m
+	SenderField   string        `mapstructure:"sender_field"`   // JSON field naming the sender, e.g. "from"; without it every message is a sender of its own
+	MaxCaps       float64       `mapstructure:"max_caps"`       // share of capital letters above which a line is spam, e.g. 0.7; 0 = off
+	MinLetters    int           `mapstructure:"min_letters"`    // max_caps ignores lines with fewer letters; default 12
+	Patterns      []string      `mapstructure:"patterns"`       // regular expressions of known spam
+	Action        string        `mapstructure:"action"`         // drop (default), delay or notify
+	Delay         time.Duration `mapstructure:"delay"`          // how long action: delay holds a message; default 1m
+}
+
+// Enabled reports whether any heuristic is set.
+func (s SpamConfig) Enabled() bool {
+	return s.RepeatSenders > 0 || s.MaxCaps > 0 || len(s.Patterns) > 0
+}
+
+// OutputTransforms returns the mapping's transforms, with the one its Emoji
+// setting stands for appended
+func (m MappingConfig) OutputTransforms() []string {
+	switch m.Emoji {
+	case "shortcodes":
+		return append(m.Transforms[:len(m.Transforms):len(m.Transforms)], "emoji_shortcodes")
+	case "unicode":
+		return append(m.Transforms[:len(m.Transforms):len(m.Transforms)], "emoji_unicode")
+	}
+	return m.Transforms
+}
+
+// Trust levels every configuration has
+const (
+	TrustTrusted   = "trusted"   // content is sent as formatted
+	TrustUntrusted = "untrusted" // DefaultUntrusted, unless bridge.trust_levels redefines it
+)
+
+// TrustLevelConfig says how defensively the content of a mapping with this
+// trust level is treated, e.g. one bridging a public broker
+type TrustLevelConfig struct {
+	StripFormatting  bool   `mapstructure:"strip_formatting"`  // remove IRC colors and formatting
+	MaxLength        int    `mapstructure:"max_length"`        // cut lines to this many characters; 0 = max_message_length
+	MangleHighlights bool   `mapstructure:"mangle_highlights"` // break nicks of channel members so they are not highlighted
+	URLs             string `mapstructure:"urls"`              // keep (default), strip, mark or wrap
+	URLMarker        string `mapstructure:"url_marker"`        // replaces links with urls: mark; default "[link removed]"
+	URLWrapper       string `mapstructure:"url_wrapper"`       // links are routed through this URL with urls: wrap; {url} is the escaped link
+}
+
+// DefaultUntrusted is the untrusted trust level unless bridge.trust_levels
+// redefines it
+var DefaultUntrusted = TrustLevelConfig{StripFormatting: true, MaxLength: 200, MangleHighlights: true, URLs: "strip"}
+
+// TrustLevel returns the trust level named by a mapping's trust setting, or
+// false if there is none by that name
+func (b BridgeConfig) TrustLevel(name string) (TrustLevelConfig, bool) {
+	if name == "" || name == TrustTrusted {
+		return TrustLevelConfig{}, true
+	}
+	if level, ok := b.TrustLevels[name]; ok {
+		return level, true
+	}
+	if name == TrustUntrusted {
+		return DefaultUntrusted, true
+	}
+	return TrustLevelConfig{}, false
+}
+
+// Transforms returns the output transforms that apply the trust level,
+// run after the mapping's own
+func (l TrustLevelConfig) Transforms() []string {
+	var names []string
+	if l.StripFormatting {
+		names = append(names, "strip_colors")
+	}
+	switch l.URLs {
+	case "strip":
+		names = append(names, "strip_urls")
+	case "mark":
+		names = append(names, "mark_urls="+l.URLMarker)
+	case "wrap":
+		names = append(names, "wrap_urls="+l.URLWrapper)
+	}
+	if l.MaxLength > 0 {
+		names = append(names, fmt.Sprintf("truncate=%d", l.MaxLength))
+	}
+	return names
+}
+
+// SchemaConfig validates a mapping's payloads. Non-conforming payloads are
+// counted and reported to the ops channels (sampled); they are still bridged
+// unless Drop is set.
+type SchemaConfig struct {
+	File            string `mapstructure:"file"` // JSON Schema file; empty disables validation
+	Drop            bool   `mapstructure:"drop"`
+	DeadLetterTopic string `mapstructure:"dead_letter_topic"` // MQTT topic receiving non-conforming messages
 }
 
 // QueueConfig contains message queue settings
 type QueueConfig struct {
-	MaxSize     int  `mapstructure:"max_size"`
-	BlockOnFull bool `mapstructure:"block_on_full"`
+	MaxSize              int     `mapstructure:"max_size"`
+	BlockOnFull          bool    `mapstructure:"block_on_full"`
+	QoSPriority          bool    `mapstructure:"qos_priority"`           // QoS2 → high priority, QoS0 → low priority
+	LowPriorityWatermark float64 `mapstructure:"low_priority_watermark"` // queue fill ratio above which low priority is dropped
+}
+
+// StartupConfig controls how the bridge starts
+type StartupConfig struct {
+	// Degraded starts the bridge even if MQTT or IRC cannot be reached and
+	// keeps connecting them in the background, instead of exiting
+	Degraded bool `mapstructure:"degraded"`
+
+	// Retry retries failed initial connections instead of exiting
+	Retry StartupRetryConfig `mapstructure:"retry"`
+}
+
+// StartupRetryConfig controls retries of the initial connections. Disabled,
+// the bridge exits when a connection fails, for restart loops of a service
+// manager.
+type StartupRetryConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	MaxAttempts int           `mapstructure:"max_attempts"` // per side; 0 = unlimited
+	Backoff     time.Duration `mapstructure:"backoff"`      // first delay, doubled up to 60s
 }
 
 // LoggingConfig contains logging settings
 type LoggingConfig struct {
-	Level  string `mapstructure:"level"`
-	Format string `mapstructure:"format"`
+	Level    string           `mapstructure:"level"`
+	Format   string           `mapstructure:"format"`
+	Payloads PayloadLogConfig `mapstructure:"payloads"`
+	EventLog string           `mapstructure:"event_log"` // JSON lines per message outcome: a file or "stdout"
+}
+
+// PayloadLogConfig limits the message payloads logged at debug level, so
+// debug logging can stay on in production
+type PayloadLogConfig struct {
+	Sample       int      `mapstructure:"sample"`         // log one in every N messages; 0 or 1 = all
+	MaxPerMinute int      `mapstructure:"max_per_minute"` // 0 = unlimited
+	MaxBytes     int      `mapstructure:"max_bytes"`      // truncate longer payloads; 0 = whole payloads
+	Topics       []string `mapstructure:"topics"`         // MQTT patterns; empty = all topics
 }
 
 // HealthConfig contains health check server settings
 type HealthConfig struct {
-	Enabled bool `mapstructure:"enabled"`
-	Port    int  `mapstructure:"port"`
+	Enabled   bool                `mapstructure:"enabled"`
+	Address   string              `mapstructure:"address"` // bind address; "0.0.0.0" or "" for all interfaces
+	Port      int                 `mapstructure:"port"`
+	Socket    string              `mapstructure:"socket"` // unix socket path; replaces address and port when set
+	Auth      HTTPAuthConfig      `mapstructure:"auth"`
+	RateLimit HTTPRateLimitConfig `mapstructure:"rate_limit"`
+	TLS       HTTPTLSConfig       `mapstructure:"tls"`
+	// UnauthenticatedWrites serves the endpoints that change state (POST
+	// /drain, POST /api/v1/purge) without auth credentials configured
+	UnauthenticatedWrites bool `mapstructure:"unauthenticated_writes"`
+}
+
+// HTTPAuthConfig requires HTTP basic auth or a bearer token on the health
+// server. With neither set, no authentication is required
+type HTTPAuthConfig struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Token    string `mapstructure:"token"` // accepted as "Authorization: Bearer <token>"
+
+	// PublicPaths are served without authentication, e.g. for container
+	// health checks and readiness probes
+	PublicPaths []string `mapstructure:"public_paths"`
+}
+
+// Enabled reports whether any credentials are configured.
+func (c HTTPAuthConfig) Enabled() bool {
+	return c.Username != "" || c.Token != ""
+}
+
+// HTTPRateLimitConfig limits requests per client IP (token bucket)
+type HTTPRateLimitConfig struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"` // 0 disables the limit
+	Burst             int     `mapstructure:"burst"`
+}
+
+// HTTPTLSConfig serves the health server over HTTPS; with ClientCAFile set,
+// clients must present a certificate signed by that CA (mTLS)
+type HTTPTLSConfig struct {
+	CertFile     string `mapstructure:"cert_file"`
+	KeyFile      string `mapstructure:"key_file"`
+	ClientCAFile string `mapstructure:"client_ca_file"`
 }
 
 // Load reads configuration from file and environment variables
 func Load(configPath string) (*Config, error) {
 	v := viper.New()
-
-	// Set defaults
-	v.SetDefault("mqtt.qos", 1)
-	v.SetDefault("mqtt.use_tls", true)
-	v.SetDefault("irc.use_tls", true)
-	v.SetDefault("irc.rate_limit.messages_per_second", 2.0)
-	v.SetDefault("irc.rate_limit.burst", 5)
-	v.SetDefault("bridge.queue.max_size", 1000)
-	v.SetDefault("bridge.queue.block_on_full", false)
-	v.SetDefault("bridge.max_message_length", 400)
-	v.SetDefault("bridge.truncate_suffix", "...")
-	v.SetDefault("logging.level", "info")
-	v.SetDefault("logging.format", "json")
-	v.SetDefault("health.enabled", true)
-	v.SetDefault("health.port", 8080)
-	v.SetDefault("admin.enabled", false)
-	v.SetDefault("admin.command_prefix", "!")
-	v.SetDefault("admin.accept_pm", true)
+	setDefaults(v)
 
 	// Configure Viper
 	if configPath != "" {
@@ -143,6 +740,91 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
+	cfg, err := decode(v)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Source = newSource(v)
+	return cfg, nil
+}
+
+// setDefaults registers the default value of every optional setting.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("mqtt.qos", 1)
+	v.SetDefault("mqtt.use_tls", true)
+	v.SetDefault("mqtt.probe.interval", "0s")
+	v.SetDefault("mqtt.probe.timeout", "30s")
+	v.SetDefault("mqtt.redelivery_window", "0s")
+	v.SetDefault("mqtt.storm_breaker.cooldown", "5m")
+	v.SetDefault("irc.use_tls", true)
+	v.SetDefault("irc.rate_limit.messages_per_second", 2.0)
+	v.SetDefault("irc.rate_limit.burst", 5)
+	v.SetDefault("irc.failback_interval", "30m")
+	v.SetDefault("irc.keepalive.interval", "2m")
+	v.SetDefault("irc.keepalive.timeout", "30s")
+	v.SetDefault("irc.nick_reclaim.enabled", false)
+	v.SetDefault("irc.nick_reclaim.command", "regain")
+	v.SetDefault("irc.nick_reclaim.interval", "1m")
+	v.SetDefault("irc.ident.listen", ":113")
+	v.SetDefault("irc.ident.oidentd_file", "~/.oidentd.conf")
+	v.SetDefault("bridge.queue.max_size", 1000)
+	v.SetDefault("bridge.queue.block_on_full", false)
+	v.SetDefault("bridge.queue.qos_priority", false)
+	v.SetDefault("bridge.queue.low_priority_watermark", 0.8)
+	v.SetDefault("bridge.max_message_length", 400)
+	v.SetDefault("bridge.max_message_bytes", 400)
+	v.SetDefault("bridge.truncate_mode", "runes")
+	v.SetDefault("bridge.truncate_suffix", "...")
+	v.SetDefault("bridge.cache_json", true)
+	v.SetDefault("bridge.channel_order", "config")
+	v.SetDefault("bridge.channel_queue_size", 0)
+	v.SetDefault("bridge.history_size", 0)
+	v.SetDefault("bridge.stats_publish.interval", "1m")
+	v.SetDefault("bridge.stats_publish.retain", true)
+	v.SetDefault("bridge.stats_publish.qos", 0)
+	v.SetDefault("bridge.remote_mappings.interval", "5m")
+	v.SetDefault("bridge.alertmanager.interval", "1m")
+	v.SetDefault("bridge.home_assistant.prefix", "homeassistant")
+	v.SetDefault("bridge.home_assistant.node_id", "mqtt2irc")
+	v.SetDefault("bridge.drain_timeout", "25s")
+	v.SetDefault("bridge.error_budget.threshold", 0.0)
+	v.SetDefault("bridge.error_budget.window", "10m")
+	v.SetDefault("bridge.error_budget.min_messages", 20)
+	v.SetDefault("bridge.circuit_breaker.cooldown", "5m")
+	v.SetDefault("bridge.error_budget.reasons", []string{"queue_full", "low_priority", "channel_queue", "channel_budget", "send_failed"})
+	v.SetDefault("bridge.flap_detection.history_size", 20)
+	v.SetDefault("bridge.flap_detection.max_disconnects", 5)
+	v.SetDefault("bridge.flap_detection.window", "10m")
+	v.SetDefault("bridge.flap_detection.backoff", "2m")
+	v.SetDefault("bridge.banner.min_downtime", "5m")
+	v.SetDefault("bridge.redaction.mask", "***")
+	v.SetDefault("bridge.channel_rate.messages_per_minute", 0)
+	v.SetDefault("bridge.channel_rate.burst", 5)
+	v.SetDefault("bridge.metadata.device_fields", []string{"device_id", "sender"})
+	v.SetDefault("bridge.sent_hook.timeout", "5s")
+	v.SetDefault("bridge.sent_hook.max_running", 4)
+	v.SetDefault("logging.level", "info")
+	v.SetDefault("logging.format", "json")
+	v.SetDefault("health.enabled", true)
+	v.SetDefault("health.address", "127.0.0.1")
+	v.SetDefault("health.port", 8080)
+	v.SetDefault("health.auth.public_paths", []string{"/health", "/ready"})
+	v.SetDefault("health.rate_limit.requests_per_second", 10.0)
+	v.SetDefault("health.rate_limit.burst", 20)
+	v.SetDefault("health.unauthenticated_writes", false)
+	v.SetDefault("startup.retry.backoff", "1s")
+
+	v.SetDefault("admin.enabled", false)
+	v.SetDefault("admin.command_prefix", "!")
+	v.SetDefault("admin.accept_pm", true)
+	v.SetDefault("admin.reply_mode", "channel")
+	v.SetDefault("admin.language", "en")
+	v.SetDefault("admin.flow_timeout", "5m")
+	v.SetDefault("admin.auth.webhook.timeout", "5s")
+}
+
+// decode unmarshals and validates the config read into v.
+func decode(v *viper.Viper) (*Config, error) {
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Diff summarizes the changes between two configurations as far as a reload
+// is concerned: mappings and MQTT subscriptions are applied live, everything
+// else needs a restart.
+type Diff struct {
+	MappingsAdded   []string // mqtt_topic of each added mapping
+	MappingsRemoved []string
+	MappingsChanged []string
+	Subscribe       []string // topic patterns
+	Unsubscribe     []string
+	Restart         []string // changed sections that only take effect after a restart
+}
+
+// Compare returns the differences from old to new.
+func Compare(old, new *Config) Diff {
+	var d Diff
+
+	// Mappings are matched by mqtt_topic and position among mappings with the
+	// same topic (size routing can map one topic several times).
+	oldMappings := mappingsByKey(old.Bridge.Mappings)
+	newMappings := mappingsByKey(new.Bridge.Mappings)
+	for _, key := range mappingKeys(old.Bridge.Mappings) {
+		n, ok := newMappings[key]
+		switch {
+		case !ok:
+			d.MappingsRemoved = append(d.MappingsRemoved, oldMappings[key].MQTTTopic)
+		case !reflect.DeepEqual(oldMappings[key], n):
+			d.MappingsChanged = append(d.MappingsChanged, n.MQTTTopic)
+		}
+	}
+	for _, key := range mappingKeys(new.Bridge.Mappings) {
+		if _, ok := oldMappings[key]; !ok {
+			d.MappingsAdded = append(d.MappingsAdded, newMappings[key].MQTTTopic)
+		}
+	}
+
+	oldTopics := topicSet(old.MQTT.Topics)
+	newTopics := topicSet(new.MQTT.Topics)
+	for _, t := range new.MQTT.Topics {
+		if q, ok := oldTopics[t.Pattern]; !ok || q != t.QoS {
+			d.Subscribe = append(d.Subscribe, t.Pattern)
+		}
+	}
+	for _, t := range old.MQTT.Topics {
+		if _, ok := newTopics[t.Pattern]; !ok {
+			d.Unsubscribe = append(d.Unsubscribe, t.Pattern)
+		}
+	}
+
+	oldMQTT, newMQTT := old.MQTT, new.MQTT
+	oldMQTT.Topics, newMQTT.Topics = nil, nil
+	oldBridge, newBridge := old.Bridge, new.Bridge
+	oldBridge.Mappings, newBridge.Mappings = nil, nil
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"mqtt", oldMQTT, newMQTT},
+		{"irc", old.IRC, new.IRC},
+		{"bridge", oldBridge, newBridge},
+		{"logging", old.Logging, new.Logging},
+		{"health", old.Health, new.Health},
+		{"admin", old.Admin, new.Admin},
+		{"startup", old.Startup, new.Startup},
+		{"tenants", old.Tenants, new.Tenants},
+		{"timezone", old.Timezone, new.Timezone},
+	}
+	for _, s := range sections {
+		if !reflect.DeepEqual(s.old, s.new) {
+			d.Restart = append(d.Restart, s.name)
+		}
+	}
+
+	return d
+}
+
+// Empty reports whether nothing changed.
+func (d Diff) Empty() bool {
+	return len(d.MappingsAdded) == 0 && len(d.MappingsRemoved) == 0 && len(d.MappingsChanged) == 0 &&
+		len(d.Subscribe) == 0 && len(d.Unsubscribe) == 0 && len(d.Restart) == 0
+}
+
+// Live reports whether the diff contains changes a reload can apply.
+func (d Diff) Live() bool {
+	return len(d.MappingsAdded) > 0 || len(d.MappingsRemoved) > 0 || len(d.MappingsChanged) > 0 ||
+		len(d.Subscribe) > 0 || len(d.Unsubscribe) > 0
+}
+
+// Lines renders the diff as short lines suitable for IRC.
+func (d Diff) Lines() []string {
+	if d.Empty() {
+		return []string{"no changes"}
+	}
+	var lines []string
+	add := func(label string, items []string) {
+		if len(items) > 0 {
+			lines = append(lines, fmt.Sprintf("%s (%d): %s", label, len(items), strings.Join(items, ", ")))
+		}
+	}
+	add("mappings added", d.MappingsAdded)
+	add("mappings removed", d.MappingsRemoved)
+	add("mappings changed", d.MappingsChanged)
+	add("subscribe", d.Subscribe)
+	add("unsubscribe", d.Unsubscribe)
+	add("needs restart, not applied", d.Restart)
+	return lines
+}
+
+func mappingKey(topic string, n int) string {
+	return fmt.Sprintf("%s#%d", topic, n)
+}
+
+// mappingKeys returns the keys of mappings in config order.
+func mappingKeys(mappings []MappingConfig) []string {
+	seen := make(map[string]int, len(mappings))
+	keys := make([]string, len(mappings))
+	for i, m := range mappings {
+		keys[i] = mappingKey(m.MQTTTopic, seen[m.MQTTTopic])
+		seen[m.MQTTTopic]++
+	}
+	return keys
+}
+
+func mappingsByKey(mappings []MappingConfig) map[string]MappingConfig {
+	byKey := make(map[string]MappingConfig, len(mappings))
+	for i, key := range mappingKeys(mappings) {
+		byKey[key] = mappings[i]
+	}
+	return byKey
+}
+
+func topicSet(topics []TopicConfig) map[string]byte {
+	set := make(map[string]byte, len(topics))
+	for _, t := range topics {
+		set[t.Pattern] = t.QoS
+	}
+	return set
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	old := &Config{
+		MQTT: MQTTConfig{Broker: "tcp://a:1883", Topics: []TopicConfig{{Pattern: "a/#"}, {Pattern: "b/#", QoS: 1}}},
+		Bridge: BridgeConfig{Mappings: []MappingConfig{
+			{MQTTTopic: "a/#", IRCChannels: []string{"#a"}},
+			{MQTTTopic: "cam", IRCChannels: []string{"#a"}, MaxBytes: 100},
+			{MQTTTopic: "cam", IRCChannels: []string{"#a"}, MinBytes: 101},
+			{MQTTTopic: "b/#", IRCChannels: []string{"#b"}},
+		}},
+	}
+	new := &Config{
+		MQTT: MQTTConfig{Broker: "tcp://b:1883", Topics: []TopicConfig{{Pattern: "a/#"}, {Pattern: "b/#", QoS: 2}, {Pattern: "c/#"}}},
+		Bridge: BridgeConfig{Mappings: []MappingConfig{
+			{MQTTTopic: "a/#", IRCChannels: []string{"#a"}},
+			{MQTTTopic: "cam", IRCChannels: []string{"#a"}, MaxBytes: 100},
+			{MQTTTopic: "cam", IRCChannels: []string{"#cam"}, MinBytes: 101},
+			{MQTTTopic: "c/#", IRCChannels: []string{"#c"}},
+		}},
+		Logging: LoggingConfig{Level: "debug"},
+	}
+
+	got := Compare(old, new)
+	want := Diff{
+		MappingsAdded:   []string{"c/#"},
+		MappingsRemoved: []string{"b/#"},
+		MappingsChanged: []string{"cam"},
+		Subscribe:       []string{"b/#", "c/#"},
+		Restart:         []string{"mqtt", "logging"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() = %+v, want %+v", got, want)
+	}
+	if !got.Live() || got.Empty() {
+		t.Error("diff should be live and non-empty")
+	}
+
+	if d := Compare(old, old); !d.Empty() || !reflect.DeepEqual(d.Lines(), []string{"no changes"}) {
+		t.Errorf("Compare(old, old) = %+v", d)
+	}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/viper"
+)
+
+// regionPattern matches Meshtastic region names such as "EU_868" or "US".
+var regionPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// PresetOptions holds the user-supplied values a preset is rendered with.
+// Empty fields fall back to the preset's defaults.
+type PresetOptions struct {
+	Region    string // e.g. "EU_868", "US"
+	Channel   string // IRC channel to post to
+	IRCServer string // host:port
+	Nickname  string
+}
+
+// preset is a named config generator: a YAML template plus default options.
+type preset struct {
+	defaults PresetOptions
+	template string
+}
+
+var presets = map[string]preset{
+	"meshtastic": {
+		defaults: PresetOptions{
+			Region:    "EU_868",
+			Channel:   "#meshtastic",
+			IRCServer: "irc.libera.chat:6697",
+			Nickname:  "meshbridge",
+		},
+		template: meshtasticPreset,
+	},
+}
+
+// meshtasticPreset targets the public mqtt.meshtastic.org broker. Its credentials
+// are published in the Meshtastic documentation and are not secrets.
+const meshtasticPreset = `# Generated by: mqtt2irc -preset meshtastic -region {{.Region}} -channel {{q .Channel}}
+# Public Meshtastic broker, JSON uplink for region {{.Region}}.
+
+mqtt:
+  broker: "tcp://mqtt.meshtastic.org:1883"
+  client_id: {{q .ClientID}}
+  username: "meshdev"
+  password: "large4cats"
+  use_tls: false
+  qos: 0
+  topics:
+    - pattern: "msh/{{.Region}}/2/json/#"
+      qos: 0
+
+irc:
+  server: {{q .IRCServer}}
+  use_tls: {{.IRCTLS}}
+  nickname: {{q .Nickname}}
+  username: {{q .Nickname}}
+  realname: "Meshtastic to IRC bridge"
+  # Public meshes can be chatty; stay well below typical flood limits.
+  rate_limit:
+    messages_per_second: 1
+    burst: 3
+
+bridge:
+  mappings:
+    - mqtt_topic: "msh/{{.Region}}/2/json/#"
+      irc_channels:
+        - {{q .Channel}}
+      processor: "meshtastic"
+      processor_config:
+        dedup_window: "60s"
+        # node_db: "/var/lib/mqtt2irc/meshtastic_nodes.json"
+
+  queue:
+    max_size: 500
+    block_on_full: false
+
+  max_message_length: 400
+  truncate_suffix: "..."
+
+logging:
+  level: "info"
+  format: "console"
+
+health:
+  enabled: true
+  port: 8080
+`
+
+// PresetNames returns the names of all built-in presets, sorted.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RenderPreset renders a complete YAML config for the named preset.
+// The result is validated by loading it back before being returned.
+func RenderPreset(name string, opts PresetOptions) (string, error) {
+	p, ok := presets[name]
+	if !ok {
+		return "", fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+	}
+
+	if opts.Region == "" {
+		opts.Region = p.defaults.Region
+	}
+	if opts.Channel == "" {
+		opts.Channel = p.defaults.Channel
+	}
+	if opts.IRCServer == "" {
+		opts.IRCServer = p.defaults.IRCServer
+	}
+	if opts.Nickname == "" {
+		opts.Nickname = p.defaults.Nickname
+	}
+	if !strings.HasPrefix(opts.Channel, "#") && !strings.HasPrefix(opts.Channel, "&") {
+		opts.Channel = "#" + opts.Channel
+	}
+	if !regionPattern.MatchString(opts.Region) {
+		return "", fmt.Errorf("invalid region %q", opts.Region)
+	}
+
+	// Plain-text IRC is conventionally on 6667; everything else gets TLS.
+	ircTLS := !strings.HasSuffix(opts.IRCServer, ":6667")
+
+	data := map[string]interface{}{
+		"Region":    opts.Region,
+		"Channel":   opts.Channel,
+		"IRCServer": opts.IRCServer,
+		"IRCTLS":    ircTLS,
+		"Nickname":  opts.Nickname,
+		"ClientID":  "mqtt2irc_" + strings.ToLower(opts.Nickname),
+	}
+
+	// Values are user input: render them as quoted YAML scalars so quotes,
+	// backslashes or newlines cannot break out of their field.
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"q": strconv.Quote}).Parse(p.template)
+	if err != nil {
+		return "", fmt.Errorf("preset %q: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("preset %q: %w", name, err)
+	}
+
+	// Make sure the generated config loads the way Load would read it.
+	v := viper.New()
+	setDefaults(v)
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(buf.Bytes())); err != nil {
+		return "", fmt.Errorf("preset %q: generated invalid YAML: %w", name, err)
+	}
+	if _, err := decode(v); err != nil {
+		return "", fmt.Errorf("preset %q: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestRenderPreset_Meshtastic(t *testing.T) {
+	out, err := RenderPreset("meshtastic", PresetOptions{Region: "US", Channel: "mesh"})
+	if err != nil {
+		t.Fatalf("RenderPreset: %v", err)
+	}
+	for _, want := range []string{
+		`pattern: "msh/US/2/json/#"`,
+		`mqtt_topic: "msh/US/2/json/#"`,
+		`- "#mesh"`,
+		`processor: "meshtastic"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered preset missing %q", want)
+		}
+	}
+}
+
+func TestRenderPreset_PlainIRC(t *testing.T) {
+	out, err := RenderPreset("meshtastic", PresetOptions{IRCServer: "localhost:6667"})
+	if err != nil {
+		t.Fatalf("RenderPreset: %v", err)
+	}
+	if !strings.Contains(out, "use_tls: false\n  nickname") {
+		t.Error("expected IRC TLS to be disabled for port 6667")
+	}
+}
+
+func TestRenderPreset_Errors(t *testing.T) {
+	if _, err := RenderPreset("nope", PresetOptions{}); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+	if _, err := RenderPreset("meshtastic", PresetOptions{Region: "EU/#"}); err == nil {
+		t.Error("expected error for region containing topic separators")
+	}
+}
+
+func TestRenderPreset_HostileInput(t *testing.T) {
+	out, err := RenderPreset("meshtastic", PresetOptions{
+		Channel:  "#mesh\"\n  evil: true",
+		Nickname: `bot\" # x`,
+	})
+	if err != nil {
+		t.Fatalf("RenderPreset: %v", err)
+	}
+	if strings.Contains(out, "\n  evil: true") {
+		t.Error("channel value injected a YAML key")
+	}
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(out)); err != nil {
+		t.Fatalf("rendered preset is not valid YAML: %v", err)
+	}
+	if got := v.GetString("irc.nickname"); got != `bot\" # x` {
+		t.Errorf("nickname = %q, want it unchanged", got)
+	}
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Bridge.Mappings[0].IRCChannels[0]; got != "#mesh\"\n  evil: true" {
+		t.Errorf("channel = %q, want it unchanged", got)
+	}
+}
+
+func TestRenderPreset_InvalidRegion(t *testing.T) {
+	for _, region := range []string{"EU 868", "EU\"868", "EU\n868", `EU\868`} {
+		if _, err := RenderPreset("meshtastic", PresetOptions{Region: region}); err == nil {
+			t.Errorf("region %q: expected error", region)
+		}
+	}
+}
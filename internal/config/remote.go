@@ -0,0 +1,56 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// OriginRemote marks settings taken from bridge.remote_mappings.
+const OriginRemote = "remote"
+
+// RemoteMappings is a parsed remote mappings document (see RemoteConfig).
+type RemoteMappings struct {
+	Mappings []MappingConfig
+	raw      interface{} // the mappings as read, for Source
+}
+
+// ParseMappings parses a remote mappings document: YAML or JSON with a
+// top-level "mappings" list. The mappings are validated with the rest of the
+// configuration by WithRemoteMappings.
+func ParseMappings(data []byte) (*RemoteMappings, error) {
+	v := viper.New()
+	v.SetConfigType("yaml") // JSON is valid YAML
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to parse mappings: %w", err)
+	}
+	var mappings []MappingConfig
+	if err := v.UnmarshalKey("mappings", &mappings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mappings: %w", err)
+	}
+	if len(mappings) == 0 {
+		return nil, fmt.Errorf("mappings document has no mappings")
+	}
+	return &RemoteMappings{Mappings: mappings, raw: v.Get("mappings")}, nil
+}
+
+// WithRemoteMappings returns a copy of c using the remote mappings instead
+// of bridge.mappings, validated as a whole.
+func (c *Config) WithRemoteMappings(rm *RemoteMappings) (*Config, error) {
+	next := *c
+	next.Bridge.Mappings = rm.Mappings
+	if err := Validate(&next); err != nil {
+		return nil, fmt.Errorf("invalid remote mappings: %w", err)
+	}
+	if c.Source != nil {
+		const key = "bridge.mappings"
+		remote := &Source{
+			Settings: map[string]Setting{key: {Value: redact(key, rm.raw), Origin: OriginRemote}},
+			digests:  map[string][sha256.Size]byte{key: digest(key, rm.raw)},
+		}
+		next.Source = c.Source.With(remote, key)
+	}
+	return &next, nil
+}
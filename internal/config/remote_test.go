@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMappings(t *testing.T) {
+	yaml := "mappings:\n  - mqtt_topic: \"b/#\"\n    irc_channels: [\"#b\"]\n    message_format: \"{{.Payload}}\"\n"
+	json := `{"mappings": [{"mqtt_topic": "b/#", "irc_channels": ["#b"], "message_format": "{{.Payload}}"}]}`
+	for name, doc := range map[string]string{"yaml": yaml, "json": json} {
+		rm, err := ParseMappings([]byte(doc))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(rm.Mappings) != 1 || rm.Mappings[0].MQTTTopic != "b/#" || rm.Mappings[0].IRCChannels[0] != "#b" {
+			t.Errorf("%s: mappings = %+v", name, rm.Mappings)
+		}
+	}
+
+	for _, doc := range []string{"mappings: [", "other: 1", "mappings: []"} {
+		if _, err := ParseMappings([]byte(doc)); err == nil {
+			t.Errorf("ParseMappings(%q) succeeded", doc)
+		}
+	}
+}
+
+func TestWithRemoteMappings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(sourceTestConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rm, err := ParseMappings([]byte(`{"mappings": [{"mqtt_topic": "a/b", "irc_channels": ["#b"], "message_format": "{{.Payload}}"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := cfg.WithRemoteMappings(rm)
+	if err != nil {
+		t.Fatalf("WithRemoteMappings: %v", err)
+	}
+	if next.Bridge.Mappings[0].MQTTTopic != "a/b" || cfg.Bridge.Mappings[0].MQTTTopic != "a/#" {
+		t.Errorf("mappings = %+v, original %+v", next.Bridge.Mappings, cfg.Bridge.Mappings)
+	}
+	if got := next.Source.Settings["bridge.mappings"].Origin; got != OriginRemote {
+		t.Errorf("bridge.mappings source = %q, want %q", got, OriginRemote)
+	}
+	if next.Source.Hash == cfg.Source.Hash {
+		t.Error("hash unchanged with different mappings")
+	}
+
+	invalid, _ := ParseMappings([]byte(`{"mappings": [{"mqtt_topic": "a/b", "irc_channels": ["b"]}]}`))
+	if _, err := cfg.WithRemoteMappings(invalid); err == nil {
+		t.Error("WithRemoteMappings accepted a channel without #")
+	}
+}
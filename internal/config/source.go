@@ -0,0 +1,162 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Origins of a setting's value.
+const (
+	OriginDefault = "default"
+	OriginFile    = "file"
+	OriginEnv     = "env"
+)
+
+// redacted replaces secret values in Source.
+const redacted = "<redacted>"
+
+// Source describes where each value of a loaded configuration came from, for
+// answering "which config is it actually using" without guessing viper's
+// search path. Secrets are redacted.
+type Source struct {
+	File     string             `json:"file"` // config file that was read
+	Hash     string             `json:"hash"` // identifies the effective values, see rehash
+	Settings map[string]Setting `json:"settings"`
+
+	digests map[string][sha256.Size]byte // per setting, of the unredacted value
+}
+
+// Setting is the effective value of one setting (dotted key) and its origin.
+type Setting struct {
+	Value  interface{} `json:"value"`
+	Origin string      `json:"source"` // OriginDefault, OriginFile or OriginEnv
+}
+
+// envKey returns the environment variable that overrides key.
+func envKey(key string) string {
+	return "MQTT2IRC_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// newSource records the effective value and origin of every key v knows.
+func newSource(v *viper.Viper) *Source {
+	s := &Source{
+		File:     v.ConfigFileUsed(),
+		Settings: make(map[string]Setting),
+		digests:  make(map[string][sha256.Size]byte),
+	}
+	for _, key := range v.AllKeys() {
+		origin := OriginDefault
+		if os.Getenv(envKey(key)) != "" { // viper ignores empty variables
+			origin = OriginEnv
+		} else if v.InConfig(key) {
+			origin = OriginFile
+		}
+		value := v.Get(key)
+		s.Settings[key] = Setting{Value: redact(key, value), Origin: origin}
+		s.digests[key] = digest(key, value)
+	}
+	s.rehash()
+	return s
+}
+
+// digest identifies the unredacted value of a setting.
+func digest(key string, value interface{}) [sha256.Size]byte {
+	return sha256.Sum256([]byte(fmt.Sprintf("%s=%v", key, value)))
+}
+
+// rehash sets Hash from the settings' digests. Secrets count, so changing a
+// password changes the hash, while where a value came from does not.
+func (s *Source) rehash() {
+	h := sha256.New()
+	for _, key := range s.Keys() {
+		d := s.digests[key]
+		h.Write(d[:])
+	}
+	s.Hash = hex.EncodeToString(h.Sum(nil))
+}
+
+// EnvOverrides returns the environment variables that set values, sorted.
+func (s *Source) EnvOverrides() []string {
+	var vars []string
+	for _, key := range s.Keys() {
+		if s.Settings[key].Origin == OriginEnv {
+			vars = append(vars, envKey(key))
+		}
+	}
+	return vars
+}
+
+// Keys returns the setting keys in sorted order.
+func (s *Source) Keys() []string {
+	keys := make([]string, 0, len(s.Settings))
+	for k := range s.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// With returns a copy of s with the given settings taken from other, for
+// reloads that apply only part of a new configuration.
+func (s *Source) With(other *Source, keys ...string) *Source {
+	next := &Source{
+		File:     s.File,
+		Settings: make(map[string]Setting, len(s.Settings)),
+		digests:  make(map[string][sha256.Size]byte, len(s.digests)),
+	}
+	for k, v := range s.Settings {
+		next.Settings[k] = v
+		next.digests[k] = s.digests[k]
+	}
+	for _, k := range keys {
+		if v, ok := other.Settings[k]; ok {
+			next.Settings[k] = v
+			next.digests[k] = other.digests[k]
+		} else {
+			delete(next.Settings, k)
+			delete(next.digests, k)
+		}
+	}
+	next.rehash()
+	return next
+}
+
+// secretKey reports whether a setting (last key segment) holds a secret.
+func secretKey(key string) bool {
+	key = strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+	for _, s := range []string{"password", "token", "secret"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces non-empty secrets in value, including those nested in
+// lists and maps (e.g. tenants).
+func redact(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = redact(k, e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = redact(key, e)
+		}
+		return out
+	}
+	if secretKey(key) && value != nil && value != "" {
+		return redacted
+	}
+	return value
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const sourceTestConfig = `
+mqtt:
+  broker: "tcp://localhost:1883"
+  client_id: "test"
+  password: "mqtt-secret"
+  topics:
+    - pattern: "a/#"
+irc:
+  server: "irc.example.org:6697"
+  nickname: "bot"
+  nickserv_password: ""
+bridge:
+  mappings:
+    - mqtt_topic: "a/#"
+      irc_channels: ["#a"]
+      message_format: "{{.Payload}}"
+      processor_config:
+        api_token: "nested"
+`
+
+func TestLoad_Source(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(sourceTestConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MQTT2IRC_IRC_NICKNAME", "envbot")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := cfg.Source
+	if src.File != path {
+		t.Errorf("File = %q, want %q", src.File, path)
+	}
+	tests := []struct {
+		key    string
+		value  interface{}
+		origin string
+	}{
+		{"mqtt.broker", "tcp://localhost:1883", OriginFile},
+		{"mqtt.password", redacted, OriginFile},
+		{"irc.nickname", "envbot", OriginEnv},
+		{"irc.nickserv_password", "", OriginFile},
+		{"bridge.queue.max_size", 1000, OriginDefault},
+	}
+	for _, tt := range tests {
+		got, ok := src.Settings[tt.key]
+		if !ok {
+			t.Errorf("%s: missing", tt.key)
+			continue
+		}
+		if got.Value != tt.value || got.Origin != tt.origin {
+			t.Errorf("%s = %v (%s), want %v (%s)", tt.key, got.Value, got.Origin, tt.value, tt.origin)
+		}
+	}
+	mappings := src.Settings["bridge.mappings"].Value.([]interface{})
+	pc := mappings[0].(map[string]interface{})["processor_config"].(map[string]interface{})
+	if pc["api_token"] != redacted {
+		t.Errorf("nested api_token = %v, want it redacted", pc["api_token"])
+	}
+	if got := src.EnvOverrides(); len(got) != 1 || got[0] != "MQTT2IRC_IRC_NICKNAME" {
+		t.Errorf("EnvOverrides() = %v", got)
+	}
+
+	// The hash covers values including secrets, not where they came from.
+	hash := src.Hash
+	t.Setenv("MQTT2IRC_MQTT_PASSWORD", "other")
+	if cfg, _ := Load(path); cfg.Source.Hash == hash {
+		t.Error("hash unchanged after a password change")
+	}
+	t.Setenv("MQTT2IRC_MQTT_PASSWORD", "mqtt-secret")
+	if cfg, _ := Load(path); cfg.Source.Hash != hash {
+		t.Error("hash changed when a value moved from the file to the environment")
+	}
+
+	if cfg.IRC.Nickname != "envbot" {
+		t.Errorf("Nickname = %q, want the env override", cfg.IRC.Nickname)
+	}
+}
+
+func TestSource_With(t *testing.T) {
+	old := &Source{File: "a.yaml", Settings: map[string]Setting{
+		"bridge.mappings": {Value: "old", Origin: OriginFile},
+		"irc.nickname":    {Value: "bot", Origin: OriginFile},
+	}}
+	reloaded := &Source{File: "a.yaml", Settings: map[string]Setting{
+		"bridge.mappings": {Value: "new", Origin: OriginFile},
+		"irc.nickname":    {Value: "other", Origin: OriginFile},
+	}}
+	got := old.With(reloaded, "bridge.mappings")
+	if got.Settings["bridge.mappings"].Value != "new" || got.Settings["irc.nickname"].Value != "bot" {
+		t.Errorf("With = %v, want only mappings taken from the reloaded config", got.Settings)
+	}
+	if old.Settings["bridge.mappings"].Value != "old" {
+		t.Error("With modified the receiver")
+	}
+}
@@ -2,12 +2,39 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"path"
+	"regexp"
 	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/persist"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/schema"
 )
 
+// instanceNamePattern is what instance_name may contain, so that it can be
+// typed as a command address ("@mesh-eu") and used as a metrics label.
+var instanceNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// MaxLinesLimit caps a mapping's max_lines, so one message cannot occupy the
+// rate limiter for long.
+const MaxLinesLimit = 20
+
 // Validate checks if the configuration is valid
 func Validate(cfg *Config) error {
+	if _, err := schedule.LoadLocation(cfg.Timezone); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	if cfg.InstanceName != "" && !instanceNamePattern.MatchString(cfg.InstanceName) {
+		return fmt.Errorf("instance_name must consist of letters, digits, '.', '_' and '-'")
+	}
+
+	if r := cfg.Startup.Retry; r.MaxAttempts < 0 || r.Backoff < 0 {
+		return fmt.Errorf("startup.retry.max_attempts and startup.retry.backoff must not be negative")
+	}
+
 	// MQTT validation
 	if cfg.MQTT.Broker == "" {
 		return fmt.Errorf("mqtt.broker is required")
@@ -29,10 +56,31 @@ func Validate(cfg *Config) error {
 			return fmt.Errorf("mqtt.topics[%d].qos must be 0, 1, or 2", i)
 		}
 	}
+	if p := cfg.MQTT.Probe; p.Interval > 0 {
+		if p.Timeout <= 0 || p.Timeout >= p.Interval {
+			return fmt.Errorf("mqtt.probe.timeout must be positive and shorter than mqtt.probe.interval")
+		}
+		if strings.ContainsAny(p.Topic, "+#") {
+			return fmt.Errorf("mqtt.probe.topic must not contain wildcards")
+		}
+	}
+	if cfg.MQTT.RedeliveryWindow < 0 {
+		return fmt.Errorf("mqtt.redelivery_window must not be negative")
+	}
+	if sb := cfg.MQTT.StormBreaker; sb.MaxRate < 0 {
+		return fmt.Errorf("mqtt.storm_breaker.max_rate must not be negative")
+	} else if sb.MaxRate > 0 && sb.Cooldown <= 0 {
+		return fmt.Errorf("mqtt.storm_breaker.cooldown must be positive")
+	}
 
 	// IRC validation
-	if cfg.IRC.Server == "" {
-		return fmt.Errorf("irc.server is required")
+	if cfg.IRC.Server == "" && len(cfg.IRC.Servers) == 0 && cfg.IRC.SRVDomain == "" {
+		return fmt.Errorf("irc.server, irc.servers or irc.srv_domain is required")
+	}
+	for i, server := range cfg.IRC.Servers {
+		if server == "" {
+			return fmt.Errorf("irc.servers[%d] must not be empty", i)
+		}
 	}
 	if cfg.IRC.Nickname == "" {
 		return fmt.Errorf("irc.nickname is required")
@@ -43,6 +91,48 @@ func Validate(cfg *Config) error {
 	if cfg.IRC.RateLimit.Burst <= 0 {
 		return fmt.Errorf("irc.rate_limit.burst must be positive")
 	}
+	if ka := cfg.IRC.Keepalive; ka.Interval > 0 && (ka.Timeout <= 0 || ka.Timeout >= ka.Interval) {
+		return fmt.Errorf("irc.keepalive.timeout must be positive and shorter than irc.keepalive.interval")
+	}
+	if cfg.IRC.PingDelay < 0 || cfg.IRC.StallTimeout < 0 {
+		return fmt.Errorf("irc.ping_delay and irc.stall_timeout must not be negative")
+	}
+	if st := cfg.IRC.StallTimeout; st > 0 {
+		// A healthy but quiet connection sees traffic at least every ping.
+		ping := cfg.IRC.PingDelay
+		if ping == 0 {
+			ping = 10 * time.Minute // girc's default
+		}
+		if ka := cfg.IRC.Keepalive.Interval; ka > 0 && ka < ping {
+			ping = ka
+		}
+		if st <= ping {
+			return fmt.Errorf("irc.stall_timeout must be longer than irc.ping_delay (default 10m) or irc.keepalive.interval")
+		}
+	}
+	if nr := cfg.IRC.NickReclaim; nr.Enabled {
+		switch nr.Command {
+		case "regain", "recover", "ghost", "none":
+		default:
+			return fmt.Errorf("irc.nick_reclaim.command must be regain, recover, ghost or none")
+		}
+		if nr.Interval < 0 {
+			return fmt.Errorf("irc.nick_reclaim.interval must not be negative")
+		}
+	}
+	switch id := cfg.IRC.Ident; id.Mode {
+	case "":
+	case "server":
+		if id.Listen == "" {
+			return fmt.Errorf("irc.ident.listen is required in server mode")
+		}
+	case "oidentd":
+		if id.OidentdFile == "" {
+			return fmt.Errorf("irc.ident.oidentd_file is required in oidentd mode")
+		}
+	default:
+		return fmt.Errorf("irc.ident.mode must be empty, server or oidentd")
+	}
 
 	// Bridge validation
 	if len(cfg.Bridge.Mappings) == 0 {
@@ -60,29 +150,331 @@ func Validate(cfg *Config) error {
 				return fmt.Errorf("bridge.mappings[%d].irc_channels[%d] must start with # or &", i, j)
 			}
 		}
+		if mapping.MinBytes < 0 || mapping.MaxBytes < 0 {
+			return fmt.Errorf("bridge.mappings[%d]: min_bytes and max_bytes must not be negative", i)
+		}
+		if mapping.MaxBytes > 0 && mapping.MinBytes > mapping.MaxBytes {
+			return fmt.Errorf("bridge.mappings[%d]: min_bytes must not exceed max_bytes", i)
+		}
+		if mapping.MaxLines < 0 || mapping.MaxLines > MaxLinesLimit {
+			return fmt.Errorf("bridge.mappings[%d].max_lines must be between 0 and %d", i, MaxLinesLimit)
+		}
+		if mapping.Timezone != "" {
+			if _, err := schedule.LoadLocation(mapping.Timezone); err != nil {
+				return fmt.Errorf("bridge.mappings[%d].timezone: %w", i, err)
+			}
+		}
+		if sc := mapping.Schema; sc.File != "" {
+			if _, err := schema.Load(sc.File); err != nil {
+				return fmt.Errorf("bridge.mappings[%d].schema.file: %w", i, err)
+			}
+			if strings.ContainsAny(sc.DeadLetterTopic, "+#") {
+				return fmt.Errorf("bridge.mappings[%d].schema.dead_letter_topic must not contain wildcards", i)
+			}
+		} else if sc.Drop || sc.DeadLetterTopic != "" {
+			return fmt.Errorf("bridge.mappings[%d].schema needs a file", i)
+		}
+		switch mapping.Emoji {
+		case "", "shortcodes", "unicode":
+		default:
+			return fmt.Errorf("bridge.mappings[%d].emoji must be shortcodes or unicode", i)
+		}
+		if _, ok := cfg.Bridge.TrustLevel(mapping.Trust); !ok {
+			return fmt.Errorf("bridge.mappings[%d].trust: unknown trust level %q", i, mapping.Trust)
+		}
+		if mapping.Group.Window < 0 {
+			return fmt.Errorf("bridge.mappings[%d].group.window must not be negative", i)
+		}
+		if err := validateSpam(mapping.Spam); err != nil {
+			return fmt.Errorf("bridge.mappings[%d].spam.%w", i, err)
+		}
+		if rc := mapping.ReviewChannel; rc != "" {
+			if !strings.HasPrefix(rc, "#") && !strings.HasPrefix(rc, "&") {
+				return fmt.Errorf("bridge.mappings[%d].review_channel must start with # or &", i)
+			}
+			for _, channel := range mapping.IRCChannels {
+				if strings.EqualFold(channel, rc) {
+					return fmt.Errorf("bridge.mappings[%d].review_channel must not be one of its irc_channels", i)
+				}
+			}
+		}
 	}
 	if cfg.Bridge.Queue.MaxSize <= 0 {
 		return fmt.Errorf("bridge.queue.max_size must be positive")
 	}
+	if cfg.Bridge.Queue.QoSPriority {
+		if w := cfg.Bridge.Queue.LowPriorityWatermark; w <= 0 || w > 1 {
+			return fmt.Errorf("bridge.queue.low_priority_watermark must be in (0, 1]")
+		}
+	}
 	if cfg.Bridge.MaxMessageLength <= 0 {
 		return fmt.Errorf("bridge.max_message_length must be positive")
 	}
+	if cfg.Bridge.MaxMessageBytes < 0 {
+		return fmt.Errorf("bridge.max_message_bytes must not be negative")
+	}
+	switch cfg.Bridge.TruncateMode {
+	case "", "runes", "width":
+	default:
+		return fmt.Errorf("bridge.truncate_mode must be runes or width")
+	}
+	switch cfg.Bridge.ChannelOrder {
+	case "", "config", "round_robin", "random":
+	default:
+		return fmt.Errorf("bridge.channel_order must be config, round_robin or random")
+	}
+	if cfg.Bridge.ChannelQueueSize < 0 {
+		return fmt.Errorf("bridge.channel_queue_size must not be negative")
+	}
+	if cfg.Bridge.DrainTimeout < 0 {
+		return fmt.Errorf("bridge.drain_timeout must not be negative")
+	}
+	if cfg.Bridge.HistorySize < 0 {
+		return fmt.Errorf("bridge.history_size must not be negative")
+	}
+	if sp := cfg.Bridge.StatsPublish; sp.Topic != "" {
+		if strings.ContainsAny(sp.Topic, "+#") {
+			return fmt.Errorf("bridge.stats_publish.topic must not contain wildcards")
+		}
+		if sp.Interval <= 0 {
+			return fmt.Errorf("bridge.stats_publish.interval must be positive")
+		}
+		if sp.QoS > 2 {
+			return fmt.Errorf("bridge.stats_publish.qos must be 0, 1 or 2")
+		}
+	}
+	if ha := cfg.Bridge.HomeAssistant; ha.Discovery {
+		if cfg.Bridge.StatsPublish.Topic == "" {
+			return fmt.Errorf("bridge.home_assistant.discovery requires bridge.stats_publish.topic")
+		}
+		if ha.Prefix == "" || ha.NodeID == "" {
+			return fmt.Errorf("bridge.home_assistant.prefix and node_id must not be empty")
+		}
+		if strings.ContainsAny(ha.NodeID, "+#/ ") {
+			return fmt.Errorf("bridge.home_assistant.node_id must not contain '/', '+', '#' or spaces")
+		}
+		if strings.ContainsAny(ha.CommandTopic, "+#") {
+			return fmt.Errorf("bridge.home_assistant.command_topic must not contain wildcards")
+		}
+	}
+	if strings.ContainsAny(cfg.Bridge.AckTopic, "+#") {
+		return fmt.Errorf("bridge.ack_topic must not contain wildcards")
+	}
+	if err := validateStorage(cfg.Bridge); err != nil {
+		return err
+	}
+	if strings.ContainsAny(cfg.Bridge.CapabilitiesTopic, "+#") {
+		return fmt.Errorf("bridge.capabilities_topic must not contain wildcards")
+	}
+	if cfg.Bridge.Latency.Threshold < 0 {
+		return fmt.Errorf("bridge.latency.threshold must not be negative")
+	}
+	for name, level := range cfg.Bridge.TrustLevels {
+		if name == TrustTrusted {
+			return fmt.Errorf("bridge.trust_levels: %q cannot be redefined", name)
+		}
+		if level.MaxLength < 0 {
+			return fmt.Errorf("bridge.trust_levels.%s.max_length must not be negative", name)
+		}
+		switch level.URLs {
+		case "", "keep", "strip", "mark":
+		case "wrap":
+			if level.URLWrapper == "" {
+				return fmt.Errorf("bridge.trust_levels.%s.url_wrapper is required with urls: wrap", name)
+			}
+		default:
+			return fmt.Errorf("bridge.trust_levels.%s.urls must be keep, strip, mark or wrap", name)
+		}
+	}
+	if hook := cfg.Bridge.SentHook; len(hook.Command) > 0 {
+		if hook.Command[0] == "" {
+			return fmt.Errorf("bridge.sent_hook.command must start with the program to run")
+		}
+		if hook.Timeout <= 0 {
+			return fmt.Errorf("bridge.sent_hook.timeout must be positive")
+		}
+		if hook.MaxRunning < 1 {
+			return fmt.Errorf("bridge.sent_hook.max_running must be at least 1")
+		}
+	}
+	if ts := cfg.Bridge.TopicStats; ts.MaxTopics < 0 {
+		return fmt.Errorf("bridge.topic_stats.max_topics must not be negative")
+	}
+	for i, pattern := range cfg.Bridge.TopicStats.Rollup {
+		if pattern == "" {
+			return fmt.Errorf("bridge.topic_stats.rollup[%d] must not be empty", i)
+		}
+	}
+	for i, channel := range cfg.Bridge.OpsChannels {
+		if !strings.HasPrefix(channel, "#") && !strings.HasPrefix(channel, "&") {
+			return fmt.Errorf("bridge.ops_channels[%d] must start with # or &", i)
+		}
+	}
+	if flap := cfg.Bridge.FlapDetection; flap.MaxDisconnects > 0 {
+		if flap.Window <= 0 {
+			return fmt.Errorf("bridge.flap_detection.window must be positive")
+		}
+		// Each disconnect is usually paired with a reconnect event.
+		if flap.HistorySize < 2*flap.MaxDisconnects {
+			return fmt.Errorf("bridge.flap_detection.history_size must be at least twice max_disconnects")
+		}
+	}
+	if eb := cfg.Bridge.ErrorBudget; eb.Threshold != 0 {
+		if eb.Threshold < 0 || eb.Threshold > 1 {
+			return fmt.Errorf("bridge.error_budget.threshold must be between 0 and 1")
+		}
+		if eb.Window <= 0 {
+			return fmt.Errorf("bridge.error_budget.window must be positive")
+		}
+		if eb.MinMessages < 1 {
+			return fmt.Errorf("bridge.error_budget.min_messages must be at least 1")
+		}
+		if len(eb.Reasons) == 0 {
+			return fmt.Errorf("bridge.error_budget.reasons must not be empty")
+		}
+		if len(cfg.Bridge.OpsChannels) == 0 {
+			return fmt.Errorf("bridge.error_budget requires bridge.ops_channels")
+		}
+	}
+	if cb := cfg.Bridge.CircuitBreaker; cb.Failures < 0 {
+		return fmt.Errorf("bridge.circuit_breaker.failures must not be negative")
+	} else if cb.Failures > 0 && cb.Cooldown <= 0 {
+		return fmt.Errorf("bridge.circuit_breaker.cooldown must be positive")
+	}
+	if rm := cfg.Bridge.RemovedMappings; rm.Notice != "" {
+		if _, err := template.New("notice").Parse(rm.Notice); err != nil {
+			return fmt.Errorf("bridge.removed_mappings.notice: %w", err)
+		}
+	}
+	switch cfg.Bridge.RemovedMappings.Queued {
+	case "", RemovedSend, RemovedDrop:
+	default:
+		return fmt.Errorf("bridge.removed_mappings.queued must be send or drop")
+	}
+	if f := cfg.Bridge.Faults; f.IRCSendDrop < 0 || f.IRCSendDrop > 1 {
+		return fmt.Errorf("bridge.faults.irc_send_drop must be between 0 and 1")
+	} else if f.MQTTDelay < 0 {
+		return fmt.Errorf("bridge.faults.mqtt_delay must not be negative")
+	} else if f.IRCDisconnect != "" {
+		if _, err := schedule.ParseCron(f.IRCDisconnect, nil); err != nil {
+			return fmt.Errorf("bridge.faults.irc_disconnect: %w", err)
+		}
+	}
+	for i, channel := range cfg.Bridge.Banner.Channels {
+		if !strings.HasPrefix(channel, "#") && !strings.HasPrefix(channel, "&") {
+			return fmt.Errorf("bridge.banner.channels[%d] must start with # or &", i)
+		}
+	}
+	if cfg.Bridge.Banner.MinDowntime < 0 {
+		return fmt.Errorf("bridge.banner.min_downtime must not be negative")
+	}
+	for i, p := range cfg.Bridge.Redaction.Patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("bridge.redaction.patterns[%d]: %w", i, err)
+		}
+	}
+	if cr := cfg.Bridge.ChannelRate; cr.MessagesPerMinute < 0 || (cr.MessagesPerMinute > 0 && cr.Burst <= 0) {
+		return fmt.Errorf("bridge.channel_rate.messages_per_minute must not be negative and burst must be positive")
+	}
+	if ch := cfg.Bridge.OverflowChannel; ch != "" {
+		if !strings.HasPrefix(ch, "#") && !strings.HasPrefix(ch, "&") {
+			return fmt.Errorf("bridge.overflow_channel must start with # or &")
+		}
+		if cfg.Bridge.ChannelRate.MessagesPerMinute <= 0 {
+			return fmt.Errorf("bridge.overflow_channel requires bridge.channel_rate.messages_per_minute")
+		}
+	}
+	for i, a := range cfg.Bridge.TopicAliases {
+		if (a.Topic == "") == (a.Regex == "") {
+			return fmt.Errorf("bridge.topic_aliases[%d] needs exactly one of topic and regex", i)
+		}
+		if a.Canonical == "" || strings.ContainsAny(a.Canonical, "+#") {
+			return fmt.Errorf("bridge.topic_aliases[%d].canonical must be a topic without wildcards", i)
+		}
+		if a.Regex != "" {
+			if _, err := regexp.Compile(a.Regex); err != nil {
+				return fmt.Errorf("bridge.topic_aliases[%d].regex: %w", i, err)
+			}
+		}
+	}
+	if rm := cfg.Bridge.RemoteMappings; rm.URL != "" || rm.MQTTTopic != "" {
+		if rm.URL != "" && rm.MQTTTopic != "" {
+			return fmt.Errorf("bridge.remote_mappings needs only one of url and mqtt_topic")
+		}
+		if rm.URL != "" && !strings.HasPrefix(rm.URL, "http://") && !strings.HasPrefix(rm.URL, "https://") {
+			return fmt.Errorf("bridge.remote_mappings.url must be an http or https URL")
+		}
+		if rm.URL != "" && rm.Interval <= 0 {
+			return fmt.Errorf("bridge.remote_mappings.interval must be positive")
+		}
+		if strings.ContainsAny(rm.MQTTTopic, "+#") {
+			return fmt.Errorf("bridge.remote_mappings.mqtt_topic must not contain wildcards")
+		}
+	}
+	if am := cfg.Bridge.Alertmanager; am.URL != "" {
+		if !strings.HasPrefix(am.URL, "http://") && !strings.HasPrefix(am.URL, "https://") {
+			return fmt.Errorf("bridge.alertmanager.url must be an http or https URL")
+		}
+		if am.Interval <= 0 {
+			return fmt.Errorf("bridge.alertmanager.interval must be positive")
+		}
+		if len(am.Channels) == 0 {
+			return fmt.Errorf("bridge.alertmanager.channels must not be empty")
+		}
+		for i, channel := range am.Channels {
+			if !strings.HasPrefix(channel, "#") && !strings.HasPrefix(channel, "&") {
+				return fmt.Errorf("bridge.alertmanager.channels[%d] must start with # or &", i)
+			}
+		}
+	}
+	for i, hb := range cfg.Bridge.Heartbeats {
+		if hb.Name == "" {
+			return fmt.Errorf("bridge.heartbeats[%d].name is required", i)
+		}
+		if hb.MQTTTopic == "" {
+			return fmt.Errorf("bridge.heartbeats[%d].mqtt_topic is required", i)
+		}
+		if hb.Timeout <= 0 {
+			return fmt.Errorf("bridge.heartbeats[%d].timeout must be positive", i)
+		}
+		if hb.Mode != "" && hb.Mode != "message" && hb.Mode != "topic" {
+			return fmt.Errorf("bridge.heartbeats[%d].mode must be one of: message, topic", i)
+		}
+		if len(hb.IRCChannels) == 0 {
+			return fmt.Errorf("bridge.heartbeats[%d].irc_channels must have at least one channel", i)
+		}
+		for j, channel := range hb.IRCChannels {
+			if !strings.HasPrefix(channel, "#") && !strings.HasPrefix(channel, "&") {
+				return fmt.Errorf("bridge.heartbeats[%d].irc_channels[%d] must start with # or &", i, j)
+			}
+		}
+	}
 
 	// Logging validation
 	validLevels := map[string]bool{"trace": true, "debug": true, "info": true, "warn": true, "error": true, "fatal": true, "panic": true}
 	if !validLevels[cfg.Logging.Level] {
 		return fmt.Errorf("logging.level must be one of: trace, debug, info, warn, error, fatal, panic")
 	}
+	if p := cfg.Logging.Payloads; p.Sample < 0 || p.MaxPerMinute < 0 || p.MaxBytes < 0 {
+		return fmt.Errorf("logging.payloads.sample, max_per_minute and max_bytes must not be negative")
+	}
 
 	// Health validation
-	if cfg.Health.Enabled && (cfg.Health.Port <= 0 || cfg.Health.Port > 65535) {
+	if cfg.Health.Enabled && cfg.Health.Socket == "" && (cfg.Health.Port <= 0 || cfg.Health.Port > 65535) {
 		return fmt.Errorf("health.port must be between 1 and 65535")
 	}
+	if err := validateHealth(cfg.Health); err != nil {
+		return err
+	}
+
+	if err := validateTenants(cfg); err != nil {
+		return err
+	}
 
 	// Admin validation
 	if cfg.Admin.Enabled {
-		if len(cfg.Admin.AllowList) == 0 {
-			return fmt.Errorf("admin.allow_list must be non-empty when admin is enabled")
+		if err := validateAdminAuth(cfg.Admin); err != nil {
+			return err
 		}
 		for i, entry := range cfg.Admin.AllowList {
 			if entry.Nick == "" {
@@ -97,7 +489,212 @@ func Validate(cfg *Config) error {
 		if len(cfg.Admin.Channels) == 0 && !cfg.Admin.AcceptPM {
 			return fmt.Errorf("admin must have at least one channel or accept_pm: true")
 		}
+		switch cfg.Admin.ReplyMode {
+		case "", "channel", "notice", "pm":
+		default:
+			return fmt.Errorf("admin.reply_mode must be channel, notice or pm")
+		}
+		switch cfg.Admin.Language {
+		case "", "en", "de":
+		default:
+			return fmt.Errorf("admin.language must be en or de")
+		}
+		if cfg.Admin.FlowTimeout < 0 {
+			return fmt.Errorf("admin.flow_timeout must not be negative")
+		}
+		for _, name := range cfg.Admin.PublicCommands {
+			if name != "bridge" {
+				return fmt.Errorf("admin.public_commands: unknown command %q (available: bridge)", name)
+			}
+		}
+		for name, text := range map[string]string{"status": cfg.Admin.Templates.Status, "help": cfg.Admin.Templates.Help} {
+			if _, err := template.New(name).Parse(text); err != nil {
+				return fmt.Errorf("admin.templates.%s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateAdminAuth checks admin.auth and that every provider it uses is
+// configured.
+func validateAdminAuth(cfg AdminConfig) error {
+	seen := make(map[string]bool)
+	for _, p := range cfg.Auth.AuthProviders() {
+		if seen[p] {
+			return fmt.Errorf("admin.auth.providers lists %s twice", p)
+		}
+		seen[p] = true
+		switch p {
+		case "allow_list":
+			if len(cfg.AllowList) == 0 {
+				return fmt.Errorf("admin.allow_list must be non-empty when admin is enabled")
+			}
+		case "account":
+			if len(cfg.Auth.Accounts) == 0 {
+				return fmt.Errorf("admin.auth.accounts must be non-empty for the account provider")
+			}
+		case "oper":
+		case "webhook":
+			wh := cfg.Auth.Webhook
+			if !strings.HasPrefix(wh.URL, "http://") && !strings.HasPrefix(wh.URL, "https://") {
+				return fmt.Errorf("admin.auth.webhook.url must be an http or https URL")
+			}
+			if wh.Timeout < 0 {
+				return fmt.Errorf("admin.auth.webhook.timeout must not be negative")
+			}
+		default:
+			return fmt.Errorf("admin.auth.providers: unknown provider %q (allow_list, account, oper or webhook)", p)
+		}
 	}
+	return nil
+}
 
+// validateTenants checks tenant definitions and that tenant-owned mappings only
+// post to channels of their tenant.
+func validateTenants(cfg *Config) error {
+	tenantChannels := make(map[string][]string, len(cfg.Tenants))
+	for i, t := range cfg.Tenants {
+		if t.Name == "" {
+			return fmt.Errorf("tenants[%d].name is required", i)
+		}
+		if _, dup := tenantChannels[t.Name]; dup {
+			return fmt.Errorf("tenants[%d]: duplicate tenant name %q", i, t.Name)
+		}
+		if len(t.Channels) == 0 {
+			return fmt.Errorf("tenants[%d].channels must have at least one channel", i)
+		}
+		for j, channel := range t.Channels {
+			if !strings.HasPrefix(channel, "#") && !strings.HasPrefix(channel, "&") {
+				return fmt.Errorf("tenants[%d].channels[%d] must start with # or &", i, j)
+			}
+		}
+		for j, op := range t.Operators {
+			if op.Nick == "" {
+				return fmt.Errorf("tenants[%d].operators[%d].nick is required", i, j)
+			}
+			if op.Hostmask != "" {
+				if _, err := path.Match(op.Hostmask, ""); err != nil {
+					return fmt.Errorf("tenants[%d].operators[%d].hostmask is invalid: %w", i, j, err)
+				}
+			}
+		}
+		if t.Quota.MessagesPerDay < 0 || t.Quota.MaxMappings < 0 {
+			return fmt.Errorf("tenants[%d].quota values must not be negative", i)
+		}
+		tenantChannels[t.Name] = t.Channels
+	}
+
+	mappingCount := make(map[string]int, len(cfg.Tenants))
+	for i, mapping := range cfg.Bridge.Mappings {
+		if mapping.Tenant == "" {
+			continue
+		}
+		channels, ok := tenantChannels[mapping.Tenant]
+		if !ok {
+			return fmt.Errorf("bridge.mappings[%d].tenant: unknown tenant %q", i, mapping.Tenant)
+		}
+		mappingCount[mapping.Tenant]++
+		for _, channel := range mapping.IRCChannels {
+			if !containsFold(channels, channel) {
+				return fmt.Errorf("bridge.mappings[%d]: channel %s is not a channel of tenant %q", i, channel, mapping.Tenant)
+			}
+		}
+	}
+
+	for _, t := range cfg.Tenants {
+		if n := mappingCount[t.Name]; t.Quota.MaxMappings > 0 && n > t.Quota.MaxMappings {
+			return fmt.Errorf("tenant %q has %d mappings, quota allows %d", t.Name, n, t.Quota.MaxMappings)
+		}
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHealth checks the health server's address, auth, rate limit and
+// TLS settings.
+func validateHealth(h HealthConfig) error {
+	if h.Address != "" && net.ParseIP(h.Address) == nil && strings.ContainsAny(h.Address, ":/ ") {
+		return fmt.Errorf("health.address %q must be an IP address or host name", h.Address)
+	}
+	if (h.Auth.Username == "") != (h.Auth.Password == "") {
+		return fmt.Errorf("health.auth.username and health.auth.password must be set together")
+	}
+	for i, p := range h.Auth.PublicPaths {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("health.auth.public_paths[%d] must start with /", i)
+		}
+	}
+	if h.RateLimit.RequestsPerSecond < 0 {
+		return fmt.Errorf("health.rate_limit.requests_per_second must not be negative")
+	}
+	if h.RateLimit.RequestsPerSecond > 0 && h.RateLimit.Burst < 1 {
+		return fmt.Errorf("health.rate_limit.burst must be at least 1")
+	}
+	if (h.TLS.CertFile == "") != (h.TLS.KeyFile == "") {
+		return fmt.Errorf("health.tls.cert_file and health.tls.key_file must be set together")
+	}
+	if h.TLS.ClientCAFile != "" && h.TLS.CertFile == "" {
+		return fmt.Errorf("health.tls.client_ca_file requires health.tls.cert_file and key_file")
+	}
+	return nil
+}
+
+// validateStorage checks bridge.storage.
+func validateStorage(cfg BridgeConfig) error {
+	stores := []struct {
+		name string
+		cfg  StoreConfig
+	}{{"node_db", cfg.Storage.NodeDB}, {"history", cfg.Storage.History}, {"queue", cfg.Storage.Queue}}
+	for _, st := range stores {
+		switch st.cfg.Mode {
+		case "", persist.ModeFile, persist.ModeMemory:
+		default:
+			return fmt.Errorf("bridge.storage.%s.mode must be file or memory", st.name)
+		}
+		if st.cfg.SnapshotInterval < 0 {
+			return fmt.Errorf("bridge.storage.%s.snapshot_interval must not be negative", st.name)
+		}
+	}
+	if cfg.Storage.NodeDB.File != "" {
+		return fmt.Errorf("bridge.storage.node_db.file is not used: set node_db in the processor_config of the meshtastic mappings")
+	}
+	if cfg.Storage.History.File != "" && cfg.HistorySize <= 0 {
+		return fmt.Errorf("bridge.storage.history.file requires bridge.history_size")
+	}
+	return nil
+}
+
+// validateSpam checks a mapping's spam heuristics; errors name the key
+// within spam.
+func validateSpam(s SpamConfig) error {
+	if s.RepeatSenders < 0 || s.MinLetters < 0 {
+		return fmt.Errorf("repeat_senders and min_letters must not be negative")
+	}
+	if s.RepeatWindow < 0 || s.Delay < 0 {
+		return fmt.Errorf("repeat_window and delay must not be negative")
+	}
+	if s.MaxCaps < 0 || s.MaxCaps >= 1 {
+		return fmt.Errorf("max_caps must be a share between 0 and 1, got %g", s.MaxCaps)
+	}
+	for _, p := range s.Patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("patterns: %q: %v", p, err)
+		}
+	}
+	switch s.Action {
+	case "", SpamDrop, SpamDelay, SpamNotify:
+	default:
+		return fmt.Errorf("action must be drop, delay or notify")
+	}
 	return nil
 }
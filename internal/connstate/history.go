@@ -0,0 +1,146 @@
+// Package connstate tracks connection state transitions for the bridge's
+// upstream connections and detects flapping.
+package connstate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// Event is a single connect or disconnect transition.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Connected bool      `json:"connected"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// FlapPolicy defines when a connection is considered to be flapping.
+// Detection is disabled when MaxDisconnects is zero.
+type FlapPolicy struct {
+	MaxDisconnects int           // disconnects within Window that mark the connection as flapping
+	Window         time.Duration // sliding window for counting disconnects
+	Backoff        time.Duration // extra delay before reconnect attempts while flapping
+}
+
+// History keeps the last N connection events for one connection (e.g. "mqtt")
+// and evaluates the flap policy on every recorded disconnect.
+type History struct {
+	mu       sync.Mutex
+	name     string
+	size     int
+	events   []Event
+	policy   FlapPolicy
+	onFlap   func(name string, disconnects int)
+	flapping bool
+	clock    schedule.Clock
+}
+
+// New creates a History that retains at most size events.
+func New(name string, size int) *History {
+	if size <= 0 {
+		size = 20
+	}
+	return &History{
+		name:  name,
+		size:  size,
+		clock: schedule.Real,
+	}
+}
+
+// SetClock replaces the time source (for tests).
+func (h *History) SetClock(c schedule.Clock) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clock = c
+}
+
+// Name returns the connection name this history belongs to.
+func (h *History) Name() string {
+	return h.name
+}
+
+// SetFlapPolicy enables flap detection. onFlap (optional) is called once each
+// time the connection transitions into the flapping state.
+func (h *History) SetFlapPolicy(p FlapPolicy, onFlap func(name string, disconnects int)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.policy = p
+	h.onFlap = onFlap
+}
+
+// RecordUp records a successful connection.
+func (h *History) RecordUp(detail string) {
+	h.record(Event{Time: h.clock.Now(), Connected: true, Detail: detail})
+}
+
+// RecordDown records a lost connection and evaluates the flap policy.
+func (h *History) RecordDown(detail string) {
+	h.record(Event{Time: h.clock.Now(), Connected: false, Detail: detail})
+}
+
+func (h *History) record(ev Event) {
+	h.mu.Lock()
+	h.events = append(h.events, ev)
+	if len(h.events) > h.size {
+		h.events = h.events[len(h.events)-h.size:]
+	}
+
+	var notify func(string, int)
+	var count int
+	if !ev.Connected && h.policy.MaxDisconnects > 0 {
+		count = h.disconnectsLocked(ev.Time)
+		if count >= h.policy.MaxDisconnects && !h.flapping {
+			h.flapping = true
+			notify = h.onFlap
+		}
+	}
+	h.mu.Unlock()
+
+	if notify != nil {
+		notify(h.name, count)
+	}
+}
+
+// disconnectsLocked counts disconnect events within the policy window ending at now.
+func (h *History) disconnectsLocked(now time.Time) int {
+	cutoff := now.Add(-h.policy.Window)
+	n := 0
+	for _, ev := range h.events {
+		if !ev.Connected && !ev.Time.Before(cutoff) {
+			n++
+		}
+	}
+	return n
+}
+
+// Events returns a copy of the recorded events, oldest first.
+func (h *History) Events() []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]Event, len(h.events))
+	copy(out, h.events)
+	return out
+}
+
+// Flapping reports whether the connection is currently flapping.
+// The state clears once the disconnect count within the window drops below the threshold.
+func (h *History) Flapping() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.flapping && h.disconnectsLocked(h.clock.Now()) < h.policy.MaxDisconnects {
+		h.flapping = false
+	}
+	return h.flapping
+}
+
+// Backoff returns the extra reconnect delay to apply, or zero when not flapping.
+func (h *History) Backoff() time.Duration {
+	if !h.Flapping() {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.policy.Backoff
+}
@@ -0,0 +1,84 @@
+package connstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// fakeClock installs a controllable time source in h.
+func fakeClock(h *History, start time.Time) *schedule.Fake {
+	clk := schedule.NewFake(start)
+	h.SetClock(clk)
+	return clk
+}
+
+func TestHistory_RingSize(t *testing.T) {
+	h := New("mqtt", 3)
+	for i := 0; i < 5; i++ {
+		h.RecordUp("")
+		h.RecordDown("")
+	}
+	if got := len(h.Events()); got != 3 {
+		t.Fatalf("expected 3 events retained, got %d", got)
+	}
+	if h.Events()[2].Connected {
+		t.Error("expected newest event to be the last disconnect")
+	}
+}
+
+func TestHistory_FlapDetection(t *testing.T) {
+	h := New("irc", 20)
+	clk := fakeClock(h, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	notified := 0
+	h.SetFlapPolicy(FlapPolicy{MaxDisconnects: 3, Window: 5 * time.Minute, Backoff: time.Minute},
+		func(name string, n int) {
+			notified++
+			if name != "irc" || n != 3 {
+				t.Errorf("onFlap(%q, %d), want (irc, 3)", name, n)
+			}
+		})
+
+	for i := 0; i < 3; i++ {
+		h.RecordDown("lost")
+		h.RecordUp("")
+		clk.Advance(time.Minute)
+	}
+	if !h.Flapping() {
+		t.Fatal("expected flapping after 3 disconnects within window")
+	}
+	if h.Backoff() != time.Minute {
+		t.Errorf("Backoff() = %v, want 1m", h.Backoff())
+	}
+
+	// A further disconnect while already flapping must not notify again.
+	h.RecordDown("lost")
+	if notified != 1 {
+		t.Errorf("expected a single notification, got %d", notified)
+	}
+
+	// Once old disconnects age out of the window, flapping clears.
+	clk.Advance(10 * time.Minute)
+	if h.Flapping() {
+		t.Error("expected flapping to clear after window elapsed")
+	}
+	if h.Backoff() != 0 {
+		t.Errorf("Backoff() = %v, want 0 when not flapping", h.Backoff())
+	}
+}
+
+func TestHistory_SpreadDisconnectsNotFlapping(t *testing.T) {
+	h := New("mqtt", 20)
+	clk := fakeClock(h, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	h.SetFlapPolicy(FlapPolicy{MaxDisconnects: 3, Window: 5 * time.Minute}, nil)
+
+	for i := 0; i < 5; i++ {
+		h.RecordDown("lost")
+		clk.Advance(3 * time.Minute)
+	}
+	if h.Flapping() {
+		t.Error("disconnects spread beyond the window should not count as flapping")
+	}
+}
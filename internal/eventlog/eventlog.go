@@ -0,0 +1,76 @@
+// Package eventlog writes one JSON record per message outcome (sent to a
+// channel or dropped), for ingestion into log pipelines such as Loki or
+// Elastic. It is separate from the human-oriented application log.
+package eventlog
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outcomes of a message.
+const (
+	OutcomeSent    = "sent"
+	OutcomeDropped = "dropped"
+)
+
+// Event is one record of the event log.
+type Event struct {
+	Time         time.Time `json:"time"`
+	MsgID        string    `json:"msg_id,omitempty"`
+	Topic        string    `json:"topic"`
+	Channel      string    `json:"channel,omitempty"`
+	Outcome      string    `json:"outcome"`          // OutcomeSent or OutcomeDropped
+	Reason       string    `json:"reason,omitempty"` // drop reason, see stats.DropReason
+	PayloadBytes int       `json:"payload_bytes"`
+	TextBytes    int       `json:"text_bytes,omitempty"` // of the line sent to IRC
+	LatencyMS    float64   `json:"latency_ms"`           // from receipt to outcome
+}
+
+// Log writes events as JSON lines. A nil *Log writes nothing.
+type Log struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer // nil for stdout
+}
+
+// Open opens the event log at path for appending; "stdout" writes to the
+// standard output.
+func Open(path string) (*Log, error) {
+	if path == "stdout" {
+		return New(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := New(f)
+	l.c = f
+	return l, nil
+}
+
+// New returns an event log writing to w.
+func New(w io.Writer) *Log {
+	return &Log{enc: json.NewEncoder(w)}
+}
+
+// Write appends e to the log.
+func (l *Log) Write(e Event) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(e)
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	if l == nil || l.c == nil {
+		return nil
+	}
+	return l.c.Close()
+}
@@ -0,0 +1,56 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLog_Write(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf)
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.Write(Event{Time: at, MsgID: "3f9a01c2", Topic: "a/b", Channel: "#c", Outcome: OutcomeSent, PayloadBytes: 10, TextBytes: 12, LatencyMS: 1.5}) //nolint:errcheck
+	l.Write(Event{Time: at, Topic: "a/b", Outcome: OutcomeDropped, Reason: "mute", PayloadBytes: 10})                                               //nolint:errcheck
+
+	want := `{"time":"2026-01-01T12:00:00Z","msg_id":"3f9a01c2","topic":"a/b","channel":"#c","outcome":"sent","payload_bytes":10,"text_bytes":12,"latency_ms":1.5}
+{"time":"2026-01-01T12:00:00Z","topic":"a/b","outcome":"dropped","reason":"mute","payload_bytes":10,"latency_ms":0}
+`
+	if buf.String() != want {
+		t.Errorf("log =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	var none *Log
+	if err := none.Write(Event{}); err != nil || none.Close() != nil {
+		t.Error("nil log should write nothing")
+	}
+}
+
+func TestOpen_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	for i := 0; i < 2; i++ {
+		l, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		l.Write(Event{Topic: "t", Outcome: OutcomeSent}) //nolint:errcheck
+		if err := l.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want 2", len(lines))
+	}
+	var e Event
+	if err := json.Unmarshal(lines[1], &e); err != nil || e.Outcome != OutcomeSent {
+		t.Errorf("line = %s, %v", lines[1], err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package expr evaluates simple conditions on JSON payload fields, such as
+// "temperature > 30" or "sensor.state == open". Fields are dotted paths into
+// nested objects. Ordering operators compare numbers; == and != compare
+// numbers when both sides are numbers and text otherwise; ~ and !~ match a
+// regular expression against the field's text.
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Ops lists the supported operators.
+var Ops = []string{"==", "!=", "<", "<=", ">", ">=", "~", "!~"}
+
+// Cond is a compiled condition on one field.
+type Cond struct {
+	field  string
+	path   []string
+	op     string
+	value  string
+	number float64
+	isNum  bool
+	re     *regexp.Regexp
+}
+
+// Compile compiles the condition "field op value".
+func Compile(field, op, value string) (*Cond, error) {
+	if field == "" {
+		return nil, fmt.Errorf("empty field")
+	}
+	c := &Cond{field: field, path: strings.Split(field, "."), op: op, value: value}
+	c.number, c.isNum = parseNumber(value)
+	switch op {
+	case "==", "!=":
+	case "<", "<=", ">", ">=":
+		if !c.isNum {
+			return nil, fmt.Errorf("%s needs a number, got %q", op, value)
+		}
+	case "~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		c.re = re
+	default:
+		return nil, fmt.Errorf("unknown operator %q (supported: %s)", op, strings.Join(Ops, " "))
+	}
+	return c, nil
+}
+
+// String returns the condition as written, e.g. "temperature > 30".
+func (c *Cond) String() string {
+	return c.field + " " + c.op + " " + c.value
+}
+
+// Field returns the field the condition tests.
+func (c *Cond) Field() string { return c.field }
+
+// Eval tests the condition on a decoded JSON object and returns the field's
+// value as text. A missing field, or an object or list, matches nothing.
+func (c *Cond) Eval(doc map[string]interface{}) (value string, ok bool) {
+	v, found := lookup(doc, c.path)
+	if !found {
+		return "", false
+	}
+	var n float64
+	isNum := false
+	switch v := v.(type) {
+	case float64:
+		n, isNum = v, true
+		value = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		value = v
+		n, isNum = parseNumber(v)
+	case bool:
+		value = strconv.FormatBool(v)
+	case nil:
+		value = "null"
+	default:
+		return "", false
+	}
+
+	switch c.op {
+	case "==", "!=":
+		equal := value == c.value
+		if isNum && c.isNum {
+			equal = n == c.number
+		}
+		return value, equal == (c.op == "==")
+	case "~":
+		return value, c.re.MatchString(value)
+	case "!~":
+		return value, !c.re.MatchString(value)
+	}
+	if !isNum {
+		return value, false
+	}
+	switch c.op {
+	case "<":
+		return value, n < c.number
+	case "<=":
+		return value, n <= c.number
+	case ">":
+		return value, n > c.number
+	default: // ">="
+		return value, n >= c.number
+	}
+}
+
+// lookup follows path through nested objects.
+func lookup(doc map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = doc
+	for _, name := range path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func parseNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f, err == nil
+}
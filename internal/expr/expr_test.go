@@ -0,0 +1,51 @@
+package expr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCond_Eval(t *testing.T) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"temperature": 31.5, "state": "open", "battery": "15", "ok": true, "sensor": {"rssi": -92}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		field, op, value string
+		want             bool
+		got              string
+	}{
+		{"temperature", ">", "30", true, "31.5"},
+		{"temperature", "<=", "31.5", true, "31.5"},
+		{"temperature", "<", "30", false, "31.5"},
+		{"temperature", "==", "31.50", true, "31.5"}, // numerically
+		{"state", "==", "open", true, "open"},
+		{"state", "!=", "open", false, "open"},
+		{"state", ">", "1", false, "open"},
+		{"battery", "<", "20", true, "15"}, // numeric string
+		{"ok", "==", "true", true, "true"},
+		{"sensor.rssi", "<", "-90", true, "-92"},
+		{"state", "~", "^op", true, "open"},
+		{"state", "!~", "clos", true, "open"},
+		{"missing", "!=", "x", false, ""},
+		{"sensor", "==", "x", false, ""},
+	}
+	for _, tt := range tests {
+		c, err := Compile(tt.field, tt.op, tt.value)
+		if err != nil {
+			t.Fatalf("Compile(%s %s %s): %v", tt.field, tt.op, tt.value, err)
+		}
+		got, ok := c.Eval(doc)
+		if ok != tt.want || got != tt.got {
+			t.Errorf("%s = %q, %v; want %q, %v", c, got, ok, tt.got, tt.want)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, c := range [][3]string{{"", "==", "x"}, {"a", "=", "x"}, {"a", ">", "high"}, {"a", "~", "("}} {
+		if _, err := Compile(c[0], c[1], c[2]); err == nil {
+			t.Errorf("Compile(%q) succeeded", c)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package geo reverse-geocodes coordinates into place names ("Budapest, HU")
+// for processors that post positions. Lookups never block on a busy or
+// unreachable service: they return no place instead.
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Place is a named location near a position.
+type Place struct {
+	Name    string
+	Country string // ISO 3166-1 alpha-2 code, upper case; may be empty
+}
+
+// String returns "Name, CC" (or just the name without a country).
+func (p Place) String() string {
+	if p.Country == "" {
+		return p.Name
+	}
+	return p.Name + ", " + p.Country
+}
+
+// Geocoder resolves coordinates (degrees) to the nearest known place.
+type Geocoder interface {
+	Reverse(lat, lon float64) (Place, bool)
+}
+
+// Config selects and configures a geocoder; it is parsed from a processor's
+// reverse_geocode map.
+type Config struct {
+	Provider    string        // "offline" or "nominatim"
+	Dataset     string        // offline: CSV of name,country,lat,lon
+	MaxDistance float64       // offline: metres; farther places are not reported
+	URL         string        // nominatim: base URL
+	UserAgent   string        // nominatim: required by the public service's usage policy
+	Rate        float64       // nominatim: requests per second
+	Timeout     time.Duration // nominatim: per request
+	CacheSize   int           // resolved positions kept (both providers)
+}
+
+// ParseConfig reads a reverse_geocode map from a processor config.
+func ParseConfig(m map[string]interface{}) (Config, error) {
+	cfg := Config{
+		Provider:    "offline",
+		MaxDistance: 50000,
+		URL:         "https://nominatim.openstreetmap.org",
+		UserAgent:   "mqtt2irc",
+		Rate:        1,
+		Timeout:     2 * time.Second,
+		CacheSize:   1000,
+	}
+	str := func(key string, dst *string) {
+		if v, ok := m[key]; ok {
+			*dst = fmt.Sprintf("%v", v)
+		}
+	}
+	num := func(key string, dst *float64) error {
+		v, ok := m[key]
+		if !ok {
+			return nil
+		}
+		f, err := strconv.ParseFloat(fmt.Sprintf("%v", v), 64)
+		if err != nil || f <= 0 {
+			return fmt.Errorf("reverse_geocode.%s must be a positive number", key)
+		}
+		*dst = f
+		return nil
+	}
+
+	str("provider", &cfg.Provider)
+	str("dataset", &cfg.Dataset)
+	str("url", &cfg.URL)
+	str("user_agent", &cfg.UserAgent)
+	km := cfg.MaxDistance / 1000
+	if err := num("max_distance_km", &km); err != nil {
+		return cfg, err
+	}
+	cfg.MaxDistance = km * 1000
+	if err := num("rate", &cfg.Rate); err != nil {
+		return cfg, err
+	}
+	if v, ok := m["timeout"]; ok {
+		d, err := time.ParseDuration(fmt.Sprintf("%v", v))
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("reverse_geocode.timeout must be a positive duration")
+		}
+		cfg.Timeout = d
+	}
+	if v, ok := m["cache_size"]; ok {
+		n, err := strconv.Atoi(fmt.Sprintf("%v", v))
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("reverse_geocode.cache_size must not be negative")
+		}
+		cfg.CacheSize = n
+	}
+
+	switch cfg.Provider {
+	case "offline":
+		if cfg.Dataset == "" {
+			return cfg, fmt.Errorf("reverse_geocode.dataset is required for the offline provider")
+		}
+	case "nominatim":
+		if cfg.URL == "" || cfg.UserAgent == "" {
+			return cfg, fmt.Errorf("reverse_geocode.url and user_agent are required for the nominatim provider")
+		}
+	default:
+		return cfg, fmt.Errorf("reverse_geocode.provider must be offline or nominatim, got %q", cfg.Provider)
+	}
+	return cfg, nil
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   = make(map[string]Geocoder)
+)
+
+// New returns the geocoder for cfg. Geocoders are shared between processors
+// with the same configuration, so the dataset is loaded once and a service's
+// rate limit holds for the whole bridge.
+func New(cfg Config) (Geocoder, error) {
+	key := fmt.Sprintf("%+v", cfg)
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if g, ok := shared[key]; ok {
+		return g, nil
+	}
+
+	var r resolver
+	switch cfg.Provider {
+	case "offline":
+		o, err := loadOffline(cfg.Dataset, cfg.MaxDistance)
+		if err != nil {
+			return nil, err
+		}
+		r = o
+	case "nominatim":
+		r = newNominatim(cfg.URL, cfg.UserAgent, cfg.Rate, cfg.Timeout)
+	default:
+		return nil, fmt.Errorf("unknown reverse geocoding provider %q", cfg.Provider)
+	}
+	g := newCached(r, cfg.CacheSize)
+	shared[key] = g
+	return g, nil
+}
+
+// resolver is implemented by the providers. err is set when the lookup
+// could not be made (rate limited, service error), as opposed to a position
+// with no place nearby.
+type resolver interface {
+	resolve(lat, lon float64) (Place, bool, error)
+}
+
+// uncached adapts a resolver to Geocoder.
+type uncached struct{ r resolver }
+
+func (u uncached) Reverse(lat, lon float64) (Place, bool) {
+	place, ok, err := u.r.resolve(lat, lon)
+	return place, ok && err == nil
+}
+
+// cacheKey rounds a position to about 1 km, so nearby positions of a moving
+// node share a lookup.
+func cacheKey(lat, lon float64) [2]int32 {
+	return [2]int32{int32(math.Round(lat * 100)), int32(math.Round(lon * 100))}
+}
+
+// cached remembers the results (including misses) of a resolver, evicting
+// the oldest entry when full.
+type cached struct {
+	mu    sync.Mutex
+	r     resolver
+	size  int
+	order [][2]int32
+	known map[[2]int32]cacheEntry
+}
+
+type cacheEntry struct {
+	place Place
+	ok    bool
+}
+
+func newCached(r resolver, size int) Geocoder {
+	if size <= 0 {
+		return uncached{r}
+	}
+	return &cached{r: r, size: size, known: make(map[[2]int32]cacheEntry)}
+}
+
+// Reverse implements Geocoder. Failed lookups are not cached, so they are
+// retried later.
+func (c *cached) Reverse(lat, lon float64) (Place, bool) {
+	key := cacheKey(lat, lon)
+	c.mu.Lock()
+	if e, ok := c.known[key]; ok {
+		c.mu.Unlock()
+		return e.place, e.ok
+	}
+	c.mu.Unlock()
+
+	place, ok, err := c.r.resolve(lat, lon)
+	if err != nil {
+		return Place{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, seen := c.known[key]; !seen {
+		if len(c.order) >= c.size {
+			delete(c.known, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.known[key] = cacheEntry{place: place, ok: ok}
+	return place, ok
+}
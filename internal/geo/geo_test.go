@@ -0,0 +1,143 @@
+package geo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func writeDataset(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "places.csv")
+	data := "# name,country,lat,lon\nBudapest,HU,47.4979,19.0402\nVienna,AT,48.2082,16.3738\n\"Szeged, city\",HU,46.253,20.1414\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOffline(t *testing.T) {
+	o, err := loadOffline(writeDataset(t), 50000)
+	if err != nil {
+		t.Fatalf("loadOffline: %v", err)
+	}
+	tests := []struct {
+		lat, lon float64
+		want     string
+		ok       bool
+	}{
+		{47.53, 19.05, "Budapest, HU", true},
+		{48.1, 16.3, "Vienna, AT", true},
+		{46.26, 20.15, "Szeged, city, HU", true},
+		{45.0, 25.0, "", false}, // nothing within 50 km
+	}
+	for _, tt := range tests {
+		place, ok, err := o.resolve(tt.lat, tt.lon)
+		if err != nil || ok != tt.ok || place.String() != tt.want {
+			t.Errorf("resolve(%v, %v) = %q, %v, %v; want %q, %v", tt.lat, tt.lon, place, ok, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig(map[string]interface{}{"dataset": "places.csv", "max_distance_km": 20})
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.Provider != "offline" || cfg.MaxDistance != 20000 || cfg.CacheSize != 1000 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	bad := []map[string]interface{}{
+		{},                                   // offline without dataset
+		{"provider": "google"},               // unknown provider
+		{"provider": "nominatim", "rate": 0}, // rate must be positive
+		{"provider": "nominatim", "timeout": "soon"},
+	}
+	for _, m := range bad {
+		if _, err := ParseConfig(m); err == nil {
+			t.Errorf("ParseConfig(%v): expected error", m)
+		}
+	}
+}
+
+// countingResolver returns a fixed place, or an error when failing is set.
+type countingResolver struct {
+	calls   int
+	failing bool
+}
+
+func (c *countingResolver) resolve(lat, lon float64) (Place, bool, error) {
+	c.calls++
+	if c.failing {
+		return Place{}, false, fmt.Errorf("unavailable")
+	}
+	return Place{Name: "Budapest", Country: "HU"}, true, nil
+}
+
+func TestCached(t *testing.T) {
+	r := &countingResolver{failing: true}
+	g := newCached(r, 2)
+
+	if _, ok := g.Reverse(47.5, 19.04); ok {
+		t.Error("failed lookup must not report a place")
+	}
+	r.failing = false
+	if p, ok := g.Reverse(47.5, 19.04); !ok || p.Name != "Budapest" {
+		t.Errorf("Reverse = %v, %v; want Budapest", p, ok)
+	}
+	g.Reverse(47.501, 19.041) // same ~1 km cell
+	if r.calls != 2 {
+		t.Errorf("resolver called %d times, want 2 (failures are retried, hits cached)", r.calls)
+	}
+
+	g.Reverse(10, 10)
+	g.Reverse(20, 20) // evicts 47.5,19.04
+	g.Reverse(47.5, 19.04)
+	if r.calls != 5 {
+		t.Errorf("resolver called %d times, want 5 after eviction", r.calls)
+	}
+}
+
+func TestNominatim(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/reverse" || r.Header.Get("User-Agent") != "test-agent" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("lat") == "0.00000" {
+			fmt.Fprint(w, `{"error":"Unable to geocode"}`)
+			return
+		}
+		fmt.Fprint(w, `{"address":{"town":"Gödöllő","county":"Pest","country_code":"hu"}}`)
+	}))
+	defer srv.Close()
+
+	n := newNominatim(srv.URL+"/", "test-agent", 1, time.Second)
+	n.limiter = rate.NewLimiter(rate.Inf, 1)
+	place, ok, err := n.resolve(47.6, 19.35)
+	if err != nil || !ok || place.String() != "Gödöllő, HU" {
+		t.Errorf("resolve = %q, %v, %v; want Gödöllő, HU", place, ok, err)
+	}
+	if _, ok, err := n.resolve(0, 0); ok || err != nil {
+		t.Errorf("sea position = %v, %v; want no place and no error", ok, err)
+	}
+
+	slow := newNominatim(srv.URL, "test-agent", 0.001, time.Second)
+	slow.resolve(47.6, 19.35)
+	if _, _, err := slow.resolve(47.6, 19.35); err == nil {
+		t.Error("second request within the rate limit must fail without a request")
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("%d requests, want 3", got)
+	}
+}
@@ -0,0 +1,89 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// nominatim queries a Nominatim reverse geocoding service. Requests over the
+// rate limit are not queued: the lookup fails and is retried on a later
+// message.
+type nominatim struct {
+	base      string
+	userAgent string
+	limiter   *rate.Limiter
+	client    *http.Client
+}
+
+func newNominatim(base, userAgent string, perSecond float64, timeout time.Duration) *nominatim {
+	return &nominatim{
+		base:      strings.TrimRight(base, "/"),
+		userAgent: userAgent,
+		limiter:   rate.NewLimiter(rate.Limit(perSecond), 1),
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+// nominatimResponse is the part of a /reverse response we use.
+type nominatimResponse struct {
+	Error   string `json:"error"`
+	Address struct {
+		City        string `json:"city"`
+		Town        string `json:"town"`
+		Village     string `json:"village"`
+		Hamlet      string `json:"hamlet"`
+		County      string `json:"county"`
+		State       string `json:"state"`
+		CountryCode string `json:"country_code"`
+	} `json:"address"`
+}
+
+func (n *nominatim) resolve(lat, lon float64) (Place, bool, error) {
+	if !n.limiter.Allow() {
+		return Place{}, false, fmt.Errorf("rate limited")
+	}
+
+	q := url.Values{}
+	q.Set("format", "jsonv2")
+	q.Set("lat", strconv.FormatFloat(lat, 'f', 5, 64))
+	q.Set("lon", strconv.FormatFloat(lon, 'f', 5, 64))
+	q.Set("zoom", "10") // city level
+	q.Set("addressdetails", "1")
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, n.base+"/reverse?"+q.Encode(), nil)
+	if err != nil {
+		return Place{}, false, err
+	}
+	req.Header.Set("User-Agent", n.userAgent)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return Place{}, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Place{}, false, fmt.Errorf("nominatim: %s", resp.Status)
+	}
+
+	var r nominatimResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return Place{}, false, fmt.Errorf("nominatim: %w", err)
+	}
+	if r.Error != "" {
+		return Place{}, false, nil // e.g. "Unable to geocode" over the sea
+	}
+	a := r.Address
+	for _, name := range []string{a.City, a.Town, a.Village, a.Hamlet, a.County, a.State} {
+		if name != "" {
+			return Place{Name: name, Country: strings.ToUpper(a.CountryCode)}, true, nil
+		}
+	}
+	return Place{}, false, nil
+}
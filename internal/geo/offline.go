@@ -0,0 +1,81 @@
+package geo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// offline finds the nearest place in a local dataset.
+type offline struct {
+	places      []datasetPlace
+	maxDistance float64 // metres
+}
+
+type datasetPlace struct {
+	Place
+	lat, lon float64
+}
+
+// loadOffline reads a CSV dataset with the columns name, country, lat, lon
+// (no header; lines starting with # are comments).
+func loadOffline(path string, maxDistance float64) (*offline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geocoding dataset: %w", err)
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 4
+	o := &offline{maxDistance: maxDistance}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("geocoding dataset %s: %w", path, err)
+		}
+		lat, errLat := strconv.ParseFloat(row[2], 64)
+		lon, errLon := strconv.ParseFloat(row[3], 64)
+		if errLat != nil || errLon != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("geocoding dataset %s: line %d: invalid coordinates", path, line)
+		}
+		o.places = append(o.places, datasetPlace{Place: Place{Name: row[0], Country: row[1]}, lat: lat, lon: lon})
+	}
+	return o, nil
+}
+
+func (o *offline) resolve(lat, lon float64) (Place, bool, error) {
+	best, bestDist := -1, math.Inf(1)
+	for i, p := range o.places {
+		// Cheap rejection before the haversine: 1° of latitude ≈ 111 km.
+		if math.Abs(p.lat-lat)*111000 > o.maxDistance {
+			continue
+		}
+		if d := distance(lat, lon, p.lat, p.lon); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	if best < 0 || bestDist > o.maxDistance {
+		return Place{}, false, nil
+	}
+	return o.places[best].Place, true, nil
+}
+
+// distance returns the great-circle distance in metres.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
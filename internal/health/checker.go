@@ -3,11 +3,20 @@ package health
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
 // StatusProvider provides health status information
@@ -15,16 +24,67 @@ type StatusProvider interface {
 	HealthStatus() map[string]interface{}
 }
 
+// MetricsProvider is optionally implemented by the StatusProvider to expose
+// counters on /metrics in the Prometheus text format
+type MetricsProvider interface {
+	WriteMetrics(w io.Writer) error
+}
+
+// ConfigProvider is optionally implemented by the StatusProvider to expose
+// the effective configuration and the origin of each value on /api/v1/config
+type ConfigProvider interface {
+	ConfigSource() *config.Source
+}
+
+// DrainProvider is optionally implemented by the StatusProvider to support
+// POST /drain (Kubernetes preStop): /ready reports 503 from then on while
+// the queued messages are sent. A drain cannot be undone; the process is
+// expected to stop
+type DrainProvider interface {
+	Drain(ctx context.Context) error
+	Draining() bool
+}
+
+// PurgeProvider is optionally implemented by the StatusProvider to support
+// POST /api/v1/purge, which removes a node, nick or topic from the bridge's
+// stores for data-removal requests
+type PurgeProvider interface {
+	Purge(kind, value string) (types.PurgeResult, error)
+}
+
+// QueueProvider is optionally implemented by the StatusProvider to list the
+// messages waiting to be sent on GET /api/v1/queue
+type QueueProvider interface {
+	Queue(limit int) (types.QueueListing, error)
+}
+
+// defaultQueueLimit is the number of messages GET /api/v1/queue lists
+// without a limit parameter.
+const defaultQueueLimit = 100
+
+// maxPurgeBody is the largest request body POST /api/v1/purge reads.
+const maxPurgeBody = 4096
+
 // Server provides HTTP health check endpoints
 type Server struct {
 	server   *http.Server
+	socket   string // unix socket path, if set instead of server.Addr
 	provider StatusProvider
+	ctx      context.Context // of serve, bounds a drain started by POST /drain
 	logger   zerolog.Logger
 }
 
-// New creates a new health check server
-func New(port int, provider StatusProvider, logger zerolog.Logger) *Server {
+// New creates a new health check server. Requests are rate limited per
+// client IP first, then authenticated (see config.HealthConfig).
+func New(cfg config.HealthConfig, provider StatusProvider, logger zerolog.Logger) (*Server, error) {
+	tc, err := tlsConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &Server{
+		ctx:      context.Background(),
+		socket:   cfg.Socket,
 		provider: provider,
 		logger:   logger.With().Str("component", "health").Logger(),
 	}
@@ -32,25 +92,65 @@ func New(port int, provider StatusProvider, logger zerolog.Logger) *Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.healthHandler)
 	mux.HandleFunc("/ready", s.readyHandler)
+	if _, ok := provider.(MetricsProvider); ok {
+		mux.HandleFunc("/metrics", s.metricsHandler)
+	}
+	if _, ok := provider.(DrainProvider); ok {
+		if writable(cfg, "/drain") {
+			mux.HandleFunc("POST /drain", s.drainHandler)
+		} else {
+			s.logger.Info().Msg("not serving POST /drain without health.auth (or health.unauthenticated_writes)")
+		}
+	}
+	if _, ok := provider.(ConfigProvider); ok {
+		mux.HandleFunc("GET /api/v1/config", s.configHandler)
+	}
+	if _, ok := provider.(PurgeProvider); ok {
+		if writable(cfg, "/api/v1/purge") {
+			mux.HandleFunc("POST /api/v1/purge", s.purgeHandler)
+		} else {
+			s.logger.Info().Msg("not serving POST /api/v1/purge without health.auth (or health.unauthenticated_writes)")
+		}
+	}
+	if _, ok := provider.(QueueProvider); ok {
+		mux.HandleFunc("GET /api/v1/queue", s.queueHandler)
+	}
 
 	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      mux,
+		Addr:         net.JoinHostPort(cfg.Address, strconv.Itoa(cfg.Port)),
+		Handler:      rateLimit(cfg.RateLimit, requireAuth(cfg.Auth, mux)),
+		TLSConfig:    tc,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	return s
+	return s, nil
 }
 
 // Start starts the health check server
 func (s *Server) Start(ctx context.Context) error {
-	s.logger.Info().Str("addr", s.server.Addr).Msg("starting health check server")
+	l, err := s.listen()
+	if err != nil {
+		return fmt.Errorf("health server failed: %w", err)
+	}
+	return s.serve(ctx, l)
+}
+
+// serve serves requests on l until ctx is done.
+func (s *Server) serve(ctx context.Context, l net.Listener) error {
+	s.ctx = ctx
+	s.logger.Info().Str("addr", l.Addr().String()).Bool("tls", s.server.TLSConfig != nil).Msg("starting health check server")
 
 	errChan := make(chan error, 1)
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.server.TLSConfig != nil {
+			err = s.server.ServeTLS(l, "", "") // certificates are in TLSConfig
+		} else {
+			err = s.server.Serve(l)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errChan <- err
 		}
 	}()
@@ -63,6 +163,29 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 }
 
+// listen opens the unix socket if one is configured, otherwise the TCP
+// address. A socket file left by an earlier run is replaced; the socket is
+// removed again when the server shuts down.
+func (s *Server) listen() (net.Listener, error) {
+	if s.socket == "" {
+		return net.Listen("tcp", s.server.Addr)
+	}
+	if fi, err := os.Lstat(s.socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(s.socket); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(s.socket, 0o660); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
 // healthHandler handles /health endpoint
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	status := s.provider.HealthStatus()
@@ -88,6 +211,12 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 
 // readyHandler handles /ready endpoint (for Kubernetes readiness probes)
 func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
+	if d, ok := s.provider.(DrainProvider); ok && d.Draining() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("draining"))
+		return
+	}
+
 	status := s.provider.HealthStatus()
 
 	mqttOk := status["mqtt_connected"].(bool)
@@ -102,6 +231,98 @@ func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// metricsHandler handles /metrics endpoint
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := s.provider.(MetricsProvider).WriteMetrics(w); err != nil {
+		s.logger.Error().Err(err).Msg("failed to write metrics")
+	}
+}
+
+// drainHandler handles POST /drain: it starts draining and returns at once.
+// The drain ends when the queues are empty or the server stops; the bridge
+// stays drained until restarted.
+func (s *Server) drainHandler(w http.ResponseWriter, r *http.Request) {
+	d := s.provider.(DrainProvider)
+	if !d.Draining() {
+		s.logger.Info().Str("remote", r.RemoteAddr).Msg("drain requested")
+		go d.Drain(s.ctx)
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("draining"))
+}
+
+// configHandler handles /api/v1/config endpoint. Secrets are redacted by
+// config.Source; the endpoint is not public unless listed in public_paths.
+func (s *Server) configHandler(w http.ResponseWriter, r *http.Request) {
+	src := s.provider.(ConfigProvider).ConfigSource()
+	if src == nil {
+		http.Error(w, "configuration source not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(src); err != nil {
+		s.logger.Error().Err(err).Msg("failed to encode config")
+	}
+}
+
+// purgeHandler handles POST /api/v1/purge with a JSON body of kind (node,
+// nick or topic) and value, e.g. {"kind":"node","value":"!abcd1234"}, and
+// replies with what was removed as JSON. Other content types are refused, so
+// a browser cannot be made to send it with a plain form.
+func (s *Server) purgeHandler(w http.ResponseWriter, r *http.Request) {
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	var req struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPurgeBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.logger.Info().Str("remote", r.RemoteAddr).Str("kind", req.Kind).Str("value", req.Value).Msg("purge requested")
+	res, err := s.provider.(PurgeProvider).Purge(req.Kind, req.Value)
+	switch {
+	case errors.Is(err, types.ErrInvalidPurge):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		s.logger.Error().Err(err).Msg("failed to encode purge result")
+	}
+}
+
+// queueHandler handles GET /api/v1/queue: the messages waiting to be sent,
+// in the order they are sent, at most limit (query parameter, default 100)
+// of them.
+func (s *Server) queueHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultQueueLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	listing, err := s.provider.(QueueProvider).Queue(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(listing); err != nil {
+		s.logger.Error().Err(err).Msg("failed to encode queue")
+	}
+}
+
 // Shutdown gracefully shuts down the health server
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info().Msg("shutting down health check server")
@@ -0,0 +1,279 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+type stubProvider struct{}
+
+func (stubProvider) HealthStatus() map[string]interface{} {
+	return map[string]interface{}{"mqtt_connected": true, "irc_connected": true}
+}
+
+func TestServer_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "health.sock")
+	s, err := New(config.HealthConfig{Socket: socket}, stubProvider{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := s.listen()
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.serve(ctx, l) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://health/ready")
+	if err != nil {
+		t.Fatalf("GET /ready over the socket: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "ready" {
+		t.Errorf("GET /ready = %d %q", resp.StatusCode, body)
+	}
+	if fi, err := os.Stat(socket); err != nil || fi.Mode().Perm() != 0o660 {
+		t.Errorf("socket mode = %v (%v), want 0660", fi.Mode().Perm(), err)
+	}
+
+	client.CloseIdleConnections()
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Start: %v", err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket not removed on shutdown: %v", err)
+	}
+}
+
+type configProvider struct{ stubProvider }
+
+func (configProvider) ConfigSource() *config.Source {
+	return &config.Source{File: "config.yaml", Settings: map[string]config.Setting{
+		"irc.nickname": {Value: "bot", Origin: config.OriginEnv},
+	}}
+}
+
+func TestServer_Config(t *testing.T) {
+	cfg := config.HealthConfig{Auth: config.HTTPAuthConfig{Token: "t", PublicPaths: []string{"/health"}}}
+	s, err := New(cfg, configProvider{}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/config", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated GET = %d, want 401", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/config", nil)
+	req.Header.Set("Authorization", "Bearer t")
+	s.server.Handler.ServeHTTP(rec, req)
+	var got config.Source
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.Code != http.StatusOK || got.File != "config.yaml" || got.Settings["irc.nickname"].Origin != config.OriginEnv {
+		t.Errorf("GET /api/v1/config = %d %+v", rec.Code, got)
+	}
+
+	// Without a ConfigProvider the endpoint does not exist.
+	s, _ = New(config.HealthConfig{}, stubProvider{}, zerolog.Nop())
+	rec = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/config", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET without provider = %d, want 404", rec.Code)
+	}
+}
+
+type drainProvider struct {
+	stubProvider
+	drained chan struct{}
+}
+
+func (p *drainProvider) Drain(context.Context) error {
+	close(p.drained)
+	return nil
+}
+
+func (p *drainProvider) Draining() bool {
+	select {
+	case <-p.drained:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestServer_Drain(t *testing.T) {
+	p := &drainProvider{drained: make(chan struct{})}
+	s, err := New(config.HealthConfig{Auth: config.HTTPAuthConfig{Token: "secret"}}, p, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("GET", "/ready"); rec.Code != http.StatusOK {
+		t.Errorf("GET /ready before drain = %d", rec.Code)
+	}
+	if rec := get("GET", "/drain"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /drain = %d, want 405", rec.Code)
+	}
+	if rec := get("POST", "/drain"); rec.Code != http.StatusAccepted {
+		t.Errorf("POST /drain = %d, want 202", rec.Code)
+	}
+	<-p.drained
+	if rec := get("GET", "/ready"); rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "draining" {
+		t.Errorf("GET /ready while draining = %d %q", rec.Code, rec.Body)
+	}
+	// Liveness is unaffected.
+	if rec := get("GET", "/health"); rec.Code != http.StatusOK {
+		t.Errorf("GET /health while draining = %d", rec.Code)
+	}
+
+	// Without credentials the endpoint is not served.
+	s, _ = New(config.HealthConfig{}, &drainProvider{drained: make(chan struct{})}, zerolog.Nop())
+	if rec := get("POST", "/drain"); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /drain without auth = %d, want 404", rec.Code)
+	}
+}
+
+type purgeProvider struct {
+	stubProvider
+	kind, value string
+}
+
+func (p *purgeProvider) Purge(kind, value string) (types.PurgeResult, error) {
+	if kind != "node" {
+		return types.PurgeResult{}, fmt.Errorf("%w: unknown kind %q", types.ErrInvalidPurge, kind)
+	}
+	p.kind, p.value = kind, value
+	return types.PurgeResult{Nodes: 1, History: 3}, nil
+}
+
+func TestServer_Purge(t *testing.T) {
+	p := &purgeProvider{}
+	s, err := New(config.HealthConfig{Auth: config.HTTPAuthConfig{Token: "secret"}}, p, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/purge", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("application/json; charset=utf-8", `{"kind":"node","value":"!abcd1234"}`)
+	if rec.Code != http.StatusOK || p.value != "!abcd1234" {
+		t.Fatalf("POST = %d, Purge(%q, %q)", rec.Code, p.kind, p.value)
+	}
+	var res types.PurgeResult
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil || res.Nodes != 1 || res.History != 3 {
+		t.Errorf("result = %+v, %v", res, err)
+	}
+	if rec := post("application/json", `{"kind":"planet","value":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST with a bad kind = %d, want 400", rec.Code)
+	}
+	if rec := post("application/json", `kind=node`); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST with a bad body = %d, want 400", rec.Code)
+	}
+	if rec := post("application/x-www-form-urlencoded", "kind=node&value=%21abcd1234"); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("POST of a form = %d, want 415", rec.Code)
+	}
+}
+
+func TestServer_PurgeNeedsAuth(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   config.HealthConfig
+		serve bool
+	}{
+		{"no auth", config.HealthConfig{}, false},
+		{"public", config.HealthConfig{Auth: config.HTTPAuthConfig{Token: "secret", PublicPaths: []string{"/api/v1/purge"}}}, false},
+		{"auth", config.HealthConfig{Auth: config.HTTPAuthConfig{Token: "secret"}}, true},
+		{"opt-in", config.HealthConfig{UnauthenticatedWrites: true}, true},
+	} {
+		s, err := New(tc.cfg, &purgeProvider{}, zerolog.Nop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", "/api/v1/purge", strings.NewReader(`{"kind":"node","value":"!abcd1234"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+		if served := rec.Code != http.StatusNotFound; served != tc.serve {
+			t.Errorf("%s: POST = %d, served %v, want %v", tc.name, rec.Code, served, tc.serve)
+		}
+	}
+}
+
+type queueProvider struct {
+	stubProvider
+	limit int
+}
+
+func (p *queueProvider) Queue(limit int) (types.QueueListing, error) {
+	p.limit = limit
+	return types.QueueListing{Total: 7, Messages: []types.QueuedMessage{{ID: "m1", Topic: "sensors/a", Stage: types.QueueStageBridge}}}, nil
+}
+
+func TestServer_Queue(t *testing.T) {
+	p := &queueProvider{}
+	s, err := New(config.HealthConfig{}, p, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/queue")
+	if rec.Code != http.StatusOK || p.limit != defaultQueueLimit {
+		t.Fatalf("GET = %d, limit %d", rec.Code, p.limit)
+	}
+	var listing types.QueueListing
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil || listing.Total != 7 || len(listing.Messages) != 1 || listing.Messages[0].ID != "m1" {
+		t.Errorf("listing = %+v, %v", listing, err)
+	}
+	if rec := get("/api/v1/queue?limit=5"); rec.Code != http.StatusOK || p.limit != 5 {
+		t.Errorf("GET ?limit=5 = %d, limit %d", rec.Code, p.limit)
+	}
+	if rec := get("/api/v1/queue?limit=x"); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET ?limit=x = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,166 @@
+package health
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+// limiterIdle is how long a client's rate limiter is kept after its last
+// request.
+const limiterIdle = 5 * time.Minute
+
+// requireAuth wraps next so requests outside cfg.PublicPaths need the
+// configured basic auth credentials or bearer token.
+func requireAuth(cfg config.HTTPAuthConfig, next http.Handler) http.Handler {
+	if !cfg.Enabled() {
+		return next
+	}
+	public := make(map[string]bool, len(cfg.PublicPaths))
+	for _, p := range cfg.PublicPaths {
+		public[p] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if public[r.URL.Path] || authorized(cfg, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cfg.Username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="mqtt2irc"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// writable reports whether to serve the endpoint at path, which changes
+// state: only behind auth credentials, unless unauthenticated_writes is set.
+func writable(cfg config.HealthConfig, path string) bool {
+	if cfg.UnauthenticatedWrites {
+		return true
+	}
+	return cfg.Auth.Enabled() && !slices.Contains(cfg.Auth.PublicPaths, path)
+}
+
+// authorized reports whether r carries valid credentials. Comparisons take
+// constant time.
+func authorized(cfg config.HTTPAuthConfig, r *http.Request) bool {
+	if cfg.Token != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			return subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) == 1
+		}
+	}
+	if cfg.Username != "" {
+		if user, pass, ok := r.BasicAuth(); ok {
+			userOK := subtle.ConstantTimeCompare([]byte(user), []byte(cfg.Username)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.Password)) == 1
+			return userOK && passOK
+		}
+	}
+	return false
+}
+
+// ipLimiter rate limits requests per client IP with a token bucket each.
+type ipLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	clients  map[string]*ipBucket
+	lastTidy time.Time
+	now      func() time.Time
+}
+
+type ipBucket struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+func newIPLimiter(cfg config.HTTPRateLimitConfig) *ipLimiter {
+	return &ipLimiter{
+		limit:   rate.Limit(cfg.RequestsPerSecond),
+		burst:   cfg.Burst,
+		clients: make(map[string]*ipBucket),
+		now:     time.Now,
+	}
+}
+
+// allow reports whether a request from ip may be served now. Clients idle
+// for limiterIdle are forgotten, at most once per limiterIdle.
+func (l *ipLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastTidy) >= limiterIdle {
+		for k, c := range l.clients {
+			if now.Sub(c.seen) >= limiterIdle {
+				delete(l.clients, k)
+			}
+		}
+		l.lastTidy = now
+	}
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &ipBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = c
+	}
+	c.seen = now
+	return c.limiter.AllowN(now, 1)
+}
+
+// rateLimit wraps next with a per-IP request limit; 0 requests per second
+// disables it.
+func rateLimit(cfg config.HTTPRateLimitConfig, next http.Handler) http.Handler {
+	if cfg.RequestsPerSecond <= 0 {
+		return next
+	}
+	l := newIPLimiter(cfg)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !l.allow(ip) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tlsConfig returns the server TLS config for cfg, or nil when TLS is off.
+// With a client CA, clients must present a certificate it signed.
+func tlsConfig(cfg config.HTTPTLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load health.tls certificate: %w", err)
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read health.tls.client_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("health.tls.client_ca_file %s holds no PEM certificates", cfg.ClientCAFile)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tc, nil
+}
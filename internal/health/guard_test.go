@@ -0,0 +1,92 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+func TestRequireAuth(t *testing.T) {
+	h := requireAuth(config.HTTPAuthConfig{
+		Username:    "prom",
+		Password:    "secret",
+		Token:       "t0ken",
+		PublicPaths: []string{"/health"},
+	}, ok)
+
+	tests := []struct {
+		name string
+		path string
+		set  func(r *http.Request)
+		want int
+	}{
+		{"public path", "/health", func(*http.Request) {}, http.StatusOK},
+		{"no credentials", "/metrics", func(*http.Request) {}, http.StatusUnauthorized},
+		{"basic auth", "/metrics", func(r *http.Request) { r.SetBasicAuth("prom", "secret") }, http.StatusOK},
+		{"wrong password", "/metrics", func(r *http.Request) { r.SetBasicAuth("prom", "guess") }, http.StatusUnauthorized},
+		{"bearer token", "/metrics", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") }, http.StatusOK},
+		{"wrong token", "/metrics", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		tt.set(r)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without WWW-Authenticate", tt.name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	requireAuth(config.HTTPAuthConfig{}, ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("without credentials configured: status %d, want 200", w.Code)
+	}
+}
+
+func TestIPLimiter(t *testing.T) {
+	l := newIPLimiter(config.HTTPRateLimitConfig{RequestsPerSecond: 1, Burst: 2})
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	if !l.allow("10.0.0.1") || !l.allow("10.0.0.1") {
+		t.Fatal("burst of 2 refused")
+	}
+	if l.allow("10.0.0.1") {
+		t.Error("third request within the second allowed")
+	}
+	if !l.allow("10.0.0.2") {
+		t.Error("other client limited by the first one's requests")
+	}
+	now = now.Add(time.Second)
+	if !l.allow("10.0.0.1") {
+		t.Error("request after the refill refused")
+	}
+
+	now = now.Add(limiterIdle)
+	l.allow("10.0.0.3")
+	if len(l.clients) != 1 {
+		t.Errorf("%d clients tracked, want idle ones forgotten", len(l.clients))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	h := rateLimit(config.HTTPRateLimitConfig{RequestsPerSecond: 1, Burst: 1}, ok)
+	codes := make([]int, 2)
+	for i := range codes {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		codes[i] = w.Code
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("status codes = %v, want 200 then 429", codes)
+	}
+}
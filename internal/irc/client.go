@@ -3,9 +3,9 @@ package irc
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"net"
 	"sync"
 	"time"
 
@@ -14,6 +14,8 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
 )
 
 // Client wraps the IRC client
@@ -26,15 +28,58 @@ type Client struct {
 	mu          sync.RWMutex
 	ready       chan struct{}
 	readyClosed bool
+	history     *connstate.History
+
+	// Failover state (see servers.go)
+	servers      []serverAddr
+	serverIdx    int
+	sessionUp    bool          // current connection attempt reached CONNECTED
+	stopped      bool          // Disconnect was called; the supervisor must exit
+	startBackoff time.Duration // first backoff between rounds before the first connection (ConnectRetry); 0 = minConnectBackoff
+	lookupSRV    func(service, proto, name string) (string, []*net.SRV, error)
+
+	// Keepalive self-test state (see keepalive.go)
+	pingToken       string
+	pongCh          chan struct{}
+	keepaliveFailed bool // last check failed or the connection stalled; cleared on the next successful connection
+
+	// Stall detection state (see stall.go)
+	lastTraffic time.Time // of the last server event
+	stalls      uint64
+	downDetail  string // why the bridge closed the connection, for the history
+
+	logRedact func(string) string // masks sensitive values in logged messages (optional)
+	safeMode  bool                // messages are only logged (see SetSafeMode)
+
+	desiredNick string // set by Nick; overrides config.Nickname for nick reclaim (see nick.go)
+
+	sent []time.Time // send times within the last minute (see pace.go)
+
+	// Channels the bot cannot speak in (see speak.go)
+	blocked   map[string]string  // lower-cased channel → reason
+	rejoins   map[string]*rejoin // lower-cased channel → JOIN retry after a ban
+	onBlocked func(channel, reason string, blocked bool)
+
+	// Per-channel server feedback (see speak.go)
+	onRejected func(channel, reason string)
+	onJoined   func(channel string)
+
+	clock schedule.Clock // for JOIN retries
 }
 
-// New creates a new IRC client
-func New(cfg config.IRCConfig, logger zerolog.Logger) *Client {
+// New creates a new IRC client. Connection transitions are recorded in history.
+func New(cfg config.IRCConfig, history *connstate.History, logger zerolog.Logger) *Client {
 	c := &Client{
-		config:   cfg,
-		logger:   logger.With().Str("component", "irc").Logger(),
-		channels: make(map[string]bool),
-		ready:    make(chan struct{}),
+		config:    cfg,
+		history:   history,
+		logger:    logger.With().Str("component", "irc").Logger(),
+		channels:  make(map[string]bool),
+		blocked:   make(map[string]string),
+		rejoins:   make(map[string]*rejoin),
+		clock:     schedule.Real,
+		ready:     make(chan struct{}),
+		servers:   serverList(cfg),
+		lookupSRV: net.LookupSRV,
 	}
 
 	// Create rate limiter (token bucket)
@@ -43,25 +88,16 @@ func New(cfg config.IRCConfig, logger zerolog.Logger) *Client {
 		cfg.RateLimit.Burst,
 	)
 
-	// Configure girc client
+	// Configure girc client; the supervisor switches Server/Port on failover.
 	ircCfg := girc.Config{
-		Server: cfg.Server,
-		Port:   6667, // Default port
+		Server: c.servers[0].host,
+		Port:   c.servers[0].port,
 		Nick:   cfg.Nickname,
 		User:   cfg.Username,
 		Name:   cfg.Realname,
 	}
-
-	// Parse server and port if provided in "host:port" format
-	if strings.Contains(cfg.Server, ":") {
-		parts := strings.Split(cfg.Server, ":")
-		ircCfg.Server = parts[0]
-		if len(parts) > 1 {
-			// Parse port from string
-			if port, err := strconv.Atoi(parts[1]); err == nil {
-				ircCfg.Port = port
-			}
-		}
+	if cfg.PingDelay > 0 {
+		ircCfg.PingDelay = cfg.PingDelay
 	}
 
 	// TLS configuration
@@ -72,60 +108,160 @@ func New(cfg config.IRCConfig, logger zerolog.Logger) *Client {
 		}
 	}
 
+	ircCfg.HandleNickCollide = c.fallbackNick
+
 	c.client = girc.New(ircCfg)
 
 	// Set up event handlers
 	c.client.Handlers.Add(girc.CONNECTED, c.onConnect)
 	c.client.Handlers.Add(girc.DISCONNECTED, c.onDisconnect)
 	c.client.Handlers.Add(girc.JOIN, c.onJoin)
+	c.client.Handlers.Add(girc.PONG, c.onPong)
+	c.client.Handlers.Add(girc.MODE, c.onMode)
+	c.client.Handlers.Add(girc.RPL_CHANNELMODEIS, c.onChannelModes)
+	c.client.Handlers.Add(girc.ERR_CANNOTSENDTOCHAN, c.onCannotSend)
+	c.client.Handlers.Add(girc.ERR_BANNEDFROMCHAN, c.onBannedFromChan)
+	c.client.Handlers.Add(girc.ERR_NOSUCHCHANNEL, c.onNoSuchChannel)
+	if cfg.StallTimeout > 0 {
+		c.client.Handlers.Add(girc.ALL_EVENTS, c.onTraffic)
+	}
 
 	return c
 }
 
-// Connect establishes connection to IRC server
+// SetClock replaces the time source of JOIN retries (for tests).
+func (c *Client) SetClock(clock schedule.Clock) {
+	c.clock = clock
+}
+
+// Connect starts the connection supervisor and waits for the first successful
+// connection. It fails if every configured server failed once, on timeout, or
+// when ctx is cancelled.
 func (c *Client) Connect(ctx context.Context) error {
-	c.logger.Info().Str("server", c.config.Server).Msg("connecting to IRC server")
-
-	// Connect in background
-	errChan := make(chan error, 1)
-	go func() {
-		if err := c.client.Connect(); err != nil {
-			c.logger.Error().Err(err).Msg("IRC connect error")
-			errChan <- err
-		}
-	}()
+	failed := make(chan error, 1)
+	if err := c.start(ctx, failed); err != nil {
+		return err
+	}
 
-	// Wait for connection with a reasonable timeout
-	timeout := time.After(30 * time.Second)
+	// Wait for connection with a reasonable timeout per server
+	timeout := time.After(time.Duration(len(c.servers)) * 30 * time.Second)
 
 	// Wait for connection or context cancellation
 	select {
-	case err := <-errChan:
+	case err := <-failed:
+		c.stop()
 		return fmt.Errorf("failed to connect to IRC server: %w", err)
-	case <-c.ready:
-		c.logger.Info().Msg("connected to IRC server")
+	case <-c.readyChan():
+		c.logger.Info().Str("server", c.currentServer().String()).Msg("connected to IRC server")
 		return nil
 	case <-timeout:
-		c.client.Close()
+		c.stop()
 		return fmt.Errorf("IRC connection timeout")
 	case <-ctx.Done():
-		c.client.Close()
+		c.stop()
 		return ctx.Err()
 	}
 }
 
+// ConnectRetry is Connect for startup.retry: rather than failing after one
+// round of failed servers, it keeps trying until a connection succeeds or
+// attempts rounds failed (0 = no limit). Between rounds it waits backoff
+// (default 1s), doubled after every round up to 60s. onFail, if set, is
+// called after every failed round but the last.
+func (c *Client) ConnectRetry(ctx context.Context, attempts int, backoff time.Duration, onFail func(attempt int, err error)) error {
+	c.startBackoff = backoff
+	failed := make(chan error, 1)
+	if err := c.start(ctx, failed); err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case err := <-failed:
+			if attempts > 0 && attempt >= attempts {
+				c.stop()
+				return fmt.Errorf("failed to connect to IRC server after %d attempts: %w", attempt, err)
+			}
+			if onFail != nil {
+				onFail(attempt, err)
+			}
+		case <-c.readyChan():
+			c.logger.Info().Str("server", c.currentServer().String()).Msg("connected to IRC server")
+			return nil
+		case <-ctx.Done():
+			c.stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Start starts the connection supervisor without waiting for a connection:
+// it keeps trying the servers, with backoff, until ctx is cancelled. Ready
+// is closed on the first successful connection. It fails only if the ident
+// setup does.
+func (c *Client) Start(ctx context.Context) error {
+	return c.start(ctx, nil)
+}
+
+// Ready returns a channel closed on the first successful connection.
+func (c *Client) Ready() <-chan struct{} {
+	return c.readyChan()
+}
+
+// start starts the supervisor and its helpers. Until the first successful
+// connection, a full round of failed servers is reported on failed, if set.
+func (c *Client) start(ctx context.Context, failed chan<- error) error {
+	c.logger.Info().Str("server", c.currentServer().String()).Int("servers", len(c.servers)).Msg("connecting to IRC server")
+
+	if err := c.startIdent(ctx); err != nil {
+		return err
+	}
+
+	go c.supervise(ctx, failed)
+	if (len(c.servers) > 1 || c.config.SRVDomain != "") && c.config.FailbackInterval > 0 {
+		go c.failback(ctx)
+	}
+	if c.config.Keepalive.Interval > 0 {
+		go c.keepalive(ctx)
+	}
+	if c.config.StallTimeout > 0 {
+		go c.stallDetector(ctx)
+	}
+	if c.config.NickReclaim.Enabled && c.config.NickReclaim.Interval > 0 {
+		go c.nickReclaimLoop(ctx)
+	}
+	return nil
+}
+
+// readyChan returns the channel closed on the first successful connection.
+func (c *Client) readyChan() <-chan struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ready
+}
+
 // onConnect is called when connection is established
 func (c *Client) onConnect(client *girc.Client, event girc.Event) {
 	c.logger.Info().Msg("IRC connection established")
+	c.mu.Lock()
+	c.sessionUp = true
+	c.keepaliveFailed = false
+	c.lastTraffic = time.Now()
+	c.mu.Unlock()
+	c.history.RecordUp(c.currentServer().String())
 
 	// Authenticate with NickServ if configured
 	if c.config.NickServPassword != "" {
-		c.logger.Info().Msg("authenticating with NickServ")
-		c.client.Cmd.Message("NickServ", fmt.Sprintf("IDENTIFY %s", c.config.NickServPassword))
+		c.identify()
 		// Give NickServ time to process
 		time.Sleep(2 * time.Second)
 	}
 
+	// Connected on a fallback nick: take the configured one back.
+	if c.config.NickReclaim.Enabled {
+		c.reclaim()
+	}
+
 	// Signal that we're ready (guard against double-close on reconnect cycles)
 	c.mu.Lock()
 	if !c.readyClosed {
@@ -138,6 +274,21 @@ func (c *Client) onConnect(client *girc.Client, event girc.Event) {
 // onDisconnect is called when connection is lost
 func (c *Client) onDisconnect(client *girc.Client, event girc.Event) {
 	c.logger.Warn().Msg("IRC connection lost")
+	c.mu.Lock()
+	detail := c.downDetail
+	c.downDetail = ""
+	c.mu.Unlock()
+	c.history.RecordDown(detail)
+
+	// Channel membership does not survive the connection. Every channel is
+	// joined again on connect, so pending JOIN retries are not needed.
+	c.mu.Lock()
+	c.channels = make(map[string]bool)
+	for key, r := range c.rejoins {
+		r.timer.Stop()
+		delete(c.rejoins, key)
+	}
+	c.mu.Unlock()
 }
 
 // onJoin is called when we join a channel
@@ -148,6 +299,11 @@ func (c *Client) onJoin(client *girc.Client, event girc.Event) {
 		c.channels[channel] = true
 		c.mu.Unlock()
 		c.logger.Info().Str("channel", channel).Msg("joined IRC channel")
+		c.stopRejoin(channel)
+		c.recheckSpeak(channel) // not banned any more, if it was
+		if c.onJoined != nil {
+			c.onJoined(channel)
+		}
 	}
 }
 
@@ -169,51 +325,167 @@ func (c *Client) SendMessage(ctx context.Context, channel, message string) error
 	c.JoinChannel(channel)
 
 	// Wait for rate limiter
-	if err := c.limiter.Wait(ctx); err != nil {
-		return fmt.Errorf("rate limiter error: %w", err)
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+
+	if c.safeMode {
+		c.logSafeMode(channel, message)
+		return nil
 	}
 
 	// Send message
 	c.logger.Debug().
 		Str("channel", channel).
-		Str("message", message).
+		Str("message", c.redactLog(message)).
 		Msg("sending message to IRC")
 
 	c.client.Cmd.Message(channel, message)
+	c.recordSend(time.Now())
 	return nil
 }
 
+// ErrNotConnected is returned by SendMessage when the client is not
+// connected; the message is not sent.
+var ErrNotConnected = errors.New("not connected to IRC")
+
+// wait waits for the rate limiter to let a message through. A message that
+// cannot be sent because the client is not connected gets its token back:
+// lines failing during a disconnect neither use up the burst the queued
+// lines need once the client is back, nor wait for tokens themselves.
+func (c *Client) wait(ctx context.Context) error {
+	now := time.Now()
+	r := c.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return fmt.Errorf("rate limiter error: burst of %d exceeded", c.limiter.Burst())
+	}
+	delay := r.DelayFrom(now)
+	// Cancelling as of the time the token was granted returns it even
+	// after that time has passed.
+	granted := now.Add(delay)
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			r.Cancel()
+			return fmt.Errorf("rate limiter error: %w", ctx.Err())
+		}
+	}
+	if !c.IsConnected() {
+		r.CancelAt(granted)
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// SetSafeMode makes the bridge's messages and topic changes only logged,
+// for --safe-mode. Messages still wait for the rate limiter, so queues
+// behave as they would in production. Must be called before Connect.
+func (c *Client) SetSafeMode() {
+	c.safeMode = true
+}
+
+// logSafeMode logs a message not sent in safe mode.
+func (c *Client) logSafeMode(channel, message string) {
+	c.logger.Info().
+		Str("channel", channel).
+		Str("message", c.redactLog(message)).
+		Msg("safe mode: not sending message")
+}
+
+// SetLogRedactor sets a function applied to message text before it is logged.
+// Must be called before Connect.
+func (c *Client) SetLogRedactor(fn func(string) string) {
+	c.logRedact = fn
+}
+
+func (c *Client) redactLog(s string) string {
+	if c.logRedact == nil {
+		return s
+	}
+	return c.logRedact(s)
+}
+
+// SetTopic sets the topic of an IRC channel (the bot needs the rights to do so).
+func (c *Client) SetTopic(channel, topic string) {
+	c.JoinChannel(channel)
+	if c.safeMode {
+		c.logger.Info().Str("channel", channel).Str("topic", topic).Msg("safe mode: not setting IRC channel topic")
+		return
+	}
+	c.logger.Debug().Str("channel", channel).Str("topic", topic).Msg("setting IRC channel topic")
+	c.client.Cmd.Topic(channel, topic)
+}
+
+// Target returns the current IRC state for a channel, for channel-aware formatting.
+// Channel fields are zero when the bot has not (yet) joined the channel.
+func (c *Client) Target(channel string) Target {
+	t := Target{
+		Channel: ChannelInfo{Name: channel},
+		Nick:    c.client.GetNick(),
+	}
+	if ch := c.client.LookupChannel(channel); ch != nil {
+		t.Channel.Users = ch.Len()
+		t.Channel.Topic = ch.Topic
+	}
+	return t
+}
+
 // Disconnect closes the IRC connection
 func (c *Client) Disconnect() {
 	c.logger.Info().Msg("disconnecting from IRC server")
-	c.client.Close()
+	c.stop()
 	c.logger.Info().Msg("disconnected from IRC server")
 }
 
-// IsConnected returns true if connected to IRC server
+// IsConnected returns true if connected to IRC server and the last keepalive
+// check (if enabled) did not fail, nor did the connection stall.
 func (c *Client) IsConnected() bool {
-	return c.client.IsConnected()
+	c.mu.RLock()
+	failed := c.keepaliveFailed
+	c.mu.RUnlock()
+	return !failed && c.client.IsConnected()
 }
 
-// Nick changes the bot's IRC nickname.
+// WaitConnected blocks until the client is connected (see IsConnected) or
+// ctx is done.
+func (c *Client) WaitConnected(ctx context.Context) error {
+	if c.IsConnected() {
+		return nil
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if c.IsConnected() {
+				return nil
+			}
+		}
+	}
+}
+
+// History returns the connection state history.
+func (c *Client) History() *connstate.History {
+	return c.history
+}
+
+// Nick changes the bot's IRC nickname. Nick reclaim keeps this nick from now on.
 func (c *Client) Nick(newnick string) {
+	c.mu.Lock()
+	c.desiredNick = newnick
+	c.mu.Unlock()
 	c.client.Cmd.Nick(newnick)
 }
 
-// Reconnect drops the current connection and reconnects.
-// girc v1.1.1 has no built-in Reconnect(); we reset state and re-call Connect().
+// Reconnect drops the current connection; the supervisor reconnects to the
+// same server (after the flap backoff, if the connection is flapping).
 func (c *Client) Reconnect() {
-	c.mu.Lock()
-	c.ready = make(chan struct{})
-	c.readyClosed = false
-	c.channels = make(map[string]bool)
-	c.mu.Unlock()
 	c.client.Close()
-	go func() {
-		if err := c.client.Connect(); err != nil {
-			c.logger.Error().Err(err).Msg("IRC reconnect failed")
-		}
-	}()
 }
 
 // AddHandler registers an additional girc event handler.
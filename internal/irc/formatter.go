@@ -1,52 +1,122 @@
 package irc
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"text/template"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
+// ChannelInfo describes the IRC channel a message is being formatted for.
+type ChannelInfo struct {
+	Name  string
+	Users int // number of users currently in the channel (0 if unknown)
+	Topic string
+}
+
+// Target is the IRC-side state exposed to templates as {{.Channel}} and {{.Nick}}.
+type Target struct {
+	Channel ChannelInfo
+	Nick    string // the bot's current nick
+}
+
+// UsesTarget reports whether a template references IRC state ({{.Channel}} or
+// {{.Nick}}) and therefore has to be rendered separately for each channel.
+func UsesTarget(templateStr string) bool {
+	return strings.Contains(templateStr, ".Channel") || strings.Contains(templateStr, ".Nick")
+}
+
 // FormatMessage formats an MQTT message for IRC using a template
 func FormatMessage(msg types.Message, templateStr string, maxLength int, truncateSuffix string) (string, error) {
+	return FormatMessageFor(msg, templateStr, Target{}, maxLength, truncateSuffix)
+}
+
+// FormatMessageFor formats an MQTT message for a specific IRC target, making
+// the target's channel state and the bot nick available to the template.
+func FormatMessageFor(msg types.Message, templateStr string, target Target, maxLength int, truncateSuffix string) (string, error) {
+	return SanitizeAndTruncate(RenderMessage(msg, templateStr, target), maxLength, truncateSuffix), nil
+}
+
+// RenderMessage executes a message template for target without sanitizing
+// the result, so line breaks are kept (see SplitLines). Invalid templates
+// fall back to "[topic] payload".
+func RenderMessage(msg types.Message, templateStr string, target Target) string {
 	// Default template if none provided
 	if templateStr == "" {
 		templateStr = "[{{.Topic}}] {{.Payload}}"
 	}
 
-	// Parse template; missingkey=zero returns "" for missing JSON fields (string zero value)
-	tmpl, err := template.New("message").Option("missingkey=zero").Parse(templateStr)
-	if err != nil {
+	tmpl := messageTemplate(templateStr)
+	if tmpl == nil {
 		// Fallback to simple format if template is invalid
-		return formatSimple(msg, maxLength, truncateSuffix), nil
+		return formatSimple(msg)
 	}
 
-	// Template data
+	// Template data; the channel topic is set by IRC users, like payloads
+	channel := target.Channel
+	channel.Topic = StripMarkers(channel.Topic)
 	data := map[string]interface{}{
-		"Topic":   msg.Topic,
-		"Payload": payloadString(msg.Payload),
+		"Topic":   StripMarkers(msg.Topic),
+		"Payload": StripMarkers(payloadString(msg.Payload)),
 		"QoS":     msg.QoS,
-		"JSON":    ParseJSON(msg.Payload),
+		"Size":    len(msg.Payload),
+		"Time":    msg.Timestamp,
+		"JSON":    MessageJSON(msg),
+		"Meta":    msg.Meta,
+		"Channel": channel,
+		"Nick":    target.Nick,
 	}
 
 	// Execute template
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	if err := tmpl.Execute(buf, data); err != nil {
 		// Fallback to simple format if execution fails
-		return formatSimple(msg, maxLength, truncateSuffix), nil
+		return formatSimple(msg)
 	}
+	return buf.String()
+}
 
-	result := buf.String()
+// templates caches parsed message templates by format string, so each format
+// is parsed once rather than for every message. A nil entry marks an invalid
+// format.
+var templates = struct {
+	sync.RWMutex
+	m map[string]*template.Template
+}{m: make(map[string]*template.Template)}
+
+// messageTemplate returns the parsed template for format, or nil if it does
+// not parse. missingkey=zero returns "" for missing JSON fields (string zero value).
+func messageTemplate(format string) *template.Template {
+	templates.RLock()
+	tmpl, ok := templates.m[format]
+	templates.RUnlock()
+	if ok {
+		return tmpl
+	}
 
-	// Sanitize and truncate
-	result = sanitize(result)
-	result = truncate(result, maxLength, truncateSuffix)
+	tmpl, err := template.New("message").Option("missingkey=zero").Funcs(Funcs).Parse(format)
+	if err != nil {
+		tmpl = nil
+	}
+	templates.Lock()
+	templates.m[format] = tmpl
+	templates.Unlock()
+	return tmpl
+}
 
-	return result, nil
+// ResetTemplates drops the parsed template cache. Call it when the set of
+// message formats changes (config reload, runtime format changes) so formats
+// no longer in use are released.
+func ResetTemplates() {
+	templates.Lock()
+	templates.m = make(map[string]*template.Template)
+	templates.Unlock()
 }
 
 // ParseJSON attempts to parse a payload as a JSON object.
@@ -54,13 +124,45 @@ func FormatMessage(msg types.Message, templateStr string, maxLength int, truncat
 // Only JSON objects (not arrays or scalars) are supported.
 // Using string values ensures missing keys produce "" in templates rather than "<no value>".
 func ParseJSON(payload []byte) map[string]string {
+	return stringFields(decodeObject(payload))
+}
+
+// CacheJSON decodes msg's payload as a JSON object and keeps the result in
+// msg.JSON, so later JSONObject and MessageJSON calls don't parse it again.
+func CacheJSON(msg *types.Message) {
+	msg.JSON = decodeObject(msg.Payload)
+	msg.JSONParsed = true
+}
+
+// JSONObject returns msg's payload decoded as a JSON object, or nil if it is
+// not one. The cached decode is used when present.
+func JSONObject(msg types.Message) map[string]interface{} {
+	if msg.JSONParsed {
+		return msg.JSON
+	}
+	return decodeObject(msg.Payload)
+}
+
+// MessageJSON is ParseJSON for a message, using its cached decode when present.
+func MessageJSON(msg types.Message) map[string]string {
+	return stringFields(JSONObject(msg))
+}
+
+func decodeObject(payload []byte) map[string]interface{} {
 	var raw map[string]interface{}
 	if err := json.Unmarshal(payload, &raw); err != nil {
 		return nil
 	}
+	return raw
+}
+
+func stringFields(raw map[string]interface{}) map[string]string {
+	if raw == nil {
+		return nil
+	}
 	result := make(map[string]string, len(raw))
 	for k, v := range raw {
-		result[k] = fmt.Sprintf("%v", v)
+		result[k] = StripMarkers(fmt.Sprintf("%v", v))
 	}
 	return result
 }
@@ -75,61 +177,65 @@ func payloadString(payload []byte) string {
 }
 
 // formatSimple creates a simple formatted message
-func formatSimple(msg types.Message, maxLength int, truncateSuffix string) string {
-	result := "[" + msg.Topic + "] " + payloadString(msg.Payload)
-	result = sanitize(result)
-	result = truncate(result, maxLength, truncateSuffix)
-	return result
+func formatSimple(msg types.Message) string {
+	return "[" + msg.Topic + "] " + payloadString(msg.Payload)
 }
 
 // SanitizeAndTruncate applies IRC sanitization and length truncation to a pre-formatted string.
 // This is the exported entry point for message processors that pre-format their output.
+// Optional sections (see Funcs) are dropped, last first, before the text is cut.
 func SanitizeAndTruncate(s string, maxLen int, suffix string) string {
-	s = sanitize(s)
-	s = truncate(s, maxLen, suffix)
-	return s
+	return Limits{MaxLength: maxLen, Suffix: suffix}.Fit(s)
+}
+
+// SplitLines turns multi-line output into at most maxLines IRC messages, each
+// fitted to limits. Blank lines are skipped. When lines are cut, the last
+// kept line ends with the suffix. maxLines <= 1 flattens s into one line.
+func SplitLines(s string, maxLines int, limits Limits) []string {
+	if maxLines <= 1 {
+		return []string{limits.Fit(s)}
+	}
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = limits.Fit(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := lines[maxLines-1]
+		if !strings.HasSuffix(last, limits.Suffix) {
+			lines[maxLines-1] = limits.cut(last + limits.Suffix)
+		}
+	}
+	return lines
 }
 
 // sanitize removes or replaces problematic characters for IRC
 func sanitize(s string) string {
-	// Remove control characters except for common formatting codes
-	var result strings.Builder
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
+	// Replace control characters (except for common formatting codes) with
+	// spaces, collapse runs of whitespace and trim both ends in one pass
+	space := false
 	for _, r := range s {
-		// Allow printable characters and IRC color codes
-		if r >= 32 && r < 127 || r == '\x02' || r == '\x1F' || r == '\x16' || r == '\x03' {
-			result.WriteRune(r)
-		} else if r >= 128 { // Allow UTF-8
-			result.WriteRune(r)
-		} else {
-			// Replace control chars with space
-			result.WriteRune(' ')
+		// Allow printable characters, IRC color codes and UTF-8
+		allowed := r >= 32 && r < 127 || r == '\x02' || r == '\x1F' || r == '\x16' || r == '\x03' || r >= 128
+		if !allowed || unicode.IsSpace(r) {
+			space = buf.Len() > 0
+			continue
+		}
+		if space {
+			buf.WriteByte(' ')
+			space = false
 		}
+		buf.WriteRune(r)
 	}
-
-	// Collapse multiple spaces
-	s = result.String()
-	s = strings.Join(strings.Fields(s), " ")
-
-	return s
+	return buf.String()
 }
 
-// truncate limits message length for IRC protocol
+// truncate limits message length (in runes) for IRC protocol
 func truncate(s string, maxLength int, suffix string) string {
-	if maxLength <= 0 {
-		maxLength = 400
-	}
-
-	if utf8.RuneCountInString(s) <= maxLength {
-		return s
-	}
-
-	// Reserve space for suffix
-	targetLen := maxLength - utf8.RuneCountInString(suffix)
-	if targetLen <= 0 {
-		return suffix
-	}
-
-	// Truncate to rune boundary
-	runes := []rune(s)
-	return string(runes[:targetLen]) + suffix
+	return Limits{MaxLength: maxLength, Suffix: suffix}.cut(s)
 }
@@ -57,6 +57,22 @@ func TestFormatMessage(t *testing.T) {
 			truncateSuffix: "...",
 			expected:       "sensors/env: temp=22.5C",
 		},
+		{
+			name:           "metadata access",
+			msg:            types.Message{Topic: "sensors/temp", Payload: []byte("25.5"), Meta: map[string]string{"room": "Kitchen"}},
+			template:       "{{.Meta.room}}: {{.Payload}}{{.Meta.unit}}",
+			maxLength:      100,
+			truncateSuffix: "...",
+			expected:       "Kitchen: 25.5",
+		},
+		{
+			name:           "metadata missing",
+			msg:            types.Message{Topic: "sensors/temp", Payload: []byte("25.5")},
+			template:       "[{{.Meta.room}}] {{.Payload}}",
+			maxLength:      100,
+			truncateSuffix: "...",
+			expected:       "[] 25.5",
+		},
 		{
 			name:           "json missing field returns empty",
 			msg:            types.Message{Topic: "sensors/env", Payload: []byte(`{"temp":22.5}`), QoS: 1},
@@ -97,6 +113,46 @@ func TestFormatMessage(t *testing.T) {
 	}
 }
 
+func TestFormatMessageFor(t *testing.T) {
+	msg := types.Message{Topic: "sensors/temp", Payload: []byte(`{"temp":"21.5","room":"lab"}`)}
+	tmpl := `{{if gt .Channel.Users 50}}{{.JSON.temp}}{{else}}{{.Nick}}: {{.JSON.room}} is {{.JSON.temp}}°C in {{.Channel.Name}}{{end}}`
+
+	busy := Target{Channel: ChannelInfo{Name: "#busy", Users: 120}, Nick: "bot"}
+	got, err := FormatMessageFor(msg, tmpl, busy, 100, "...")
+	if err != nil {
+		t.Fatalf("FormatMessageFor: %v", err)
+	}
+	if got != "21.5" {
+		t.Errorf("busy channel: got %q, want %q", got, "21.5")
+	}
+
+	quiet := Target{Channel: ChannelInfo{Name: "#quiet", Users: 3}, Nick: "bot"}
+	got, err = FormatMessageFor(msg, tmpl, quiet, 100, "...")
+	if err != nil {
+		t.Fatalf("FormatMessageFor: %v", err)
+	}
+	if want := "bot: lab is 21.5°C in #quiet"; got != want {
+		t.Errorf("quiet channel: got %q, want %q", got, want)
+	}
+}
+
+func TestUsesTarget(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		want bool
+	}{
+		{"[{{.Topic}}] {{.Payload}}", false},
+		{"{{.Channel.Users}}", true},
+		{"{{.Nick}} says {{.Payload}}", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := UsesTarget(tt.tmpl); got != tt.want {
+			t.Errorf("UsesTarget(%q) = %v, want %v", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
 func TestParseJSON(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -175,6 +231,10 @@ func TestSanitize(t *testing.T) {
 		{"newlines", "hello\nworld", "hello world"},
 		{"tabs", "hello\tworld", "hello world"},
 		{"utf8", "hello 世界", "hello 世界"},
+		{"trimmed", "\r\n  hello world \x00", "hello world"},
+		{"unicode spaces", "hello\u00a0\u3000world", "hello world"},
+		{"irc formatting kept", "\x02bold\x02 \x0304red", "\x02bold\x02 \x0304red"},
+		{"only control chars", "\x00\x01\n", ""},
 	}
 
 	for _, tt := range tests {
@@ -220,3 +280,126 @@ func TestTruncate(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxLines int
+		maxLen   int
+		want     []string
+	}{
+		{"single line mode flattens", "temp 21\nhum 40", 1, 100, []string{"temp 21 hum 40"}},
+		{"one message per line", "temp 21\nhum 40\r\n", 5, 100, []string{"temp 21", "hum 40"}},
+		{"blank lines skipped", "a\n\n  \nb", 5, 100, []string{"a", "b"}},
+		{"line cap marks the cut", "a\nb\nc\nd", 2, 100, []string{"a", "b..."}},
+		{"each line truncated", "hello world\nok", 3, 8, []string{"hello...", "ok"}},
+		{"cut line already truncated", "a\nhello world\nc", 2, 8, []string{"a", "hello..."}},
+		{"empty output", "\n\n", 3, 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitLines(tt.input, tt.maxLines, Limits{MaxLength: tt.maxLen, Suffix: "..."})
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("SplitLines(%q, %d) = %q, want %q", tt.input, tt.maxLines, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMessage_KeepsNewlines(t *testing.T) {
+	msg := types.Message{Topic: "sensors/env", Payload: []byte(`{"temp":21,"hum":40}`)}
+	got := RenderMessage(msg, "temp {{.JSON.temp}}\nhum {{.JSON.hum}}", Target{})
+	if got != "temp 21\nhum 40" {
+		t.Errorf("RenderMessage = %q", got)
+	}
+}
+
+func BenchmarkRenderMessage(b *testing.B) {
+	msg := types.Message{
+		Topic:   "sensors/env/living_room",
+		Payload: []byte(`{"temperature":21.5,"humidity":48,"battery":97,"linkquality":120}`),
+	}
+	format := "[{{.Topic}}] temp={{.JSON.temperature}}°C humidity={{.JSON.humidity}}%{{opt}} bat={{.JSON.battery}}%"
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RenderMessage(msg, format, Target{})
+	}
+}
+
+// BenchmarkRenderMessage_Uncached parses the template for every message, as
+// RenderMessage did before templates were cached; compare its allocations
+// with BenchmarkRenderMessage.
+func BenchmarkRenderMessage_Uncached(b *testing.B) {
+	msg := types.Message{
+		Topic:   "sensors/env/living_room",
+		Payload: []byte(`{"temperature":21.5,"humidity":48,"battery":97,"linkquality":120}`),
+	}
+	format := "[{{.Topic}}] temp={{.JSON.temperature}}°C humidity={{.JSON.humidity}}%{{opt}} bat={{.JSON.battery}}%"
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ResetTemplates()
+		RenderMessage(msg, format, Target{})
+	}
+}
+
+func TestRenderMessage_TemplateCache(t *testing.T) {
+	ResetTemplates()
+	msg := types.Message{Topic: "t", Payload: []byte("p")}
+	if got := RenderMessage(msg, "{{.Topic}}={{.Payload}}", Target{}); got != "t=p" {
+		t.Errorf("RenderMessage = %q", got)
+	}
+	first := messageTemplate("{{.Topic}}={{.Payload}}")
+	if first == nil || messageTemplate("{{.Topic}}={{.Payload}}") != first {
+		t.Error("template not cached")
+	}
+	if got := RenderMessage(msg, "{{.Topic", Target{}); got != "[t] p" {
+		t.Errorf("invalid template: RenderMessage = %q, want fallback", got)
+	}
+	ResetTemplates()
+	if messageTemplate("{{.Topic}}={{.Payload}}") == first {
+		t.Error("ResetTemplates kept the cached template")
+	}
+}
+
+func BenchmarkParseJSON(b *testing.B) {
+	payload := []byte(`{"temperature":21.5,"humidity":48,"battery":97,"linkquality":120,"state":"ON"}`)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseJSON(payload)
+	}
+}
+
+func TestCacheJSON(t *testing.T) {
+	msg := types.Message{Topic: "t", Payload: []byte(`{"temp":21.5,"nested":{"a":1}}`)}
+	CacheJSON(&msg)
+	if !msg.JSONParsed || msg.JSON["temp"] != 21.5 {
+		t.Fatalf("CacheJSON() = %v (parsed %v), want decoded object", msg.JSON, msg.JSONParsed)
+	}
+
+	// Later lookups use the cached decode, not the payload.
+	msg.Payload = []byte(`{"temp":0}`)
+	if got := MessageJSON(msg)["temp"]; got != "21.5" {
+		t.Errorf("MessageJSON()[temp] = %q, want cached 21.5", got)
+	}
+	got := RenderMessage(msg, "{{.JSON.temp}}", Target{})
+	if got != "21.5" {
+		t.Errorf("RenderMessage() = %q, want cached 21.5", got)
+	}
+}
+
+func TestCacheJSON_NotAnObject(t *testing.T) {
+	for _, payload := range []string{"hello", "[1,2]", "42", "null"} {
+		msg := types.Message{Payload: []byte(payload)}
+		CacheJSON(&msg)
+		if !msg.JSONParsed || msg.JSON != nil {
+			t.Errorf("CacheJSON(%q) = %v (parsed %v), want nil, parsed", payload, msg.JSON, msg.JSONParsed)
+		}
+		if got := MessageJSON(msg); got != nil {
+			t.Errorf("MessageJSON(%q) = %v, want nil", payload, got)
+		}
+	}
+}
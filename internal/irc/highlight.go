@@ -0,0 +1,61 @@
+package irc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// zeroWidthSpace breaks a nick without changing how it looks.
+const zeroWidthSpace = "\u200b"
+
+// Unhighlight breaks the nicks of channel's members in s with a zero-width
+// space after their first character, so bridged text naming them does not
+// highlight them. Without channel state s is returned unchanged.
+func (c *Client) Unhighlight(channel, s string) string {
+	ch := c.client.LookupChannel(channel)
+	if ch == nil {
+		return s
+	}
+	return unhighlight(s, ch.UserIn)
+}
+
+// unhighlight breaks the words of s for which member reports true. Words are
+// runs of the characters nicks are made of.
+func unhighlight(s string, member func(nick string) bool) string {
+	var sb strings.Builder
+	start := -1
+	flush := func(end int) {
+		word := s[start:end]
+		if _, size := utf8.DecodeRuneInString(word); size < len(word) && member(word) {
+			sb.WriteString(word[:size])
+			sb.WriteString(zeroWidthSpace)
+			sb.WriteString(word[size:])
+		} else {
+			sb.WriteString(word)
+		}
+		start = -1
+	}
+	for i, r := range s {
+		if isNickChar(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			flush(i)
+		}
+		sb.WriteRune(r)
+	}
+	if start >= 0 {
+		flush(len(s))
+	}
+	return sb.String()
+}
+
+// isNickChar reports whether r may appear in a nick (RFC 2812, with the
+// characters servers commonly allow beyond it).
+func isNickChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		strings.ContainsRune("[]\\`_^{|}-", r)
+}
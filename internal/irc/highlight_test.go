@@ -0,0 +1,24 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnhighlight(t *testing.T) {
+	members := map[string]bool{"alice": true, "bob_": true, "x": true}
+	member := func(nick string) bool { return members[strings.ToLower(nick)] }
+
+	for in, want := range map[string]string{
+		"no members here":     "no members here",
+		"Alice: ping bob_!":   "A\u200blice: ping b\u200bob_!",
+		"malice and alice2":   "malice and alice2",
+		"x marks the spot":    "x marks the spot", // one-letter nicks cannot be broken
+		"<alice> héllo alice": "<a\u200blice> héllo a\u200blice",
+		"":                    "",
+	} {
+		if got := unhighlight(in, member); got != want {
+			t.Errorf("unhighlight(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
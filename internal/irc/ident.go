@@ -0,0 +1,122 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ident modes (irc.ident.mode).
+const (
+	IdentOff     = ""
+	IdentServer  = "server"  // built-in RFC 1413 responder
+	IdentOidentd = "oidentd" // write the user's oidentd config before connecting
+)
+
+// identTimeout bounds how long an ident query connection may stay open.
+const identTimeout = 10 * time.Second
+
+// identUser returns the user name reported by ident.
+func (c *Client) identUser() string {
+	switch {
+	case c.config.Ident.Username != "":
+		return c.config.Ident.Username
+	case c.config.Username != "":
+		return c.config.Username
+	default:
+		return c.config.Nickname
+	}
+}
+
+// startIdent sets up ident for irc.ident.mode before the first connection
+// attempt. The built-in responder runs until ctx is done.
+func (c *Client) startIdent(ctx context.Context) error {
+	switch c.config.Ident.Mode {
+	case IdentServer:
+		ln, err := net.Listen("tcp", c.config.Ident.Listen)
+		if err != nil {
+			return fmt.Errorf("identd: %w", err)
+		}
+		c.logger.Info().Str("listen", ln.Addr().String()).Str("user", c.identUser()).Msg("identd listening")
+		go func() {
+			<-ctx.Done()
+			ln.Close()
+		}()
+		go c.serveIdent(ln)
+	case IdentOidentd:
+		path, err := writeOidentdConf(c.config.Ident.OidentdFile, c.identUser())
+		if err != nil {
+			return err
+		}
+		c.logger.Info().Str("file", path).Str("user", c.identUser()).Msg("oidentd config written")
+	}
+	return nil
+}
+
+// serveIdent answers ident queries until ln is closed.
+func (c *Client) serveIdent(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go c.answerIdent(conn)
+	}
+}
+
+// answerIdent answers a single query. Only queries about a connection to the
+// current IRC server's port get the user name.
+func (c *Client) answerIdent(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(identTimeout))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	reply := identReply(line, c.currentServer().port, c.identUser())
+	c.logger.Debug().Str("remote", conn.RemoteAddr().String()).Str("query", strings.TrimSpace(line)).Str("reply", reply).Msg("ident query")
+	fmt.Fprintf(conn, "%s\r\n", reply)
+}
+
+// identReply builds the RFC 1413 response to query ("<local port> , <remote
+// port>"), reporting user for connections to serverPort.
+func identReply(query string, serverPort int, user string) string {
+	local, remote, ok := strings.Cut(strings.TrimSpace(query), ",")
+	if !ok {
+		return "0 , 0 : ERROR : INVALID-PORT"
+	}
+	local, remote = strings.TrimSpace(local), strings.TrimSpace(remote)
+	lport, err1 := strconv.Atoi(local)
+	rport, err2 := strconv.Atoi(remote)
+	if err1 != nil || err2 != nil || lport < 1 || lport > 65535 || rport < 1 || rport > 65535 {
+		return fmt.Sprintf("%s , %s : ERROR : INVALID-PORT", local, remote)
+	}
+	if rport != serverPort {
+		return fmt.Sprintf("%d , %d : ERROR : NO-USER", lport, rport)
+	}
+	return fmt.Sprintf("%d , %d : USERID : UNIX : %s", lport, rport, user)
+}
+
+// writeOidentdConf writes an oidentd user config replying with user and
+// returns its path. A leading ~ in path is the home directory.
+func writeOidentdConf(path, user string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("oidentd config: %w", err)
+		}
+		path = filepath.Join(home, rest)
+	}
+	conf := fmt.Sprintf("global {\n\treply %q\n}\n", user)
+	if err := os.WriteFile(path, []byte(conf), 0o644); err != nil {
+		return "", fmt.Errorf("oidentd config: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,88 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+)
+
+func TestIdentReply(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"6193, 6667\r\n", "6193 , 6667 : USERID : UNIX : bridge"},
+		{" 6193 ,6667", "6193 , 6667 : USERID : UNIX : bridge"},
+		{"6193, 23", "6193 , 23 : ERROR : NO-USER"},
+		{"6193, 70000", "6193 , 70000 : ERROR : INVALID-PORT"},
+		{"abc, 6667", "abc , 6667 : ERROR : INVALID-PORT"},
+		{"garbage", "0 , 0 : ERROR : INVALID-PORT"},
+	}
+	for _, tt := range tests {
+		if got := identReply(tt.query, 6667, "bridge"); got != tt.want {
+			t.Errorf("identReply(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestIdentServer(t *testing.T) {
+	cfg := config.IRCConfig{
+		Server:    "irc.example.org:6697",
+		Nickname:  "bot",
+		Username:  "mqtt2irc",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+		Ident:     config.IdentConfig{Mode: IdentServer, Listen: "127.0.0.1:0"},
+	}
+	c := New(cfg, connstate.New("irc", 0), zerolog.New(os.Stderr).Level(zerolog.Disabled))
+
+	ln, err := net.Listen("tcp", cfg.Ident.Listen)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go c.serveIdent(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "51234 , 6697\r\n")
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := "51234 , 6697 : USERID : UNIX : mqtt2irc\r\n"; reply != want {
+		t.Errorf("reply = %q, want %q", reply, want)
+	}
+}
+
+func TestStartIdent_Oidentd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oidentd.conf")
+	cfg := config.IRCConfig{
+		Server:    "irc.example.org:6697",
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+		Ident:     config.IdentConfig{Mode: IdentOidentd, OidentdFile: path},
+	}
+	c := New(cfg, connstate.New("irc", 0), zerolog.New(os.Stderr).Level(zerolog.Disabled))
+	if err := c.startIdent(context.Background()); err != nil {
+		t.Fatalf("startIdent: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := "global {\n\treply \"bot\"\n}\n"; string(data) != want {
+		t.Errorf("oidentd config = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,70 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lrstanley/girc"
+)
+
+// keepalive actively verifies the connection by sending a PING with a unique
+// token and waiting for the matching PONG. girc's IsConnected can stay true on
+// a half-open TCP connection; a missed PONG marks the client as not connected
+// (so /ready reports not-ready) and closes the connection so the supervisor
+// reconnects.
+func (c *Client) keepalive(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Keepalive.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if c.isStopped() || !c.client.IsConnected() {
+			continue
+		}
+
+		token := fmt.Sprintf("mqtt2irc-%d", time.Now().UnixNano())
+		pong := make(chan struct{})
+		c.mu.Lock()
+		c.pingToken, c.pongCh = token, pong
+		c.mu.Unlock()
+
+		start := time.Now()
+		c.client.Cmd.Ping(token)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-pong:
+			c.logger.Debug().Dur("rtt", time.Since(start)).Msg("IRC keepalive ok")
+		case <-time.After(c.config.Keepalive.Timeout):
+			c.logger.Warn().
+				Dur("timeout", c.config.Keepalive.Timeout).
+				Msg("IRC keepalive failed, forcing reconnect")
+			c.mu.Lock()
+			c.keepaliveFailed = true
+			c.downDetail = fmt.Sprintf("keepalive: no PONG within %s", c.config.Keepalive.Timeout)
+			c.mu.Unlock()
+			c.client.Close()
+		}
+
+		c.mu.Lock()
+		c.pingToken, c.pongCh = "", nil
+		c.mu.Unlock()
+	}
+}
+
+// onPong completes an outstanding keepalive check when its token comes back.
+func (c *Client) onPong(client *girc.Client, event girc.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pongCh != nil && event.Last() == c.pingToken {
+		close(c.pongCh)
+		c.pongCh = nil
+	}
+}
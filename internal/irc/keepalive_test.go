@@ -0,0 +1,68 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lrstanley/girc"
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+)
+
+func TestOnPong_MatchesToken(t *testing.T) {
+	c := New(config.IRCConfig{
+		Server:    "localhost:6667",
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+
+	pong := make(chan struct{})
+	c.pingToken, c.pongCh = "mqtt2irc-1", pong
+
+	// A PONG for girc's own keepalive must not complete our check.
+	c.onPong(c.client, girc.Event{Command: girc.PONG, Params: []string{"server", "girc-timestamp"}})
+	select {
+	case <-pong:
+		t.Fatal("unrelated PONG completed the keepalive check")
+	default:
+	}
+
+	c.onPong(c.client, girc.Event{Command: girc.PONG, Params: []string{"server", "mqtt2irc-1"}})
+	select {
+	case <-pong:
+	default:
+		t.Fatal("matching PONG did not complete the keepalive check")
+	}
+
+	// A duplicate PONG must not panic on the already-closed channel.
+	c.onPong(c.client, girc.Event{Command: girc.PONG, Params: []string{"server", "mqtt2irc-1"}})
+}
+
+func TestIsConnected_KeepaliveFailed(t *testing.T) {
+	c := New(config.IRCConfig{
+		Server:    "localhost:6667",
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+	c.keepaliveFailed = true
+	if c.IsConnected() {
+		t.Error("IsConnected() should be false after a failed keepalive")
+	}
+}
+
+func TestWaitConnected_ContextDone(t *testing.T) {
+	c := New(config.IRCConfig{
+		Server:    "localhost:6667",
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.WaitConnected(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitConnected while disconnected = %v, want deadline exceeded", err)
+	}
+}
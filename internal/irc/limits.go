@@ -0,0 +1,106 @@
+package irc
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// DefaultMaxLength applies when Limits.MaxLength is not set.
+const DefaultMaxLength = 400
+
+// Limits bounds the length of one IRC message.
+type Limits struct {
+	MaxLength int    // characters, or display columns in Width mode (0 = DefaultMaxLength)
+	MaxBytes  int    // UTF-8 bytes (0 = no limit); servers cut lines at 512 bytes, prefix included
+	Width     bool   // count wide characters (CJK, most emoji) as two columns
+	Suffix    string // appended when text is cut
+}
+
+// Fit sanitizes s and shortens it to the limits: optional sections (see
+// Funcs) are dropped first, last first, then the text is cut.
+func (l Limits) Fit(s string) string {
+	if containsMarkers(s) {
+		return fitOptional(s, l)
+	}
+	return l.cut(sanitize(s))
+}
+
+// measure returns the length of s in characters or columns.
+func (l Limits) measure(s string) int {
+	if !l.Width {
+		return utf8.RuneCountInString(s)
+	}
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+func (l Limits) maxLength() int {
+	if l.MaxLength <= 0 {
+		return DefaultMaxLength
+	}
+	return l.MaxLength
+}
+
+// fits reports whether s is within the limits.
+func (l Limits) fits(s string) bool {
+	return l.measure(s) <= l.maxLength() && (l.MaxBytes <= 0 || len(s) <= l.MaxBytes)
+}
+
+// cut truncates s at a character boundary so that it fits with the suffix.
+func (l Limits) cut(s string) string {
+	if l.fits(s) {
+		return s
+	}
+
+	// Reserve space for suffix
+	lengthBudget := l.maxLength() - l.measure(l.Suffix)
+	byteBudget := l.MaxBytes - len(l.Suffix)
+	if lengthBudget <= 0 || (l.MaxBytes > 0 && byteBudget <= 0) {
+		return l.Suffix
+	}
+
+	length, end := 0, 0
+	for i, r := range s {
+		w := 1
+		if l.Width {
+			w = runeWidth(r)
+		}
+		next := i + utf8.RuneLen(r)
+		if length+w > lengthBudget || (l.MaxBytes > 0 && next > byteBudget) {
+			break
+		}
+		length, end = length+w, next
+	}
+	return s[:end] + l.Suffix
+}
+
+// FitWith appends tail to s, a line that fits, cutting s further if needed
+// so that tail is kept whole, e.g. for an annotation. If tail alone does not
+// fit, s is returned unchanged.
+func (l Limits) FitWith(s, tail string) string {
+	if l.fits(s + tail) {
+		return s + tail
+	}
+	room := l
+	room.MaxLength = l.maxLength() - l.measure(tail)
+	if l.MaxBytes > 0 {
+		room.MaxBytes = l.MaxBytes - len(tail)
+	}
+	if room.MaxLength <= 0 || (l.MaxBytes > 0 && room.MaxBytes <= 0) {
+		return s
+	}
+	return room.cut(s) + tail
+}
+
+// runeWidth returns the display width of r in a terminal-style IRC client.
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,133 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLimits_Fit(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		input  string
+		want   string
+	}{
+		{
+			name:   "within limits",
+			limits: Limits{MaxLength: 10, MaxBytes: 10, Suffix: "..."},
+			input:  "hello",
+			want:   "hello",
+		},
+		{
+			name:   "rune limit",
+			limits: Limits{MaxLength: 8, Suffix: "..."},
+			input:  "hello world",
+			want:   "hello...",
+		},
+		{
+			name:   "byte limit cuts multibyte text",
+			limits: Limits{MaxLength: 100, MaxBytes: 12, Suffix: "..."},
+			input:  "日本語のテキストです",
+			want:   "日本語...",
+		},
+		{
+			name:   "byte limit never splits a rune",
+			limits: Limits{MaxLength: 100, MaxBytes: 10, Suffix: "..."},
+			input:  "ááááááá",
+			want:   "ááá...",
+		},
+		{
+			name:   "width mode counts CJK as two columns",
+			limits: Limits{MaxLength: 9, Width: true, Suffix: "..."},
+			input:  "日本語のテキスト",
+			want:   "日本語...",
+		},
+		{
+			name:   "width mode fits narrow text",
+			limits: Limits{MaxLength: 9, Width: true, Suffix: "..."},
+			input:  "abcdefghi",
+			want:   "abcdefghi",
+		},
+		{
+			name:   "width mode counts emoji as two columns",
+			limits: Limits{MaxLength: 6, Width: true, Suffix: "…"},
+			input:  "🌍🌍🌍🌍",
+			want:   "🌍🌍…",
+		},
+		{
+			name:   "suffix larger than the budget",
+			limits: Limits{MaxLength: 2, Suffix: "..."},
+			input:  "hello",
+			want:   "...",
+		},
+		{
+			name:   "default length",
+			limits: Limits{},
+			input:  strings.Repeat("a", DefaultMaxLength+1),
+			want:   strings.Repeat("a", DefaultMaxLength),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.limits.Fit(tt.input)
+			if got != tt.want {
+				t.Errorf("Fit(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Fit(%q) returned invalid UTF-8", tt.input)
+			}
+		})
+	}
+}
+
+func TestLimits_FitOptional(t *testing.T) {
+	limits := Limits{MaxLength: 100, MaxBytes: 12, Suffix: "..."}
+	got := limits.Fit("温度 20" + optStart + " 湿度 50" + optEnd)
+	if got != "温度 20" {
+		t.Errorf("Fit() = %q, want the optional section dropped by byte length", got)
+	}
+}
+
+func BenchmarkLimitsFit(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		limits Limits
+		input  string
+	}{
+		{"short", Limits{Suffix: "..."}, "[sensors/env] temp=21.5 humidity=48"},
+		{"truncated", Limits{MaxLength: 100, Suffix: "..."}, strings.Repeat("lorem ipsum ", 50)},
+		{"bytes", Limits{MaxBytes: 400, Suffix: "..."}, strings.Repeat("日本語のテキスト", 30)},
+		{"width", Limits{Width: true, Suffix: "..."}, strings.Repeat("日本語のテキスト", 30)},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bm.limits.Fit(bm.input)
+			}
+		})
+	}
+}
+
+func TestLimits_FitWith(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		input  string
+		want   string
+	}{
+		{"room left", Limits{MaxLength: 20, Suffix: "..."}, "hello", "hello (+2.3s)"},
+		{"line cut for the tail", Limits{MaxLength: 16, Suffix: "..."}, "hello world", "hello... (+2.3s)"},
+		{"byte limit", Limits{MaxLength: 100, MaxBytes: 17, Suffix: "..."}, "ááááá", "ááá... (+2.3s)"},
+		{"tail too long", Limits{MaxLength: 5, Suffix: "..."}, "hello", "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.FitWith(tt.input, " (+2.3s)"); got != tt.want {
+				t.Errorf("FitWith(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
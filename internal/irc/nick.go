@@ -0,0 +1,96 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// wantedNick returns the nick the bot should be using: the configured one, or
+// the one last requested with Nick.
+func (c *Client) wantedNick() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.desiredNick != "" {
+		return c.desiredNick
+	}
+	return c.config.Nickname
+}
+
+// fallbackNick is girc's nick collision handler. During registration it moves
+// to nick_ (nick__, ...) so the connection completes; once registered a failed
+// NICK (e.g. a reclaim attempt) keeps the current nick.
+func (c *Client) fallbackNick(current string) string {
+	c.mu.RLock()
+	registered := c.sessionUp
+	c.mu.RUnlock()
+	if registered {
+		c.logger.Debug().Str("nick", current).Msg("IRC nick change rejected, keeping current nick")
+		return ""
+	}
+	c.logger.Warn().Str("nick", current).Str("fallback", current+"_").Msg("IRC nick in use, using fallback nick")
+	return current + "_"
+}
+
+// identify authenticates with NickServ. On a fallback nick the account name is
+// given explicitly, as IDENTIFY would otherwise target the fallback nick.
+func (c *Client) identify() {
+	c.logger.Info().Msg("authenticating with NickServ")
+	if strings.EqualFold(c.client.GetNick(), c.config.Nickname) {
+		c.client.Cmd.Message("NickServ", fmt.Sprintf("IDENTIFY %s", c.config.NickServPassword))
+		return
+	}
+	c.client.Cmd.Message("NickServ", fmt.Sprintf("IDENTIFY %s %s", c.config.Nickname, c.config.NickServPassword))
+}
+
+// reclaim asks services to release the wanted nick (if configured) and
+// switches to it. It does nothing when the bot already has the nick.
+func (c *Client) reclaim() {
+	want := c.wantedNick()
+	current := c.client.GetNick()
+	if strings.EqualFold(current, want) {
+		return
+	}
+	c.logger.Info().Str("nick", current).Str("wanted", want).Msg("reclaiming IRC nick")
+	if c.config.NickServPassword != "" {
+		for _, m := range reclaimMessages(c.config.NickReclaim.Command, want, c.config.NickServPassword) {
+			c.client.Cmd.Message("NickServ", m)
+		}
+	}
+	c.client.Cmd.Nick(want)
+}
+
+// reclaimMessages returns the NickServ messages that free nick for our
+// account. Networks differ in which of the commands they support.
+func reclaimMessages(command, nick, password string) []string {
+	switch command {
+	case "regain", "recover", "ghost":
+		return []string{fmt.Sprintf("%s %s %s", strings.ToUpper(command), nick, password)}
+	default:
+		return nil
+	}
+}
+
+// nickReclaimLoop periodically retries the wanted nick while the bot is on a
+// fallback nick, e.g. after a services enforcer renamed it to Guest12345.
+func (c *Client) nickReclaimLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.config.NickReclaim.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.RLock()
+		registered := c.sessionUp
+		c.mu.RUnlock()
+		if c.isStopped() || !registered || !c.client.IsConnected() {
+			continue
+		}
+		c.reclaim()
+	}
+}
@@ -0,0 +1,63 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+)
+
+func TestReclaimMessages(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"regain", []string{"REGAIN bot secret"}},
+		{"recover", []string{"RECOVER bot secret"}},
+		{"ghost", []string{"GHOST bot secret"}},
+		{"none", nil},
+	}
+	for _, tt := range tests {
+		if got := reclaimMessages(tt.command, "bot", "secret"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("reclaimMessages(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackNick(t *testing.T) {
+	c := New(config.IRCConfig{
+		Server:    "localhost:6667",
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+
+	// During registration a collision moves to a fallback nick.
+	if got := c.fallbackNick("bot"); got != "bot_" {
+		t.Errorf("fallbackNick during registration = %q, want %q", got, "bot_")
+	}
+
+	// Once registered, a rejected reclaim keeps the current nick.
+	c.sessionUp = true
+	if got := c.fallbackNick("bot_"); got != "" {
+		t.Errorf("fallbackNick after registration = %q, want empty", got)
+	}
+}
+
+func TestWantedNick(t *testing.T) {
+	c := New(config.IRCConfig{
+		Server:    "localhost:6667",
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+
+	if got := c.wantedNick(); got != "bot" {
+		t.Errorf("wantedNick() = %q, want %q", got, "bot")
+	}
+	c.desiredNick = "newbot"
+	if got := c.wantedNick(); got != "newbot" {
+		t.Errorf("wantedNick() after Nick = %q, want %q", got, "newbot")
+	}
+}
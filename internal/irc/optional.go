@@ -0,0 +1,97 @@
+package irc
+
+import (
+	"strings"
+	"text/template"
+)
+
+// Markers delimiting optional sections in rendered output. They are private
+// use code points, removed by SanitizeAndTruncate. Payloads may contain them
+// too, so values passed to templates go through StripMarkers first and only
+// {{opt}} and {{endopt}} produce them.
+const (
+	optStart = "\ue000"
+	optEnd   = "\ue001"
+)
+
+var markerStripper = strings.NewReplacer(optStart, "", optEnd, "")
+
+// StripMarkers removes optional section markers from s. Template data taken
+// from messages must be stripped so it cannot open or close sections.
+func StripMarkers(s string) string {
+	if !containsMarkers(s) {
+		return s
+	}
+	return markerStripper.Replace(s)
+}
+
+// Funcs are the functions available to message templates:
+//
+//	{{opt}} starts an optional section, running to {{endopt}}, the next
+//	{{opt}} or the end of the line. When a message is too long, optional
+//	sections are dropped, last first, before the text is cut, e.g.
+//	"{{.JSON.temp}}°C{{opt}} hum={{.JSON.hum}}%{{opt}} rssi={{.JSON.rssi}}".
+var Funcs = template.FuncMap{
+	"opt":    func() string { return optStart },
+	"endopt": func() string { return optEnd },
+}
+
+// segment is a run of rendered text, either required or optional.
+type segment struct {
+	text     string
+	optional bool
+}
+
+// splitOptional splits s at the optional section markers.
+func splitOptional(s string) []segment {
+	var segs []segment
+	var cur strings.Builder
+	optional := false
+	flush := func() {
+		if cur.Len() > 0 {
+			segs = append(segs, segment{text: cur.String(), optional: optional})
+			cur.Reset()
+		}
+	}
+	for _, r := range s {
+		switch string(r) {
+		case optStart:
+			flush()
+			optional = true
+		case optEnd:
+			flush()
+			optional = false
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return segs
+}
+
+// containsMarkers reports whether s has optional sections.
+func containsMarkers(s string) bool {
+	return strings.ContainsAny(s, optStart+optEnd)
+}
+
+// fitOptional sanitizes s and fits it into limits by dropping optional
+// sections from the end, then truncating the rest if still too long.
+func fitOptional(s string, limits Limits) string {
+	segs := splitOptional(s)
+	join := func() string {
+		var sb strings.Builder
+		for _, seg := range segs {
+			sb.WriteString(seg.text)
+		}
+		return sanitize(sb.String())
+	}
+
+	out := join()
+	for i := len(segs) - 1; i >= 0 && !limits.fits(out); i-- {
+		if segs[i].optional {
+			segs = append(segs[:i], segs[i+1:]...)
+			out = join()
+		}
+	}
+	return limits.cut(out)
+}
@@ -0,0 +1,70 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestFormatMessage_OptionalSections(t *testing.T) {
+	msg := types.Message{Topic: "sensors/env", Payload: []byte(`{"temp":21.5,"hum":40,"rssi":-70}`)}
+	format := "{{.JSON.temp}}C{{opt}} hum={{.JSON.hum}}%{{endopt}} [env]{{opt}} rssi={{.JSON.rssi}}"
+
+	tests := []struct {
+		maxLen int
+		want   string
+	}{
+		{100, "21.5C hum=40% [env] rssi=-70"},
+		{20, "21.5C hum=40% [env]"}, // last optional section dropped first
+		{12, "21.5C [env]"},         // then the earlier one
+		{8, "21.5C..."},             // required text is cut as before
+	}
+	for _, tt := range tests {
+		got, err := FormatMessage(msg, format, tt.maxLen, "...")
+		if err != nil {
+			t.Fatalf("FormatMessage: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("maxLen %d: got %q, want %q", tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeAndTruncate_StripsMarkers(t *testing.T) {
+	s := "a" + optStart + " b" + optEnd + " c"
+	if got := SanitizeAndTruncate(s, 100, "..."); got != "a b c" {
+		t.Errorf("got %q, want markers removed", got)
+	}
+}
+
+func TestFormatMessage_PayloadMarkers(t *testing.T) {
+	// Marker code points in the payload must not open or close optional
+	// sections: "a" would make " req" optional, "b" would make " y" required.
+	msg := types.Message{
+		Topic:   "sensors/env",
+		Payload: []byte(`{"a":"A` + optStart + `","b":"1` + optEnd + `"}`),
+	}
+	format := "{{.JSON.a}} req{{opt}} x={{.JSON.b}} y"
+
+	tests := []struct {
+		maxLen int
+		want   string
+	}{
+		{100, "A req x=1 y"},
+		{8, "A req"},
+	}
+	for _, tt := range tests {
+		got, err := FormatMessage(msg, format, tt.maxLen, "...")
+		if err != nil {
+			t.Fatalf("FormatMessage: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("maxLen %d: got %q, want %q", tt.maxLen, got, tt.want)
+		}
+	}
+
+	raw := types.Message{Topic: "t", Payload: []byte("a" + optStart + "b" + optEnd)}
+	if got := RenderMessage(raw, "{{.Payload}}", Target{}); containsMarkers(got) {
+		t.Errorf("RenderMessage(%q) kept payload markers: %q", raw.Payload, got)
+	}
+}
@@ -0,0 +1,59 @@
+package irc
+
+import (
+	"context"
+	"time"
+)
+
+// paceWindow is the period over which the current send pace is measured.
+const paceWindow = time.Minute
+
+// recordSend notes a sent message for Pace.
+func (c *Client) recordSend(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(trimSent(c.sent, now), now)
+}
+
+// trimSent drops send times older than paceWindow.
+func trimSent(sent []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(sent) && now.Sub(sent[i]) >= paceWindow {
+		i++
+	}
+	return sent[i:]
+}
+
+// Pace returns the number of messages sent in the last minute.
+func (c *Client) Pace() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = trimSent(c.sent, time.Now())
+	return len(c.sent)
+}
+
+// SendMessageTimed is SendMessage for latency checks: the message is built by
+// compose only after the rate limiter let it through, with the time spent
+// waiting.
+func (c *Client) SendMessageTimed(ctx context.Context, channel string, compose func(wait time.Duration) string) error {
+	c.JoinChannel(channel)
+
+	start := time.Now()
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	message := compose(time.Since(start))
+	if c.safeMode {
+		c.logSafeMode(channel, message)
+		return nil
+	}
+
+	c.logger.Debug().
+		Str("channel", channel).
+		Str("message", c.redactLog(message)).
+		Msg("sending message to IRC")
+
+	c.client.Cmd.Message(channel, message)
+	c.recordSend(time.Now())
+	return nil
+}
@@ -0,0 +1,49 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+)
+
+func TestTrimSent(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	sent := []time.Time{
+		now.Add(-2 * time.Minute),
+		now.Add(-time.Minute),
+		now.Add(-59 * time.Second),
+		now.Add(-time.Second),
+	}
+	if got := trimSent(sent, now); len(got) != 2 || !got[0].Equal(sent[2]) {
+		t.Errorf("trimSent() = %v, want the last two entries", got)
+	}
+	if got := trimSent(nil, now); len(got) != 0 {
+		t.Errorf("trimSent(nil) = %v", got)
+	}
+}
+
+func TestSendMessage_RefundsWhenNotConnected(t *testing.T) {
+	cfg := config.IRCConfig{Server: "localhost:6667", Nickname: "bot"}
+	cfg.RateLimit.MessagesPerSecond = 1.0 / 3600 // no refill during the test
+	cfg.RateLimit.Burst = 1
+	c := New(cfg, connstate.New("irc", 0), zerolog.New(os.Stderr).Level(zerolog.Disabled))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		// Without the refund the second send would wait an hour for a token.
+		if err := c.SendMessage(ctx, "#ch", "hello"); !errors.Is(err, ErrNotConnected) {
+			t.Fatalf("send %d: err = %v, want ErrNotConnected", i, err)
+		}
+	}
+	if tokens := c.limiter.Tokens(); tokens < 1 {
+		t.Errorf("tokens = %.2f after failed sends, want the burst back", tokens)
+	}
+}
@@ -0,0 +1,29 @@
+package irc
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer keeps the occasional huge payload from pinning memory in
+// the pool.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// GetBuffer returns an empty buffer from a shared pool. Return it with
+// PutBuffer once its contents have been copied out (e.g. with String()).
+func GetBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// PutBuffer resets buf and returns it to the pool.
+func PutBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
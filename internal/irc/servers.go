@@ -0,0 +1,249 @@
+package irc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+const (
+	defaultIRCPort    = 6667
+	defaultIRCTLSPort = 6697
+	minConnectBackoff = time.Second
+	maxConnectBackoff = 60 * time.Second
+)
+
+// serverAddr is a single IRC server endpoint.
+type serverAddr struct {
+	host string
+	port int
+}
+
+func (a serverAddr) String() string {
+	return net.JoinHostPort(a.host, strconv.Itoa(a.port))
+}
+
+// parseServer parses a "host" or "host:port" server string. IPv6 literals
+// are written "[2001:db8::1]:6697", or bare without a port.
+func parseServer(s string) serverAddr {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		// No port (or a bare IPv6 literal).
+		return serverAddr{host: strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"), port: defaultIRCPort}
+	}
+	addr := serverAddr{host: host, port: defaultIRCPort}
+	if port, err := strconv.Atoi(portStr); err == nil {
+		addr.port = port
+	}
+	return addr
+}
+
+// serverList returns the configured servers in failover order: irc.servers if
+// set, otherwise the single irc.server. With only irc.srv_domain configured,
+// the domain itself is used until SRV resolution succeeds.
+func serverList(cfg config.IRCConfig) []serverAddr {
+	if len(cfg.Servers) == 0 && cfg.Server == "" && cfg.SRVDomain != "" {
+		addr := serverAddr{host: cfg.SRVDomain, port: defaultIRCPort}
+		if cfg.UseTLS {
+			addr.port = defaultIRCTLSPort
+		}
+		return []serverAddr{addr}
+	}
+	if len(cfg.Servers) == 0 {
+		return []serverAddr{parseServer(cfg.Server)}
+	}
+	servers := make([]serverAddr, 0, len(cfg.Servers))
+	for _, s := range cfg.Servers {
+		servers = append(servers, parseServer(s))
+	}
+	return servers
+}
+
+// srvService returns the SRV service name for the configured transport.
+func (c *Client) srvService() string {
+	if c.config.UseTLS {
+		return "ircs"
+	}
+	return "irc"
+}
+
+// resolveServers looks up the SRV records for irc.srv_domain
+// (_ircs._tcp.<domain> with TLS, _irc._tcp.<domain> without) and replaces the
+// server list with the targets in priority/weight order. On lookup failure the
+// previous list is kept.
+func (c *Client) resolveServers() {
+	if c.config.SRVDomain == "" {
+		return
+	}
+	_, records, err := c.lookupSRV(c.srvService(), "tcp", c.config.SRVDomain)
+	if err != nil || len(records) == 0 {
+		c.logger.Warn().Err(err).Str("domain", c.config.SRVDomain).Msg("IRC SRV lookup failed, keeping previous server list")
+		return
+	}
+
+	servers := make([]serverAddr, 0, len(records))
+	for _, r := range records {
+		servers = append(servers, serverAddr{host: strings.TrimSuffix(r.Target, "."), port: int(r.Port)})
+	}
+
+	c.mu.Lock()
+	c.servers = servers
+	c.serverIdx = 0
+	c.mu.Unlock()
+
+	c.logger.Debug().Str("domain", c.config.SRVDomain).Int("servers", len(servers)).Msg("resolved IRC servers via SRV")
+}
+
+// currentServer returns the server the client is (or will be) connecting to.
+func (c *Client) currentServer() serverAddr {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.servers[c.serverIdx]
+}
+
+// serverCount returns the number of servers in the current list.
+func (c *Client) serverCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.servers)
+}
+
+// rotate advances to the next server in round-robin order.
+func (c *Client) rotate() serverAddr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serverIdx = (c.serverIdx + 1) % len(c.servers)
+	return c.servers[c.serverIdx]
+}
+
+// stop makes the supervisor exit and closes the current connection.
+func (c *Client) stop() {
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+	c.client.Close()
+}
+
+func (c *Client) isStopped() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stopped
+}
+
+// supervise keeps the client connected. A failed connection attempt moves on to
+// the next server; an established session that ends reconnects to the same one.
+// After each full round of failures it backs off exponentially. Until the first
+// successful connection, a full round of failures is reported on failed.
+func (c *Client) supervise(ctx context.Context, failed chan<- error) {
+	backoff := minConnectBackoff
+	if c.startBackoff > 0 {
+		backoff = c.startBackoff
+	}
+	failures := 0
+	resolve := true
+
+	for {
+		// SRV records are re-resolved at the start of every round and before
+		// reconnecting an ended session, so rotating server pools are followed.
+		if resolve {
+			c.resolveServers()
+			resolve = false
+		}
+
+		addr := c.currentServer()
+		c.mu.Lock()
+		c.sessionUp = false
+		c.client.Config.Server = addr.host
+		c.client.Config.Port = addr.port
+		c.mu.Unlock()
+
+		err := c.client.Connect()
+		if ctx.Err() != nil || c.isStopped() {
+			return
+		}
+
+		c.mu.RLock()
+		wasUp, everReady := c.sessionUp, c.readyClosed
+		c.mu.RUnlock()
+
+		var delay time.Duration
+		if wasUp {
+			c.logger.Warn().Err(err).Str("server", addr.String()).Msg("IRC session ended, reconnecting")
+			failures = 0
+			backoff = minConnectBackoff
+			resolve = true
+		} else {
+			failures++
+			next := c.rotate()
+			c.logger.Warn().Err(err).Str("server", addr.String()).Str("next", next.String()).Msg("IRC connect failed")
+			if failures%c.serverCount() == 0 {
+				resolve = true
+				if !everReady {
+					select {
+					case failed <- err:
+					default:
+					}
+				}
+				delay = backoff
+				backoff *= 2
+				if backoff > maxConnectBackoff {
+					backoff = maxConnectBackoff
+				}
+			}
+		}
+
+		if flap := c.history.Backoff(); flap > 0 {
+			c.logger.Warn().Dur("backoff", flap).Msg("IRC connection flapping, delaying reconnect")
+			delay += flap
+		}
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// failback periodically checks whether the primary server is reachable again
+// while connected to a fallback, and switches back to it.
+func (c *Client) failback(ctx context.Context) {
+	ticker := time.NewTicker(c.config.FailbackInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.RLock()
+		onFallback := c.serverIdx != 0
+		c.mu.RUnlock()
+		if !onFallback || !c.IsConnected() || c.isStopped() {
+			continue
+		}
+
+		c.mu.RLock()
+		primary := c.servers[0]
+		c.mu.RUnlock()
+		conn, err := net.DialTimeout("tcp", primary.String(), 10*time.Second)
+		if err != nil {
+			c.logger.Debug().Err(err).Str("server", primary.String()).Msg("primary IRC server still unreachable")
+			continue
+		}
+		conn.Close()
+
+		c.logger.Info().Str("server", primary.String()).Msg("primary IRC server reachable, failing back")
+		c.mu.Lock()
+		c.serverIdx = 0
+		c.mu.Unlock()
+		c.client.Close()
+	}
+}
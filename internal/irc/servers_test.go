@@ -0,0 +1,104 @@
+package irc
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+)
+
+func TestParseServer(t *testing.T) {
+	tests := []struct {
+		in   string
+		want serverAddr
+	}{
+		{"irc.libera.chat:6697", serverAddr{"irc.libera.chat", 6697}},
+		{"irc.example.net", serverAddr{"irc.example.net", 6667}},
+		{"irc.example.net:bad", serverAddr{"irc.example.net", 6667}},
+		{"[2001:db8::1]:6697", serverAddr{"2001:db8::1", 6697}},
+		{"[2001:db8::1]", serverAddr{"2001:db8::1", 6667}},
+		{"2001:db8::1", serverAddr{"2001:db8::1", 6667}},
+	}
+	for _, tt := range tests {
+		if got := parseServer(tt.in); got != tt.want {
+			t.Errorf("parseServer(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestServerList(t *testing.T) {
+	v6 := serverList(config.IRCConfig{Server: "[2001:db8::1]:6697"})
+	if len(v6) != 1 || v6[0].String() != "[2001:db8::1]:6697" {
+		t.Errorf("IPv6 server: got %v", v6)
+	}
+
+	single := serverList(config.IRCConfig{Server: "a:6697"})
+	if len(single) != 1 || single[0].String() != "a:6697" {
+		t.Errorf("single server: got %v", single)
+	}
+
+	multi := serverList(config.IRCConfig{Server: "ignored:1", Servers: []string{"a:6697", "b:6697", "c"}})
+	if len(multi) != 3 || multi[0].host != "a" || multi[2].String() != "c:6667" {
+		t.Errorf("server list: got %v", multi)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	c := New(config.IRCConfig{
+		Servers:   []string{"a:6697", "b:6697", "c:6697"},
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+
+	if got := c.currentServer().host; got != "a" {
+		t.Fatalf("initial server = %q, want a", got)
+	}
+	for _, want := range []string{"b", "c", "a"} {
+		if got := c.rotate().host; got != want {
+			t.Errorf("rotate() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestResolveServers(t *testing.T) {
+	c := New(config.IRCConfig{
+		SRVDomain: "example.org",
+		UseTLS:    true,
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+
+	if got := c.currentServer().String(); got != "example.org:6697" {
+		t.Errorf("server before resolution = %q, want example.org:6697", got)
+	}
+
+	var gotService string
+	c.lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		gotService = service
+		return "", []*net.SRV{
+			{Target: "irc1.example.org.", Port: 6697},
+			{Target: "irc2.example.org.", Port: 7000},
+		}, nil
+	}
+	c.resolveServers()
+	if gotService != "ircs" {
+		t.Errorf("SRV service = %q, want ircs", gotService)
+	}
+	if c.serverCount() != 2 || c.currentServer().String() != "irc1.example.org:6697" {
+		t.Errorf("resolved servers: got %v", c.servers)
+	}
+
+	// A failed lookup keeps the previous list.
+	c.lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", nil, errors.New("no such host")
+	}
+	c.rotate()
+	c.resolveServers()
+	if c.serverCount() != 2 || c.currentServer().host != "irc2.example.org" {
+		t.Errorf("failed lookup should keep servers and position, got %v idx=%d", c.servers, c.serverIdx)
+	}
+}
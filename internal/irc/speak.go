@@ -0,0 +1,268 @@
+package irc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/lrstanley/girc"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// reasonModerated is why the bot cannot speak in a moderated channel.
+const reasonModerated = "channel is moderated (+m) and the bot has no voice"
+
+// reasonBanned is why the bot is not in a channel whose JOIN was refused.
+const reasonBanned = "banned from the channel (+b)"
+
+// A JOIN refused for a ban is retried after minRejoinBackoff, doubling up to
+// maxRejoinBackoff while the ban stays: outside the channel the bot never
+// sees the -b that would lift it.
+const (
+	minRejoinBackoff = time.Minute
+	maxRejoinBackoff = 30 * time.Minute
+)
+
+// rejoin is the pending JOIN retry of a channel the bot is banned from.
+type rejoin struct {
+	timer   schedule.Timer
+	backoff time.Duration
+}
+
+// OnChannelBlocked sets fn to be called when the bot can no longer speak in
+// a channel (blocked, with the reason) and when it can again. Must be called
+// before Connect.
+func (c *Client) OnChannelBlocked(fn func(channel, reason string, blocked bool)) {
+	c.onBlocked = fn
+}
+
+// OnSendRejected sets fn to be called whenever the server refuses a message
+// or JOIN for a channel (ERR_CANNOTSENDTOCHAN, ERR_NOSUCHCHANNEL,
+// ERR_BANNEDFROMCHAN), with the server's text. Messages are sent without
+// waiting for a reply, so this is how a failed send shows. Must be called
+// before Connect.
+func (c *Client) OnSendRejected(fn func(channel, reason string)) {
+	c.onRejected = fn
+}
+
+// OnJoined sets fn to be called when the bot has joined a channel. Must be
+// called before Connect.
+func (c *Client) OnJoined(fn func(channel string)) {
+	c.onJoined = fn
+}
+
+// rejected reports a refusal of the server for channel to the
+// OnSendRejected callback.
+func (c *Client) rejected(channel string, event girc.Event) {
+	if c.onRejected != nil {
+		c.onRejected(channel, event.Last())
+	}
+}
+
+// Blocked reports whether the bot cannot speak in channel, and why.
+func (c *Client) Blocked(channel string) (reason string, blocked bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	reason, blocked = c.blocked[strings.ToLower(channel)]
+	return reason, blocked
+}
+
+// BlockedChannels returns the channels the bot cannot speak in, with the reasons.
+func (c *Client) BlockedChannels() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]string, len(c.blocked))
+	for channel, reason := range c.blocked {
+		out[channel] = reason
+	}
+	return out
+}
+
+// WaitSpeak blocks until the bot may speak in channel (see Blocked) or ctx
+// is done.
+func (c *Client) WaitSpeak(ctx context.Context, channel string) error {
+	if _, blocked := c.Blocked(channel); !blocked {
+		return nil
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, blocked := c.Blocked(channel); !blocked {
+				return nil
+			}
+		}
+	}
+}
+
+// setBlocked records whether the bot can speak in channel and reports
+// changes to the OnChannelBlocked callback.
+func (c *Client) setBlocked(channel, reason string, blocked bool) {
+	key := strings.ToLower(channel)
+	c.mu.Lock()
+	prev, was := c.blocked[key]
+	if blocked {
+		c.blocked[key] = reason
+	} else {
+		delete(c.blocked, key)
+	}
+	c.mu.Unlock()
+	if was == blocked && (!blocked || prev == reason) {
+		return
+	}
+
+	if blocked {
+		c.logger.Warn().Str("channel", channel).Str("reason", reason).Msg("cannot speak in IRC channel, pausing deliveries")
+	} else {
+		c.logger.Info().Str("channel", channel).Msg("can speak in IRC channel again, resuming deliveries")
+	}
+	if c.onBlocked != nil && was != blocked {
+		c.onBlocked(channel, reason, blocked)
+	}
+}
+
+// speakState reports whether girc's channel and user tracking says the bot
+// can speak in channel. Only moderation is known from the state; bans show
+// up as ERR_CANNOTSENDTOCHAN on the next message.
+func (c *Client) speakState(channel string) (reason string, ok bool) {
+	ch := c.client.LookupChannel(channel)
+	if ch == nil || !ch.Modes.HasMode("m") || c.privileged(channel) {
+		return "", true
+	}
+	return reasonModerated, false
+}
+
+// privileged reports whether the bot has voice, half-op or op in channel.
+func (c *Client) privileged(channel string) bool {
+	me := c.client.LookupUser(c.client.GetNick())
+	if me == nil || me.Perms == nil {
+		return false
+	}
+	perms, ok := me.Perms.Lookup(channel)
+	return ok && (perms.IsAdmin() || perms.HalfOp || perms.Voice)
+}
+
+// recheckSpeak updates a channel's state from girc's tracking.
+func (c *Client) recheckSpeak(channel string) {
+	reason, ok := c.speakState(channel)
+	c.setBlocked(channel, reason, !ok)
+}
+
+// onCannotSend handles ERR_CANNOTSENDTOCHAN: the message just sent was
+// rejected, e.g. for a ban or a moderated channel.
+func (c *Client) onCannotSend(client *girc.Client, event girc.Event) {
+	if len(event.Params) < 2 {
+		return
+	}
+	channel := event.Params[1]
+	reason, ok := c.speakState(channel)
+	if ok {
+		reason = "server: " + event.Last()
+	}
+	c.setBlocked(channel, reason, true)
+	c.rejected(channel, event)
+}
+
+// onBannedFromChan handles ERR_BANNEDFROMCHAN for a JOIN.
+func (c *Client) onBannedFromChan(client *girc.Client, event girc.Event) {
+	if len(event.Params) < 2 {
+		return
+	}
+	channel := event.Params[1]
+	c.setBlocked(channel, reasonBanned, true)
+	c.scheduleRejoin(channel)
+	c.rejected(channel, event)
+}
+
+// onNoSuchChannel handles ERR_NOSUCHCHANNEL, e.g. for a JOIN of a channel
+// with a name the server does not accept.
+func (c *Client) onNoSuchChannel(client *girc.Client, event girc.Event) {
+	if len(event.Params) < 2 {
+		return
+	}
+	c.rejected(event.Params[1], event)
+}
+
+// scheduleRejoin retries the JOIN of channel after the next backoff.
+func (c *Client) scheduleRejoin(channel string) {
+	key := strings.ToLower(channel)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.rejoins[key]
+	if r == nil {
+		r = &rejoin{backoff: minRejoinBackoff}
+		c.rejoins[key] = r
+	} else {
+		r.timer.Stop()
+		r.backoff = min(2*r.backoff, maxRejoinBackoff)
+	}
+	r.timer = c.clock.AfterFunc(r.backoff, func() { c.retryJoin(channel) })
+	c.logger.Info().Str("channel", channel).Dur("backoff", r.backoff).Msg("banned from IRC channel, retrying JOIN later")
+}
+
+// retryJoin joins channel again if the bot is still banned from it. A
+// successful JOIN unblocks it (onJoin); another ERR_BANNEDFROMCHAN schedules
+// the next retry.
+func (c *Client) retryJoin(channel string) {
+	if reason, blocked := c.Blocked(channel); !blocked || reason != reasonBanned || !c.IsConnected() {
+		return
+	}
+	c.logger.Info().Str("channel", channel).Msg("retrying JOIN of IRC channel")
+	c.client.Cmd.Join(channel)
+}
+
+// stopRejoin cancels the JOIN retry of channel, once it is joined.
+func (c *Client) stopRejoin(channel string) {
+	key := strings.ToLower(channel)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r := c.rejoins[key]; r != nil {
+		r.timer.Stop()
+		delete(c.rejoins, key)
+	}
+}
+
+// onMode rechecks a channel after a mode change. A ban or quiet is assumed
+// lifted by any -b or -q, as the masks are not matched against the bot; one
+// that remains blocks the channel again on the next message. Voice or op
+// lifts it too.
+func (c *Client) onMode(client *girc.Client, event girc.Event) {
+	if len(event.Params) < 2 || !girc.IsValidChannel(event.Params[0]) {
+		return
+	}
+	channel, modes := event.Params[0], event.Params[1]
+	if reason, blocked := c.Blocked(channel); blocked && reason != reasonModerated {
+		if !removesMode(modes, "bq") && !c.privileged(channel) {
+			return
+		}
+	}
+	c.recheckSpeak(channel)
+}
+
+// removesMode reports whether a mode string such as "+v-b" removes any of modes.
+func removesMode(change, modes string) bool {
+	adding := true
+	for _, r := range change {
+		switch {
+		case r == '+':
+			adding = true
+		case r == '-':
+			adding = false
+		case !adding && strings.ContainsRune(modes, r):
+			return true
+		}
+	}
+	return false
+}
+
+// onChannelModes handles RPL_CHANNELMODEIS, girc's mode query after a JOIN.
+func (c *Client) onChannelModes(client *girc.Client, event girc.Event) {
+	if len(event.Params) < 2 {
+		return
+	}
+	c.recheckSpeak(event.Params[1])
+}
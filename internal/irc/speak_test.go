@@ -0,0 +1,170 @@
+package irc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lrstanley/girc"
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+func TestChannelBlocked(t *testing.T) {
+	c := New(config.IRCConfig{
+		Server:    "localhost:6667",
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+
+	type change struct {
+		channel string
+		blocked bool
+	}
+	var changes []change
+	c.OnChannelBlocked(func(channel, reason string, blocked bool) {
+		changes = append(changes, change{channel, blocked})
+	})
+
+	c.onCannotSend(c.client, girc.Event{Command: girc.ERR_CANNOTSENDTOCHAN, Params: []string{"bot", "#Sensors", "Cannot send to channel (+b)"}})
+	c.onCannotSend(c.client, girc.Event{Command: girc.ERR_CANNOTSENDTOCHAN, Params: []string{"bot", "#sensors", "Cannot send to channel (+b)"}})
+	reason, blocked := c.Blocked("#sensors")
+	if !blocked || reason != "server: Cannot send to channel (+b)" {
+		t.Fatalf("Blocked(#sensors) = %q, %v", reason, blocked)
+	}
+
+	// Unrelated mode changes keep a ban block; removing a ban lifts it.
+	c.onMode(c.client, girc.Event{Command: girc.MODE, Params: []string{"#sensors", "+l", "20"}})
+	c.onMode(c.client, girc.Event{Command: girc.MODE, Params: []string{"#sensors", "+b", "*!*@other"}})
+	if _, blocked := c.Blocked("#sensors"); !blocked {
+		t.Fatal("ban block lifted by an unrelated mode change")
+	}
+	c.onMode(c.client, girc.Event{Command: girc.MODE, Params: []string{"#sensors", "+v-b", "someone", "*!*@bot.host"}})
+	if _, blocked := c.Blocked("#sensors"); blocked {
+		t.Fatal("ban block kept after -b")
+	}
+
+	c.onBannedFromChan(c.client, girc.Event{Command: girc.ERR_BANNEDFROMCHAN, Params: []string{"bot", "#alerts", "Cannot join channel (+b)"}})
+	if got := c.BlockedChannels(); len(got) != 1 || got["#alerts"] == "" {
+		t.Errorf("BlockedChannels() = %v", got)
+	}
+
+	want := []change{{"#Sensors", true}, {"#sensors", false}, {"#alerts", true}}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %v, want %v", i, changes[i], want[i])
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.WaitSpeak(ctx, "#alerts"); err == nil {
+		t.Error("WaitSpeak returned for a blocked channel")
+	}
+	if err := c.WaitSpeak(context.Background(), "#sensors"); err != nil {
+		t.Errorf("WaitSpeak(#sensors) = %v", err)
+	}
+}
+
+func TestRemovesMode(t *testing.T) {
+	tests := []struct {
+		change string
+		want   bool
+	}{
+		{"-b", true},
+		{"+b", false},
+		{"+v-q", true},
+		{"-v+b", false},
+		{"+m", false},
+	}
+	for _, tt := range tests {
+		if got := removesMode(tt.change, "bq"); got != tt.want {
+			t.Errorf("removesMode(%q) = %v, want %v", tt.change, got, tt.want)
+		}
+	}
+}
+
+func TestBannedFromChan_Rejoin(t *testing.T) {
+	c := New(config.IRCConfig{
+		Server:    "localhost:6667",
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	c.SetClock(clock)
+	banned := girc.Event{Command: girc.ERR_BANNEDFROMCHAN, Params: []string{"bot", "#alerts", "Cannot join channel (+b)"}}
+
+	c.onBannedFromChan(c.client, banned)
+	if clock.Waiters() != 1 || c.rejoins["#alerts"].backoff != minRejoinBackoff {
+		t.Fatalf("no JOIN retry scheduled after a ban")
+	}
+	clock.Advance(minRejoinBackoff) // not connected: nothing to do
+	if clock.Waiters() != 0 {
+		t.Errorf("%d timers left after the retry", clock.Waiters())
+	}
+
+	// Refused again: the backoff doubles, up to the maximum.
+	c.onBannedFromChan(c.client, banned)
+	if got := c.rejoins["#alerts"].backoff; got != 2*minRejoinBackoff {
+		t.Errorf("backoff = %v, want %v", got, 2*minRejoinBackoff)
+	}
+	for i := 0; i < 10; i++ {
+		c.onBannedFromChan(c.client, banned)
+	}
+	if got := c.rejoins["#alerts"].backoff; got != maxRejoinBackoff {
+		t.Errorf("backoff = %v, want %v", got, maxRejoinBackoff)
+	}
+	if clock.Waiters() != 1 {
+		t.Errorf("%d retries pending, want 1", clock.Waiters())
+	}
+
+	// The ban is lifted: the JOIN succeeds.
+	c.onJoin(c.client, girc.Event{Command: girc.JOIN, Source: &girc.Source{Name: "bot"}, Params: []string{"#alerts"}})
+	if _, blocked := c.Blocked("#alerts"); blocked {
+		t.Error("still blocked after joining")
+	}
+	if len(c.rejoins) != 0 || clock.Waiters() != 0 {
+		t.Error("JOIN retry kept after joining")
+	}
+}
+
+func TestSendRejected(t *testing.T) {
+	c := New(config.IRCConfig{
+		Server:    "localhost:6667",
+		Nickname:  "bot",
+		RateLimit: config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+	}, connstate.New("irc", 0), zerolog.Nop())
+	var rejected, joined []string
+	c.OnSendRejected(func(channel, reason string) { rejected = append(rejected, channel+": "+reason) })
+	c.OnJoined(func(channel string) { joined = append(joined, channel) })
+
+	c.onCannotSend(c.client, girc.Event{Command: girc.ERR_CANNOTSENDTOCHAN, Params: []string{"bot", "#quiet", "Cannot send to channel"}})
+	c.onNoSuchChannel(c.client, girc.Event{Command: girc.ERR_NOSUCHCHANNEL, Params: []string{"bot", "#gone", "No such channel"}})
+	c.onBannedFromChan(c.client, girc.Event{Command: girc.ERR_BANNEDFROMCHAN, Params: []string{"bot", "#alerts", "Cannot join channel (+b)"}})
+	c.onNoSuchChannel(c.client, girc.Event{Command: girc.ERR_NOSUCHCHANNEL, Params: []string{"bot"}})
+
+	want := []string{"#quiet: Cannot send to channel", "#gone: No such channel", "#alerts: Cannot join channel (+b)"}
+	if len(rejected) != len(want) {
+		t.Fatalf("rejected = %q, want %q", rejected, want)
+	}
+	for i := range want {
+		if rejected[i] != want[i] {
+			t.Errorf("rejected[%d] = %q, want %q", i, rejected[i], want[i])
+		}
+	}
+	if _, blocked := c.Blocked("#gone"); blocked {
+		t.Error("ERR_NOSUCHCHANNEL blocked the channel")
+	}
+
+	c.onJoin(c.client, girc.Event{Command: girc.JOIN, Source: &girc.Source{Name: "someone"}, Params: []string{"#gone"}})
+	c.onJoin(c.client, girc.Event{Command: girc.JOIN, Source: &girc.Source{Name: "bot"}, Params: []string{"#gone"}})
+	if len(joined) != 1 || joined[0] != "#gone" {
+		t.Errorf("joined = %q, want the bot's JOIN only", joined)
+	}
+}
@@ -0,0 +1,70 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lrstanley/girc"
+)
+
+// onTraffic records that the server sent something.
+func (c *Client) onTraffic(client *girc.Client, event girc.Event) {
+	c.mu.Lock()
+	c.lastTraffic = time.Now()
+	c.mu.Unlock()
+}
+
+// stallDetector closes a connection that received no server traffic for
+// irc.stall_timeout, so the supervisor reconnects. girc's own PINGs (every
+// irc.ping_delay) keep a healthy connection busy, so a silent one has
+// stalled even if girc still reports it as connected.
+func (c *Client) stallDetector(ctx context.Context) {
+	timeout := c.config.StallTimeout
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if c.isStopped() || !c.client.IsConnected() {
+				continue
+			}
+			if silent, stalled := c.checkStall(now); stalled {
+				c.logger.Warn().
+					Dur("silent", silent).
+					Msg("IRC connection stalled, forcing reconnect")
+				c.client.Close()
+			}
+		}
+	}
+}
+
+// checkStall reports whether the connection stalled by now and, if so,
+// counts the stall and marks the connection as down.
+func (c *Client) checkStall(now time.Time) (silent time.Duration, stalled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastTraffic.IsZero() {
+		c.lastTraffic = now // no traffic seen yet; start counting
+		return 0, false
+	}
+	silent = now.Sub(c.lastTraffic)
+	if silent < c.config.StallTimeout {
+		return silent, false
+	}
+	c.stalls++
+	c.keepaliveFailed = true
+	c.downDetail = fmt.Sprintf("stalled: no server traffic for %s", silent.Round(time.Second))
+	c.lastTraffic = time.Time{}
+	return silent, true
+}
+
+// Stalls returns how many stalled connections were closed.
+func (c *Client) Stalls() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stalls
+}
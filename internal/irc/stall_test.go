@@ -0,0 +1,46 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lrstanley/girc"
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+)
+
+func TestCheckStall(t *testing.T) {
+	history := connstate.New("irc", 0)
+	c := New(config.IRCConfig{
+		Server:       "localhost:6667",
+		Nickname:     "bot",
+		RateLimit:    config.RateLimitConfig{MessagesPerSecond: 1, Burst: 1},
+		StallTimeout: 5 * time.Minute,
+	}, history, zerolog.Nop())
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.lastTraffic = start
+	if _, stalled := c.checkStall(start.Add(4 * time.Minute)); stalled {
+		t.Error("stalled before stall_timeout")
+	}
+	c.onTraffic(c.client, girc.Event{Command: girc.PONG})
+	if _, stalled := c.checkStall(time.Now().Add(4 * time.Minute)); stalled {
+		t.Error("stalled although traffic was seen")
+	}
+
+	silent, stalled := c.checkStall(time.Now().Add(6 * time.Minute))
+	if !stalled || silent < 5*time.Minute {
+		t.Fatalf("checkStall after 6m of silence = %v, %v", silent, stalled)
+	}
+	if c.Stalls() != 1 || c.IsConnected() {
+		t.Errorf("after a stall: %d stalls, connected %v", c.Stalls(), c.IsConnected())
+	}
+
+	c.onDisconnect(c.client, girc.Event{Command: girc.DISCONNECTED})
+	events := history.Events()
+	if len(events) != 1 || events[0].Detail != "stalled: no server traffic for 6m0s" {
+		t.Errorf("history = %+v", events)
+	}
+}
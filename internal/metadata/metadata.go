@@ -0,0 +1,156 @@
+// Package metadata loads static attributes (room, owner, unit, ...) for
+// topics and devices, used to enrich messages with context that is not in
+// their payload.
+package metadata
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Table holds attributes by key. Keys containing a "/" are MQTT topics (MQTT
+// wildcards allowed), all others are device IDs. A nil *Table is valid and
+// has no entries.
+type Table struct {
+	topics   map[string]map[string]string // exact topics
+	patterns []string                     // wildcard topics, least specific first
+	wildcard map[string]map[string]string
+	devices  map[string]map[string]string
+}
+
+// Load reads a metadata file: CSV (.csv, a header row naming the key column
+// and then the attributes) or YAML (key → attribute map).
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata file: %w", err)
+	}
+	defer f.Close()
+
+	var entries map[string]map[string]string
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		entries, err = readCSV(f)
+	} else {
+		entries, err = readYAML(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("metadata file %s: %w", path, err)
+	}
+	return newTable(entries), nil
+}
+
+func readYAML(r io.Reader) (map[string]map[string]string, error) {
+	var raw map[string]map[string]interface{}
+	if err := yaml.NewDecoder(r).Decode(&raw); err != nil && err != io.EOF {
+		return nil, err
+	}
+	entries := make(map[string]map[string]string, len(raw))
+	for key, attrs := range raw {
+		m := make(map[string]string, len(attrs))
+		for k, v := range attrs {
+			m[k] = fmt.Sprintf("%v", v)
+		}
+		entries[key] = m
+	}
+	return entries, nil
+}
+
+func readCSV(r io.Reader) (map[string]map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]map[string]string)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		attrs := make(map[string]string, len(row)-1)
+		for i := 1; i < len(row); i++ {
+			if row[i] != "" {
+				attrs[header[i]] = row[i]
+			}
+		}
+		entries[row[0]] = attrs
+	}
+}
+
+func newTable(entries map[string]map[string]string) *Table {
+	t := &Table{
+		topics:   make(map[string]map[string]string),
+		wildcard: make(map[string]map[string]string),
+		devices:  make(map[string]map[string]string),
+	}
+	for key, attrs := range entries {
+		switch {
+		case strings.ContainsAny(key, "+#"):
+			t.wildcard[key] = attrs
+			t.patterns = append(t.patterns, key)
+		case strings.Contains(key, "/"):
+			t.topics[key] = attrs
+		default:
+			t.devices[key] = attrs
+		}
+	}
+	// Longer patterns are more specific and are applied later, so they win.
+	sort.Slice(t.patterns, func(i, j int) bool {
+		if len(t.patterns[i]) != len(t.patterns[j]) {
+			return len(t.patterns[i]) < len(t.patterns[j])
+		}
+		return t.patterns[i] < t.patterns[j]
+	})
+	return t
+}
+
+// HasDevices reports whether any entry is keyed by device ID, i.e. whether
+// callers need to extract device IDs from payloads.
+func (t *Table) HasDevices() bool {
+	return t != nil && len(t.devices) > 0
+}
+
+// Lookup returns the attributes for a message on topic from device (empty if
+// unknown), or nil when there are none. Matching wildcard topics apply first,
+// then the exact topic, then the device, each overriding earlier values.
+func (t *Table) Lookup(topic, device string, match func(topic, pattern string) bool) map[string]string {
+	if t == nil {
+		return nil
+	}
+	var meta map[string]string
+	merge := func(attrs map[string]string) {
+		if meta == nil {
+			meta = make(map[string]string, len(attrs))
+		}
+		for k, v := range attrs {
+			meta[k] = v
+		}
+	}
+	for _, p := range t.patterns {
+		if match(topic, p) {
+			merge(t.wildcard[p])
+		}
+	}
+	if attrs, ok := t.topics[topic]; ok {
+		merge(attrs)
+	}
+	if attrs, ok := t.devices[device]; ok && device != "" {
+		merge(attrs)
+	}
+	return meta
+}
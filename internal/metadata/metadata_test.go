@@ -0,0 +1,106 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// match is a minimal MQTT matcher for the tests ("+" and trailing "#").
+func match(topic, pattern string) bool {
+	tp, pp := strings.Split(topic, "/"), strings.Split(pattern, "/")
+	for i, p := range pp {
+		if p == "#" {
+			return true
+		}
+		if i >= len(tp) || (p != "+" && p != tp[i]) {
+			return false
+		}
+	}
+	return len(tp) == len(pp)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_YAML(t *testing.T) {
+	path := writeFile(t, "meta.yaml", `
+sensors/#:
+  site: home
+sensors/+/temp:
+  unit: "°C"
+sensors/livingroom/temp:
+  room: Living room
+"!a1b2c3d4":
+  owner: alice
+  room: Attic
+`)
+	table, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		topic, device string
+		want          map[string]string
+	}{
+		{"sensors/livingroom/temp", "", map[string]string{"site": "home", "unit": "°C", "room": "Living room"}},
+		{"sensors/kitchen/temp", "", map[string]string{"site": "home", "unit": "°C"}},
+		{"sensors/livingroom/temp", "!a1b2c3d4", map[string]string{"site": "home", "unit": "°C", "room": "Attic", "owner": "alice"}},
+		{"other/topic", "", nil},
+	}
+	for _, tt := range tests {
+		got := table.Lookup(tt.topic, tt.device, match)
+		if len(got) != len(tt.want) {
+			t.Errorf("Lookup(%q, %q) = %v, want %v", tt.topic, tt.device, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("Lookup(%q, %q)[%s] = %q, want %q", tt.topic, tt.device, k, got[k], v)
+			}
+		}
+	}
+	if !table.HasDevices() {
+		t.Error("HasDevices() = false, want true")
+	}
+}
+
+func TestLoad_CSV(t *testing.T) {
+	path := writeFile(t, "meta.csv", `key,room,owner
+# comment
+sensors/garage/door,Garage,
+!0000beef,,bob
+`)
+	table, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got := table.Lookup("sensors/garage/door", "!0000beef", match)
+	if got["room"] != "Garage" || got["owner"] != "bob" {
+		t.Errorf("Lookup = %v, want room Garage, owner bob", got)
+	}
+	if _, ok := table.Lookup("sensors/garage/door", "", match)["owner"]; ok {
+		t.Error("empty CSV cells must not set attributes")
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestNilTable(t *testing.T) {
+	var table *Table
+	if table.HasDevices() || table.Lookup("a/b", "x", match) != nil {
+		t.Error("nil table must have no entries")
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"sync"
 	"time"
 
 	pahomqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/rs/zerolog"
 
 	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
 	"github.com/dyuri/mqtt2irc/pkg/types"
 )
 
@@ -18,17 +22,67 @@ type Client struct {
 	client  pahomqtt.Client
 	config  config.MQTTConfig
 	msgChan chan<- types.Message
+	history *connstate.History
 	logger  zerolog.Logger
+
+	// QoS-based priority (optional, see SetPriorityQueue)
+	highChan     chan<- types.Message
+	highFull     func(types.Message) // takes QoS 2 messages while highChan is full
+	lowWatermark int
+
+	// Liveness probe state (see probe.go)
+	mu             sync.Mutex
+	probeToken     string
+	probeCh        chan struct{}
+	probeFailed    bool // last probe failed; cleared by the next successful probe
+	onProbeFailure func(timeout time.Duration)
+
+	// Redelivery suppression (nil when disabled, see redelivery.go)
+	redeliveries *redeliveryCache
+
+	// Storm breaker (nil when disabled, see storm.go)
+	storms  *stormBreaker
+	onStorm func(topic string, maxRate int, cooldown time.Duration)
+
+	clock schedule.Clock
+
+	stop     chan struct{} // closed by Disconnect; ends a flap backoff
+	stopOnce sync.Once
+
+	// Drop accounting (optional, see SetDropCounter)
+	drops *stats.Drops
+
+	// Topics handled by the bridge itself, not forwarded (see Handle)
+	handlers map[string]func(payload []byte)
+
+	safeMode bool // Publish only logs (see SetSafeMode)
 }
 
-// New creates a new MQTT client
-func New(cfg config.MQTTConfig, msgChan chan<- types.Message, logger zerolog.Logger) (*Client, error) {
+// New creates a new MQTT client. Connection transitions are recorded in history.
+func New(cfg config.MQTTConfig, msgChan chan<- types.Message, history *connstate.History, logger zerolog.Logger) (*Client, error) {
 	c := &Client{
 		config:  cfg,
 		msgChan: msgChan,
+		history: history,
 		logger:  logger.With().Str("component", "mqtt").Logger(),
+		clock:   schedule.Real,
+		stop:    make(chan struct{}),
+	}
+	if cfg.RedeliveryWindow > 0 {
+		c.redeliveries = newRedeliveryCache(cfg.RedeliveryWindow, c.clock)
 	}
+	if sb := cfg.StormBreaker; sb.MaxRate > 0 {
+		c.storms = newStormBreaker(sb.MaxRate, sb.Cooldown, c.clock)
+	}
+
+	c.client = pahomqtt.NewClient(c.clientOptions())
+
+	return c, nil
+}
 
+// clientOptions builds the paho options for the configured broker.
+func (c *Client) clientOptions() *pahomqtt.ClientOptions {
+	cfg := c.config
 	opts := pahomqtt.NewClientOptions()
 	opts.AddBroker(cfg.Broker)
 	opts.SetClientID(cfg.ClientID)
@@ -50,22 +104,36 @@ func New(cfg config.MQTTConfig, msgChan chan<- types.Message, logger zerolog.Log
 	opts.SetConnectionLostHandler(c.onConnectionLost)
 	opts.SetReconnectingHandler(c.onReconnecting)
 
-	// Reconnection settings
+	// Reconnection settings. The initial connect is a single attempt: the
+	// bridge retries it as startup.retry says, or exits.
 	opts.SetAutoReconnect(true)
 	opts.SetMaxReconnectInterval(60 * time.Second)
-	opts.SetConnectRetryInterval(1 * time.Second)
-	opts.SetConnectRetry(true)
+	opts.SetConnectRetry(false)
 
 	// Keep alive
 	opts.SetKeepAlive(60 * time.Second)
 	opts.SetPingTimeout(10 * time.Second)
 
-	// Clean session
-	opts.SetCleanSession(true)
+	// A persistent session makes the broker keep our subscriptions and
+	// redeliver unacknowledged QoS 1/2 messages after a reconnect, possibly
+	// before onConnect has subscribed again: route those to messageHandler.
+	opts.SetCleanSession(cfg.UseCleanSession())
+	if !cfg.UseCleanSession() {
+		opts.SetDefaultPublishHandler(c.messageHandler)
+	}
 
-	c.client = pahomqtt.NewClient(opts)
+	return opts
+}
 
-	return c, nil
+// SetClock replaces the time source (for tests). Must be called before Connect.
+func (c *Client) SetClock(clock schedule.Clock) {
+	c.clock = clock
+	if c.redeliveries != nil {
+		c.redeliveries.clock = clock
+	}
+	if c.storms != nil {
+		c.storms.clock = clock
+	}
 }
 
 // Connect establishes connection to MQTT broker
@@ -85,79 +153,263 @@ func (c *Client) Connect(ctx context.Context) error {
 	}
 
 	c.logger.Info().Msg("connected to MQTT broker")
+
+	if c.config.Probe.Interval > 0 {
+		go c.probe(ctx)
+	}
 	return nil
 }
 
 // onConnect is called when connection is established
 func (c *Client) onConnect(client pahomqtt.Client) {
 	c.logger.Info().Msg("MQTT connection established")
+	c.history.RecordUp("")
 
 	// Subscribe to all configured topics
-	for _, topic := range c.config.Topics {
-		c.logger.Info().
-			Str("pattern", topic.Pattern).
-			Uint8("qos", topic.QoS).
-			Msg("subscribing to MQTT topic")
-
-		token := client.Subscribe(topic.Pattern, topic.QoS, c.messageHandler)
-		if token.Wait() && token.Error() != nil {
-			c.logger.Error().
-				Err(token.Error()).
-				Str("pattern", topic.Pattern).
-				Msg("failed to subscribe to topic")
-		} else {
-			c.logger.Info().
-				Str("pattern", topic.Pattern).
-				Msg("subscribed to topic")
-		}
+	for _, topic := range c.topics() {
+		c.subscribe(client, topic)
 	}
+
+	if c.config.Probe.Interval > 0 {
+		c.subscribeProbe(client)
+	}
+	c.subscribeHandlers(client)
 }
 
 // onConnectionLost is called when connection is lost
 func (c *Client) onConnectionLost(client pahomqtt.Client, err error) {
 	c.logger.Warn().Err(err).Msg("MQTT connection lost")
+	detail := ""
+	if err != nil {
+		detail = err.Error()
+	}
+	c.history.RecordDown(detail)
 }
 
-// onReconnecting is called when attempting to reconnect
+// onReconnecting is called when attempting to reconnect.
+// While the connection is flapping, the attempt is delayed by the flap backoff;
+// paho runs this handler synchronously in its reconnect loop. Disconnect cuts
+// the backoff short, so shutdown and drain do not wait it out.
 func (c *Client) onReconnecting(client pahomqtt.Client, opts *pahomqtt.ClientOptions) {
+	if backoff := c.history.Backoff(); backoff > 0 {
+		c.logger.Warn().Dur("backoff", backoff).Msg("MQTT connection flapping, delaying reconnect")
+		select {
+		case <-c.clock.After(backoff):
+		case <-c.stop:
+			return
+		}
+	}
 	c.logger.Info().Msg("attempting to reconnect to MQTT broker")
 }
 
+// SetPriorityQueue enables QoS-based priority: QoS 2 messages are delivered to
+// high, or handed to full while high is full, so they are never dropped; QoS 0
+// messages are dropped once the normal queue holds lowWatermark messages.
+// full must not block. Without it QoS 2 messages fall back to the normal
+// queue. Must be called before Connect.
+func (c *Client) SetPriorityQueue(high chan<- types.Message, lowWatermark int, full func(types.Message)) {
+	c.highChan = high
+	c.lowWatermark = lowWatermark
+	c.highFull = full
+}
+
+// SetDropCounter records messages dropped before reaching the bridge queue
+// in drops. Must be called before Connect.
+func (c *Client) SetDropCounter(drops *stats.Drops) {
+	c.drops = drops
+}
+
+// priorityFor maps the MQTT QoS of a message to a bridge priority.
+func (c *Client) priorityFor(qos byte) types.Priority {
+	if c.highChan == nil {
+		return types.PriorityNormal
+	}
+	switch qos {
+	case 2:
+		return types.PriorityHigh
+	case 0:
+		return types.PriorityLow
+	default:
+		return types.PriorityNormal
+	}
+}
+
 // messageHandler processes incoming MQTT messages
 func (c *Client) messageHandler(client pahomqtt.Client, msg pahomqtt.Message) {
+	if c.redeliveries != nil && msg.Qos() > 0 &&
+		c.redeliveries.redelivered(msg.Topic(), msg.MessageID(), msg.Duplicate()) {
+		c.drops.Record(stats.DropRedelivery, msg.Topic(), c.clock.Now())
+		c.logger.Debug().
+			Str("topic", msg.Topic()).
+			Str("reason", string(stats.DropRedelivery)).
+			Uint16("message_id", msg.MessageID()).
+			Msg("suppressed duplicate MQTT redelivery")
+		return
+	}
+
+	if c.stormed(msg.Topic()) {
+		return
+	}
+
 	message := types.Message{
+		ID:        nextMessageID(),
 		Topic:     msg.Topic(),
 		Payload:   msg.Payload(),
-		Timestamp: time.Now(),
+		Timestamp: c.clock.Now(),
 		QoS:       msg.Qos(),
+		Priority:  c.priorityFor(msg.Qos()),
 	}
 
 	c.logger.Debug().
+		Str("msg_id", message.ID).
 		Str("topic", message.Topic).
 		Int("payload_size", len(message.Payload)).
+		Str("priority", message.Priority.String()).
 		Msg("received MQTT message")
 
+	switch message.Priority {
+	case types.PriorityHigh:
+		// Never block here: this runs on paho's delivery goroutine, which
+		// also acknowledges QoS 2 messages.
+		select {
+		case c.highChan <- message:
+			return
+		default:
+		}
+		if c.highFull != nil {
+			c.highFull(message)
+			return
+		}
+	case types.PriorityLow:
+		if len(c.msgChan) >= c.lowWatermark {
+			c.drops.Record(stats.DropLowPriority, message.Topic, c.clock.Now())
+			c.logger.Warn().
+				Str("msg_id", message.ID).
+				Str("topic", message.Topic).
+				Str("reason", string(stats.DropLowPriority)).
+				Msg("message queue above low-priority watermark, dropping QoS 0 message")
+			return
+		}
+	}
+
 	// Send to bridge (non-blocking if channel is full)
 	select {
 	case c.msgChan <- message:
 		// Message sent successfully
 	default:
+		c.drops.Record(stats.DropQueueFull, message.Topic, c.clock.Now())
 		c.logger.Warn().
 			Str("topic", message.Topic).
+			Str("reason", string(stats.DropQueueFull)).
 			Msg("message queue full, dropping message")
 	}
 }
 
+// publishTimeout bounds how long Publish waits for the broker.
+const publishTimeout = 10 * time.Second
+
+// SetSafeMode makes Publish log instead of publishing, for --safe-mode.
+// Must be called before Connect.
+func (c *Client) SetSafeMode() {
+	c.safeMode = true
+}
+
+// Publish sends payload to topic and waits until the broker accepted it
+// (QoS 1/2) or it was written (QoS 0). In safe mode it only logs.
+func (c *Client) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	if c.safeMode {
+		c.logger.Info().Str("topic", topic).Bool("retained", retained).Int("bytes", len(payload)).Msg("safe mode: not publishing")
+		return nil
+	}
+	if !c.client.IsConnected() {
+		return fmt.Errorf("publish to %s: not connected", topic)
+	}
+	token := c.client.Publish(topic, qos, retained, payload)
+	if !token.WaitTimeout(publishTimeout) {
+		return fmt.Errorf("publish to %s: timed out after %s", topic, publishTimeout)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
 // Disconnect closes the MQTT connection
 func (c *Client) Disconnect(timeout time.Duration) {
+	c.stopOnce.Do(func() { close(c.stop) })
 	c.logger.Info().Msg("disconnecting from MQTT broker")
 	c.client.Disconnect(uint(timeout.Milliseconds()))
 	c.logger.Info().Msg("disconnected from MQTT broker")
 }
 
-// IsConnected returns true if connected to MQTT broker
+// stormed runs the storm breaker on a received message and reports whether
+// to drop it. The message that trips the breaker is logged and reported to
+// the OnStorm callback; the ones after it are only counted.
+func (c *Client) stormed(topic string) bool {
+	drop, tripped, ended := c.storms.check(topic)
+	if ended > 0 {
+		c.logger.Info().
+			Str("topic", topic).
+			Int("suppressed", ended).
+			Msg("topic storm cooldown over, forwarding again")
+	}
+	if !drop {
+		return false
+	}
+	now := c.clock.Now()
+	c.drops.Record(stats.DropStorm, topic, now)
+	if tripped {
+		until := now.Add(c.storms.cooldown)
+		c.logger.Warn().
+			Str("topic", topic).
+			Str("reason", string(stats.DropStorm)).
+			Int("max_rate", c.storms.maxRate).
+			Time("until", until).
+			Msg("topic storm, suppressing topic")
+		c.mu.Lock()
+		fn := c.onStorm
+		c.mu.Unlock()
+		if fn != nil {
+			fn(topic, c.storms.maxRate, c.storms.cooldown)
+		}
+	}
+	return true
+}
+
+// OnStorm registers a callback invoked when the storm breaker starts to
+// suppress a topic.
+func (c *Client) OnStorm(fn func(topic string, maxRate int, cooldown time.Duration)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onStorm = fn
+}
+
+// StormTopics returns the topics the storm breaker suppresses now.
+func (c *Client) StormTopics() []string {
+	return c.storms.active()
+}
+
+// SuppressedRedeliveries returns the number of broker redeliveries dropped by
+// the redelivery cache (always 0 when mqtt.redelivery_window is not set).
+func (c *Client) SuppressedRedeliveries() uint64 {
+	if c.redeliveries == nil {
+		return 0
+	}
+	return c.redeliveries.count()
+}
+
+// History returns the connection state history.
+func (c *Client) History() *connstate.History {
+	return c.history
+}
+
+// IsConnected returns true if connected to MQTT broker and the last liveness
+// probe (if enabled) did not fail.
 func (c *Client) IsConnected() bool {
-	return c.client.IsConnected()
+	c.mu.Lock()
+	failed := c.probeFailed
+	c.mu.Unlock()
+	return !failed && c.client.IsConnected()
 }
 
 // ForceReconnect disconnects and immediately reconnects to the MQTT broker.
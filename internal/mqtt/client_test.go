@@ -0,0 +1,103 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// TestMessageHandler_HighQueueFull checks that a full high-priority queue does
+// not block the paho delivery goroutine, and that QoS 2 messages are not
+// dropped: they are handed to the full callback.
+func TestMessageHandler_HighQueueFull(t *testing.T) {
+	normal := make(chan types.Message, 1)
+	high := make(chan types.Message, 1)
+	cfg := config.MQTTConfig{Broker: "tcp://localhost:1883", ClientID: "test"}
+	c, err := New(cfg, normal, connstate.New("MQTT", 5), zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spilled []types.Message
+	c.SetPriorityQueue(high, 1, func(msg types.Message) { spilled = append(spilled, msg) })
+	drops := stats.NewDrops()
+	c.SetDropCounter(drops)
+
+	done := make(chan struct{})
+	go func() {
+		for id := uint16(1); id <= 3; id++ {
+			c.messageHandler(nil, fakeMessage{topic: "alarm", id: id, qos: 2})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("messageHandler blocked on a full high-priority queue")
+	}
+
+	if len(high) != 1 || len(spilled) != 2 || len(normal) != 0 {
+		t.Errorf("queued high=%d spilled=%d normal=%d, want 1, 2 and 0", len(high), len(spilled), len(normal))
+	}
+	if snap := drops.Snapshot(); len(snap) != 0 {
+		t.Errorf("drops = %+v, want none", snap)
+	}
+}
+
+func TestOnReconnecting_FlapBackoff(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	history := connstate.New("MQTT", 10)
+	history.SetClock(clock)
+	history.SetFlapPolicy(connstate.FlapPolicy{MaxDisconnects: 2, Window: time.Hour, Backoff: time.Minute}, nil)
+	for i := 0; i < 2; i++ {
+		history.RecordDown("lost")
+		history.RecordUp("")
+	}
+	cfg := config.MQTTConfig{Broker: "tcp://localhost:1883", ClientID: "test"}
+	c, err := New(cfg, make(chan types.Message, 1), history, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetClock(clock)
+
+	reconnecting := func() <-chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			c.onReconnecting(nil, nil)
+			close(done)
+		}()
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		return done
+	}
+
+	done := reconnecting()
+	clock.Advance(59 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("reconnect attempted before the flap backoff ended")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconnect still waiting after the flap backoff")
+	}
+
+	// Disconnect cuts the backoff short.
+	done = reconnecting()
+	c.Disconnect(0)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Disconnect did not end the flap backoff")
+	}
+}
@@ -0,0 +1,21 @@
+package mqtt
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// lastMessageID numbers received messages. It starts at a random value so
+// IDs from before a restart are unlikely to show up again in the same logs.
+var lastMessageID atomic.Uint32
+
+func init() {
+	lastMessageID.Store(rand.Uint32())
+}
+
+// nextMessageID returns the short ID of a newly received message: eight hex
+// digits, unique within the process until 2^32 messages.
+func nextMessageID() string {
+	return fmt.Sprintf("%08x", lastMessageID.Add(1))
+}
@@ -0,0 +1,94 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pahomqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// probeTopic returns the loopback topic used by the liveness probe.
+func (c *Client) probeTopic() string {
+	if c.config.Probe.Topic != "" {
+		return c.config.Probe.Topic
+	}
+	return "mqtt2irc/probe/" + c.config.ClientID
+}
+
+// subscribeProbe subscribes to the probe topic. Called from onConnect.
+func (c *Client) subscribeProbe(client pahomqtt.Client) {
+	topic := c.probeTopic()
+	token := client.Subscribe(topic, 1, c.probeHandler)
+	if token.Wait() && token.Error() != nil {
+		c.logger.Error().Err(token.Error()).Str("topic", topic).Msg("failed to subscribe to probe topic")
+	}
+}
+
+// probeHandler completes an outstanding probe when its payload comes back.
+func (c *Client) probeHandler(client pahomqtt.Client, msg pahomqtt.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.probeCh != nil && string(msg.Payload()) == c.probeToken {
+		close(c.probeCh)
+		c.probeCh = nil
+	}
+}
+
+// probe periodically publishes a unique token to the probe topic and waits for
+// it to be delivered back. A broker can keep accepting connections while no
+// longer delivering messages; a probe that does not complete within the timeout
+// marks the client as not connected, reports the failure and forces a reconnect.
+func (c *Client) probe(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.clock.After(c.config.Probe.Interval):
+		}
+
+		if !c.client.IsConnected() {
+			continue
+		}
+
+		token := fmt.Sprintf("%s-%d", c.config.ClientID, c.clock.Now().UnixNano())
+		done := make(chan struct{})
+		c.mu.Lock()
+		c.probeToken, c.probeCh = token, done
+		c.mu.Unlock()
+
+		start := c.clock.Now()
+		c.client.Publish(c.probeTopic(), 1, false, token)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			c.mu.Lock()
+			c.probeFailed = false
+			c.mu.Unlock()
+			c.logger.Debug().Dur("rtt", c.clock.Now().Sub(start)).Msg("MQTT probe ok")
+		case <-c.clock.After(c.config.Probe.Timeout):
+			c.logger.Warn().
+				Str("topic", c.probeTopic()).
+				Dur("timeout", c.config.Probe.Timeout).
+				Msg("MQTT probe not delivered back, forcing reconnect")
+			c.mu.Lock()
+			c.probeFailed = true
+			onFailure := c.onProbeFailure
+			c.probeCh = nil
+			c.mu.Unlock()
+			if onFailure != nil {
+				onFailure(c.config.Probe.Timeout)
+			}
+			c.ForceReconnect()
+		}
+	}
+}
+
+// OnProbeFailure registers a callback invoked when a liveness probe times out.
+func (c *Client) OnProbeFailure(fn func(timeout time.Duration)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onProbeFailure = fn
+}
@@ -0,0 +1,87 @@
+package mqtt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// redeliveryCache remembers recently delivered (topic, message-id) pairs so
+// that broker redeliveries (DUP flag set) after a reconnect can be suppressed.
+// Message IDs are reused by the broker, so entries expire after a short window.
+type redeliveryCache struct {
+	mu         sync.Mutex
+	entries    map[string]time.Time // topic+id → expiry time
+	order      []redeliveryEntry    // entries in expiry order, for eviction
+	window     time.Duration
+	clock      schedule.Clock
+	suppressed uint64
+}
+
+// redeliveryEntry is one recorded delivery. Since the window is fixed,
+// appending in arrival order keeps the list sorted by expiry.
+type redeliveryEntry struct {
+	key    string
+	expiry time.Time
+}
+
+func newRedeliveryCache(window time.Duration, clock schedule.Clock) *redeliveryCache {
+	return &redeliveryCache{
+		entries: make(map[string]time.Time),
+		window:  window,
+		clock:   clock,
+	}
+}
+
+// redelivered records the message and returns true if it is a redelivery
+// (dup set) of a message already seen within the window.
+func (c *redeliveryCache) redelivered(topic string, id uint16, dup bool) bool {
+	key := fmt.Sprintf("%s\x00%d", topic, id)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	c.evict(now)
+
+	if expiry, ok := c.entries[key]; ok && dup && now.Before(expiry) {
+		c.suppressed++
+		return true
+	}
+
+	expiry := now.Add(c.window)
+	c.entries[key] = expiry
+	c.order = append(c.order, redeliveryEntry{key: key, expiry: expiry})
+	return false
+}
+
+// evict removes the expired entries from the front of the expiry list. A key
+// recorded again later has a newer expiry in the map and is kept.
+func (c *redeliveryCache) evict(now time.Time) {
+	n := 0
+	for ; n < len(c.order) && now.After(c.order[n].expiry); n++ {
+		e := c.order[n]
+		if c.entries[e.key].Equal(e.expiry) {
+			delete(c.entries, e.key)
+		}
+	}
+	if n > 0 {
+		c.order = append(c.order[:0], c.order[n:]...)
+	}
+}
+
+// count returns the number of suppressed redeliveries.
+func (c *redeliveryCache) count() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.suppressed
+}
+
+// size returns the number of remembered deliveries.
+func (c *redeliveryCache) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
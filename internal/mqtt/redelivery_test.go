@@ -0,0 +1,158 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestRedeliveryCache(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	c := newRedeliveryCache(10*time.Second, clock)
+
+	if c.redelivered("a/b", 7, false) {
+		t.Fatal("first delivery reported as redelivery")
+	}
+	clock.Advance(time.Second)
+	if !c.redelivered("a/b", 7, true) {
+		t.Error("DUP redelivery within window not suppressed")
+	}
+	// Same ID without DUP flag is a new message (the broker reused the ID).
+	clock.Advance(time.Second)
+	if c.redelivered("a/b", 7, false) {
+		t.Error("non-DUP message with reused ID suppressed")
+	}
+	// Same ID on another topic is unrelated.
+	clock.Advance(time.Second)
+	if c.redelivered("a/c", 7, true) {
+		t.Error("DUP message on another topic suppressed")
+	}
+	// After the window the entry is gone.
+	clock.Advance(time.Minute)
+	if c.redelivered("a/b", 7, true) {
+		t.Error("DUP message after window suppressed")
+	}
+	if got := c.count(); got != 1 {
+		t.Errorf("count() = %d, want 1", got)
+	}
+}
+
+func TestRedeliveryCache_Eviction(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	c := newRedeliveryCache(10*time.Second, clock)
+
+	for id := uint16(0); id < 100; id++ {
+		c.redelivered("a/b", id, false)
+	}
+	// Recording id 0 again later keeps it past the first entry's expiry.
+	clock.Advance(5 * time.Second)
+	c.redelivered("a/b", 0, false)
+
+	clock.Advance(6 * time.Second)
+	c.redelivered("a/c", 1, false)
+	if got := c.size(); got != 2 {
+		t.Errorf("size() = %d after expiry, want 2 (a/b 0 and a/c 1)", got)
+	}
+	if !c.redelivered("a/b", 0, true) {
+		t.Error("re-recorded entry evicted with its older expiry")
+	}
+	if got := len(c.order); got > 3 {
+		t.Errorf("expiry list holds %d entries, want expired ones dropped", got)
+	}
+}
+
+func TestClientOptions_CleanSession(t *testing.T) {
+	off, on := false, true
+	tests := []struct {
+		name string
+		cfg  config.MQTTConfig
+		want bool
+	}{
+		{"default", config.MQTTConfig{}, true},
+		{"redelivery suppression", config.MQTTConfig{RedeliveryWindow: 30 * time.Second}, false},
+		{"explicit on with suppression", config.MQTTConfig{RedeliveryWindow: 30 * time.Second, CleanSession: &on}, true},
+		{"explicit off", config.MQTTConfig{CleanSession: &off}, false},
+	}
+	for _, tt := range tests {
+		tt.cfg.Broker, tt.cfg.ClientID = "tcp://localhost:1883", "test"
+		c, err := New(tt.cfg, make(chan types.Message, 1), connstate.New("MQTT", 5), zerolog.Nop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.clientOptions().CleanSession; got != tt.want {
+			t.Errorf("%s: CleanSession = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fakeMessage implements pahomqtt.Message.
+type fakeMessage struct {
+	topic string
+	id    uint16
+	qos   byte
+	dup   bool
+}
+
+func (m fakeMessage) Duplicate() bool   { return m.dup }
+func (m fakeMessage) Qos() byte         { return m.qos }
+func (m fakeMessage) Retained() bool    { return false }
+func (m fakeMessage) Topic() string     { return m.topic }
+func (m fakeMessage) MessageID() uint16 { return m.id }
+func (m fakeMessage) Payload() []byte   { return []byte("x") }
+func (m fakeMessage) Ack()              {}
+
+// TestMessageHandler_RedeliveryAfterReconnect delivers a QoS 1 message, then
+// the broker's DUP redelivery into the persistent session, which arrives via
+// the default publish handler before the topics are subscribed again.
+func TestMessageHandler_RedeliveryAfterReconnect(t *testing.T) {
+	msgs := make(chan types.Message, 10)
+	cfg := config.MQTTConfig{Broker: "tcp://localhost:1883", ClientID: "test", RedeliveryWindow: 30 * time.Second}
+	c, err := New(cfg, msgs, connstate.New("MQTT", 5), zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	c.SetClock(clock)
+	drops := stats.NewDrops()
+	c.SetDropCounter(drops)
+
+	opts := c.clientOptions()
+	if opts.CleanSession || opts.DefaultPublishHandler == nil {
+		t.Fatal("redelivery suppression needs a persistent session with a default publish handler")
+	}
+
+	c.messageHandler(nil, fakeMessage{topic: "a/b", id: 3, qos: 1})
+	clock.Advance(5 * time.Second)
+	opts.DefaultPublishHandler(nil, fakeMessage{topic: "a/b", id: 3, qos: 1, dup: true})
+
+	if len(msgs) != 1 {
+		t.Errorf("queued %d messages, want the redelivery suppressed", len(msgs))
+	}
+	if got := (<-msgs).Timestamp; !got.Equal(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Timestamp = %v, want the injected clock's time", got)
+	}
+	if c.SuppressedRedeliveries() != 1 {
+		t.Errorf("SuppressedRedeliveries() = %d, want 1", c.SuppressedRedeliveries())
+	}
+}
+
+func TestMessageHandler_MessageID(t *testing.T) {
+	msgs := make(chan types.Message, 10)
+	c, err := New(config.MQTTConfig{Broker: "tcp://localhost:1883", ClientID: "test"}, msgs, connstate.New("MQTT", 5), zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.messageHandler(nil, fakeMessage{topic: "a/b", id: 1})
+	c.messageHandler(nil, fakeMessage{topic: "a/b", id: 2})
+	first, second := <-msgs, <-msgs
+	if len(first.ID) != 8 || first.ID == second.ID {
+		t.Errorf("message IDs = %q, %q, want distinct 8-digit IDs", first.ID, second.ID)
+	}
+}
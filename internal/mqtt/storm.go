@@ -0,0 +1,113 @@
+package mqtt
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// stormSweep is how often topics without recent messages are forgotten.
+const stormSweep = time.Minute
+
+// stormBreaker suppresses a topic that receives more than maxRate messages
+// within a second for cooldown (mqtt.storm_breaker), so a publisher stuck in
+// a loop cannot flood the queue and starve every other topic. A nil
+// *stormBreaker suppresses nothing.
+type stormBreaker struct {
+	maxRate  int
+	cooldown time.Duration
+	clock    schedule.Clock
+
+	mu     sync.Mutex
+	topics map[string]*topicRate
+	swept  time.Time
+}
+
+// topicRate counts the messages of one topic in the current one-second
+// window.
+type topicRate struct {
+	window     time.Time // start of the current window
+	count      int
+	until      time.Time // suppressed until; zero if not suppressed
+	suppressed int       // messages dropped in the current suppression
+}
+
+func newStormBreaker(maxRate int, cooldown time.Duration, clock schedule.Clock) *stormBreaker {
+	return &stormBreaker{
+		maxRate:  maxRate,
+		cooldown: cooldown,
+		clock:    clock,
+		topics:   make(map[string]*topicRate),
+		swept:    clock.Now(),
+	}
+}
+
+// check counts a message on topic and reports whether to drop it. tripped is
+// set for the message that starts a suppression. ended is the number of
+// messages dropped by a suppression that ended with this message, or 0.
+func (s *stormBreaker) check(topic string) (drop, tripped bool, ended int) {
+	if s == nil {
+		return false, false, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	defer s.sweep(now) // after this topic is updated, so it is kept
+	t, ok := s.topics[topic]
+	if !ok {
+		t = &topicRate{window: now}
+		s.topics[topic] = t
+	}
+	if !t.until.IsZero() {
+		if now.Before(t.until) {
+			t.suppressed++
+			return true, false, 0
+		}
+		ended = t.suppressed
+		*t = topicRate{window: now}
+	}
+	if now.Sub(t.window) >= time.Second {
+		t.window, t.count = now, 0
+	}
+	t.count++
+	if t.count > s.maxRate {
+		t.until, t.suppressed = now.Add(s.cooldown), 1
+		return true, true, ended
+	}
+	return false, false, ended
+}
+
+// sweep forgets the topics that are not suppressed (any more) and had no
+// message in the last second, once per stormSweep.
+func (s *stormBreaker) sweep(now time.Time) {
+	if now.Sub(s.swept) < stormSweep {
+		return
+	}
+	s.swept = now
+	for topic, t := range s.topics {
+		if !now.Before(t.until) && now.Sub(t.window) >= time.Second {
+			delete(s.topics, topic)
+		}
+	}
+}
+
+// active returns the topics suppressed now, sorted.
+func (s *stormBreaker) active() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	var topics []string
+	for topic, t := range s.topics {
+		if now.Before(t.until) {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
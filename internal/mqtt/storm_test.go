@@ -0,0 +1,100 @@
+package mqtt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+	"github.com/dyuri/mqtt2irc/internal/connstate"
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+	"github.com/dyuri/mqtt2irc/internal/stats"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+func TestStormBreaker(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC))
+	s := newStormBreaker(3, time.Minute, clock)
+
+	for i := 0; i < 3; i++ {
+		if drop, _, _ := s.check("loop/x"); drop {
+			t.Fatalf("message %d within the rate dropped", i+1)
+		}
+	}
+	if drop, tripped, _ := s.check("loop/x"); !drop || !tripped {
+		t.Fatalf("message over the rate: drop %v, tripped %v", drop, tripped)
+	}
+	if drop, tripped, _ := s.check("loop/x"); !drop || tripped {
+		t.Errorf("message during cooldown: drop %v, tripped %v", drop, tripped)
+	}
+	if drop, _, _ := s.check("other/y"); drop {
+		t.Error("other topic dropped")
+	}
+	if got := s.active(); !reflect.DeepEqual(got, []string{"loop/x"}) {
+		t.Errorf("active() = %v", got)
+	}
+
+	// A new second starts a new count.
+	clock.Advance(time.Second)
+	for i := 0; i < 3; i++ {
+		s.check("other/y")
+	}
+	if drop, _, _ := s.check("other/y"); !drop {
+		t.Error("fourth message in a new second not dropped")
+	}
+
+	clock.Advance(time.Minute)
+	drop, tripped, ended := s.check("loop/x")
+	if drop || tripped || ended != 2 {
+		t.Errorf("after cooldown: drop %v, tripped %v, ended %d, want 2 dropped", drop, tripped, ended)
+	}
+	if got := s.active(); len(got) != 0 {
+		t.Errorf("active() after cooldown = %v", got)
+	}
+
+	clock.Advance(stormSweep)
+	s.check("loop/x")
+	if len(s.topics) != 1 {
+		t.Errorf("%d topics remembered after the sweep, want 1", len(s.topics))
+	}
+
+	var nilBreaker *stormBreaker
+	if drop, _, _ := nilBreaker.check("loop/x"); drop {
+		t.Error("nil breaker dropped a message")
+	}
+}
+
+func TestMessageHandler_Storm(t *testing.T) {
+	msgs := make(chan types.Message, 10)
+	cfg := config.MQTTConfig{
+		Broker: "tcp://localhost:1883", ClientID: "test",
+		StormBreaker: config.StormBreakerConfig{MaxRate: 2, Cooldown: time.Minute},
+	}
+	c, err := New(cfg, msgs, connstate.New("MQTT", 5), zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetClock(schedule.NewFake(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)))
+	drops := stats.NewDrops()
+	c.SetDropCounter(drops)
+	var notices []string
+	c.OnStorm(func(topic string, maxRate int, cooldown time.Duration) {
+		notices = append(notices, topic)
+	})
+
+	for i := 0; i < 5; i++ {
+		c.messageHandler(nil, fakeMessage{topic: "loop/x"})
+	}
+	if len(msgs) != 2 {
+		t.Errorf("%d messages queued, want 2", len(msgs))
+	}
+	if len(notices) != 1 {
+		t.Errorf("%d storm notices, want 1", len(notices))
+	}
+	snap := drops.Snapshot()
+	if len(snap) != 1 || snap[0].Reason != string(stats.DropStorm) || snap[0].Count != 3 {
+		t.Errorf("drops = %+v, want 3 storm drops", snap)
+	}
+}
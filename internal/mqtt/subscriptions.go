@@ -0,0 +1,106 @@
+package mqtt
+
+import (
+	pahomqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/dyuri/mqtt2irc/internal/config"
+)
+
+// topics returns the current subscription list.
+func (c *Client) topics() []config.TopicConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]config.TopicConfig(nil), c.config.Topics...)
+}
+
+// Topics returns the current subscription list.
+func (c *Client) Topics() []config.TopicConfig {
+	return c.topics()
+}
+
+// subscribe subscribes to a single topic and logs the outcome.
+func (c *Client) subscribe(client pahomqtt.Client, topic config.TopicConfig) {
+	c.logger.Info().
+		Str("pattern", topic.Pattern).
+		Uint8("qos", topic.QoS).
+		Msg("subscribing to MQTT topic")
+
+	token := client.Subscribe(topic.Pattern, topic.QoS, c.messageHandler)
+	if token.Wait() && token.Error() != nil {
+		c.logger.Error().
+			Err(token.Error()).
+			Str("pattern", topic.Pattern).
+			Msg("failed to subscribe to topic")
+	} else {
+		c.logger.Info().
+			Str("pattern", topic.Pattern).
+			Msg("subscribed to topic")
+	}
+}
+
+// SetTopics replaces the subscription list (config reload). New or changed
+// topics are subscribed and dropped ones unsubscribed right away when
+// connected; the full list is used on every reconnect.
+func (c *Client) SetTopics(topics []config.TopicConfig) {
+	old := c.topics()
+	c.mu.Lock()
+	c.config.Topics = append([]config.TopicConfig(nil), topics...)
+	c.mu.Unlock()
+
+	if !c.client.IsConnectionOpen() {
+		return
+	}
+
+	current := make(map[string]byte, len(old))
+	for _, t := range old {
+		current[t.Pattern] = t.QoS
+	}
+	keep := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		keep[t.Pattern] = true
+		if qos, ok := current[t.Pattern]; !ok || qos != t.QoS {
+			c.subscribe(c.client, t)
+		}
+	}
+	for _, t := range old {
+		if keep[t.Pattern] {
+			continue
+		}
+		c.logger.Info().Str("pattern", t.Pattern).Msg("unsubscribing from MQTT topic")
+		if token := c.client.Unsubscribe(t.Pattern); token.Wait() && token.Error() != nil {
+			c.logger.Error().Err(token.Error()).Str("pattern", t.Pattern).Msg("failed to unsubscribe from topic")
+		}
+	}
+}
+
+// Handle calls fn with the payload of every message on topic instead of
+// forwarding it to the bridge queue. fn runs on paho's delivery goroutine and
+// must not block (or publish and wait). Must be called before Connect.
+func (c *Client) Handle(topic string, fn func(payload []byte)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.handlers == nil {
+		c.handlers = make(map[string]func(payload []byte))
+	}
+	c.handlers[topic] = fn
+}
+
+// subscribeHandlers subscribes to the topics registered with Handle. Called
+// from onConnect.
+func (c *Client) subscribeHandlers(client pahomqtt.Client) {
+	c.mu.Lock()
+	handlers := make(map[string]func(payload []byte), len(c.handlers))
+	for topic, fn := range c.handlers {
+		handlers[topic] = fn
+	}
+	c.mu.Unlock()
+
+	for topic, fn := range handlers {
+		token := client.Subscribe(topic, 1, func(_ pahomqtt.Client, msg pahomqtt.Message) {
+			fn(msg.Payload())
+		})
+		if token.Wait() && token.Error() != nil {
+			c.logger.Error().Err(token.Error()).Str("topic", topic).Msg("failed to subscribe to command topic")
+		}
+	}
+}
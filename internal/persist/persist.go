@@ -0,0 +1,284 @@
+// Package persist writes state files (node registry, channel history, queue)
+// with a selectable durability policy. In file mode every change is written
+// at once; in memory mode changes are kept in memory and written as periodic
+// snapshots, which saves SD cards on small boards such as a Raspberry Pi at
+// the cost of losing up to one interval of changes on power loss.
+//
+// Load reads them back without failing on files that are corrupt, truncated
+// or oversized (common after a power loss): the bad file is moved aside and
+// the store starts fresh, or from the backup WriteFile keeps.
+package persist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+// Modes of a Policy.
+const (
+	ModeFile   = "file"   // write on every change (default)
+	ModeMemory = "memory" // write every Interval and on shutdown
+)
+
+// DefaultInterval is the snapshot interval of memory mode when none is set.
+const DefaultInterval = 5 * time.Minute
+
+// Policy selects how a state file is written.
+type Policy struct {
+	Mode     string        // ModeFile ("" means the same) or ModeMemory
+	Interval time.Duration // between snapshots in memory mode (default DefaultInterval)
+	Fsync    bool          // fsync the file and its directory after each write
+	Backup   bool          // keep the previous file as <path>.bak, for Load to recover from
+}
+
+// Memory reports whether changes are only written as snapshots.
+func (p Policy) Memory() bool {
+	return p.Mode == ModeMemory
+}
+
+func (p Policy) interval() time.Duration {
+	if p.Interval > 0 {
+		return p.Interval
+	}
+	return DefaultInterval
+}
+
+// State tracks the unsaved changes of one state file and decides, by its
+// Policy, when they are written. It does not write anything itself; owners
+// call Changed on every change, write when it says so, and call Due
+// periodically and Flush on shutdown. A nil *State never asks for a write.
+type State struct {
+	policy Policy
+	clock  schedule.Clock
+
+	mu      sync.Mutex
+	dirty   bool
+	savedAt time.Time
+}
+
+// NewState tracks a state file written with policy. The first snapshot in
+// memory mode is due one interval after now.
+func NewState(policy Policy, clock schedule.Clock) *State {
+	return &State{policy: policy, clock: clock, savedAt: clock.Now()}
+}
+
+// Policy returns the policy of the state file.
+func (s *State) Policy() Policy {
+	if s == nil {
+		return Policy{}
+	}
+	return s.policy
+}
+
+// Changed records a change and reports whether to write now: always in file
+// mode, in memory mode only once the interval has passed since the last
+// write.
+func (s *State) Changed() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	return !s.policy.Memory() || s.clock.Now().Sub(s.savedAt) >= s.policy.interval()
+}
+
+// Touched records a change that is not worth a write of its own in file
+// mode; it is written with the next change, snapshot or Flush.
+func (s *State) Touched() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+}
+
+// Due reports whether there are unsaved changes whose snapshot is due.
+func (s *State) Due() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dirty && s.clock.Now().Sub(s.savedAt) >= s.policy.interval()
+}
+
+// Dirty reports whether there are unsaved changes, e.g. for a final write
+// on shutdown.
+func (s *State) Dirty() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dirty
+}
+
+// Saved records that the state was written.
+func (s *State) Saved() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = false
+	s.savedAt = s.clock.Now()
+}
+
+// WriteFile replaces path with data atomically: it writes a temporary file
+// in the same directory and renames it over path. With policy.Fsync the data
+// and the rename are flushed to the device before it returns; with
+// policy.Backup the replaced file is kept as <path>.bak.
+func WriteFile(path string, data []byte, policy Policy) error {
+	fsync := policy.Fsync
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if fsync {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return fmt.Errorf("sync %s: %w", path, err)
+		}
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if policy.Backup {
+		// A hard link costs no data writes. Best effort: without one (e.g.
+		// on FAT) there is simply no backup to recover from.
+		_ = os.Remove(path + BackupSuffix)
+		_ = os.Link(path, path+BackupSuffix)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if fsync {
+		d, err := os.Open(dir)
+		if err != nil {
+			return fmt.Errorf("sync %s: %w", dir, err)
+		}
+		defer d.Close()
+		if err := d.Sync(); err != nil {
+			return fmt.Errorf("sync %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// BackupSuffix is appended to a state file's path for its backup.
+const BackupSuffix = ".bak"
+
+// RemoveBackup deletes the backup of path, if there is one, e.g. after
+// data was purged from the state file so the backup must not keep it.
+func RemoveBackup(path string) error {
+	if err := os.Remove(path + BackupSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove backup of %s: %w", path, err)
+	}
+	return nil
+}
+
+// MaxFileSize is the largest state file Load reads; larger ones are treated
+// as corrupt rather than risking running out of memory on a small board.
+const MaxFileSize = 64 << 20
+
+// Recovery describes a corrupt state file Load moved aside.
+type Recovery struct {
+	Path       string // the state file
+	MovedTo    string // where the corrupt file is now
+	Err        error  // why it was rejected
+	FromBackup bool   // the backup was loaded instead; otherwise the store starts fresh
+	BackupErr  error  // why the backup could not be loaded, if there is one
+}
+
+var (
+	recoveryMu sync.Mutex
+	onRecovery = func(Recovery) {}
+)
+
+// OnRecovery sets the function told about every corrupt state file Load
+// moves aside, e.g. to log a warning. Call it at startup.
+func OnRecovery(fn func(Recovery)) {
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+	onRecovery = fn
+}
+
+// corruptError marks state files that were read but cannot be used.
+type corruptError struct{ err error }
+
+func (e *corruptError) Error() string { return e.err.Error() }
+
+// Load reads path and passes its content to decode, which must leave the
+// store unchanged when it returns an error. A missing file is a fresh start.
+// A file that is oversized or that decode rejects does not fail: it is moved
+// aside to <path>.corrupt-<time>, the backup is tried if backup is set, and
+// otherwise the store starts fresh; OnRecovery is told either way. Errors
+// are returned only when the file cannot be read or moved.
+func Load(path string, backup bool, decode func([]byte) error) error {
+	err := loadFile(path, decode)
+	var bad *corruptError
+	switch {
+	case err == nil || os.IsNotExist(err):
+		return nil
+	case !errors.As(err, &bad):
+		return err
+	}
+
+	r := Recovery{Path: path, MovedTo: path + ".corrupt-" + time.Now().UTC().Format("20060102T150405Z"), Err: bad.err}
+	if err := os.Rename(path, r.MovedTo); err != nil {
+		return fmt.Errorf("move corrupt %s aside: %w", path, err)
+	}
+	if backup {
+		switch err := loadFile(path+BackupSuffix, decode); {
+		case err == nil:
+			r.FromBackup = true
+		case !os.IsNotExist(err):
+			r.BackupErr = err
+		}
+	}
+	recoveryMu.Lock()
+	fn := onRecovery
+	recoveryMu.Unlock()
+	fn(r)
+	return nil
+}
+
+// loadFile reads path into decode; failures of decode and oversized files
+// are corruptErrors.
+func loadFile(path string, decode func([]byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxFileSize+1))
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if len(data) > MaxFileSize {
+		return &corruptError{fmt.Errorf("larger than %d bytes", MaxFileSize)}
+	}
+	if err := decode(data); err != nil {
+		return &corruptError{err}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/internal/schedule"
+)
+
+func TestState(t *testing.T) {
+	clock := schedule.NewFake(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC))
+
+	file := NewState(Policy{}, clock)
+	if !file.Changed() {
+		t.Error("file mode: change not written at once")
+	}
+
+	mem := NewState(Policy{Mode: ModeMemory, Interval: time.Minute}, clock)
+	if mem.Changed() || mem.Due() {
+		t.Error("memory mode: write asked for before the interval")
+	}
+	clock.Advance(time.Minute)
+	if !mem.Due() {
+		t.Error("memory mode: snapshot not due after the interval")
+	}
+	mem.Saved()
+	if mem.Dirty() || mem.Due() {
+		t.Error("changes left after Saved")
+	}
+	mem.Touched()
+	clock.Advance(time.Minute)
+	if !mem.Due() {
+		t.Error("touched state not due")
+	}
+
+	var none *State
+	if none.Changed() || none.Due() || none.Dirty() {
+		t.Error("nil State asked for a write")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	for _, fsync := range []bool{false, true} {
+		if err := WriteFile(path, []byte(`{"ok":true}`), Policy{Fsync: fsync}); err != nil {
+			t.Fatalf("WriteFile(fsync=%v): %v", fsync, err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != `{"ok":true}` {
+		t.Errorf("read back %q, %v", data, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("%d files left, want only the state file", len(entries))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	var recoveries []Recovery
+	OnRecovery(func(r Recovery) { recoveries = append(recoveries, r) })
+	defer OnRecovery(func(Recovery) {})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	var got map[string]int
+	decode := func(data []byte) error {
+		var m map[string]int
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		got = m
+		return nil
+	}
+
+	if err := Load(path, true, decode); err != nil || got != nil {
+		t.Fatalf("missing file: %v, %v", got, err)
+	}
+
+	// With backup the previous version is kept on every write.
+	backup := Policy{Backup: true}
+	for _, data := range []string{`{"n":1}`, `{"n":2}`} {
+		if err := WriteFile(path, []byte(data), backup); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Load(path, true, decode); err != nil || got["n"] != 2 {
+		t.Fatalf("good file: %v, %v", got, err)
+	}
+
+	// A truncated file is moved aside and the backup loaded.
+	got = nil
+	if err := os.WriteFile(path, []byte(`{"n":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(path, true, decode); err != nil || got["n"] != 1 {
+		t.Fatalf("corrupt file: %v, %v", got, err)
+	}
+	if len(recoveries) != 1 || !recoveries[0].FromBackup || recoveries[0].Err == nil {
+		t.Fatalf("recoveries = %+v", recoveries)
+	}
+	if _, err := os.Stat(recoveries[0].MovedTo); err != nil {
+		t.Errorf("corrupt file not kept: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("corrupt file left in place: %v", err)
+	}
+
+	// Without a usable backup the store starts fresh.
+	got = nil
+	if err := os.WriteFile(path, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(path, false, decode); err != nil || got != nil {
+		t.Fatalf("corrupt file without backup: %v, %v", got, err)
+	}
+	if len(recoveries) != 2 || recoveries[1].FromBackup {
+		t.Errorf("recoveries = %+v", recoveries)
+	}
+
+	// Errors other than a bad file still fail.
+	failing := func([]byte) error { return nil }
+	if err := Load(dir, false, failing); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unreadable path: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package redact masks sensitive values (tokens, passwords, coordinates) in
+// message payloads and log output.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultMask replaces redacted values when no mask is configured.
+const DefaultMask = "***"
+
+// Redactor masks JSON fields by name and text matching regular expressions.
+// A nil *Redactor is valid and redacts nothing.
+type Redactor struct {
+	fields   map[string]bool // lower-cased field names
+	patterns []*regexp.Regexp
+	mask     string
+}
+
+// New creates a Redactor. Field names match JSON object keys at any depth,
+// case-insensitively. For patterns with capture groups only the groups are
+// masked (e.g. `token=(\w+)`), otherwise the whole match is. Returns nil when
+// there is nothing to redact.
+func New(fields, patterns []string, mask string) (*Redactor, error) {
+	if len(fields) == 0 && len(patterns) == 0 {
+		return nil, nil
+	}
+	if mask == "" {
+		mask = DefaultMask
+	}
+	r := &Redactor{fields: make(map[string]bool, len(fields)), mask: mask}
+	for _, f := range fields {
+		r.fields[strings.ToLower(f)] = true
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Payload returns payload with configured JSON fields and pattern matches
+// masked. JSON payloads are re-encoded only when a field was masked; the
+// original slice is returned when nothing changed.
+func (r *Redactor) Payload(payload []byte) []byte {
+	if r == nil {
+		return payload
+	}
+	out := payload
+	if len(r.fields) > 0 {
+		if masked, ok := r.maskJSON(payload); ok {
+			out = masked
+		}
+	}
+	if len(r.patterns) > 0 {
+		s := string(out)
+		if masked := r.String(s); masked != s {
+			out = []byte(masked)
+		}
+	}
+	return out
+}
+
+// String masks pattern matches in s (field names only apply to JSON payloads).
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = r.replace(re, s)
+	}
+	return s
+}
+
+func (r *Redactor) replace(re *regexp.Regexp, s string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllLiteralString(s, r.mask)
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		for g := 1; g <= re.NumSubexp(); g++ {
+			start, end := m[2*g], m[2*g+1]
+			if start < last || start < 0 {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(r.mask)
+			last = end
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// maskJSON masks configured fields in a JSON payload. ok is false when the
+// payload is not JSON or contains none of the fields.
+func (r *Redactor) maskJSON(payload []byte) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber() // keep numbers exactly as published
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	if !r.maskValue(v) {
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+// maskValue masks matching keys in place and reports whether anything changed.
+func (r *Redactor) maskValue(v interface{}) bool {
+	changed := false
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if r.fields[strings.ToLower(k)] {
+				val[k] = r.mask
+				changed = true
+				continue
+			}
+			if r.maskValue(child) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, child := range val {
+			if r.maskValue(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,52 @@
+package redact
+
+import "testing"
+
+func TestRedactor_Payload(t *testing.T) {
+	r, err := New([]string{"password", "Latitude_I"}, []string{`token=(\w+)`, `\b\d{3}-\d{4}\b`}, "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"json field", `{"user":"bob","password":"hunter2"}`, `{"password":"***","user":"bob"}`},
+		{"nested and case-insensitive", `{"payload":{"latitude_i":475000000,"alt":120}}`, `{"payload":{"alt":120,"latitude_i":"***"}}`},
+		{"array", `[{"password":"x"},{"ok":1}]`, `[{"password":"***"},{"ok":1}]`},
+		{"numbers preserved", `{"password":"x","big":479000000}`, `{"big":479000000,"password":"***"}`},
+		{"no match unchanged", `{"temp": 21.5}`, `{"temp": 21.5}`},
+		{"pattern group", "login token=abc123 ok", "login token=*** ok"},
+		{"pattern whole match", "call 555-1234 now", "call *** now"},
+		{"pattern in json", `{"url":"x?token=abc"}`, `{"url":"x?token=***"}`},
+		{"plain text", "hello", "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(r.Payload([]byte(tt.payload))); got != tt.want {
+				t.Errorf("Payload(%q) = %q, want %q", tt.payload, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactor_Nil(t *testing.T) {
+	r, err := New(nil, nil, "")
+	if err != nil || r != nil {
+		t.Fatalf("New(nil, nil) = %v, %v; want nil, nil", r, err)
+	}
+	if got := string(r.Payload([]byte(`{"password":"x"}`))); got != `{"password":"x"}` {
+		t.Errorf("nil Payload() = %q", got)
+	}
+	if got := r.String("token=abc"); got != "token=abc" {
+		t.Errorf("nil String() = %q", got)
+	}
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	if _, err := New(nil, []string{"("}, ""); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,154 @@
+// Package schedule provides the time primitives shared by time-based
+// features: an injectable clock, cron expressions, time-of-day windows (quiet
+// hours) and timezone handling.
+package schedule
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock abstracts the current time and timers so time-based code can be
+// tested deterministically with a Fake clock.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel that receives the current time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls f in its own goroutine once d has elapsed (a Fake clock
+	// calls it from Advance).
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending AfterFunc call.
+type Timer interface {
+	// Stop prevents the call; it reports false if it already ran or was stopped.
+	Stop() bool
+}
+
+// Real is the wall clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Fake is a manually advanced clock for tests. The zero value is not usable;
+// create one with NewFake.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending After channel or AfterFunc call.
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+	fn func()
+}
+
+// NewFake returns a Fake clock set to t.
+func NewFake(t time.Time) *Fake {
+	return &Fake{now: t}
+}
+
+// Now returns the fake current time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel that fires once the clock is advanced by d.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, &fakeWaiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// AfterFunc calls fn once the clock is advanced by d. Due functions run
+// synchronously in Advance/Set, after the clock was moved.
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{at: f.now.Add(d), fn: fn}
+	f.waiters = append(f.waiters, w)
+	return fakeTimer{f: f, w: w}
+}
+
+type fakeTimer struct {
+	f *Fake
+	w *fakeWaiter
+}
+
+func (t fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	for i, w := range t.f.waiters {
+		if w == t.w {
+			t.f.waiters = append(t.f.waiters[:i], t.f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward by d, firing due timers in order.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves the clock to t (which must not be before the current time),
+// firing due timers in order.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	f.now = t
+	sort.SliceStable(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+	var kept []*fakeWaiter
+	var due []func()
+	for _, w := range f.waiters {
+		if w.at.After(t) {
+			kept = append(kept, w)
+			continue
+		}
+		if w.fn != nil {
+			due = append(due, w.fn)
+			continue
+		}
+		w.ch <- t
+	}
+	f.waiters = kept
+	f.mu.Unlock()
+
+	// Outside the lock: functions may use the clock themselves.
+	for _, fn := range due {
+		fn()
+	}
+}
+
+// Waiters returns the number of pending timers, so tests can wait until the
+// code under test is blocked on the clock before advancing it.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// LoadLocation resolves a timezone name; "" and "Local" mean the system zone.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
@@ -0,0 +1,187 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLoc(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestCronNext(t *testing.T) {
+	utc := time.UTC
+	base := time.Date(2026, 1, 2, 12, 34, 56, 0, utc) // Friday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 2, 12, 35, 0, 0, utc)},
+		{"*/15 * * * *", time.Date(2026, 1, 2, 12, 45, 0, 0, utc)},
+		{"0 9 * * *", time.Date(2026, 1, 3, 9, 0, 0, 0, utc)},
+		{"@daily", time.Date(2026, 1, 3, 0, 0, 0, 0, utc)},
+		{"30 8 * * mon-fri", time.Date(2026, 1, 5, 8, 30, 0, 0, utc)},
+		{"0 0 1 * *", time.Date(2026, 2, 1, 0, 0, 0, 0, utc)},
+		{"0 12 * feb 7", time.Date(2026, 2, 1, 12, 0, 0, 0, utc)},
+		{"0 0 13 * 5", time.Date(2026, 1, 9, 0, 0, 0, 0, utc)}, // dom OR dow
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := ParseCron(tt.expr, utc)
+			if err != nil {
+				t.Fatalf("ParseCron: %v", err)
+			}
+			if got := c.Next(base); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronTimezone(t *testing.T) {
+	budapest := mustLoc(t, "Europe/Budapest")
+	c, err := ParseCron("0 8 * * *", budapest)
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+	got := c.Next(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 7, 1, 6, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+	if !c.Matches(time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)) {
+		t.Error("Matches() false for 08:00 CET")
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := ParseCron(expr, time.UTC); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	utc := time.UTC
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 1, day, hour, min, 0, 0, utc) } // Jan 5 2026 is Monday
+
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"22:00-07:00", at(5, 23, 0), true},
+		{"22:00-07:00", at(5, 6, 59), true},
+		{"22:00-07:00", at(5, 7, 0), false},
+		{"22:00-07:00", at(5, 12, 0), false},
+		{"09:00-17:00", at(5, 9, 0), true},
+		{"09:00-17:00", at(5, 17, 0), false},
+		{"mon-fri 09:00-17:00", at(10, 10, 0), false}, // Saturday
+		{"mon-fri 09:00-17:00", at(9, 10, 0), true},   // Friday
+		{"fri 22:00-07:00", at(10, 3, 0), true},       // Saturday morning belongs to Friday night
+		{"fri 22:00-07:00", at(11, 3, 0), false},      // Sunday morning
+		{"12:00-24:00", at(5, 23, 59), true},
+	}
+
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.expr, utc)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q): %v", tt.expr, err)
+		}
+		if got := w.Contains(tt.t); got != tt.want {
+			t.Errorf("%q.Contains(%v) = %v, want %v", tt.expr, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestWindowEnd(t *testing.T) {
+	w, err := ParseWindow("22:00-07:00", time.UTC)
+	if err != nil {
+		t.Fatalf("ParseWindow: %v", err)
+	}
+	in := time.Date(2026, 1, 5, 23, 0, 0, 0, time.UTC)
+	if got, want := w.End(in), time.Date(2026, 1, 6, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("End() = %v, want %v", got, want)
+	}
+	out := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	if got := w.End(out); !got.Equal(out) {
+		t.Errorf("End() outside window = %v, want %v", got, out)
+	}
+}
+
+func TestParseWindowErrors(t *testing.T) {
+	for _, expr := range []string{"", "22:00", "25:00-07:00", "09:00-09:00", "xyz 09:00-10:00", "a b c"} {
+		if _, err := ParseWindow(expr, time.UTC); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := NewFake(start)
+
+	short := clk.After(time.Second)
+	long := clk.After(time.Minute)
+	if clk.Waiters() != 2 {
+		t.Fatalf("Waiters() = %d, want 2", clk.Waiters())
+	}
+
+	clk.Advance(30 * time.Second)
+	select {
+	case got := <-short:
+		if !got.Equal(start.Add(30 * time.Second)) {
+			t.Errorf("short timer fired at %v", got)
+		}
+	default:
+		t.Error("short timer did not fire")
+	}
+	select {
+	case <-long:
+		t.Error("long timer fired early")
+	default:
+	}
+
+	clk.Advance(30 * time.Second)
+	select {
+	case <-long:
+	default:
+		t.Error("long timer did not fire")
+	}
+	if !clk.Now().Equal(start.Add(time.Minute)) {
+		t.Errorf("Now() = %v", clk.Now())
+	}
+}
+
+func TestFakeClock_AfterFunc(t *testing.T) {
+	clk := NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	var fired []string
+	clk.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	clk.AfterFunc(time.Second, func() {
+		fired = append(fired, "a")
+		// Timers may be set from a callback.
+		clk.AfterFunc(time.Second, func() { fired = append(fired, "c") })
+	})
+	stopped := clk.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop() should report true once")
+	}
+
+	clk.Advance(2 * time.Second)
+	if got := strings.Join(fired, ","); got != "a,b" {
+		t.Errorf("fired %q, want a,b", got)
+	}
+	clk.Advance(time.Second)
+	if got := strings.Join(fired, ","); got != "a,b,c" {
+		t.Errorf("fired %q, want a,b,c", got)
+	}
+}
@@ -0,0 +1,112 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a recurring time-of-day window such as quiet hours, optionally
+// limited to certain weekdays. Windows may wrap midnight ("22:00-07:00");
+// the weekday restriction applies to the day the window starts.
+//
+// Syntax: "[days ]HH:MM-HH:MM", where days is a comma-separated list of
+// weekdays or weekday ranges, e.g. "mon-fri 09:00-17:00", "sat,sun 22:00-09:00".
+type Window struct {
+	expr       string
+	start, end int // minutes since midnight
+	days       uint8
+	loc        *time.Location
+}
+
+// ParseWindow parses a window evaluated in loc (nil = time.Local).
+func ParseWindow(expr string, loc *time.Location) (*Window, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	w := &Window{expr: expr, days: 0x7f, loc: loc}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 1:
+	case 2:
+		bits, err := parseCronField(strings.ToLower(fields[0]), 0, 7, weekdayNames)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: days: %w", expr, err)
+		}
+		if bits&(1<<7) != 0 {
+			bits |= 1
+		}
+		w.days = uint8(bits & 0x7f)
+		fields = fields[1:]
+	default:
+		return nil, fmt.Errorf("window %q: expected \"[days ]HH:MM-HH:MM\"", expr)
+	}
+
+	from, to, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return nil, fmt.Errorf("window %q: expected HH:MM-HH:MM", expr)
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return nil, fmt.Errorf("window %q: %w", expr, err)
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return nil, fmt.Errorf("window %q: %w", expr, err)
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("window %q: start and end must differ", expr)
+	}
+	return w, nil
+}
+
+// String returns the original expression.
+func (w *Window) String() string {
+	return w.expr
+}
+
+// Contains reports whether t falls inside the window.
+func (w *Window) Contains(t time.Time) bool {
+	t = t.In(w.loc)
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end && w.dayAllowed(t.Weekday())
+	}
+	// Wraps midnight: the late part belongs to today, the early part to yesterday.
+	if m >= w.start {
+		return w.dayAllowed(t.Weekday())
+	}
+	if m < w.end {
+		return w.dayAllowed((t.Weekday() + 6) % 7)
+	}
+	return false
+}
+
+// End returns when the window containing t ends; t itself if t is outside.
+func (w *Window) End(t time.Time) time.Time {
+	if !w.Contains(t) {
+		return t
+	}
+	lt := t.In(w.loc)
+	end := time.Date(lt.Year(), lt.Month(), lt.Day(), w.end/60, w.end%60, 0, 0, w.loc)
+	if !end.After(lt) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+func (w *Window) dayAllowed(d time.Weekday) bool {
+	return w.days&(1<<uint(d)) != 0
+}
+
+// parseClock parses "HH:MM" (24:00 is accepted as end of day) into minutes.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
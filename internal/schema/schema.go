@@ -0,0 +1,352 @@
+// Package schema validates JSON payloads against a JSON Schema. It supports
+// the keywords needed to pin down a device's payload format: type, enum,
+// const, properties, required, additionalProperties (boolean), items,
+// minItems, maxItems, minimum, maximum, exclusiveMinimum, exclusiveMaximum,
+// minLength, maxLength and pattern. Other keywords are ignored.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	types      []string
+	enum       []interface{}
+	constant   interface{}
+	hasConst   bool
+	properties map[string]*Schema
+	required   []string
+	noExtra    bool // additionalProperties: false
+	items      *Schema
+	minItems   *int
+	maxItems   *int
+	minimum    *float64
+	maximum    *float64
+	exclMin    *float64
+	exclMax    *float64
+	minLength  *int
+	maxLength  *int
+	pattern    *regexp.Regexp
+}
+
+// Load reads and compiles a JSON Schema file.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s, err := Compile(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Compile compiles a decoded JSON Schema document.
+func Compile(doc interface{}) (*Schema, error) {
+	return compile(doc, "#")
+}
+
+func compile(doc interface{}, path string) (*Schema, error) {
+	if b, ok := doc.(bool); ok {
+		if b {
+			return &Schema{}, nil
+		}
+		return &Schema{enum: []interface{}{}}, nil // matches nothing
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object", path)
+	}
+
+	s := &Schema{}
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, e := range t {
+			name, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s/type: must be a string or list of strings", path)
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s/type: must be a string or list of strings", path)
+	}
+	for _, t := range s.types {
+		switch t {
+		case "object", "array", "string", "number", "integer", "boolean", "null":
+		default:
+			return nil, fmt.Errorf("%s/type: unknown type %q", path, t)
+		}
+	}
+
+	if e, ok := m["enum"]; ok {
+		list, ok := e.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/enum: must be a list", path)
+		}
+		s.enum = list
+	}
+	s.constant, s.hasConst = m["const"]
+
+	if p, ok := m["properties"]; ok {
+		props, ok := p.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/properties: must be an object", path)
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, sub := range props {
+			c, err := compile(sub, path+"/properties/"+name)
+			if err != nil {
+				return nil, err
+			}
+			s.properties[name] = c
+		}
+	}
+	if r, ok := m["required"]; ok {
+		list, ok := r.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/required: must be a list", path)
+		}
+		for _, e := range list {
+			name, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s/required: must be a list of strings", path)
+			}
+			s.required = append(s.required, name)
+		}
+	}
+	if a, ok := m["additionalProperties"].(bool); ok {
+		s.noExtra = !a
+	}
+	if i, ok := m["items"]; ok {
+		c, err := compile(i, path+"/items")
+		if err != nil {
+			return nil, err
+		}
+		s.items = c
+	}
+
+	var err error
+	if s.minItems, err = intKeyword(m, "minItems", path); err != nil {
+		return nil, err
+	}
+	if s.maxItems, err = intKeyword(m, "maxItems", path); err != nil {
+		return nil, err
+	}
+	if s.minLength, err = intKeyword(m, "minLength", path); err != nil {
+		return nil, err
+	}
+	if s.maxLength, err = intKeyword(m, "maxLength", path); err != nil {
+		return nil, err
+	}
+	if s.minimum, err = numberKeyword(m, "minimum", path); err != nil {
+		return nil, err
+	}
+	if s.maximum, err = numberKeyword(m, "maximum", path); err != nil {
+		return nil, err
+	}
+	if s.exclMin, err = numberKeyword(m, "exclusiveMinimum", path); err != nil {
+		return nil, err
+	}
+	if s.exclMax, err = numberKeyword(m, "exclusiveMaximum", path); err != nil {
+		return nil, err
+	}
+	if p, ok := m["pattern"]; ok {
+		expr, ok := p.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s/pattern: must be a string", path)
+		}
+		if s.pattern, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("%s/pattern: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+func numberKeyword(m map[string]interface{}, key, path string) (*float64, error) {
+	v, ok := m[key]
+	if !ok {
+		return nil, nil
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s/%s: must be a number", path, key)
+	}
+	return &f, nil
+}
+
+func intKeyword(m map[string]interface{}, key, path string) (*int, error) {
+	f, err := numberKeyword(m, key, path)
+	if f == nil || err != nil {
+		return nil, err
+	}
+	if *f < 0 || *f != float64(int(*f)) {
+		return nil, fmt.Errorf("%s/%s: must be a non-negative integer", path, key)
+	}
+	n := int(*f)
+	return &n, nil
+}
+
+// Validate checks v, a value decoded by encoding/json, and returns the first
+// violation found, e.g. "/temperature: expected number, got string".
+func (s *Schema) Validate(v interface{}) error {
+	return s.validate(v, "")
+}
+
+func (s *Schema) validate(v interface{}, path string) error {
+	where := path
+	if where == "" {
+		where = "/"
+	}
+
+	if len(s.types) > 0 {
+		ok := false
+		for _, t := range s.types {
+			if hasType(v, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %s", where, joinTypes(s.types), typeOf(v))
+		}
+	}
+	if s.enum != nil && !contains(s.enum, v) {
+		return fmt.Errorf("%s: value not in enum", where)
+	}
+	if s.hasConst && !reflect.DeepEqual(s.constant, v) {
+		return fmt.Errorf("%s: value does not match const", where)
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", where, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names) // report violations deterministically
+		for _, name := range names {
+			sub, ok := s.properties[name]
+			if !ok {
+				if s.noExtra {
+					return fmt.Errorf("%s: unexpected property %q", where, name)
+				}
+				continue
+			}
+			if err := sub.validate(v[name], path+"/"+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			return fmt.Errorf("%s: fewer than %d items", where, *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			return fmt.Errorf("%s: more than %d items", where, *s.maxItems)
+		}
+		if s.items != nil {
+			for i, e := range v {
+				if err := s.items.validate(e, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			return fmt.Errorf("%s: %v is less than %v", where, v, *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			return fmt.Errorf("%s: %v is greater than %v", where, v, *s.maximum)
+		}
+		if s.exclMin != nil && v <= *s.exclMin {
+			return fmt.Errorf("%s: %v is not greater than %v", where, v, *s.exclMin)
+		}
+		if s.exclMax != nil && v >= *s.exclMax {
+			return fmt.Errorf("%s: %v is not less than %v", where, v, *s.exclMax)
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			return fmt.Errorf("%s: shorter than %d characters", where, *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			return fmt.Errorf("%s: longer than %d characters", where, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return fmt.Errorf("%s: does not match pattern %s", where, s.pattern)
+		}
+	}
+	return nil
+}
+
+func hasType(v interface{}, t string) bool {
+	switch t {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	default:
+		return typeOf(v) == t
+	}
+}
+
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinTypes(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	out := types[0]
+	for _, t := range types[1:] {
+		out += " or " + t
+	}
+	return out
+}
+
+func contains(list []interface{}, v interface{}) bool {
+	for _, e := range list {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const envSchema = `{
+  "type": "object",
+  "required": ["temperature", "unit"],
+  "additionalProperties": false,
+  "properties": {
+    "temperature": {"type": "number", "minimum": -40, "maximum": 85},
+    "unit": {"enum": ["C", "F"]},
+    "id": {"type": "string", "pattern": "^[0-9a-f]{8}$"},
+    "tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "minLength": 1}},
+    "count": {"type": ["integer", "null"]}
+  }
+}`
+
+func mustCompile(t *testing.T, doc string) *Schema {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Compile(v)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	return s
+}
+
+func TestValidate(t *testing.T) {
+	s := mustCompile(t, envSchema)
+	tests := []struct {
+		payload string
+		err     string // substring; empty = valid
+	}{
+		{`{"temperature": 21.5, "unit": "C"}`, ""},
+		{`{"temperature": 21.5, "unit": "C", "id": "0a1b2c3d", "tags": ["a"], "count": null}`, ""},
+		{`{"temperature": 21.5, "unit": "C", "count": 3}`, ""},
+		{`{"temperature": "21.5", "unit": "C"}`, "/temperature: expected number, got string"},
+		{`{"temperature": 90, "unit": "C"}`, "/temperature: 90 is greater than 85"},
+		{`{"unit": "C"}`, `/: missing required property "temperature"`},
+		{`{"temperature": 1, "unit": "K"}`, "/unit: value not in enum"},
+		{`{"temperature": 1, "unit": "C", "extra": 1}`, `unexpected property "extra"`},
+		{`{"temperature": 1, "unit": "C", "id": "xyz"}`, "/id: does not match pattern"},
+		{`{"temperature": 1, "unit": "C", "tags": ["a", "b", "c"]}`, "/tags: more than 2 items"},
+		{`{"temperature": 1, "unit": "C", "tags": [""]}`, "/tags/0: shorter than 1 characters"},
+		{`{"temperature": 1, "unit": "C", "count": 1.5}`, "/count: expected integer or null, got number"},
+		{`[1, 2]`, "/: expected object, got array"},
+	}
+	for _, tt := range tests {
+		var v interface{}
+		if err := json.Unmarshal([]byte(tt.payload), &v); err != nil {
+			t.Fatal(err)
+		}
+		err := s.Validate(v)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.payload, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: error = %v, want %q", tt.payload, err, tt.err)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, doc := range []string{
+		`[]`,
+		`{"type": "float"}`,
+		`{"pattern": "("}`,
+		`{"minLength": -1}`,
+		`{"properties": {"a": {"type": 1}}}`,
+	} {
+		var v interface{}
+		json.Unmarshal([]byte(doc), &v)
+		if _, err := Compile(v); err == nil {
+			t.Errorf("Compile(%s) succeeded", doc)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.json")
+	if err := os.WriteFile(path, []byte(envSchema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("Load: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Load of invalid JSON: %v", err)
+	}
+}
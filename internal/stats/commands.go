@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// maxCommandNicks bounds the nicks Commands tracks; unauthorized senders can
+// pick any nick. Further nicks are counted under OtherNicks.
+const maxCommandNicks = 500
+
+// OtherNicks collects the counts of nicks beyond maxCommandNicks.
+const OtherNicks = "(other)"
+
+// Commands counts admin command use per nick, for security review of who
+// operates the bridge. The zero value is not usable; use NewCommands. A nil
+// *Commands ignores records.
+type Commands struct {
+	mu     sync.Mutex
+	counts map[string]*types.CommandCount // lower-cased nick → counts
+}
+
+// NewCommands creates an empty admin command counter.
+func NewCommands() *Commands {
+	return &Commands{counts: make(map[string]*types.CommandCount)}
+}
+
+// Invoked counts an authorized command by nick.
+func (c *Commands) Invoked(nick, command string, now time.Time) {
+	c.record(nick, command, now, func(cc *types.CommandCount) { cc.Commands++ })
+}
+
+// Failed counts a failed command by nick.
+func (c *Commands) Failed(nick, command string, now time.Time) {
+	c.record(nick, command, now, func(cc *types.CommandCount) { cc.Failures++ })
+}
+
+// Unauthorized counts a command attempt by a nick without access.
+func (c *Commands) Unauthorized(nick, command string, now time.Time) {
+	c.record(nick, command, now, func(cc *types.CommandCount) { cc.Unauthorized++ })
+}
+
+func (c *Commands) record(nick, command string, now time.Time, count func(*types.CommandCount)) {
+	if c == nil || nick == "" {
+		return
+	}
+	key := strings.ToLower(nick)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cc, ok := c.counts[key]
+	if !ok {
+		if len(c.counts) >= maxCommandNicks {
+			key, nick = OtherNicks, OtherNicks
+			cc = c.counts[key]
+		}
+		if cc == nil {
+			cc = &types.CommandCount{Nick: nick}
+			c.counts[key] = cc
+		}
+	}
+	count(cc)
+	cc.LastCommand = command
+	cc.Last = now
+}
+
+// Snapshot returns the counts, most active nick first.
+func (c *Commands) Snapshot() []types.CommandCount {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]types.CommandCount, 0, len(c.counts))
+	for _, cc := range c.counts {
+		out = append(out, *cc)
+	}
+	total := func(cc types.CommandCount) uint64 { return cc.Commands + cc.Failures + cc.Unauthorized }
+	sort.Slice(out, func(i, j int) bool {
+		if ti, tj := total(out[i]), total(out[j]); ti != tj {
+			return ti > tj
+		}
+		return out[i].Nick < out[j].Nick
+	})
+	return out
+}
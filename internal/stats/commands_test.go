@@ -0,0 +1,49 @@
+package stats
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCommands(t *testing.T) {
+	c := NewCommands()
+	t0 := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	c.Invoked("Alice", "status", t0)
+	c.Invoked("alice", "mapping", t0)
+	c.Failed("alice", "mapping", t0.Add(time.Minute))
+	c.Unauthorized("mallory", "shutdown", t0)
+	c.Invoked("", "status", t0) // internal dispatch, no nick
+
+	snap := c.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("got %d nicks, want 2: %+v", len(snap), snap)
+	}
+	if a := snap[0]; a.Nick != "Alice" || a.Commands != 2 || a.Failures != 1 || a.LastCommand != "mapping" || !a.Last.Equal(t0.Add(time.Minute)) {
+		t.Errorf("first = %+v, want Alice with 2 commands, 1 failure", a)
+	}
+	if m := snap[1]; m.Nick != "mallory" || m.Unauthorized != 1 || m.Commands != 0 {
+		t.Errorf("second = %+v, want mallory with 1 unauthorized attempt", m)
+	}
+}
+
+func TestCommands_Bounded(t *testing.T) {
+	c := NewCommands()
+	now := time.Now()
+	for i := 0; i < maxCommandNicks+10; i++ {
+		c.Unauthorized(fmt.Sprintf("nick%d", i), "status", now)
+	}
+	snap := c.Snapshot()
+	if len(snap) != maxCommandNicks+1 {
+		t.Fatalf("tracking %d nicks, want %d plus %s", len(snap), maxCommandNicks, OtherNicks)
+	}
+	if snap[0].Nick != OtherNicks || snap[0].Unauthorized != 10 {
+		t.Errorf("first = %+v, want the overflow nicks counted under %s", snap[0], OtherNicks)
+	}
+
+	var nilCommands *Commands
+	nilCommands.Invoked("a", "status", now)
+	if nilCommands.Snapshot() != nil {
+		t.Error("nil Commands snapshot should be nil")
+	}
+}
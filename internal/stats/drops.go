@@ -0,0 +1,89 @@
+// Package stats collects counters shared by the MQTT client and the bridge.
+package stats
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// DropReason says why a message never reached IRC.
+type DropReason string
+
+// Drop reasons, one per drop path.
+const (
+	DropQueueFull      DropReason = "queue_full"      // bridge queue full
+	DropLowPriority    DropReason = "low_priority"    // QoS 0 above the low-priority watermark
+	DropRedelivery     DropReason = "redelivery"      // MQTT DUP redelivery suppressed
+	DropStorm          DropReason = "storm"           // topic suppressed by the storm breaker
+	DropNoMapping      DropReason = "no_mapping"      // no active mapping matches the topic
+	DropMute           DropReason = "mute"            // suppressed by !mute
+	DropQuota          DropReason = "quota"           // tenant daily quota exceeded
+	DropDedup          DropReason = "dedup"           // duplicate suppressed by a processor
+	DropPrivacy        DropReason = "privacy"         // withheld by a processor's privacy rules
+	DropProcessor      DropReason = "processor"       // dropped by a processor (other reasons)
+	DropChannelBudget  DropReason = "channel_budget"  // channel (and overflow channel) over budget
+	DropChannelQueue   DropReason = "channel_queue"   // channel's outbound queue full
+	DropPaused         DropReason = "paused"          // bridge paused (Home Assistant pause button)
+	DropSendFailed     DropReason = "send_failed"     // IRC send failed
+	DropChannelBlocked DropReason = "channel_blocked" // bot cannot speak in the channel (+m without voice, banned)
+	DropSchema         DropReason = "schema"          // payload violates the mapping's schema (schema.drop)
+	DropDigest         DropReason = "digest"          // summarized into a processor's periodic digest
+	DropQuiet          DropReason = "quiet"           // control traffic a processor counts but does not post by default
+	DropReview         DropReason = "review"          // rejected in, or evicted unreviewed from, a mapping's review channel
+	DropAcked          DropReason = "acked"           // repeat of an alert acknowledged with !ack
+	DropSpam           DropReason = "spam"            // looks like spam to the mapping's spam heuristics
+	DropCircuitOpen    DropReason = "circuit_open"    // channel skipped after repeated send failures
+	DropMappingRemoved DropReason = "mapping_removed" // queued for a mapping a reload removed (removed_mappings.queued: drop)
+)
+
+// Drops counts dropped messages by reason and remembers the latest one. The
+// zero value is not usable; use NewDrops. A nil *Drops ignores records.
+type Drops struct {
+	mu     sync.Mutex
+	counts map[DropReason]*types.DropCount
+}
+
+// NewDrops creates an empty drop counter.
+func NewDrops() *Drops {
+	return &Drops{counts: make(map[DropReason]*types.DropCount)}
+}
+
+// Record counts a dropped message on topic.
+func (d *Drops) Record(reason DropReason, topic string, now time.Time) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.counts[reason]
+	if !ok {
+		c = &types.DropCount{Reason: string(reason)}
+		d.counts[reason] = c
+	}
+	c.Count++
+	c.LastTopic = topic
+	c.Last = now
+}
+
+// Snapshot returns the counts, most frequent reason first.
+func (d *Drops) Snapshot() []types.DropCount {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]types.DropCount, 0, len(d.counts))
+	for _, c := range d.counts {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Reason < out[j].Reason
+	})
+	return out
+}
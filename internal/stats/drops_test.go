@@ -0,0 +1,36 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDrops(t *testing.T) {
+	d := NewDrops()
+	t0 := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	d.Record(DropDedup, "msh/a", t0)
+	d.Record(DropQueueFull, "sensors/x", t0)
+	d.Record(DropDedup, "msh/b", t0.Add(time.Minute))
+
+	snap := d.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("got %d reasons, want 2", len(snap))
+	}
+	if snap[0].Reason != string(DropDedup) || snap[0].Count != 2 {
+		t.Errorf("first = %+v, want dedup x2", snap[0])
+	}
+	if snap[0].LastTopic != "msh/b" || !snap[0].Last.Equal(t0.Add(time.Minute)) {
+		t.Errorf("last = %s at %v, want msh/b at %v", snap[0].LastTopic, snap[0].Last, t0.Add(time.Minute))
+	}
+	if snap[1].Reason != string(DropQueueFull) || snap[1].Count != 1 {
+		t.Errorf("second = %+v, want queue_full x1", snap[1])
+	}
+}
+
+func TestDrops_Nil(t *testing.T) {
+	var d *Drops
+	d.Record(DropMute, "a", time.Now())
+	if snap := d.Snapshot(); snap != nil {
+		t.Errorf("nil Drops snapshot = %v, want nil", snap)
+	}
+}
@@ -0,0 +1,228 @@
+package transform
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// The built-in transforms are registered on import, so config validation
+// and the bridge see the same set without a separate registration step.
+func init() {
+	Register("strip_colors", noArg(StripColors))
+	Register("ascii", noArg(ASCII))
+	Register("emoji_shortcodes", noArg(EmojiToShortcodes))
+	Register("emoji_unicode", noArg(ShortcodesToEmoji))
+	Register("truncate", newTruncate)
+	Register("prefix", newPrefix)
+	Register("strip_urls", noArg(StripURLs))
+	Register("mark_urls", newMarkURLs)
+	Register("wrap_urls", newWrapURLs)
+
+	Document("strip_colors", types.ComponentDoc{Summary: "Removes IRC colors and formatting (bold, italics, underline, ...)"})
+	Document("ascii", types.ComponentDoc{Summary: "Transliterates to ASCII: é → e, ß → ss, … → ...; other characters become ?"})
+	Document("emoji_shortcodes", types.ComponentDoc{Summary: "Replaces emoji with their shortcodes (✅ → :white_check_mark:)"})
+	Document("emoji_unicode", types.ComponentDoc{Summary: "Replaces shortcodes with the emoji (:white_check_mark: → ✅)"})
+	Document("strip_urls", types.ComponentDoc{Summary: "Removes links (http://, https://, ftp:// and www. addresses)"})
+	Document("mark_urls", types.ComponentDoc{
+		Summary: "Replaces links with a marker",
+		Options: []types.OptionDoc{{Name: "TEXT", Type: "string", Default: DefaultURLMarker, Description: "The marker (mark_urls=TEXT)"}},
+	})
+	Document("wrap_urls", types.ComponentDoc{
+		Summary: "Routes links through a redirector, link checker or shortener",
+		Options: []types.OptionDoc{{Name: "URL", Type: "string", Required: true, Description: "The wrapper URL; {url} is replaced by the query-escaped link, which is appended without it (wrap_urls=URL)"}},
+	})
+	Document("truncate", types.ComponentDoc{
+		Summary: "Cuts lines to a length, with the truncation suffix",
+		Options: []types.OptionDoc{{Name: "N", Type: "int", Default: "max_message_length", Description: "Maximum line length in characters (truncate=N)"}},
+	})
+	Document("prefix", types.ComponentDoc{
+		Summary: "Puts a text in front of every line",
+		Options: []types.OptionDoc{{Name: "TEXT", Type: "string", Required: true, Description: "The prefix, separated from the line by a space (prefix=TEXT)"}},
+	})
+}
+
+// noArg adapts a transform that takes no argument.
+func noArg(fn Func) Factory {
+	return func(opts Options) (Func, error) {
+		if opts.Arg != "" {
+			return nil, fmt.Errorf("takes no argument")
+		}
+		return fn, nil
+	}
+}
+
+// newTruncate cuts lines to the bridge's limits, or to the number of
+// characters given as argument, with the truncation suffix.
+func newTruncate(opts Options) (Func, error) {
+	limits := opts.Limits
+	if opts.Arg != "" {
+		n, err := strconv.Atoi(opts.Arg)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("length must be a positive number, got %q", opts.Arg)
+		}
+		limits.MaxLength = n
+	}
+	return limits.Fit, nil
+}
+
+// newPrefix puts its argument in front of every line.
+func newPrefix(opts Options) (Func, error) {
+	if opts.Arg == "" {
+		return nil, fmt.Errorf("needs the prefix, e.g. prefix=[mesh]")
+	}
+	prefix := opts.Arg + " "
+	return func(s string) string { return prefix + s }, nil
+}
+
+// urlPattern matches the links StripURLs removes.
+var urlPattern = regexp.MustCompile(`(?i)\b(?:(?:https?|ftp)://|www\.)[^\s<>"]+`)
+
+// StripURLs removes links from s, with the space before them.
+func StripURLs(s string) string {
+	if !hasURL(s) {
+		return s
+	}
+	return strings.TrimSpace(spaceRun.ReplaceAllString(urlPattern.ReplaceAllString(s, ""), " "))
+}
+
+// DefaultURLMarker replaces links for mark_urls without an argument.
+const DefaultURLMarker = "[link removed]"
+
+// newMarkURLs replaces links with its argument, or DefaultURLMarker.
+func newMarkURLs(opts Options) (Func, error) {
+	marker := opts.Arg
+	if marker == "" {
+		marker = DefaultURLMarker
+	}
+	return func(s string) string {
+		if !hasURL(s) {
+			return s
+		}
+		return urlPattern.ReplaceAllLiteralString(s, marker)
+	}, nil
+}
+
+// newWrapURLs replaces links with its argument, a URL in which "{url}" is
+// replaced by the query-escaped link; without "{url}" the link is appended.
+func newWrapURLs(opts Options) (Func, error) {
+	wrapper := opts.Arg
+	if wrapper == "" {
+		return nil, fmt.Errorf("needs the wrapper URL, e.g. wrap_urls=https://check.example/?u={url}")
+	}
+	if !strings.Contains(wrapper, "{url}") {
+		wrapper += "{url}"
+	}
+	return func(s string) string {
+		if !hasURL(s) {
+			return s
+		}
+		return urlPattern.ReplaceAllStringFunc(s, func(link string) string {
+			return strings.ReplaceAll(wrapper, "{url}", url.QueryEscape(link))
+		})
+	}, nil
+}
+
+// hasURL reports whether s may contain a link, cheaply.
+func hasURL(s string) bool {
+	return strings.Contains(s, "://") || strings.Contains(strings.ToLower(s), "www.")
+}
+
+// spaceRun matches the spaces left where a link was removed.
+var spaceRun = regexp.MustCompile(` {2,}`)
+
+// StripColors removes IRC formatting: colors (with their codes), bold,
+// italics, underline, strikethrough, monospace, reverse and reset.
+func StripColors(s string) string {
+	if !strings.ContainsAny(s, "\x02\x03\x04\x0f\x11\x16\x1d\x1e\x1f") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\x03': // ^C[fg[,bg]], one or two digits each
+			i += skipColor(s[i+1:], isDigit, 2)
+		case '\x04': // hex color ^D[rrggbb[,rrggbb]]
+			i += skipColor(s[i+1:], isHex, 6)
+		case '\x02', '\x0f', '\x11', '\x16', '\x1d', '\x1e', '\x1f':
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// skipColor returns the length of the color code at the start of s: up to
+// max digits, optionally followed by a comma and up to max more.
+func skipColor(s string, digit func(byte) bool, max int) int {
+	n := 0
+	for n < len(s) && n < max && digit(s[n]) {
+		n++
+	}
+	if n == 0 || n >= len(s) || s[n] != ',' {
+		return n
+	}
+	m := 0
+	for n+1+m < len(s) && m < max && digit(s[n+1+m]) {
+		m++
+	}
+	if m == 0 {
+		return n // a plain comma after the color
+	}
+	return n + 1 + m
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isHex(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// asciiReplacements spell out characters that do not decompose into ASCII.
+var asciiReplacements = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D", 'þ': "th", 'Þ': "Th", 'ı': "i",
+	'‘': "'", '’': "'", '‚': "'", '“': "\"", '”': "\"", '„': "\"", '«': "\"", '»': "\"",
+	'–': "-", '—': "-", '−': "-", '…': "...", '•': "*", '·': ".", '×': "x",
+	'°': " deg", '€': "EUR", '£': "GBP", '©': "(c)", '®': "(R)", '™': "TM",
+	'→': "->", '←': "<-", '⏎': "|", ' ': " ",
+}
+
+// ASCII transliterates s to ASCII: accents are dropped ("é" → "e"), some
+// characters are spelled out ("ß" → "ss", "…" → "...") and everything else
+// outside ASCII becomes "?".
+func ASCII(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case r < 0x80:
+			sb.WriteRune(r)
+		case unicode.Is(unicode.Mn, r) || r == variationSelector:
+			// combining accent or emoji presentation
+		default:
+			if rep, ok := asciiReplacements[r]; ok {
+				sb.WriteString(rep)
+			} else {
+				sb.WriteByte('?')
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,122 @@
+// Package transform holds output transforms: functions applied to formatted
+// IRC lines after rendering, per mapping.
+package transform
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Func transforms one formatted IRC line.
+type Func func(string) string
+
+// variationSelector asks for the emoji presentation of the preceding
+// character; it is dropped with the emoji it follows.
+const variationSelector = '️'
+
+// emojiCodes lists the emoji converted to and from shortcodes, with the
+// shortcodes used by GitHub and Slack. Unlisted emoji are left alone.
+var emojiCodes = []struct {
+	emoji rune
+	code  string
+}{
+	// Faces and people
+	{'😀', "grinning"}, {'😃', "smiley"}, {'😄', "smile"}, {'😁', "grin"},
+	{'😆', "laughing"}, {'😅', "sweat_smile"}, {'😂', "joy"}, {'🙂', "slightly_smiling_face"},
+	{'😉', "wink"}, {'😊', "blush"}, {'😍', "heart_eyes"}, {'😎', "sunglasses"},
+	{'🤔', "thinking"}, {'😐', "neutral_face"}, {'😴', "sleeping"}, {'😢', "cry"},
+	{'😭', "sob"}, {'😱', "scream"}, {'😡', "rage"}, {'🤯', "exploding_head"},
+	{'👍', "+1"}, {'👎', "-1"}, {'👋', "wave"}, {'👏', "clap"},
+	{'🙏', "pray"}, {'💪', "muscle"}, {'👀', "eyes"}, {'🚶', "walking"},
+	{'🏃', "runner"},
+	// Symbols and status
+	{'✅', "white_check_mark"}, {'❌', "x"}, {'❎', "negative_squared_cross_mark"}, {'✔', "heavy_check_mark"},
+	{'⚠', "warning"}, {'⛔', "no_entry"}, {'🚫', "no_entry_sign"}, {'❗', "exclamation"},
+	{'❓', "question"}, {'ℹ', "information_source"}, {'🆗', "ok"}, {'🆘', "sos"},
+	{'🔴', "red_circle"}, {'🟠', "orange_circle"}, {'🟡', "yellow_circle"}, {'🟢', "green_circle"},
+	{'🔵', "large_blue_circle"}, {'⚫', "black_circle"}, {'⚪', "white_circle"}, {'⭐', "star"},
+	{'✨', "sparkles"}, {'💯', "100"}, {'❤', "heart"}, {'💔', "broken_heart"},
+	{'💚', "green_heart"}, {'💙', "blue_heart"}, {'💛', "yellow_heart"}, {'💜', "purple_heart"},
+	{'🔥', "fire"}, {'💥', "boom"}, {'⚡', "zap"}, {'🎉', "tada"},
+	{'🚨', "rotating_light"}, {'🔔', "bell"}, {'🔕', "no_bell"}, {'📢', "loudspeaker"},
+	{'📣', "mega"}, {'⬆', "arrow_up"}, {'⬇', "arrow_down"}, {'➡', "arrow_right"},
+	{'⬅', "arrow_left"}, {'↗', "arrow_upper_right"}, {'↘', "arrow_lower_right"}, {'🔄', "arrows_counterclockwise"},
+	{'🔁', "repeat"}, {'⏸', "pause_button"}, {'▶', "arrow_forward"}, {'⏹', "stop_button"},
+	{'🔒', "lock"}, {'🔓', "unlock"}, {'🔑', "key"}, {'🛑', "stop_sign"},
+	// Weather and nature
+	{'☀', "sunny"}, {'🌤', "sun_behind_small_cloud"}, {'⛅', "partly_sunny"}, {'☁', "cloud"},
+	{'🌧', "cloud_with_rain"}, {'⛈', "cloud_with_lightning_and_rain"}, {'🌩', "cloud_with_lightning"}, {'🌨', "cloud_with_snow"},
+	{'❄', "snowflake"}, {'🌬', "wind_face"}, {'🌪', "tornado"}, {'🌫', "fog"},
+	{'🌈', "rainbow"}, {'☔', "umbrella"}, {'💧', "droplet"}, {'🌊', "ocean"},
+	{'🌡', "thermometer"}, {'🌍', "earth_africa"}, {'🌎', "earth_americas"}, {'🌏', "earth_asia"},
+	{'🌙', "crescent_moon"}, {'🌱', "seedling"}, {'🌳', "deciduous_tree"}, {'🍃', "leaves"},
+	// Objects and devices
+	{'📡', "satellite"}, {'🛰', "artificial_satellite"}, {'📱', "iphone"}, {'💻', "computer"},
+	{'🖥', "desktop_computer"}, {'⌨', "keyboard"}, {'🖨', "printer"}, {'📷', "camera"},
+	{'📹', "video_camera"}, {'🎥', "movie_camera"}, {'🔋', "battery"}, {'🔌', "electric_plug"},
+	{'💡', "bulb"}, {'🔦', "flashlight"}, {'📶', "signal_strength"}, {'📊', "bar_chart"},
+	{'📈', "chart_with_upwards_trend"}, {'📉', "chart_with_downwards_trend"}, {'📦', "package"}, {'📍', "round_pushpin"},
+	{'📌', "pushpin"}, {'🗺', "world_map"}, {'🧭', "compass"}, {'🖊', "pen"},
+	{'✏', "pencil2"}, {'📝', "memo"}, {'📄', "page_facing_up"}, {'📁', "file_folder"},
+	{'📅', "date"}, {'⏰', "alarm_clock"}, {'⏱', "stopwatch"}, {'⌛', "hourglass"},
+	{'⏳', "hourglass_flowing_sand"}, {'🕐', "clock1"}, {'🔧', "wrench"}, {'🔨', "hammer"},
+	{'⚙', "gear"}, {'🛠', "hammer_and_wrench"}, {'🧰', "toolbox"}, {'🔗', "link"},
+	{'🏠', "house"}, {'🏢', "office"}, {'🚪', "door"}, {'🪟', "window"},
+	{'🚗', "car"}, {'🚲', "bike"}, {'🚀', "rocket"}, {'✈', "airplane"},
+	{'⛽', "fuelpump"}, {'💰', "moneybag"}, {'🔍', "mag"}, {'🗑', "wastebasket"},
+	// Communication
+	{'💬', "speech_balloon"}, {'🗨', "left_speech_bubble"}, {'💭', "thought_balloon"}, {'📧', "email"},
+	{'✉', "envelope"}, {'📨', "incoming_envelope"}, {'📞', "telephone_receiver"}, {'📻', "radio"},
+	{'🤖', "robot"}, {'👤', "bust_in_silhouette"}, {'👥', "busts_in_silhouette"}, {'🐛', "bug"},
+}
+
+var (
+	byEmoji = make(map[rune]string, len(emojiCodes))
+	byCode  = make(map[string]rune, len(emojiCodes))
+
+	shortcodeRE = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+)
+
+func init() {
+	for _, e := range emojiCodes {
+		byEmoji[e.emoji] = e.code
+		byCode[e.code] = e.emoji
+	}
+}
+
+// EmojiToShortcodes replaces known emoji with their :shortcode:, e.g. "🔋"
+// with ":battery:", for clients that render emoji poorly.
+func EmojiToShortcodes(s string) string {
+	var sb strings.Builder
+	skipVS := false
+	for _, r := range s {
+		if skipVS && r == variationSelector {
+			skipVS = false
+			continue
+		}
+		code, ok := byEmoji[r]
+		skipVS = ok
+		if !ok {
+			sb.WriteRune(r)
+			continue
+		}
+		sb.WriteByte(':')
+		sb.WriteString(code)
+		sb.WriteByte(':')
+	}
+	return sb.String()
+}
+
+// ShortcodesToEmoji replaces known :shortcode:s with their emoji, e.g.
+// ":battery:" with "🔋".
+func ShortcodesToEmoji(s string) string {
+	if !strings.Contains(s, ":") {
+		return s
+	}
+	return shortcodeRE.ReplaceAllStringFunc(s, func(m string) string {
+		if r, ok := byCode[m[1:len(m)-1]]; ok {
+			return string(r)
+		}
+		return m
+	})
+}
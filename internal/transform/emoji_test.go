@@ -0,0 +1,47 @@
+package transform
+
+import "testing"
+
+func TestEmojiToShortcodes(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"🔋 87% 📡 -70dB", ":battery: 87% :satellite: -70dB"},
+		{"🌡️ 21.5°C", ":thermometer: 21.5°C"}, // variation selector dropped
+		{"ok ✅", "ok :white_check_mark:"},
+		{"unknown 🦩 stays", "unknown 🦩 stays"},
+		{"no emoji at 12:30:00", "no emoji at 12:30:00"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := EmojiToShortcodes(tt.in); got != tt.want {
+			t.Errorf("EmojiToShortcodes(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestShortcodesToEmoji(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{":battery: 87% :satellite:", "🔋 87% 📡"},
+		{":+1: :unknown_code:", "👍 :unknown_code:"},
+		{"at 12:30:00", "at 12:30:00"},
+	}
+	for _, tt := range tests {
+		if got := ShortcodesToEmoji(tt.in); got != tt.want {
+			t.Errorf("ShortcodesToEmoji(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEmojiCodes_Unique(t *testing.T) {
+	if len(byEmoji) != len(emojiCodes) || len(byCode) != len(emojiCodes) {
+		t.Errorf("%d entries, but %d distinct emoji and %d distinct codes", len(emojiCodes), len(byEmoji), len(byCode))
+	}
+	for _, e := range emojiCodes {
+		if got := ShortcodesToEmoji(EmojiToShortcodes(string(e.emoji))); got != string(e.emoji) {
+			t.Errorf("%q does not round-trip: %q", e.emoji, got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dyuri/mqtt2irc/internal/irc"
+	"github.com/dyuri/mqtt2irc/pkg/types"
+)
+
+// Options is what a transform is created with: the bridge's message limits
+// and the argument given after "=" in its config entry (e.g. "100" for
+// "truncate=100"), or "".
+type Options struct {
+	Limits irc.Limits
+	Arg    string
+}
+
+// Factory creates a transform; it fails on an invalid argument.
+type Factory func(opts Options) (Func, error)
+
+// registry holds the registered transform factories. The built-ins are
+// registered by this package (see builtins.go).
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+	docs      map[string]types.ComponentDoc
+}{factories: map[string]Factory{}, docs: map[string]types.ComponentDoc{}}
+
+// Register adds a Factory to the registry under name, replacing any factory
+// registered under the same name.
+func Register(name string, factory Factory) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.factories[name] = factory
+}
+
+// Document records the description of the transform registered under name
+// and of its argument, for "mqtt2irc describe transforms".
+func Document(name string, doc types.ComponentDoc) {
+	registry.Lock()
+	defer registry.Unlock()
+	doc.Name = name
+	registry.docs[name] = doc
+}
+
+// Docs returns the descriptions of the registered transforms, sorted by
+// name. A transform registered without Document has only its name.
+func Docs() []types.ComponentDoc {
+	registry.RLock()
+	defer registry.RUnlock()
+	docs := make([]types.ComponentDoc, 0, len(registry.factories))
+	for name := range registry.factories {
+		doc, ok := registry.docs[name]
+		if !ok {
+			doc = types.ComponentDoc{Name: name}
+		}
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Name < docs[j].Name })
+	return docs
+}
+
+// List returns the names of the registered transforms, sorted.
+func List() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the transform named by a config entry, "name" or "name=arg".
+func New(entry string, limits irc.Limits) (Func, error) {
+	name, arg, _ := strings.Cut(entry, "=")
+	registry.RLock()
+	factory, ok := registry.factories[name]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown transform %q (registered: %s)", name, strings.Join(List(), ", "))
+	}
+	fn, err := factory(Options{Limits: limits, Arg: arg})
+	if err != nil {
+		return nil, fmt.Errorf("transform %s: %w", name, err)
+	}
+	return fn, nil
+}
+
+// Chain creates the transforms named by entries and returns them as one, run
+// in order. It returns nil for no entries.
+func Chain(entries []string, limits irc.Limits) (Func, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	fns := make([]Func, 0, len(entries))
+	for _, entry := range entries {
+		fn, err := New(entry, limits)
+		if err != nil {
+			return nil, err
+		}
+		fns = append(fns, fn)
+	}
+	if len(fns) == 1 {
+		return fns[0], nil
+	}
+	return func(s string) string {
+		for _, fn := range fns {
+			s = fn(s)
+		}
+		return s
+	}, nil
+}
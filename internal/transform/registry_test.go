@@ -0,0 +1,157 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dyuri/mqtt2irc/internal/irc"
+)
+
+func TestChain(t *testing.T) {
+	limits := irc.Limits{MaxLength: 12, Suffix: "…"}
+	fn, err := Chain([]string{"strip_colors", "ascii", "prefix=[x]", "truncate"}, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fn("\x0304,01Größe\x0f: 5"); got != "[x] Grosse:…" {
+		t.Errorf("chain = %q", got)
+	}
+
+	fn, err = Chain([]string{"truncate=5"}, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fn("abcdefgh"); got != "abcd…" {
+		t.Errorf("truncate=5 = %q", got)
+	}
+
+	if fn, err := Chain(nil, limits); fn != nil || err != nil {
+		t.Errorf("Chain(nil) = %v, %v", fn, err)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	for _, entry := range []string{"nope", "ascii=1", "truncate=x", "truncate=0", "prefix", "wrap_urls"} {
+		if _, err := New(entry, irc.Limits{}); err == nil {
+			t.Errorf("New(%q) succeeded", entry)
+		}
+	}
+	_, err := New("nope", irc.Limits{})
+	if !strings.Contains(err.Error(), "strip_colors") {
+		t.Errorf("error does not list the transforms: %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test_upper", noArg(strings.ToUpper))
+	defer func() {
+		registry.Lock()
+		delete(registry.factories, "test_upper")
+		registry.Unlock()
+	}()
+	fn, err := New("test_upper", irc.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fn("abc"); got != "ABC" {
+		t.Errorf("test_upper = %q", got)
+	}
+}
+
+func TestStripColors(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"\x02bold\x02 \x1ditalic\x1d \x1funder\x1f", "bold italic under"},
+		{"\x034red\x03 \x0312,1blue\x03", "red blue"},
+		{"\x033,temp", ",temp"}, // a comma not followed by a color
+		{"\x04ff0000,00ff00hex\x0f", "hex"},
+		{"1,5 no codes", "1,5 no codes"},
+	}
+	for _, tt := range tests {
+		if got := StripColors(tt.in); got != tt.want {
+			t.Errorf("StripColors(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestASCII(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Árvíztűrő tükörfúrógép", "Arvizturo tukorfurogep"},
+		{"Straße – „quoted“…", "Strasse - \"quoted\"..."},
+		{"21.5°C", "21.5 degC"},
+		{"🔋 87%", "? 87%"},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		if got := ASCII(tt.in); got != tt.want {
+			t.Errorf("ASCII(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDocs(t *testing.T) {
+	docs := Docs()
+	if len(docs) != len(List()) {
+		t.Fatalf("Docs() has %d entries, List() %d", len(docs), len(List()))
+	}
+	for _, doc := range docs {
+		if doc.Summary == "" {
+			t.Errorf("transform %q has no description", doc.Name)
+		}
+	}
+
+	Register("test_undocumented", noArg(ASCII))
+	defer func() {
+		registry.Lock()
+		delete(registry.factories, "test_undocumented")
+		registry.Unlock()
+	}()
+	found := false
+	for _, doc := range Docs() {
+		if doc.Name == "test_undocumented" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("undocumented transform missing from Docs()")
+	}
+}
+
+func TestStripURLs(t *testing.T) {
+	for in, want := range map[string]string{
+		"no links here":                              "no links here",
+		"see https://evil.example/x?a=1 now":         "see now",
+		"HTTP://A.B and www.example.com/path.":       "and",
+		"ftp://files.example":                        "",
+		"mail me at a@b.c, it's not a link":          "mail me at a@b.c, it's not a link",
+		"text http://a.example\x02bold\x02 text end": "text text end",
+	} {
+		if got := StripURLs(in); got != want {
+			t.Errorf("StripURLs(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMarkAndWrapURLs(t *testing.T) {
+	line := "see https://evil.example/x?a=1 now"
+	for entry, want := range map[string]string{
+		"mark_urls":        "see [link removed] now",
+		"mark_urls=<link>": "see <link> now",
+		"wrap_urls=https://check.example/?u={url}&v=1": "see https://check.example/?u=https%3A%2F%2Fevil.example%2Fx%3Fa%3D1&v=1 now",
+		"wrap_urls=https://s.example/":                 "see https://s.example/https%3A%2F%2Fevil.example%2Fx%3Fa%3D1 now",
+	} {
+		fn, err := New(entry, irc.Limits{})
+		if err != nil {
+			t.Fatalf("New(%q): %v", entry, err)
+		}
+		if got := fn(line); got != want {
+			t.Errorf("%s = %q, want %q", entry, got, want)
+		}
+		if got := fn("no links"); got != "no links" {
+			t.Errorf("%s changed a line without links: %q", entry, got)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package types
+
+import "time"
+
+// Ack is an alert acknowledged from IRC with !ack, as published to
+// bridge.ack_topic.
+type Ack struct {
+	ID      int       `json:"id"`
+	Alert   string    `json:"alert"` // the processor's key of the alert
+	Topic   string    `json:"topic"` // MQTT topic the alert came from
+	By      string    `json:"by"`    // nick of the admin
+	Comment string    `json:"comment,omitempty"`
+	Time    time.Time `json:"time"`
+}
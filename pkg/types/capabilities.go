@@ -0,0 +1,18 @@
+package types
+
+// CapabilitiesSchema is the version of the Capabilities document format. It
+// is raised when fields change meaning or are removed, not when some are
+// added.
+const CapabilitiesSchema = 1
+
+// Capabilities describes what a bridge instance supports, as published
+// (retained) to bridge.capabilities_topic and shown by !features.
+type Capabilities struct {
+	Schema     int      `json:"schema"`
+	Version    string   `json:"version"`
+	Instance   string   `json:"instance,omitempty"` // instance_name
+	Features   []string `json:"features"`           // enabled optional features, sorted
+	Mappings   int      `json:"mappings"`           // mappings in effect, including runtime-added ones
+	Processors []string `json:"processors"`         // registered processor names
+	Transforms []string `json:"transforms"`         // registered output transform names
+}
@@ -0,0 +1,19 @@
+package types
+
+// ComponentDoc describes a registered component (a processor or transform)
+// and the options it takes, as printed by "mqtt2irc describe".
+type ComponentDoc struct {
+	Name    string      `json:"name"`
+	Summary string      `json:"summary"`
+	Options []OptionDoc `json:"options,omitempty"`
+}
+
+// OptionDoc describes one option of a component: a processor_config key,
+// or a transform's argument.
+type OptionDoc struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`              // string, duration, int, bool, list, map or template
+	Default     string `json:"default,omitempty"` // empty = none
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,23 @@
+package types
+
+// MappingInfo describes a configured mapping and its runtime state, as shown
+// to admin commands.
+type MappingInfo struct {
+	Index    int // 1-based position in bridge.mappings
+	Topic    string
+	Channels []string
+	Tenant   string // empty for mappings not owned by a tenant
+	Paused   bool
+	Format   string
+	Changed  bool // Format was changed at runtime
+	Added    bool // added at runtime; a config reload drops it
+}
+
+// Field is a field found in recent JSON payloads of a topic, as shown by
+// !fields. Nested fields are named by their dotted path.
+type Field struct {
+	Name    string // e.g. "temperature" or "payload.temperature"
+	Type    string // JSON type: string, number, boolean, array, object or null
+	Example string // value from the most recent payload that has the field
+	Seen    int    // number of samples that have the field
+}
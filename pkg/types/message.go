@@ -4,8 +4,38 @@ import "time"
 
 // Message represents a message flowing from MQTT to IRC
 type Message struct {
+	ID        string // short ID assigned on receipt, logged as msg_id to correlate log lines
 	Topic     string
 	Payload   []byte
 	Timestamp time.Time
 	QoS       byte
+	Priority  Priority
+	Meta      map[string]string // static attributes from bridge.metadata; nil if none
+
+	// JSON is the payload decoded as a JSON object (nil if it is not one),
+	// cached by the bridge so that mappings, processors and logging share a
+	// single parse; JSONParsed reports whether it is set. Treat as read-only.
+	JSON       map[string]interface{}
+	JSONParsed bool
+}
+
+// Priority controls how a message is treated when the bridge queue is under pressure.
+type Priority int
+
+const (
+	PriorityNormal Priority = iota // dropped when the queue is full
+	PriorityLow                    // dropped early, once the queue passes its low-priority watermark
+	PriorityHigh                   // never dropped; delivered ahead of other messages
+)
+
+// String returns the priority name.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
 }
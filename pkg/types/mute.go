@@ -0,0 +1,10 @@
+package types
+
+import "time"
+
+// Mute is an active runtime suppression rule, as shown to admin commands.
+type Mute struct {
+	Target     string // topic pattern or node ID (!xxxxxxxx)
+	Until      time.Time
+	Suppressed int // messages suppressed so far
+}
@@ -0,0 +1,14 @@
+package types
+
+import "errors"
+
+// PurgeResult counts what !purge removed from each store.
+type PurgeResult struct {
+	Nodes   int `json:"nodes"`   // node registry entries
+	History int `json:"history"` // channel history lines
+	Samples int `json:"samples"` // payload samples kept for !fields
+}
+
+// ErrInvalidPurge is wrapped by purge errors caused by the request, such as
+// an unknown kind or a malformed node ID, rather than by a store.
+var ErrInvalidPurge = errors.New("invalid purge request")
@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// Stages of a QueuedMessage.
+const (
+	QueueStageBridge  = "bridge"  // received, waiting to be processed
+	QueueStageChannel = "channel" // formatted, waiting in a channel's outbound queue
+)
+
+// QueuedMessage is a message waiting to be sent, as listed by GET
+// /api/v1/queue.
+type QueuedMessage struct {
+	ID         string    `json:"id"`
+	Topic      string    `json:"topic"`
+	Stage      string    `json:"stage"` // QueueStageBridge or QueueStageChannel
+	Received   time.Time `json:"received"`
+	AgeSeconds float64   `json:"age_seconds"`
+	Channels   []string  `json:"channels"` // target channels; for the bridge stage, those of the matching mappings
+	Priority   string    `json:"priority"` // low, normal or high
+	Preview    string    `json:"preview"`  // the payload, or the formatted line, shortened
+}
+
+// QueueListing is the content of the queues, in the order it is sent.
+type QueueListing struct {
+	Total    int             `json:"total"` // queued messages, including those past the limit
+	Messages []QueuedMessage `json:"messages"`
+}
@@ -0,0 +1,9 @@
+package types
+
+// Review is a message held in a mapping's review channel, as shown to admin
+// commands.
+type Review struct {
+	ID      int
+	Topic   string
+	Channel string // where approving it sends it
+}
@@ -0,0 +1,17 @@
+package types
+
+import "time"
+
+// SentMessage is a line the bridge sent to IRC, as passed to send hooks
+// (Bridge.OnSent, and as JSON to bridge.sent_hook).
+type SentMessage struct {
+	MsgID    string            `json:"msg_id"`
+	Topic    string            `json:"topic"`
+	Channel  string            `json:"channel"`
+	Text     string            `json:"text"` // the line as sent
+	Tenant   string            `json:"tenant,omitempty"`
+	Priority string            `json:"priority"` // low, normal or high
+	Meta     map[string]string `json:"meta,omitempty"`
+	Received time.Time         `json:"received"`
+	Sent     time.Time         `json:"sent"`
+}
@@ -0,0 +1,43 @@
+package types
+
+import "time"
+
+// SendStats describes the state of the IRC send path at the moment a message
+// passed the rate limiter, for latency checks such as !ping.
+type SendStats struct {
+	Wait          time.Duration // time spent waiting for the rate limiter
+	QueueSize     int           // messages waiting in the bridge queues
+	QueueCapacity int
+	Pace          int     // messages sent to IRC in the last minute
+	Rate          float64 // configured messages per second
+	Burst         int
+}
+
+// DropCount summarizes the messages dropped for one reason.
+type DropCount struct {
+	Reason    string
+	Count     uint64
+	LastTopic string // topic of the most recent drop
+	Last      time.Time
+}
+
+// CommandCount summarizes the admin commands sent by one nick.
+type CommandCount struct {
+	Nick         string
+	Commands     uint64 // authorized commands, including failed ones
+	Failures     uint64 // commands that failed (errors, unknown or not permitted)
+	Unauthorized uint64 // attempts without an allow_list or tenant operator match
+	LastCommand  string // name of the most recent command or attempt
+	Last         time.Time
+}
+
+// TopicCount summarizes the messages of one topic, or of a roll-up bucket
+// of topics not tracked one by one (bridge.topic_stats).
+type TopicCount struct {
+	Topic    string // the topic, or the bucket's pattern or "(other)"
+	Rollup   bool   // Topic is a bucket
+	Received uint64
+	Sent     uint64 // deliveries to channels
+	Dropped  uint64 // dropped by the bridge; see DropCount
+	Last     time.Time
+}
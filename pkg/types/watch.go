@@ -0,0 +1,14 @@
+package types
+
+import "time"
+
+// Watch is a temporary condition on payload fields set up with !watch, as
+// shown to admin commands.
+type Watch struct {
+	ID      int
+	Owner   string // nick that set it up
+	Target  string // channel or nick notified
+	Pattern string // MQTT topic pattern
+	Cond    string // e.g. "temperature > 30"
+	Until   time.Time
+}